@@ -0,0 +1,121 @@
+// Package acl provides tools for managing ACLs across ZooKeeper subtrees.
+package acl
+
+import (
+	"sort"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Change describes a node whose ACL didn't match the desired spec.
+type Change struct {
+	Path string
+	Old  []zookeeper.ACL
+	New  []zookeeper.ACL
+	// Applied is false for dry runs.
+	Applied bool
+}
+
+type options struct {
+	rateLimit int
+}
+
+// Option configures ApplyRecursive.
+type Option func(options) options
+
+// WithRateLimit limits ApplyRecursive to perSecond ZooKeeper requests per
+// second, to avoid loading the ensemble when walking large trees.
+func WithRateLimit(perSecond int) Option {
+	return func(o options) options {
+		o.rateLimit = perSecond
+		return o
+	}
+}
+
+// ApplyRecursive walks the subtree rooted at path and sets the ACL of every
+// node that doesn't already match spec. Nodes are compared regardless of the
+// order of their ACL entries, and compliant nodes are left untouched. With
+// dryRun, nothing is changed and the returned changes describe what would be
+// done.
+//
+// ACLs are set at the version they were read at, so a concurrent ACL change
+// aborts the walk rather than being overwritten. Changes made before an error
+// are returned along with it.
+func ApplyRecursive(s session.Session, path string, spec []zookeeper.ACL, dryRun bool, opts ...Option) ([]Change, error) {
+	var o options
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	throttle := func() {}
+	if o.rateLimit > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(o.rateLimit))
+		defer ticker.Stop()
+		throttle = func() { <-ticker.C }
+	}
+
+	var changes []Change
+	nodes := []string{path}
+	for len(nodes) > 0 {
+		node := nodes[0]
+		nodes = nodes[1:]
+
+		throttle()
+		current, stat, err := s.ACL(node)
+		if err != nil {
+			return changes, err
+		}
+
+		// Children are listed before the ACL is set, so that a spec denying
+		// us READ doesn't keep the walk from going on.
+		throttle()
+		children, _, err := s.Children(node)
+		if err != nil {
+			return changes, err
+		}
+
+		if !Equal(current, spec) {
+			change := Change{Path: node, Old: current, New: spec}
+			if !dryRun {
+				throttle()
+				if err := s.SetACL(node, spec, stat.AVersion()); err != nil {
+					return changes, err
+				}
+				change.Applied = true
+			}
+			changes = append(changes, change)
+		}
+
+		parent := node
+		if parent == "/" {
+			parent = ""
+		}
+		sort.Strings(children)
+		for _, child := range children {
+			nodes = append(nodes, parent+"/"+child)
+		}
+	}
+
+	return changes, nil
+}
+
+// Equal reports whether a and b contain the same ACL entries, in any order.
+func Equal(a, b []zookeeper.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[zookeeper.ACL]int, len(a))
+	for _, entry := range a {
+		counts[entry]++
+	}
+	for _, entry := range b {
+		if counts[entry] == 0 {
+			return false
+		}
+		counts[entry]--
+	}
+	return true
+}
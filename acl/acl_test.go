@@ -0,0 +1,141 @@
+package acl
+
+import (
+	"context"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	open       = zookeeper.WorldACL(zookeeper.PERM_ALL)
+	restricted = zookeeper.WorldACL(zookeeper.PERM_READ | zookeeper.PERM_DELETE | zookeeper.PERM_ADMIN)
+)
+
+func TestEqualShouldIgnoreOrder(t *testing.T) {
+	a := []zookeeper.ACL{{Perms: zookeeper.PERM_ALL, Scheme: "world", Id: "anyone"}, {Perms: zookeeper.PERM_READ, Scheme: "ip", Id: "10.0.0.1"}}
+	b := []zookeeper.ACL{a[1], a[0]}
+
+	assert.True(t, Equal(a, b))
+	assert.False(t, Equal(a, a[:1]))
+	assert.False(t, Equal(a, []zookeeper.ACL{a[0], a[0]}))
+}
+
+func TestApplyRecursiveDryRunShouldNotChangeACLs(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		for _, node := range []string{"/test", "/test/foo"} {
+			if _, err := s.Create(node, "", 0, open); err != nil {
+				t.Fatal("Create error: ", err)
+			}
+		}
+
+		changes, err := ApplyRecursive(s, "/test", restricted, true)
+		if err != nil {
+			t.Fatal("ApplyRecursive error: ", err)
+		}
+		assert.Len(t, changes, 2)
+
+		current, _, err := s.ACL("/test/foo")
+		if err != nil {
+			t.Fatal("ACL error: ", err)
+		}
+		assert.True(t, Equal(open, current))
+	})
+}
+
+func TestApplyRecursiveShouldSkipCompliantNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "", 0, open); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+		if _, err := s.Create("/test/foo", "", 0, restricted); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		changes, err := ApplyRecursive(s, "/test", restricted, false, WithRateLimit(100))
+		if err != nil {
+			t.Fatal("ApplyRecursive error: ", err)
+		}
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "/test", changes[0].Path)
+		assert.True(t, changes[0].Applied)
+	})
+}
+
+func TestApplyRecursiveShouldWalkPastACLsDenyingRead(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		nodes := []string{"/test", "/test/foo", "/test/foo/bar"}
+		for _, node := range nodes {
+			if _, err := s.Create(node, "", 0, open); err != nil {
+				t.Fatal("Create error: ", err)
+			}
+		}
+		// Give READ back so the tree can be cleaned up.
+		defer func() {
+			for _, node := range nodes {
+				_ = s.SetACL(node, open, -1)
+			}
+		}()
+
+		writeOnly := zookeeper.WorldACL(zookeeper.PERM_ALL &^ zookeeper.PERM_READ)
+		changes, err := ApplyRecursive(s, "/test", writeOnly, false)
+		if err != nil {
+			t.Fatal("ApplyRecursive error: ", err)
+		}
+		assert.Len(t, changes, 3)
+
+		for _, node := range nodes {
+			current, _, err := s.ACL(node)
+			if err != nil {
+				t.Fatal("ACL error: ", err)
+			}
+			assert.True(t, Equal(writeOnly, current), node)
+		}
+	})
+}
+
+func TestAuditSubtreeShouldReportEveryNode(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		for _, path := range []string{"/test", "/test/a", "/test/a/b"} {
+			if _, err := s.Create(path, "data", 0, open); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.Create("/test/eph", "", zookeeper.EPHEMERAL, restricted); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, errc := AuditSubtree(context.Background(), s, "/test", WithAuditConcurrency(2))
+		audited := make(map[string]AuditEntry)
+		for entry := range entries {
+			audited[entry.Path] = entry
+		}
+		assert.NoError(t, <-errc)
+
+		assert.Len(t, audited, 4)
+		assert.Equal(t, 4, audited["/test/a/b"].DataLength)
+		assert.Equal(t, 1, audited["/test/a"].NumChildren)
+		assert.Zero(t, audited["/test/a"].Owner)
+		assert.NotZero(t, audited["/test/eph"].Owner)
+		assert.True(t, Equal(restricted, audited["/test/eph"].ACL))
+	})
+}
+
+func TestAuditSubtreeShouldStopWhenCancelled(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		for _, path := range []string{"/test", "/test/a", "/test/b"} {
+			if _, err := s.Create(path, "", 0, open); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		entries, errc := AuditSubtree(ctx, s, "/test")
+		<-entries
+		cancel()
+		assert.ErrorIs(t, <-errc, context.Canceled)
+	})
+}
@@ -0,0 +1,98 @@
+package acl
+
+import (
+	"context"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultAuditConcurrency is the number of nodes AuditSubtree reads at once
+// unless WithAuditConcurrency is given.
+const DefaultAuditConcurrency = 8
+
+// AuditEntry describes a node's permissions and ownership, for audits.
+type AuditEntry struct {
+	Path string
+	ACL  []zookeeper.ACL
+	// Owner is the session owning the node if it is ephemeral, and zero
+	// otherwise.
+	Owner       int64
+	DataLength  int
+	NumChildren int
+	Created     time.Time
+	Modified    time.Time
+	// Version and ACLVersion are the number of changes to the node's data
+	// and ACL.
+	Version    int
+	ACLVersion int
+}
+
+type auditOptions struct {
+	concurrency int
+}
+
+// AuditOption configures AuditSubtree.
+type AuditOption func(auditOptions) auditOptions
+
+// WithAuditConcurrency lets AuditSubtree read up to n nodes at once.
+func WithAuditConcurrency(n int) AuditOption {
+	return func(o auditOptions) auditOptions {
+		o.concurrency = n
+		return o
+	}
+}
+
+// AuditSubtree reads the ACL and Stat of path and every node below it, and
+// streams an entry for each on the returned channel, in no particular order.
+// The channel is closed once the subtree has been read, after which the
+// error channel delivers the error that stopped the audit, or nil. Nodes
+// deleted while the audit is running are skipped.
+//
+// Entries must be received promptly, as the audit waits for each to be taken.
+// Cancelling ctx stops the audit, which then reports ctx's error.
+func AuditSubtree(ctx context.Context, s session.Session, path string, opts ...AuditOption) (<-chan AuditEntry, <-chan error) {
+	o := auditOptions{concurrency: DefaultAuditConcurrency}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	entries := make(chan AuditEntry)
+	errc := make(chan error, 1)
+	go func() {
+		err := session.Walk(s, path, func(node string, _ []byte, _ *zookeeper.Stat) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			acl, stat, err := s.ACL(node)
+			if zookeeper.IsError(err, zookeeper.ZNONODE) {
+				return session.SkipSubtree
+			}
+			if err != nil {
+				return err
+			}
+
+			entry := AuditEntry{
+				Path:        node,
+				ACL:         acl,
+				Owner:       stat.EphemeralOwner(),
+				DataLength:  stat.DataLength(),
+				NumChildren: stat.NumChildren(),
+				Created:     stat.CTime(),
+				Modified:    stat.MTime(),
+				Version:     stat.Version(),
+				ACLVersion:  stat.AVersion(),
+			}
+			select {
+			case entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, session.WithConcurrency(o.concurrency))
+		close(entries)
+		errc <- err
+	}()
+	return entries, errc
+}
@@ -0,0 +1,167 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// permLetters maps permissions to their letters in the order zkCli prints
+// them.
+var permLetters = []struct {
+	perm   uint32
+	letter byte
+}{
+	{zookeeper.PERM_CREATE, 'c'},
+	{zookeeper.PERM_DELETE, 'd'},
+	{zookeeper.PERM_READ, 'r'},
+	{zookeeper.PERM_WRITE, 'w'},
+	{zookeeper.PERM_ADMIN, 'a'},
+}
+
+// Format renders entry as "scheme:id:perms", e.g. "world:anyone:cdrwa", with
+// the permissions in the order zkCli prints them.
+func Format(entry zookeeper.ACL) string {
+	var perms strings.Builder
+	for _, p := range permLetters {
+		if entry.Perms&p.perm != 0 {
+			perms.WriteByte(p.letter)
+		}
+	}
+	return entry.Scheme + ":" + entry.Id + ":" + perms.String()
+}
+
+// FormatList renders every entry of acl with Format.
+func FormatList(acl []zookeeper.ACL) []string {
+	formatted := make([]string, len(acl))
+	for i, entry := range acl {
+		formatted[i] = Format(entry)
+	}
+	return formatted
+}
+
+// Parse parses an entry in the form rendered by Format. The id runs from the
+// first to the last colon, so ids containing colons, such as digests, parse
+// correctly.
+func Parse(s string) (zookeeper.ACL, error) {
+	first, last := strings.Index(s, ":"), strings.LastIndex(s, ":")
+	if first < 0 || first == last {
+		return zookeeper.ACL{}, fmt.Errorf("parsing ACL %q: expected scheme:id:perms", s)
+	}
+
+	entry := zookeeper.ACL{Scheme: s[:first], Id: s[first+1 : last]}
+	for _, letter := range []byte(s[last+1:]) {
+		found := false
+		for _, p := range permLetters {
+			if p.letter == letter {
+				entry.Perms |= p.perm
+				found = true
+			}
+		}
+		if !found {
+			return zookeeper.ACL{}, fmt.Errorf("parsing ACL %q: unknown permission %q", s, letter)
+		}
+	}
+	return entry, nil
+}
+
+// ParseList parses every entry of entries with Parse.
+func ParseList(entries []string) ([]zookeeper.ACL, error) {
+	acl := make([]zookeeper.ACL, len(entries))
+	for i, s := range entries {
+		entry, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		acl[i] = entry
+	}
+	return acl, nil
+}
+
+// GetACL returns the ACL of path in the form rendered by Format.
+func GetACL(s session.Session, path string) ([]string, *zookeeper.Stat, error) {
+	acl, stat, err := s.ACL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return FormatList(acl), stat, nil
+}
+
+// SetACL parses entries with ParseList and sets them as the ACL of path, at
+// the given ACL version.
+func SetACL(s session.Session, path string, entries []string, aversion int) error {
+	acl, err := ParseList(entries)
+	if err != nil {
+		return err
+	}
+	return s.SetACL(path, acl, aversion)
+}
+
+// Diff describes how to get from one ACL to another. Entries whose scheme and
+// id appear in both but with different permissions are reported as Changed
+// rather than as a removal and an addition.
+type Diff struct {
+	Added   []zookeeper.ACL
+	Removed []zookeeper.ACL
+	Changed []PermChange
+}
+
+// PermChange is an entry whose permissions differ between two ACLs.
+type PermChange struct {
+	Scheme string
+	Id     string
+	Old    uint32
+	New    uint32
+}
+
+// Empty reports whether the ACLs compared were equal.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d Diff) String() string {
+	var lines []string
+	for _, entry := range d.Removed {
+		lines = append(lines, "- "+Format(entry))
+	}
+	for _, entry := range d.Added {
+		lines = append(lines, "+ "+Format(entry))
+	}
+	for _, c := range d.Changed {
+		old := Format(zookeeper.ACL{Scheme: c.Scheme, Id: c.Id, Perms: c.Old})
+		lines = append(lines, "~ "+old+" -> "+Format(zookeeper.ACL{Scheme: c.Scheme, Id: c.Id, Perms: c.New}))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffACL compares the current and desired ACLs, in the order their entries
+// appear in each.
+func DiffACL(current, desired []zookeeper.ACL) Diff {
+	type key struct{ scheme, id string }
+	perms := make(map[key]uint32, len(current))
+	for _, entry := range current {
+		perms[key{entry.Scheme, entry.Id}] |= entry.Perms
+	}
+	wanted := make(map[key]bool, len(desired))
+
+	var d Diff
+	for _, entry := range desired {
+		k := key{entry.Scheme, entry.Id}
+		wanted[k] = true
+		old, ok := perms[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, entry)
+		case old != entry.Perms:
+			d.Changed = append(d.Changed, PermChange{Scheme: entry.Scheme, Id: entry.Id, Old: old, New: entry.Perms})
+		}
+	}
+	for _, entry := range current {
+		if !wanted[key{entry.Scheme, entry.Id}] {
+			d.Removed = append(d.Removed, entry)
+		}
+	}
+	return d
+}
@@ -0,0 +1,37 @@
+package acl
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAndParseShouldRoundTrip(t *testing.T) {
+	entries := []string{"world:anyone:cdrwa", "digest:bob:aGFzaA==:r", "ip:10.0.0.1:rw"}
+
+	acl, err := ParseList(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, zookeeper.WorldACL(zookeeper.PERM_ALL)[0], acl[0])
+	assert.Equal(t, zookeeper.ACL{Scheme: "digest", Id: "bob:aGFzaA==", Perms: zookeeper.PERM_READ}, acl[1])
+	assert.Equal(t, entries, FormatList(acl))
+}
+
+func TestParseShouldRejectMalformedEntries(t *testing.T) {
+	for _, s := range []string{"", "world", "world:anyone", "world:anyone:rx"} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestDiffACLShouldReportChanges(t *testing.T) {
+	current, _ := ParseList([]string{"world:anyone:r", "ip:10.0.0.1:cdrwa"})
+	desired, _ := ParseList([]string{"world:anyone:rw", "digest:bob:hash:cdrwa"})
+
+	d := DiffACL(current, desired)
+	assert.False(t, d.Empty())
+	assert.Equal(t, "- ip:10.0.0.1:cdrwa\n+ digest:bob:hash:cdrwa\n~ world:anyone:r -> world:anyone:rw", d.String())
+	assert.True(t, DiffACL(current, current).Empty())
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func withTestClient(t *testing.T, f func(*session.ZKSession, *Client)) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	s.DeleteRecursive("/test")
+
+	dir, err := ioutil.TempDir("", "gozk-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "agent.sock")
+	srv := NewServer(s)
+	go srv.ListenAndServe(socket)
+	defer srv.Close()
+
+	var client *Client
+	for i := 0; i < 50; i++ {
+		if client, err = Dial(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal("Failed to connect to agent: ", err)
+	}
+	defer client.Close()
+
+	f(s, client)
+}
+
+func TestClientShouldReadThroughAgent(t *testing.T) {
+	withTestClient(t, func(s *session.ZKSession, client *Client) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		data, stat, err := client.Get("/test")
+		if err != nil {
+			t.Fatal("Get error: ", err)
+		}
+		assert.Equal(t, "foo", data)
+		assert.Equal(t, 0, stat.Version)
+
+		_, _, err = client.Get("/test/missing")
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+	})
+}
+
+func TestClientShouldReadBinaryData(t *testing.T) {
+	withTestClient(t, func(s *session.ZKSession, client *Client) {
+		binary := "\xff\xfe\x00\x80spam\xc3"
+		if _, err := s.Create("/test", binary, 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		data, _, err := client.Get("/test")
+		if err != nil {
+			t.Fatal("Get error: ", err)
+		}
+		assert.Equal(t, binary, data)
+	})
+}
+
+func TestResponseShouldKeepBinaryData(t *testing.T) {
+	encoded, err := json.Marshal(response{ID: 1, Data: []byte("\xff\x00\x80")})
+	assert.NoError(t, err)
+
+	var decoded response
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, []byte("\xff\x00\x80"), decoded.Data)
+}
+
+func TestClientWatchShouldFire(t *testing.T) {
+	withTestClient(t, func(s *session.ZKSession, client *Client) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		_, _, watch, err := client.GetW("/test")
+		if err != nil {
+			t.Fatal("GetW error: ", err)
+		}
+
+		if _, err := s.Set("/test", "bar", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+
+		select {
+		case event := <-watch:
+			assert.Equal(t, zookeeper.EVENT_CHANGED, event.Type)
+		case <-time.After(5 * time.Second):
+			t.Error("Failed to receive watch event")
+		}
+	})
+}
+
+func TestClientWatchShouldBeGivenUpWhenCancelled(t *testing.T) {
+	withTestClient(t, func(s *session.ZKSession, client *Client) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, _, watch, err := client.GetWContext(ctx, "/test")
+		if err != nil {
+			t.Fatal("GetWContext error: ", err)
+		}
+		cancel()
+
+		select {
+		case _, ok := <-watch:
+			assert.False(t, ok, "Expected the watch to be closed without an event")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Failed to close the cancelled watch")
+		}
+
+		client.mu.Lock()
+		assert.Empty(t, client.pending)
+		assert.Empty(t, client.watches)
+		client.mu.Unlock()
+
+		_, _, _, err = client.GetWContext(ctx, "/test")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
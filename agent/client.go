@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrClientClosed is returned for requests made after the connection to the
+// agent was lost or closed.
+var ErrClientClosed = errors.New("agent connection closed")
+
+// Client issues read and watch requests through an agent Server. Errors
+// returned by ZooKeeper are reconstructed as *zookeeper.Error values, so
+// zookeeper.IsError works as with a direct session.
+type Client struct {
+	conn net.Conn
+
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan response
+	watches map[uint64]chan zookeeper.Event
+	closed  bool
+}
+
+// Dial connects to the agent listening on the unix socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		pending: make(map[uint64]chan response),
+		watches: make(map[uint64]chan zookeeper.Event),
+	}
+	go c.read()
+	return c, nil
+}
+
+// Close disconnects from the agent. Outstanding watches are closed, and
+// given up by the agent.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) read() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), maxResponseSize)
+	for scanner.Scan() {
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			break
+		}
+
+		c.mu.Lock()
+		if resp.Watch {
+			if watch, ok := c.watches[resp.ID]; ok {
+				delete(c.watches, resp.ID)
+				watch <- *resp.Event
+				close(watch)
+			}
+		} else if pending, ok := c.pending[resp.ID]; ok {
+			delete(c.pending, resp.ID)
+			pending <- resp
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, pending := range c.pending {
+		close(pending)
+		delete(c.pending, id)
+	}
+	for id, watch := range c.watches {
+		close(watch)
+		delete(c.watches, id)
+	}
+}
+
+func (c *Client) call(ctx context.Context, op, path string, watched bool) (response, <-chan zookeeper.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return response{}, nil, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return response{}, nil, ErrClientClosed
+	}
+	c.nextID++
+	id := c.nextID
+	pending := make(chan response, 1)
+	c.pending[id] = pending
+	var watch chan zookeeper.Event
+	if watched {
+		watch = make(chan zookeeper.Event, 1)
+		c.watches[id] = watch
+	}
+	c.mu.Unlock()
+
+	if err := c.send(request{ID: id, Op: op, Path: path}); err != nil {
+		c.forget(id)
+		return response{}, nil, err
+	}
+
+	var resp response
+	var ok bool
+	select {
+	case resp, ok = <-pending:
+	case <-ctx.Done():
+		// The agent handles requests in order, so the watch is set by the
+		// time it reads the cancel.
+		if c.forget(id) {
+			_ = c.send(request{ID: id, Op: opCancel})
+		}
+		return response{}, nil, ctx.Err()
+	}
+	if !ok {
+		return response{}, nil, ErrClientClosed
+	}
+	if resp.Error != nil {
+		if watched {
+			c.forget(id)
+		}
+		if resp.Error.Op == "" {
+			return resp, nil, errors.New(resp.Error.Message)
+		}
+		return resp, nil, &zookeeper.Error{Op: resp.Error.Op, Code: resp.Error.Code, Path: resp.Error.Path}
+	}
+	if !watched {
+		return resp, nil, nil
+	}
+	return resp, c.cancellable(ctx, id, watch), nil
+}
+
+func (c *Client) send(req request) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.encoder.Encode(req)
+}
+
+// forget drops the request of id and closes its watch, if any, reporting
+// whether the watch was still outstanding.
+func (c *Client) forget(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+	watch, ok := c.watches[id]
+	if ok {
+		delete(c.watches, id)
+		close(watch)
+	}
+	return ok
+}
+
+// cancellable forwards the watch of id until ctx is done, then closes it
+// without an event and has the agent give it up.
+func (c *Client) cancellable(ctx context.Context, id uint64, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if ctx.Done() == nil {
+		return watch
+	}
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		defer close(forwarded)
+		select {
+		case event, ok := <-watch:
+			if ok {
+				forwarded <- event
+			}
+		case <-ctx.Done():
+			if c.forget(id) {
+				_ = c.send(request{ID: id, Op: opCancel})
+			}
+		}
+	}()
+	return forwarded
+}
+
+func (c *Client) Get(path string) (string, *Stat, error) {
+	resp, _, err := c.call(context.Background(), opGet, path, false)
+	return string(resp.Data), resp.Stat, err
+}
+
+func (c *Client) GetW(path string) (string, *Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(context.Background(), opGetW, path, true)
+	return string(resp.Data), resp.Stat, watch, err
+}
+
+func (c *Client) Exists(path string) (*Stat, error) {
+	resp, _, err := c.call(context.Background(), opExists, path, false)
+	return resp.Stat, err
+}
+
+func (c *Client) ExistsW(path string) (*Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(context.Background(), opExistsW, path, true)
+	return resp.Stat, watch, err
+}
+
+func (c *Client) Children(path string) ([]string, *Stat, error) {
+	resp, _, err := c.call(context.Background(), opChildren, path, false)
+	return resp.Children, resp.Stat, err
+}
+
+func (c *Client) ChildrenW(path string) ([]string, *Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(context.Background(), opChildrenW, path, true)
+	return resp.Children, resp.Stat, watch, err
+}
+
+// GetWContext is GetW, except that the request and its watch are given up
+// once ctx is done: the watch channel is then closed without an event, and
+// the agent abandons the watch in the shared session.
+func (c *Client) GetWContext(ctx context.Context, path string) (string, *Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(ctx, opGetW, path, true)
+	return string(resp.Data), resp.Stat, watch, err
+}
+
+// ExistsWContext is ExistsW, with the request and its watch given up once
+// ctx is done as with GetWContext.
+func (c *Client) ExistsWContext(ctx context.Context, path string) (*Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(ctx, opExistsW, path, true)
+	return resp.Stat, watch, err
+}
+
+// ChildrenWContext is ChildrenW, with the request and its watch given up
+// once ctx is done as with GetWContext.
+func (c *Client) ChildrenWContext(ctx context.Context, path string) ([]string, *Stat, <-chan zookeeper.Event, error) {
+	resp, watch, err := c.call(ctx, opChildrenW, path, true)
+	return resp.Children, resp.Stat, watch, err
+}
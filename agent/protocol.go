@@ -0,0 +1,108 @@
+// Package agent lets several processes on a host share a single ZooKeeper
+// session for reads and watches. A Server owns the session and listens on a
+// unix socket; each process connects with a thin Client.
+//
+// Requests and responses are exchanged as newline-delimited JSON. Node data
+// goes as base64, so binary values survive the trip.
+package agent
+
+import (
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+const (
+	opGet       = "get"
+	opGetW      = "getw"
+	opExists    = "exists"
+	opExistsW   = "existsw"
+	opChildren  = "children"
+	opChildrenW = "childrenw"
+	// opCancel gives up the watch set by the request of the same ID. It has
+	// no response.
+	opCancel = "cancel"
+)
+
+// watchKinds are the kinds, as session.AbandonWatch takes them, of the
+// watches set by each watching op.
+var watchKinds = map[string]string{
+	opGetW:      "data",
+	opExistsW:   "exists",
+	opChildrenW: "children",
+}
+
+// maxPayload is ZooKeeper's default jute.maxbuffer, the most a node's data,
+// or the names of its children, can take.
+const maxPayload = 1 << 20
+
+// maxResponseSize bounds an encoded response line: data grows by a third as
+// base64, and JSON escapes each byte of child names into at most six.
+const maxResponseSize = 6*maxPayload + 64*1024
+
+type request struct {
+	ID   uint64 `json:"id"`
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+type response struct {
+	ID       uint64           `json:"id"`
+	Data     []byte           `json:"data,omitempty"`
+	Stat     *Stat            `json:"stat,omitempty"`
+	Children []string         `json:"children,omitempty"`
+	Error    *responseError   `json:"error,omitempty"`
+	Watch    bool             `json:"watch,omitempty"`
+	Event    *zookeeper.Event `json:"event,omitempty"`
+}
+
+type responseError struct {
+	Op      string              `json:"op"`
+	Code    zookeeper.ErrorCode `json:"code"`
+	Path    string              `json:"path"`
+	Message string              `json:"message"`
+}
+
+// Stat describes a node, as reported by the agent.
+type Stat struct {
+	Czxid          int64     `json:"czxid"`
+	Mzxid          int64     `json:"mzxid"`
+	Pzxid          int64     `json:"pzxid"`
+	CTime          time.Time `json:"ctime"`
+	MTime          time.Time `json:"mtime"`
+	Version        int       `json:"version"`
+	CVersion       int       `json:"cversion"`
+	AVersion       int       `json:"aversion"`
+	EphemeralOwner int64     `json:"ephemeralOwner"`
+	DataLength     int       `json:"dataLength"`
+	NumChildren    int       `json:"numChildren"`
+}
+
+func newStat(stat *zookeeper.Stat) *Stat {
+	if stat == nil {
+		return nil
+	}
+	return &Stat{
+		Czxid:          stat.Czxid(),
+		Mzxid:          stat.Mzxid(),
+		Pzxid:          stat.Pzxid(),
+		CTime:          stat.CTime(),
+		MTime:          stat.MTime(),
+		Version:        stat.Version(),
+		CVersion:       stat.CVersion(),
+		AVersion:       stat.AVersion(),
+		EphemeralOwner: stat.EphemeralOwner(),
+		DataLength:     stat.DataLength(),
+		NumChildren:    stat.NumChildren(),
+	}
+}
+
+func newResponseError(err error) *responseError {
+	if err == nil {
+		return nil
+	}
+	if zkErr, ok := err.(*zookeeper.Error); ok {
+		return &responseError{Op: zkErr.Op, Code: zkErr.Code, Path: zkErr.Path, Message: err.Error()}
+	}
+	return &responseError{Code: zookeeper.ZSYSTEMERROR, Message: err.Error()}
+}
@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Server serves read and watch requests from Clients using a shared session.
+type Server struct {
+	session session.Session
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// NewServer creates a Server answering requests with s.
+func NewServer(s session.Session) *Server {
+	return &Server{session: s, conns: make(map[net.Conn]struct{})}
+}
+
+// ListenAndServe listens on the unix socket at path, replacing any stale socket
+// file, and serves requests until the server is closed.
+func (srv *Server) ListenAndServe(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// Serve accepts connections on l until the server is closed.
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return l.Close()
+	}
+	srv.listeners = append(srv.listeners, l)
+	srv.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		srv.mu.Lock()
+		srv.conns[conn] = struct{}{}
+		srv.mu.Unlock()
+		go srv.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections and disconnects all clients. The shared
+// session is left open.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.closed = true
+	var err error
+	for _, l := range srv.listeners {
+		if closeErr := l.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (srv *Server) serveConn(conn net.Conn) {
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.conns, conn)
+		srv.mu.Unlock()
+		conn.Close()
+	}()
+
+	var writeMu sync.Mutex
+	encoder := json.NewEncoder(conn)
+	write := func(resp response) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_ = encoder.Encode(resp)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// cancels holds the channel cancelling each outstanding watch by request
+	// ID.
+	var cancelsMu sync.Mutex
+	cancels := make(map[uint64]chan struct{})
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return
+		}
+
+		if req.Op == opCancel {
+			cancelsMu.Lock()
+			if cancel, ok := cancels[req.ID]; ok {
+				delete(cancels, req.ID)
+				close(cancel)
+			}
+			cancelsMu.Unlock()
+			continue
+		}
+
+		resp, watch := srv.handle(req)
+		write(resp)
+
+		if watch != nil {
+			cancel := make(chan struct{})
+			cancelsMu.Lock()
+			cancels[req.ID] = cancel
+			cancelsMu.Unlock()
+
+			go func(req request, watch <-chan zookeeper.Event) {
+				select {
+				case event := <-watch:
+					cancelsMu.Lock()
+					delete(cancels, req.ID)
+					cancelsMu.Unlock()
+					write(response{ID: req.ID, Watch: true, Event: &event})
+				case <-cancel:
+					session.AbandonWatch(srv.session, req.Path, watchKinds[req.Op])
+				case <-done:
+					session.AbandonWatch(srv.session, req.Path, watchKinds[req.Op])
+				}
+			}(req, watch)
+		}
+	}
+}
+
+func (srv *Server) handle(req request) (response, <-chan zookeeper.Event) {
+	resp := response{ID: req.ID}
+	var data string
+	var stat *zookeeper.Stat
+	var watch <-chan zookeeper.Event
+	var err error
+
+	switch req.Op {
+	case opGet:
+		data, stat, err = srv.session.Get(req.Path)
+	case opGetW:
+		data, stat, watch, err = srv.session.GetW(req.Path)
+	case opExists:
+		stat, err = srv.session.Exists(req.Path)
+	case opExistsW:
+		stat, watch, err = srv.session.ExistsW(req.Path)
+	case opChildren:
+		resp.Children, stat, err = srv.session.Children(req.Path)
+	case opChildrenW:
+		resp.Children, stat, watch, err = srv.session.ChildrenW(req.Path)
+	default:
+		err = &zookeeper.Error{Op: req.Op, Code: zookeeper.ZUNIMPLEMENTED, Path: req.Path}
+	}
+
+	if data != "" {
+		resp.Data = []byte(data)
+	}
+	resp.Stat = newStat(stat)
+	resp.Error = newResponseError(err)
+	if err != nil {
+		watch = nil
+	}
+	return resp, watch
+}
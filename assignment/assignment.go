@@ -0,0 +1,324 @@
+// Package assignment distributes a fixed number of partitions over a dynamic
+// set of workers.
+//
+// Workers register under root/workers and elect a leader among themselves.
+// The leader computes the partition to worker mapping, keeping partitions
+// where they are whenever possible, and stores it in root/assignment, which
+// every worker watches to learn its partitions.
+//
+// When a partition moves between two live workers, the new owner waits for a
+// handoff window, measured from when it first sees the move, before taking
+// it, giving the previous owner time to notice it was revoked and stop.
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/election"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/watch"
+)
+
+// DefaultHandoffWindow is how long a new owner waits for the previous owner
+// of a partition to release it, unless WithHandoffWindow is given.
+const DefaultHandoffWindow = 10 * time.Second
+
+// Handler is notified of changes to a worker's partitions. Calls are made
+// from a single goroutine, except for the revocations made by Close.
+type Handler interface {
+	Assign(partition int)
+	Revoke(partition int)
+}
+
+type options struct {
+	recipeopts.Options
+	handoff time.Duration
+}
+
+// Option configures a Worker: the option below, or one of the options every
+// recipe shares from recipeopts. The assignment is encoded with the codec,
+// which every worker under a root must share.
+type Option = recipeopts.Option
+
+// WithHandoffWindow sets how long a new owner waits before taking a partition
+// from a worker that is still alive.
+func WithHandoffWindow(window time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.handoff = window })
+}
+
+// Worker takes part in the assignment of partitions under a root.
+type Worker struct {
+	session    session.Session
+	root       string
+	id         string
+	partitions int
+	handler    Handler
+	opts       options
+
+	selector *election.LeaderSelector
+	watcher  *watch.Watcher
+	member   string
+
+	mu    sync.Mutex
+	owned map[int]bool
+	// pending maps partitions being handed to us to the generation of the
+	// handoff and when we first saw it.
+	pending map[int]handoff
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type handoff struct {
+	generation int64
+	seen       time.Time
+}
+
+// New creates a worker identified by id, taking part in assigning partitions
+// partitions under root, the default base path. Every worker under a root
+// must agree on the number of partitions. It fails if opts are invalid.
+func New(s session.Session, root, id string, partitions int, handler Handler, opts ...Option) (*Worker, error) {
+	o := &options{Options: recipeopts.Defaults(root), handoff: DefaultHandoffWindow}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.handoff < 0 {
+		return nil, fmt.Errorf("%w: handoff window must not be negative, got %s", recipeopts.ErrInvalidOptions, o.handoff)
+	}
+
+	w := &Worker{
+		session:    s,
+		root:       o.BasePath,
+		id:         id,
+		partitions: partitions,
+		handler:    handler,
+		opts:       *o,
+		owned:      make(map[int]bool),
+		pending:    make(map[int]handoff),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	selector, err := election.NewLeaderSelector(s, o.Path("leader"), id, w.lead)
+	if err != nil {
+		return nil, err
+	}
+	w.selector = selector
+	w.watcher = watch.New(s, o.Path("assignment"))
+	return w, nil
+}
+
+func (w *Worker) workers() string { return path.Join(w.root, "workers") }
+
+// Start registers the worker and starts following its assignment.
+func (w *Worker) Start() error {
+	for _, p := range []string{w.root, w.workers()} {
+		if _, err := (managednode.Node{Path: p, OnConflict: managednode.Adopt}).Ensure(w.session); err != nil {
+			return err
+		}
+	}
+
+	member, err := managednode.Node{Path: path.Join(w.workers(), w.id), Flags: zookeeper.EPHEMERAL, Recipe: "assignment"}.Ensure(w.session)
+	if err != nil {
+		return err
+	}
+	w.member = member
+
+	w.watcher.Start()
+	if err := w.selector.Start(); err != nil {
+		w.watcher.Close()
+		_ = w.session.Delete(member, -1)
+		return err
+	}
+	session.Go(w.session, "assignment", w.run)
+	return nil
+}
+
+// Owned returns the partitions currently assigned to the worker, in order.
+func (w *Worker) Owned() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	owned := make([]int, 0, len(w.owned))
+	for p := range w.owned {
+		owned = append(owned, p)
+	}
+	sort.Ints(owned)
+	return owned
+}
+
+// Close revokes the worker's partitions and leaves the assignment, letting the
+// leader hand the partitions to other workers.
+func (w *Worker) Close() error {
+	close(w.stop)
+	<-w.done
+	w.watcher.Close()
+	err := w.selector.Close()
+
+	for _, p := range w.Owned() {
+		w.revoke(p)
+	}
+	if derr := w.session.Delete(w.member, -1); derr != nil && !zookeeper.IsError(derr, zookeeper.ZNONODE) && err == nil {
+		err = derr
+	}
+	return err
+}
+
+// Run registers the worker and follows its assignment until ctx is done,
+// closing the worker and returning ctx.Err(), or until it stops otherwise,
+// returning nil. See session.RunUntil.
+func (w *Worker) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, w.Start, w.done, w.Close)
+}
+
+func (w *Worker) run() {
+	defer close(w.done)
+	detach := session.Attach(w.session, "assignment", w.member)
+	defer detach()
+
+	var current Assignment
+	var timer *time.Timer
+	var expired <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events():
+			if !ok {
+				return
+			}
+			current = Assignment{}
+			if event.Exists && event.Data != "" {
+				if err := w.opts.Codec.Unmarshal([]byte(event.Data), &current); err != nil {
+					continue
+				}
+			}
+		case <-expired:
+		case <-w.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		expired = nil
+		if wait := w.apply(current, time.Now()); wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+	}
+}
+
+// apply reconciles the worker's partitions with a, returning how long until
+// the next pending handoff completes, or 0 if there are none.
+func (w *Worker) apply(a Assignment, now time.Time) time.Duration {
+	var wait time.Duration
+	for i := 0; i < w.partitions; i++ {
+		var p Partition
+		if i < len(a.Partitions) {
+			p = a.Partitions[i]
+		}
+
+		w.mu.Lock()
+		owned := w.owned[i]
+		w.mu.Unlock()
+
+		switch {
+		case p.Owner != w.id:
+			delete(w.pending, i)
+			if owned {
+				w.revoke(i)
+			}
+		case owned:
+		case p.Previous == "":
+			delete(w.pending, i)
+			w.assign(i)
+		default:
+			h, ok := w.pending[i]
+			if !ok || h.generation != p.Generation {
+				h = handoff{generation: p.Generation, seen: now}
+				w.pending[i] = h
+			}
+			if remaining := h.seen.Add(w.opts.handoff).Sub(now); remaining > 0 {
+				if wait == 0 || remaining < wait {
+					wait = remaining
+				}
+				continue
+			}
+			delete(w.pending, i)
+			w.assign(i)
+		}
+	}
+	return wait
+}
+
+func (w *Worker) assign(partition int) {
+	w.mu.Lock()
+	w.owned[partition] = true
+	w.mu.Unlock()
+	_ = session.Protect(w.session, "assignment", func() { w.handler.Assign(partition) })
+}
+
+func (w *Worker) revoke(partition int) {
+	w.mu.Lock()
+	delete(w.owned, partition)
+	w.mu.Unlock()
+	_ = session.Protect(w.session, "assignment", func() { w.handler.Revoke(partition) })
+}
+
+// lead maintains the assignment while the worker is the leader.
+func (w *Worker) lead(ctx context.Context) error {
+	for {
+		workers, _, changed, err := w.session.ChildrenW(w.workers())
+		if err != nil {
+			return err
+		}
+		if err := w.reassign(workers); err != nil {
+			return err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (w *Worker) reassign(workers []string) (err error) {
+	node := path.Join(w.root, "assignment")
+	_, end := w.opts.StartOperation(w.session, "assignment", "reassign", node)
+	defer func() { end(err) }()
+	data, stat, err := w.session.Get(node)
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return err
+	}
+
+	var current Assignment
+	if data != "" {
+		// A corrupt assignment is replaced from scratch.
+		_ = w.opts.Codec.Unmarshal([]byte(data), &current)
+	}
+	next := rebalance(current, w.partitions, workers, time.Now().UnixNano())
+	if stat != nil && next.equal(current) {
+		return nil
+	}
+
+	encoded, err := w.opts.Codec.Marshal(next)
+	if err != nil {
+		return err
+	}
+	if stat == nil {
+		_, err = w.session.Create(node, string(encoded), 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	} else {
+		_, err = w.session.Set(node, string(encoded), stat.Version())
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package assignment
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWorker(t *testing.T, s session.Session, id string, handler Handler) *Worker {
+	w, err := New(s, "/test", id, 4, handler, WithHandoffWindow(100*time.Millisecond))
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return w
+}
+
+func TestNewShouldRejectInvalidOptions(t *testing.T) {
+	_, err := New(nil, "/test", "a", 4, nil, WithHandoffWindow(-time.Second))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	owned map[int]bool
+}
+
+func (r *recorder) Assign(partition int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owned[partition] = true
+}
+
+func (r *recorder) Revoke(partition int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.owned, partition)
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.owned)
+}
+
+func TestWorkersShouldSplitPartitions(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		a, b := &recorder{owned: map[int]bool{}}, &recorder{owned: map[int]bool{}}
+
+		first := newWorker(t, s, "a", a)
+		if err := first.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer first.Close()
+
+		assert.Eventually(t, func() bool { return a.count() == 4 }, 5*time.Second, 10*time.Millisecond)
+
+		second := newWorker(t, s, "b", b)
+		if err := second.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+
+		assert.Eventually(t, func() bool { return a.count() == 2 && b.count() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+		if err := second.Close(); err != nil {
+			t.Fatal("Close error: ", err)
+		}
+		assert.Equal(t, 0, b.count())
+		assert.Eventually(t, func() bool { return a.count() == 4 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
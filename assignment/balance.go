@@ -0,0 +1,84 @@
+package assignment
+
+import "sort"
+
+// Partition is the assignment of one partition.
+type Partition struct {
+	// Owner is the worker the partition is assigned to, or "" if there are no
+	// workers.
+	Owner string `json:"owner"`
+	// Previous is the worker the partition is being handed off from, if it's
+	// still alive. The owner waits out the handoff window before taking the
+	// partition, to give the previous owner time to release it.
+	Previous string `json:"previous,omitempty"`
+	// Generation identifies the handoff, so workers can tell a new move from
+	// one they have already seen.
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// Assignment maps partitions, by index, to workers.
+type Assignment struct {
+	Partitions []Partition `json:"partitions"`
+}
+
+// rebalance computes a new assignment of n partitions over workers, starting
+// from current. It is sticky: a partition keeps its owner as long as the
+// owner is alive and doesn't hold more than its fair share, so the minimum
+// number of partitions move. generation tags the moves made.
+func rebalance(current Assignment, n int, workers []string, generation int64) Assignment {
+	next := Assignment{Partitions: make([]Partition, n)}
+	copy(next.Partitions, current.Partitions)
+	if len(workers) == 0 {
+		return next
+	}
+
+	alive := make(map[string]bool, len(workers))
+	load := make(map[string]int, len(workers))
+	for _, w := range workers {
+		alive[w] = true
+		load[w] = 0
+	}
+	capacity := (n + len(workers) - 1) / len(workers)
+
+	var unassigned []int
+	for i := range next.Partitions {
+		owner := next.Partitions[i].Owner
+		if alive[owner] && load[owner] < capacity {
+			load[owner]++
+			continue
+		}
+		unassigned = append(unassigned, i)
+	}
+
+	sorted := append([]string(nil), workers...)
+	sort.Strings(sorted)
+	for _, i := range unassigned {
+		target := sorted[0]
+		for _, w := range sorted[1:] {
+			if load[w] < load[target] {
+				target = w
+			}
+		}
+		load[target]++
+
+		old := next.Partitions[i].Owner
+		p := Partition{Owner: target, Generation: generation}
+		if alive[old] {
+			p.Previous = old
+		}
+		next.Partitions[i] = p
+	}
+	return next
+}
+
+func (a Assignment) equal(b Assignment) bool {
+	if len(a.Partitions) != len(b.Partitions) {
+		return false
+	}
+	for i := range a.Partitions {
+		if a.Partitions[i] != b.Partitions[i] {
+			return false
+		}
+	}
+	return true
+}
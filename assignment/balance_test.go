@@ -0,0 +1,45 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func owners(a Assignment) []string {
+	var owners []string
+	for _, p := range a.Partitions {
+		owners = append(owners, p.Owner)
+	}
+	return owners
+}
+
+func TestRebalanceShouldSpreadPartitionsEvenly(t *testing.T) {
+	a := rebalance(Assignment{}, 4, []string{"b", "a"}, 1)
+	assert.Equal(t, []string{"a", "b", "a", "b"}, owners(a))
+	assert.Equal(t, "", a.Partitions[0].Previous)
+}
+
+func TestRebalanceShouldBeSticky(t *testing.T) {
+	a := rebalance(Assignment{}, 4, []string{"a", "b"}, 1)
+
+	// A new worker only takes over what it needs for its share.
+	b := rebalance(a, 4, []string{"a", "b", "c"}, 2)
+	assert.Equal(t, []string{"a", "b", "a", "b"}, owners(b))
+
+	b = rebalance(a, 6, []string{"a", "b", "c"}, 2)
+	assert.Equal(t, []string{"a", "b", "a", "b", "c", "c"}, owners(b))
+}
+
+func TestRebalanceShouldHandOffFromLiveOwners(t *testing.T) {
+	a := rebalance(Assignment{}, 4, []string{"a"}, 1)
+	b := rebalance(a, 4, []string{"a", "b"}, 2)
+
+	assert.Equal(t, []string{"a", "a", "b", "b"}, owners(b))
+	assert.Equal(t, Partition{Owner: "b", Previous: "a", Generation: 2}, b.Partitions[2])
+	assert.Equal(t, Partition{Owner: "a", Generation: 1}, b.Partitions[0])
+
+	// Partitions of a dead worker move without a handoff.
+	c := rebalance(b, 4, []string{"b"}, 3)
+	assert.Equal(t, Partition{Owner: "b", Generation: 3}, c.Partitions[0])
+}
@@ -0,0 +1,312 @@
+// Package bigvalue stores values larger than a znode can hold, splitting them
+// into chunks kept in child nodes and described by a manifest in the value's
+// own node.
+//
+// Each write stores its chunks under a new generation node, a sequential
+// child of the value's node, and then swaps the manifest over to it with a
+// versioned update, so readers see either the old value or the new one in
+// full. Generations replaced by a write are deleted once the swap is done.
+// Values small enough to fit in the manifest are stored inline, with an empty
+// generation.
+package bigvalue
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultChunkSize is the size of the chunks values are split into unless
+// WithChunkSize is given, comfortably below ZooKeeper's default 1MB limit on
+// node data.
+const DefaultChunkSize = 512 * 1024
+
+// maxReadAttempts is how many times Get reads the value again when a write
+// replaces it while it is being read.
+const maxReadAttempts = 10
+
+// generationPrefix names the generation nodes holding the chunks.
+const generationPrefix = "gen-"
+
+// ErrInconsistent is returned by Get when the value kept changing while it
+// was being read, or its chunks don't match its manifest.
+var ErrInconsistent = errors.New("big value chunks don't match manifest")
+
+// manifest is the data of the value's node.
+type manifest struct {
+	Inline     []byte `json:"inline,omitempty"`
+	Generation string `json:"generation"`
+	Chunks     int    `json:"chunks"`
+	Size       int    `json:"size"`
+	SHA256     []byte `json:"sha256"`
+}
+
+type options struct {
+	recipeopts.Options
+	chunkSize int
+}
+
+// Option configures a Value: the option below, or one of the options every
+// recipe shares from recipeopts. Manifests are encoded with the codec, which
+// every client of the value must share.
+type Option = recipeopts.Option
+
+// WithChunkSize splits values into chunks of at most size bytes. It must
+// leave room below the server's jute.maxbuffer setting.
+func WithChunkSize(size int) Option {
+	return recipeopts.Own(func(o *options) { o.chunkSize = size })
+}
+
+// Value is a value of any size stored at a path.
+type Value struct {
+	session session.Session
+	path    string
+	opts    options
+}
+
+// New returns the value stored at path, the default base path, or fails if
+// opts are invalid.
+func New(s session.Session, path string, opts ...Option) (*Value, error) {
+	o := &options{Options: recipeopts.Defaults(path), chunkSize: DefaultChunkSize}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.chunkSize <= 0 {
+		return nil, fmt.Errorf("%w: chunk size must be positive, got %d", recipeopts.ErrInvalidOptions, o.chunkSize)
+	}
+	return &Value{session: s, path: o.BasePath, opts: *o}, nil
+}
+
+// Get returns the value and the version of its manifest, to pass to Set or
+// Delete for a conditional update. A node created without a manifest reads
+// as an empty value.
+func (v *Value) Get() ([]byte, int, error) {
+	for attempt := 0; attempt < maxReadAttempts; attempt++ {
+		m, stat, err := v.manifest()
+		if err != nil {
+			return nil, 0, err
+		}
+		data, err := v.assemble(m)
+		if errors.Is(err, ErrInconsistent) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return data, stat.Version(), nil
+	}
+	return nil, 0, fmt.Errorf("reading %s: %w", v.path, ErrInconsistent)
+}
+
+func (v *Value) manifest() (manifest, *zookeeper.Stat, error) {
+	data, stat, err := v.session.Get(v.path)
+	if err != nil {
+		return manifest{}, nil, err
+	}
+	var m manifest
+	if data == "" {
+		return m, stat, nil
+	}
+	if err := v.opts.Codec.Unmarshal([]byte(data), &m); err != nil {
+		return manifest{}, nil, fmt.Errorf("reading manifest of %s: %w", v.path, err)
+	}
+	return m, stat, nil
+}
+
+// assemble reads the chunks described by m, returning ErrInconsistent if
+// they are gone or don't match, as after a concurrent write.
+func (v *Value) assemble(m manifest) ([]byte, error) {
+	if m.Generation == "" {
+		return []byte{}, nil
+	}
+	if m.Chunks == 0 {
+		return append([]byte{}, m.Inline...), checkSum(m, m.Inline)
+	}
+
+	data := make([]byte, 0, m.Size)
+	for i := 0; i < m.Chunks; i++ {
+		chunk, _, err := v.session.Get(chunkPath(v.path, m.Generation, i))
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return nil, ErrInconsistent
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	return data, checkSum(m, data)
+}
+
+func checkSum(m manifest, data []byte) error {
+	sum := sha256.Sum256(data)
+	if len(data) != m.Size || string(sum[:]) != string(m.SHA256) {
+		return ErrInconsistent
+	}
+	return nil
+}
+
+// Set stores data if the manifest is still at version, or whatever its
+// version with -1, creating the node if it doesn't exist and version is -1.
+// It returns the new version. A write losing a race with another fails with a
+// ZBADVERSION error if version was given, and is retried otherwise.
+func (v *Value) Set(data []byte, version int) (newVersion int, err error) {
+	_, end := v.opts.StartOperation(v.session, "bigvalue", "set", v.path)
+	defer func() { end(err) }()
+	for {
+		newVersion, err := v.trySet(data, version)
+		if version == -1 && zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+			continue
+		}
+		return newVersion, err
+	}
+}
+
+func (v *Value) trySet(data []byte, version int) (int, error) {
+	_, stat, err := v.manifest()
+	if zookeeper.IsError(err, zookeeper.ZNONODE) && version == -1 {
+		_, err = v.session.Create(v.path, "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+		if err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return 0, err
+		}
+		_, stat, err = v.manifest()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if version != -1 && stat.Version() != version {
+		return 0, v.lostRace()
+	}
+
+	sum := sha256.Sum256(data)
+	m := manifest{Size: len(data), SHA256: sum[:]}
+	chunked := data
+	if len(data) <= v.opts.chunkSize/2 {
+		m.Inline, chunked = data, nil
+	}
+	if m.Generation, m.Chunks, err = v.writeGeneration(chunked); err != nil {
+		return 0, err
+	}
+
+	encoded, err := v.opts.Codec.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	newStat, err := v.session.Set(v.path, string(encoded), stat.Version())
+	if err != nil {
+		_ = v.deleteGeneration(m.Generation)
+		return 0, err
+	}
+	v.deleteGenerationsBefore(m.Generation)
+	return newStat.Version(), nil
+}
+
+// writeGeneration stores data in chunks under a new generation, returning its
+// name and the number of chunks written.
+func (v *Value) writeGeneration(data []byte) (string, int, error) {
+	acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+	created, err := v.session.Create(v.path+"/"+generationPrefix, "", zookeeper.SEQUENCE, acl)
+	if err != nil {
+		return "", 0, err
+	}
+	generation := path.Base(created)
+
+	chunks := 0
+	for start := 0; start < len(data); start += v.opts.chunkSize {
+		end := start + v.opts.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := v.session.Create(chunkPath(v.path, generation, chunks), string(data[start:end]), 0, acl); err != nil {
+			_ = v.deleteGeneration(generation)
+			if zookeeper.IsError(err, zookeeper.ZNONODE) {
+				// A faster write replaced the manifest and deleted the
+				// generation.
+				return "", 0, v.lostRace()
+			}
+			return "", 0, fmt.Errorf("writing chunk %d of %s: %w", chunks, v.path, err)
+		}
+		chunks++
+	}
+	return generation, chunks, nil
+}
+
+// lostRace returns the error of a write that lost a race with another.
+func (v *Value) lostRace() error {
+	return &zookeeper.Error{Op: "set", Code: zookeeper.ZBADVERSION, Path: v.path}
+}
+
+// deleteGenerationsBefore deletes the generations created before current,
+// which no manifest refers to any more once current is in place. These
+// include those left behind by failed writes, and those of slower writes
+// still in progress: they read the manifest before current replaced it, so
+// they have lost the race to current, and writing their chunks fails as
+// they would have failed to replace the manifest. Generations created since
+// belong to writes in progress, which will replace current or fail. Errors
+// are ignored; the generations are deleted by a later write instead.
+func (v *Value) deleteGenerationsBefore(current string) {
+	seq, err := session.ParseSequence(current)
+	if err != nil {
+		return
+	}
+	children, _, err := v.session.Children(v.path)
+	if err != nil {
+		return
+	}
+	for _, child := range children {
+		childSeq, err := session.ParseSequence(child)
+		if err == nil && session.SequenceLess(childSeq, seq) {
+			_ = v.deleteGeneration(child)
+		}
+	}
+}
+
+func (v *Value) deleteGeneration(generation string) error {
+	node := path.Join(v.path, generation)
+	chunks, _, err := v.session.Children(node)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sort.Strings(chunks)
+	for _, chunk := range chunks {
+		if err := v.session.Delete(path.Join(node, chunk), -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return err
+		}
+	}
+	if err := v.session.Delete(node, -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the value and its chunks if the manifest is at version, or
+// whatever its version with -1. The manifest is cleared first, so a write
+// racing with Delete either fails or leaves a complete value behind.
+func (v *Value) Delete(version int) error {
+	stat, err := v.session.Set(v.path, "", version)
+	if err != nil {
+		return err
+	}
+	children, _, err := v.session.Children(v.path)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := v.deleteGeneration(child); err != nil {
+			return err
+		}
+	}
+	return v.session.Delete(v.path, stat.Version())
+}
+
+func chunkPath(root, generation string, i int) string {
+	return fmt.Sprintf("%s/%s/%06d", root, generation, i)
+}
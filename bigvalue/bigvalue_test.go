@@ -0,0 +1,117 @@
+package bigvalue
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newValue(t *testing.T, s session.Session) *Value {
+	v, err := New(s, "/test", WithChunkSize(16))
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return v
+}
+
+func TestNewShouldRejectInvalidChunkSize(t *testing.T) {
+	_, err := New(nil, "/test", WithChunkSize(0))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestValueShouldSplitIntoChunksAndReassemble(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		big := bytes.Repeat([]byte("0123456789"), 10)
+
+		version, err := v.Set(big, -1)
+		if err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		generations, _, _ := s.Children("/test")
+		assert.Len(t, generations, 1)
+		chunks, _, _ := s.Children("/test/" + generations[0])
+		assert.Len(t, chunks, 7)
+
+		data, got, err := v.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, big, data)
+		assert.Equal(t, version, got)
+
+		if _, err := v.Set([]byte("small"), version); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		data, _, err = v.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("small"), data)
+		replaced, _, _ := s.Children("/test")
+		assert.Len(t, replaced, 1)
+		assert.NotEqual(t, generations[0], replaced[0])
+	})
+}
+
+func TestValueShouldRejectStaleVersions(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		version, err := v.Set(bytes.Repeat([]byte("a"), 40), -1)
+		if err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		if _, err := v.Set(bytes.Repeat([]byte("b"), 40), version); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+
+		_, err = v.Set(bytes.Repeat([]byte("c"), 40), version)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZBADVERSION))
+		data, _, _ := v.Get()
+		assert.Equal(t, bytes.Repeat([]byte("b"), 40), data)
+		generations, _, _ := s.Children("/test")
+		assert.Len(t, generations, 1)
+	})
+}
+
+func TestConcurrentSetsShouldAllSucceed(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		written := map[string]bool{}
+		errs := make(chan error, 8)
+		for i := 0; i < 8; i++ {
+			data := bytes.Repeat([]byte{byte('a' + i)}, 200)
+			written[string(data)] = true
+			go func() {
+				_, err := v.Set(data, -1)
+				errs <- err
+			}()
+		}
+		for i := 0; i < 8; i++ {
+			assert.NoError(t, <-errs)
+		}
+
+		data, _, err := v.Get()
+		assert.NoError(t, err)
+		assert.True(t, written[string(data)], "Expected one of the values written, got %q", data)
+		generations, _, _ := s.Children("/test")
+		assert.Len(t, generations, 1)
+	})
+}
+
+func TestValueShouldDeleteChunks(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		version, err := v.Set(bytes.Repeat([]byte("a"), 40), -1)
+		if err != nil {
+			t.Fatal("Set error: ", err)
+		}
+
+		assert.NoError(t, v.Delete(version))
+		stat, err := s.Exists("/test")
+		assert.NoError(t, err)
+		assert.Nil(t, stat)
+	})
+}
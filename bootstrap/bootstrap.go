@@ -0,0 +1,152 @@
+// Package bootstrap runs one-time initialization of a ZooKeeper tree, such as
+// creating a recipe's base paths and seeding defaults, once across every
+// process that starts up with it.
+//
+// Initialization is recorded in a marker node holding the version that was
+// initialized and who initialized it. Callers finding an up-to-date marker
+// return right away; otherwise they take a lock, check the marker again and
+// only then initialize, so concurrent callers wait for the first one instead
+// of initializing the tree themselves.
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/lock"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrSessionExpired is returned when the session expired while initializing,
+// releasing the lock. The marker isn't written, so initialization is run
+// again by the next caller. It is a session.ErrSessionLost.
+var ErrSessionExpired = session.NewError("session expired during initialization", session.ErrSessionLost)
+
+// Marker records a completed initialization.
+type Marker struct {
+	Version     int       `json:"version"`
+	Initializer string    `json:"initializer"`
+	Time        time.Time `json:"time"`
+}
+
+type options struct {
+	version     int
+	initializer string
+}
+
+// Option configures EnsureInitialized.
+type Option func(options) options
+
+// WithVersion sets the version of the initialization, 1 by default. A tree
+// initialized at an older version is initialized again, which lets init
+// migrate it.
+func WithVersion(version int) Option {
+	return func(o options) options {
+		o.version = version
+		return o
+	}
+}
+
+// WithInitializer sets the identity recorded in the marker, the host name
+// and process ID by default.
+func WithInitializer(id string) Option {
+	return func(o options) options {
+		o.initializer = id
+		return o
+	}
+}
+
+func markerPath(root string) string { return path.Join(root, "_initialized") }
+func lockPath(root string) string   { return path.Join(root, "_initlock") }
+
+// EnsureInitialized runs init for the tree at root unless it has already been
+// initialized at the requested version, and returns the marker describing the
+// initialization. init is given the previous marker when upgrading a tree
+// initialized at an older version, and nil otherwise.
+//
+// Once init succeeds, it isn't run again for that version. If it fails, or
+// its process dies, the next caller runs it again, so init must cope with
+// what an earlier attempt left behind, for example by creating nodes with
+// managednode.Adopt.
+func EnsureInitialized(s *session.ZKSession, root string, init func(previous *Marker) error, opts ...Option) (Marker, error) {
+	o := options{version: 1}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	if o.initializer == "" {
+		host, _ := os.Hostname()
+		o.initializer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	if m, err := readMarker(s, root); err != nil || (m != nil && m.Version >= o.version) {
+		return deref(m), err
+	}
+
+	if _, err := (managednode.Node{Path: lockPath(root), Parents: true, OnConflict: managednode.Adopt}).Ensure(s); err != nil {
+		return Marker{}, err
+	}
+	l, err := lock.NewGlobalLock(s, lockPath(root), o.initializer)
+	if err != nil {
+		return Marker{}, err
+	}
+	epoch := s.Epoch()
+	if err := l.Lock(); err != nil {
+		return Marker{}, err
+	}
+	defer l.Unlock()
+
+	previous, err := readMarker(s, root)
+	if err != nil || (previous != nil && previous.Version >= o.version) {
+		return deref(previous), err
+	}
+
+	if err := init(previous); err != nil {
+		return Marker{}, fmt.Errorf("initializing %s: %w", root, err)
+	}
+	if s.Epoch() != epoch {
+		return Marker{}, ErrSessionExpired
+	}
+
+	m := Marker{Version: o.version, Initializer: o.initializer, Time: time.Now().UTC()}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return Marker{}, err
+	}
+	if _, err := (managednode.Node{Path: markerPath(root), Data: string(data), OnConflict: managednode.Overwrite}).Ensure(s); err != nil {
+		return Marker{}, err
+	}
+	return m, nil
+}
+
+// Initialized returns the marker of the tree at root, or nil if it hasn't
+// been initialized.
+func Initialized(s session.Session, root string) (*Marker, error) {
+	return readMarker(s, root)
+}
+
+func readMarker(s session.Session, root string) (*Marker, error) {
+	data, _, err := s.Get(markerPath(root))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Marker
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("marker %s holds %q: %w", markerPath(root), data, err)
+	}
+	return &m, nil
+}
+
+func deref(m *Marker) Marker {
+	if m == nil {
+		return Marker{}
+	}
+	return *m
+}
@@ -0,0 +1,89 @@
+package bootstrap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureInitializedShouldRunOnce(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		var runs int32
+		init := func(previous *Marker) error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		markers := make([]Marker, 5)
+		for i := range markers {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				m, err := EnsureInitialized(s, "/test/app", init, WithInitializer("worker"))
+				assert.NoError(t, err)
+				markers[i] = m
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+		for _, m := range markers {
+			assert.Equal(t, 1, m.Version)
+			assert.Equal(t, "worker", m.Initializer)
+		}
+		m, err := Initialized(s, "/test/app")
+		assert.NoError(t, err)
+		assert.Equal(t, markers[0], *m)
+	})
+}
+
+func TestEnsureInitializedShouldUpgradeOlderVersions(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		_, err := EnsureInitialized(s, "/test/app", func(previous *Marker) error {
+			assert.Nil(t, previous)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		var upgradedFrom int
+		m, err := EnsureInitialized(s, "/test/app", func(previous *Marker) error {
+			upgradedFrom = previous.Version
+			return nil
+		}, WithVersion(2))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, upgradedFrom)
+		assert.Equal(t, 2, m.Version)
+
+		m, err = EnsureInitialized(s, "/test/app", func(*Marker) error {
+			t.Error("initialized again at an older version")
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, m.Version)
+	})
+}
+
+func TestEnsureInitializedShouldRetryFailures(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		failure := errors.New("seeding failed")
+		_, err := EnsureInitialized(s, "/test/app", func(*Marker) error { return failure })
+		assert.ErrorIs(t, err, failure)
+
+		m, err := Initialized(s, "/test/app")
+		assert.NoError(t, err)
+		assert.Nil(t, m)
+
+		ran := false
+		_, err = EnsureInitialized(s, "/test/app", func(*Marker) error { ran = true; return nil })
+		assert.NoError(t, err)
+		assert.True(t, ran)
+	})
+}
@@ -0,0 +1,407 @@
+// Package breaker shares the state of circuit breakers between processes, so
+// that once one instance trips the breaker guarding a dependency, every
+// instance stops calling it too.
+//
+// Each breaker is a znode under a root, holding its Record as JSON, and
+// every process keeps a local copy up to date with a watch, so checking a
+// breaker doesn't touch ZooKeeper. Changes are applied to the local copy right
+// away and written at most once per WithMinInterval, the latest one winning,
+// so a dependency flapping under a fleet of callers doesn't turn into a storm
+// of writes; a change to the state the node already holds isn't written at
+// all. The last change written is the one every process sees.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/watch"
+)
+
+// DefaultMinInterval is the least time between two writes of a breaker's
+// state unless WithMinInterval is given.
+const DefaultMinInterval = time.Second
+
+// ErrClosed is returned when using a Registry after Close.
+var ErrClosed = errors.New("breaker registry closed")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed breakers let calls through.
+	Closed State = iota
+	// Open breakers reject calls.
+	Open
+	// HalfOpen breakers let calls through to find out whether the
+	// dependency has recovered.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	if s < Closed || s > HalfOpen {
+		return nil, fmt.Errorf("unknown breaker state %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		if string(text) == state.String() {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown breaker state %q", text)
+}
+
+// Record is the shared state of a breaker: since when it has been in State,
+// and who changed it to that and why.
+type Record struct {
+	State  State     `json:"state"`
+	Since  time.Time `json:"since"`
+	By     string    `json:"by,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+type options struct {
+	recipeopts.Options
+	minInterval time.Duration
+	cooldown    time.Duration
+	identity    string
+	onChange    func(name string, r Record)
+}
+
+// Option configures a Registry: one of the options below, or one of the
+// options every recipe shares from recipeopts. Breaker records are encoded
+// with the codec given to recipeopts.WithCodec.
+type Option = recipeopts.Option
+
+// WithMinInterval writes a breaker's state at most once per interval. A
+// change made sooner is applied locally and written once interval has passed
+// since the last write, unless another replaces it first.
+func WithMinInterval(interval time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.minInterval = interval })
+}
+
+// WithCooldown reports a breaker that has been open for cooldown as
+// HalfOpen, so callers across the fleet start probing the dependency without
+// anyone having to change its state. Without it, open breakers stay open
+// until changed.
+func WithCooldown(cooldown time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.cooldown = cooldown })
+}
+
+// WithIdentity records identity, such as the host name, as who made the
+// changes written by the registry.
+func WithIdentity(identity string) Option {
+	return recipeopts.Own(func(o *options) { o.identity = identity })
+}
+
+// OnChange calls f with a breaker's name and record when another process
+// changes it. Calls go through session.Dispatch, one at a time and in order.
+func OnChange(f func(name string, r Record)) Option {
+	return recipeopts.Own(func(o *options) { o.onChange = f })
+}
+
+// Registry holds the breakers shared under a root node.
+type Registry struct {
+	session session.Session
+	root    string
+	o       options
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+	ensured  bool
+	closed   bool
+}
+
+// New returns a Registry of the breakers stored under root, the default base
+// path, or fails if opts are invalid.
+func New(s session.Session, root string, opts ...Option) (*Registry, error) {
+	o := &options{Options: recipeopts.Defaults(root), minInterval: DefaultMinInterval}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	return &Registry{session: s, root: o.BasePath, o: *o, breakers: map[string]*Breaker{}}, nil
+}
+
+// Breaker returns the breaker guarding the dependency name, following its
+// shared state from the first call on. A breaker that was never changed is
+// Closed.
+func (r *Registry) Breaker(name string) (*Breaker, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid breaker name %q", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrClosed
+	}
+	if b, ok := r.breakers[name]; ok {
+		return b, nil
+	}
+	if !r.ensured {
+		if _, err := (managednode.Node{Path: r.root, OnConflict: managednode.Adopt}).Ensure(r.session); err != nil {
+			return nil, err
+		}
+		r.ensured = true
+	}
+
+	b := &Breaker{
+		r:       r,
+		name:    name,
+		path:    path.Join(r.root, name),
+		version: -1,
+		done:    make(chan struct{}),
+	}
+	b.watcher = watch.New(r.session, b.path)
+	b.watcher.Start()
+	b.update(<-b.watcher.Events())
+	session.Go(r.session, "breaker", b.run)
+	r.breakers[name] = b
+	return b, nil
+}
+
+// Close stops following the breakers. Changes not written yet are written
+// first. The nodes are left in place.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	breakers := r.breakers
+	r.breakers = map[string]*Breaker{}
+	r.mu.Unlock()
+
+	var errs []string
+	for _, b := range breakers {
+		if err := b.close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("writing breaker states: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Breaker is a circuit breaker whose state is shared through a Registry.
+type Breaker struct {
+	r       *Registry
+	name    string
+	path    string
+	watcher *watch.Watcher
+
+	mu sync.Mutex
+	// shared is the record last read from the node, at version, or -1 if
+	// there is no node. pending is a local change yet to be written, and
+	// flush the timer writing it.
+	shared    Record
+	version   int
+	pending   *Record
+	flush     *time.Timer
+	lastWrite time.Time
+
+	done chan struct{}
+}
+
+// Name returns the name of the dependency the breaker guards.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Record returns the breaker's state as known locally: the latest change made
+// through it if one is yet to be written, or else the shared one.
+func (b *Breaker) Record() Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current()
+}
+
+// State returns the breaker's state, reported as HalfOpen once it has been
+// open for the cooldown given to WithCooldown.
+func (b *Breaker) State() State {
+	r := b.Record()
+	if r.State == Open && b.r.o.cooldown > 0 && time.Since(r.Since) >= b.r.o.cooldown {
+		return HalfOpen
+	}
+	return r.State
+}
+
+// Allow reports whether the breaker lets calls through, that is whether it
+// isn't Open.
+func (b *Breaker) Allow() bool {
+	return b.State() != Open
+}
+
+// Trip opens the breaker for every process.
+func (b *Breaker) Trip(reason string) error {
+	return b.Set(Open, reason)
+}
+
+// Reset closes the breaker for every process.
+func (b *Breaker) Reset(reason string) error {
+	return b.Set(Closed, reason)
+}
+
+// Set changes the breaker's state to state for every process. The change is
+// applied locally right away and written now, or later if the breaker was
+// written less than the minimum interval ago, in which case an error writing
+// it is only logged, and it is tried again after another interval. Setting
+// the state the breaker is already in does nothing.
+func (b *Breaker) Set(state State, reason string) error {
+	if _, err := state.MarshalText(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if b.current().State == state {
+		b.mu.Unlock()
+		return nil
+	}
+	b.pending = &Record{State: state, Since: time.Now(), By: b.r.o.identity, Reason: reason}
+	if wait := time.Until(b.lastWrite.Add(b.r.o.minInterval)); wait > 0 {
+		b.schedule(wait)
+		b.mu.Unlock()
+		return nil
+	}
+	b.mu.Unlock()
+	return b.write()
+}
+
+// current returns the pending change, if any, or the shared record.
+func (b *Breaker) current() Record {
+	if b.pending != nil {
+		return *b.pending
+	}
+	return b.shared
+}
+
+// schedule writes the pending change after wait, unless already scheduled.
+func (b *Breaker) schedule(wait time.Duration) {
+	if b.flush == nil {
+		b.flush = time.AfterFunc(wait, b.flushLater)
+	}
+}
+
+func (b *Breaker) flushLater() {
+	b.mu.Lock()
+	b.flush = nil
+	b.mu.Unlock()
+	if err := b.write(); err != nil {
+		b.mu.Lock()
+		b.schedule(b.r.o.minInterval)
+		b.mu.Unlock()
+	}
+}
+
+// write writes the pending change, if any, and if it changes the shared
+// state.
+func (b *Breaker) write() (err error) {
+	b.mu.Lock()
+	pending := b.pending
+	if pending == nil {
+		b.mu.Unlock()
+		return nil
+	}
+	if pending.State == b.shared.State && b.version >= 0 {
+		b.pending = nil
+		b.mu.Unlock()
+		return nil
+	}
+	b.lastWrite = time.Now()
+	b.mu.Unlock()
+
+	_, end := b.r.o.StartOperation(b.r.session, "breaker", "set", b.path)
+	defer func() { end(err) }()
+	data, err := b.r.o.Codec.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	_, err = b.r.session.Set(b.path, string(data), -1)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		_, err = b.r.session.Create(b.path, string(data), 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+		if zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			_, err = b.r.session.Set(b.path, string(data), -1)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	if b.pending == pending {
+		b.pending = nil
+		b.shared = *pending
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *Breaker) run() {
+	defer close(b.done)
+	for event := range b.watcher.Events() {
+		b.update(event)
+	}
+}
+
+// update applies the node's state from a watch event to the local copy.
+func (b *Breaker) update(event watch.Event) {
+	record, version := Record{State: Closed}, -1
+	if event.Exists {
+		if err := b.r.o.Codec.Unmarshal([]byte(event.Data), &record); err != nil {
+			_, end := b.r.o.StartOperation(b.r.session, "breaker", "read", b.path)
+			end(fmt.Errorf("ignoring invalid breaker state: %w", err))
+			return
+		}
+		version = event.Stat.Version()
+	}
+
+	b.mu.Lock()
+	changed := record.State != b.current().State
+	b.shared, b.version = record, version
+	// A change written by another process since supersedes an older local
+	// one.
+	if b.pending != nil && !record.Since.Before(b.pending.Since) {
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	if event.Type == watch.Initial || !changed || b.r.o.onChange == nil {
+		return
+	}
+	onChange := b.r.o.onChange
+	session.Dispatch(b.r.session, "breaker", b.name, func() { onChange(b.name, record) })
+}
+
+// close stops following the breaker after writing its pending change.
+func (b *Breaker) close() error {
+	b.mu.Lock()
+	if b.flush != nil {
+		b.flush.Stop()
+		b.flush = nil
+	}
+	b.mu.Unlock()
+	err := b.write()
+	b.watcher.Close()
+	<-b.done
+	return err
+}
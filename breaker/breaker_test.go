@@ -0,0 +1,105 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateShouldRoundTripThroughText(t *testing.T) {
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		text, err := state.MarshalText()
+		assert.NoError(t, err)
+		var parsed State
+		assert.NoError(t, parsed.UnmarshalText(text))
+		assert.Equal(t, state, parsed)
+	}
+	var parsed State
+	assert.Error(t, parsed.UnmarshalText([]byte("ajar")))
+}
+
+func newRegistry(t *testing.T, s session.Session, opts ...Option) *Registry {
+	r, err := New(s, "/test/breakers", opts...)
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return r
+}
+
+func TestNewShouldRejectInvalidOptions(t *testing.T) {
+	_, err := New(nil, "breakers", WithIdentity("a"))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestBreakerShouldShareTrips(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		changes := make(chan Record, 1)
+		ours := newRegistry(t, s, WithIdentity("a"))
+		defer ours.Close()
+		theirs := newRegistry(t, s, OnChange(func(name string, r Record) {
+			assert.Equal(t, "payments", name)
+			changes <- r
+		}))
+		defer theirs.Close()
+
+		a, err := ours.Breaker("payments")
+		if err != nil {
+			t.Fatal("Breaker error: ", err)
+		}
+		b, err := theirs.Breaker("payments")
+		if err != nil {
+			t.Fatal("Breaker error: ", err)
+		}
+		assert.True(t, b.Allow())
+
+		if err := a.Trip("timeouts"); err != nil {
+			t.Fatal("Trip error: ", err)
+		}
+		select {
+		case r := <-changes:
+			assert.Equal(t, Open, r.State)
+			assert.Equal(t, "a", r.By)
+			assert.Equal(t, "timeouts", r.Reason)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the trip wasn't shared")
+		}
+		assert.False(t, b.Allow())
+	})
+}
+
+func TestBreakerShouldRateLimitWrites(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		r := newRegistry(t, s, WithMinInterval(200*time.Millisecond), WithCooldown(time.Hour))
+		b, err := r.Breaker("search")
+		if err != nil {
+			t.Fatal("Breaker error: ", err)
+		}
+
+		assert.NoError(t, b.Trip("errors"))
+		assert.NoError(t, b.Reset("recovered"))
+		assert.Equal(t, Closed, b.State())
+
+		data, stat, err := s.Get("/test/breakers/search")
+		assert.NoError(t, err)
+		assert.Contains(t, data, `"open"`)
+		assert.Equal(t, 0, stat.Version())
+
+		assert.Eventually(t, func() bool {
+			_, stat, err := s.Get("/test/breakers/search")
+			return err == nil && stat.Version() == 1
+		}, 5*time.Second, 20*time.Millisecond)
+
+		// Changes cancelling out before they are written aren't written.
+		assert.NoError(t, b.Trip("errors again"))
+		assert.NoError(t, b.Reset("false alarm"))
+		assert.NoError(t, r.Close())
+		_, stat, err = s.Get("/test/breakers/search")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stat.Version())
+	})
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// Node is a snapshot of a cached znode. Epoch is the session epoch the node
+// was read in; see session.ZKSession.Epoch.
+//
+// Restored is set for nodes loaded from a snapshot file and not yet read
+// again from ZooKeeper; see WithSnapshot. Their Stat is nil and their Epoch
+// zero.
+type Node struct {
+	Path     string
+	Data     string
+	Stat     *zookeeper.Stat
+	Epoch    uint64
+	Restored bool
+}
+
+// Diff is a change to the cached tree. It is one of NodeCreated, NodeUpdated,
+// NodeDeleted, NodeRejected or InitialSyncDone.
+type Diff interface {
+	diff()
+}
+
+// NodeCreated is delivered when a node is added to the cache, including while
+// the cache is first being populated.
+type NodeCreated struct {
+	Path    string
+	Data    string
+	Stat    *zookeeper.Stat
+	Epoch   uint64
+	CatchUp bool
+}
+
+// NodeUpdated is delivered when a cached node's data changes.
+type NodeUpdated struct {
+	Path    string
+	Old     Node
+	New     Node
+	CatchUp bool
+}
+
+// NodeDeleted is delivered when a node is removed from the cache. Descendants
+// are deleted before their parents.
+type NodeDeleted struct {
+	Path    string
+	Epoch   uint64
+	CatchUp bool
+}
+
+// NodeRejected is delivered when the data read for a node is rejected by a
+// validator given to session.WithReadValidator. A cached node keeps its last
+// valid data, and a node not yet cached is only added once valid data is
+// written to it.
+type NodeRejected struct {
+	Path  string
+	Stat  *zookeeper.Stat
+	Epoch uint64
+	Err   error
+}
+
+// InitialSyncDone marks the end of the initial population of the cache. Every
+// NodeCreated delivered before it describes the tree as it was when the cache
+// started.
+type InitialSyncDone struct{}
+
+func (NodeCreated) diff()     {}
+func (NodeUpdated) diff()     {}
+func (NodeDeleted) diff()     {}
+func (NodeRejected) diff()    {}
+func (InitialSyncDone) diff() {}
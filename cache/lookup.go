@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/internal/negcache"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+type lookupResult struct {
+	data string
+	stat *zookeeper.Stat
+}
+
+// Lookup reads individual nodes on demand, for paths not worth keeping a
+// TreeCache for. Concurrent reads of the same path share a single request, and
+// a path found missing is reported missing for ttl without reading it again,
+// so callers polling for a node that doesn't exist yet don't multiply into a
+// read storm. Existing nodes are always read from ZooKeeper.
+type Lookup struct {
+	session session.Session
+	reads   *negcache.Cache[lookupResult]
+}
+
+// NewLookup returns a Lookup reading from s that remembers up to max missing
+// paths for ttl each.
+func NewLookup(s session.Session, ttl time.Duration, max int) *Lookup {
+	return &Lookup{
+		session: s,
+		reads: negcache.New(ttl, max, func(_ lookupResult, err error) bool {
+			return zookeeper.IsError(err, zookeeper.ZNONODE)
+		}),
+	}
+}
+
+// Get returns the data and stat of the node at path. A ZNONODE error may have
+// been remembered from an earlier read.
+func (l *Lookup) Get(path string) (string, *zookeeper.Stat, error) {
+	r, err := l.reads.Do(path, func() (lookupResult, error) {
+		data, stat, err := l.session.Get(path)
+		return lookupResult{data: data, stat: stat}, err
+	})
+	return r.data, r.stat, err
+}
+
+// Forget makes the next Get of path read it again, for callers that know it
+// has since been created.
+func (l *Lookup) Forget(path string) {
+	l.reads.Forget(path)
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// PreloadProgress reports a path whose cache has completed its initial sync,
+// or failed to before the deadline.
+type PreloadProgress struct {
+	Path string
+	// Done and Total count the paths preloaded so far, including this one,
+	// and to preload.
+	Done    int
+	Total   int
+	Elapsed time.Duration
+	Err     error
+}
+
+type preloadOptions struct {
+	timeout      time.Duration
+	progress     func(PreloadProgress)
+	cacheOptions []Option
+}
+
+// PreloadOption configures Preload.
+type PreloadOption func(preloadOptions) preloadOptions
+
+// WithPreloadTimeout gives up waiting for the preload after timeout overall.
+func WithPreloadTimeout(timeout time.Duration) PreloadOption {
+	return func(o preloadOptions) preloadOptions {
+		o.timeout = timeout
+		return o
+	}
+}
+
+// WithPreloadProgress calls progress as each path finishes preloading. It is
+// called from the goroutine waiting for the path, so calls may be concurrent.
+func WithPreloadProgress(progress func(PreloadProgress)) PreloadOption {
+	return func(o preloadOptions) preloadOptions {
+		o.progress = progress
+		return o
+	}
+}
+
+// WithCacheOptions configures the caches started by Preload.
+func WithCacheOptions(opts ...Option) PreloadOption {
+	return func(o preloadOptions) preloadOptions {
+		o.cacheOptions = append(o.cacheOptions, opts...)
+		return o
+	}
+}
+
+// Preloaded is a set of caches started by Preload.
+type Preloaded struct {
+	// caches have disjoint subtrees, so at most one covers any path.
+	caches []*TreeCache
+}
+
+// Preload starts a TreeCache for each of paths, all in parallel, and waits
+// for them to complete their initial sync, so that reads of hot paths made
+// once an application has started are served from memory, with watches
+// already set. Paths under another of the paths share its cache.
+//
+// If ctx is done or the timeout given with WithPreloadTimeout passes first,
+// Preload returns the caches along with an error naming the paths still
+// syncing, which carry on syncing in the background.
+func Preload(ctx context.Context, s session.Session, paths []string, opts ...PreloadOption) (*Preloaded, error) {
+	var o preloadOptions
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	roots := preloadRoots(paths)
+	p := &Preloaded{}
+	for _, root := range roots {
+		p.caches = append(p.caches, NewTreeCache(s, root, o.cacheOptions...))
+	}
+
+	start := time.Now()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var pending []string
+	done := 0
+	for _, tc := range p.caches {
+		wg.Add(1)
+		tc := tc
+		go func() {
+			defer wg.Done()
+			err := tc.StartAndWait(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				pending = append(pending, tc.root)
+			}
+			if o.progress != nil {
+				progress := PreloadProgress{Path: tc.root, Done: done, Total: len(roots), Elapsed: time.Since(start), Err: err}
+				_ = session.Protect(s, "preload", func() { o.progress(progress) })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		err := ctx.Err()
+		if err == nil {
+			err = ErrClosedBeforeSync
+		}
+		return p, fmt.Errorf("preloading %s: %w", strings.Join(pending, ", "), err)
+	}
+	return p, nil
+}
+
+// preloadRoots returns paths without duplicates and paths under another one.
+func preloadRoots(paths []string) []string {
+	var roots []string
+	for _, path := range paths {
+		covered := false
+		for _, other := range paths {
+			if other != path && matchesPrefix(path, other) {
+				covered = true
+				break
+			}
+		}
+		if !covered && !contains(roots, path) {
+			roots = append(roots, path)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Cache returns the cache covering path, if any.
+func (p *Preloaded) Cache(path string) (*TreeCache, bool) {
+	for _, tc := range p.caches {
+		if matchesPrefix(path, tc.root) {
+			return tc, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the cached node at path, if path is under one of the preloaded
+// paths and the node exists.
+func (p *Preloaded) Get(path string) (Node, bool) {
+	tc, ok := p.Cache(path)
+	if !ok {
+		return Node{}, false
+	}
+	return tc.Get(path)
+}
+
+// Close closes every cache.
+func (p *Preloaded) Close() {
+	for _, tc := range p.caches {
+		tc.Close()
+	}
+}
+
+func contains(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreloadRootsShouldDropNestedPaths(t *testing.T) {
+	roots := preloadRoots([]string{"/a/b", "/a-b", "/a", "/c", "/a", "/c/d/e"})
+	assert.Equal(t, []string{"/a", "/a-b", "/c"}, roots)
+}
+
+func TestPreloadShouldSyncEveryPath(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo", "/test/foo/bar", "/test/baz")
+
+		var progress []PreloadProgress
+		p, err := Preload(context.Background(), s, []string{"/test/foo", "/test/foo/bar", "/test/baz"},
+			WithPreloadTimeout(5*time.Second),
+			WithPreloadProgress(func(pp PreloadProgress) { progress = append(progress, pp) }))
+		if err != nil {
+			t.Fatal("Preload error: ", err)
+		}
+		defer p.Close()
+
+		_, ok := p.Get("/test/foo/bar")
+		assert.True(t, ok)
+		_, ok = p.Get("/test/baz")
+		assert.True(t, ok)
+		_, ok = p.Get("/test")
+		assert.False(t, ok)
+
+		assert.Len(t, progress, 2)
+		for _, pp := range progress {
+			assert.NoError(t, pp.Err)
+			assert.Equal(t, 2, pp.Total)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"errors"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+type readOptions struct {
+	policy session.DisconnectPolicy
+	fresh  bool
+}
+
+// ReadOption sets how a single Read trades freshness for availability,
+// instead of the policy given with WithDisconnectPolicy.
+type ReadOption func(readOptions) readOptions
+
+// PreferCache answers the Read from the cached node whatever the state of the
+// session and the cache, like session.ServeStale: always available, but
+// possibly out of date or, before the initial sync, missing.
+func PreferCache() ReadOption {
+	return func(o readOptions) readOptions {
+		o.policy, o.fresh = session.ServeStale(), false
+		return o
+	}
+}
+
+// RequireFresh reads the node from ZooKeeper rather than the cache, which
+// trails it by however long watch notifications take, failing with
+// session.ErrDisconnected while the session is disconnected rather than
+// answer with data that may be out of date.
+func RequireFresh() ReadOption {
+	return func(o readOptions) readOptions {
+		o.fresh = true
+		return o
+	}
+}
+
+// WithReadPolicy applies policy to the Read, as WithDisconnectPolicy does to
+// every Read of the cache.
+func WithReadPolicy(policy session.DisconnectPolicy) ReadOption {
+	return func(o readOptions) readOptions {
+		o.policy, o.fresh = policy, false
+		return o
+	}
+}
+
+// ErrNoSession is returned by RequireFresh reads from a cache created
+// without a session, such as to load a snapshot file.
+var ErrNoSession = errors.New("cache has no session to read from")
+
+// readFresh reads the node at path from ZooKeeper, reporting whether it
+// exists.
+func (tc *TreeCache) readFresh(path string) (Node, bool, error) {
+	if tc.session == nil {
+		return Node{}, false, ErrNoSession
+	}
+	if !tc.conn.Connected() {
+		return Node{}, false, session.ErrDisconnected
+	}
+	epoch := session.EpochOf(tc.session)
+	data, stat, err := tc.session.Get(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return Node{}, false, nil
+	}
+	if err != nil {
+		return Node{}, false, err
+	}
+	return Node{Path: path, Data: data, Stat: stat, Epoch: epoch}, true, nil
+}
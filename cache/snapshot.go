@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrNoSnapshotFile is returned by SaveSnapshot for caches created without
+// WithSnapshot.
+var ErrNoSnapshotFile = errors.New("cache has no snapshot file")
+
+// snapshotVersion is the format version of snapshot files.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version int            `json:"version"`
+	Root    string         `json:"root"`
+	Nodes   []snapshotNode `json:"nodes"`
+}
+
+type snapshotNode struct {
+	Path    string `json:"path"`
+	Data    string `json:"data"`
+	Version int    `json:"data_version"`
+	Mzxid   int64  `json:"mzxid"`
+}
+
+// WithSnapshot makes the cache start from the snapshot in file, if there is
+// one, and save a snapshot to it once it has synced and again when it is
+// closed. Nodes loaded from the snapshot are readable as soon as Start
+// returns, marked Restored and without a Stat, and DiffStream subscribers get
+// them as NodeCreated diffs. The cache then reconciles them with ZooKeeper in
+// the background, delivering NodeUpdated, NodeCreated and NodeDeleted diffs
+// for whatever changed since the snapshot was taken, and InitialSyncDone once
+// every node has been read again.
+//
+// A missing, unreadable or mismatched snapshot is ignored and the cache starts
+// empty, as without WithSnapshot.
+func WithSnapshot(file string) Option {
+	return func(o options) options {
+		o.snapshotFile = file
+		return o
+	}
+}
+
+// WriteSnapshot writes the cached nodes to w, in the form loaded by
+// WithSnapshot.
+func (tc *TreeCache) WriteSnapshot(w io.Writer) error {
+	tc.mu.RLock()
+	snap := snapshot{Version: snapshotVersion, Root: tc.root, Nodes: make([]snapshotNode, 0, len(tc.nodes))}
+	for _, node := range tc.nodes {
+		sn := snapshotNode{Path: node.Path, Data: node.Data, Version: node.restoredVersion}
+		if node.Stat != nil {
+			sn.Version = node.Stat.Version()
+			sn.Mzxid = node.Stat.Mzxid()
+		}
+		snap.Nodes = append(snap.Nodes, sn)
+	}
+	tc.mu.RUnlock()
+
+	sort.Slice(snap.Nodes, func(i, j int) bool { return snap.Nodes[i].Path < snap.Nodes[j].Path })
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// SaveSnapshot saves the cached nodes to the file given to WithSnapshot. It is
+// done automatically once the cache has synced and when it is closed, without
+// reporting errors; call it to save at other times or to check for errors.
+// The file is replaced through a temporary file, so a crash doesn't leave it
+// truncated.
+func (tc *TreeCache) SaveSnapshot() error {
+	if tc.opts.snapshotFile == "" {
+		return ErrNoSnapshotFile
+	}
+	file := tc.opts.snapshotFile
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tc.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// loadSnapshot populates the cache from the snapshot file, returning false if
+// there is no usable snapshot.
+func (tc *TreeCache) loadSnapshot() bool {
+	f, err := os.Open(tc.opts.snapshotFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil || snap.Version != snapshotVersion || snap.Root != tc.root {
+		return false
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for _, sn := range snap.Nodes {
+		if sn.Path != tc.root && !matchesPrefix(sn.Path, tc.root) {
+			continue
+		}
+		tc.nodes[sn.Path] = &treeNode{
+			Node:            Node{Path: sn.Path, Data: sn.Data, Restored: true},
+			children:        make(map[string]bool),
+			restoredVersion: sn.Version,
+		}
+	}
+	for path := range tc.nodes {
+		if path == tc.root {
+			continue
+		}
+		parent, ok := tc.nodes[parentPath(path)]
+		if !ok {
+			return tc.discardSnapshot()
+		}
+		parent.children[baseName(path)] = true
+	}
+	if _, ok := tc.nodes[tc.root]; !ok && len(tc.nodes) > 0 {
+		return tc.discardSnapshot()
+	}
+	return len(tc.nodes) > 0
+}
+
+// discardSnapshot empties a cache populated from an inconsistent snapshot.
+func (tc *TreeCache) discardSnapshot() bool {
+	tc.nodes = make(map[string]*treeNode)
+	return false
+}
@@ -0,0 +1,664 @@
+// Package cache keeps local copies of ZooKeeper data up to date using watches.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// retryInterval is how long to wait before re-reading a node after an error
+// talking to ZooKeeper.
+var retryInterval = time.Second
+
+// diffBuffer is the number of diffs buffered for each DiffStream subscriber.
+const diffBuffer = 64
+
+// ErrClosedBeforeSync is returned by StartAndWait when the cache is closed
+// before its initial population completes.
+var ErrClosedBeforeSync = errors.New("cache closed before initial sync")
+
+// ErrNotSynced is returned by Read, following session.FailFast or
+// session.BlockUntilReconnect, before the cache has completed its initial
+// sync.
+var ErrNotSynced = errors.New("cache has not completed its initial sync")
+
+type refreshKind int
+
+const (
+	refreshData refreshKind = iota
+	refreshChildren
+	refreshRoot
+)
+
+type refresh struct {
+	path  string
+	kind  refreshKind
+	retry bool
+	// catchUp is set for refreshes triggered by the session reconnecting
+	// rather than by a change.
+	catchUp bool
+}
+
+type subscription struct {
+	prefix string
+	diffs  chan Diff
+}
+
+type options struct {
+	snapshotFile     string
+	middlewares      []middleware.Middleware[Diff]
+	disconnectPolicy session.DisconnectPolicy
+}
+
+// Option configures a TreeCache.
+type Option func(options) options
+
+// WithMiddleware passes diffs through middlewares, the first seeing each diff
+// first, before they are delivered to DiffStream subscribers. Diffs go through
+// the chain once however many subscribers there are, except the replay of
+// cached nodes to a new subscriber, which goes through it again for that
+// subscriber only. Middlewares run on the cache's goroutine, so they must not
+// block for long.
+func WithMiddleware(middlewares ...middleware.Middleware[Diff]) Option {
+	return func(o options) options {
+		o.middlewares = append(o.middlewares, middlewares...)
+		return o
+	}
+}
+
+// WithDisconnectPolicy sets what Read does while the session is disconnected
+// or the cache hasn't completed its initial sync, including while it
+// reconciles nodes restored from a snapshot: fail, wait, or, like the
+// default, serve the cached nodes, which may be out of date. Get always
+// serves the cached nodes.
+func WithDisconnectPolicy(policy session.DisconnectPolicy) Option {
+	return func(o options) options {
+		o.disconnectPolicy = policy
+		return o
+	}
+}
+
+type treeNode struct {
+	Node
+	children map[string]bool
+	// restoredVersion is the data version of a restored node.
+	restoredVersion int
+}
+
+// TreeCache caches all nodes in the subtree rooted at a path, keeping them up to
+// date with data and child watches. Watches are re-armed after they fire or
+// the session reconnects, and the affected nodes re-read so that changes made
+// in the meantime are picked up.
+//
+// All reads and watch handling happen on a single goroutine, so diffs are
+// delivered in the order they were observed.
+type TreeCache struct {
+	session session.Session
+	root    string
+	opts    options
+
+	mu    sync.RWMutex
+	nodes map[string]*treeNode
+
+	emitter middleware.Handler[Diff]
+	conn    *session.ConnectionState
+
+	refreshes   chan refresh
+	subscribe   chan subscription
+	subscribers []subscription
+	// early holds the subscriptions made before Start, guarded by mu
+	// until started is set.
+	early   []subscription
+	started bool
+	// closed is set by Close, guarded by mu, keeping a later Start from
+	// running the cache.
+	closed   bool
+	synced   bool
+	syncDone chan struct{}
+	retries  int
+	// catchingUp is set while handling a catch-up refresh, marking the
+	// diffs it emits.
+	catchingUp bool
+	// syncOp traces the initial sync, until synced is set.
+	syncOp *session.Operation
+
+	unregister func()
+	closeOnce  sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewTreeCache creates a cache for the subtree rooted at root. The root does
+// not need to exist; it will be picked up once created.
+func NewTreeCache(s session.Session, root string, opts ...Option) *TreeCache {
+	var o options
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	tc := &TreeCache{
+		session:   s,
+		root:      root,
+		opts:      o,
+		nodes:     make(map[string]*treeNode),
+		refreshes: make(chan refresh),
+		subscribe: make(chan subscription),
+		syncDone:  make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	tc.emitter = middleware.Chain(tc.fanOut, o.middlewares...)
+	// Caches without a session, such as ones only loading a snapshot, have
+	// no connection to follow.
+	if s != nil {
+		tc.conn = session.NewConnectionState(s)
+	}
+	return tc
+}
+
+// Start populates the cache and keeps it up to date in the background until
+// Close is called or the session is closed.
+func (tc *TreeCache) Start() {
+	if tc.opts.snapshotFile != "" {
+		tc.loadSnapshot()
+	}
+	tc.mu.Lock()
+	if tc.closed {
+		tc.mu.Unlock()
+		return
+	}
+	tc.started = true
+	tc.mu.Unlock()
+	tc.unregister = session.RegisterCloser(tc.session, session.CloserFunc(func() error {
+		tc.Close()
+		return nil
+	}))
+	session.Go(tc.session, "cache", tc.run)
+}
+
+// StartAndWait starts the cache and waits for its initial population, so that
+// it isn't read while still empty. If ctx is done first its error is returned
+// and the cache keeps populating in the background.
+func (tc *TreeCache) StartAndWait(ctx context.Context) error {
+	tc.Start()
+	select {
+	case <-tc.syncDone:
+		return nil
+	case <-tc.done:
+		return ErrClosedBeforeSync
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InitialSyncDone returns a channel closed once the cache has finished its
+// initial population, as reported to DiffStream subscribers by the
+// InitialSyncDone diff.
+func (tc *TreeCache) InitialSyncDone() <-chan struct{} {
+	return tc.syncDone
+}
+
+// Close stops updating the cache and closes all DiffStream channels. Closing
+// it again has no effect, and a cache closed before Start is never started.
+func (tc *TreeCache) Close() {
+	tc.closeOnce.Do(func() {
+		if tc.unregister != nil {
+			tc.unregister()
+		}
+		close(tc.stop)
+		tc.mu.Lock()
+		tc.closed = true
+		started := tc.started
+		early := tc.early
+		tc.early = nil
+		tc.mu.Unlock()
+		if !started {
+			// run never ran, so nothing else closes these.
+			for _, sub := range early {
+				close(sub.diffs)
+			}
+			close(tc.done)
+			return
+		}
+		<-tc.done
+		if tc.opts.snapshotFile != "" && tc.synced {
+			_ = tc.SaveSnapshot()
+		}
+	})
+}
+
+// Run starts the cache and keeps it up to date until ctx is done, closing it
+// and returning ctx.Err(), or until it is closed otherwise, as with its
+// session, returning nil. See session.RunUntil.
+func (tc *TreeCache) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		tc.Start()
+		return nil
+	}, tc.done, func() error {
+		tc.Close()
+		return nil
+	})
+}
+
+// Get returns the cached node at path.
+func (tc *TreeCache) Get(path string) (Node, bool) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	node, ok := tc.nodes[path]
+	if !ok {
+		return Node{}, false
+	}
+	return node.Node, true
+}
+
+// Read returns the cached node at path like Get, applying the policy given
+// with WithDisconnectPolicy while the session is disconnected or the cache
+// isn't synced. opts override the policy for this call, such as PreferCache
+// or RequireFresh.
+func (tc *TreeCache) Read(path string, opts ...ReadOption) (Node, bool, error) {
+	o := readOptions{policy: tc.opts.disconnectPolicy}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	if o.fresh {
+		return tc.readFresh(path)
+	}
+	if _, err := o.policy.CheckReady(tc.conn, tc.syncDone, ErrNotSynced); err != nil {
+		return Node{}, false, err
+	}
+	node, ok := tc.Get(path)
+	return node, ok, nil
+}
+
+// Children returns the sorted names of the cached children of path.
+func (tc *TreeCache) Children(path string) []string {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	node, ok := tc.nodes[path]
+	if !ok {
+		return nil
+	}
+
+	children := make([]string, 0, len(node.children))
+	for child := range node.children {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	return children
+}
+
+// DiffStream returns a channel of changes to path and its descendants. The
+// nodes already cached are delivered first as NodeCreated diffs, so a
+// subscriber can build a complete view from the stream alone. InitialSyncDone
+// is delivered once the cache has finished its initial population.
+//
+// The channel must be drained promptly: the cache blocks when it is full. It
+// is closed when the cache is closed. DiffStream may be called before Start,
+// and must be to see the diffs reconciling nodes restored with WithSnapshot.
+func (tc *TreeCache) DiffStream(path string) <-chan Diff {
+	sub := subscription{prefix: path, diffs: make(chan Diff, diffBuffer)}
+	tc.mu.Lock()
+	if tc.closed && !tc.started {
+		tc.mu.Unlock()
+		close(sub.diffs)
+		return sub.diffs
+	}
+	if !tc.started {
+		tc.early = append(tc.early, sub)
+		tc.mu.Unlock()
+		return sub.diffs
+	}
+	tc.mu.Unlock()
+
+	select {
+	case tc.subscribe <- sub:
+	case <-tc.done:
+		close(sub.diffs)
+	}
+	return sub.diffs
+}
+
+func (tc *TreeCache) run() {
+	detach := session.Attach(tc.session, "cache", tc.root)
+	defer detach()
+	defer close(tc.done)
+	tc.syncOp = session.StartOperation(tc.session, "cache", "sync", tc.root)
+	defer func() {
+		if !tc.synced {
+			tc.syncOp.End(ErrClosedBeforeSync)
+		}
+	}()
+	defer func() {
+		for _, sub := range tc.subscribers {
+			close(sub.diffs)
+		}
+	}()
+
+	for _, sub := range tc.early {
+		tc.addSubscriber(sub)
+	}
+	tc.early = nil
+
+	tc.refreshData(tc.root)
+	tc.checkSynced()
+
+	for {
+		select {
+		case r := <-tc.refreshes:
+			if r.retry {
+				tc.retries--
+			}
+			tc.catchingUp = r.catchUp
+			switch r.kind {
+			case refreshData:
+				tc.refreshData(r.path)
+			case refreshChildren:
+				tc.refreshChildren(r.path)
+			case refreshRoot:
+				tc.watchRoot()
+			}
+			tc.catchingUp = false
+			tc.checkSynced()
+		case sub := <-tc.subscribe:
+			tc.addSubscriber(sub)
+		case <-tc.stop:
+			return
+		}
+	}
+}
+
+func (tc *TreeCache) checkSynced() {
+	if !tc.synced && tc.retries == 0 {
+		tc.synced = true
+		tc.syncOp.End(nil)
+		close(tc.syncDone)
+		tc.emit(InitialSyncDone{})
+		if tc.opts.snapshotFile != "" {
+			_ = tc.SaveSnapshot()
+		}
+	}
+}
+
+func (tc *TreeCache) refreshData(path string) {
+	epoch := session.EpochOf(tc.session)
+	data, stat, watch, err := tc.session.GetW(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		tc.remove(path)
+		return
+	}
+	if errors.Is(err, session.ErrInvalidData) {
+		tc.arm(watch, refresh{path: path, kind: refreshData})
+		tc.emit(NodeRejected{Path: path, Stat: stat, Epoch: epoch, Err: err})
+		return
+	}
+	if err != nil {
+		tc.retry(refresh{path: path, kind: refreshData})
+		return
+	}
+	tc.arm(watch, refresh{path: path, kind: refreshData})
+
+	tc.mu.Lock()
+	existing, ok := tc.nodes[path]
+	node := Node{Path: path, Data: data, Stat: stat, Epoch: epoch}
+	if ok {
+		old := existing.Node
+		oldVersion := existing.restoredVersion
+		if old.Stat != nil {
+			oldVersion = old.Stat.Version()
+		}
+		existing.Node = node
+		tc.mu.Unlock()
+		if (old.Stat == nil && !old.Restored) || oldVersion != stat.Version() || old.Data != data {
+			tc.emit(NodeUpdated{Path: path, Old: old, New: node, CatchUp: tc.catchingUp})
+		}
+		if old.Restored {
+			tc.refreshChildren(path)
+		}
+		return
+	}
+
+	tc.nodes[path] = &treeNode{Node: node, children: make(map[string]bool)}
+	if parent, ok := tc.nodes[parentPath(path)]; ok && path != tc.root {
+		parent.children[baseName(path)] = true
+	}
+	tc.mu.Unlock()
+
+	tc.emit(NodeCreated{Path: path, Data: data, Stat: stat, Epoch: epoch, CatchUp: tc.catchingUp})
+	tc.refreshChildren(path)
+}
+
+func (tc *TreeCache) refreshChildren(path string) {
+	tc.mu.RLock()
+	_, ok := tc.nodes[path]
+	tc.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	children, _, watch, err := tc.session.ChildrenW(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		// The data watch will notice the deletion.
+		return
+	}
+	if err != nil {
+		tc.retry(refresh{path: path, kind: refreshChildren})
+		return
+	}
+	tc.arm(watch, refresh{path: path, kind: refreshChildren})
+
+	current := make(map[string]bool, len(children))
+	for _, child := range children {
+		current[child] = true
+	}
+
+	tc.mu.RLock()
+	var removed []string
+	for child := range tc.nodes[path].children {
+		if !current[child] {
+			removed = append(removed, child)
+		}
+	}
+	// Restored children are read again like new ones, to reconcile them
+	// and set their watches.
+	var added []string
+	for _, child := range children {
+		if node, ok := tc.nodes[joinPath(path, child)]; !ok || node.Restored {
+			added = append(added, child)
+		}
+	}
+	tc.mu.RUnlock()
+
+	sort.Strings(removed)
+	for _, child := range removed {
+		tc.remove(joinPath(path, child))
+	}
+	sort.Strings(added)
+	for _, child := range added {
+		tc.refreshData(joinPath(path, child))
+	}
+}
+
+// remove deletes path and its descendants from the cache, deepest first.
+func (tc *TreeCache) remove(path string) {
+	tc.mu.RLock()
+	node, ok := tc.nodes[path]
+	var children []string
+	if ok {
+		for child := range node.children {
+			children = append(children, child)
+		}
+	}
+	tc.mu.RUnlock()
+
+	if ok {
+		sort.Strings(children)
+		for _, child := range children {
+			tc.remove(joinPath(path, child))
+		}
+
+		tc.mu.Lock()
+		delete(tc.nodes, path)
+		if parent, ok := tc.nodes[parentPath(path)]; ok {
+			delete(parent.children, baseName(path))
+		}
+		tc.mu.Unlock()
+		tc.emit(NodeDeleted{Path: path, Epoch: session.EpochOf(tc.session), CatchUp: tc.catchingUp})
+	}
+
+	if path == tc.root {
+		tc.watchRoot()
+	}
+}
+
+// watchRoot waits for the root to be (re)created.
+func (tc *TreeCache) watchRoot() {
+	stat, watch, err := tc.session.ExistsW(tc.root)
+	if err != nil {
+		tc.retry(refresh{path: tc.root, kind: refreshRoot})
+		return
+	}
+	if stat != nil {
+		tc.refreshData(tc.root)
+		return
+	}
+	tc.arm(watch, refresh{path: tc.root, kind: refreshData})
+}
+
+// arm queues r once watch fires. Watches closed by a reconnect fire with a
+// zero event, which also triggers a refresh and re-arms the watch; that
+// refresh catches up with the changes made while disconnected, comparing the
+// nodes read with the cached versions.
+func (tc *TreeCache) arm(watch <-chan zookeeper.Event, r refresh) {
+	session.Go(tc.session, "cache", func() {
+		select {
+		case event, ok := <-watch:
+			r.catchUp = !ok || event.Type == zookeeper.EVENT_SESSION
+		case <-tc.stop:
+			return
+		}
+
+		select {
+		case tc.refreshes <- r:
+		case <-tc.stop:
+		}
+	})
+}
+
+func (tc *TreeCache) retry(r refresh) {
+	r.retry = true
+	r.catchUp = tc.catchingUp
+	tc.retries++
+	if !tc.synced {
+		tc.syncOp.Step("retrying " + r.path)
+	}
+	time.AfterFunc(retryInterval, func() {
+		select {
+		case tc.refreshes <- r:
+		case <-tc.stop:
+		}
+	})
+}
+
+func (tc *TreeCache) addSubscriber(sub subscription) {
+	tc.mu.RLock()
+	var paths []string
+	for path := range tc.nodes {
+		if matchesPrefix(path, sub.prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	nodes := make([]Node, 0, len(paths))
+	for _, path := range paths {
+		nodes = append(nodes, tc.nodes[path].Node)
+	}
+	tc.mu.RUnlock()
+
+	// The replay goes through the middlewares like every other diff, but
+	// only to the new subscriber.
+	ok := true
+	replay := middleware.Chain(func(d Diff) {
+		ok = ok && tc.send(sub, d)
+	}, tc.opts.middlewares...)
+	for _, node := range nodes {
+		if replay(NodeCreated{Path: node.Path, Data: node.Data, Stat: node.Stat, Epoch: node.Epoch}); !ok {
+			return
+		}
+	}
+	if tc.synced {
+		if replay(InitialSyncDone{}); !ok {
+			return
+		}
+	}
+	tc.subscribers = append(tc.subscribers, sub)
+}
+
+func (tc *TreeCache) emit(d Diff) {
+	tc.emitter(d)
+}
+
+func (tc *TreeCache) fanOut(d Diff) {
+	for _, sub := range tc.subscribers {
+		tc.send(sub, d)
+	}
+}
+
+func (tc *TreeCache) send(sub subscription, d Diff) bool {
+	if !matchesDiff(d, sub.prefix) {
+		return true
+	}
+
+	select {
+	case sub.diffs <- d:
+		return true
+	case <-tc.stop:
+		return false
+	}
+}
+
+func matchesDiff(d Diff, prefix string) bool {
+	switch d := d.(type) {
+	case NodeCreated:
+		return matchesPrefix(d.Path, prefix)
+	case NodeUpdated:
+		return matchesPrefix(d.Path, prefix)
+	case NodeDeleted:
+		return matchesPrefix(d.Path, prefix)
+	case NodeRejected:
+		return matchesPrefix(d.Path, prefix)
+	}
+	return true
+}
+
+func matchesPrefix(path, prefix string) bool {
+	return prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func joinPath(parent, child string) string {
+	if parent == "/" {
+		return "/" + child
+	}
+	return parent + "/" + child
+}
+
+func parentPath(path string) string {
+	index := strings.LastIndex(path, "/")
+	if index <= 0 {
+		return "/"
+	}
+	return path[:index]
+}
+
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,449 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createNodes(t *testing.T, s *session.ZKSession, nodes ...string) {
+	for _, node := range nodes {
+		if _, err := s.Create(node, node, 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Unable to create initial value in zk: ", err)
+		}
+	}
+}
+
+func nextDiff(t *testing.T, diffs <-chan Diff) Diff {
+	select {
+	case d := <-diffs:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failed to receive diff")
+	}
+	return nil
+}
+
+func TestTreeCacheShouldLoadExistingNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo", "/test/foo/bar")
+
+		tc := NewTreeCache(s, "/test")
+		tc.Start()
+		defer tc.Close()
+
+		diffs := tc.DiffStream("/test")
+		var created []string
+		for {
+			d := nextDiff(t, diffs)
+			if _, ok := d.(InitialSyncDone); ok {
+				break
+			}
+			created = append(created, d.(NodeCreated).Path)
+		}
+
+		assert.Equal(t, []string{"/test", "/test/foo", "/test/foo/bar"}, created)
+		assert.Equal(t, []string{"bar"}, tc.Children("/test/foo"))
+
+		node, ok := tc.Get("/test/foo/bar")
+		assert.True(t, ok)
+		assert.Equal(t, "/test/foo/bar", node.Data)
+	})
+}
+
+func TestStartAndWaitShouldReturnPopulatedCache(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo", "/test/foo/bar")
+
+		tc := NewTreeCache(s, "/test")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tc.StartAndWait(ctx); err != nil {
+			t.Fatal("StartAndWait error: ", err)
+		}
+		defer tc.Close()
+
+		_, ok := tc.Get("/test/foo/bar")
+		assert.True(t, ok)
+		select {
+		case <-tc.InitialSyncDone():
+		default:
+			t.Fatal("InitialSyncDone not closed")
+		}
+	})
+}
+
+func TestRunShouldCloseCacheWhenContextIsDone(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo")
+
+		tc := NewTreeCache(s, "/test")
+		diffs := tc.DiffStream("/test")
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() { result <- tc.Run(ctx) }()
+
+		select {
+		case <-tc.InitialSyncDone():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for initial sync")
+		}
+		_, ok := tc.Get("/test/foo")
+		assert.True(t, ok)
+
+		cancel()
+		select {
+		case err := <-result:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for Run to return")
+		}
+		for range diffs {
+		}
+	})
+}
+
+func TestReadShouldFailFastBeforeSync(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo")
+
+		tc := NewTreeCache(s, "/test", WithDisconnectPolicy(session.FailFast()))
+		_, _, err := tc.Read("/test/foo")
+		assert.ErrorIs(t, err, ErrNotSynced)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tc.StartAndWait(ctx); err != nil {
+			t.Fatal("StartAndWait error: ", err)
+		}
+		defer tc.Close()
+
+		_, ok, err := tc.Read("/test/foo")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestReadOptionsShouldOverridePolicy(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo")
+
+		tc := NewTreeCache(s, "/test", WithDisconnectPolicy(session.FailFast()))
+		_, ok, err := tc.Read("/test/foo", PreferCache())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		node, ok, err := tc.Read("/test/foo", RequireFresh())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "/test/foo", node.Path)
+
+		_, ok, err = tc.Read("/test/missing", RequireFresh())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestRequireFreshShouldFailWithoutSession(t *testing.T) {
+	tc := NewTreeCache(nil, "/test")
+	_, _, err := tc.Read("/test", RequireFresh())
+	assert.Equal(t, ErrNoSession, err)
+}
+
+func TestCloseShouldReturnWithoutStart(t *testing.T) {
+	tc := NewTreeCache(nil, "/test")
+	diffs := tc.DiffStream("/test")
+	closed := make(chan struct{})
+	go func() {
+		tc.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on a cache that was never started")
+	}
+	_, ok := <-diffs
+	assert.False(t, ok, "Expected DiffStream channels to be closed")
+	_, ok = <-tc.DiffStream("/test")
+	assert.False(t, ok, "Expected DiffStream channels of a closed cache to be closed")
+	assert.Equal(t, ErrClosedBeforeSync, tc.StartAndWait(context.Background()))
+}
+
+func TestDiffStreamShouldReportChanges(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+
+		tc := NewTreeCache(s, "/test")
+		tc.Start()
+		defer tc.Close()
+
+		diffs := tc.DiffStream("/test")
+		assert.Equal(t, "/test", nextDiff(t, diffs).(NodeCreated).Path)
+		assert.Equal(t, InitialSyncDone{}, nextDiff(t, diffs))
+
+		createNodes(t, s, "/test/foo")
+		assert.Equal(t, "/test/foo", nextDiff(t, diffs).(NodeCreated).Path)
+
+		if _, err := s.Set("/test/foo", "spam", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		updated := nextDiff(t, diffs).(NodeUpdated)
+		assert.Equal(t, "/test/foo", updated.Old.Data)
+		assert.Equal(t, "spam", updated.New.Data)
+
+		if err := s.Delete("/test/foo", -1); err != nil {
+			t.Fatal("Delete error: ", err)
+		}
+		assert.Equal(t, NodeDeleted{Path: "/test/foo"}, nextDiff(t, diffs))
+	})
+}
+
+func TestPathHelpers(t *testing.T) {
+	assert.Equal(t, "/foo", joinPath("/", "foo"))
+	assert.Equal(t, "/foo/bar", joinPath("/foo", "bar"))
+	assert.Equal(t, "/", parentPath("/foo"))
+	assert.Equal(t, "/foo", parentPath("/foo/bar"))
+	assert.Equal(t, "bar", baseName("/foo/bar"))
+	assert.True(t, matchesPrefix("/foo/bar", "/foo"))
+	assert.False(t, matchesPrefix("/foobar", "/foo"))
+	assert.True(t, matchesPrefix("/foobar", "/"))
+}
+
+func TestLookupShouldRememberMissingPaths(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		l := NewLookup(s, time.Minute, 16)
+		_, _, err := l.Get("/test")
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+
+		createNodes(t, s, "/test")
+		_, _, err = l.Get("/test")
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+
+		l.Forget("/test")
+		data, stat, err := l.Get("/test")
+		if err != nil {
+			t.Fatal("Get error: ", err)
+		}
+		assert.Equal(t, "/test", data)
+		assert.NotNil(t, stat)
+	})
+}
+
+func TestTreeCacheShouldStartFromSnapshot(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		file := filepath.Join(t.TempDir(), "snapshot.json")
+		createNodes(t, s, "/test", "/test/foo", "/test/bar")
+
+		tc := NewTreeCache(s, "/test", WithSnapshot(file))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tc.StartAndWait(ctx); err != nil {
+			t.Fatal("StartAndWait error: ", err)
+		}
+		tc.Close()
+
+		if _, err := s.Set("/test/foo", "spam", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		if err := s.Delete("/test/bar", -1); err != nil {
+			t.Fatal("Delete error: ", err)
+		}
+		createNodes(t, s, "/test/baz")
+
+		tc = NewTreeCache(s, "/test", WithSnapshot(file))
+		diffs := tc.DiffStream("/test")
+		tc.Start()
+		defer tc.Close()
+
+		node, ok := tc.Get("/test/bar")
+		assert.True(t, ok)
+		assert.True(t, node.Restored)
+
+		var restored []string
+		for len(restored) < 3 {
+			created := nextDiff(t, diffs).(NodeCreated)
+			restored = append(restored, created.Path)
+		}
+		assert.Equal(t, []string{"/test", "/test/bar", "/test/foo"}, restored)
+
+		var corrections []Diff
+		for {
+			d := nextDiff(t, diffs)
+			if _, ok := d.(InitialSyncDone); ok {
+				break
+			}
+			corrections = append(corrections, d)
+		}
+		if assert.Len(t, corrections, 3) {
+			assert.Equal(t, "/test/bar", corrections[0].(NodeDeleted).Path)
+			assert.Equal(t, "/test/baz", corrections[1].(NodeCreated).Path)
+			updated := corrections[2].(NodeUpdated)
+			assert.Equal(t, "/test/foo", updated.Old.Data)
+			assert.True(t, updated.Old.Restored)
+			assert.Equal(t, "spam", updated.New.Data)
+		}
+
+		node, ok = tc.Get("/test")
+		assert.True(t, ok)
+		assert.False(t, node.Restored)
+		assert.NotNil(t, node.Stat)
+	})
+}
+
+func TestSnapshotShouldBeIgnoredUnlessConsistent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	tc := NewTreeCache(nil, "/test", WithSnapshot(write("ok.json", `{"version":1,"root":"/test","nodes":[{"path":"/test","data":"a"},{"path":"/test/foo","data":"b","data_version":3}]}`)))
+	assert.True(t, tc.loadSnapshot())
+	node, ok := tc.Get("/test/foo")
+	assert.True(t, ok)
+	assert.True(t, node.Restored)
+	assert.Equal(t, "b", node.Data)
+	assert.Equal(t, []string{"foo"}, tc.Children("/test"))
+
+	tc = NewTreeCache(nil, "/test", WithSnapshot(write("root.json", `{"version":1,"root":"/other","nodes":[{"path":"/other"}]}`)))
+	assert.False(t, tc.loadSnapshot())
+
+	tc = NewTreeCache(nil, "/test", WithSnapshot(write("orphan.json", `{"version":1,"root":"/test","nodes":[{"path":"/test"},{"path":"/test/a/b"}]}`)))
+	assert.False(t, tc.loadSnapshot())
+	_, ok = tc.Get("/test")
+	assert.False(t, ok)
+
+	tc = NewTreeCache(nil, "/test", WithSnapshot(filepath.Join(dir, "missing.json")))
+	assert.False(t, tc.loadSnapshot())
+	assert.ErrorIs(t, NewTreeCache(nil, "/test").SaveSnapshot(), ErrNoSnapshotFile)
+}
+
+func TestTreeCacheShouldApplyMiddleware(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo")
+
+		onlyCreated := middleware.Filter(func(d Diff) bool {
+			_, ok := d.(NodeCreated)
+			return ok
+		})
+		tc := NewTreeCache(s, "/test", WithMiddleware(onlyCreated))
+		diffs := tc.DiffStream("/test")
+		tc.Start()
+		defer tc.Close()
+
+		assert.Equal(t, "/test", nextDiff(t, diffs).(NodeCreated).Path)
+		assert.Equal(t, "/test/foo", nextDiff(t, diffs).(NodeCreated).Path)
+
+		if _, err := s.Set("/test/foo", "spam", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		createNodes(t, s, "/test/bar")
+		assert.Equal(t, "/test/bar", nextDiff(t, diffs).(NodeCreated).Path)
+	})
+}
+
+func TestTreeCacheShouldKeepDataRejectedByReadValidators(t *testing.T) {
+	notEmpty := func(data []byte) error {
+		if len(data) == 0 {
+			return errors.New("empty")
+		}
+		return nil
+	}
+	s, err := session.NewSessionWithOpts(
+		session.WithZookeepers(strings.Split(test.GetZooKeepers(t), ",")),
+		session.WithSessionTimeout(200*time.Millisecond),
+		session.WithReadValidator("/test/*", notEmpty),
+	)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+	createNodes(t, s, "/test", "/test/foo")
+
+	tc := NewTreeCache(s, "/test")
+	diffs := tc.DiffStream("/test")
+	tc.Start()
+	defer tc.Close()
+	assert.Equal(t, "/test", nextDiff(t, diffs).(NodeCreated).Path)
+	assert.Equal(t, "/test/foo", nextDiff(t, diffs).(NodeCreated).Path)
+	assert.Equal(t, InitialSyncDone{}, nextDiff(t, diffs))
+
+	if _, err := s.Set("/test/foo", "", -1); err != nil {
+		t.Fatal("Set error: ", err)
+	}
+	rejected := nextDiff(t, diffs).(NodeRejected)
+	assert.Equal(t, "/test/foo", rejected.Path)
+	assert.True(t, errors.Is(rejected.Err, session.ErrInvalidData))
+	node, ok := tc.Get("/test/foo")
+	assert.True(t, ok)
+	assert.Equal(t, "/test/foo", node.Data)
+
+	if _, err := s.Set("/test/foo", "spam", -1); err != nil {
+		t.Fatal("Set error: ", err)
+	}
+	assert.Equal(t, "spam", nextDiff(t, diffs).(NodeUpdated).New.Data)
+}
+
+// heldWatchSession hands out data watches on path that only fire when the
+// test fires them, to stand in for a watch lost to a disconnection.
+type heldWatchSession struct {
+	session.Session
+	path    string
+	watches chan chan zookeeper.Event
+}
+
+func (h *heldWatchSession) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	data, stat, watch, err := h.Session.GetW(path)
+	if path != h.path || err != nil {
+		return data, stat, watch, err
+	}
+	held := make(chan zookeeper.Event, 1)
+	h.watches <- held
+	return data, stat, held, nil
+}
+
+func TestTreeCacheShouldMarkCatchUpDiffs(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/foo")
+
+		h := &heldWatchSession{Session: s, path: "/test/foo", watches: make(chan chan zookeeper.Event, 4)}
+		tc := NewTreeCache(h, "/test")
+		tc.Start()
+		defer tc.Close()
+
+		diffs := tc.DiffStream("/test")
+		assert.Equal(t, "/test", nextDiff(t, diffs).(NodeCreated).Path)
+		assert.Equal(t, "/test/foo", nextDiff(t, diffs).(NodeCreated).Path)
+		assert.Equal(t, InitialSyncDone{}, nextDiff(t, diffs))
+
+		// The change is missed until the watch fires for the session.
+		if _, err := s.Set("/test/foo", "spam", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		(<-h.watches) <- zookeeper.Event{Type: zookeeper.EVENT_SESSION}
+		updated := nextDiff(t, diffs).(NodeUpdated)
+		assert.Equal(t, "spam", updated.New.Data)
+		assert.True(t, updated.CatchUp)
+
+		createNodes(t, s, "/test/bar")
+		created := nextDiff(t, diffs).(NodeCreated)
+		assert.Equal(t, "/test/bar", created.Path)
+		assert.False(t, created.CatchUp)
+	})
+}
@@ -0,0 +1,162 @@
+// Package checkpoint stores consumer offsets in ZooKeeper.
+//
+// A checkpoint is a persistent node holding an offset as a decimal string.
+// A consumer acquires it by creating the ephemeral owner child, which keeps
+// other consumers from committing to it for as long as the owner's session
+// lives. Commits are compare-and-set on the node's version, so a commit based
+// on a stale read fails instead of moving the offset backwards.
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cleanup"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrOwned is returned by Acquire when another consumer owns the checkpoint.
+// It is a session.ErrAlreadyExists.
+var ErrOwned = session.NewError("checkpoint owned by another consumer", session.ErrAlreadyExists)
+
+// ErrNotOwner is returned by Commit once the checkpoint's owner marker no
+// longer belongs to the consumer, for example after its session expired. It
+// is a session.ErrNotOwner and a session.ErrRevoked.
+var ErrNotOwner = session.NewError("checkpoint no longer owned", session.ErrNotOwner, session.ErrRevoked)
+
+// ErrConflict is returned by Commit when the offset was changed since it was
+// last read.
+var ErrConflict = errors.New("checkpoint changed concurrently")
+
+// Option configures a Checkpoint, with the options every recipe shares from
+// recipeopts. Offsets stay decimal strings, so the codec isn't used; commits
+// are reported to the metrics and their failures logged.
+type Option = recipeopts.Option
+
+// Checkpoint is an offset owned by a single consumer.
+type Checkpoint struct {
+	session session.Session
+	path    string
+	id      string
+	o       recipeopts.Options
+
+	offset     int64
+	version    int
+	unregister func()
+}
+
+// Acquire takes ownership of the checkpoint at path for the consumer
+// identified by id, creating it with an offset of 0 if it doesn't exist. A
+// consumer acquiring a checkpoint it already owns through the same session
+// adopts its marker; a marker left by a previous session, such as before a
+// restart, is owned until that session times out. path is the default base
+// path, and Acquire fails without creating anything if opts are invalid.
+func Acquire(s session.Session, path, id string, opts ...Option) (*Checkpoint, error) {
+	o := recipeopts.Defaults(path)
+	if err := recipeopts.Apply(&o, opts...); err != nil {
+		return nil, err
+	}
+	if _, err := (managednode.Node{Path: o.BasePath, Data: "0", Parents: true, OnConflict: managednode.Adopt}).Ensure(s); err != nil {
+		return nil, err
+	}
+
+	c := &Checkpoint{session: s, path: o.BasePath, id: id, o: o}
+	_, err := managednode.Node{Path: c.owner(), Data: id, Flags: zookeeper.EPHEMERAL, OnConflict: managednode.AdoptIfOwner}.Ensure(s)
+	if zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		return nil, ErrOwned
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.unregister = cleanup.Register("checkpoint owner "+c.owner(), c.Release)
+
+	if err := c.Refresh(); err != nil {
+		_ = c.Release()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Checkpoint) owner() string { return path.Join(c.path, "owner") }
+
+// Offset returns the offset as of the last Refresh or Commit.
+func (c *Checkpoint) Offset() int64 {
+	return c.offset
+}
+
+// Refresh reads the stored offset.
+func (c *Checkpoint) Refresh() error {
+	data, stat, err := c.session.Get(c.path)
+	if err != nil {
+		return err
+	}
+	offset, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s holds %q: %w", c.path, data, err)
+	}
+	c.offset = offset
+	c.version = stat.Version()
+	return nil
+}
+
+// Commit stores offset, provided the consumer still owns the checkpoint and
+// nobody else changed it since it was last read.
+func (c *Checkpoint) Commit(offset int64) (err error) {
+	_, end := c.o.StartOperation(c.session, "checkpoint", "commit", c.path)
+	defer func() { end(err) }()
+	data, _, err := c.session.Get(c.owner())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) || (err == nil && data != c.id) {
+		return ErrNotOwner
+	}
+	if err != nil {
+		return err
+	}
+
+	stat, err := c.session.Set(c.path, strconv.FormatInt(offset, 10), c.version)
+	if zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	c.offset = offset
+	c.version = stat.Version()
+	return nil
+}
+
+// Advance is the fetch-process-commit cycle of a consumer: it calls process
+// with the current offset and commits the offset it returns. Nothing is
+// committed if process fails.
+func (c *Checkpoint) Advance(process func(offset int64) (next int64, err error)) error {
+	next, err := process(c.offset)
+	if err != nil {
+		return err
+	}
+	return c.Commit(next)
+}
+
+// Release gives up ownership, letting another consumer acquire the
+// checkpoint. The offset is kept.
+func (c *Checkpoint) Release() error {
+	c.unregister()
+	data, stat, err := c.session.Get(c.owner())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if data != c.id {
+		return nil
+	}
+	err = c.session.Delete(c.owner(), stat.Version())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package checkpoint
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireShouldRejectInvalidOptions(t *testing.T) {
+	_, err := Acquire(nil, "consumer", "a")
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestCheckpointShouldCommitOffsets(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		c, err := Acquire(s, "/test/consumer", "a")
+		if err != nil {
+			t.Fatal("Acquire error: ", err)
+		}
+		assert.Equal(t, int64(0), c.Offset())
+
+		err = c.Advance(func(offset int64) (int64, error) { return offset + 10, nil })
+		if err != nil {
+			t.Fatal("Advance error: ", err)
+		}
+		data, _, err := s.Get("/test/consumer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "10", data)
+
+		if _, err := s.Set("/test/consumer", "20", -1); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ErrConflict, c.Commit(15))
+		assert.NoError(t, c.Refresh())
+		assert.Equal(t, int64(20), c.Offset())
+		assert.NoError(t, c.Commit(25))
+	})
+}
+
+func TestCheckpointShouldHaveOneOwner(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		c, err := Acquire(s, "/test/consumer", "a")
+		if err != nil {
+			t.Fatal("Acquire error: ", err)
+		}
+
+		_, err = Acquire(s, "/test/consumer", "b")
+		assert.Equal(t, ErrOwned, err)
+
+		assert.NoError(t, c.Release())
+		assert.Equal(t, ErrNotOwner, c.Commit(1))
+
+		other, err := Acquire(s, "/test/consumer", "b")
+		if err != nil {
+			t.Fatal("Acquire error: ", err)
+		}
+		assert.NoError(t, other.Commit(1))
+	})
+}
@@ -0,0 +1,101 @@
+// Package cleanup releases ZooKeeper state owned by this process, such as held
+// locks and ephemeral recipe nodes, during graceful shutdown.
+//
+// Ephemeral nodes are normally only removed once the server notices the
+// session is gone, which takes a full session timeout after the process
+// exits. Recipes register their nodes here while they own them; calling Run,
+// or installing OnSignal, deletes them right away instead.
+package cleanup
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+)
+
+type release struct {
+	name string
+	fn   func() error
+}
+
+var (
+	mu       sync.Mutex
+	nextID   int
+	releases = map[int]release{}
+	handled  = map[os.Signal]bool{}
+)
+
+// Register adds fn to the functions run by Run. The returned function removes
+// it again, and should be called once the state fn releases is gone.
+func Register(name string, fn func() error) func() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	nextID++
+	id := nextID
+	releases[id] = release{name: name, fn: fn}
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(releases, id)
+	}
+}
+
+// Run calls every registered function, most recently registered first, and
+// removes them. All functions are run even if some fail; the first error is
+// returned.
+func Run() error {
+	mu.Lock()
+	ids := make([]int, 0, len(releases))
+	for id := range releases {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	pending := make([]release, 0, len(ids))
+	for _, id := range ids {
+		pending = append(pending, releases[id])
+		delete(releases, id)
+	}
+	mu.Unlock()
+
+	var first error
+	for _, r := range pending {
+		if err := r.fn(); err != nil && first == nil {
+			first = fmt.Errorf("releasing %s: %w", r.name, err)
+		}
+	}
+	return first
+}
+
+// OnSignal calls Run when the process receives any of signals. It is intended
+// for processes that don't otherwise handle these signals: after releasing,
+// the default behavior of the signal is restored and the signal is raised
+// again, so the process terminates as it would have without the handler.
+//
+// Installing a handler for a signal more than once has no effect.
+func OnSignal(signals ...os.Signal) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, sig := range signals {
+		if handled[sig] {
+			continue
+		}
+		handled[sig] = true
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, sig)
+		go func(c chan os.Signal) {
+			received := <-c
+			_ = Run()
+			signal.Stop(c)
+
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = p.Signal(received)
+			}
+		}(c)
+	}
+}
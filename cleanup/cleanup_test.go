@@ -0,0 +1,42 @@
+package cleanup
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShouldReleaseInReverseOrder(t *testing.T) {
+	var order []string
+	Register("first", func() error { order = append(order, "first"); return nil })
+	Register("second", func() error { order = append(order, "second"); return nil })
+
+	if err := Run(); err != nil {
+		t.Error("Run error: ", err)
+	}
+	assert.Equal(t, []string{"second", "first"}, order)
+
+	order = nil
+	Run()
+	assert.Empty(t, order)
+}
+
+func TestUnregisteredFunctionsShouldNotRun(t *testing.T) {
+	ran := false
+	unregister := Register("lock", func() error { ran = true; return nil })
+	unregister()
+
+	Run()
+	assert.False(t, ran)
+}
+
+func TestRunShouldReturnFirstError(t *testing.T) {
+	ran := false
+	Register("ok", func() error { ran = true; return nil })
+	Register("broken", func() error { return errors.New("broken") })
+
+	err := Run()
+	assert.EqualError(t, err, "releasing broken: broken")
+	assert.True(t, ran)
+}
@@ -0,0 +1,266 @@
+// Command zkbench generates read, write and watch load against a ZooKeeper
+// ensemble through the session package, and reports the latency percentiles
+// of each kind of operation. It is meant for validating client changes and
+// ensemble upgrades: run it before and after, or leave it running while
+// servers are restarted and compare the reports.
+//
+// Operations are picked at random by weight from a fixed set of keys under
+// the root:
+//
+//   - a read gets a key;
+//   - a write increments a key with RetryChange, so contention between
+//     clients is retried by the session;
+//   - a watch sets a data watch on a key, writes the key and waits for the
+//     watch to fire, measuring the time from the write to the notification.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+var (
+	servers        = flag.String("servers", "localhost:2181", "The comma separated list of ZooKeeper servers.")
+	root           = flag.String("root", "/zkbench", "The node under which the benchmark's keys are created.")
+	duration       = flag.Duration("duration", 30*time.Second, "How long to generate load for.")
+	clients        = flag.Int("clients", 8, "The number of concurrent clients sharing the session.")
+	keys           = flag.Int("keys", 100, "The number of keys operated on.")
+	valueSize      = flag.Int("value-size", 128, "The size in bytes of the values written.")
+	reads          = flag.Int("reads", 80, "The relative weight of reads.")
+	writes         = flag.Int("writes", 15, "The relative weight of writes.")
+	watches        = flag.Int("watches", 5, "The relative weight of watches.")
+	watchTimeout   = flag.Duration("watch-timeout", 5*time.Second, "How long to wait for a watch to fire.")
+	maxInflight    = flag.Int("max-inflight", 0, "The most operations outstanding at once; 0 is unlimited.")
+	sessionTimeout = flag.Duration("session-timeout", 10*time.Second, "The session timeout.")
+	interval       = flag.Duration("interval", 10*time.Second, "How often to print intermediate reports; 0 prints only the final one.")
+	cleanupKeys    = flag.Bool("cleanup", false, "Delete the root and the keys under it when done.")
+)
+
+type op int
+
+const (
+	read op = iota
+	write
+	watch
+)
+
+var opNames = []string{"read", "write", "watch"}
+
+type bench struct {
+	session session.Session
+	padding string
+	results []*recorder
+	events  int64
+}
+
+func main() {
+	flag.Parse()
+	if *reads < 0 || *writes < 0 || *watches < 0 || *reads+*writes+*watches == 0 {
+		log.Fatalf("The operation weights must not be negative and not all zero.")
+	}
+	if *keys <= 0 || *clients <= 0 {
+		log.Fatalf("The number of keys and clients must be positive.")
+	}
+
+	sess, err := session.NewSessionWithOpts(
+		session.WithZookeepers(strings.Split(*servers, ",")),
+		session.WithSessionTimeout(*sessionTimeout),
+		session.WithMaxInflight(*maxInflight),
+		session.WithName("zkbench"),
+		session.WithLogger(log.Default()),
+	)
+	if err != nil {
+		log.Fatalf("Couldn't establish a session with a ZooKeeper server. %s", err)
+	}
+	defer sess.Close()
+
+	b := &bench{session: sess, padding: strings.Repeat("x", *valueSize)}
+	for range opNames {
+		b.results = append(b.results, &recorder{})
+	}
+
+	events := make(chan session.ZKSessionEvent, 16)
+	sess.Subscribe(events)
+	go func() {
+		for event := range events {
+			atomic.AddInt64(&b.events, 1)
+			log.Printf("Session event: %v", event)
+		}
+	}()
+
+	if err := b.setup(); err != nil {
+		log.Fatalf("Couldn't create the keys. %s", err)
+	}
+	log.Printf("Running %d clients against %d keys for %v.", *clients, *keys, *duration)
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("Signalled. Stopping early. Signal: %v", sig)
+		case <-time.After(*duration):
+		}
+		close(stop)
+	}()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < *clients; i++ {
+		wg.Add(1)
+		seed := start.UnixNano() + int64(i)
+		go func() {
+			defer wg.Done()
+			b.run(rand.New(rand.NewSource(seed)), stop)
+		}()
+	}
+
+	if *interval > 0 {
+		go func() {
+			ticker := time.NewTicker(*interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					b.report(time.Since(start))
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	b.report(time.Since(start))
+
+	if *cleanupKeys {
+		if err := sess.DeleteRecursive(*root); err != nil {
+			log.Printf("Couldn't delete %s. %s", *root, err)
+		}
+	}
+}
+
+func (b *bench) key(i int) string {
+	return path.Join(*root, "key-"+strconv.Itoa(i))
+}
+
+// setup creates the keys, keeping any left from a previous run.
+func (b *bench) setup() error {
+	for i := 0; i < *keys; i++ {
+		node := managednode.Node{Path: b.key(i), Data: b.value(0), Parents: true, OnConflict: managednode.Adopt}
+		if _, err := node.Ensure(b.session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value encodes n padded to the configured value size.
+func (b *bench) value(n int) string {
+	v := strconv.Itoa(n) + ":"
+	if len(v) < len(b.padding) {
+		v += b.padding[len(v):]
+	}
+	return v
+}
+
+// counter decodes a value written by value.
+func counter(value string) int {
+	n, _ := strconv.Atoi(strings.SplitN(value, ":", 2)[0])
+	return n
+}
+
+// pick chooses an operation by the configured weights.
+func pick(r *rand.Rand) op {
+	n := r.Intn(*reads + *writes + *watches)
+	switch {
+	case n < *reads:
+		return read
+	case n < *reads+*writes:
+		return write
+	default:
+		return watch
+	}
+}
+
+func (b *bench) run(r *rand.Rand, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		o := pick(r)
+		key := b.key(r.Intn(*keys))
+		var latency time.Duration
+		var err error
+		switch o {
+		case read:
+			started := time.Now()
+			_, _, err = b.session.Get(key)
+			latency = time.Since(started)
+		case write:
+			started := time.Now()
+			err = b.increment(key)
+			latency = time.Since(started)
+		case watch:
+			latency, err = b.watch(key)
+		}
+		b.results[o].record(latency, err)
+	}
+}
+
+func (b *bench) increment(key string) error {
+	return b.session.RetryChange(key, 0, zookeeper.WorldACL(zookeeper.PERM_ALL), func(old string, _ *zookeeper.Stat) (string, error) {
+		return b.value(counter(old) + 1), nil
+	})
+}
+
+// watch writes key under a data watch, returning the time the watch took to
+// fire after the write.
+func (b *bench) watch(key string) (time.Duration, error) {
+	_, _, events, err := b.session.GetW(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := b.increment(key); err != nil {
+		return 0, err
+	}
+	written := time.Now()
+
+	select {
+	case event := <-events:
+		if event.Type != zookeeper.EVENT_CHANGED {
+			return 0, fmt.Errorf("watch on %s fired with %v", key, event)
+		}
+		return time.Since(written), nil
+	case <-time.After(*watchTimeout):
+		return 0, fmt.Errorf("watch on %s didn't fire within %v", key, *watchTimeout)
+	}
+}
+
+func (b *bench) report(elapsed time.Duration) {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("After %v, %d session events:", elapsed.Round(time.Millisecond), atomic.LoadInt64(&b.events)))
+	for o, r := range b.results {
+		lines = append(lines, fmt.Sprintf("  %-5s %s", opNames[o], r.summarize().String(elapsed)))
+	}
+	log.Print(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recorder collects the latencies and errors of one kind of operation.
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func (r *recorder) record(latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+}
+
+// summary describes the recorded operations.
+type summary struct {
+	count, errors      int
+	p50, p90, p99, max time.Duration
+}
+
+func (r *recorder) summarize() summary {
+	r.mu.Lock()
+	latencies := append([]time.Duration(nil), r.latencies...)
+	errors := r.errors
+	r.mu.Unlock()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s := summary{count: len(latencies), errors: errors}
+	if len(latencies) > 0 {
+		s.p50 = percentile(latencies, 50)
+		s.p90 = percentile(latencies, 90)
+		s.p99 = percentile(latencies, 99)
+		s.max = latencies[len(latencies)-1]
+	}
+	return s
+}
+
+// percentile returns the p-th percentile of sorted, by the nearest-rank
+// method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (s summary) String(elapsed time.Duration) string {
+	rate := float64(s.count) / elapsed.Seconds()
+	return fmt.Sprintf("%8d ok %6d err %9.1f/s  p50 %-10v p90 %-10v p99 %-10v max %v",
+		s.count, s.errors, rate, s.p50, s.p90, s.p99, s.max)
+}
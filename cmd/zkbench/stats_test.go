@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentileShouldUseNearestRank(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 5*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 9*time.Millisecond, percentile(sorted, 90))
+	assert.Equal(t, 10*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, time.Millisecond, percentile(sorted, 0))
+}
+
+func TestSummarizeShouldCountErrorsSeparately(t *testing.T) {
+	r := &recorder{}
+	r.record(3*time.Millisecond, nil)
+	r.record(time.Millisecond, nil)
+	r.record(time.Second, errors.New("timeout"))
+
+	s := r.summarize()
+	assert.Equal(t, 2, s.count)
+	assert.Equal(t, 1, s.errors)
+	assert.Equal(t, 3*time.Millisecond, s.max)
+	assert.Equal(t, time.Millisecond, s.p50)
+}
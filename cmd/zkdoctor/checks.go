@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// serverResult turns the diagnosis of a server into the result of its
+// connectivity check. A server that is reachable but doesn't answer the four
+// letter words is only a warning, as they may just not be whitelisted.
+func serverResult(d session.ServerDiagnosis) result {
+	res := result{Check: "connectivity", Target: d.Server, Duration: d.Latency, Detail: d.String()}
+	switch {
+	case !d.Reachable:
+		res.Status = fail
+	case !d.Ruok || d.Mode == "":
+		res.Status = warn
+		res.Detail += " (are ruok and srvr in 4lw.commands.whitelist?)"
+	default:
+		res.Status = pass
+	}
+	return res
+}
+
+// latencyResult summarizes the round trips of the latency check. Round trips
+// over a third of the session timeout put the session at risk, as the client
+// must hear from the server within that time.
+func latencyResult(samples []time.Duration, sessionTimeout time.Duration) result {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	min, median, max := sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1]
+	res := result{
+		Check:    "latency",
+		Status:   pass,
+		Duration: total,
+		Detail:   fmt.Sprintf("%d round trips: min %s, median %s, max %s", len(sorted), min.Round(time.Microsecond), median.Round(time.Microsecond), max.Round(time.Microsecond)),
+	}
+	if max > sessionTimeout/3 {
+		res.Status = warn
+		res.Detail += fmt.Sprintf(", over a third of the session timeout %s", sessionTimeout)
+	}
+	return res
+}
+
+type doctor struct {
+	session        *session.ZKSession
+	scratch        string
+	sessionTimeout time.Duration
+	watchTimeout   time.Duration
+}
+
+// timed runs check and records how long it took.
+func timed(name string, check func() (status, string)) result {
+	start := time.Now()
+	st, detail := check()
+	return result{Check: name, Status: st, Duration: time.Since(start), Detail: detail}
+}
+
+func failed(err error) (status, string) {
+	return fail, err.Error()
+}
+
+// ensureScratch creates the scratch node the checks create their nodes under.
+func (d *doctor) ensureScratch() error {
+	_, err := (managednode.Node{Path: d.scratch, Parents: true, OnConflict: managednode.Adopt}).Ensure(d.session)
+	return err
+}
+
+// scratchNode creates an ephemeral node under the scratch node.
+func (d *doctor) scratchNode(name string) (string, error) {
+	prefix := path.Join(d.scratch, fmt.Sprintf("%s-%d-", name, os.Getpid()))
+	return d.session.Create(prefix, "zkdoctor", zookeeper.EPHEMERAL|zookeeper.SEQUENCE, zookeeper.WorldACL(zookeeper.PERM_ALL))
+}
+
+func (d *doctor) latency(samples int) result {
+	durations := make([]time.Duration, 0, samples)
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := d.session.Exists("/"); err != nil {
+			return result{Check: "latency", Status: fail, Detail: err.Error()}
+		}
+		durations = append(durations, time.Since(start))
+	}
+	return latencyResult(durations, d.sessionTimeout)
+}
+
+// roundTrip creates, reads, updates and deletes a node.
+func (d *doctor) roundTrip() (status, string) {
+	node, err := d.scratchNode("roundtrip")
+	if err != nil {
+		return failed(fmt.Errorf("creating: %w", err))
+	}
+	data, stat, err := d.session.Get(node)
+	if err != nil {
+		return failed(fmt.Errorf("reading %s: %w", node, err))
+	}
+	if data != "zkdoctor" {
+		return fail, fmt.Sprintf("read %q back from %s, wrote %q", data, node, "zkdoctor")
+	}
+	if _, err := d.session.Set(node, "updated", stat.Version()); err != nil {
+		return failed(fmt.Errorf("updating %s: %w", node, err))
+	}
+	if err := d.session.Delete(node, -1); err != nil {
+		return failed(fmt.Errorf("deleting %s: %w", node, err))
+	}
+	return pass, "created, read, updated and deleted " + node
+}
+
+// watch checks that a data watch fires when its node changes.
+func (d *doctor) watch() (status, string) {
+	node, err := d.scratchNode("watch")
+	if err != nil {
+		return failed(fmt.Errorf("creating: %w", err))
+	}
+	defer d.session.Delete(node, -1)
+
+	_, _, w, err := d.session.GetW(node)
+	if err != nil {
+		return failed(fmt.Errorf("watching %s: %w", node, err))
+	}
+	written := time.Now()
+	if _, err := d.session.Set(node, "changed", -1); err != nil {
+		return failed(fmt.Errorf("updating %s: %w", node, err))
+	}
+	select {
+	case event := <-w:
+		if event.Type != zookeeper.EVENT_CHANGED {
+			return fail, fmt.Sprintf("watch on %s fired with %v, expected a data change", node, event)
+		}
+		return pass, fmt.Sprintf("watch fired %s after the write", time.Since(written).Round(time.Microsecond))
+	case <-time.After(d.watchTimeout):
+		return fail, fmt.Sprintf("watch on %s didn't fire within %s", node, d.watchTimeout)
+	}
+}
+
+// expiry expires the session and checks that the client recovers as it
+// should: a new session is established, subscribers hear about it, the epoch
+// advances and the old session's ephemeral nodes are gone.
+func (d *doctor) expiry() (status, string) {
+	node, err := d.scratchNode("expiry")
+	if err != nil {
+		return failed(fmt.Errorf("creating: %w", err))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.sessionTimeout)
+	defer cancel()
+	events := d.session.Events(ctx)
+	epoch := d.session.Epoch()
+
+	expired := make(chan error, 1)
+	go func() { expired <- d.session.SimulateExpiry() }()
+	for seen := false; !seen; {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return fail, "the session terminated instead of recovering"
+			}
+			seen = event == session.SessionExpiredReconnected
+		case err := <-expired:
+			if err != nil {
+				return failed(fmt.Errorf("expiring: %w", err))
+			}
+			expired = nil
+		case <-ctx.Done():
+			return fail, fmt.Sprintf("SessionExpiredReconnected wasn't delivered within %s", d.sessionTimeout)
+		}
+	}
+	if expired != nil {
+		if err := <-expired; err != nil {
+			return failed(fmt.Errorf("expiring: %w", err))
+		}
+	}
+	if d.session.Epoch() == epoch {
+		return fail, "the session epoch didn't advance"
+	}
+	stat, err := d.session.Exists(node)
+	if err != nil {
+		return failed(fmt.Errorf("checking %s: %w", node, err))
+	}
+	if stat != nil {
+		return fail, fmt.Sprintf("ephemeral node %s survived the expiry", node)
+	}
+	return pass, fmt.Sprintf("new session established at epoch %d, ephemeral nodes removed", d.session.Epoch())
+}
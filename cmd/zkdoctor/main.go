@@ -0,0 +1,146 @@
+// Command zkdoctor runs a battery of checks against a ZooKeeper ensemble
+// through the session package and prints a report, as a standard first step
+// when diagnosing an application's trouble with ZooKeeper:
+//
+//   - connectivity: every server accepts connections, answers ruok and
+//     reports its mode with srvr;
+//   - session: a session can be established with the servers;
+//   - auth: with -auth, the credentials are accepted and allow reading;
+//   - latency: the round trips of a handful of cheap reads, warning when they
+//     come close to the session timeout;
+//   - roundtrip: a node can be created, read, updated and deleted under the
+//     scratch path;
+//   - watch: a data watch fires when its node changes;
+//   - expiry: after the session expires, the client establishes a new
+//     session, reports it and the old session's ephemeral nodes are gone.
+//
+// Every node is created under -scratch, which is deleted afterwards if
+// nothing else is in it. The exit status is 1 if any check failed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+var (
+	servers        = flag.String("servers", "localhost:2181", "The comma separated list of ZooKeeper servers.")
+	sessionTimeout = flag.Duration("session-timeout", 10*time.Second, "The session timeout.")
+	connectTimeout = flag.Duration("connect-timeout", session.DefaultConnectTimeout, "How long to wait for the session to be established.")
+	probeTimeout   = flag.Duration("probe-timeout", session.DefaultProbeTimeout, "How long to wait for the servers to answer the connectivity checks.")
+	auth           = flag.String("auth", "", "Credentials to check, as scheme:credentials, such as digest:user:password.")
+	scratch        = flag.String("scratch", "/zkdoctor", "The node under which the checks create their nodes.")
+	samples        = flag.Int("samples", 20, "The number of round trips measured by the latency check.")
+	watchTimeout   = flag.Duration("watch-timeout", 5*time.Second, "How long to wait for a watch to fire.")
+	expiry         = flag.Bool("expiry", true, "Check the recovery from a session expiry, by expiring the check's own session.")
+	asJSON         = flag.Bool("json", false, "Print the report as JSON rather than text.")
+	verbose        = flag.Bool("v", false, "Log the session's messages.")
+)
+
+func main() {
+	flag.Parse()
+	if *samples <= 0 {
+		log.Fatalf("The number of latency samples must be positive.")
+	}
+
+	rep := run()
+	write := rep.writeText
+	if *asJSON {
+		write = rep.writeJSON
+	}
+	if err := write(os.Stdout); err != nil {
+		log.Fatalf("Couldn't write the report. %s", err)
+	}
+	if rep.failed() {
+		os.Exit(1)
+	}
+}
+
+// later are the checks skipped when no session can be established.
+var later = []string{"auth", "latency", "roundtrip", "watch", "expiry"}
+
+func run() report {
+	rep := report{Servers: *servers, Started: time.Now()}
+	hosts := strings.Split(*servers, ",")
+
+	var addresses []string
+	for _, host := range hosts {
+		addresses = append(addresses, strings.TrimSuffix(host, ":observer"))
+	}
+	for _, d := range session.DiagnoseServers(addresses, *probeTimeout) {
+		rep.add(serverResult(d))
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if *verbose {
+		logger = log.Default()
+	}
+	opts := []session.SessionOpt{
+		session.WithZookeepers(hosts),
+		session.WithSessionTimeout(*sessionTimeout),
+		session.WithConnectTimeout(*connectTimeout),
+		session.WithName("zkdoctor"),
+		session.WithLogger(logger),
+	}
+	var scheme string
+	if *auth != "" {
+		var credentials string
+		var ok bool
+		scheme, credentials, ok = strings.Cut(*auth, ":")
+		if !ok {
+			log.Fatalf("The -auth credentials must be given as scheme:credentials.")
+		}
+		opts = append(opts, session.WithAuth(scheme, credentials))
+	}
+	if *expiry {
+		opts = append(opts, session.WithExpirySimulation())
+	}
+
+	start := time.Now()
+	sess, err := session.NewSessionWithOpts(opts...)
+	if err != nil {
+		rep.add(result{Check: "session", Status: fail, Duration: time.Since(start), Detail: err.Error()})
+		for _, check := range later {
+			rep.add(result{Check: check, Status: skip, Detail: "no session"})
+		}
+		return rep
+	}
+	defer sess.Close()
+	rep.add(result{Check: "session", Status: pass, Duration: time.Since(start), Detail: "connected to " + sess.CurrentServer()})
+
+	d := &doctor{session: sess, scratch: *scratch, sessionTimeout: *sessionTimeout, watchTimeout: *watchTimeout}
+	if *auth == "" {
+		rep.add(result{Check: "auth", Status: skip, Detail: "no -auth given"})
+	} else {
+		rep.add(timed("auth", func() (status, string) {
+			if _, _, err := sess.Children("/"); err != nil {
+				return failed(fmt.Errorf("reading / with the credentials: %w", err))
+			}
+			return pass, "authenticated with the " + scheme + " scheme"
+		}))
+	}
+	rep.add(d.latency(*samples))
+
+	if err := d.ensureScratch(); err != nil {
+		for _, check := range later[2:] {
+			rep.add(result{Check: check, Status: fail, Detail: fmt.Sprintf("creating the scratch node %s: %s", *scratch, err)})
+		}
+		return rep
+	}
+	defer sess.Delete(*scratch, -1)
+	rep.add(timed("roundtrip", d.roundTrip))
+	rep.add(timed("watch", d.watch))
+	if *expiry {
+		rep.add(timed("expiry", d.expiry))
+	} else {
+		rep.add(result{Check: "expiry", Status: skip, Detail: "-expiry=false"})
+	}
+	return rep
+}
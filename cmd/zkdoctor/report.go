@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+type status string
+
+const (
+	pass status = "pass"
+	warn status = "warn"
+	fail status = "fail"
+	skip status = "skip"
+)
+
+// result is the outcome of one check, against one server for the checks run
+// per server.
+type result struct {
+	Check    string        `json:"check"`
+	Target   string        `json:"target,omitempty"`
+	Status   status        `json:"status"`
+	Duration time.Duration `json:"duration_ns"`
+	Detail   string        `json:"detail,omitempty"`
+}
+
+type report struct {
+	Servers string    `json:"servers"`
+	Started time.Time `json:"started"`
+	Results []result  `json:"results"`
+}
+
+func (r *report) add(res result) {
+	r.Results = append(r.Results, res)
+}
+
+// failed reports whether any check failed.
+func (r report) failed() bool {
+	for _, res := range r.Results {
+		if res.Status == fail {
+			return true
+		}
+	}
+	return false
+}
+
+// summary counts the results by status.
+func (r report) summary() string {
+	counts := map[status]int{}
+	for _, res := range r.Results {
+		counts[res.Status]++
+	}
+	return fmt.Sprintf("%d passed, %d warnings, %d failed, %d skipped", counts[pass], counts[warn], counts[fail], counts[skip])
+}
+
+func (r report) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "zkdoctor report for %s at %s\n\n", r.Servers, r.Started.UTC().Format(time.RFC3339))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCHECK\tTARGET\tTIME\tDETAIL")
+	for _, res := range r.Results {
+		target := res.Target
+		if target == "" {
+			target = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", res.Status, res.Check, target, res.Duration.Round(time.Microsecond), res.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", r.summary())
+	return err
+}
+
+func (r report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
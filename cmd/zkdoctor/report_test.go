@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerResultShouldGradeDiagnoses(t *testing.T) {
+	assert.Equal(t, fail, serverResult(session.ServerDiagnosis{Server: "zk1:2181", Err: errors.New("refused")}).Status)
+	assert.Equal(t, warn, serverResult(session.ServerDiagnosis{Server: "zk1:2181", Reachable: true}).Status)
+	assert.Equal(t, pass, serverResult(session.ServerDiagnosis{Server: "zk1:2181", Reachable: true, Ruok: true, Mode: "follower"}).Status)
+}
+
+func TestLatencyResultShouldWarnNearSessionTimeout(t *testing.T) {
+	samples := []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond}
+	res := latencyResult(samples, 10*time.Second)
+	assert.Equal(t, pass, res.Status)
+	assert.Equal(t, "3 round trips: min 1ms, median 2ms, max 3ms", res.Detail)
+	assert.Equal(t, 6*time.Millisecond, res.Duration)
+
+	res = latencyResult(append(samples, 4*time.Second), 10*time.Second)
+	assert.Equal(t, warn, res.Status)
+}
+
+func TestReportShouldSummarize(t *testing.T) {
+	rep := report{Servers: "zk1:2181", Started: time.Unix(0, 0)}
+	rep.add(result{Check: "connectivity", Target: "zk1:2181", Status: pass})
+	rep.add(result{Check: "auth", Status: skip, Detail: "no -auth given"})
+	assert.False(t, rep.failed())
+	rep.add(result{Check: "watch", Status: fail, Detail: "watch didn't fire"})
+	assert.True(t, rep.failed())
+
+	var out bytes.Buffer
+	assert.NoError(t, rep.writeText(&out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, "zkdoctor report for zk1:2181 at 1970-01-01T00:00:00Z", lines[0])
+	assert.Contains(t, lines[3], "pass    connectivity  zk1:2181")
+	assert.Equal(t, "1 passed, 0 warnings, 1 failed, 1 skipped", lines[len(lines)-1])
+}
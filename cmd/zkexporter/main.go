@@ -0,0 +1,129 @@
+// Command zkexporter exports gauges describing ZooKeeper trees for Prometheus
+// to scrape, so dashboards can be built on coordination state without
+// writing code.
+//
+// Each configured path is followed with a cache.TreeCache, and scrapes are
+// served from the caches without reading from ZooKeeper. For every node down
+// to the configured depth it exports:
+//
+//   - zk_node_children, the number of children;
+//   - zk_node_value, the node's data, for nodes holding a number;
+//   - zk_node_version and zk_node_size_bytes, from the node's Stat;
+//   - zk_node_age_seconds, the time since the node was last modified, to
+//     spot stale heartbeats and configuration.
+//
+// Every series is labelled with the configured root and the node's path.
+// zk_exporter_root_exists, zk_exporter_synced and
+// zk_exporter_session_connected describe the exporter itself.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+var (
+	servers        = flag.String("servers", "localhost:2181", "The comma separated list of ZooKeeper servers.")
+	paths          = flag.String("paths", "/", "The comma separated list of paths to export.")
+	depth          = flag.Int("depth", 1, "How many levels below each path to export; -1 exports the whole tree.")
+	listen         = flag.String("listen", ":9141", "The address to serve metrics on.")
+	sessionTimeout = flag.Duration("session-timeout", 10*time.Second, "The session timeout.")
+)
+
+type exporter struct {
+	roots     []string
+	caches    []*cache.TreeCache
+	connected int32
+}
+
+func main() {
+	flag.Parse()
+
+	sess, err := session.NewSessionWithOpts(
+		session.WithZookeepers(strings.Split(*servers, ",")),
+		session.WithSessionTimeout(*sessionTimeout),
+		session.WithName("zkexporter"),
+		session.WithLogger(log.Default()),
+	)
+	if err != nil {
+		log.Fatalf("Couldn't establish a session with a ZooKeeper server. %s", err)
+	}
+	defer sess.Close()
+
+	e := &exporter{connected: 1}
+	events := make(chan session.ZKSessionEvent, 16)
+	sess.Subscribe(events)
+	go e.follow(events)
+
+	for _, root := range strings.Split(*paths, ",") {
+		tc := cache.NewTreeCache(sess, root)
+		tc.Start()
+		defer tc.Close()
+		e.roots = append(e.roots, root)
+		e.caches = append(e.caches, tc)
+	}
+
+	http.Handle("/metrics", e)
+	server := &http.Server{Addr: *listen}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Couldn't serve metrics. %s", err)
+		}
+	}()
+	log.Printf("Exporting %s on %s.", strings.Join(e.roots, ", "), *listen)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	log.Printf("Signalled. Shutting down. Signal: %v", sig)
+	server.Close()
+}
+
+// follow tracks whether the session is connected.
+func (e *exporter) follow(events <-chan session.ZKSessionEvent) {
+	for event := range events {
+		log.Printf("Session event: %v", event)
+		switch event {
+		case session.SessionReconnected, session.SessionExpiredReconnected:
+			atomic.StoreInt32(&e.connected, 1)
+		default:
+			atomic.StoreInt32(&e.connected, 0)
+		}
+	}
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := newCollector()
+	c.add("zk_exporter_session_connected", "Whether the exporter's session is connected.", float64(atomic.LoadInt32(&e.connected)))
+	now := time.Now()
+	for i, tc := range e.caches {
+		root := e.roots[i]
+		synced := false
+		select {
+		case <-tc.InitialSyncDone():
+			synced = true
+		default:
+		}
+		c.add("zk_exporter_synced", "Whether the cache of the root has finished its initial sync.", boolValue(synced), [2]string{"root", root})
+		c.collectTree(tc, root, *depth, now)
+	}
+
+	var buf bytes.Buffer
+	if err := c.write(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+)
+
+// tree is the part of cache.TreeCache the metrics are read from.
+type tree interface {
+	Get(path string) (cache.Node, bool)
+	Children(path string) []string
+}
+
+// sample is one value of a metric.
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+// family is a metric with its samples, written in the Prometheus text format.
+type family struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+type collector struct {
+	families map[string]*family
+	order    []string
+}
+
+func newCollector() *collector {
+	return &collector{families: make(map[string]*family)}
+}
+
+func (c *collector) add(name, help string, value float64, labels ...[2]string) {
+	f, ok := c.families[name]
+	if !ok {
+		f = &family{name: name, help: help}
+		c.families[name] = f
+		c.order = append(c.order, name)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// collectTree adds the metrics of root and its descendants down to depth
+// levels below it, a negative depth meaning the whole tree.
+func (c *collector) collectTree(t tree, root string, depth int, now time.Time) {
+	node, ok := t.Get(root)
+	c.add("zk_exporter_root_exists", "Whether the configured root exists.", boolValue(ok), [2]string{"root", root})
+	if ok {
+		c.collectNode(t, root, node, depth, now)
+	}
+}
+
+func (c *collector) collectNode(t tree, root string, node cache.Node, depth int, now time.Time) {
+	labels := [][2]string{{"root", root}, {"path", node.Path}}
+	children := t.Children(node.Path)
+
+	c.add("zk_node_children", "The number of children of the node.", float64(len(children)), labels...)
+	if value, ok := numeric(node.Data); ok {
+		c.add("zk_node_value", "The node's data, for nodes holding a number.", value, labels...)
+	}
+	if node.Stat != nil {
+		c.add("zk_node_version", "The version of the node's data.", float64(node.Stat.Version()), labels...)
+		c.add("zk_node_size_bytes", "The size of the node's data.", float64(node.Stat.DataLength()), labels...)
+		c.add("zk_node_age_seconds", "The time since the node's data was last modified.", now.Sub(node.Stat.MTime()).Seconds(), labels...)
+	}
+
+	if depth == 0 {
+		return
+	}
+	for _, child := range children {
+		childPath := strings.TrimSuffix(node.Path, "/") + "/" + child
+		if childNode, ok := t.Get(childPath); ok {
+			c.collectNode(t, root, childNode, depth-1, now)
+		}
+	}
+}
+
+// numeric parses data holding a single number, ignoring surrounding space.
+func numeric(data string) (float64, bool) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(data), 64)
+	return value, err == nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// write writes the collected metrics in the Prometheus text format.
+func (c *collector) write(w io.Writer) error {
+	for _, name := range c.order {
+		f := c.families[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name); err != nil {
+			return err
+		}
+		sort.SliceStable(f.samples, func(i, j int) bool {
+			return formatLabels(f.samples[i].labels) < formatLabels(f.samples[j].labels)
+		})
+		for _, s := range f.samples {
+			value := strconv.FormatFloat(s.value, 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(s.labels), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, label[0]+`="`+escapeLabel(label[1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
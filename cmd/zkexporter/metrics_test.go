@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTree map[string][]string
+
+func (t fakeTree) Get(path string) (cache.Node, bool) {
+	if _, ok := t[path]; !ok {
+		return cache.Node{}, false
+	}
+	data := map[string]string{"/jobs/a": "3", "/jobs/b": " 2.5\n", "/jobs/a/x": "7"}[path]
+	return cache.Node{Path: path, Data: data}, true
+}
+
+func (t fakeTree) Children(path string) []string {
+	return t[path]
+}
+
+func TestCollectorShouldExportNodesDownToDepth(t *testing.T) {
+	tree := fakeTree{"/jobs": {"a", "b"}, "/jobs/a": {"x"}, "/jobs/b": nil, "/jobs/a/x": nil}
+
+	c := newCollector()
+	c.collectTree(tree, "/jobs", 1, time.Now())
+	c.collectTree(tree, "/missing", 1, time.Now())
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.write(&buf))
+	assert.Equal(t, `# HELP zk_exporter_root_exists Whether the configured root exists.
+# TYPE zk_exporter_root_exists gauge
+zk_exporter_root_exists{root="/jobs"} 1
+zk_exporter_root_exists{root="/missing"} 0
+# HELP zk_node_children The number of children of the node.
+# TYPE zk_node_children gauge
+zk_node_children{root="/jobs",path="/jobs"} 2
+zk_node_children{root="/jobs",path="/jobs/a"} 1
+zk_node_children{root="/jobs",path="/jobs/b"} 0
+# HELP zk_node_value The node's data, for nodes holding a number.
+# TYPE zk_node_value gauge
+zk_node_value{root="/jobs",path="/jobs/a"} 3
+zk_node_value{root="/jobs",path="/jobs/b"} 2.5
+`, buf.String())
+}
+
+func TestFormatLabelsShouldEscapeValues(t *testing.T) {
+	assert.Equal(t, `{path="/a\"b\\c\nd"}`, formatLabels([][2]string{{"path", "/a\"b\\c\nd"}}))
+	assert.Equal(t, "", formatLabels(nil))
+}
@@ -0,0 +1,89 @@
+// Command zktimeline prints the timelines written by sessions configured with
+// session.WithTimeline, for reconstructing the exact order of events around an
+// incident.
+//
+// Each argument is the path given to WithTimeline; its rotated backups are
+// read too, oldest first. The entries of a single timeline are printed in the
+// order the session wrote them. Given several timelines, as from different
+// processes, entries are interleaved by time, which is only as accurate as
+// the hosts' clocks.
+//
+// Times are printed relative to the first entry shown, then absolute:
+//
+//	+0.000s 2026-10-14T10:00:00.000Z orders#3 event     SessionDisconnected
+//	+0.512s 2026-10-14T10:00:00.512Z orders#3 operation lock[7] acquire: waiting
+//	+1.204s 2026-10-14T10:00:01.204Z orders#3 event     SessionReconnected
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	kinds     = flag.String("kind", "", "The comma separated kinds of entries to show: event, state, redial or operation. Empty shows all.")
+	sessions  = flag.String("session", "", "Only show entries of the session with this name.")
+	operation = flag.String("operation", "", "Only show the entries of the operation with this ID.")
+	since     = flag.String("since", "", "Only show entries from this RFC 3339 time.")
+	until     = flag.String("until", "", "Only show entries before this RFC 3339 time.")
+	raw       = flag.Bool("json", false, "Print the entries as JSON lines rather than text.")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] timeline...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f := filter{session: *sessions, operation: *operation}
+	if *kinds != "" {
+		f.kinds = strings.Split(*kinds, ",")
+	}
+	var err error
+	if f.since, err = parseTime(*since); err != nil {
+		log.Fatalf("Invalid -since. %s", err)
+	}
+	if f.until, err = parseTime(*until); err != nil {
+		log.Fatalf("Invalid -until. %s", err)
+	}
+
+	var timelines [][]entry
+	for _, path := range flag.Args() {
+		entries, err := readTimeline(path)
+		if err != nil {
+			log.Fatalf("Couldn't read the timeline %s. %s", path, err)
+		}
+		timelines = append(timelines, entries)
+	}
+
+	var first time.Time
+	for _, e := range merge(timelines) {
+		if !f.matches(e.TimelineEntry) {
+			continue
+		}
+		if *raw {
+			fmt.Println(string(e.line))
+			continue
+		}
+		if first.IsZero() {
+			first = e.Time
+		}
+		fmt.Println(format(e.TimelineEntry, first))
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// entry is a timeline entry along with the line it was decoded from.
+type entry struct {
+	session.TimelineEntry
+	line []byte
+}
+
+// files returns the files of the timeline at path, oldest first: its backups
+// path.N down to path.1, then path itself.
+func files(path string) []string {
+	var backups []string
+	for i := 1; ; i++ {
+		backup := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		backups = append([]string{backup}, backups...)
+	}
+	return append(backups, path)
+}
+
+// readTimeline reads the entries of the timeline at path and its backups.
+func readTimeline(path string) ([]entry, error) {
+	var entries []entry
+	for _, file := range files(path) {
+		read, err := readFile(file)
+		if err != nil && !(errors.Is(err, os.ErrNotExist) && file == path) {
+			return nil, err
+		}
+		entries = append(entries, read...)
+	}
+	return entries, nil
+}
+
+func readFile(file string) ([]entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(bufio.NewScanner(f), file)
+}
+
+// parse decodes the lines of scanner. A truncated last line, as left by a
+// process killed while writing, is skipped.
+func parse(scanner *bufio.Scanner, file string) ([]entry, error) {
+	var entries []entry
+	var bad error
+	for n := 1; scanner.Scan(); n++ {
+		if bad != nil {
+			return nil, bad
+		}
+		line := append([]byte(nil), scanner.Bytes()...)
+		if len(line) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e.TimelineEntry); err != nil {
+			bad = fmt.Errorf("%s:%d: %w", file, n, err)
+			continue
+		}
+		e.line = line
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// merge interleaves timelines by time, keeping the order of the entries of
+// each one.
+func merge(timelines [][]entry) []entry {
+	if len(timelines) == 1 {
+		return timelines[0]
+	}
+	var merged []entry
+	for _, entries := range timelines {
+		merged = append(merged, entries...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	return merged
+}
+
+// filter selects the entries to show. Zero fields match everything.
+type filter struct {
+	kinds        []string
+	session      string
+	operation    string
+	since, until time.Time
+}
+
+func (f filter) matches(e session.TimelineEntry) bool {
+	if len(f.kinds) > 0 {
+		found := false
+		for _, kind := range f.kinds {
+			found = found || kind == e.Kind
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case f.session != "" && e.Session != f.session:
+		return false
+	case f.operation != "" && e.OperationID != f.operation:
+		return false
+	case !f.since.IsZero() && e.Time.Before(f.since):
+		return false
+	case !f.until.IsZero() && !e.Time.Before(f.until):
+		return false
+	}
+	return true
+}
+
+// format describes e on one line, with its time relative to first.
+func format(e session.TimelineEntry, first time.Time) string {
+	name := e.Session
+	if name == "" {
+		name = "unknown"
+	}
+	fields := []string{
+		fmt.Sprintf("+%.3fs", e.Time.Sub(first).Seconds()),
+		e.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		fmt.Sprintf("%s#%d", name, e.Epoch),
+		fmt.Sprintf("%-9s", e.Kind),
+	}
+	if e.Kind == session.TimelineOperation {
+		fields = append(fields, fmt.Sprintf("%s[%s]", e.Recipe, e.OperationID))
+	}
+	fields = append(fields, e.Event)
+	if e.Detail != "" {
+		fields = append(fields, e.Detail)
+	}
+	if e.Done {
+		fields = append(fields, fmt.Sprintf("done in %v", e.Elapsed))
+	}
+	if e.Err != "" {
+		fields = append(fields, "error: "+e.Err)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTimelineShouldReadBackupsOldestFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline")
+	assert.NoError(t, os.WriteFile(path+".2", []byte(`{"seq":1}`+"\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path+".1", []byte(`{"seq":2}`+"\n"+`{"seq":3}`+"\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path, []byte(`{"seq":4}`+"\n"+`{"seq"`), 0o644))
+
+	entries, err := readTimeline(path)
+	assert.NoError(t, err)
+	var seqs []uint64
+	for _, e := range entries {
+		seqs = append(seqs, e.Seq)
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4}, seqs)
+}
+
+func TestParseShouldRejectCorruptLines(t *testing.T) {
+	_, err := parse(bufio.NewScanner(strings.NewReader("{\"seq\":1}\nnot json\n{\"seq\":3}\n")), "timeline")
+	assert.ErrorContains(t, err, "timeline:2")
+}
+
+func TestMergeShouldInterleaveTimelinesByTime(t *testing.T) {
+	at := func(seconds int, name string) entry {
+		return entry{TimelineEntry: session.TimelineEntry{Time: time.Unix(int64(seconds), 0), Session: name}}
+	}
+	merged := merge([][]entry{{at(1, "a"), at(3, "a")}, {at(2, "b"), at(3, "b")}})
+	var order []string
+	for _, e := range merged {
+		order = append(order, e.Session)
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, order)
+}
+
+func TestFilterShouldMatchAllFields(t *testing.T) {
+	now := time.Now()
+	e := session.TimelineEntry{Time: now, Session: "a", Kind: session.TimelineOperation, OperationID: "7"}
+
+	assert.True(t, filter{}.matches(e))
+	assert.True(t, filter{kinds: []string{"event", "operation"}, session: "a", operation: "7"}.matches(e))
+	assert.False(t, filter{kinds: []string{"event"}}.matches(e))
+	assert.False(t, filter{session: "b"}.matches(e))
+	assert.False(t, filter{since: now.Add(time.Second)}.matches(e))
+	assert.False(t, filter{until: now}.matches(e))
+}
+
+func TestFormatShouldDescribeOperations(t *testing.T) {
+	first := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	e := session.TimelineEntry{
+		Time:        first.Add(1500 * time.Millisecond),
+		Session:     "orders",
+		Epoch:       3,
+		Kind:        session.TimelineOperation,
+		Event:       "acquire",
+		OperationID: "7",
+		Recipe:      "lock",
+		Done:        true,
+		Elapsed:     time.Second,
+		Err:         "failure",
+	}
+	assert.Equal(t, "+1.500s 2026-10-14T10:00:01.500Z orders#3 operation lock[7] acquire done in 1s error: failure", format(e, first))
+}
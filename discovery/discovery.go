@@ -0,0 +1,228 @@
+// Package discovery registers service instances in ZooKeeper and finds them,
+// preferring instances close to the caller.
+//
+// Each instance is an ephemeral znode under a service root holding the
+// instance as JSON, including the region and zone it runs in. Discover can
+// prefer instances in the caller's zone, then its region, before falling back
+// to every instance.
+package discovery
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/ephemeral"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Instance is a registered instance of a service.
+type Instance struct {
+	ID       string            `json:"id"`
+	Address  string            `json:"address"`
+	Region   string            `json:"region,omitempty"`
+	Zone     string            `json:"zone,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Degraded is set on instances registered with RegisterWhenHealthy
+	// while their health check reports them Degraded.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// Locality is where an instance, or the caller, runs.
+type Locality struct {
+	Region string
+	Zone   string
+}
+
+// Tier is how close the instances returned by Discover are to the preferred
+// locality.
+type Tier int
+
+const (
+	// SameZone instances run in the preferred zone and region.
+	SameZone Tier = iota
+	// SameRegion instances run in the preferred region.
+	SameRegion
+	// Anywhere means locality was not taken into account.
+	Anywhere
+)
+
+func (t Tier) String() string {
+	switch t {
+	case SameZone:
+		return "SameZone"
+	case SameRegion:
+		return "SameRegion"
+	case Anywhere:
+		return "Anywhere"
+	}
+	return "Unknown"
+}
+
+// Register creates and maintains the ephemeral node for inst under root,
+// signalling dead when it no longer exists; see ephemeral.CreateAndMaintain.
+// Use RegisterInstance to choose how the ID is picked and what happens when it
+// is already registered.
+func Register(z *session.ZKSession, root string, inst Instance, dead chan<- error) (err error) {
+	op := session.StartOperation(z, "discovery", "register", path.Join(root, inst.ID))
+	defer func() { op.End(err) }()
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := (managednode.Node{Path: root, OnConflict: managednode.Adopt}).Ensure(z); err != nil {
+		return err
+	}
+	return ephemeral.Maintain(z, managednode.Node{Path: path.Join(root, inst.ID), Data: string(data), Recipe: "discovery"}, dead)
+}
+
+type options struct {
+	locality      *Locality
+	minInstances  int
+	filter        func(Instance) bool
+	preferHealthy bool
+}
+
+// Option configures Discover.
+type Option func(options) options
+
+// PreferLocality makes Discover return only the instances in loc's zone if
+// there are any, then only those in its region, before falling back to every
+// instance.
+func PreferLocality(loc Locality) Option {
+	return func(o options) options {
+		o.locality = &loc
+		return o
+	}
+}
+
+// WithMinInstances makes Discover fall back to the next tier unless the
+// preferred one has at least n instances, so a zone down to its last instance
+// doesn't have to take all of its local traffic.
+func WithMinInstances(n int) Option {
+	return func(o options) options {
+		o.minInstances = n
+		return o
+	}
+}
+
+// WithFilter only considers instances for which keep returns true, before
+// locality is applied.
+func WithFilter(keep func(Instance) bool) Option {
+	return func(o options) options {
+		o.filter = keep
+		return o
+	}
+}
+
+// PreferHealthy leaves degraded instances out unless every instance is
+// degraded, after the filter and before locality are applied.
+func PreferHealthy() Option {
+	return func(o options) options {
+		o.preferHealthy = true
+		return o
+	}
+}
+
+// Discover returns the instances registered under root, sorted by ID, along
+// with the locality tier they were chosen from.
+func Discover(s session.Session, root string, opts ...Option) ([]Instance, Tier, error) {
+	instances, err := list(s, root)
+	if err != nil {
+		return nil, Anywhere, err
+	}
+	selected, tier := choose(instances, opts)
+	return selected, tier, nil
+}
+
+// list returns every instance registered under root, sorted by ID.
+func list(s session.Session, root string) ([]Instance, error) {
+	children, _, err := s.Children(root)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]Instance, 0, len(children))
+	for _, child := range children {
+		data, _, err := s.Get(path.Join(root, child))
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			// Deregistered since we listed the root.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var inst Instance
+		if err := json.Unmarshal([]byte(data), &inst); err != nil {
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+// choose applies the filter and locality preference of opts to instances,
+// without modifying it.
+func choose(instances []Instance, opts []Option) ([]Instance, Tier) {
+	o := options{minInstances: 1}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	if o.filter != nil {
+		kept := make([]Instance, 0, len(instances))
+		for _, inst := range instances {
+			if o.filter(inst) {
+				kept = append(kept, inst)
+			}
+		}
+		instances = kept
+	}
+	if o.preferHealthy {
+		instances = healthiest(instances)
+	}
+
+	if o.locality == nil {
+		return instances, Anywhere
+	}
+	return selectTier(instances, *o.locality, o.minInstances)
+}
+
+// healthiest returns the instances that aren't degraded, or all of them if
+// they all are.
+func healthiest(instances []Instance) []Instance {
+	healthy := make([]Instance, 0, len(instances))
+	for _, inst := range instances {
+		if !inst.Degraded {
+			healthy = append(healthy, inst)
+		}
+	}
+	if len(healthy) == 0 {
+		return instances
+	}
+	return healthy
+}
+
+// selectTier returns the instances in the closest tier to loc holding at
+// least min of them, or all instances.
+func selectTier(instances []Instance, loc Locality, min int) ([]Instance, Tier) {
+	for _, tier := range []Tier{SameZone, SameRegion} {
+		var matching []Instance
+		for _, inst := range instances {
+			if inst.Region != loc.Region || (tier == SameZone && inst.Zone != loc.Zone) {
+				continue
+			}
+			matching = append(matching, inst)
+		}
+		if len(matching) > 0 && len(matching) >= min {
+			return matching, tier
+		}
+	}
+	return instances, Anywhere
+}
@@ -0,0 +1,154 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(instances []Instance) []string {
+	var ids []string
+	for _, inst := range instances {
+		ids = append(ids, inst.ID)
+	}
+	return ids
+}
+
+func TestSelectTierShouldFallBack(t *testing.T) {
+	instances := []Instance{
+		{ID: "a", Region: "us-east", Zone: "1"},
+		{ID: "b", Region: "us-east", Zone: "2"},
+		{ID: "c", Region: "us-west", Zone: "1"},
+	}
+
+	selected, tier := selectTier(instances, Locality{Region: "us-east", Zone: "1"}, 1)
+	assert.Equal(t, SameZone, tier)
+	assert.Equal(t, []string{"a"}, ids(selected))
+
+	selected, tier = selectTier(instances, Locality{Region: "us-east", Zone: "1"}, 2)
+	assert.Equal(t, SameRegion, tier)
+	assert.Equal(t, []string{"a", "b"}, ids(selected))
+
+	selected, tier = selectTier(instances, Locality{Region: "eu-west", Zone: "1"}, 1)
+	assert.Equal(t, Anywhere, tier)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(selected))
+}
+
+func TestDiscoverShouldPreferLocalInstances(t *testing.T) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	dead := make(chan error, 2)
+	for _, inst := range []Instance{
+		{ID: "a", Address: "10.0.0.1:80", Region: "us-east", Zone: "1"},
+		{ID: "b", Address: "10.0.1.1:80", Region: "us-west", Zone: "1"},
+	} {
+		if err := Register(s, "/test", inst, dead); err != nil {
+			t.Fatal("Register error: ", err)
+		}
+	}
+
+	all, tier, err := Discover(s, "/test")
+	if err != nil {
+		t.Fatal("Discover error: ", err)
+	}
+	assert.Equal(t, Anywhere, tier)
+	assert.Equal(t, []string{"a", "b"}, ids(all))
+
+	local, tier, err := Discover(s, "/test", PreferLocality(Locality{Region: "us-west", Zone: "1"}))
+	if err != nil {
+		t.Fatal("Discover error: ", err)
+	}
+	assert.Equal(t, SameZone, tier)
+	assert.Equal(t, "10.0.1.1:80", local[0].Address)
+}
+
+func TestResolverShouldRememberAbsentServices(t *testing.T) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	r := NewResolver(s, WithNegativeTTL(time.Minute))
+	_, _, err = r.Discover("/test")
+	assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+
+	dead := make(chan error, 1)
+	if err := Register(s, "/test", Instance{ID: "a", Address: "10.0.0.1:80"}, dead); err != nil {
+		t.Fatal("Register error: ", err)
+	}
+	_, _, err = r.Discover("/test")
+	assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+
+	r.Forget("/test")
+	instances, _, err := r.Discover("/test")
+	if err != nil {
+		t.Fatal("Discover error: ", err)
+	}
+	assert.Equal(t, []string{"a"}, ids(instances))
+}
+
+func TestIDStrategies(t *testing.T) {
+	t.Setenv("POD_NAME", "web-0")
+	id, err := PodNameID()()
+	assert.NoError(t, err)
+	assert.Equal(t, "web-0", id)
+
+	first, err := RandomID()()
+	assert.NoError(t, err)
+	second, err := RandomID()()
+	assert.NoError(t, err)
+	assert.Len(t, first, 36)
+	assert.Equal(t, byte('4'), first[14])
+	assert.NotEqual(t, first, second)
+}
+
+func TestRegisterInstanceShouldResolveCollisions(t *testing.T) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	other, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer other.Close()
+
+	dead := make(chan error, 4)
+	fixed := WithIDStrategy(func() (string, error) { return "web-0", nil })
+	if _, err := RegisterInstance(other, "/test", Instance{Address: "10.0.0.1:80"}, dead, fixed); err != nil {
+		t.Fatal("RegisterInstance error: ", err)
+	}
+
+	_, err = RegisterInstance(s, "/test", Instance{Address: "10.0.0.2:80"}, dead, fixed)
+	assert.ErrorIs(t, err, ErrIDInUse)
+
+	inst, err := RegisterInstance(s, "/test", Instance{Address: "10.0.0.2:80"}, dead, fixed, OnCollision(Suffix))
+	if err != nil {
+		t.Fatal("RegisterInstance error: ", err)
+	}
+	assert.Equal(t, "web-0-2", inst.ID)
+
+	if _, err := RegisterInstance(s, "/test", Instance{Address: "10.0.0.3:80"}, dead, fixed, OnCollision(Adopt)); err != nil {
+		t.Fatal("RegisterInstance error: ", err)
+	}
+	instances, _, err := Discover(s, "/test")
+	if err != nil {
+		t.Fatal("Discover error: ", err)
+	}
+	assert.Equal(t, []string{"web-0", "web-0-2"}, ids(instances))
+	assert.Equal(t, "10.0.0.3:80", instances[0].Address)
+}
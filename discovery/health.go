@@ -0,0 +1,350 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cleanup"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+const (
+	// DefaultHealthInterval is how often RegisterWhenHealthy checks the
+	// instance's health unless WithHealthInterval is given.
+	DefaultHealthInterval = 5 * time.Second
+	// DefaultRise and DefaultFall are how many checks in a row must pass to
+	// register an instance, and fail to deregister it, unless
+	// WithFlapDamping is given.
+	DefaultRise = 1
+	DefaultFall = 3
+)
+
+// Health is the outcome of a health check.
+type Health int
+
+const (
+	// Healthy instances are registered.
+	Healthy Health = iota
+	// Degraded instances are registered with Instance.Degraded set, for
+	// clients to avoid them when they can; see PreferHealthy.
+	Degraded
+	// Unhealthy instances aren't registered.
+	Unhealthy
+)
+
+func (h Health) String() string {
+	switch h {
+	case Healthy:
+		return "Healthy"
+	case Degraded:
+		return "Degraded"
+	case Unhealthy:
+		return "Unhealthy"
+	}
+	return "Unknown"
+}
+
+// HealthCheck reports the health of the instance. It should return once ctx
+// is done, which it is after the check interval.
+type HealthCheck func(ctx context.Context) Health
+
+type healthOptions struct {
+	interval   time.Duration
+	rise, fall int
+	onChange   func(Health)
+}
+
+// HealthOption configures RegisterWhenHealthy.
+type HealthOption func(healthOptions) healthOptions
+
+// WithHealthInterval checks the instance's health every interval.
+func WithHealthInterval(interval time.Duration) HealthOption {
+	return func(o healthOptions) healthOptions {
+		o.interval = interval
+		return o
+	}
+}
+
+// WithFlapDamping only registers an unhealthy instance once rise checks in a
+// row have passed, and only deregisters a registered one once fall checks in
+// a row have failed, so an instance flapping between the two doesn't churn
+// its clients. Changes between Healthy and Degraded are published as soon as
+// they are seen.
+func WithFlapDamping(rise, fall int) HealthOption {
+	return func(o healthOptions) healthOptions {
+		o.rise, o.fall = rise, fall
+		return o
+	}
+}
+
+// OnHealthChange calls f with the instance's health every time the damped
+// health changes, from the checking goroutine.
+func OnHealthChange(f func(Health)) HealthOption {
+	return func(o healthOptions) healthOptions {
+		o.onChange = f
+		return o
+	}
+}
+
+// HealthRegistration is an instance registered while it is healthy; see
+// RegisterWhenHealthy.
+type HealthRegistration struct {
+	z     *session.ZKSession
+	check HealthCheck
+	inst  Instance
+	node  string
+	o     healthOptions
+
+	mu sync.Mutex
+	// health is the damped health, published the health the instance's
+	// node currently reflects, and passes and failures the checks in a row
+	// that passed and failed.
+	health    Health
+	published Health
+	passes    int
+	failures  int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// RegisterWhenHealthy registers inst under root, as Register does, only while
+// check reports it Healthy or Degraded. The health is checked right away,
+// then every DefaultHealthInterval unless WithHealthInterval is given, and
+// damped as set with WithFlapDamping; a node deleted while the instance is
+// unhealthy is created again once it recovers, and after a session
+// expiry. Failures to create, update or delete the node are tried again at
+// the next check.
+//
+// dead is signalled once the registration ends with the session: with nil
+// when the session is closed, and with session.ErrZKSessionDisconnected when
+// it fails or expires for good. It isn't signalled after Stop.
+func RegisterWhenHealthy(z *session.ZKSession, root string, inst Instance, check HealthCheck, dead chan<- error, opts ...HealthOption) (_ *HealthRegistration, err error) {
+	op := session.StartOperation(z, "discovery", "register_when_healthy", path.Join(root, inst.ID))
+	defer func() { op.End(err) }()
+
+	o := healthOptions{interval: DefaultHealthInterval, rise: DefaultRise, fall: DefaultFall}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	switch {
+	case inst.ID == "" || path.Base(inst.ID) != inst.ID:
+		return nil, fmt.Errorf("invalid instance ID %q", inst.ID)
+	case o.interval <= 0:
+		return nil, fmt.Errorf("health check interval must be positive, got %s", o.interval)
+	case o.rise < 1 || o.fall < 1:
+		return nil, fmt.Errorf("flap damping must be at least 1, got rise %d and fall %d", o.rise, o.fall)
+	}
+
+	if _, err := (managednode.Node{Path: root, OnConflict: managednode.Adopt}).Ensure(z); err != nil {
+		return nil, err
+	}
+
+	r := &HealthRegistration{
+		z:         z,
+		check:     check,
+		inst:      inst,
+		node:      path.Join(root, inst.ID),
+		o:         o,
+		health:    Unhealthy,
+		published: Unhealthy,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := z.Events(ctx)
+	r.observe(r.runCheck())
+	if err := r.sync(); err != nil {
+		op.Step("initial registration failed, will retry: " + err.Error())
+	}
+
+	unregister := cleanup.Register("discovery "+r.node, r.deregister)
+	session.Go(z, "discovery", func() {
+		defer close(r.done)
+		defer cancel()
+		detach := session.Attach(z, "discovery", r.node)
+		defer detach()
+		defer unregister()
+		if ended, err := r.loop(events); ended {
+			dead <- err
+		}
+	})
+	return r, nil
+}
+
+// Health returns the instance's damped health.
+func (r *HealthRegistration) Health() Health {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.health
+}
+
+// Registered reports whether the instance's node is currently registered.
+func (r *HealthRegistration) Registered() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.published != Unhealthy
+}
+
+// Stop stops checking the instance's health and deregisters it.
+func (r *HealthRegistration) Stop() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+	return r.deregister()
+}
+
+// loop checks the health every interval and follows the session. It returns
+// once stopped, or with ended and the error to signal dead with once the
+// session is gone for good.
+func (r *HealthRegistration) loop(events <-chan session.ZKSessionEvent) (ended bool, err error) {
+	ticker := time.NewTicker(r.o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return false, nil
+		case <-ticker.C:
+			r.observe(r.runCheck())
+		case ev := <-events:
+			switch ev {
+			case session.SessionClosed:
+				return true, nil
+			case session.SessionFailed, session.SessionExpired:
+				return true, session.ErrZKSessionDisconnected
+			case session.SessionExpiredReconnected:
+				// The node went with the old session.
+				r.mu.Lock()
+				r.published = Unhealthy
+				r.mu.Unlock()
+			default:
+				continue
+			}
+		}
+		r.sync()
+	}
+}
+
+func (r *HealthRegistration) runCheck() Health {
+	ctx, cancel := context.WithTimeout(context.Background(), r.o.interval)
+	defer cancel()
+	return r.check(ctx)
+}
+
+// observe damps the outcome of a check into the instance's health.
+func (r *HealthRegistration) observe(h Health) {
+	r.mu.Lock()
+	previous := r.health
+	if h == Unhealthy {
+		r.passes, r.failures = 0, r.failures+1
+		if r.failures >= r.o.fall {
+			r.health = Unhealthy
+		}
+	} else {
+		r.passes, r.failures = r.passes+1, 0
+		if r.health != Unhealthy || r.passes >= r.o.rise {
+			r.health = h
+		}
+	}
+	changed := r.health != previous
+	health := r.health
+	r.mu.Unlock()
+
+	if changed && r.o.onChange != nil {
+		r.o.onChange(health)
+	}
+}
+
+// sync creates, updates or deletes the instance's node to reflect its
+// health.
+func (r *HealthRegistration) sync() error {
+	r.mu.Lock()
+	health, published := r.health, r.published
+	r.mu.Unlock()
+	if health == published {
+		return nil
+	}
+
+	var err error
+	switch {
+	case health == Unhealthy:
+		err = r.deregister()
+	case published == Unhealthy:
+		err = r.register(health)
+	default:
+		err = r.update(health)
+	}
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.published = health
+	r.mu.Unlock()
+	return nil
+}
+
+// data returns the node's data for an instance in health.
+func (r *HealthRegistration) data(health Health) (string, error) {
+	inst := r.inst
+	inst.Degraded = health == Degraded
+	data, err := json.Marshal(inst)
+	return string(data), err
+}
+
+// update writes the node's data for an instance in health.
+func (r *HealthRegistration) update(health Health) error {
+	data, err := r.data(health)
+	if err != nil {
+		return err
+	}
+	_, err = r.z.Set(r.node, session.TagOwnership(r.z, "discovery", data), -1)
+	return err
+}
+
+func (r *HealthRegistration) register(health Health) error {
+	data, err := r.data(health)
+	if err != nil {
+		return err
+	}
+	node := managednode.Node{
+		Path:       r.node,
+		Data:       data,
+		Flags:      zookeeper.EPHEMERAL,
+		Recipe:     "discovery",
+		OnConflict: managednode.AdoptIfOwner,
+		// A node left by this instance, degraded or not, is adopted and
+		// updated.
+		Owns: func(data string, _ *zookeeper.Stat) bool {
+			var inst Instance
+			return json.Unmarshal([]byte(data), &inst) == nil && inst.ID == r.inst.ID && inst.Address == r.inst.Address
+		},
+	}
+	if _, err := node.Ensure(r.z); err != nil {
+		return err
+	}
+	current, _, err := r.z.Get(r.node)
+	if err != nil || current == data {
+		return err
+	}
+	return r.update(health)
+}
+
+func (r *HealthRegistration) deregister() error {
+	err := r.z.Delete(r.node, -1)
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return err
+	}
+	r.mu.Lock()
+	r.published = Unhealthy
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveShouldDampFlaps(t *testing.T) {
+	var changes []Health
+	r := &HealthRegistration{
+		health: Unhealthy,
+		o:      healthOptions{rise: 2, fall: 2, onChange: func(h Health) { changes = append(changes, h) }},
+	}
+	for _, h := range []Health{Healthy, Unhealthy, Healthy, Healthy, Degraded, Unhealthy, Healthy, Unhealthy, Unhealthy} {
+		r.observe(h)
+	}
+	assert.Equal(t, []Health{Healthy, Degraded, Healthy, Unhealthy}, changes)
+}
+
+func TestPreferHealthyShouldFallBackToDegraded(t *testing.T) {
+	instances := []Instance{{ID: "a", Degraded: true}, {ID: "b"}}
+	selected, _ := choose(instances, []Option{PreferHealthy()})
+	assert.Equal(t, []string{"b"}, ids(selected))
+
+	selected, _ = choose(instances[:1], []Option{PreferHealthy()})
+	assert.Equal(t, []string{"a"}, ids(selected))
+}
+
+func TestRegisterWhenHealthyShouldFollowHealth(t *testing.T) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	var health int32 = int32(Unhealthy)
+	check := func(context.Context) Health { return Health(atomic.LoadInt32(&health)) }
+	dead := make(chan error, 1)
+	r, err := RegisterWhenHealthy(s, "/test", Instance{ID: "a", Address: "10.0.0.1:80"}, check, dead, WithHealthInterval(10*time.Millisecond), WithFlapDamping(1, 1))
+	if err != nil {
+		t.Fatal("RegisterWhenHealthy error: ", err)
+	}
+	defer r.Stop()
+	registered := func() []Instance {
+		instances, _, err := Discover(s, "/test")
+		assert.NoError(t, err)
+		return instances
+	}
+	assert.Empty(t, registered())
+
+	atomic.StoreInt32(&health, int32(Degraded))
+	assert.Eventually(t, func() bool { i := registered(); return len(i) == 1 && i[0].Degraded }, time.Second, 10*time.Millisecond)
+	atomic.StoreInt32(&health, int32(Healthy))
+	assert.Eventually(t, func() bool { i := registered(); return len(i) == 1 && !i[0].Degraded }, time.Second, 10*time.Millisecond)
+	atomic.StoreInt32(&health, int32(Unhealthy))
+	assert.Eventually(t, func() bool { return len(registered()) == 0 }, time.Second, 10*time.Millisecond)
+	assert.False(t, r.Registered())
+
+	atomic.StoreInt32(&health, int32(Healthy))
+	assert.Eventually(t, r.Registered, time.Second, 10*time.Millisecond)
+	assert.NoError(t, r.Stop())
+	assert.Empty(t, registered())
+}
@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/ephemeral"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// maxSuffix bounds how many suffixed IDs Suffix tries.
+const maxSuffix = 100
+
+// ErrIDInUse is returned by RegisterInstance when the instance's ID is taken
+// and the collision handling doesn't resolve it. It is a
+// session.ErrAlreadyExists.
+var ErrIDInUse = session.NewError("instance ID already registered", session.ErrAlreadyExists)
+
+// IDStrategy picks the ID an instance registers under.
+type IDStrategy func() (string, error)
+
+// HostnameID uses the host name.
+func HostnameID() IDStrategy {
+	return os.Hostname
+}
+
+// PodNameID uses the Kubernetes pod name from the POD_NAME environment
+// variable, usually set through the downward API, falling back to the host
+// name, which Kubernetes sets to the pod name.
+func PodNameID() IDStrategy {
+	return func() (string, error) {
+		if name := os.Getenv("POD_NAME"); name != "" {
+			return name, nil
+		}
+		return os.Hostname()
+	}
+}
+
+// RandomID uses a random UUID, so every registration gets a fresh ID.
+func RandomID() IDStrategy {
+	return func() (string, error) {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return "", err
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	}
+}
+
+// Collision is what RegisterInstance does when a node already exists for the
+// instance's ID, such as one left by a restarted pod with the same host name
+// whose session hasn't timed out yet.
+type Collision int
+
+const (
+	// Fail returns ErrIDInUse.
+	Fail Collision = iota
+	// Adopt replaces the existing node with one owned by this session. If
+	// the node belongs to a live instance, that instance loses its
+	// registration.
+	Adopt
+	// Suffix registers under the first free ID of the form id-2, id-3 and so
+	// on.
+	Suffix
+)
+
+func (c Collision) String() string {
+	switch c {
+	case Fail:
+		return "Fail"
+	case Adopt:
+		return "Adopt"
+	case Suffix:
+		return "Suffix"
+	}
+	return "Unknown"
+}
+
+type registerOptions struct {
+	strategy  IDStrategy
+	collision Collision
+}
+
+// RegisterOption configures RegisterInstance.
+type RegisterOption func(registerOptions) registerOptions
+
+// WithIDStrategy sets the instance's ID with strategy, replacing any ID it
+// has.
+func WithIDStrategy(strategy IDStrategy) RegisterOption {
+	return func(o registerOptions) registerOptions {
+		o.strategy = strategy
+		return o
+	}
+}
+
+// OnCollision sets what happens when the instance's ID is already
+// registered. The default is Fail.
+func OnCollision(c Collision) RegisterOption {
+	return func(o registerOptions) registerOptions {
+		o.collision = c
+		return o
+	}
+}
+
+// RegisterInstance is Register with control over the instance's ID. An
+// instance without an ID, and no strategy given, is registered under the host
+// name. It returns the instance as registered, with the ID it got.
+func RegisterInstance(z *session.ZKSession, root string, inst Instance, dead chan<- error, opts ...RegisterOption) (_ Instance, err error) {
+	op := session.StartOperation(z, "discovery", "register", root)
+	defer func() { op.End(err) }()
+
+	o := registerOptions{}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	strategy := o.strategy
+	if strategy == nil && inst.ID == "" {
+		strategy = HostnameID()
+	}
+	if strategy != nil {
+		id, err := strategy()
+		if err != nil {
+			return Instance{}, fmt.Errorf("choosing instance ID: %w", err)
+		}
+		inst.ID = id
+	}
+	if inst.ID == "" || path.Base(inst.ID) != inst.ID {
+		return Instance{}, fmt.Errorf("invalid instance ID %q", inst.ID)
+	}
+
+	if _, err := (managednode.Node{Path: root, OnConflict: managednode.Adopt}).Ensure(z); err != nil {
+		return Instance{}, err
+	}
+
+	base := inst.ID
+	for n := 1; n <= maxSuffix; n++ {
+		if n > 1 {
+			inst.ID = base + "-" + strconv.Itoa(n)
+		}
+		data, err := json.Marshal(inst)
+		if err != nil {
+			return Instance{}, err
+		}
+
+		op.Step("trying ID " + inst.ID)
+		node := managednode.Node{Path: path.Join(root, inst.ID), Data: string(data), Recipe: "discovery"}
+		if o.collision == Adopt {
+			node.OnConflict = managednode.Overwrite
+		}
+		err = ephemeral.Maintain(z, node, dead)
+		if err == nil {
+			return inst, nil
+		}
+		if !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return Instance{}, err
+		}
+		if o.collision != Suffix {
+			break
+		}
+	}
+	return Instance{}, fmt.Errorf("%w: %s under %s", ErrIDInUse, base, root)
+}
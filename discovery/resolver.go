@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/internal/negcache"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+const (
+	// DefaultNegativeTTL is how long a Resolver remembers that a service had
+	// no instances, unless WithNegativeTTL is given.
+	DefaultNegativeTTL = 5 * time.Second
+	// DefaultMaxNegativeEntries is how many absent services a Resolver
+	// remembers, unless WithMaxNegativeEntries is given.
+	DefaultMaxNegativeEntries = 1024
+)
+
+type resolverOptions struct {
+	negativeTTL time.Duration
+	maxNegative int
+}
+
+// ResolverOption configures a Resolver.
+type ResolverOption func(resolverOptions) resolverOptions
+
+// WithNegativeTTL sets how long a service found absent is reported absent
+// without asking ZooKeeper again. A ttl of 0 disables remembering.
+func WithNegativeTTL(ttl time.Duration) ResolverOption {
+	return func(o resolverOptions) resolverOptions {
+		o.negativeTTL = ttl
+		return o
+	}
+}
+
+// WithMaxNegativeEntries bounds how many absent services are remembered; the
+// oldest are forgotten first.
+func WithMaxNegativeEntries(n int) ResolverOption {
+	return func(o resolverOptions) resolverOptions {
+		o.maxNegative = n
+		return o
+	}
+}
+
+// Resolver discovers instances like Discover, protecting the ensemble from
+// callers retrying a service that isn't there. Concurrent lookups of the same
+// root share a single read, and a root found missing or without instances is
+// reported as such for a short while without reading it again.
+type Resolver struct {
+	session session.Session
+	lookups *negcache.Cache[[]Instance]
+}
+
+// NewResolver returns a Resolver reading from s.
+func NewResolver(s session.Session, opts ...ResolverOption) *Resolver {
+	o := resolverOptions{negativeTTL: DefaultNegativeTTL, maxNegative: DefaultMaxNegativeEntries}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	return &Resolver{
+		session: s,
+		lookups: negcache.New(o.negativeTTL, o.maxNegative, absent),
+	}
+}
+
+// absent reports whether a lookup found that the service doesn't exist.
+func absent(instances []Instance, err error) bool {
+	if err != nil {
+		return zookeeper.IsError(err, zookeeper.ZNONODE)
+	}
+	return len(instances) == 0
+}
+
+// Discover returns the instances registered under root as Discover does. The
+// ZNONODE error for a missing root may have been remembered from an earlier
+// lookup.
+func (r *Resolver) Discover(root string, opts ...Option) ([]Instance, Tier, error) {
+	instances, err := r.lookups.Do(root, func() ([]Instance, error) {
+		return list(r.session, root)
+	})
+	if err != nil {
+		return nil, Anywhere, err
+	}
+
+	// The slice is shared with concurrent callers of the same root.
+	selected, tier := choose(append(make([]Instance, 0, len(instances)), instances...), opts)
+	return selected, tier, nil
+}
+
+// Forget makes the next Discover of root read it again, for callers that know
+// the service has since been registered.
+func (r *Resolver) Forget(root string) {
+	r.lookups.Forget(root)
+}
@@ -0,0 +1,244 @@
+// Package election implements leader election on top of ZooKeeper sequential
+// ephemeral nodes.
+//
+// Each candidate creates a node under a shared root and the candidate owning
+// the node with the lowest sequence number is the leader. Like the lock recipe,
+// each candidate only watches its immediate predecessor, so a change in
+// leadership wakes up a single client.
+//
+// Two lifecycles are provided on top of the same internals: a LeaderLatch
+// holds leadership until it is closed or its session expires, while a
+// LeaderSelector runs a callback while leader and requeues once it returns.
+//
+// Elections can also be weighted, with WithPriority, so that higher priority
+// candidates are preferred over those that joined earlier; see WithPriority
+// and WithPreemption.
+package election
+
+import (
+	"path"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cleanup"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+const candidatePrefix = "candidate-"
+
+// retryInterval is how long to wait before rejoining an election after an
+// error talking to ZooKeeper.
+var retryInterval = time.Second
+
+// errNodeLost is returned when the candidate's node disappeared, usually
+// because the session expired.
+var errNodeLost = session.NewError("election node no longer exists", session.ErrRevoked)
+
+type candidate struct {
+	session    session.Session
+	root       string
+	data       string
+	opts       options
+	node       string
+	epoch      uint64
+	unregister func()
+}
+
+func newCandidate(s session.Session, root, data string, opts []Option) (*candidate, error) {
+	o := &options{Options: recipeopts.Defaults(root)}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	return &candidate{session: s, root: o.BasePath, data: data, opts: *o}, nil
+}
+
+// join creates the candidate's node, and the election root if needed.
+func (c *candidate) join() error {
+	if _, err := (managednode.Node{Path: c.root, OnConflict: managednode.Adopt}).Ensure(c.session); err != nil {
+		return err
+	}
+
+	epoch := session.EpochOf(c.session)
+	node, err := managednode.Node{
+		Path:   path.Join(c.root, c.opts.nodePrefix()),
+		Data:   c.data,
+		Flags:  zookeeper.EPHEMERAL | zookeeper.SEQUENCE,
+		Recipe: "election",
+	}.Ensure(c.session)
+	if err != nil {
+		return err
+	}
+	c.node = node
+	c.epoch = epoch
+	c.unregister = cleanup.Register("election candidate "+node, func() error {
+		err := c.session.Delete(node, -1)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return nil
+		}
+		return err
+	})
+	return nil
+}
+
+// wait blocks until the candidate is the leader, returning true, or until stop
+// is closed, returning false.
+func (c *candidate) wait(stop <-chan struct{}) (bool, error) {
+	for {
+		children, _, err := c.session.Children(c.root)
+		if err != nil {
+			return false, err
+		}
+		ranked := rank(children)
+		name := path.Base(c.node)
+
+		if c.opts.weighted && !c.opts.preempt {
+			incumbent, err := c.incumbent(ranked)
+			if err != nil {
+				return false, err
+			}
+			if incumbent == name {
+				return true, nil
+			}
+			if incumbent != "" {
+				promote(ranked, incumbent)
+			}
+		}
+
+		index := -1
+		for i, child := range ranked {
+			if child == name {
+				index = i
+				break
+			}
+		}
+
+		switch {
+		case index < 0:
+			return false, errNodeLost
+		case index == 0 && c.opts.weighted && !c.opts.preempt:
+			// Claim leadership through the marker; if another candidate got
+			// there first, rank again.
+			if c.claim() {
+				return true, nil
+			}
+			continue
+		case index == 0:
+			return true, nil
+		}
+
+		stat, watch, err := c.session.ExistsW(path.Join(c.root, ranked[index-1]))
+		if err != nil {
+			return false, err
+		}
+		if stat == nil {
+			continue
+		}
+
+		select {
+		case <-watch:
+		case <-stop:
+			return false, nil
+		}
+	}
+}
+
+// incumbent returns the candidate named by the leader marker, or "" if there
+// is none. A marker naming a candidate that's gone is removed.
+func (c *candidate) incumbent(ranked []string) (string, error) {
+	marker := path.Join(c.root, leaderMarker)
+	data, stat, err := c.session.Get(marker)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, child := range ranked {
+		if child == data {
+			return data, nil
+		}
+	}
+	err = c.session.Delete(marker, stat.Version())
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) && !zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+		return "", err
+	}
+	return "", nil
+}
+
+// claim creates the leader marker naming the candidate, reporting whether it
+// succeeded.
+func (c *candidate) claim() bool {
+	_, err := c.session.Create(path.Join(c.root, leaderMarker), path.Base(c.node), zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	return err == nil
+}
+
+// held returns a channel that is closed once the candidate's node goes away,
+// a candidate with a higher priority joins a preemptive election, or stop is
+// closed.
+func (c *candidate) held(stop <-chan struct{}) <-chan struct{} {
+	lost := make(chan struct{})
+	session.Go(c.session, "election", func() {
+		defer close(lost)
+		for {
+			stat, watch, err := c.session.ExistsW(c.node)
+			if err != nil || stat == nil {
+				return
+			}
+
+			var preempted <-chan zookeeper.Event
+			if c.opts.preempt {
+				children, _, childWatch, err := c.session.ChildrenW(c.root)
+				if err != nil {
+					return
+				}
+				if ranked := rank(children); len(ranked) > 0 && ranked[0] != path.Base(c.node) {
+					return
+				}
+				preempted = childWatch
+			}
+
+			select {
+			case <-watch:
+			case <-preempted:
+			case <-stop:
+				return
+			}
+		}
+	})
+	return lost
+}
+
+// leave deletes the candidate's node, if it has one.
+func (c *candidate) leave() error {
+	if c.node == "" {
+		return nil
+	}
+
+	// Release the leader marker first, so that candidates woken by the node's
+	// deletion don't find it still naming us.
+	marker := path.Join(c.root, leaderMarker)
+	if data, stat, err := c.session.Get(marker); err == nil && data == path.Base(c.node) {
+		_ = c.session.Delete(marker, stat.Version())
+	}
+
+	err := c.session.Delete(c.node, -1)
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return err
+	}
+	c.node = ""
+	c.unregister()
+	return nil
+}
+
+// sleep waits for d, returning false early if stop is closed.
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrLatchClosed is returned by Await once the latch has been closed.
+var ErrLatchClosed = errors.New("leader latch closed")
+
+// LeaderLatch joins an election and holds leadership, once acquired, until it
+// is closed, its node is lost with the session, or it is preempted in a
+// preemptive weighted election. After losing leadership the latch
+// automatically rejoins the election.
+type LeaderLatch struct {
+	candidate *candidate
+
+	mu       sync.Mutex
+	leader   bool
+	acquired chan struct{}
+	started  bool
+	closed   bool
+
+	closeOnce sync.Once
+	closeErr  error
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewLeaderLatch creates a latch for the election under root. data is stored
+// in the latch's node, and can be used to identify the leader. It fails if
+// opts are invalid.
+func NewLeaderLatch(s session.Session, root string, data string, opts ...Option) (*LeaderLatch, error) {
+	c, err := newCandidate(s, root, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaderLatch{
+		candidate: c,
+		acquired:  make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start joins the election. It returns an error if the latch's node couldn't
+// be created, or ErrLatchClosed if the latch was closed; otherwise leadership
+// is pursued in the background.
+func (l *LeaderLatch) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrLatchClosed
+	}
+	if err := l.candidate.join(); err != nil {
+		return err
+	}
+	l.started = true
+	session.Go(l.candidate.session, "election", l.run)
+	return nil
+}
+
+func (l *LeaderLatch) run() {
+	detach := session.Attach(l.candidate.session, "leader latch", l.candidate.root)
+	defer detach()
+	defer close(l.done)
+	for {
+		leader, err := l.candidate.wait(l.stop)
+		if err != nil {
+			if !sleep(retryInterval, l.stop) {
+				return
+			}
+			_ = l.rejoin()
+			continue
+		}
+		if !leader {
+			return
+		}
+
+		l.setLeader(true)
+		select {
+		case <-l.candidate.held(l.stop):
+		case <-l.stop:
+		}
+		l.setLeader(false)
+
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+		_ = l.rejoin()
+	}
+}
+
+func (l *LeaderLatch) rejoin() error {
+	_ = l.candidate.leave()
+	return l.candidate.join()
+}
+
+func (l *LeaderLatch) setLeader(leader bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if leader == l.leader {
+		return
+	}
+	l.leader = leader
+	if leader {
+		close(l.acquired)
+	} else {
+		l.acquired = make(chan struct{})
+	}
+}
+
+// IsLeader reports whether the latch currently holds leadership.
+func (l *LeaderLatch) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leader
+}
+
+// Await blocks until the latch holds leadership, ctx is done, or the latch is
+// closed.
+func (l *LeaderLatch) Await(ctx context.Context) error {
+	l.mu.Lock()
+	acquired := l.acquired
+	l.mu.Unlock()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.done:
+		return ErrLatchClosed
+	}
+}
+
+// Close relinquishes leadership, if held, and leaves the election. Closing a
+// latch that was never started returns right away, and closing it again
+// returns the result of the first Close.
+func (l *LeaderLatch) Close() error {
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		started := l.started
+		l.mu.Unlock()
+
+		close(l.stop)
+		if !started {
+			close(l.done)
+			return
+		}
+		<-l.done
+		l.setLeader(false)
+		l.closeErr = l.candidate.leave()
+	})
+	return l.closeErr
+}
+
+// Run joins the election and pursues leadership until ctx is done, closing
+// the latch and returning ctx.Err(), or until it stops otherwise, returning
+// nil. See session.RunUntil.
+func (l *LeaderLatch) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, l.Start, l.done, l.Close)
+}
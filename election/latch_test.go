@@ -0,0 +1,124 @@
+package election
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+)
+
+func newLatch(t *testing.T, s session.Session, data string, opts ...Option) *LeaderLatch {
+	l, err := NewLeaderLatch(s, "/test", data, opts...)
+	if err != nil {
+		t.Fatal("NewLeaderLatch error: ", err)
+	}
+	return l
+}
+
+func newSelector(t *testing.T, s session.Session, data string, lead LeaderFunc) *LeaderSelector {
+	l, err := NewLeaderSelector(s, "/test", data, lead)
+	if err != nil {
+		t.Fatal("NewLeaderSelector error: ", err)
+	}
+	return l
+}
+
+func TestLeaderLatchFailsOverWhenClosed(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		first := newLatch(t, s, "first")
+		if err := first.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+
+		second := newLatch(t, s, "second")
+		if err := second.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer second.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := first.Await(ctx); err != nil {
+			t.Fatal("Expected first latch to become leader: ", err)
+		}
+		if second.IsLeader() {
+			t.Error("Expected second latch not to be leader")
+		}
+
+		if err := first.Close(); err != nil {
+			t.Error("Close error: ", err)
+		}
+
+		if err := second.Await(ctx); err != nil {
+			t.Error("Expected second latch to become leader: ", err)
+		}
+	})
+}
+
+func TestLeaderSelectorRequeuesAfterReturning(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		leading := make(chan string, 4)
+		lead := func(name string) LeaderFunc {
+			return func(ctx context.Context) error {
+				select {
+				case leading <- name:
+				case <-ctx.Done():
+				}
+				return nil
+			}
+		}
+
+		first := newSelector(t, s, "first", lead("first"))
+		second := newSelector(t, s, "second", lead("second"))
+		if err := first.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer first.Close()
+		if err := second.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer second.Close()
+
+		seen := map[string]bool{}
+		for len(seen) < 2 {
+			select {
+			case name := <-leading:
+				seen[name] = true
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected both selectors to lead in turn, saw: ", seen)
+			}
+		}
+	})
+}
+
+func TestLeaderLatchCloseBeforeStartReturns(t *testing.T) {
+	l := newLatch(t, nil, "latch")
+	if err := l.Close(); err != nil {
+		t.Error("Close error: ", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Error("Second Close error: ", err)
+	}
+	if err := l.Start(); err != ErrLatchClosed {
+		t.Errorf("Expected ErrLatchClosed from Start after Close, got %v", err)
+	}
+	if err := l.Await(context.Background()); err != ErrLatchClosed {
+		t.Errorf("Expected ErrLatchClosed from Await after Close, got %v", err)
+	}
+}
+
+func TestLeaderSelectorCloseBeforeStartReturns(t *testing.T) {
+	l := newSelector(t, nil, "selector", func(context.Context) error { return nil })
+	if err := l.Close(); err != nil {
+		t.Error("Close error: ", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Error("Second Close error: ", err)
+	}
+	if err := l.Start(); err != ErrSelectorClosed {
+		t.Errorf("Expected ErrSelectorClosed from Start after Close, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrSelectorClosed is returned by Start once the selector has been closed.
+var ErrSelectorClosed = errors.New("leader selector closed")
+
+// LeaderFunc is run by a LeaderSelector while it holds leadership. ctx is
+// cancelled as soon as leadership is lost. Returning relinquishes leadership
+// and requeues the selector in the election.
+type LeaderFunc func(ctx context.Context) error
+
+type epochKey struct{}
+
+// LeaderEpoch returns the session epoch in which the leadership passed to a
+// LeaderFunc as ctx was won. Work started under an epoch older than the
+// session's current one belongs to a previous leadership term.
+func LeaderEpoch(ctx context.Context) uint64 {
+	epoch, _ := ctx.Value(epochKey{}).(uint64)
+	return epoch
+}
+
+// LeaderSelector repeatedly joins an election and runs a LeaderFunc each time it
+// becomes the leader.
+type LeaderSelector struct {
+	candidate *candidate
+	lead      LeaderFunc
+
+	mu         sync.Mutex
+	started    bool
+	closed     bool
+	unregister func()
+	closeOnce  sync.Once
+	closeErr   error
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewLeaderSelector creates a selector for the election under root, running
+// lead while leader. data is stored in the selector's node, and can be used to
+// identify the leader. It fails if opts are invalid.
+func NewLeaderSelector(s session.Session, root string, data string, lead LeaderFunc, opts ...Option) (*LeaderSelector, error) {
+	c, err := newCandidate(s, root, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaderSelector{
+		candidate: c,
+		lead:      lead,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start joins the election. It returns an error if the selector's node
+// couldn't be created, or ErrSelectorClosed if the selector was closed;
+// otherwise the selector runs in the background until closed, or the session
+// is.
+func (l *LeaderSelector) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrSelectorClosed
+	}
+	if err := l.candidate.join(); err != nil {
+		return err
+	}
+	l.started = true
+	l.unregister = session.RegisterCloser(l.candidate.session, l)
+	session.Go(l.candidate.session, "election", l.run)
+	return nil
+}
+
+func (l *LeaderSelector) run() {
+	detach := session.Attach(l.candidate.session, "leader selector", l.candidate.root)
+	defer detach()
+	defer close(l.done)
+	for {
+		leader, err := l.candidate.wait(l.stop)
+		if err == nil && !leader {
+			return
+		}
+
+		if err == nil {
+			l.runLeader()
+		}
+
+		_ = l.candidate.leave()
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+
+		if err != nil && !sleep(retryInterval, l.stop) {
+			return
+		}
+		for l.candidate.join() != nil {
+			if !sleep(retryInterval, l.stop) {
+				return
+			}
+		}
+	}
+}
+
+func (l *LeaderSelector) runLeader() {
+	ctx := context.WithValue(context.Background(), epochKey{}, l.candidate.epoch)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session.Go(l.candidate.session, "election", func() {
+		select {
+		case <-l.candidate.held(l.stop):
+		case <-ctx.Done():
+		}
+		cancel()
+	})
+
+	_ = session.Protect(l.candidate.session, "election", func() { _ = l.lead(ctx) })
+}
+
+// Close cancels the LeaderFunc, if running, waits for it to return and leaves
+// the election. Closing a selector that was never started returns right away,
+// and closing it again returns the result of the first Close.
+func (l *LeaderSelector) Close() error {
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		started := l.started
+		l.mu.Unlock()
+
+		close(l.stop)
+		if !started {
+			close(l.done)
+			return
+		}
+		if l.unregister != nil {
+			l.unregister()
+		}
+		<-l.done
+		l.closeErr = l.candidate.leave()
+	})
+	return l.closeErr
+}
+
+// Run joins the election and takes part in it until ctx is done, closing the
+// selector and returning ctx.Err(), or until it stops otherwise, as when
+// closed with its session, returning nil. See session.RunUntil.
+func (l *LeaderSelector) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, l.Start, l.done, l.Close)
+}
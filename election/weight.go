@@ -0,0 +1,109 @@
+package election
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// leaderMarker is the name of the node recording the incumbent leader in
+// non-preemptive weighted elections. It holds the leader's node name.
+const leaderMarker = "leader"
+
+type options struct {
+	recipeopts.Options
+	priority int
+	weighted bool
+	preempt  bool
+}
+
+// Option configures a LeaderLatch or LeaderSelector: one of the options below,
+// or one of the options every recipe shares from recipeopts. The base path is
+// the election's root.
+type Option = recipeopts.Option
+
+// WithPriority makes the election weighted and gives the candidate a
+// priority: when leadership changes hands, waiting candidates with a higher
+// priority are preferred, and candidates with the same priority are served in
+// the order they joined. Candidates without a priority have priority 0.
+//
+// Every candidate in a weighted election must be created with the same
+// preemption mode, but their priorities may differ.
+func WithPriority(priority int) Option {
+	return recipeopts.Own(func(o *options) {
+		o.priority = priority
+		o.weighted = true
+	})
+}
+
+// WithPreemption makes a weighted election preemptive: a leader yields as soon
+// as a candidate with a higher priority joins, instead of keeping leadership
+// until it leaves. The leader finds out through a watch, so for a short while
+// both may consider themselves leader.
+func WithPreemption() Option {
+	return recipeopts.Own(func(o *options) {
+		o.weighted = true
+		o.preempt = true
+	})
+}
+
+// nodePrefix returns the name prefix for a candidate's node. Weighted
+// candidates encode their priority in the name so that every participant can
+// rank the others from a single children listing.
+func (o options) nodePrefix() string {
+	if !o.weighted {
+		return candidatePrefix
+	}
+	return fmt.Sprintf("%sp%d-", candidatePrefix, o.priority)
+}
+
+// priorityOf returns the priority encoded in a candidate's node name.
+func priorityOf(name string) int {
+	rest := strings.TrimPrefix(name, candidatePrefix)
+	if !strings.HasPrefix(rest, "p") {
+		return 0
+	}
+	rest = rest[1:]
+	end := strings.LastIndex(rest, "-")
+	if end <= 0 {
+		return 0
+	}
+	priority, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// rank orders candidate node names by descending priority, then by sequence,
+// dropping anything that isn't a candidate node.
+func rank(children []string) []string {
+	ranked := make([]string, 0, len(children))
+	for _, child := range children {
+		if strings.HasPrefix(child, candidatePrefix) {
+			ranked = append(ranked, child)
+		}
+	}
+	session.SortBySequence(ranked)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return priorityOf(ranked[i]) > priorityOf(ranked[j])
+	})
+	return ranked
+}
+
+// promote moves name to the front of ranked, if present, and reports whether
+// it was found.
+func promote(ranked []string, name string) bool {
+	for i, child := range ranked {
+		if child == name {
+			copy(ranked[1:i+1], ranked[:i])
+			ranked[0] = name
+			return true
+		}
+	}
+	return false
+}
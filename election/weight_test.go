@@ -0,0 +1,109 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankShouldOrderByPriorityThenSequence(t *testing.T) {
+	children := []string{
+		"candidate-p1-0000000001",
+		"candidate-p-2-0000000000",
+		"candidate-p5-0000000003",
+		"leader",
+		"candidate-p5-0000000002",
+		"candidate-0000000004",
+	}
+
+	assert.Equal(t, []string{
+		"candidate-p5-0000000002",
+		"candidate-p5-0000000003",
+		"candidate-p1-0000000001",
+		"candidate-0000000004",
+		"candidate-p-2-0000000000",
+	}, rank(children))
+}
+
+func TestNodePrefixShouldEncodePriority(t *testing.T) {
+	assert.Equal(t, "candidate-", options{}.nodePrefix())
+	o := &options{}
+	assert.NoError(t, WithPriority(-3)(o))
+	assert.Equal(t, "candidate-p-3-", o.nodePrefix())
+	assert.Equal(t, -3, priorityOf("candidate-p-3-0000000001"))
+	assert.Equal(t, 0, priorityOf("candidate-0000000001"))
+}
+
+func TestNewLeaderLatchShouldRejectInvalidOptions(t *testing.T) {
+	_, err := NewLeaderLatch(nil, "test", "latch", WithPriority(1))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestWeightedLatchShouldNotBePreemptedByDefault(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		low := newLatch(t, s, "low", WithPriority(1))
+		if err := low.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		if err := low.Await(ctx); err != nil {
+			t.Fatal("Expected low latch to become leader: ", err)
+		}
+
+		mid := newLatch(t, s, "mid", WithPriority(2))
+		high := newLatch(t, s, "high", WithPriority(3))
+		for _, l := range []*LeaderLatch{mid, high} {
+			if err := l.Start(); err != nil {
+				t.Fatal("Start error: ", err)
+			}
+			defer l.Close()
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		assert.True(t, low.IsLeader())
+
+		if err := low.Close(); err != nil {
+			t.Error("Close error: ", err)
+		}
+		if err := high.Await(ctx); err != nil {
+			t.Error("Expected high latch to become leader: ", err)
+		}
+		assert.False(t, mid.IsLeader())
+	})
+}
+
+func TestWeightedLatchShouldYieldWithPreemption(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		low := newLatch(t, s, "low", WithPriority(1), WithPreemption())
+		if err := low.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer low.Close()
+		if err := low.Await(ctx); err != nil {
+			t.Fatal("Expected low latch to become leader: ", err)
+		}
+
+		high := newLatch(t, s, "high", WithPriority(2), WithPreemption())
+		if err := high.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer high.Close()
+
+		if err := high.Await(ctx); err != nil {
+			t.Fatal("Expected high latch to become leader: ", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		assert.False(t, low.IsLeader())
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cleanup"
+	"github.com/Shopify/gozk-recipes/managednode"
 	"github.com/Shopify/gozk-recipes/session"
 )
 
@@ -20,9 +22,21 @@ var maxWait = 20 * time.Second
 // This is not an appropriate construct to use for locking, as a partition will
 // not be immediately reported to the caller; the code will wait for a
 // reconnect or expiry before notifying.
+//
+// The node is registered with the cleanup package while it is maintained, so
+// it is deleted by cleanup.Run during graceful shutdown.
 func CreateAndMaintain(z *session.ZKSession, path, data string, dead chan<- error) error {
+	return Maintain(z, managednode.Node{Path: path, Data: data}, dead)
+}
+
+// Maintain is CreateAndMaintain for a node described with managednode, whose
+// OnConflict decides what happens when the node already exists when it is
+// first created. The node is always created ephemeral.
+func Maintain(z *session.ZKSession, node managednode.Node, dead chan<- error) error {
+	node.Flags |= zookeeper.EPHEMERAL
+	path := node.Path
 	doCreate := func() error {
-		_, err := z.Create(path, data, zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL))
+		_, err := node.Ensure(z)
 		return err
 	}
 
@@ -33,7 +47,24 @@ func CreateAndMaintain(z *session.ZKSession, path, data string, dead chan<- erro
 	evs := make(chan session.ZKSessionEvent)
 	z.Subscribe(evs)
 
-	go func() { dead <- maintainEphemeral(evs, doCreate) }()
+	unregister := cleanup.Register("ephemeral "+path, func() error {
+		err := z.Delete(path, -1)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return nil
+		}
+		return err
+	})
+
+	// After the session expires, a node holding our data is one we created
+	// again already, not a conflict.
+	node.OnConflict = managednode.AdoptIfOwner
+	session.Go(z, "ephemeral", func() {
+		detach := session.Attach(z, "ephemeral", path)
+		err := maintainEphemeral(evs, doCreate)
+		detach()
+		unregister()
+		dead <- err
+	})
 	return nil
 }
 
@@ -51,7 +82,7 @@ func maintainEphemeral(evs <-chan session.ZKSessionEvent, doCreate func() error)
 				// shut down gracefully. The node will already be removed by the
 				// connection teardown.
 				return nil
-			case session.SessionFailed:
+			case session.SessionFailed, session.SessionExpired:
 				return session.ErrZKSessionDisconnected
 			case session.SessionDisconnected:
 				// If the connection isn't re-established before 20 seconds have
@@ -109,7 +109,7 @@ func start(sess *session.ZKSession) {
 					log.Printf("Previous lock state: %v", locked)
 					lockedMu.Unlock()
 					getLock()
-				case session.SessionFailed:
+				case session.SessionFailed, session.SessionExpired:
 					log.Printf("The session failed.")
 					stop(sess)
 				}
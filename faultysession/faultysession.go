@@ -0,0 +1,233 @@
+// Package faultysession provides a session.Session decorator that injects
+// failures, latency and dropped watch events, for exercising the resilience of
+// code built on top of this library in staging environments.
+package faultysession
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+type options struct {
+	failureRate float64
+	failureCode zookeeper.ErrorCode
+	latency     time.Duration
+	jitter      time.Duration
+	dropRate    float64
+	seed        int64
+}
+
+// Option configures a faulty session.
+type Option func(options) options
+
+// WithFailureRate fails the given fraction (between 0 and 1) of operations
+// with a ZCONNECTIONLOSS error, without sending them to ZooKeeper.
+func WithFailureRate(rate float64) Option {
+	return func(o options) options {
+		o.failureRate = rate
+		return o
+	}
+}
+
+// WithFailureCode sets the error code returned by failed operations.
+func WithFailureCode(code zookeeper.ErrorCode) Option {
+	return func(o options) options {
+		o.failureCode = code
+		return o
+	}
+}
+
+// WithLatency delays every operation by latency plus a random duration of up
+// to jitter.
+func WithLatency(latency, jitter time.Duration) Option {
+	return func(o options) options {
+		o.latency = latency
+		o.jitter = jitter
+		return o
+	}
+}
+
+// WithDroppedWatches drops the given fraction (between 0 and 1) of watches:
+// the returned watch channel never fires.
+func WithDroppedWatches(rate float64) Option {
+	return func(o options) options {
+		o.dropRate = rate
+		return o
+	}
+}
+
+// WithSeed seeds the random source deciding which operations fail, for
+// reproducible runs.
+func WithSeed(seed int64) Option {
+	return func(o options) options {
+		o.seed = seed
+		return o
+	}
+}
+
+// Session wraps a session.Session, injecting faults into its operations.
+type Session struct {
+	session.Session
+	opts options
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+var _ session.Session = (*Session)(nil)
+
+// New wraps s with the given faults. With no options, all operations pass
+// through unchanged.
+func New(s session.Session, opts ...Option) *Session {
+	o := options{
+		failureCode: zookeeper.ZCONNECTIONLOSS,
+		seed:        time.Now().UnixNano(),
+	}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	return &Session{
+		Session: s,
+		opts:    o,
+		rand:    rand.New(rand.NewSource(o.seed)),
+	}
+}
+
+func (f *Session) float64() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64()
+}
+
+// inject applies latency and decides whether the operation fails.
+func (f *Session) inject(op, path string) error {
+	delay := f.opts.latency
+	if f.opts.jitter > 0 {
+		delay += time.Duration(f.float64() * float64(f.opts.jitter))
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if f.opts.failureRate > 0 && f.float64() < f.opts.failureRate {
+		return &zookeeper.Error{Op: op, Code: f.opts.failureCode, Path: path}
+	}
+	return nil
+}
+
+// watch returns w, or a channel that never fires if the watch is dropped.
+func (f *Session) watch(w <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if w == nil || f.opts.dropRate <= 0 || f.float64() >= f.opts.dropRate {
+		return w
+	}
+
+	go func() {
+		for range w {
+		}
+	}()
+	return make(chan zookeeper.Event)
+}
+
+// RemoveWatches gives up the watches of kind on path in the wrapped session,
+// with session.AbandonWatch.
+func (f *Session) RemoveWatches(path, kind string) error {
+	session.AbandonWatch(f.Session, path, kind)
+	return nil
+}
+
+func (f *Session) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	if err := f.inject("acl", path); err != nil {
+		return nil, nil, err
+	}
+	return f.Session.ACL(path)
+}
+
+func (f *Session) Children(path string) ([]string, *zookeeper.Stat, error) {
+	if err := f.inject("children", path); err != nil {
+		return nil, nil, err
+	}
+	return f.Session.Children(path)
+}
+
+func (f *Session) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := f.inject("children", path); err != nil {
+		return nil, nil, nil, err
+	}
+	children, stat, w, err := f.Session.ChildrenW(path)
+	return children, stat, f.watch(w), err
+}
+
+func (f *Session) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	if err := f.inject("create", path); err != nil {
+		return "", err
+	}
+	return f.Session.Create(path, value, flags, aclv)
+}
+
+func (f *Session) Delete(path string, version int) error {
+	if err := f.inject("delete", path); err != nil {
+		return err
+	}
+	return f.Session.Delete(path, version)
+}
+
+func (f *Session) Exists(path string) (*zookeeper.Stat, error) {
+	if err := f.inject("exists", path); err != nil {
+		return nil, err
+	}
+	return f.Session.Exists(path)
+}
+
+func (f *Session) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := f.inject("exists", path); err != nil {
+		return nil, nil, err
+	}
+	stat, w, err := f.Session.ExistsW(path)
+	return stat, f.watch(w), err
+}
+
+func (f *Session) Get(path string) (string, *zookeeper.Stat, error) {
+	if err := f.inject("get", path); err != nil {
+		return "", nil, err
+	}
+	return f.Session.Get(path)
+}
+
+func (f *Session) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := f.inject("get", path); err != nil {
+		return "", nil, nil, err
+	}
+	value, stat, w, err := f.Session.GetW(path)
+	return value, stat, f.watch(w), err
+}
+
+func (f *Session) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	if err := f.inject("set", path); err != nil {
+		return nil, err
+	}
+	return f.Session.Set(path, value, version)
+}
+
+func (f *Session) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	if err := f.inject("retrychange", path); err != nil {
+		return err
+	}
+	return f.Session.RetryChange(path, flags, acl, changeFunc)
+}
+
+func (f *Session) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	if err := f.inject("setacl", path); err != nil {
+		return err
+	}
+	return f.Session.SetACL(path, aclv, version)
+}
+
+// Epoch returns the epoch of the wrapped session; see session.EpochOf.
+func (f *Session) Epoch() uint64 {
+	return session.EpochOf(f.Session)
+}
@@ -0,0 +1,67 @@
+package faultysession
+
+import (
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// stubSession succeeds at everything without talking to ZooKeeper.
+type stubSession struct {
+	session.Session
+}
+
+func (stubSession) Get(path string) (string, *zookeeper.Stat, error) {
+	return "data", &zookeeper.Stat{}, nil
+}
+
+func (stubSession) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	w := make(chan zookeeper.Event, 1)
+	w <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: path}
+	return &zookeeper.Stat{}, w, nil
+}
+
+func TestFailureRateShouldFailOperations(t *testing.T) {
+	f := New(stubSession{}, WithFailureRate(1))
+
+	_, _, err := f.Get("/foo")
+	if !zookeeper.IsError(err, zookeeper.ZCONNECTIONLOSS) {
+		t.Error("Expected ZCONNECTIONLOSS, got: ", err)
+	}
+}
+
+func TestNoOptionsShouldPassThrough(t *testing.T) {
+	f := New(stubSession{})
+
+	data, _, err := f.Get("/foo")
+	if err != nil || data != "data" {
+		t.Error("Expected Get to pass through, got: ", data, err)
+	}
+}
+
+func TestLatencyShouldDelayOperations(t *testing.T) {
+	f := New(stubSession{}, WithLatency(50*time.Millisecond, 0))
+
+	start := time.Now()
+	f.Get("/foo")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Error("Expected Get to be delayed, took: ", elapsed)
+	}
+}
+
+func TestDroppedWatchesShouldNeverFire(t *testing.T) {
+	f := New(stubSession{}, WithDroppedWatches(1))
+
+	_, w, err := f.ExistsW("/foo")
+	if err != nil {
+		t.Fatal("ExistsW error: ", err)
+	}
+
+	select {
+	case <-w:
+		t.Error("Expected dropped watch not to fire")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
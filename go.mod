@@ -1,10 +1,19 @@
 module github.com/Shopify/gozk-recipes
 
-go 1.16
+go 1.18
 
 require (
 	github.com/Shopify/gozk v0.0.0-20230116163947-813187cc9453
 	github.com/Shopify/toxiproxy/v2 v2.5.0
+	github.com/go-zookeeper/zk v1.0.4
 )
 
-require github.com/stretchr/testify v1.8.1
+require (
+	github.com/stretchr/testify v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,296 @@
+// Package history keeps the past values of a node, so operators can tell what
+// a configuration was at some point, who changed it since, and put it back.
+//
+// Every write made through a History first archives the value it replaces
+// as a sequential node under an archive node, then replaces it if nothing
+// else has changed the node in between. The archive is pruned to the most
+// recent entries after each write. Writes made to the node without going
+// through the History aren't archived.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/retry"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultLimit is how many entries are kept unless WithLimit is given.
+const DefaultLimit = 100
+
+// entryPrefix prefixes the names of the archive's sequential nodes.
+const entryPrefix = "v-"
+
+// ErrNoVersion is returned for a version that isn't in the archive, either
+// never written or pruned.
+var ErrNoVersion = errors.New("history: no such version")
+
+// Entry is a value the node held before a write through the History.
+type Entry struct {
+	// Seq is the entry's sequence number in the archive, increasing with
+	// every write.
+	Seq int `json:"-"`
+	// Value is the value the node held, Version its data version and
+	// Modified when it was written.
+	Value    string    `json:"value"`
+	Version  int       `json:"version"`
+	Modified time.Time `json:"modified"`
+	// Replaced is when the write replacing the value was made, and By who
+	// made it, as given to WithIdentity.
+	Replaced time.Time `json:"replaced"`
+	By       string    `json:"by,omitempty"`
+}
+
+type options struct {
+	recipeopts.Options
+	archive  string
+	limit    int
+	maxAge   time.Duration
+	identity string
+}
+
+// Option configures a History: one of the options below, or one of the
+// options every recipe shares from recipeopts. The timeout bounds each Set,
+// retries included, and entries are encoded with the codec.
+type Option = recipeopts.Option
+
+// WithArchive keeps the entries under archive instead of a sibling of the node
+// named after it with a ".history" suffix.
+func WithArchive(archive string) Option {
+	return recipeopts.Own(func(o *options) { o.archive = archive })
+}
+
+// WithLimit keeps at most limit entries, DefaultLimit if it isn't positive.
+func WithLimit(limit int) Option {
+	return recipeopts.Own(func(o *options) { o.limit = limit })
+}
+
+// WithMaxAge also prunes the entries replaced more than maxAge ago.
+func WithMaxAge(maxAge time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.maxAge = maxAge })
+}
+
+// WithIdentity records identity, such as the operator's or the host's name, as
+// who made the writes.
+func WithIdentity(identity string) Option {
+	return recipeopts.Own(func(o *options) { o.identity = identity })
+}
+
+// History writes a node, keeping its past values.
+type History struct {
+	session session.Session
+	path    string
+	o       options
+
+	mu      sync.Mutex
+	ensured bool
+}
+
+// New returns a History of the node at path, the default base path, or fails
+// if opts are invalid.
+func New(s session.Session, path string, opts ...Option) (*History, error) {
+	o := &options{Options: recipeopts.Defaults(path), limit: DefaultLimit}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.archive == "" {
+		o.archive = o.BasePath + ".history"
+	}
+	if o.limit <= 0 {
+		o.limit = DefaultLimit
+	}
+	return &History{session: s, path: o.BasePath, o: *o}, nil
+}
+
+// Get returns the node's current value.
+func (h *History) Get() (string, *zookeeper.Stat, error) {
+	return h.session.Get(h.path)
+}
+
+// Set replaces the node's value with value, creating the node if it doesn't
+// exist, after archiving the value it held. If the node changes between the
+// two, the entry is withdrawn and both are tried again, backing off according
+// to the session's retry policy. The entry stays if the write fails
+// otherwise, as it may still have been made.
+func (h *History) Set(value string) (stat *zookeeper.Stat, err error) {
+	op, end := h.o.StartOperation(h.session, "history", "set", h.path)
+	defer func() { end(err) }()
+	ctx, cancel := h.o.Context(context.Background())
+	defer cancel()
+	if err := h.ensureArchive(); err != nil {
+		return nil, err
+	}
+
+	backoff := session.RetryPolicyOf(h.session, retry.DefaultPolicy).Start()
+	for {
+		current, currentStat, err := h.session.Get(h.path)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			_, err = h.session.Create(h.path, value, 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+			if err == nil {
+				return h.session.Exists(h.path)
+			}
+			if !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, err
+		} else {
+			entry, err := h.archive(current, currentStat)
+			if err != nil {
+				return nil, err
+			}
+			stat, err = h.session.Set(h.path, value, currentStat.Version())
+			if err == nil {
+				if err := h.Prune(); err != nil {
+					op.Step("pruning failed: " + err.Error())
+				}
+				return stat, nil
+			}
+			if !zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+				return nil, err
+			}
+			if err := h.session.Delete(entry, -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+				return nil, err
+			}
+		}
+		op.Step("the node changed meanwhile, trying again")
+		if err := retry.Sleep(ctx, backoff.Next()); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// archive adds an entry for value, read at stat, and returns its path.
+func (h *History) archive(value string, stat *zookeeper.Stat) (string, error) {
+	data, err := h.o.Codec.Marshal(Entry{
+		Value:    value,
+		Version:  stat.Version(),
+		Modified: stat.MTime(),
+		Replaced: time.Now(),
+		By:       h.o.identity,
+	})
+	if err != nil {
+		return "", err
+	}
+	return h.session.Create(path.Join(h.o.archive, entryPrefix), string(data), zookeeper.SEQUENCE, zookeeper.WorldACL(zookeeper.PERM_ALL))
+}
+
+func (h *History) ensureArchive() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ensured {
+		return nil
+	}
+	node := managednode.Node{Path: h.o.archive, Parents: true, OnConflict: managednode.Adopt}
+	if _, err := node.Ensure(h.session); err != nil {
+		return err
+	}
+	h.ensured = true
+	return nil
+}
+
+// Versions returns the sequence numbers of the entries in the archive, oldest
+// first.
+func (h *History) Versions() ([]int, error) {
+	entries, err := h.entries()
+	if err != nil {
+		return nil, err
+	}
+	seqs := make([]int, len(entries))
+	for i, e := range entries {
+		seqs[i] = e.seq
+	}
+	return seqs, nil
+}
+
+// GetVersion returns the entry numbered n.
+func (h *History) GetVersion(n int) (Entry, error) {
+	data, _, err := h.session.Get(path.Join(h.o.archive, fmt.Sprintf("%s%010d", entryPrefix, n)))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return Entry{}, fmt.Errorf("%w: %d", ErrNoVersion, n)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := h.o.Codec.Unmarshal([]byte(data), &entry); err != nil {
+		return Entry{}, fmt.Errorf("history: entry %d is invalid: %w", n, err)
+	}
+	entry.Seq = n
+	return entry, nil
+}
+
+// Rollback sets the node back to the value of the entry numbered n. The value
+// it replaces is archived, so a rollback can itself be rolled back.
+func (h *History) Rollback(n int) (*zookeeper.Stat, error) {
+	entry, err := h.GetVersion(n)
+	if err != nil {
+		return nil, err
+	}
+	return h.Set(entry.Value)
+}
+
+// Prune deletes the oldest entries beyond the limit, and those older than the
+// maximum age given to WithMaxAge. Set prunes after every write.
+func (h *History) Prune() error {
+	entries, err := h.entries()
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		expired := h.o.maxAge > 0 && time.Since(e.stat.CTime()) > h.o.maxAge
+		if len(entries)-i <= h.o.limit && !expired {
+			continue
+		}
+		err := h.session.Delete(path.Join(h.o.archive, e.name), -1)
+		if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return err
+		}
+	}
+	return nil
+}
+
+type archived struct {
+	name string
+	seq  int
+	stat *zookeeper.Stat
+}
+
+// entries lists the archive, oldest first.
+func (h *History) entries() ([]archived, error) {
+	children, _, err := session.ChildrenStats(h.session, h.o.archive)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(children))
+	stats := map[string]*zookeeper.Stat{}
+	for _, child := range children {
+		if strings.HasPrefix(child.Name, entryPrefix) {
+			names = append(names, child.Name)
+			stats[child.Name] = child.Stat
+		}
+	}
+	session.SortBySequence(names)
+
+	entries := make([]archived, 0, len(names))
+	for _, name := range names {
+		seq, err := session.ParseSequence(name)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, archived{name: name, seq: seq, stat: stats[name]})
+	}
+	return entries, nil
+}
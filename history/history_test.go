@@ -0,0 +1,73 @@
+package history
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHistory(t *testing.T, s session.Session, opts ...Option) *History {
+	h, err := New(s, "/test/config", opts...)
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return h
+}
+
+func TestHistoryShouldArchiveAndRollBack(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		h := newHistory(t, s, WithIdentity("ops"))
+		for _, value := range []string{"one", "two", "three"} {
+			if _, err := h.Set(value); err != nil {
+				t.Fatal("Set error: ", err)
+			}
+		}
+
+		versions, err := h.Versions()
+		assert.NoError(t, err)
+		if !assert.Len(t, versions, 2) {
+			return
+		}
+		entry, err := h.GetVersion(versions[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "one", entry.Value)
+		assert.Equal(t, 0, entry.Version)
+		assert.Equal(t, "ops", entry.By)
+
+		if _, err := h.Rollback(versions[0]); err != nil {
+			t.Fatal("Rollback error: ", err)
+		}
+		value, _, err := h.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "one", value)
+
+		versions, _ = h.Versions()
+		latest, _ := h.GetVersion(versions[len(versions)-1])
+		assert.Equal(t, "three", latest.Value)
+
+		_, err = h.GetVersion(versions[len(versions)-1] + 1)
+		assert.True(t, errors.Is(err, ErrNoVersion))
+	})
+}
+
+func TestHistoryShouldPruneToLimit(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		h := newHistory(t, s, WithLimit(2), WithArchive("/test/archive"))
+		for _, value := range []string{"a", "b", "c", "d", "e"} {
+			if _, err := h.Set(value); err != nil {
+				t.Fatal("Set error: ", err)
+			}
+		}
+
+		versions, err := h.Versions()
+		assert.NoError(t, err)
+		if !assert.Len(t, versions, 2) {
+			return
+		}
+		oldest, _ := h.GetVersion(versions[0])
+		assert.Equal(t, "c", oldest.Value)
+	})
+}
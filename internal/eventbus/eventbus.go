@@ -0,0 +1,66 @@
+// Package eventbus provides typed topics with ordered delivery, connecting the
+// session's event sources with the recipes and application code consuming
+// them.
+package eventbus
+
+import "sync"
+
+// Topic delivers values of type T to its subscribers. Publish blocks until
+// every subscriber has received the value, so subscribers see values in the
+// order they were published, and a subscriber that doesn't drain its channel
+// holds up the topic. The zero value is an empty topic ready to use.
+type Topic[T any] struct {
+	mu          sync.Mutex
+	subscribers []chan<- T
+}
+
+// Subscribe delivers every value published from now on to ch.
+func (t *Topic[T]) Subscribe(ch chan<- T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers = append(t.subscribers, ch)
+}
+
+// Unsubscribe stops delivering values to ch. Values sent to ch while waiting
+// for a publication in progress to finish are discarded.
+func (t *Topic[T]) Unsubscribe(ch chan T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-ch:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, subscriber := range t.subscribers {
+		if subscriber == (chan<- T)(ch) {
+			t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish delivers v to every subscriber, in subscription order.
+func (t *Topic[T]) Publish(v T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, subscriber := range t.subscribers {
+		subscriber <- v
+	}
+}
+
+// TrySubscribers returns the current subscribers, or false if a publication
+// is in progress, which may be blocked on a subscriber.
+func (t *Topic[T]) TrySubscribers() ([]chan<- T, bool) {
+	if !t.mu.TryLock() {
+		return nil, false
+	}
+	defer t.mu.Unlock()
+	return append([]chan<- T(nil), t.subscribers...), true
+}
@@ -0,0 +1,33 @@
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishShouldDeliverInOrder(t *testing.T) {
+	var topic Topic[int]
+	first, second := make(chan int, 3), make(chan int, 3)
+	topic.Subscribe(first)
+	topic.Subscribe(second)
+
+	for i := 1; i <= 3; i++ {
+		topic.Publish(i)
+	}
+	for _, ch := range []chan int{first, second} {
+		assert.Equal(t, []int{1, 2, 3}, []int{<-ch, <-ch, <-ch})
+	}
+}
+
+func TestUnsubscribeShouldStopDelivery(t *testing.T) {
+	var topic Topic[string]
+	ch := make(chan string)
+	topic.Subscribe(ch)
+	topic.Unsubscribe(ch)
+
+	topic.Publish("ignored")
+	subscribers, ok := topic.TrySubscribers()
+	assert.True(t, ok)
+	assert.Empty(t, subscribers)
+}
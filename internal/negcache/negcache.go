@@ -0,0 +1,126 @@
+// Package negcache coalesces concurrent lookups of the same key and briefly
+// remembers lookups that found nothing, so callers retrying an absent path
+// don't each send their own reads to the ensemble.
+package negcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache runs lookups of values of type T. Only misses, as decided by the
+// missing function given to New, are remembered; hits are always looked up
+// again.
+type Cache[T any] struct {
+	ttl     time.Duration
+	max     int
+	missing func(T, error) bool
+	now     func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]*call[T]
+	misses   map[string]*list.Element
+	// order holds the remembered misses, oldest first.
+	order *list.List
+}
+
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+type miss[T any] struct {
+	key     string
+	value   T
+	err     error
+	expires time.Time
+}
+
+// New returns a cache remembering up to max misses for ttl each. missing
+// reports whether the result of a lookup is a miss.
+func New[T any](ttl time.Duration, max int, missing func(T, error) bool) *Cache[T] {
+	return &Cache[T]{
+		ttl:      ttl,
+		max:      max,
+		missing:  missing,
+		now:      time.Now,
+		inflight: make(map[string]*call[T]),
+		misses:   make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Do returns the remembered miss for key if there is one. Otherwise it calls
+// lookup, or waits for the call already in progress for key, and returns its
+// result.
+func (c *Cache[T]) Do(key string, lookup func() (T, error)) (T, error) {
+	c.mu.Lock()
+	if e, ok := c.misses[key]; ok {
+		m := e.Value.(*miss[T])
+		if c.now().Before(m.expires) {
+			c.mu.Unlock()
+			return m.value, m.err
+		}
+		c.remove(e)
+	}
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.value, cl.err
+	}
+	cl := &call[T]{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+
+	cl.value, cl.err = lookup()
+	if c.ttl > 0 && c.max > 0 && c.missing(cl.value, cl.err) {
+		c.remember(key, cl.value, cl.err)
+	}
+	return cl.value, cl.err
+}
+
+// Forget drops the remembered miss for key, for callers that know it now
+// exists.
+func (c *Cache[T]) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.misses[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Len returns the number of remembered misses, including expired ones not yet
+// evicted.
+func (c *Cache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache[T]) remember(key string, value T, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.misses[key]; ok {
+		c.remove(e)
+	}
+	for c.order.Len() >= c.max {
+		c.remove(c.order.Front())
+	}
+	m := &miss[T]{key: key, value: value, err: err, expires: c.now().Add(c.ttl)}
+	c.misses[key] = c.order.PushBack(m)
+}
+
+func (c *Cache[T]) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.misses, e.Value.(*miss[T]).key)
+}
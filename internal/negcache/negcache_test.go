@@ -0,0 +1,96 @@
+package negcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errNotFound = errors.New("not found")
+
+func isNotFound(_ string, err error) bool { return errors.Is(err, errNotFound) }
+
+func TestDoShouldRememberMissesUntilExpired(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := New(time.Second, 10, isNotFound)
+	c.now = func() time.Time { return now }
+
+	var lookups int
+	lookup := func() (string, error) {
+		lookups++
+		return "", errNotFound
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Do("/missing", lookup)
+		assert.Equal(t, errNotFound, err)
+	}
+	assert.Equal(t, 1, lookups)
+
+	now = now.Add(time.Second)
+	_, _ = c.Do("/missing", lookup)
+	assert.Equal(t, 2, lookups)
+
+	c.Forget("/missing")
+	_, _ = c.Do("/missing", lookup)
+	assert.Equal(t, 3, lookups)
+}
+
+func TestDoShouldNotRememberHits(t *testing.T) {
+	c := New(time.Minute, 10, isNotFound)
+	var lookups int
+	for i := 0; i < 2; i++ {
+		value, err := c.Do("/present", func() (string, error) {
+			lookups++
+			return "value", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	assert.Equal(t, 2, lookups)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestDoShouldEvictOldestMisses(t *testing.T) {
+	c := New(time.Minute, 2, isNotFound)
+	miss := func() (string, error) { return "", errNotFound }
+	for _, key := range []string{"/a", "/b", "/c"} {
+		_, _ = c.Do(key, miss)
+	}
+	assert.Equal(t, 2, c.Len())
+
+	var looked bool
+	_, _ = c.Do("/a", func() (string, error) {
+		looked = true
+		return "", errNotFound
+	})
+	assert.True(t, looked)
+}
+
+func TestDoShouldCoalesceConcurrentLookups(t *testing.T) {
+	c := New(time.Minute, 10, isNotFound)
+	release := make(chan struct{})
+	var lookups int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Do("/missing", func() (string, error) {
+				atomic.AddInt32(&lookups, 1)
+				<-release
+				return "", errNotFound
+			})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+}
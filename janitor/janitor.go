@@ -0,0 +1,313 @@
+// Package janitor deletes nodes that have outlived a TTL, for data such as job
+// results that is written under a known path and never cleaned up, and nodes
+// created with CreateTTL, which emulates the TTL nodes of newer ZooKeeper
+// servers.
+//
+// Janitors sharing an election path elect a leader among themselves, and only
+// the leader sweeps, so any number of instances can run the same rules.
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/election"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultInterval is how often the leader sweeps, unless WithInterval is
+// given.
+const DefaultInterval = time.Minute
+
+// Age selects the timestamp a node's age is measured from.
+type Age int
+
+const (
+	// Modified measures age from the last change to the node's data.
+	Modified Age = iota
+	// Created measures age from the node's creation.
+	Created
+)
+
+// Rule expires the children of Path once they are older than TTL. An expired
+// child is deleted along with everything under it.
+type Rule struct {
+	Path string
+	TTL  time.Duration
+	Age  Age
+}
+
+// Report describes a single sweep.
+type Report struct {
+	Started  time.Time
+	Duration time.Duration
+	// Scanned is the number of children of the rules' paths examined.
+	Scanned int
+	// Deleted lists the expired nodes, or the nodes that would have been
+	// deleted in dry-run mode.
+	Deleted []string
+	DryRun  bool
+	// Err is the first error of the sweep. A failing rule doesn't stop the
+	// others from being swept.
+	Err error
+}
+
+// Stats are the janitor's cumulative counters.
+type Stats struct {
+	Sweeps    int64
+	Scanned   int64
+	Deleted   int64
+	Errors    int64
+	LastSweep time.Time
+}
+
+type options struct {
+	recipeopts.Options
+	interval time.Duration
+	dryRun   bool
+	reporter func(Report)
+	ttlIndex string
+}
+
+// Option configures a Janitor: one of the options below, or one of the options
+// every recipe shares from recipeopts. The base path is the election path,
+// and sweeps are reported to the metrics and their failures logged.
+type Option = recipeopts.Option
+
+// WithInterval sets how often the leader sweeps.
+func WithInterval(interval time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.interval = interval })
+}
+
+// WithDryRun reports the nodes that would be deleted without deleting them.
+func WithDryRun() Option {
+	return recipeopts.Own(func(o *options) { o.dryRun = true })
+}
+
+// WithReporter calls report after every sweep.
+func WithReporter(report func(Report)) Option {
+	return recipeopts.Own(func(o *options) { o.reporter = report })
+}
+
+// Janitor sweeps expired nodes while it is the leader of its election.
+type Janitor struct {
+	session  session.Session
+	rules    []Rule
+	opts     options
+	selector *election.LeaderSelector
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New creates a janitor applying rules, taking part in the election under
+// electionPath, the default base path, as id. It fails if opts are invalid.
+func New(s session.Session, electionPath, id string, rules []Rule, opts ...Option) (*Janitor, error) {
+	o := &options{Options: recipeopts.Defaults(electionPath), interval: DefaultInterval}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.interval <= 0 {
+		return nil, fmt.Errorf("%w: sweep interval must be positive, got %s", recipeopts.ErrInvalidOptions, o.interval)
+	}
+
+	j := &Janitor{session: s, rules: rules, opts: *o}
+	selector, err := election.NewLeaderSelector(s, o.BasePath, id, j.lead)
+	if err != nil {
+		return nil, err
+	}
+	j.selector = selector
+	return j, nil
+}
+
+// Start joins the election; the election path's parent must exist.
+func (j *Janitor) Start() error {
+	return j.selector.Start()
+}
+
+// Close stops sweeping and leaves the election.
+func (j *Janitor) Close() error {
+	return j.selector.Close()
+}
+
+// Run joins the election and sweeps while leader until ctx is done, returning
+// ctx.Err(). See LeaderSelector.Run.
+func (j *Janitor) Run(ctx context.Context) error {
+	return j.selector.Run(ctx)
+}
+
+// Stats returns the janitor's counters.
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+func (j *Janitor) lead(ctx context.Context) error {
+	ticker := time.NewTicker(j.opts.interval)
+	defer ticker.Stop()
+	for {
+		j.record(j.Sweep(time.Now()))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (j *Janitor) record(r Report) {
+	j.mu.Lock()
+	j.stats.Sweeps++
+	j.stats.Scanned += int64(r.Scanned)
+	j.stats.Deleted += int64(len(r.Deleted))
+	if r.Err != nil {
+		j.stats.Errors++
+	}
+	j.stats.LastSweep = r.Started
+	j.mu.Unlock()
+
+	if j.opts.reporter != nil {
+		_ = session.Protect(j.session, "janitor", func() { j.opts.reporter(r) })
+	}
+}
+
+// Sweep applies every rule, and the TTL index if any, once, as of now,
+// regardless of leadership. It is
+// run periodically by the leader, and can be called directly from tools.
+func (j *Janitor) Sweep(now time.Time) Report {
+	r := Report{Started: time.Now(), DryRun: j.opts.dryRun}
+	_, end := j.opts.StartOperation(j.session, "janitor", "sweep", j.opts.BasePath)
+	defer func() { end(r.Err) }()
+	for _, rule := range j.rules {
+		if err := j.sweepRule(rule, now, &r); err != nil && r.Err == nil {
+			r.Err = err
+		}
+	}
+	if j.opts.ttlIndex != "" {
+		if err := j.sweepTTL(now, &r); err != nil && r.Err == nil {
+			r.Err = err
+		}
+	}
+	r.Duration = time.Since(r.Started)
+	return r
+}
+
+func (j *Janitor) sweepRule(rule Rule, now time.Time, r *Report) error {
+	children, _, err := j.session.Children(rule.Path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for _, child := range children {
+		node := path.Join(rule.Path, child)
+		stat, err := j.session.Exists(node)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		if stat == nil {
+			continue
+		}
+		r.Scanned++
+
+		since := stat.MTime()
+		if rule.Age == Created {
+			since = stat.CTime()
+		}
+		if now.Sub(since) < rule.TTL {
+			continue
+		}
+
+		if !j.opts.dryRun {
+			err := j.deleteTree(node, stat.Version())
+			if zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+				// Updated since we looked at it.
+				continue
+			}
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+		}
+		r.Deleted = append(r.Deleted, node)
+	}
+	return first
+}
+
+// deleteTree deletes node and everything under it, as long as node is still
+// at version. The version is checked before anything is deleted and again
+// before each child's subtree, so a node updated since it was found expired
+// is kept, losing at most the subtree being deleted when it was updated.
+func (j *Janitor) deleteTree(node string, version int) error {
+	children, stat, err := j.session.Children(node)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.Version() != version {
+		return &zookeeper.Error{Op: "delete", Code: zookeeper.ZBADVERSION, Path: node}
+	}
+	for i, child := range children {
+		if i > 0 {
+			stat, err := j.session.Exists(node)
+			if err != nil {
+				return err
+			}
+			if stat == nil {
+				return nil
+			}
+			if stat.Version() != version {
+				return &zookeeper.Error{Op: "delete", Code: zookeeper.ZBADVERSION, Path: node}
+			}
+		}
+		if err := j.deleteSubtree(path.Join(node, child)); err != nil {
+			return err
+		}
+	}
+
+	err = j.session.Delete(node, version)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	return err
+}
+
+// deleteSubtree deletes node and everything under it, whatever their
+// versions.
+func (j *Janitor) deleteSubtree(node string) error {
+	children, _, err := j.session.Children(node)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := j.deleteSubtree(path.Join(node, child)); err != nil {
+			return err
+		}
+	}
+
+	err = j.session.Delete(node, -1)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package janitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createNodes(t *testing.T, s session.Session, nodes ...string) {
+	for _, node := range nodes {
+		if _, err := s.Create(node, "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Unable to create node: ", err)
+		}
+	}
+}
+
+func newJanitor(t *testing.T, s session.Session, rules []Rule, opts ...Option) *Janitor {
+	j, err := New(s, "/test/janitor", "a", rules, opts...)
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return j
+}
+
+func TestNewShouldRejectInvalidOptions(t *testing.T) {
+	_, err := New(nil, "/test/janitor", "a", nil, WithInterval(0))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestSweepShouldDeleteExpiredNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/results", "/test/results/old", "/test/results/old/part")
+
+		j := newJanitor(t, s, []Rule{{Path: "/test/results", TTL: time.Hour, Age: Created}})
+		r := j.Sweep(time.Now())
+		assert.NoError(t, r.Err)
+		assert.Empty(t, r.Deleted)
+
+		createNodes(t, s, "/test/results/new")
+		r = j.Sweep(time.Now().Add(2 * time.Hour))
+		assert.NoError(t, r.Err)
+		assert.Equal(t, 2, r.Scanned)
+		assert.ElementsMatch(t, []string{"/test/results/old", "/test/results/new"}, r.Deleted)
+
+		children, _, err := s.Children("/test/results")
+		assert.NoError(t, err)
+		assert.Empty(t, children)
+	})
+}
+
+func TestDeleteTreeShouldKeepChildrenOfUpdatedNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/results", "/test/results/old", "/test/results/old/part")
+
+		stat, err := s.Exists("/test/results/old")
+		assert.NoError(t, err)
+		_, err = s.Set("/test/results/old", "updated", -1)
+		assert.NoError(t, err)
+
+		j := newJanitor(t, s, nil)
+		err = j.deleteTree("/test/results/old", stat.Version())
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZBADVERSION), "got %v", err)
+
+		stat, err = s.Exists("/test/results/old/part")
+		assert.NoError(t, err)
+		assert.NotNil(t, stat)
+	})
+}
+
+func TestDryRunShouldKeepNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/results", "/test/results/old")
+
+		j := newJanitor(t, s, []Rule{{Path: "/test/results", TTL: time.Hour, Age: Created}}, WithDryRun())
+		r := j.Sweep(time.Now().Add(2 * time.Hour))
+		assert.True(t, r.DryRun)
+		assert.Equal(t, []string{"/test/results/old"}, r.Deleted)
+
+		stat, err := s.Exists("/test/results/old")
+		assert.NoError(t, err)
+		assert.NotNil(t, stat)
+	})
+}
+
+func TestLeaderShouldSweepPeriodically(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/results", "/test/results/old")
+
+		reports := make(chan Report, 16)
+		j := newJanitor(t, s, []Rule{{Path: "/test/results", Age: Created}},
+			WithInterval(10*time.Millisecond), WithReporter(func(r Report) { reports <- r }))
+		if err := j.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer j.Close()
+
+		select {
+		case r := <-reports:
+			assert.Equal(t, []string{"/test/results/old"}, r.Deleted)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for sweep")
+		}
+		assert.Eventually(t, func() bool { return j.Stats().Sweeps >= 2 }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, int64(1), j.Stats().Deleted)
+	})
+}
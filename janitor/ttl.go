@@ -0,0 +1,183 @@
+package janitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// registration is the data of a TTL node's entry in a TTL index.
+type registration struct {
+	Path string        `json:"path"`
+	TTL  time.Duration `json:"ttl"`
+	// Czxid tells the node registered apart from one created at the same
+	// path after it was deleted.
+	Czxid int64 `json:"czxid"`
+}
+
+// WithTTLIndex makes the janitor also expire the nodes created with CreateTTL
+// and registered under index.
+func WithTTLIndex(index string) Option {
+	return recipeopts.Own(func(o *options) { o.ttlIndex = index })
+}
+
+// CreateTTL creates a node like ZooKeeper 3.5.3's TTL nodes, which gozk
+// can't create: a janitor sweeping index with WithTTLIndex deletes it once it
+// has had no children and no changes to its data for ttl. The node is created
+// as a normal persistent node, sequential if flags include
+// zookeeper.SEQUENCE, and then registered under index, which is created if
+// needed, though its parent must exist. It returns the path created.
+//
+// Expiry is only as timely as the janitor's sweeps, and a node's children are
+// only noticed when a sweep finds them, so the TTL of a node whose last child
+// was removed counts from the sweep that last saw a child.
+func CreateTTL(s session.Session, index, nodePath, value string, flags int, ttl time.Duration, acl []zookeeper.ACL) (string, error) {
+	if flags&zookeeper.EPHEMERAL != 0 {
+		return "", fmt.Errorf("creating %q: %w: TTL nodes can't be ephemeral", nodePath, session.ErrInvalidCreateMode)
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("creating %q: TTL must be positive, got %v", nodePath, ttl)
+	}
+
+	created, err := s.Create(nodePath, value, flags, acl)
+	if err != nil {
+		return "", err
+	}
+	if err := register(s, index, created, ttl); err != nil {
+		_ = s.Delete(created, -1)
+		return "", fmt.Errorf("registering TTL node %s: %w", created, err)
+	}
+	return created, nil
+}
+
+func register(s session.Session, index, node string, ttl time.Duration) error {
+	stat, err := s.Exists(node)
+	if err != nil {
+		return err
+	}
+	if stat == nil {
+		return &zookeeper.Error{Op: "exists", Code: zookeeper.ZNONODE, Path: node}
+	}
+	data, err := json.Marshal(registration{Path: node, TTL: ttl, Czxid: stat.Czxid()})
+	if err != nil {
+		return err
+	}
+
+	acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+	entry := path.Join(index, url.PathEscape(node))
+	_, err = s.Create(entry, string(data), 0, acl)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		if _, err := s.Create(index, "", 0, acl); err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return err
+		}
+		_, err = s.Create(entry, string(data), 0, acl)
+	}
+	if zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		// Left behind by a node deleted at the same path.
+		_, err = s.Set(entry, string(data), -1)
+	}
+	return err
+}
+
+// sweepTTL expires the TTL nodes registered under the janitor's TTL index.
+func (j *Janitor) sweepTTL(now time.Time, r *Report) error {
+	index := j.opts.ttlIndex
+	entries, _, err := j.session.Children(index)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for _, entry := range entries {
+		deleted, err := j.expireTTL(path.Join(index, entry), now, r)
+		if err != nil && first == nil {
+			first = err
+		}
+		if deleted != "" {
+			r.Deleted = append(r.Deleted, deleted)
+		}
+	}
+	return first
+}
+
+// expireTTL applies the registration at entry, returning the node it
+// deleted, if any.
+func (j *Janitor) expireTTL(entry string, now time.Time, r *Report) (string, error) {
+	data, entryStat, err := j.session.Get(entry)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var reg registration
+	if err := json.Unmarshal([]byte(data), &reg); err != nil {
+		return "", fmt.Errorf("reading TTL registration %s: %w", entry, err)
+	}
+
+	stat, err := j.session.Exists(reg.Path)
+	if err != nil {
+		return "", err
+	}
+	if stat == nil || stat.Czxid() != reg.Czxid {
+		// Deleted, and perhaps created again without a TTL.
+		return "", j.unregister(entry)
+	}
+	r.Scanned++
+
+	if stat.NumChildren() > 0 {
+		// Restart the TTL from now, when the node was last seen with
+		// children.
+		if j.opts.dryRun {
+			return "", nil
+		}
+		_, err := j.session.Set(entry, data, entryStat.Version())
+		if zookeeper.IsError(err, zookeeper.ZBADVERSION) || zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	since := stat.MTime()
+	if entryStat.MTime().After(since) {
+		since = entryStat.MTime()
+	}
+	if now.Sub(since) < reg.TTL {
+		return "", nil
+	}
+	if j.opts.dryRun {
+		return reg.Path, nil
+	}
+
+	err = j.session.Delete(reg.Path, stat.Version())
+	switch {
+	case zookeeper.IsError(err, zookeeper.ZBADVERSION), zookeeper.IsError(err, zookeeper.ZNOTEMPTY):
+		// Changed since we looked at it.
+		return "", nil
+	case zookeeper.IsError(err, zookeeper.ZNONODE):
+		return "", j.unregister(entry)
+	case err != nil:
+		return "", err
+	}
+	return reg.Path, j.unregister(entry)
+}
+
+func (j *Janitor) unregister(entry string) error {
+	if j.opts.dryRun {
+		return nil
+	}
+	err := j.session.Delete(entry, -1)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	return err
+}
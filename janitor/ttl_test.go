@@ -0,0 +1,80 @@
+package janitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepShouldExpireTTLNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/leases")
+		acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+
+		idle, err := CreateTTL(s, "/test/ttl", "/test/leases/idle", "", 0, time.Hour, acl)
+		assert.NoError(t, err)
+		busy, err := CreateTTL(s, "/test/ttl", "/test/leases/busy-", "", zookeeper.SEQUENCE, time.Hour, acl)
+		assert.NoError(t, err)
+		createNodes(t, s, busy+"/child")
+
+		j := newJanitor(t, s, nil, WithTTLIndex("/test/ttl"))
+		r := j.Sweep(time.Now())
+		assert.NoError(t, r.Err)
+		assert.Equal(t, 2, r.Scanned)
+		assert.Empty(t, r.Deleted)
+
+		r = j.Sweep(time.Now().Add(2 * time.Hour))
+		assert.NoError(t, r.Err)
+		assert.Equal(t, []string{idle}, r.Deleted)
+		stat, err := s.Exists(idle)
+		assert.NoError(t, err)
+		assert.Nil(t, stat)
+
+		// The TTL of a node whose last child is gone counts from the sweep
+		// that saw the child.
+		assert.NoError(t, s.Delete(busy+"/child", -1))
+		r = j.Sweep(time.Now().Add(2 * time.Hour))
+		assert.NoError(t, r.Err)
+		assert.Equal(t, []string{busy}, r.Deleted)
+
+		entries, _, err := s.Children("/test/ttl")
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestSweepShouldForgetRecreatedTTLNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+
+		node, err := CreateTTL(s, "/test/ttl", "/test/lease", "", 0, time.Hour, acl)
+		assert.NoError(t, err)
+		assert.NoError(t, s.Delete(node, -1))
+		createNodes(t, s, node)
+
+		j := newJanitor(t, s, nil, WithTTLIndex("/test/ttl"))
+		r := j.Sweep(time.Now().Add(2 * time.Hour))
+		assert.NoError(t, r.Err)
+		assert.Empty(t, r.Deleted)
+
+		stat, err := s.Exists(node)
+		assert.NoError(t, err)
+		assert.NotNil(t, stat)
+		entries, _, err := s.Children("/test/ttl")
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCreateTTLShouldRejectEphemeralNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		_, err := CreateTTL(s, "/test/ttl", "/test/lease", "", zookeeper.EPHEMERAL, time.Hour, nil)
+		assert.True(t, errors.Is(err, session.ErrInvalidCreateMode))
+	})
+}
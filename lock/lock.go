@@ -19,22 +19,141 @@ Here are a few things of note:
 **/
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path"
-	"sort"
+	"time"
 
 	"github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cleanup"
+	"github.com/Shopify/gozk-recipes/retry"
 	"github.com/Shopify/gozk-recipes/session"
 )
 
+// ErrTooManyWaiters is returned by Lock when WithMaxWaiters is set and the
+// queue for the lock is already full.
+var ErrTooManyWaiters = errors.New("too many waiters for lock")
+
+// ErrLeaseLost is returned by KeepAlive when the lock isn't held, as when a
+// waiter took it over after its lease went stale. It is a
+// session.ErrNotOwner.
+var ErrLeaseLost = session.NewError("lock lease lost", session.ErrNotOwner)
+
 type GlobalLock struct {
 	Session       *session.ZKSession
 	root          string
 	ephemeralPath string
 	data          string
+	maxBackoff    time.Duration
+	maxWaiters    int
+	lease         time.Duration
+	progress      func(Progress)
+	acquired      time.Time
+	holds         []time.Duration
+	policy        session.DisconnectPolicy
+	conn          *session.ConnectionState
+	unregister    func()
+	detach        func()
+}
+
+// Option configures a GlobalLock.
+type Option func(*GlobalLock)
+
+// holdSamples is the number of recent hold times kept to estimate waits.
+const holdSamples = 16
+
+// Progress describes a client's place in the queue for a lock, as reported to
+// the function given to WithProgress.
+type Progress struct {
+	// Position is the number of clients ahead in the queue, counting the
+	// holder. It is zero once the lock is acquired.
+	Position int
+	// Queued is the number of clients in the queue, counting the holder and
+	// this one.
+	Queued int
+	// Holder is the data the holder gave NewGlobalLock, and HolderSince is
+	// when it joined the queue.
+	Holder      string
+	HolderSince time.Time
+	// Waited is how long this client has been waiting.
+	Waited time.Duration
+	// EstimatedWait is Position times the average of recent hold times, or
+	// zero while there are none. Hold times are this lock's own, from Lock to
+	// Unlock, and the time the queue took to move up a place while it
+	// waited, so the estimate improves as the lock is used.
+	EstimatedWait time.Duration
+}
+
+// WithAutoReleaseOnSignal releases held locks, and other nodes owned by recipes
+// in this process, when the process receives one of signals, rather than
+// leaving them until the session times out. See cleanup.OnSignal.
+func WithAutoReleaseOnSignal(signals ...os.Signal) Option {
+	return func(*GlobalLock) {
+		cleanup.OnSignal(signals...)
+	}
+}
+
+// WithBackoff makes a waiter sleep for a random time of up to max after its
+// watch fires and before checking the queue again. Watches on a popular lock
+// can fire for many waiters at once, such as when the session reconnects, and
+// the jitter spreads their reads out instead of having them all hit the
+// ensemble together.
+func WithBackoff(max time.Duration) Option {
+	return func(g *GlobalLock) {
+		g.maxBackoff = max
+	}
+}
+
+// WithMaxWaiters caps the number of clients waiting for the lock, not
+// counting its holder, at n: Lock fails with ErrTooManyWaiters rather than
+// queue when n clients are already waiting behind the holder.
+func WithMaxWaiters(n int) Option {
+	return func(g *GlobalLock) {
+		g.maxWaiters = n
+	}
 }
 
-func NewGlobalLock(session *session.ZKSession, root string, data string) (*GlobalLock, error) {
+// WithLease makes the lock a lease the holder must renew by calling KeepAlive
+// at least every ttl. A waiter next in line that sees no renewal for ttl
+// deletes the holder's node and takes the lock, so a process that is stuck
+// but keeps its session alive doesn't hold the lock forever. Renewals are
+// judged by the node's version changing, as seen by the waiter's own clock,
+// so clock skew between clients doesn't matter. Every client using the lock
+// must use the same ttl.
+func WithLease(ttl time.Duration) Option {
+	return func(g *GlobalLock) {
+		g.lease = ttl
+	}
+}
+
+// WithProgress calls progress with the client's place in the queue when it
+// joins the queue, each time the queue moves while it waits, and once it
+// acquires the lock, so that callers can alert on, or give up on, a contended
+// lock by cancelling the context given to Acquire. It is called from the
+// goroutine acquiring the lock and must not block. Reporting reads the
+// holder's node each time.
+func WithProgress(progress func(Progress)) Option {
+	return func(g *GlobalLock) {
+		g.progress = progress
+	}
+}
+
+// WithDisconnectPolicy sets what Lock does when called while the session is
+// disconnected: fail with session.ErrDisconnected, or wait for the session to
+// reconnect. ServeStale, like the default, goes ahead. A Lock already waiting
+// for the lock when the session disconnects carries on waiting.
+func WithDisconnectPolicy(policy session.DisconnectPolicy) Option {
+	return func(g *GlobalLock) {
+		g.policy = policy
+		if g.conn == nil {
+			g.conn = session.NewConnectionState(g.Session)
+		}
+	}
+}
+
+func NewGlobalLock(session *session.ZKSession, root string, data string, opts ...Option) (*GlobalLock, error) {
 	if stat, _ := session.Exists(root); stat == nil {
 		_, err := session.Create(root, "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
 		if err != nil {
@@ -43,7 +162,11 @@ func NewGlobalLock(session *session.ZKSession, root string, data string) (*Globa
 			}
 		}
 	}
-	return &GlobalLock{session, root, "", data}, nil
+	g := &GlobalLock{Session: session, root: root, data: data}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
 }
 
 func (g *GlobalLock) Destroy() error {
@@ -59,7 +182,51 @@ func (g *GlobalLock) Destroy() error {
 	return nil
 }
 
-func (g *GlobalLock) Lock() (err error) {
+// Lock waits for the lock for as long as it takes.
+func (g *GlobalLock) Lock() error {
+	return g.Acquire(context.Background())
+}
+
+// Acquire waits for the lock until ctx is done, in which case it leaves the
+// queue and returns an error wrapping ctx.Err().
+func (g *GlobalLock) Acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("acquiring lock %s: %w", g.root, err)
+	}
+	if _, err := g.policy.Check(g.conn); err != nil {
+		return err
+	}
+	op := session.StartOperation(g.Session, "lock", "acquire", g.root)
+	err := g.lock(ctx, op)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("acquiring lock %s: %w", g.root, ctx.Err())
+		g.leave(op)
+	}
+	op.End(err)
+	if err == nil && g.acquired.IsZero() {
+		g.acquired = time.Now()
+	}
+	if err == nil && g.unregister == nil {
+		g.unregister = cleanup.Register("lock "+g.ephemeralPath, g.Unlock)
+		g.detach = session.Attach(g.Session, "lock", g.ephemeralPath)
+	}
+	return err
+}
+
+// leave deletes the node queued for the lock after giving up on it, so as not
+// to hold up the clients queued behind.
+func (g *GlobalLock) leave(op *session.Operation) {
+	if g.ephemeralPath == "" {
+		return
+	}
+	err := g.Session.Delete(g.ephemeralPath, -1)
+	if err == nil || zookeeper.IsError(err, zookeeper.ZNONODE) {
+		op.Step("left the queue")
+		g.ephemeralPath = ""
+	}
+}
+
+func (g *GlobalLock) lock(ctx context.Context, op *session.Operation) (err error) {
 	if len(g.ephemeralPath) > 0 {
 		if stat, _ := g.Session.Exists(g.ephemeralPath); stat != nil {
 			return nil
@@ -67,47 +234,200 @@ func (g *GlobalLock) Lock() (err error) {
 	}
 
 	// (1)
-	g.ephemeralPath, err = g.Session.Create(g.root+"/", g.data, zookeeper.EPHEMERAL|zookeeper.SEQUENCE, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	data := session.TagOwnership(g.Session, "lock", g.data)
+	g.ephemeralPath, _, err = g.Session.CreateSequential(g.root+"/", data, zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL))
 	if err != nil {
 		return err
 	}
+	op.Step("created " + g.ephemeralPath)
 
-	var children []string
-
+	started := time.Now()
+	position, moved := -1, time.Time{}
 	for {
 		// (2)
-		children, _, err = g.Session.Children(g.root)
-
 		// The children nodes with be the sequence values --> 1, 2, 3....
-		sort.Strings(children)
+		children, _, err := session.SequentialChildren(g.Session, g.root)
+		if err != nil {
+			return err
+		}
 
 		if len(children) == 0 {
 			return fmt.Errorf("Lock in unknown state. Ephemeral path %s exists but there are no children.", g.ephemeralPath)
 		}
 
+		myIndex := indexOf(children, path.Base(g.ephemeralPath))
+		if myIndex < 0 {
+			return fmt.Errorf("Lock in unknown state. Ephemeral path %s is not a child of %s.", g.ephemeralPath, g.root)
+		}
+		if g.maxWaiters > 0 && myIndex > g.maxWaiters {
+			if err := g.Session.Delete(g.ephemeralPath, -1); err != nil {
+				return err
+			}
+			g.ephemeralPath = ""
+			return ErrTooManyWaiters
+		}
+
+		// The time to the first move covers only part of a hold, so only
+		// moves after it are sampled.
+		if position >= 0 && myIndex < position {
+			if !moved.IsZero() {
+				g.recordHold(time.Since(moved) / time.Duration(position-myIndex))
+			}
+			moved = time.Now()
+		}
+		if myIndex != position {
+			position = myIndex
+			g.report(children, myIndex, started)
+		}
+
 		// (3)
-		if children[0] == path.Base(g.ephemeralPath) {
+		if myIndex == 0 {
 			return nil
 		}
 
-		myIndex := sort.SearchStrings(children, path.Base(g.ephemeralPath))
+		previous := children[myIndex-1].Name
+		op.Step("waiting for " + previous)
+		if err := g.await(ctx, op, g.root+"/"+previous, myIndex == 1); err != nil {
+			return err
+		}
+	}
+}
 
-		for {
-			// (4)
-			stat, w, err := g.Session.ExistsW(g.root + "/" + children[myIndex-1])
-			if err != nil {
-				return err
-			}
-			// (5)
-			if stat == nil {
-				break
+// await waits for the node at previous to go away. With a lease, a holder's
+// node that isn't renewed for the lease's ttl is deleted.
+func (g *GlobalLock) await(ctx context.Context, op *session.Operation, previous string, holder bool) error {
+	for {
+		// (4)
+		stat, w, err := g.Session.ExistsW(previous)
+		if err != nil {
+			return err
+		}
+		// (5)
+		if stat == nil {
+			return nil
+		}
+		// (6)
+		if g.lease <= 0 || !holder {
+			select {
+			case <-w:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			// (6)
-			<-w
+			g.backoff(ctx)
+			continue
+		}
+		if gone, err := g.awaitLease(ctx, op, previous, stat, w); gone || err != nil {
+			return err
 		}
+		g.backoff(ctx)
 	}
+}
 
-	return nil
+// awaitLease waits for the watch w on the holder's node, found at stat, to
+// fire, or for its lease to go stale, in which case the node is deleted and
+// gone is set.
+func (g *GlobalLock) awaitLease(ctx context.Context, op *session.Operation, holder string, stat *zookeeper.Stat, w <-chan zookeeper.Event) (gone bool, err error) {
+	version, renewed := stat.Version(), time.Now()
+	timer := time.NewTimer(g.lease)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w:
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+
+		stat, err := g.Session.Exists(holder)
+		if err != nil || stat == nil {
+			return stat == nil, err
+		}
+		if stat.Version() != version {
+			version, renewed = stat.Version(), time.Now()
+			timer.Reset(g.lease)
+			continue
+		}
+
+		err = g.Session.Delete(holder, version)
+		switch {
+		case err == nil || zookeeper.IsError(err, zookeeper.ZNONODE):
+			op.Step(fmt.Sprintf("broke lease of %s, not renewed for %v", holder, time.Since(renewed).Round(time.Millisecond)))
+			return true, nil
+		case zookeeper.IsError(err, zookeeper.ZBADVERSION):
+			// Renewed just now.
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+func (g *GlobalLock) backoff(ctx context.Context) {
+	retry.Sleep(ctx, retry.Random(g.maxBackoff))
+}
+
+// recordHold keeps hold among the recent hold times.
+func (g *GlobalLock) recordHold(hold time.Duration) {
+	g.holds = append(g.holds, hold)
+	if len(g.holds) > holdSamples {
+		g.holds = g.holds[len(g.holds)-holdSamples:]
+	}
+}
+
+// estimateWait returns position times the average recent hold time.
+func (g *GlobalLock) estimateWait(position int) time.Duration {
+	if len(g.holds) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, hold := range g.holds {
+		total += hold
+	}
+	return total / time.Duration(len(g.holds)) * time.Duration(position)
+}
+
+// report tells the function given to WithProgress that the client is at
+// position in the queue of children.
+func (g *GlobalLock) report(children []session.SequentialChild, position int, started time.Time) {
+	if g.progress == nil {
+		return
+	}
+	p := Progress{
+		Position:      position,
+		Queued:        len(children),
+		Waited:        time.Since(started),
+		EstimatedWait: g.estimateWait(position),
+	}
+	if data, stat, err := g.Session.Get(g.root + "/" + children[0].Name); err == nil {
+		p.Holder, p.HolderSince = data, stat.CTime()
+	}
+	_ = session.Protect(g.Session, "lock progress", func() { g.progress(p) })
+}
+
+func indexOf(children []session.SequentialChild, name string) int {
+	for i, child := range children {
+		if child.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// KeepAlive renews the lease of a lock held with WithLease. It returns
+// ErrLeaseLost if the lock isn't held, including when a waiter took it over
+// because the lease went stale, in which case the holder must stop relying on
+// it. Call it from the work the lock guards rather than from a separate
+// goroutine, so that it stops when the work is stuck.
+func (g *GlobalLock) KeepAlive() error {
+	if g.ephemeralPath == "" {
+		return ErrLeaseLost
+	}
+	_, err := g.Session.Set(g.ephemeralPath, session.TagOwnership(g.Session, "lock", g.data), -1)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return fmt.Errorf("renewing %s: %w", g.ephemeralPath, ErrLeaseLost)
+	}
+	return err
 }
 
 func (g *GlobalLock) Unlock() error {
@@ -115,7 +435,19 @@ func (g *GlobalLock) Unlock() error {
 	if len(g.ephemeralPath) > 0 {
 		err := g.Session.Delete(g.ephemeralPath, -1)
 		if err == nil {
+			if !g.acquired.IsZero() {
+				g.recordHold(time.Since(g.acquired))
+				g.acquired = time.Time{}
+			}
 			g.ephemeralPath = ""
+			if g.unregister != nil {
+				g.unregister()
+				g.unregister = nil
+			}
+			if g.detach != nil {
+				g.detach()
+				g.detach = nil
+			}
 		}
 	}
 	return err
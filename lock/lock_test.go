@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLock(t *testing.T, s *session.ZKSession, data string, opts ...Option) *GlobalLock {
+	g, err := NewGlobalLock(s, "/test/lock", data, opts...)
+	if err != nil {
+		t.Fatal("NewGlobalLock error: ", err)
+	}
+	return g
+}
+
+// waitForQueue waits until n clients are queued for the lock.
+func waitForQueue(t *testing.T, s *session.ZKSession, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		children, _, err := s.Children("/test/lock")
+		if err == nil && len(children) == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d clients to be queued for the lock", n)
+}
+
+func TestLeaseShouldBeBrokenByNextWaiterOnceStale(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		holder := newLock(t, s, "holder", WithLease(200*time.Millisecond))
+		if err := holder.Lock(); err != nil {
+			t.Fatal("Lock error: ", err)
+		}
+
+		waiter := newLock(t, s, "waiter", WithLease(200*time.Millisecond))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, waiter.Acquire(ctx), "Expected the waiter to take over the stale lease")
+		defer waiter.Unlock()
+
+		assert.True(t, errors.Is(holder.KeepAlive(), ErrLeaseLost), "Expected the holder to learn its lease was lost")
+	})
+}
+
+func TestKeepAliveShouldKeepLease(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		holder := newLock(t, s, "holder", WithLease(300*time.Millisecond))
+		if err := holder.Lock(); err != nil {
+			t.Fatal("Lock error: ", err)
+		}
+		defer holder.Unlock()
+
+		waiter := newLock(t, s, "waiter", WithLease(300*time.Millisecond))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		acquired := make(chan error, 1)
+		go func() { acquired <- waiter.Acquire(ctx) }()
+
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		var err error
+	renewing:
+		for {
+			select {
+			case <-ticker.C:
+				if err := holder.KeepAlive(); err != nil {
+					t.Fatal("KeepAlive error: ", err)
+				}
+			case err = <-acquired:
+				break renewing
+			}
+		}
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "Expected the waiter to time out, got %v", err)
+		assert.NoError(t, holder.KeepAlive())
+	})
+}
+
+func TestMaxWaitersShouldRejectWaitersBeyondLimit(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		holder := newLock(t, s, "holder")
+		if err := holder.Lock(); err != nil {
+			t.Fatal("Lock error: ", err)
+		}
+		defer holder.Unlock()
+
+		first := newLock(t, s, "first", WithMaxWaiters(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		waiting := make(chan error, 1)
+		go func() { waiting <- first.Acquire(ctx) }()
+		waitForQueue(t, s, 2)
+
+		assert.Equal(t, ErrTooManyWaiters, newLock(t, s, "second", WithMaxWaiters(1)).Lock())
+		waitForQueue(t, s, 2)
+
+		cancel()
+		assert.True(t, errors.Is(<-waiting, context.Canceled))
+	})
+}
+
+func TestProgressShouldReportQueuePositions(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		holder := newLock(t, s, "holder")
+		if err := holder.Lock(); err != nil {
+			t.Fatal("Lock error: ", err)
+		}
+
+		progress := make(chan Progress, 16)
+		waiter := newLock(t, s, "waiter", WithProgress(func(p Progress) { progress <- p }))
+		acquired := make(chan error, 1)
+		go func() { acquired <- waiter.Lock() }()
+
+		p := <-progress
+		assert.Equal(t, 1, p.Position)
+		assert.Equal(t, 2, p.Queued)
+		assert.Equal(t, "holder", p.Holder)
+
+		if err := holder.Unlock(); err != nil {
+			t.Fatal("Unlock error: ", err)
+		}
+		assert.NoError(t, <-acquired)
+		defer waiter.Unlock()
+
+		p = <-progress
+		assert.Equal(t, 0, p.Position)
+		assert.Equal(t, 1, p.Queued)
+		assert.Equal(t, "waiter", p.Holder)
+	})
+}
+
+func TestLockShouldWorkInDryRun(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test")
+		dry, err := session.NewSessionWithOpts(session.WithZookeepers(strings.Split(test.GetZooKeepers(t), ",")), session.WithDryRun())
+		if err != nil {
+			t.Fatal("Failed to connect to Zookeeper: ", err)
+		}
+		defer dry.Close()
+
+		g := newLock(t, dry, "dry")
+		assert.NoError(t, g.Lock())
+		assert.NoError(t, g.Unlock())
+
+		stat, err := s.Exists("/test/lock")
+		assert.NoError(t, err)
+		assert.Nil(t, stat, "Expected the dry run not to create the lock")
+		journal := dry.Journal()
+		if assert.Len(t, journal, 3) {
+			assert.Equal(t, "create", journal[1].Op)
+			assert.Equal(t, "/test/lock/", journal[1].Path)
+			assert.Equal(t, "delete", journal[2].Op)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// StaleHolder is a lock node found stale by BreakStale.
+type StaleHolder struct {
+	Path string
+	Data string
+	// Owner is the session owning the node, or zero for a persistent node.
+	Owner int64
+	Age   time.Duration
+	// Holder is set for the node currently holding the lock; the others
+	// are waiting for it.
+	Holder bool
+	Reason string
+	// Removed is set once the node has been deleted, with Force.
+	Removed bool
+}
+
+// auditLogger receives BreakStale's audit records, satisfied by *log.Logger.
+type auditLogger interface {
+	Printf(format string, v ...interface{})
+}
+
+type breakOptions struct {
+	force  bool
+	logger auditLogger
+}
+
+// BreakOption configures BreakStale.
+type BreakOption func(*breakOptions)
+
+// Force makes BreakStale delete the stale nodes it finds. Without it nothing
+// is changed.
+func Force() BreakOption {
+	return func(o *breakOptions) {
+		o.force = true
+	}
+}
+
+// WithAuditLogger sets where BreakStale logs the nodes it finds and removes,
+// the standard logger by default.
+func WithAuditLogger(logger auditLogger) BreakOption {
+	return func(o *breakOptions) {
+		o.logger = logger
+	}
+}
+
+// BreakStale inspects the nodes of the lock at root and returns those that
+// are stale: persistent nodes, which no session expiry will ever remove, and
+// nodes created more than olderThan ago. Zero olderThan only reports
+// persistent nodes. With Force, the stale nodes are deleted, freeing the lock
+// if its holder was one of them. Every node found, and every removal, is
+// logged as an audit record.
+//
+// Nodes are deleted at the version they were inspected at and only if they
+// are still there, so a holder that released the lock in the meantime isn't
+// mistaken for another. Deleting a live holder's node lets another client take
+// the lock while the holder still believes it holds it, so olderThan must be
+// well beyond the longest legitimate hold.
+func BreakStale(s session.Session, root string, olderThan time.Duration, opts ...BreakOption) ([]StaleHolder, error) {
+	o := &breakOptions{logger: log.Default()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	children, _, err := s.Children(root)
+	if err != nil {
+		return nil, err
+	}
+	session.SortBySequence(children)
+
+	now := time.Now()
+	var stale []StaleHolder
+	for i, child := range children {
+		node := path.Join(root, child)
+		data, stat, err := s.Get(node)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			continue
+		}
+		if err != nil {
+			return stale, err
+		}
+
+		h := StaleHolder{Path: node, Data: data, Owner: stat.EphemeralOwner(), Age: now.Sub(stat.CTime()), Holder: i == 0}
+		switch {
+		case h.Owner == 0:
+			h.Reason = "persistent lock node"
+		case olderThan > 0 && h.Age > olderThan:
+			h.Reason = fmt.Sprintf("held for %v, longer than %v", h.Age.Round(time.Second), olderThan)
+		default:
+			continue
+		}
+		o.logger.Printf("gozk-recipes/lock: stale lock node %s (owner %#x, data %q, holder %v): %s", h.Path, h.Owner, h.Data, h.Holder, h.Reason)
+
+		if o.force {
+			err := s.Delete(node, stat.Version())
+			if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+				return append(stale, h), fmt.Errorf("removing stale lock node %s: %w", node, err)
+			}
+			h.Removed = err == nil
+			if h.Removed {
+				o.logger.Printf("gozk-recipes/lock: removed stale lock node %s (owner %#x)", h.Path, h.Owner)
+			}
+		}
+		stale = append(stale, h)
+	}
+	return stale, nil
+}
@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createNodes(t *testing.T, s session.Session, nodes ...string) {
+	for _, node := range nodes {
+		if _, err := s.Create(node, "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Unable to create node: ", err)
+		}
+	}
+}
+
+var quietAudit = WithAuditLogger(log.New(io.Discard, "", 0))
+
+func TestBreakStaleShouldOnlyReportWithoutForce(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/lock", "/test/lock/lock-0000000000")
+
+		stale, err := BreakStale(s, "/test/lock", 0, quietAudit)
+		assert.NoError(t, err)
+		if assert.Len(t, stale, 1) {
+			assert.Equal(t, "/test/lock/lock-0000000000", stale[0].Path)
+			assert.True(t, stale[0].Holder)
+			assert.False(t, stale[0].Removed)
+		}
+
+		stat, err := s.Exists("/test/lock/lock-0000000000")
+		assert.NoError(t, err)
+		assert.NotNil(t, stat, "Expected the node to be kept without Force")
+	})
+}
+
+func TestBreakStaleShouldDeletePersistentHolderWithForce(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/lock", "/test/lock/lock-0000000000")
+		if _, err := s.Create("/test/lock/lock-0000000001", "", zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal("Create error: ", err)
+		}
+
+		stale, err := BreakStale(s, "/test/lock", 0, Force(), quietAudit)
+		assert.NoError(t, err)
+		if assert.Len(t, stale, 1) {
+			assert.Equal(t, "/test/lock/lock-0000000000", stale[0].Path)
+			assert.True(t, stale[0].Removed)
+		}
+
+		children, _, err := s.Children("/test/lock")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"lock-0000000001"}, children, "Expected the waiting ephemeral node to be kept")
+	})
+}
+
+// bumpingSession updates nodes right before deleting them, as another client
+// could between BreakStale's inspection and removal.
+type bumpingSession struct {
+	session.Session
+}
+
+func (b bumpingSession) Delete(path string, version int) error {
+	if _, err := b.Session.Set(path, "touched", -1); err != nil {
+		return err
+	}
+	return b.Session.Delete(path, version)
+}
+
+func TestBreakStaleShouldKeepNodesUpdatedSinceInspection(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		createNodes(t, s, "/test", "/test/lock", "/test/lock/lock-0000000000")
+
+		stale, err := BreakStale(bumpingSession{s}, "/test/lock", 0, Force(), quietAudit)
+		var zkErr *zookeeper.Error
+		if assert.True(t, errors.As(err, &zkErr), "got %v", err) {
+			assert.Equal(t, zookeeper.ZBADVERSION, zkErr.Code)
+		}
+		if assert.Len(t, stale, 1) {
+			assert.False(t, stale[0].Removed)
+		}
+
+		data, _, err := s.Get("/test/lock/lock-0000000000")
+		assert.NoError(t, err)
+		assert.Equal(t, "touched", data)
+	})
+}
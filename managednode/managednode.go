@@ -0,0 +1,188 @@
+// Package managednode creates znodes with a chosen behaviour when the node
+// already exists, giving recipes built on it consistent semantics when they
+// recreate their nodes after a reconnect or a restart.
+package managednode
+
+import (
+	"path"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// maxAttempts bounds how many times Ensure retries when the node changes
+// under it while resolving a conflict.
+const maxAttempts = 5
+
+// Conflict is what Ensure does when the node already exists.
+type Conflict int
+
+const (
+	// Fail returns the ZNODEEXISTS error.
+	Fail Conflict = iota
+	// Overwrite replaces the existing node's data. An ephemeral node is
+	// deleted and created again instead, so it ends up owned by this session.
+	Overwrite
+	// Adopt uses the existing node as it is.
+	Adopt
+	// AdoptIfOwner uses the existing node if Owns reports it belongs to the
+	// caller, and returns the ZNODEEXISTS error otherwise.
+	AdoptIfOwner
+)
+
+func (c Conflict) String() string {
+	switch c {
+	case Fail:
+		return "Fail"
+	case Overwrite:
+		return "Overwrite"
+	case Adopt:
+		return "Adopt"
+	case AdoptIfOwner:
+		return "AdoptIfOwner"
+	}
+	return "Unknown"
+}
+
+// Node describes a znode to create.
+type Node struct {
+	Path  string
+	Data  string
+	Flags int
+	// ACL defaults to zookeeper.WorldACL(zookeeper.PERM_ALL).
+	ACL []zookeeper.ACL
+	// Parents creates missing parents as empty persistent nodes.
+	Parents bool
+	// Recipe, for nodes a recipe creates for its own use, annotates Data
+	// with its owner when the session is created with
+	// session.WithOwnershipTags. Reads strip the annotation, so Owns still
+	// sees the data without it.
+	Recipe string
+
+	OnConflict Conflict
+	// Owns decides whether an existing node belongs to the caller, for
+	// AdoptIfOwner. For ephemeral nodes it defaults to checking that the
+	// node's owner is the caller's session, since another live session may
+	// hold a node with the same data. For persistent nodes it defaults to
+	// comparing the node's data with Data, for recipes storing an identity in
+	// their nodes.
+	Owns func(data string, stat *zookeeper.Stat) bool
+}
+
+// Ensure creates the node, resolving an existing node according to
+// OnConflict, and returns its path. Sequential nodes never conflict.
+func (n Node) Ensure(s session.Session) (string, error) {
+	acl := n.ACL
+	if acl == nil {
+		acl = zookeeper.WorldACL(zookeeper.PERM_ALL)
+	}
+
+	data := n.tagged(s)
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var created string
+		created, err = s.Create(n.Path, data, n.Flags, acl)
+		switch {
+		case err == nil:
+			return created, nil
+		case zookeeper.IsError(err, zookeeper.ZNONODE) && n.Parents:
+			if perr := n.createParents(s, acl); perr != nil {
+				return "", perr
+			}
+			continue
+		case !zookeeper.IsError(err, zookeeper.ZNODEEXISTS):
+			return "", err
+		}
+
+		var done bool
+		done, err = n.resolve(s, err)
+		if done {
+			if err != nil {
+				return "", err
+			}
+			return n.Path, nil
+		}
+	}
+	return "", err
+}
+
+// resolve handles an existing node, reporting false if it changed under us and
+// creating it should be tried again.
+func (n Node) resolve(s session.Session, exists error) (bool, error) {
+	switch n.OnConflict {
+	case Adopt:
+		return true, nil
+
+	case AdoptIfOwner:
+		data, stat, err := s.Get(n.Path)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return false, err
+		}
+		if err != nil {
+			return true, err
+		}
+		owns := n.Owns
+		if owns == nil {
+			owns = n.ownedBy(s)
+		}
+		if !owns(data, stat) {
+			return true, exists
+		}
+		return true, nil
+
+	case Overwrite:
+		stat, err := s.Exists(n.Path)
+		if err != nil {
+			return true, err
+		}
+		if stat == nil {
+			return false, exists
+		}
+		if n.Flags&zookeeper.EPHEMERAL != 0 {
+			err = s.Delete(n.Path, stat.Version())
+		} else {
+			_, err = s.Set(n.Path, n.tagged(s), stat.Version())
+			if err == nil {
+				return true, nil
+			}
+		}
+		if zookeeper.IsError(err, zookeeper.ZNONODE) || zookeeper.IsError(err, zookeeper.ZBADVERSION) || err == nil {
+			return false, exists
+		}
+		return true, err
+	}
+	return true, exists
+}
+
+// ownedBy returns the default Owns for nodes created through s.
+func (n Node) ownedBy(s session.Session) func(string, *zookeeper.Stat) bool {
+	if n.Flags&zookeeper.EPHEMERAL != 0 {
+		return func(_ string, stat *zookeeper.Stat) bool {
+			id, ok := session.SessionID(s)
+			return ok && stat != nil && stat.EphemeralOwner() == id
+		}
+	}
+	return func(data string, _ *zookeeper.Stat) bool { return data == n.Data }
+}
+
+// tagged returns Data with the ownership annotation asked for by Recipe.
+func (n Node) tagged(s session.Session) string {
+	if n.Recipe == "" {
+		return n.Data
+	}
+	return session.TagOwnership(s, n.Recipe, n.Data)
+}
+
+func (n Node) createParents(s session.Session, acl []zookeeper.ACL) error {
+	var missing []string
+	for p := path.Dir(n.Path); p != "/" && p != "."; p = path.Dir(p) {
+		missing = append(missing, p)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		_, err := s.Create(missing[i], "", 0, acl)
+		if err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package managednode
+
+import (
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureShouldResolveConflicts(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		node := Node{Path: "/test/a/node", Data: "me", Parents: true}
+		if _, err := node.Ensure(s); err != nil {
+			t.Fatal("Ensure error: ", err)
+		}
+
+		_, err := node.Ensure(s)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNODEEXISTS))
+
+		node.OnConflict = AdoptIfOwner
+		created, err := node.Ensure(s)
+		assert.NoError(t, err)
+		assert.Equal(t, "/test/a/node", created)
+
+		other := Node{Path: "/test/a/node", Data: "someone else", OnConflict: AdoptIfOwner}
+		_, err = other.Ensure(s)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNODEEXISTS))
+
+		other.OnConflict = Overwrite
+		if _, err := other.Ensure(s); err != nil {
+			t.Fatal("Ensure error: ", err)
+		}
+		data, _, err := s.Get("/test/a/node")
+		assert.NoError(t, err)
+		assert.Equal(t, "someone else", data)
+	})
+}
+
+func TestAdoptIfOwnerShouldOnlyAdoptOwnEphemeralNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		other, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+		if err != nil {
+			t.Fatal("Failed to connect to Zookeeper: ", err)
+		}
+		defer other.Close()
+
+		node := Node{Path: "/test/node", Data: "me", Flags: zookeeper.EPHEMERAL, Parents: true, OnConflict: AdoptIfOwner}
+		if _, err := node.Ensure(other); err != nil {
+			t.Fatal("Ensure error: ", err)
+		}
+
+		_, err = node.Ensure(s)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNODEEXISTS), "Expected a node of another session not to be adopted despite its data")
+
+		_, err = node.Ensure(other)
+		assert.NoError(t, err)
+	})
+}
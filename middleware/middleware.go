@@ -0,0 +1,66 @@
+// Package middleware composes the handling of events delivered by recipes,
+// such as watch events and cache diffs, from reusable steps, in the manner of
+// HTTP middleware. Cross-cutting concerns like filtering, transforming and
+// logging are written once as a Middleware and put in front of every
+// consumer, instead of inside each consumer's event handler.
+package middleware
+
+// Handler handles an event.
+type Handler[T any] func(T)
+
+// Middleware wraps a handler, returning one that may inspect, change, drop or
+// duplicate events before passing them on to next.
+type Middleware[T any] func(next Handler[T]) Handler[T]
+
+// Chain returns final wrapped in middlewares, the first of which sees each
+// event first.
+func Chain[T any](final Handler[T], middlewares ...Middleware[T]) Handler[T] {
+	h := final
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Filter passes on only the events keep returns true for.
+func Filter[T any](keep func(T) bool) Middleware[T] {
+	return func(next Handler[T]) Handler[T] {
+		return func(event T) {
+			if keep(event) {
+				next(event)
+			}
+		}
+	}
+}
+
+// Transform passes on each event as changed by fn.
+func Transform[T any](fn func(T) T) Middleware[T] {
+	return func(next Handler[T]) Handler[T] {
+		return func(event T) {
+			next(fn(event))
+		}
+	}
+}
+
+// Tap calls fn with each event before passing it on unchanged, for side
+// effects such as updating metrics.
+func Tap[T any](fn func(T)) Middleware[T] {
+	return func(next Handler[T]) Handler[T] {
+		return func(event T) {
+			fn(event)
+			next(event)
+		}
+	}
+}
+
+// Logger is the logging interface used by Log, satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Log logs each event, preceded by prefix, before passing it on.
+func Log[T any](logger Logger, prefix string) Middleware[T] {
+	return Tap(func(event T) {
+		logger.Printf("%s%+v", prefix, event)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestChainShouldApplyMiddlewaresInOrder(t *testing.T) {
+	var seen []string
+	var tapped []string
+	h := Chain(
+		func(event string) { seen = append(seen, event) },
+		Filter(func(event string) bool { return !strings.HasPrefix(event, "skip") }),
+		Transform(strings.ToUpper),
+		Tap(func(event string) { tapped = append(tapped, event) }),
+	)
+
+	for _, event := range []string{"a", "skip-b", "c"} {
+		h(event)
+	}
+	assert.Equal(t, []string{"A", "C"}, seen)
+	assert.Equal(t, []string{"A", "C"}, tapped)
+}
+
+func TestChainWithoutMiddlewaresShouldCallFinal(t *testing.T) {
+	var seen []int
+	Chain(func(event int) { seen = append(seen, event) })(1)
+	assert.Equal(t, []int{1}, seen)
+}
+
+func TestLogShouldLogEvents(t *testing.T) {
+	logger := &recordingLogger{}
+	var seen []int
+	Chain(func(event int) { seen = append(seen, event) }, Log[int](logger, "event: "))(42)
+	assert.Equal(t, []string{"event: 42"}, logger.lines)
+	assert.Equal(t, []int{42}, seen)
+}
@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/election"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+const (
+	// DefaultRetention is how long messages are kept unless WithRetention is
+	// given.
+	DefaultRetention = time.Hour
+	// DefaultPruneInterval is how often the janitor prunes unless
+	// WithPruneInterval is given.
+	DefaultPruneInterval = time.Minute
+)
+
+type options struct {
+	recipeopts.Options
+	retention   time.Duration
+	maxMessages int
+	interval    time.Duration
+}
+
+// Option configures a Notifier or a Janitor: one of the options every recipe
+// shares from recipeopts, or, for a Janitor only, one of the options below.
+type Option = recipeopts.Option
+
+// WithRetention sets how long after publishing messages are pruned.
+func WithRetention(retention time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.retention = retention })
+}
+
+// WithMaxMessages additionally prunes the oldest messages of a topic holding
+// more than max messages.
+func WithMaxMessages(max int) Option {
+	return recipeopts.Own(func(o *options) { o.maxMessages = max })
+}
+
+// WithPruneInterval sets how often the leading janitor prunes.
+func WithPruneInterval(interval time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.interval = interval })
+}
+
+// Janitor prunes old messages from every topic under a root while it is the
+// leader among the janitors of that root.
+type Janitor struct {
+	notifier *Notifier
+	opts     options
+	selector *election.LeaderSelector
+}
+
+// NewJanitor creates a janitor for the notifier's topics, identified by id in
+// the janitor election, or fails if opts are invalid. The janitor starts from
+// the notifier's shared options, and its base path is the election's root,
+// root/janitor by default.
+func (n *Notifier) NewJanitor(id string, opts ...Option) (*Janitor, error) {
+	o := &options{Options: n.o, retention: DefaultRetention, interval: DefaultPruneInterval}
+	o.BasePath = n.o.Path("janitor")
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	switch {
+	case o.retention < 0:
+		return nil, fmt.Errorf("%w: retention must not be negative, got %s", recipeopts.ErrInvalidOptions, o.retention)
+	case o.maxMessages < 0:
+		return nil, fmt.Errorf("%w: max messages must not be negative, got %d", recipeopts.ErrInvalidOptions, o.maxMessages)
+	case o.interval <= 0:
+		return nil, fmt.Errorf("%w: prune interval must be positive, got %s", recipeopts.ErrInvalidOptions, o.interval)
+	}
+
+	j := &Janitor{notifier: n, opts: *o}
+	selector, err := election.NewLeaderSelector(n.session, o.BasePath, id, j.lead)
+	if err != nil {
+		return nil, err
+	}
+	j.selector = selector
+	return j, nil
+}
+
+// Start joins the janitor election.
+func (j *Janitor) Start() error {
+	if err := j.ensureRoot(); err != nil {
+		return err
+	}
+	return j.selector.Start()
+}
+
+func (j *Janitor) ensureRoot() error {
+	_, err := (managednode.Node{Path: j.notifier.root, Parents: true, OnConflict: managednode.Adopt}).Ensure(j.notifier.session)
+	return err
+}
+
+// Close stops pruning and leaves the election.
+func (j *Janitor) Close() error {
+	return j.selector.Close()
+}
+
+// Run joins the janitor election and prunes while leader until ctx is done,
+// returning ctx.Err(). See election.LeaderSelector.Run.
+func (j *Janitor) Run(ctx context.Context) error {
+	if err := j.ensureRoot(); err != nil {
+		return err
+	}
+	return j.selector.Run(ctx)
+}
+
+func (j *Janitor) lead(ctx context.Context) error {
+	ticker := time.NewTicker(j.opts.interval)
+	defer ticker.Stop()
+	for {
+		if err := j.prune(time.Now()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// prune removes expired messages from every topic.
+func (j *Janitor) prune(now time.Time) (err error) {
+	s := j.notifier.session
+	_, end := j.opts.StartOperation(s, "notify", "prune", j.notifier.topics())
+	defer func() { end(err) }()
+	topics, _, err := s.Children(j.notifier.topics())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		if err := j.pruneTopic(path.Join(j.notifier.topics(), topic), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneTopic removes messages older than the retention, and the oldest
+// messages beyond the maximum count. Messages are visited oldest first, so
+// pruning by age stops at the first message that is still retained.
+func (j *Janitor) pruneTopic(dir string, now time.Time) error {
+	s := j.notifier.session
+	children, _, err := session.SequentialChildren(s, dir)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var messages []string
+	for _, child := range children {
+		if strings.HasPrefix(child.Name, messagePrefix) {
+			messages = append(messages, child.Name)
+		}
+	}
+
+	for i, message := range messages {
+		node := path.Join(dir, message)
+		if j.opts.maxMessages <= 0 || len(messages)-i <= j.opts.maxMessages {
+			stat, err := s.Exists(node)
+			if err != nil {
+				return err
+			}
+			if stat == nil {
+				continue
+			}
+			if now.Sub(stat.CTime()) < j.opts.retention {
+				return nil
+			}
+		}
+		if err := s.Delete(node, -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Package notify broadcasts messages to every subscriber of a topic.
+//
+// Each message is a sequential node under root/topics/<topic>, so messages
+// are totally ordered per topic and, unlike updates to a single node's data,
+// none are lost when several are published between two reads. Subscribers
+// deliver messages in sequence order, at least once: a subscriber resuming
+// from the sequence after the last message it processed sees every message
+// published since that is still retained.
+//
+// Messages are kept until a Janitor prunes them. Run a Janitor in every
+// process that publishes or subscribes; they elect a leader among themselves
+// under root/janitor and only the leader prunes.
+package notify
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+var retryInterval = time.Second
+
+// messagePrefix is the name of message nodes before their sequence suffix.
+const messagePrefix = "msg-"
+
+// Message is a notification published to a topic.
+type Message struct {
+	Topic   string
+	Seq     int
+	Payload string
+	Created time.Time
+}
+
+// Notifier publishes and subscribes to topics under a root.
+type Notifier struct {
+	session session.Session
+	root    string
+	o       recipeopts.Options
+}
+
+// New returns a Notifier for the topics under root, the default base path, or
+// fails if opts are invalid. It takes the options every recipe shares from
+// recipeopts; publishes are reported to the metrics and their failures
+// logged.
+func New(s session.Session, root string, opts ...Option) (*Notifier, error) {
+	o := recipeopts.Defaults(root)
+	if err := recipeopts.Apply(&o, opts...); err != nil {
+		return nil, err
+	}
+	return &Notifier{session: s, root: o.BasePath, o: o}, nil
+}
+
+func (n *Notifier) topics() string { return path.Join(n.root, "topics") }
+
+func (n *Notifier) topic(topic string) (string, error) {
+	if topic == "" || strings.Contains(topic, "/") || topic == "." || topic == ".." {
+		return "", fmt.Errorf("notify: invalid topic %q", topic)
+	}
+	return path.Join(n.topics(), topic), nil
+}
+
+// Publish adds payload to topic and returns the message's sequence number.
+func (n *Notifier) Publish(topic, payload string) (seq int, err error) {
+	_, end := n.o.StartOperation(n.session, "notify", "publish", topic)
+	defer func() { end(err) }()
+	dir, err := n.topic(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := managednode.Node{
+		Path:    path.Join(dir, messagePrefix),
+		Data:    payload,
+		Flags:   zookeeper.SEQUENCE,
+		Parents: true,
+		Recipe:  "notify",
+	}.Ensure(n.session)
+	if err != nil {
+		return 0, err
+	}
+	return session.ParseSequence(created)
+}
+
+// Subscription delivers the messages of a topic in sequence order.
+type Subscription struct {
+	session session.Session
+	topic   string
+	dir     string
+	next    int
+
+	messages   chan Message
+	unregister func()
+	closeOnce  sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// SubscribeTopic delivers the messages of topic with a sequence number of
+// fromSeq or later, followed by messages published while subscribed. Pass 0
+// to receive every retained message.
+func (n *Notifier) SubscribeTopic(topic string, fromSeq int) (*Subscription, error) {
+	dir, err := n.topic(topic)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := (managednode.Node{Path: dir, Parents: true, OnConflict: managednode.Adopt}).Ensure(n.session); err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		session:  n.session,
+		topic:    topic,
+		dir:      dir,
+		next:     fromSeq,
+		messages: make(chan Message),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	sub.unregister = session.RegisterCloser(n.session, session.CloserFunc(func() error {
+		sub.Close()
+		return nil
+	}))
+	session.Go(n.session, "notify", sub.run)
+	return sub, nil
+}
+
+// Messages returns the channel messages are delivered on. The next message is
+// only read once the previous one has been received. The channel is closed by
+// Close.
+func (sub *Subscription) Messages() <-chan Message {
+	return sub.messages
+}
+
+// Close stops the subscription. It is also closed when the session is.
+func (sub *Subscription) Close() {
+	sub.closeOnce.Do(func() {
+		sub.unregister()
+		close(sub.stop)
+		<-sub.done
+	})
+}
+
+func (sub *Subscription) run() {
+	detach := session.Attach(sub.session, "notify", sub.dir)
+	defer detach()
+	defer close(sub.done)
+	defer close(sub.messages)
+
+	for {
+		watch, err := sub.read()
+		if err != nil {
+			select {
+			case <-time.After(retryInterval):
+				continue
+			case <-sub.stop:
+				return
+			}
+		}
+
+		select {
+		case <-watch:
+		case <-sub.stop:
+			return
+		}
+	}
+}
+
+// read delivers the messages published since the last read and returns the
+// re-armed watch on the topic. A message is only skipped once delivered, or
+// if it was pruned before it could be read.
+func (sub *Subscription) read() (<-chan zookeeper.Event, error) {
+	children, _, watch, err := session.SequentialChildrenW(sub.session, sub.dir)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		// The topic was removed; wait for it to be published to again.
+		var stat *zookeeper.Stat
+		stat, watch, err = sub.session.ExistsW(sub.dir)
+		if err == nil && stat != nil {
+			// Recreated between the two calls; read it again right away.
+			return closedWatch(), nil
+		}
+		return watch, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		seq := child.Sequence
+		if !strings.HasPrefix(child.Name, messagePrefix) || session.SequenceLess(seq, sub.next) {
+			continue
+		}
+
+		data, stat, err := sub.session.Get(path.Join(sub.dir, child.Name))
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			sub.next = int(int32(seq + 1))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		msg := Message{Topic: sub.topic, Seq: seq, Payload: data, Created: stat.CTime()}
+		select {
+		case sub.messages <- msg:
+		case <-sub.stop:
+			return watch, nil
+		}
+		sub.next = int(int32(seq + 1))
+	}
+	return watch, nil
+}
+
+func closedWatch() <-chan zookeeper.Event {
+	watch := make(chan zookeeper.Event)
+	close(watch)
+	return watch
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, sub *Subscription) Message {
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	return Message{}
+}
+
+func newNotifier(t *testing.T, s session.Session) *Notifier {
+	n, err := New(s, "/test")
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return n
+}
+
+func TestSubscribersShouldReceiveEveryMessageInOrder(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		n := newNotifier(t, s)
+		sub, err := n.SubscribeTopic("deploys", 0)
+		if err != nil {
+			t.Fatal("SubscribeTopic error: ", err)
+		}
+		defer sub.Close()
+
+		var seqs []int
+		for _, payload := range []string{"one", "two", "three"} {
+			seq, err := n.Publish("deploys", payload)
+			if err != nil {
+				t.Fatal("Publish error: ", err)
+			}
+			seqs = append(seqs, seq)
+		}
+
+		for i, payload := range []string{"one", "two", "three"} {
+			msg := receive(t, sub)
+			assert.Equal(t, payload, msg.Payload)
+			assert.Equal(t, seqs[i], msg.Seq)
+		}
+
+		resumed, err := n.SubscribeTopic("deploys", seqs[1])
+		if err != nil {
+			t.Fatal("SubscribeTopic error: ", err)
+		}
+		defer resumed.Close()
+		assert.Equal(t, "two", receive(t, resumed).Payload)
+		assert.Equal(t, "three", receive(t, resumed).Payload)
+	})
+}
+
+func TestPublishShouldRejectInvalidTopics(t *testing.T) {
+	n := newNotifier(t, nil)
+	for _, topic := range []string{"", "a/b", ".."} {
+		_, err := n.Publish(topic, "")
+		assert.Error(t, err, topic)
+	}
+}
+
+func TestJanitorOptionsShouldOnlyConfigureJanitors(t *testing.T) {
+	_, err := New(nil, "/test", WithRetention(time.Minute))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+
+	_, err = newNotifier(t, nil).NewJanitor("a", WithPruneInterval(0))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestJanitorShouldPruneOldMessages(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		n := newNotifier(t, s)
+		for i := 0; i < 5; i++ {
+			if _, err := n.Publish("deploys", "payload"); err != nil {
+				t.Fatal("Publish error: ", err)
+			}
+		}
+
+		j, err := n.NewJanitor("a", WithMaxMessages(2), WithPruneInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal("NewJanitor error: ", err)
+		}
+		if err := j.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer j.Close()
+
+		assert.Eventually(t, func() bool {
+			children, _, err := s.Children("/test/topics/deploys")
+			return err == nil && len(children) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,453 @@
+// Package pool manages several independent ZooKeeper sessions uniformly, with
+// the lifecycle of database/sql's connection pool: sessions are opened on
+// demand up to a limit, kept idle for reuse up to another, recycled once they
+// have lived or idled for too long, and checked before being handed out.
+// The pool aggregates the counters and events of its sessions, so that
+// applications needing several sessions, such as one per tenant or per
+// worker, monitor them as one.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/internal/eventbus"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultMaxIdle is how many idle sessions a pool keeps unless WithMaxIdle
+// is given, as with database/sql.
+const DefaultMaxIdle = 2
+
+// ErrPoolClosed is returned by Get once the pool is closed.
+var ErrPoolClosed = errors.New("session pool closed")
+
+// Connector opens the sessions of a pool.
+type Connector interface {
+	Connect(ctx context.Context) (session.Session, error)
+}
+
+// ConnectorFunc adapts a function to a Connector.
+type ConnectorFunc func(ctx context.Context) (session.Session, error)
+
+func (f ConnectorFunc) Connect(ctx context.Context) (session.Session, error) {
+	return f(ctx)
+}
+
+// NewConnector returns a Connector creating sessions with opts, as
+// session.NewSessionWithOpts does. Creating a session doesn't heed ctx.
+func NewConnector(opts ...session.SessionOpt) Connector {
+	return ConnectorFunc(func(context.Context) (session.Session, error) {
+		s, err := session.NewSessionWithOpts(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+type options struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+	healthCheck func(session.Session) error
+}
+
+// Option configures a Pool.
+type Option func(options) options
+
+// WithMaxOpen limits the sessions open at once, idle or in use, making Get
+// wait for one to be released beyond it. There is no limit by default.
+func WithMaxOpen(n int) Option {
+	return func(o options) options {
+		o.maxOpen = n
+		return o
+	}
+}
+
+// WithMaxIdle sets how many released sessions are kept open for reuse,
+// DefaultMaxIdle by default. Sessions released beyond it are closed.
+func WithMaxIdle(n int) Option {
+	return func(o options) options {
+		o.maxIdle = n
+		return o
+	}
+}
+
+// WithMaxLifetime closes sessions once they have been open for d, when next
+// released or found idle, so that long-lived processes spread their sessions
+// over the ensemble again after a server is replaced.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(o options) options {
+		o.maxLifetime = d
+		return o
+	}
+}
+
+// WithMaxIdleTime closes sessions once they have been idle for d.
+func WithMaxIdleTime(d time.Duration) Option {
+	return func(o options) options {
+		o.maxIdleTime = d
+		return o
+	}
+}
+
+// WithHealthCheck sets the check an idle session must pass to be handed out
+// by Get; sessions failing it are closed. It defaults to a cheap Exists call
+// on the root.
+func WithHealthCheck(check func(session.Session) error) Option {
+	return func(o options) options {
+		o.healthCheck = check
+		return o
+	}
+}
+
+func pingRoot(s session.Session) error {
+	_, err := s.Exists("/")
+	return err
+}
+
+// Pool hands out sessions opened with its Connector.
+type Pool struct {
+	// The counters are first to keep them 64-bit aligned for atomic access.
+	waitCount         uint64
+	waitDuration      int64
+	maxIdleClosed     uint64
+	maxLifetimeClosed uint64
+	maxIdleTimeClosed uint64
+	unhealthyClosed   uint64
+
+	connector Connector
+	opts      options
+	events    eventbus.Topic[Event]
+
+	mu      sync.Mutex
+	all     map[*Conn]struct{}
+	idle    []*Conn
+	open    int
+	waiters []chan *Conn
+	closed  bool
+	stop    chan struct{}
+}
+
+// New returns a pool of sessions opened by connector. Sessions are only
+// opened once needed.
+func New(connector Connector, opts ...Option) *Pool {
+	o := options{maxIdle: DefaultMaxIdle, healthCheck: pingRoot}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	p := &Pool{connector: connector, opts: o, all: map[*Conn]struct{}{}, stop: make(chan struct{})}
+	if interval := p.cleanInterval(); interval > 0 {
+		go p.cleaner(interval)
+	}
+	return p
+}
+
+// Get returns an idle session that passes the health check, or opens a new
+// one, waiting for a session to be released while WithMaxOpen sessions are
+// open. It gives up with ctx's error once ctx is done. The session must be
+// handed back with Release.
+func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	var waitStart time.Time
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if n := len(p.idle); n > 0 {
+			c := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			if p.retire(c, time.Now()) {
+				p.closeConn(c)
+				continue
+			}
+			if err := p.opts.healthCheck(c.session); err != nil {
+				atomic.AddUint64(&p.unhealthyClosed, 1)
+				p.closeConn(c)
+				continue
+			}
+			p.checkedOut(c, waitStart)
+			return c, nil
+		}
+		if p.opts.maxOpen <= 0 || p.open < p.opts.maxOpen {
+			p.open++
+			p.mu.Unlock()
+			c, err := p.connect(ctx)
+			if err != nil {
+				p.mu.Lock()
+				p.open--
+				p.wakeWaiter()
+				p.mu.Unlock()
+				return nil, err
+			}
+			p.checkedOut(c, waitStart)
+			return c, nil
+		}
+
+		// Released sessions are handed over, and a nil wakes the waiter to
+		// try again after a session was closed.
+		wait := make(chan *Conn, 1)
+		p.waiters = append(p.waiters, wait)
+		p.mu.Unlock()
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+			atomic.AddUint64(&p.waitCount, 1)
+		}
+		select {
+		case c := <-wait:
+			if c != nil {
+				p.checkedOut(c, waitStart)
+				return c, nil
+			}
+		case <-ctx.Done():
+			p.mu.Lock()
+			removed := p.removeWaiter(wait)
+			p.mu.Unlock()
+			if !removed {
+				// Woken meanwhile: pass it on.
+				if c := <-wait; c != nil {
+					p.release(c, false)
+				} else {
+					p.mu.Lock()
+					p.wakeWaiter()
+					p.mu.Unlock()
+				}
+			}
+			atomic.AddInt64(&p.waitDuration, int64(time.Since(waitStart)))
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) checkedOut(c *Conn, waitStart time.Time) {
+	if !waitStart.IsZero() {
+		atomic.AddInt64(&p.waitDuration, int64(time.Since(waitStart)))
+	}
+	atomic.StoreInt32(&c.released, 0)
+}
+
+// connect opens a session and forwards its events to the pool's.
+func (p *Pool) connect(ctx context.Context) (*Conn, error) {
+	s, err := p.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{pool: p, session: s, created: time.Now(), events: make(chan session.ZKSessionEvent), done: make(chan struct{}), released: 1}
+	s.Subscribe(c.events)
+	go c.forward()
+	p.mu.Lock()
+	p.all[c] = struct{}{}
+	p.mu.Unlock()
+	return c, nil
+}
+
+// retire reports whether c must be closed rather than reused at now, because
+// its session terminated or it outlived the pool's limits.
+func (p *Pool) retire(c *Conn, now time.Time) bool {
+	switch {
+	case c.broken():
+	case p.opts.maxLifetime > 0 && now.Sub(c.created) >= p.opts.maxLifetime:
+		atomic.AddUint64(&p.maxLifetimeClosed, 1)
+	case p.opts.maxIdleTime > 0 && !c.idleSince.IsZero() && now.Sub(c.idleSince) >= p.opts.maxIdleTime:
+		atomic.AddUint64(&p.maxIdleTimeClosed, 1)
+	default:
+		return false
+	}
+	return true
+}
+
+// release takes c back from its user.
+func (p *Pool) release(c *Conn, discard bool) {
+	c.idleSince = time.Time{}
+	if discard || p.retire(c, time.Now()) {
+		p.closeConn(c)
+		return
+	}
+
+	p.mu.Lock()
+	if len(p.waiters) > 0 {
+		wait := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		wait <- c
+		return
+	}
+	closed, full := p.closed, len(p.idle) >= p.opts.maxIdle
+	if !closed && !full {
+		c.idleSince = time.Now()
+		p.idle = append(p.idle, c)
+	}
+	p.mu.Unlock()
+	if closed || full {
+		if !closed {
+			atomic.AddUint64(&p.maxIdleClosed, 1)
+		}
+		p.closeConn(c)
+	}
+}
+
+// closeConn closes c's session and frees its place in the pool.
+func (p *Pool) closeConn(c *Conn) error {
+	err := c.session.Close()
+	close(c.done)
+	p.mu.Lock()
+	delete(p.all, c)
+	p.open--
+	p.wakeWaiter()
+	p.mu.Unlock()
+	return err
+}
+
+// wakeWaiter lets the first caller waiting in Get try again. p.mu must be
+// held.
+func (p *Pool) wakeWaiter() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	wait := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	wait <- nil
+}
+
+// removeWaiter removes wait, reporting false if it was already woken. p.mu
+// must be held.
+func (p *Pool) removeWaiter(wait chan *Conn) bool {
+	for i, w := range p.waiters {
+		if w == wait {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) cleanInterval() time.Duration {
+	interval := p.opts.maxLifetime
+	if d := p.opts.maxIdleTime; d > 0 && (interval <= 0 || d < interval) {
+		interval = d
+	}
+	if interval > 0 && interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// cleaner closes idle sessions past their limits every interval, until the
+// pool is closed.
+func (p *Pool) cleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			var retired []*Conn
+			p.mu.Lock()
+			kept := p.idle[:0]
+			for _, c := range p.idle {
+				if p.retire(c, now) {
+					retired = append(retired, c)
+				} else {
+					kept = append(kept, c)
+				}
+			}
+			p.idle = kept
+			p.mu.Unlock()
+			for _, c := range retired {
+				p.closeConn(c)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Close closes the idle sessions and makes Get fail with ErrPoolClosed.
+// Sessions in use are closed when released.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stop)
+	idle := p.idle
+	p.idle = nil
+	waiters := p.waiters
+	p.waiters = nil
+	p.mu.Unlock()
+
+	for _, wait := range waiters {
+		wait <- nil
+	}
+	var first error
+	for _, c := range idle {
+		if err := p.closeConn(c); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Conn is a session checked out of a Pool.
+type Conn struct {
+	pool    *Pool
+	session session.Session
+	created time.Time
+	events  chan session.ZKSessionEvent
+	done    chan struct{}
+	// terminated is set once the session reports a terminal event.
+	terminated int32
+	// released guards against releasing twice; idleSince is set while in
+	// the pool.
+	released  int32
+	idleSince time.Time
+}
+
+// Session returns the session, for use until the Conn is released.
+func (c *Conn) Session() session.Session {
+	return c.session
+}
+
+// Release hands the session back to the pool, which keeps it for reuse or
+// closes it. Releasing it again has no effect.
+func (c *Conn) Release() {
+	if atomic.CompareAndSwapInt32(&c.released, 0, 1) {
+		c.pool.release(c, false)
+	}
+}
+
+// Discard closes the session instead of handing it back, for sessions the
+// caller found broken.
+func (c *Conn) Discard() {
+	if atomic.CompareAndSwapInt32(&c.released, 0, 1) {
+		c.pool.release(c, true)
+	}
+}
+
+func (c *Conn) broken() bool {
+	return atomic.LoadInt32(&c.terminated) != 0
+}
+
+// forward delivers the session's events to the pool's subscribers until the
+// session is closed.
+func (c *Conn) forward() {
+	for {
+		select {
+		case event := <-c.events:
+			if event == session.SessionClosed || event == session.SessionFailed || event == session.SessionExpired {
+				atomic.StoreInt32(&c.terminated, 1)
+			}
+			c.pool.events.Publish(Event{Session: c.session, Event: event})
+		case <-c.done:
+			return
+		}
+	}
+}
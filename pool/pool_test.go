@@ -0,0 +1,186 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSession is a session whose only operation is the health check, which
+// fails once unhealthy is set.
+type fakeSession struct {
+	session.Session
+	mu          sync.Mutex
+	unhealthy   bool
+	closed      bool
+	subscribers []chan<- session.ZKSessionEvent
+	stats       session.Stats
+}
+
+func (f *fakeSession) Exists(string) (*zookeeper.Stat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unhealthy {
+		return nil, errors.New("unhealthy")
+	}
+	return &zookeeper.Stat{}, nil
+}
+
+func (f *fakeSession) Subscribe(ch chan<- session.ZKSessionEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, ch)
+}
+
+func (f *fakeSession) publish(event session.ZKSessionEvent) {
+	f.mu.Lock()
+	subscribers := f.subscribers
+	f.mu.Unlock()
+	for _, ch := range subscribers {
+		ch <- event
+	}
+}
+
+func (f *fakeSession) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	f.publish(session.SessionClosed)
+	return nil
+}
+
+func (f *fakeSession) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *fakeSession) Stats() session.Stats {
+	return f.stats
+}
+
+type fakeConnector struct {
+	mu       sync.Mutex
+	sessions []*fakeSession
+}
+
+func (c *fakeConnector) Connect(context.Context) (session.Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &fakeSession{stats: session.Stats{Ops: map[string]uint64{"get": 1}, Reconnects: 1}}
+	c.sessions = append(c.sessions, s)
+	return s, nil
+}
+
+func (c *fakeConnector) opened() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sessions)
+}
+
+func TestGetShouldReuseIdleSessions(t *testing.T) {
+	connector := &fakeConnector{}
+	p := New(connector, WithMaxIdle(1))
+	defer p.Close()
+
+	a, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	b, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NotSame(t, a.Session(), b.Session())
+
+	a.Release()
+	a.Release()
+	b.Release()
+	assert.True(t, b.Session().(*fakeSession).isClosed())
+
+	c, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, a.Session(), c.Session())
+	assert.Equal(t, 2, connector.opened())
+
+	st := p.Stats()
+	assert.Equal(t, 1, st.OpenSessions)
+	assert.Equal(t, 1, st.InUse)
+	assert.Equal(t, uint64(1), st.MaxIdleClosed)
+	assert.Equal(t, uint64(1), st.Sessions.Ops["get"])
+	c.Release()
+}
+
+func TestGetShouldCloseUnhealthyAndExpiredSessions(t *testing.T) {
+	connector := &fakeConnector{}
+	p := New(connector, WithMaxLifetime(time.Hour))
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	a.Release()
+	a.Session().(*fakeSession).unhealthy = true
+	b, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NotSame(t, a.Session(), b.Session())
+	assert.True(t, a.Session().(*fakeSession).isClosed())
+
+	b.created = time.Now().Add(-time.Hour)
+	b.Release()
+	assert.True(t, b.Session().(*fakeSession).isClosed())
+
+	st := p.Stats()
+	assert.Equal(t, uint64(1), st.UnhealthyClosed)
+	assert.Equal(t, uint64(1), st.MaxLifetimeClosed)
+	assert.Equal(t, 0, st.OpenSessions)
+}
+
+func TestGetShouldWaitForMaxOpen(t *testing.T) {
+	p := New(&fakeConnector{}, WithMaxOpen(1))
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := p.Get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	got := make(chan *Conn)
+	go func() {
+		c, err := p.Get(context.Background())
+		assert.NoError(t, err)
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	a.Release()
+	c := <-got
+	assert.Same(t, a.Session(), c.Session())
+	assert.Equal(t, uint64(2), p.Stats().WaitCount)
+
+	c.Discard()
+	c, err = p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NotSame(t, a.Session(), c.Session())
+	c.Release()
+}
+
+func TestPoolShouldForwardSessionEvents(t *testing.T) {
+	p := New(&fakeConnector{})
+	events := make(chan Event, 10)
+	p.Subscribe(events)
+
+	c, _ := p.Get(context.Background())
+	fake := c.Session().(*fakeSession)
+	fake.publish(session.SessionExpired)
+	assert.Equal(t, Event{Session: fake, Event: session.SessionExpired}, <-events)
+
+	// A terminated session isn't reused.
+	c.Release()
+	assert.True(t, fake.isClosed())
+	assert.Equal(t, session.SessionClosed, (<-events).Event)
+
+	assert.NoError(t, p.Close())
+	_, err := p.Get(context.Background())
+	assert.Equal(t, ErrPoolClosed, err)
+}
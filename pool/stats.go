@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Event is an event of one of a pool's sessions.
+type Event struct {
+	Session session.Session
+	Event   session.ZKSessionEvent
+}
+
+// Subscribe delivers the events of every session of the pool to
+// subscription, as the sessions' Subscribe does: in order for each session,
+// blocking the sessions until subscription receives them.
+func (p *Pool) Subscribe(subscription chan<- Event) {
+	p.events.Subscribe(subscription)
+}
+
+// Stats describe a pool, with the names of database/sql's DBStats.
+type Stats struct {
+	MaxOpenSessions int
+	OpenSessions    int
+	InUse           int
+	Idle            int
+	// WaitCount counts the calls to Get that waited for a session, whether
+	// they got one or gave up, for WaitDuration in total.
+	WaitCount    uint64
+	WaitDuration time.Duration
+	// MaxIdleClosed, MaxLifetimeClosed and MaxIdleTimeClosed count the
+	// sessions closed for going over WithMaxIdle, WithMaxLifetime and
+	// WithMaxIdleTime, and UnhealthyClosed those failing the health check.
+	MaxIdleClosed     uint64
+	MaxLifetimeClosed uint64
+	MaxIdleTimeClosed uint64
+	UnhealthyClosed   uint64
+	// Sessions sums the session.Stats of the open sessions that keep them,
+	// such as ZKSessions created with session.WithExpvar.
+	Sessions session.Stats
+}
+
+// Stats returns the pool's current state and counters.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	st := Stats{
+		MaxOpenSessions: p.opts.maxOpen,
+		OpenSessions:    p.open,
+		Idle:            len(p.idle),
+		InUse:           p.open - len(p.idle),
+	}
+	p.mu.Unlock()
+	st.WaitCount = atomic.LoadUint64(&p.waitCount)
+	st.WaitDuration = time.Duration(atomic.LoadInt64(&p.waitDuration))
+	st.MaxIdleClosed = atomic.LoadUint64(&p.maxIdleClosed)
+	st.MaxLifetimeClosed = atomic.LoadUint64(&p.maxLifetimeClosed)
+	st.MaxIdleTimeClosed = atomic.LoadUint64(&p.maxIdleTimeClosed)
+	st.UnhealthyClosed = atomic.LoadUint64(&p.unhealthyClosed)
+	st.Sessions = p.sessionStats()
+	return st
+}
+
+type statser interface {
+	Stats() session.Stats
+}
+
+// sessionStats sums the stats of the open sessions.
+func (p *Pool) sessionStats() session.Stats {
+	p.mu.Lock()
+	conns := make([]*Conn, 0, len(p.all))
+	for c := range p.all {
+		conns = append(conns, c)
+	}
+	p.mu.Unlock()
+
+	sum := session.Stats{Ops: map[string]uint64{}, Errors: map[string]uint64{}, InvalidReads: map[string]uint64{}}
+	for _, c := range conns {
+		s, ok := c.session.(statser)
+		if !ok {
+			continue
+		}
+		st := s.Stats()
+		for op, n := range st.Ops {
+			sum.Ops[op] += n
+		}
+		for code, n := range st.Errors {
+			sum.Errors[code] += n
+		}
+		for pattern, n := range st.InvalidReads {
+			sum.InvalidReads[pattern] += n
+		}
+		sum.Reconnects += st.Reconnects
+		sum.Watches += st.Watches
+		sum.AbandonedWatches += st.AbandonedWatches
+		sum.Subscriptions += st.Subscriptions
+	}
+	return sum
+}
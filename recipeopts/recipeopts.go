@@ -0,0 +1,273 @@
+// Package recipeopts holds the configuration recipes share, so that
+// recipes are configured the same way: a timeout for their operations, the
+// base path of their nodes, the codec of their values, a logger and metrics.
+//
+// Recipes keep their settings in an options struct embedding Options, take
+// Option, so that callers pass the shared options and the recipe's own ones
+// alike, and validate them before doing anything, following this shape:
+//
+//	type options struct {
+//		recipeopts.Options
+//		limit int
+//	}
+//
+//	type Option = recipeopts.Option
+//
+//	func WithLimit(limit int) Option {
+//		return recipeopts.Own(func(o *options) { o.limit = limit })
+//	}
+//
+//	func New(s session.Session, path string, opts ...Option) (*Recipe, error) {
+//		o := &options{Options: recipeopts.Defaults(path), limit: DefaultLimit}
+//		if err := recipeopts.Apply(o, opts...); err != nil {
+//			return nil, err
+//		}
+//		...
+//	}
+//
+// The node a recipe is given is its default base path, and mistakes such as
+// a relative base path, or another recipe's option, are reported by the
+// constructor rather than by the first write.
+//
+// Only recipes constructed over nodes of their own take Option. These keep
+// options of their own: functions taking options per call, such as those of
+// acl, bootstrap and discovery; the lock recipe, which predates this package;
+// the building blocks recipes are made of, such as watch and cache; and pool,
+// which configures sessions rather than nodes.
+package recipeopts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultTimeout bounds recipes' operations unless WithTimeout is given.
+const DefaultTimeout = 10 * time.Second
+
+// ErrInvalidOptions is wrapped by the error Validate returns for invalid
+// recipe options.
+var ErrInvalidOptions = errors.New("invalid recipe options")
+
+// Logger is the logging interface recipes log through, satisfied by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Metrics receives the outcome of every operation a recipe ends through
+// StartOperation, such as to feed a latency histogram by recipe and name.
+type Metrics interface {
+	ObserveOperation(recipe, name string, elapsed time.Duration, err error)
+}
+
+// Options are the settings every recipe shares. The zero value isn't valid;
+// recipes start from Defaults.
+type Options struct {
+	// Timeout bounds each of the recipe's operations, or none if zero.
+	Timeout time.Duration
+	// BasePath is the node the recipe keeps its nodes under.
+	BasePath string
+	// Codec encodes the recipe's structured values, session.JSONCodec by
+	// default.
+	Codec session.Codec
+	// Logger logs the recipe's failures, discarding them by default.
+	Logger Logger
+	// Metrics receives the outcome of the recipe's operations, if set.
+	Metrics Metrics
+}
+
+// Config is implemented by the options structs of recipes, through the
+// Options they embed.
+type Config interface {
+	shared() *Options
+}
+
+func (o *Options) shared() *Options {
+	return o
+}
+
+// Option configures a recipe: one of the shared options of this package, or
+// one of the recipe's own, made with Own.
+type Option func(Config) error
+
+// Own returns an option of the recipe whose options struct is T, setting its
+// own settings with set. Given to another recipe, it makes Apply fail.
+func Own[T Config](set func(T)) Option {
+	return func(c Config) error {
+		o, ok := c.(T)
+		if !ok {
+			var want T
+			return fmt.Errorf("option for %T given to a recipe configured by %T", want, c)
+		}
+		set(o)
+		return nil
+	}
+}
+
+// WithTimeout bounds each of the recipe's operations to timeout. Zero means
+// no bound.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c Config) error {
+		c.shared().Timeout = timeout
+		return nil
+	}
+}
+
+// WithBasePath keeps the recipe's nodes under path instead of its default
+// base path.
+func WithBasePath(path string) Option {
+	return func(c Config) error {
+		c.shared().BasePath = path
+		return nil
+	}
+}
+
+// WithCodec encodes the recipe's structured values with codec.
+func WithCodec(codec session.Codec) Option {
+	return func(c Config) error {
+		c.shared().Codec = codec
+		return nil
+	}
+}
+
+// WithLogger logs the recipe's failures through logger.
+func WithLogger(logger Logger) Option {
+	return func(c Config) error {
+		c.shared().Logger = logger
+		return nil
+	}
+}
+
+// WithMetrics reports the outcome of the recipe's operations to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(c Config) error {
+		c.shared().Metrics = metrics
+		return nil
+	}
+}
+
+// Defaults returns the options a recipe keeping its nodes under basePath
+// starts from: DefaultTimeout, session.JSONCodec and a logger discarding
+// everything.
+func Defaults(basePath string) Options {
+	return Options{
+		Timeout:  DefaultTimeout,
+		BasePath: basePath,
+		Codec:    session.JSONCodec,
+		Logger:   nullLogger{},
+	}
+}
+
+// Apply applies opts in order to config, which holds the recipe's defaults,
+// and validates the shared options, reporting every problem found.
+func Apply(config Config, opts ...Option) error {
+	var problems []string
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	problems = append(problems, config.shared().validate()...)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Validate checks the options, reporting every problem found.
+func (o Options) Validate() error {
+	if problems := o.validate(); len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (o Options) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if o.Timeout < 0 {
+		add("timeout must not be negative, got %s", o.Timeout)
+	}
+	if err := checkPath(o.BasePath); err != nil {
+		add("base path %q %s", o.BasePath, err)
+	}
+	if o.Codec == nil {
+		add("no codec specified")
+	}
+	if o.Logger == nil {
+		add("no logger specified")
+	}
+	return problems
+}
+
+// checkPath reports why path isn't a valid absolute znode path.
+func checkPath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("must be an absolute path")
+	}
+	if path == "/" {
+		return nil
+	}
+	if strings.HasSuffix(path, "/") {
+		return errors.New("must not end with a slash")
+	}
+	for _, segment := range strings.Split(path[1:], "/") {
+		switch segment {
+		case "":
+			return errors.New("must not have empty segments")
+		case ".", "..":
+			return errors.New("must not have relative segments")
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the node named by elems under the base path.
+func (o Options) Path(elems ...string) string {
+	p := o.BasePath
+	for _, elem := range elems {
+		if strings.HasSuffix(p, "/") {
+			p += elem
+		} else {
+			p += "/" + elem
+		}
+	}
+	return p
+}
+
+// Context returns a context bounded by the timeout, derived from parent.
+func (o Options) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, o.Timeout)
+}
+
+// StartOperation starts an operation like session.StartOperation, returning
+// it along with the function ending it, which also reports the outcome to
+// the Metrics and logs failures through the Logger.
+func (o Options) StartOperation(s session.Session, recipe, name, detail string) (*session.Operation, func(error)) {
+	op := session.StartOperation(s, recipe, name, detail)
+	return op, func(err error) {
+		op.End(err)
+		elapsed := time.Since(op.Start)
+		if err != nil && o.Logger != nil {
+			o.Logger.Printf("gozk-recipes/%s: %s %s failed after %s: %v", recipe, name, detail, elapsed.Round(time.Millisecond), err)
+		}
+		if o.Metrics != nil {
+			o.Metrics.ObserveOperation(recipe, name, elapsed, err)
+		}
+	}
+}
+
+type nullLogger struct{}
+
+func (nullLogger) Printf(format string, v ...interface{}) {}
@@ -0,0 +1,91 @@
+package recipeopts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+type recordingMetrics struct {
+	ops []string
+}
+
+func (m *recordingMetrics) ObserveOperation(recipe, name string, elapsed time.Duration, err error) {
+	m.ops = append(m.ops, fmt.Sprintf("%s/%s: %v", recipe, name, err))
+}
+
+type recipeOptions struct {
+	Options
+	limit int
+}
+
+func withLimit(limit int) Option {
+	return Own(func(o *recipeOptions) { o.limit = limit })
+}
+
+type otherOptions struct {
+	Options
+}
+
+func TestApplyShouldStartFromDefaults(t *testing.T) {
+	o := &recipeOptions{Options: Defaults("/recipe"), limit: 1}
+	assert.NoError(t, Apply(o))
+	assert.Equal(t, DefaultTimeout, o.Timeout)
+	assert.Equal(t, "/recipe", o.BasePath)
+	assert.Equal(t, session.JSONCodec, o.Codec)
+	assert.Nil(t, o.Metrics)
+	assert.Equal(t, 1, o.limit)
+
+	o = &recipeOptions{Options: Defaults("/recipe")}
+	assert.NoError(t, Apply(o, WithTimeout(time.Second), WithBasePath("/apps/web"), withLimit(2)))
+	assert.Equal(t, time.Second, o.Timeout)
+	assert.Equal(t, 2, o.limit)
+	assert.Equal(t, "/apps/web/locks/a", o.Path("locks", "a"))
+	assert.Equal(t, "/a", Options{BasePath: "/"}.Path("a"))
+}
+
+func TestApplyShouldReportEveryProblem(t *testing.T) {
+	err := Apply(&recipeOptions{Options: Defaults("/recipe")}, WithTimeout(-time.Second), WithBasePath("apps/"), WithCodec(nil))
+	assert.True(t, errors.Is(err, ErrInvalidOptions))
+	assert.Equal(t, `invalid recipe options: timeout must not be negative, got -1s; base path "apps/" must be an absolute path; no codec specified`, err.Error())
+
+	for _, path := range []string{"/apps/", "/apps//web", "/apps/../web"} {
+		o := Defaults(path)
+		assert.Error(t, Apply(&o), path)
+	}
+}
+
+func TestApplyShouldRejectOptionsOfOtherRecipes(t *testing.T) {
+	err := Apply(&otherOptions{Options: Defaults("/recipe")}, withLimit(2))
+	assert.True(t, errors.Is(err, ErrInvalidOptions))
+	assert.Equal(t, "invalid recipe options: option for *recipeopts.recipeOptions given to a recipe configured by *recipeopts.otherOptions", err.Error())
+}
+
+func TestStartOperationShouldReportOutcome(t *testing.T) {
+	logger, metrics := &recordingLogger{}, &recordingMetrics{}
+	o := Defaults("/recipe")
+	assert.NoError(t, Apply(&o, WithLogger(logger), WithMetrics(metrics)))
+
+	_, end := o.StartOperation(nil, "recipe", "get", "/recipe/a")
+	end(nil)
+	_, end = o.StartOperation(nil, "recipe", "set", "/recipe/a")
+	end(errors.New("spam"))
+
+	assert.Equal(t, []string{"recipe/get: <nil>", "recipe/set: spam"}, metrics.ops)
+	if assert.Len(t, logger.lines, 1) {
+		assert.True(t, strings.HasPrefix(logger.lines[0], "gozk-recipes/recipe: set /recipe/a failed after"))
+	}
+}
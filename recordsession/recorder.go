@@ -0,0 +1,169 @@
+package recordsession
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Recorder wraps a session.Session, writing every operation made through it
+// to a recording.
+type Recorder struct {
+	session.Session
+
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	seq     int
+	err     error
+}
+
+var _ session.Session = (*Recorder)(nil)
+
+// NewRecorder wraps s, recording to the file at path, which is truncated.
+func NewRecorder(s session.Session, path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{Session: s, file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// record completes i with its sequence number and writes it out.
+func (r *Recorder) record(i *Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	i.Seq = r.seq
+	if r.err == nil {
+		r.err = r.encoder.Encode(i)
+	}
+}
+
+// watch records the event w fires with against i, which must be recorded
+// before w fires, and forwards it.
+func (r *Recorder) watch(i *Interaction, w <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if w == nil {
+		return nil
+	}
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		event, ok := <-w
+		if ok {
+			r.mu.Lock()
+			fired := Interaction{Op: "watch", Path: i.Path, Watch: &Watch{For: i.Seq, After: r.seq, Event: event}}
+			if r.err == nil {
+				r.err = r.encoder.Encode(&fired)
+			}
+			r.mu.Unlock()
+			forwarded <- event
+		}
+		close(forwarded)
+	}()
+	return forwarded
+}
+
+// Close closes the wrapped session and the recording, returning the first
+// error writing it.
+func (r *Recorder) Close() error {
+	err := r.Session.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cerr := r.file.Close(); r.err == nil {
+		r.err = cerr
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+func (r *Recorder) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	acl, stat, err := r.Session.ACL(path)
+	r.record(&Interaction{Op: "acl", Path: path, Result: acl, Stat: recordStat(stat), Err: recordError(err)})
+	return acl, stat, err
+}
+
+func (r *Recorder) AddAuth(scheme, cert string) error {
+	err := r.Session.AddAuth(scheme, cert)
+	r.record(&Interaction{Op: "addAuth", Path: scheme, Err: recordError(err)})
+	return err
+}
+
+func (r *Recorder) Children(path string) ([]string, *zookeeper.Stat, error) {
+	children, stat, err := r.Session.Children(path)
+	r.record(&Interaction{Op: "children", Path: path, Children: children, Stat: recordStat(stat), Err: recordError(err)})
+	return children, stat, err
+}
+
+func (r *Recorder) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	children, stat, w, err := r.Session.ChildrenW(path)
+	i := &Interaction{Op: "childrenW", Path: path, Children: children, Stat: recordStat(stat), Err: recordError(err)}
+	r.record(i)
+	return children, stat, r.watch(i, w), err
+}
+
+func (r *Recorder) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	created, err := r.Session.Create(path, value, flags, aclv)
+	r.record(&Interaction{Op: "create", Path: path, Value: value, Flags: flags, ACL: aclv, Created: created, Err: recordError(err)})
+	return created, err
+}
+
+func (r *Recorder) Delete(path string, version int) error {
+	err := r.Session.Delete(path, version)
+	r.record(&Interaction{Op: "delete", Path: path, Version: version, Err: recordError(err)})
+	return err
+}
+
+func (r *Recorder) Exists(path string) (*zookeeper.Stat, error) {
+	stat, err := r.Session.Exists(path)
+	r.record(&Interaction{Op: "exists", Path: path, Stat: recordStat(stat), Err: recordError(err)})
+	return stat, err
+}
+
+func (r *Recorder) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	stat, w, err := r.Session.ExistsW(path)
+	i := &Interaction{Op: "existsW", Path: path, Stat: recordStat(stat), Err: recordError(err)}
+	r.record(i)
+	return stat, r.watch(i, w), err
+}
+
+func (r *Recorder) Get(path string) (string, *zookeeper.Stat, error) {
+	data, stat, err := r.Session.Get(path)
+	r.record(&Interaction{Op: "get", Path: path, Data: data, Stat: recordStat(stat), Err: recordError(err)})
+	return data, stat, err
+}
+
+func (r *Recorder) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	data, stat, w, err := r.Session.GetW(path)
+	i := &Interaction{Op: "getW", Path: path, Data: data, Stat: recordStat(stat), Err: recordError(err)}
+	r.record(i)
+	return data, stat, r.watch(i, w), err
+}
+
+func (r *Recorder) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	stat, err := r.Session.Set(path, value, version)
+	r.record(&Interaction{Op: "set", Path: path, Value: value, Version: version, Stat: recordStat(stat), Err: recordError(err)})
+	return stat, err
+}
+
+// RetryChange records the value changeFunc was last called with as the
+// interaction's data, so replaying can call it with the same value.
+func (r *Recorder) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	var last string
+	err := r.Session.RetryChange(path, flags, acl, func(oldValue string, oldStat *zookeeper.Stat) (string, error) {
+		last = oldValue
+		return changeFunc(oldValue, oldStat)
+	})
+	r.record(&Interaction{Op: "retryChange", Path: path, Flags: flags, ACL: acl, Data: last, Err: recordError(err)})
+	return err
+}
+
+func (r *Recorder) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	err := r.Session.SetACL(path, aclv, version)
+	r.record(&Interaction{Op: "setACL", Path: path, ACL: aclv, Version: version, Err: recordError(err)})
+	return err
+}
@@ -0,0 +1,136 @@
+// Package recordsession records the operations made on a session.Session to a
+// file and replays them later without ZooKeeper, for fast and deterministic
+// tests of code paths that are hard to reach against a live ensemble.
+//
+// A recording is a file of JSON lines, one per interaction: the operation and
+// its arguments, and the result ZooKeeper returned. When replaying, each call
+// is answered with the next recorded interaction having the same operation
+// and arguments, so concurrent callers don't need to interleave exactly as
+// they did while recording.
+//
+// Watches are replayed too: a watch fires with its recorded event once the
+// replay has served as many interactions as had been recorded when it fired.
+//
+// The client's Stat type can't be built outside the zookeeper package, so
+// replayed operations return an empty placeholder Stat wherever the recording
+// had one: every field reads as zero. The recorded Stat fields are kept in
+// the file for inspection only. Code whose behaviour depends on Stat fields,
+// such as comparing versions or ephemeral owners, can't be replayed
+// faithfully, and conditional writes, whose version would come from a
+// recorded Stat, are rejected with ErrVersionNotReplayed rather than answered
+// from a recording they can't have matched.
+package recordsession
+
+import (
+	"encoding/json"
+	"errors"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrNotRecorded is returned when replaying a call that has no matching
+// interaction left in the recording.
+var ErrNotRecorded = errors.New("interaction not recorded")
+
+// ErrVersionNotReplayed is returned when replaying a Set, Delete or SetACL
+// conditional on a version, since replayed Stats don't carry versions.
+var ErrVersionNotReplayed = errors.New("conditional writes can't be replayed: replayed Stats carry no versions")
+
+// Interaction is one line of a recording.
+type Interaction struct {
+	Seq  int    `json:"seq"`
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"`
+
+	// Arguments.
+	Value   string          `json:"value,omitempty"`
+	Flags   int             `json:"flags,omitempty"`
+	Version int             `json:"version,omitempty"`
+	ACL     []zookeeper.ACL `json:"acl,omitempty"`
+
+	// Results.
+	Data     string          `json:"data,omitempty"`
+	Children []string        `json:"children,omitempty"`
+	Created  string          `json:"created,omitempty"`
+	Result   []zookeeper.ACL `json:"result,omitempty"`
+	Stat     *Stat           `json:"stat,omitempty"`
+	Err      *Error          `json:"err,omitempty"`
+
+	// Watch is set on the lines recording a watch firing, whose Op is
+	// "watch".
+	Watch *Watch `json:"watch,omitempty"`
+}
+
+// Stat records the fields of a zookeeper.Stat.
+type Stat struct {
+	Czxid          int64 `json:"czxid"`
+	Mzxid          int64 `json:"mzxid"`
+	Pzxid          int64 `json:"pzxid"`
+	Version        int   `json:"version"`
+	CVersion       int   `json:"cversion"`
+	AVersion       int   `json:"aversion"`
+	EphemeralOwner int64 `json:"ephemeralOwner"`
+	DataLength     int   `json:"dataLength"`
+	NumChildren    int   `json:"numChildren"`
+}
+
+// Error records an error returned by an operation.
+type Error struct {
+	Code    zookeeper.ErrorCode `json:"code,omitempty"`
+	Op      string              `json:"op,omitempty"`
+	Path    string              `json:"path,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// Watch records the event a watch fired with, the sequence number of the
+// interaction that set it, and how many interactions had been recorded when
+// it fired.
+type Watch struct {
+	For   int             `json:"for"`
+	After int             `json:"after"`
+	Event zookeeper.Event `json:"event"`
+}
+
+// key identifies the calls an interaction can answer.
+func (i *Interaction) key() string {
+	b, _ := json.Marshal(Interaction{Op: i.Op, Path: i.Path, Value: i.Value, Flags: i.Flags, Version: i.Version, ACL: i.ACL})
+	return string(b)
+}
+
+func recordStat(stat *zookeeper.Stat) *Stat {
+	if stat == nil {
+		return nil
+	}
+	return &Stat{
+		Czxid:          stat.Czxid(),
+		Mzxid:          stat.Mzxid(),
+		Pzxid:          stat.Pzxid(),
+		Version:        stat.Version(),
+		CVersion:       stat.CVersion(),
+		AVersion:       stat.AVersion(),
+		EphemeralOwner: stat.EphemeralOwner(),
+		DataLength:     stat.DataLength(),
+		NumChildren:    stat.NumChildren(),
+	}
+}
+
+func recordError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var zkErr *zookeeper.Error
+	if errors.As(err, &zkErr) {
+		return &Error{Code: zkErr.Code, Op: zkErr.Op, Path: zkErr.Path}
+	}
+	return &Error{Message: err.Error()}
+}
+
+func (e *Error) err() error {
+	if e == nil {
+		return nil
+	}
+	if e.Message != "" {
+		return errors.New(e.Message)
+	}
+	return &zookeeper.Error{Op: e.Op, Code: e.Code, Path: e.Path}
+}
@@ -0,0 +1,98 @@
+package recordsession
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSession answers a few operations without talking to ZooKeeper.
+type stubSession struct {
+	session.Session
+}
+
+func (stubSession) Get(path string) (string, *zookeeper.Stat, error) {
+	return "data", &zookeeper.Stat{}, nil
+}
+
+func (stubSession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	return "", &zookeeper.Error{Op: "create", Code: zookeeper.ZNODEEXISTS, Path: path}
+}
+
+func (stubSession) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	w := make(chan zookeeper.Event, 1)
+	w <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: path}
+	return &zookeeper.Stat{}, w, nil
+}
+
+func (stubSession) Close() error { return nil }
+
+func TestReplayShouldAnswerFromRecording(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "recording.jsonl")
+	r, err := NewRecorder(stubSession{}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Get("/foo")
+	r.Create("/foo", "bar", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	_, w, _ := r.ExistsW("/foo")
+	<-w
+	if err := r.Close(); err != nil {
+		t.Fatal("Close error: ", err)
+	}
+
+	replay, err := NewReplayer(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, replay.Remaining())
+
+	_, err = replay.Create("/foo", "bar", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	assert.True(t, zookeeper.IsError(err, zookeeper.ZNODEEXISTS))
+
+	data, stat, err := replay.Get("/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", data)
+	assert.NotNil(t, stat)
+
+	_, w, err = replay.ExistsW("/foo")
+	assert.NoError(t, err)
+	select {
+	case event := <-w:
+		assert.Equal(t, zookeeper.EVENT_CHANGED, event.Type)
+	case <-time.After(time.Second):
+		t.Fatal("Expected replayed watch to fire")
+	}
+	assert.Equal(t, 0, replay.Remaining())
+
+	_, _, err = replay.Get("/foo")
+	assert.True(t, errors.Is(err, ErrNotRecorded))
+}
+
+func TestReplayShouldRejectConditionalWrites(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "recording.jsonl")
+	r, err := NewRecorder(stubSession{}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal("Close error: ", err)
+	}
+
+	replay, err := NewReplayer(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replay.Set("/foo", "bar", 3)
+	assert.ErrorIs(t, err, ErrVersionNotReplayed)
+	assert.ErrorIs(t, replay.Delete("/foo", 3), ErrVersionNotReplayed)
+	assert.ErrorIs(t, replay.SetACL("/foo", zookeeper.WorldACL(zookeeper.PERM_ALL), 0), ErrVersionNotReplayed)
+
+	_, err = replay.Set("/foo", "bar", -1)
+	assert.ErrorIs(t, err, ErrNotRecorded)
+}
@@ -0,0 +1,249 @@
+package recordsession
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Replayer is a session.Session answering calls from a recording made with a
+// Recorder.
+type Replayer struct {
+	mu      sync.Mutex
+	queues  map[string][]*Interaction
+	watches map[int]*Watch
+	pending []pendingWatch
+	served  int
+}
+
+type pendingWatch struct {
+	watch *Watch
+	ch    chan zookeeper.Event
+}
+
+var _ session.Session = (*Replayer)(nil)
+
+// NewReplayer loads the recording at path.
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := &Replayer{queues: make(map[string][]*Interaction), watches: make(map[int]*Watch)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		i := &Interaction{}
+		if err := json.Unmarshal(scanner.Bytes(), i); err != nil {
+			return nil, fmt.Errorf("reading recording %q line %d: %w", path, line, err)
+		}
+		if i.Watch != nil {
+			r.watches[i.Watch.For] = i.Watch
+			continue
+		}
+		k := i.key()
+		r.queues[k] = append(r.queues[k], i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading recording %q: %w", path, err)
+	}
+	return r, nil
+}
+
+// Remaining returns the number of recorded interactions not replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := 0
+	for _, queue := range r.queues {
+		remaining += len(queue)
+	}
+	return remaining
+}
+
+// serve returns the next interaction answering call, and a watch channel for
+// it if watched is set.
+func (r *Replayer) serve(call Interaction, watched bool) (*Interaction, <-chan zookeeper.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := call.key()
+	queue := r.queues[k]
+	if len(queue) == 0 {
+		return nil, nil, fmt.Errorf("replaying %s %q: %w", call.Op, call.Path, ErrNotRecorded)
+	}
+	i := queue[0]
+	r.queues[k] = queue[1:]
+	r.served++
+
+	var ch chan zookeeper.Event
+	if watched && i.Err == nil {
+		ch = make(chan zookeeper.Event, 1)
+		if w, ok := r.watches[i.Seq]; ok {
+			r.pending = append(r.pending, pendingWatch{watch: w, ch: ch})
+		} else {
+			// Never fired while recording.
+			r.pending = append(r.pending, pendingWatch{ch: ch})
+		}
+	}
+	r.fire()
+	return i, ch, i.Err.err()
+}
+
+// fire delivers the pending watches that had fired by this point of the
+// recording.
+func (r *Replayer) fire() {
+	pending := r.pending[:0]
+	for _, p := range r.pending {
+		if p.watch != nil && p.watch.After <= r.served {
+			p.ch <- p.watch.Event
+			close(p.ch)
+			continue
+		}
+		pending = append(pending, p)
+	}
+	r.pending = pending
+}
+
+// unconditional rejects replaying op at version, unless it's -1.
+func unconditional(op, path string, version int) error {
+	if version == -1 {
+		return nil
+	}
+	return fmt.Errorf("replaying %s %q at version %d: %w", op, path, version, ErrVersionNotReplayed)
+}
+
+// stat returns the placeholder Stat if i recorded one; see the package
+// documentation.
+func stat(i *Interaction) *zookeeper.Stat {
+	if i == nil || i.Stat == nil {
+		return nil
+	}
+	return &zookeeper.Stat{}
+}
+
+func (r *Replayer) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	i, _, err := r.serve(Interaction{Op: "acl", Path: path}, false)
+	if i == nil {
+		return nil, nil, err
+	}
+	return i.Result, stat(i), err
+}
+
+func (r *Replayer) AddAuth(scheme, cert string) error {
+	_, _, err := r.serve(Interaction{Op: "addAuth", Path: scheme}, false)
+	return err
+}
+
+func (r *Replayer) Children(path string) ([]string, *zookeeper.Stat, error) {
+	i, _, err := r.serve(Interaction{Op: "children", Path: path}, false)
+	if i == nil {
+		return nil, nil, err
+	}
+	return i.Children, stat(i), err
+}
+
+func (r *Replayer) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	i, w, err := r.serve(Interaction{Op: "childrenW", Path: path}, true)
+	if i == nil {
+		return nil, nil, nil, err
+	}
+	return i.Children, stat(i), w, err
+}
+
+// ClientId returns an empty client id; session ids aren't recorded.
+func (r *Replayer) ClientId() *zookeeper.ClientId {
+	return &zookeeper.ClientId{}
+}
+
+// Close closes the watches that never fired, as closing a session does.
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.pending {
+		close(p.ch)
+	}
+	r.pending = nil
+	return nil
+}
+
+func (r *Replayer) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	i, _, err := r.serve(Interaction{Op: "create", Path: path, Value: value, Flags: flags, ACL: aclv}, false)
+	if i == nil {
+		return "", err
+	}
+	return i.Created, err
+}
+
+func (r *Replayer) Delete(path string, version int) error {
+	if err := unconditional("delete", path, version); err != nil {
+		return err
+	}
+	_, _, err := r.serve(Interaction{Op: "delete", Path: path, Version: version}, false)
+	return err
+}
+
+func (r *Replayer) Exists(path string) (*zookeeper.Stat, error) {
+	i, _, err := r.serve(Interaction{Op: "exists", Path: path}, false)
+	return stat(i), err
+}
+
+func (r *Replayer) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	i, w, err := r.serve(Interaction{Op: "existsW", Path: path}, true)
+	return stat(i), w, err
+}
+
+func (r *Replayer) Get(path string) (string, *zookeeper.Stat, error) {
+	i, _, err := r.serve(Interaction{Op: "get", Path: path}, false)
+	if i == nil {
+		return "", nil, err
+	}
+	return i.Data, stat(i), err
+}
+
+func (r *Replayer) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	i, w, err := r.serve(Interaction{Op: "getW", Path: path}, true)
+	if i == nil {
+		return "", nil, nil, err
+	}
+	return i.Data, stat(i), w, err
+}
+
+func (r *Replayer) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	if err := unconditional("set", path, version); err != nil {
+		return nil, err
+	}
+	i, _, err := r.serve(Interaction{Op: "set", Path: path, Value: value, Version: version}, false)
+	return stat(i), err
+}
+
+// RetryChange calls changeFunc with the value it was last called with while
+// recording, and the placeholder Stat, discarding its result.
+func (r *Replayer) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	i, _, err := r.serve(Interaction{Op: "retryChange", Path: path, Flags: flags, ACL: acl}, false)
+	if i == nil {
+		return err
+	}
+	if _, cerr := changeFunc(i.Data, &zookeeper.Stat{}); cerr != nil && err == nil {
+		return cerr
+	}
+	return err
+}
+
+func (r *Replayer) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	if err := unconditional("setACL", path, version); err != nil {
+		return err
+	}
+	_, _, err := r.serve(Interaction{Op: "setACL", Path: path, ACL: aclv, Version: version}, false)
+	return err
+}
+
+// Subscribe is a no-op: session events aren't recorded.
+func (r *Replayer) Subscribe(subscription chan<- session.ZKSessionEvent) {}
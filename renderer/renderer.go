@@ -0,0 +1,271 @@
+// Package renderer keeps a local file rendered from ZooKeeper data: it
+// watches a znode, or a whole subtree, executes a template against its
+// content whenever it changes and writes the result to a file, optionally
+// running a command afterwards, such as reloading a proxy to pick up its new
+// configuration.
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/watch"
+)
+
+// Data is what the template is executed against.
+type Data struct {
+	// Path is the watched path.
+	Path string
+	// Exists and Value describe the watched node.
+	Exists bool
+	Value  string
+	// Nodes maps the path of every node in the subtree, including the root,
+	// to its data. It is only set with WithSubtree.
+	Nodes map[string]string
+}
+
+type options struct {
+	recipeopts.Options
+	subtree  bool
+	command  []string
+	debounce time.Duration
+	mode     os.FileMode
+	onError  func(error)
+}
+
+// Option configures a Renderer: one of the options below, or one of the
+// options every recipe shares from recipeopts. The base path is the watched
+// path, and failures are logged as well as handed to the error handler.
+type Option = recipeopts.Option
+
+// WithSubtree watches every node under the path, making them available to the
+// template as Data.Nodes.
+func WithSubtree() Option {
+	return recipeopts.Own(func(o *options) { o.subtree = true })
+}
+
+// WithCommand runs name with args after every render that changed the file.
+func WithCommand(name string, args ...string) Option {
+	return recipeopts.Own(func(o *options) { o.command = append([]string{name}, args...) })
+}
+
+// WithDebounce waits for changes to settle for d before rendering, so a burst
+// of changes results in a single render and command run.
+func WithDebounce(d time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.debounce = d })
+}
+
+// WithFileMode sets the permissions of the rendered file, 0644 by default.
+func WithFileMode(mode os.FileMode) Option {
+	return recipeopts.Own(func(o *options) { o.mode = mode })
+}
+
+// WithErrorHandler is called with errors executing the template, writing the
+// file or running the command. The previous file is left in place on errors.
+func WithErrorHandler(onError func(error)) Option {
+	return recipeopts.Own(func(o *options) { o.onError = onError })
+}
+
+// Renderer renders a file from a znode or subtree.
+type Renderer struct {
+	session session.Session
+	path    string
+	tmpl    *template.Template
+	dest    string
+	opts    options
+
+	watcher *watch.Watcher
+	cache   *cache.TreeCache
+	last    []byte
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a Renderer writing tmpl executed against the data at path, the
+// default base path, to dest, or fails if opts are invalid.
+func New(s session.Session, path string, tmpl *template.Template, dest string, opts ...Option) (*Renderer, error) {
+	o := &options{Options: recipeopts.Defaults(path), mode: 0o644, onError: func(error) {}}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+
+	return &Renderer{
+		session: s,
+		path:    o.BasePath,
+		tmpl:    tmpl,
+		dest:    dest,
+		opts:    *o,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start renders the file once the data has been read, and keeps rendering it
+// in the background until Close is called.
+func (r *Renderer) Start() {
+	var events <-chan watch.Event
+	var diffs <-chan cache.Diff
+	if r.opts.subtree {
+		r.cache = cache.NewTreeCache(r.session, r.path)
+		r.cache.Start()
+		diffs = r.cache.DiffStream(r.path)
+	} else {
+		r.watcher = watch.New(r.session, r.path)
+		r.watcher.Start()
+		events = r.watcher.Events()
+	}
+	session.Go(r.session, "renderer", func() {
+		r.run(events, diffs)
+	})
+}
+
+// Close stops rendering. The rendered file is left in place.
+func (r *Renderer) Close() {
+	close(r.stop)
+	<-r.done
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+	if r.cache != nil {
+		r.cache.Close()
+	}
+}
+
+// Run starts the renderer and renders until ctx is done, closing it and
+// returning ctx.Err(), or until it stops otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (r *Renderer) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		r.Start()
+		return nil
+	}, r.done, func() error {
+		r.Close()
+		return nil
+	})
+}
+
+func (r *Renderer) run(events <-chan watch.Event, diffs <-chan cache.Diff) {
+	detach := session.Attach(r.session, "renderer", r.path)
+	defer detach()
+	defer close(r.done)
+
+	data := Data{Path: r.path}
+	if r.opts.subtree {
+		data.Nodes = make(map[string]string)
+	}
+	ready := false
+
+	var timer *time.Timer
+	var settled <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data.Exists, data.Value = event.Exists, event.Data
+			ready = true
+		case diff, ok := <-diffs:
+			if !ok {
+				return
+			}
+			switch d := diff.(type) {
+			case cache.NodeCreated:
+				data.Nodes[d.Path] = d.Data
+			case cache.NodeUpdated:
+				data.Nodes[d.Path] = d.New.Data
+			case cache.NodeDeleted:
+				delete(data.Nodes, d.Path)
+			case cache.InitialSyncDone:
+				ready = true
+			}
+			data.Value, data.Exists = data.Nodes[r.path]
+		case <-settled:
+			settled = nil
+			r.render(data)
+			continue
+		case <-r.stop:
+			return
+		}
+
+		switch {
+		case !ready:
+		case r.opts.debounce <= 0:
+			r.render(data)
+		case settled == nil:
+			timer = time.NewTimer(r.opts.debounce)
+			settled = timer.C
+		default:
+			timer.Reset(r.opts.debounce)
+		}
+	}
+}
+
+// render writes the file if its content changed, then runs the command.
+func (r *Renderer) render(data Data) {
+	var out bytes.Buffer
+	if err := r.tmpl.Execute(&out, data); err != nil {
+		r.fail(fmt.Errorf("rendering %q: %w", r.dest, err))
+		return
+	}
+	if r.last != nil && bytes.Equal(out.Bytes(), r.last) {
+		return
+	}
+
+	if err := writeAtomic(r.dest, out.Bytes(), r.opts.mode); err != nil {
+		r.fail(fmt.Errorf("writing %q: %w", r.dest, err))
+		return
+	}
+	r.last = out.Bytes()
+
+	if len(r.opts.command) > 0 {
+		cmd := exec.Command(r.opts.command[0], r.opts.command[1:]...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			r.fail(fmt.Errorf("running %q: %w: %s", r.opts.command[0], err, output))
+		}
+	}
+}
+
+func (r *Renderer) fail(err error) {
+	r.opts.Logger.Printf("gozk-recipes/renderer: %v", err)
+	_ = session.Protect(r.session, "renderer", func() { r.opts.onError(err) })
+}
+
+// writeAtomic replaces the file at path with data through a rename, so
+// readers never see a partially written file.
+func writeAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
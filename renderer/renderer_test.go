@@ -0,0 +1,78 @@
+package renderer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRenderer(t *testing.T, s session.Session, tmpl *template.Template, dest string, opts ...Option) *Renderer {
+	r, err := New(s, "/test", tmpl, dest, opts...)
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return r
+}
+
+func TestNewShouldRejectInvalidOptions(t *testing.T) {
+	_, err := New(nil, "/test/", nil, "out.conf", WithSubtree())
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func contents(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestRendererShouldRenderNode(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "a", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+		dest, marker := filepath.Join(dir, "out.conf"), filepath.Join(dir, "reloaded")
+		tmpl := template.Must(template.New("").Parse("value={{.Value}}"))
+		r := newRenderer(t, s, tmpl, dest, WithCommand("touch", marker))
+		r.Start()
+		defer r.Close()
+
+		assert.Eventually(t, func() bool { return contents(dest) == "value=a" }, 5*time.Second, 10*time.Millisecond)
+
+		if _, err := s.Set("/test", "b", -1); err != nil {
+			t.Fatal(err)
+		}
+		assert.Eventually(t, func() bool { return contents(dest) == "value=b" }, 5*time.Second, 10*time.Millisecond)
+		_, err := os.Stat(marker)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRendererShouldRenderSubtree(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		for _, p := range []string{"/test", "/test/a", "/test/b"} {
+			if _, err := s.Create(p, p, 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dest := filepath.Join(t.TempDir(), "out.conf")
+		tmpl := template.Must(template.New("").Parse(`{{range $path, $data := .Nodes}}{{$path}}={{$data}};{{end}}`))
+		r := newRenderer(t, s, tmpl, dest, WithSubtree(), WithDebounce(50*time.Millisecond))
+		r.Start()
+		defer r.Close()
+
+		assert.Eventually(t, func() bool {
+			return contents(dest) == "/test=/test;/test/a=/test/a;/test/b=/test/b;"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
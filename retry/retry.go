@@ -0,0 +1,194 @@
+// Package retry holds the backoff and jitter utilities the recipes retry and
+// wait with, so that they, and applications, share one implementation: a
+// Policy describing an exponential backoff, a Backoff stepping through one,
+// context-aware Sleep and Do, and a process-wide random source for jitter.
+//
+// Sessions carry a policy of their own, set with session.WithRetryPolicy and
+// read with session.RetryPolicyOf, so a single option tunes how every recipe
+// using the session backs off.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Jitter is how much of each wait of a Backoff is randomized.
+type Jitter int
+
+const (
+	// NoJitter waits exactly the backoff.
+	NoJitter Jitter = iota
+	// FullJitter waits a random time up to the backoff, spreading contending
+	// clients out the most.
+	FullJitter
+	// EqualJitter waits half the backoff plus a random time up to the other
+	// half, spreading clients out while still waiting at least half.
+	EqualJitter
+)
+
+// Policy describes an exponential backoff: the first wait is Initial, and
+// each one after is Multiplier times as long, up to Max, each randomized
+// according to Jitter. The zero Policy doesn't wait at all.
+type Policy struct {
+	Initial time.Duration
+	Max     time.Duration
+	// Multiplier is 2 if zero.
+	Multiplier float64
+	Jitter     Jitter
+}
+
+// DefaultPolicy is the policy of sessions given no WithRetryPolicy, for the
+// recipes that don't have more specific defaults of their own.
+var DefaultPolicy = Policy{Initial: 50 * time.Millisecond, Max: 5 * time.Second, Jitter: EqualJitter}
+
+// IsZero reports whether p is the zero Policy.
+func (p Policy) IsZero() bool {
+	return p == Policy{}
+}
+
+// Validate returns an error describing what is wrong with p, if anything.
+func (p Policy) Validate() error {
+	switch {
+	case p.Initial < 0 || p.Max < 0:
+		return errors.New("retry policy waits must not be negative")
+	case p.Max < p.Initial:
+		return errors.New("retry policy maximum must not be shorter than its initial wait")
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return errors.New("retry policy multiplier must be at least 1")
+	case p.Jitter < NoJitter || p.Jitter > EqualJitter:
+		return errors.New("retry policy jitter is unknown")
+	}
+	return nil
+}
+
+// Start returns a Backoff stepping through p from its first wait.
+func (p Policy) Start() *Backoff {
+	return &Backoff{policy: p, next: p.Initial}
+}
+
+// Backoff steps through the waits of a Policy. It isn't safe for concurrent
+// use.
+type Backoff struct {
+	policy  Policy
+	next    time.Duration
+	attempt int
+}
+
+// Next returns the wait before the next attempt and advances to the one
+// after.
+func (b *Backoff) Next() time.Duration {
+	b.attempt++
+	wait := b.next
+	if wait <= 0 {
+		return 0
+	}
+	multiplier := b.policy.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	if b.next = time.Duration(float64(b.next) * multiplier); b.next > b.policy.Max || b.next <= 0 {
+		b.next = b.policy.Max
+	}
+	switch b.policy.Jitter {
+	case FullJitter:
+		return Random(wait)
+	case EqualJitter:
+		return wait/2 + Random(wait-wait/2)
+	}
+	return wait
+}
+
+// Attempt returns how many waits Next has returned since the Backoff started
+// or was reset.
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Reset starts the Backoff over from the policy's first wait, such as after
+// an attempt succeeded.
+func (b *Backoff) Reset() {
+	b.next, b.attempt = b.policy.Initial, 0
+}
+
+var (
+	randMu sync.Mutex
+	// The source is seeded from the clock and pid: the global source is
+	// seeded identically in every process, which would defeat the jitter.
+	randSource = rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())<<32))
+)
+
+// Random returns a random duration from zero up to, but excluding, max, or
+// zero if max isn't positive.
+func Random(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	randMu.Lock()
+	defer randMu.Unlock()
+	return time.Duration(randSource.Int63n(int64(max)))
+}
+
+// Sleep waits for d, returning ctx's error early if it is done first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// permanentError marks an error Do doesn't retry.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so that Do returns it, unwrapped, without retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, backing off according to p between
+// attempts. It gives up with the error of an attempt that fn marked with
+// Permanent, with the last attempt's error once maxAttempts have been made,
+// unless maxAttempts is zero, and with ctx's error once ctx is done.
+func Do(ctx context.Context, p Policy, maxAttempts int, fn func() error) error {
+	b := p.Start()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return err
+		}
+		if err := Sleep(ctx, b.Next()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffShouldGrowUpToMax(t *testing.T) {
+	b := Policy{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}.Start()
+	var waits []time.Duration
+	for i := 0; i < 5; i++ {
+		waits = append(waits, b.Next())
+	}
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}, waits)
+	assert.Equal(t, 5, b.Attempt())
+
+	b.Reset()
+	assert.Equal(t, 10*time.Millisecond, b.Next())
+	assert.Equal(t, time.Duration(0), Policy{}.Start().Next())
+}
+
+func TestBackoffShouldJitterWithinBounds(t *testing.T) {
+	full := Policy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Jitter: FullJitter}.Start()
+	equal := Policy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Jitter: EqualJitter}.Start()
+	for i := 0; i < 100; i++ {
+		if d := full.Next(); d < 0 || d >= 10*time.Millisecond {
+			t.Fatalf("Full jitter %s out of range", d)
+		}
+		if d := equal.Next(); d < 5*time.Millisecond || d >= 10*time.Millisecond {
+			t.Fatalf("Equal jitter %s out of range", d)
+		}
+	}
+	assert.Equal(t, time.Duration(0), Random(0))
+}
+
+func TestValidateShouldRejectNonsensicalPolicies(t *testing.T) {
+	assert.NoError(t, Policy{}.Validate())
+	assert.NoError(t, DefaultPolicy.Validate())
+	assert.Error(t, Policy{Initial: -time.Second}.Validate())
+	assert.Error(t, Policy{Initial: time.Second, Max: time.Millisecond}.Validate())
+	assert.Error(t, Policy{Multiplier: 0.5}.Validate())
+	assert.Error(t, Policy{Jitter: 3}.Validate())
+}
+
+func TestSleepShouldStopWithContext(t *testing.T) {
+	assert.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	assert.Equal(t, context.Canceled, Sleep(ctx, time.Hour))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDoShouldRetryUntilSuccessOrPermanentError(t *testing.T) {
+	policy := Policy{Initial: time.Millisecond, Max: time.Millisecond}
+	failure := errors.New("failure")
+
+	calls := 0
+	err := Do(context.Background(), policy, 0, func() error {
+		if calls++; calls < 3 {
+			return failure
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Do(context.Background(), policy, 2, func() error {
+		calls++
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	err = Do(context.Background(), policy, 0, func() error {
+		calls++
+		return Permanent(failure)
+	})
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, Permanent(nil))
+}
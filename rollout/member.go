@@ -0,0 +1,200 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// Member follows the configuration under a rollout root, applying the staged
+// configuration instead of the current one while it is selected for a
+// rollout.
+type Member struct {
+	session session.Session
+	root    string
+	id      string
+	apply   func(config string) error
+	o       recipeopts.Options
+	cache   *cache.TreeCache
+
+	mu      sync.Mutex
+	config  string
+	canary  bool
+	applied bool
+	// rollout is the last rollout this member took part in, and rejected
+	// whether applying its configuration failed.
+	rollout  string
+	rejected bool
+
+	done chan struct{}
+}
+
+// Option configures a Member, with the options every recipe shares from
+// recipeopts. Staged configurations and health reports stay JSON, as Stage
+// and GetStatus read and write them, so the codec isn't used.
+type Option = recipeopts.Option
+
+// NewMember creates a member identified by id, which must be unique among the
+// members under root, the default base path, or fails if opts are invalid.
+// apply is called with every configuration the member should switch to; an
+// error while applying a staged configuration is reported as a failure of the
+// rollout, and the member returns to the current configuration.
+func NewMember(s session.Session, root, id string, apply func(config string) error, opts ...Option) (*Member, error) {
+	o := recipeopts.Defaults(root)
+	if err := recipeopts.Apply(&o, opts...); err != nil {
+		return nil, err
+	}
+	return &Member{session: s, root: o.BasePath, id: id, apply: apply, o: o, done: make(chan struct{})}, nil
+}
+
+// Start follows the configuration in the background until Close is called.
+func (m *Member) Start() error {
+	if _, err := (managednode.Node{Path: m.root, Parents: true, OnConflict: managednode.Adopt}).Ensure(m.session); err != nil {
+		return err
+	}
+	m.cache = cache.NewTreeCache(m.session, m.root)
+	m.cache.Start()
+	diffs := m.cache.DiffStream(configPath(m.root))
+	staging := m.cache.DiffStream(stagingPath(m.root))
+	session.Go(m.session, "rollout", func() { m.run(diffs, staging) })
+	return nil
+}
+
+// Close stops following the configuration. The health report, if any, goes
+// away with the session.
+func (m *Member) Close() {
+	m.cache.Close()
+	<-m.done
+}
+
+// Run follows the configuration until ctx is done, closing the member and
+// returning ctx.Err(), or until it stops otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (m *Member) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, m.Start, m.done, func() error {
+		m.Close()
+		return nil
+	})
+}
+
+// Config returns the configuration last applied, and whether it is a staged
+// one.
+func (m *Member) Config() (config string, canary bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config, m.canary
+}
+
+func (m *Member) run(config, staging <-chan cache.Diff) {
+	defer close(m.done)
+	synced := false
+	for config != nil || staging != nil {
+		select {
+		case d, ok := <-config:
+			if !ok {
+				config = nil
+				continue
+			}
+			if _, ok := d.(cache.InitialSyncDone); ok {
+				synced = true
+			}
+		case _, ok := <-staging:
+			if !ok {
+				staging = nil
+				continue
+			}
+		}
+		if synced {
+			m.evaluate()
+		}
+	}
+}
+
+// evaluate applies the configuration the member should be running, according
+// to the cache.
+func (m *Member) evaluate() {
+	current, exists := m.cache.Get(configPath(m.root))
+	target, canary, rollout := current.Data, false, ""
+
+	if node, ok := m.cache.Get(stagingPath(m.root)); ok {
+		var st staged
+		if json.Unmarshal([]byte(node.Data), &st) == nil {
+			rollout = rolloutID(node.Stat)
+			m.mu.Lock()
+			rejected := m.rejected && m.rollout == rollout
+			m.mu.Unlock()
+			if !rejected && selected(m.id, rollout, st.Percent) {
+				target, canary = st.Config, true
+			}
+		}
+	}
+
+	if !exists && !canary {
+		return
+	}
+
+	m.mu.Lock()
+	if m.applied && m.config == target && !canary {
+		// Back to the current configuration, which a promotion may have made
+		// the same as the one applied.
+		m.canary = false
+		m.mu.Unlock()
+		return
+	}
+	unchanged := m.applied && m.config == target && m.canary && m.rollout == rollout
+	m.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	_, end := m.o.StartOperation(m.session, "rollout", "apply", m.root)
+	var err error
+	if perr := session.Protect(m.session, "rollout", func() { err = m.apply(target) }); perr != nil {
+		err = perr
+	}
+	end(err)
+
+	m.mu.Lock()
+	if canary {
+		m.rollout = rollout
+		m.rejected = err != nil
+	}
+	if err == nil {
+		m.config, m.canary, m.applied = target, canary, true
+	}
+	m.mu.Unlock()
+
+	if canary {
+		m.report(rollout, err)
+		if err != nil {
+			m.evaluate()
+		}
+	}
+}
+
+// report records the outcome of applying rollout's configuration.
+func (m *Member) report(rollout string, applyErr error) {
+	r := report{Rollout: rollout}
+	if applyErr != nil {
+		r.Error = applyErr.Error()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	node := managednode.Node{
+		Path:       path.Join(healthPath(m.root), m.id),
+		Data:       string(data),
+		Flags:      zookeeper.EPHEMERAL,
+		Parents:    true,
+		OnConflict: managednode.Overwrite,
+	}
+	_, _ = node.Ensure(m.session)
+}
@@ -0,0 +1,263 @@
+// Package rollout stages configuration changes on a share of a fleet before
+// promoting them to everyone.
+//
+// The current configuration lives at root/config. Stage writes a candidate
+// to root/staging along with the percentage of members that should try it.
+// Every Member watches both nodes; the members selected for the rollout apply
+// the candidate and report whether that worked under root/health, while the
+// others keep the current configuration. Once enough canaries are healthy,
+// Promote makes the candidate the current configuration for everyone, or
+// Abort sends the canaries back to the current one.
+//
+// Which members are selected depends only on the member and rollout IDs, so a
+// member that restarts makes the same choice again, and raising the
+// percentage with SetPercent keeps the members already selected.
+package rollout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strconv"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrInProgress is returned by Stage when a rollout is already staged. It is
+// a session.ErrAlreadyExists.
+var ErrInProgress = session.NewError("rollout already in progress", session.ErrAlreadyExists)
+
+// ErrNoRollout is returned when the rollout given isn't the one staged, or
+// nothing is staged.
+var ErrNoRollout = errors.New("no such rollout staged")
+
+// ErrUnhealthy is returned by Promote when canaries reported failures or too
+// few reported success.
+var ErrUnhealthy = errors.New("rollout not healthy")
+
+// staged is the document stored at root/staging.
+type staged struct {
+	Config  string `json:"config"`
+	Percent int    `json:"percent"`
+}
+
+// report is the document a member stores at root/health/<member>.
+type report struct {
+	Rollout string `json:"rollout"`
+	Error   string `json:"error,omitempty"`
+}
+
+func configPath(root string) string  { return path.Join(root, "config") }
+func stagingPath(root string) string { return path.Join(root, "staging") }
+func healthPath(root string) string  { return path.Join(root, "health") }
+
+// rolloutID identifies the rollout staged in the node described by stat. It
+// changes every time a rollout is staged, even with the same configuration.
+func rolloutID(stat *zookeeper.Stat) string {
+	return strconv.FormatInt(stat.Czxid(), 16)
+}
+
+// selected reports whether member takes part in rollout at percent.
+func selected(member, rollout string, percent int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(rollout + "/" + member))
+	return int(h.Sum32()%100) < percent
+}
+
+func checkPercent(percent int) error {
+	if percent < 1 || percent > 100 {
+		return fmt.Errorf("rollout percentage must be between 1 and 100, got %d", percent)
+	}
+	return nil
+}
+
+// Stage starts rolling out config to percent of the members under root, and
+// returns the rollout's ID.
+func Stage(s session.Session, root, config string, percent int) (string, error) {
+	if err := checkPercent(percent); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(staged{Config: config, Percent: percent})
+	if err != nil {
+		return "", err
+	}
+	if _, err := (managednode.Node{Path: healthPath(root), Parents: true, OnConflict: managednode.Adopt}).Ensure(s); err != nil {
+		return "", err
+	}
+	_, err = s.Create(stagingPath(root), string(data), 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		return "", ErrInProgress
+	}
+	if err != nil {
+		return "", err
+	}
+	stat, err := s.Exists(stagingPath(root))
+	if err != nil {
+		return "", err
+	}
+	if stat == nil {
+		return "", ErrNoRollout
+	}
+	return rolloutID(stat), nil
+}
+
+// read returns the staged rollout, checking it is the one identified by id.
+func read(s session.Session, root, id string) (staged, *zookeeper.Stat, error) {
+	data, stat, err := s.Get(stagingPath(root))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return staged{}, nil, ErrNoRollout
+	}
+	if err != nil {
+		return staged{}, nil, err
+	}
+	if rolloutID(stat) != id {
+		return staged{}, nil, ErrNoRollout
+	}
+	var st staged
+	if err := json.Unmarshal([]byte(data), &st); err != nil {
+		return staged{}, nil, fmt.Errorf("rollout %s holds %q: %w", stagingPath(root), data, err)
+	}
+	return st, stat, nil
+}
+
+// SetPercent changes the share of members taking part in rollout id. Members
+// already selected stay selected when the percentage is raised.
+func SetPercent(s session.Session, root, id string, percent int) error {
+	if err := checkPercent(percent); err != nil {
+		return err
+	}
+	st, stat, err := read(s, root, id)
+	if err != nil {
+		return err
+	}
+	st.Percent = percent
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = s.Set(stagingPath(root), string(data), stat.Version())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return ErrNoRollout
+	}
+	return err
+}
+
+// Status describes a staged rollout.
+type Status struct {
+	ID      string
+	Config  string
+	Percent int
+	// Healthy lists the members that applied the staged configuration.
+	Healthy []string
+	// Failed maps the members that failed to apply it to their error.
+	Failed map[string]string
+}
+
+// GetStatus returns the staged rollout and the health reported for it so far.
+func GetStatus(s session.Session, root string) (Status, error) {
+	data, stat, err := s.Get(stagingPath(root))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return Status{}, ErrNoRollout
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	var st staged
+	if err := json.Unmarshal([]byte(data), &st); err != nil {
+		return Status{}, fmt.Errorf("rollout %s holds %q: %w", stagingPath(root), data, err)
+	}
+
+	status := Status{ID: rolloutID(stat), Config: st.Config, Percent: st.Percent, Failed: make(map[string]string)}
+	members, _, err := s.Children(healthPath(root))
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return Status{}, err
+	}
+	sort.Strings(members)
+	for _, member := range members {
+		data, _, err := s.Get(path.Join(healthPath(root), member))
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			continue
+		}
+		if err != nil {
+			return Status{}, err
+		}
+		var r report
+		if json.Unmarshal([]byte(data), &r) != nil || r.Rollout != status.ID {
+			continue
+		}
+		if r.Error != "" {
+			status.Failed[member] = r.Error
+		} else {
+			status.Healthy = append(status.Healthy, member)
+		}
+	}
+	return status, nil
+}
+
+// Promote makes the configuration of rollout id the current configuration for
+// every member. It fails with ErrUnhealthy if any canary reported a failure
+// or fewer than minHealthy reported success.
+func Promote(s session.Session, root, id string, minHealthy int) error {
+	status, err := GetStatus(s, root)
+	if err != nil {
+		return err
+	}
+	if status.ID != id {
+		return ErrNoRollout
+	}
+	if len(status.Failed) > 0 || len(status.Healthy) < minHealthy {
+		return fmt.Errorf("%w: %d healthy, %d failed", ErrUnhealthy, len(status.Healthy), len(status.Failed))
+	}
+
+	if _, err := (managednode.Node{Path: configPath(root), Data: status.Config, Parents: true, OnConflict: managednode.Overwrite}).Ensure(s); err != nil {
+		return err
+	}
+	return end(s, root, id)
+}
+
+// Abort ends rollout id, returning the canaries to the current configuration.
+func Abort(s session.Session, root, id string) error {
+	return end(s, root, id)
+}
+
+func end(s session.Session, root, id string) error {
+	_, stat, err := read(s, root, id)
+	if err != nil {
+		return err
+	}
+	err = s.Delete(stagingPath(root), stat.Version())
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return ErrNoRollout
+	}
+	if zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+		// The percentage changed; the ID was checked above.
+		err = s.Delete(stagingPath(root), -1)
+	}
+	if err != nil {
+		return err
+	}
+	return clearHealth(s, root)
+}
+
+// clearHealth removes the health reports of every member.
+func clearHealth(s session.Session, root string) error {
+	members, _, err := s.Children(healthPath(root))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		err := s.Delete(path.Join(healthPath(root), member), -1)
+		if err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package rollout
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// applied records the configurations a member applied, failing those listed
+// in bad.
+type applied struct {
+	mu      sync.Mutex
+	configs []string
+	bad     map[string]bool
+}
+
+func (a *applied) apply(config string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configs = append(a.configs, config)
+	if a.bad[config] {
+		return errors.New("refused " + config)
+	}
+	return nil
+}
+
+func waitForConfig(t *testing.T, m *Member, config string, canary bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if c, ok := m.Config(); c == config && ok == canary {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c, ok := m.Config()
+	t.Fatalf("member has config %q (canary %v), want %q (canary %v)", c, ok, config, canary)
+}
+
+func waitForStatus(t *testing.T, s session.Session, root string, reports int) Status {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := GetStatus(s, root)
+		if err != nil {
+			t.Fatal("GetStatus error: ", err)
+		}
+		if len(status.Healthy)+len(status.Failed) >= reports || time.Now().After(deadline) {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newMember(t *testing.T, s session.Session, id string, apply func(string) error) *Member {
+	m, err := NewMember(s, "/test/app", id, apply)
+	if err != nil {
+		t.Fatal("NewMember error: ", err)
+	}
+	return m
+}
+
+func TestRolloutShouldPromoteHealthyCanaries(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Create("/test/app", "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Create("/test/app/config", "v1", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		a, b := &applied{}, &applied{}
+		ma, mb := newMember(t, s, "a", a.apply), newMember(t, s, "b", b.apply)
+		for _, m := range []*Member{ma, mb} {
+			if err := m.Start(); err != nil {
+				t.Fatal("Start error: ", err)
+			}
+			defer m.Close()
+			waitForConfig(t, m, "v1", false)
+		}
+
+		id, err := Stage(s, "/test/app", "v2", 100)
+		if err != nil {
+			t.Fatal("Stage error: ", err)
+		}
+		_, err = Stage(s, "/test/app", "v3", 100)
+		assert.Equal(t, ErrInProgress, err)
+
+		waitForConfig(t, ma, "v2", true)
+		waitForConfig(t, mb, "v2", true)
+		status := waitForStatus(t, s, "/test/app", 2)
+		assert.Equal(t, id, status.ID)
+		assert.Equal(t, []string{"a", "b"}, status.Healthy)
+		assert.Empty(t, status.Failed)
+
+		assert.ErrorIs(t, Promote(s, "/test/app", id, 3), ErrUnhealthy)
+		if err := Promote(s, "/test/app", id, 2); err != nil {
+			t.Fatal("Promote error: ", err)
+		}
+		waitForConfig(t, ma, "v2", false)
+		waitForConfig(t, mb, "v2", false)
+
+		data, _, err := s.Get("/test/app/config")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", data)
+		_, err = GetStatus(s, "/test/app")
+		assert.Equal(t, ErrNoRollout, err)
+		assert.Equal(t, []string{"v1", "v2"}, a.configs)
+	})
+}
+
+func TestRolloutShouldRevertFailedCanaries(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		id, err := Stage(s, "/test/app", "bad", 100)
+		if err != nil {
+			t.Fatal("Stage error: ", err)
+		}
+		if _, err := s.Create("/test/app/config", "v1", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		a := &applied{bad: map[string]bool{"bad": true}}
+		m := newMember(t, s, "a", a.apply)
+		if err := m.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer m.Close()
+
+		waitForConfig(t, m, "v1", false)
+		status := waitForStatus(t, s, "/test/app", 1)
+		assert.Equal(t, map[string]string{"a": "refused bad"}, status.Failed)
+		assert.ErrorIs(t, Promote(s, "/test/app", id, 0), ErrUnhealthy)
+
+		if err := Abort(s, "/test/app", id); err != nil {
+			t.Fatal("Abort error: ", err)
+		}
+		assert.Equal(t, ErrNoRollout, Abort(s, "/test/app", id))
+		waitForConfig(t, m, "v1", false)
+		assert.Equal(t, []string{"bad", "v1"}, a.configs)
+	})
+}
+
+func TestSelectedShouldKeepMembersWhenRaised(t *testing.T) {
+	count := 0
+	for i := 0; i < 1000; i++ {
+		member := fmt.Sprintf("member-%d", i)
+		if selected(member, "1a", 20) {
+			count++
+			assert.True(t, selected(member, "1a", 50), member)
+		}
+		assert.True(t, selected(member, "1a", 100), member)
+	}
+	assert.InDelta(t, 200, count, 60)
+}
@@ -0,0 +1,415 @@
+// Package schema declares the layout a ZooKeeper tree is expected to have,
+// reports how the live tree drifted from it, and converges the tree to it.
+//
+// A Schema lists the nodes an application relies on, with their create mode,
+// ACL, initial data and the version of the format of their data. Check
+// compares the live tree with the declaration. Migrate, run under a lock so
+// that only one process migrates at a time, creates missing nodes, restores
+// ACLs and brings data written in an older format up to date with the
+// declared Migrate functions.
+//
+// The version of every node's data is recorded in a registry node under the
+// schema's root, along with who migrated the tree last and when.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/acl"
+	"github.com/Shopify/gozk-recipes/lock"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// ErrSessionExpired is returned when the session expired while migrating,
+// releasing the lock. Fixes made before are kept, and the next run carries on
+// from there. It is a session.ErrSessionLost.
+var ErrSessionExpired = session.NewError("session expired during migration", session.ErrSessionLost)
+
+// Node declares a znode.
+type Node struct {
+	Path string
+	// Mode is Persistent or Ephemeral. Ephemeral nodes belong to the
+	// processes creating them, so they are never created and their absence
+	// isn't drift; only their mode is checked when they exist.
+	Mode session.CreateMode
+	// ACL defaults to zookeeper.WorldACL(zookeeper.PERM_ALL).
+	ACL []zookeeper.ACL
+	// Data is written when the node is created. The data of a node that
+	// already exists is only changed by Migrate.
+	Data string
+	// Version is the version of the format of Data, recorded in the registry
+	// when the node is created or migrated. Nodes at version 0 aren't
+	// versioned.
+	Version int
+	// Migrate converts data from the version the registry records, 0 for a
+	// node created before it was declared with a version, to Version. It is
+	// run again if the migration dies before recording the new version, so
+	// it must cope with data already converted. With no Migrate, the data is
+	// left as it is and only the new version is recorded.
+	Migrate func(data string, from int) (string, error)
+}
+
+func (n Node) acl() []zookeeper.ACL {
+	if n.ACL == nil {
+		return zookeeper.WorldACL(zookeeper.PERM_ALL)
+	}
+	return n.ACL
+}
+
+// Schema declares a tree.
+type Schema struct {
+	// Root holds the registry and the lock migrations are run under. It
+	// needn't be the parent of the declared nodes.
+	Root  string
+	Nodes []Node
+}
+
+func registryPath(root string) string { return path.Join(root, "_schema") }
+func lockPath(root string) string     { return path.Join(root, "_schemalock") }
+
+// validate checks the schema and returns its nodes sorted by path, so that
+// parents come before their children.
+func (sc Schema) validate() ([]Node, error) {
+	if !validPath(sc.Root) {
+		return nil, fmt.Errorf("schema root %q is not an absolute, clean path", sc.Root)
+	}
+	nodes := append([]Node(nil), sc.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Path < nodes[j].Path })
+	for i, n := range nodes {
+		if !validPath(n.Path) {
+			return nil, fmt.Errorf("node %q: not an absolute, clean path", n.Path)
+		}
+		if i > 0 && nodes[i-1].Path == n.Path {
+			return nil, fmt.Errorf("node %q: declared twice", n.Path)
+		}
+		switch n.Mode {
+		case session.Persistent:
+		case session.Ephemeral:
+			if n.Version != 0 || n.Migrate != nil {
+				return nil, fmt.Errorf("node %q: ephemeral nodes aren't migrated", n.Path)
+			}
+		default:
+			return nil, fmt.Errorf("node %q: %w: %s", n.Path, session.ErrInvalidCreateMode, n.Mode)
+		}
+		if n.Version < 0 {
+			return nil, fmt.Errorf("node %q: negative version %d", n.Path, n.Version)
+		}
+	}
+	return nodes, nil
+}
+
+func validPath(p string) bool {
+	return strings.HasPrefix(p, "/") && path.Clean(p) == p
+}
+
+// DriftKind is how a node differs from its declaration.
+type DriftKind int
+
+const (
+	// Missing persistent nodes don't exist.
+	Missing DriftKind = iota
+	// WrongMode nodes are ephemeral when declared persistent, or the other
+	// way around. Migrate can't fix them, as recreating a node would lose its
+	// children and, for ephemeral nodes, its owner.
+	WrongMode
+	// WrongACL nodes have a different ACL than declared.
+	WrongACL
+	// WrongVersion nodes hold data at a different version than declared.
+	// Migrate only fixes older versions; a newer one is left to the newer
+	// code that wrote it.
+	WrongVersion
+)
+
+func (k DriftKind) String() string {
+	switch k {
+	case Missing:
+		return "Missing"
+	case WrongMode:
+		return "WrongMode"
+	case WrongACL:
+		return "WrongACL"
+	case WrongVersion:
+		return "WrongVersion"
+	}
+	return fmt.Sprintf("DriftKind(%d)", int(k))
+}
+
+// Drift describes a node differing from its declaration.
+type Drift struct {
+	Path   string
+	Kind   DriftKind
+	Detail string
+	// Fixed is false for Check, and for drift Migrate can't fix.
+	Fixed bool
+}
+
+func (d Drift) String() string {
+	s := d.Path + ": " + d.Kind.String()
+	if d.Detail != "" {
+		s += " (" + d.Detail + ")"
+	}
+	if d.Fixed {
+		s += ", fixed"
+	}
+	return s
+}
+
+// Registry records the data versions of the nodes of a schema.
+type Registry struct {
+	Versions map[string]int `json:"versions"`
+	// Runner and Time tell who migrated the tree last, and when.
+	Runner string    `json:"runner,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// ReadRegistry returns the registry of the schema rooted at root, empty if no
+// migration has run yet.
+func ReadRegistry(s session.Session, root string) (Registry, error) {
+	r := Registry{Versions: map[string]int{}}
+	data, _, err := s.Get(registryPath(root))
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return r, nil
+	}
+	if err != nil {
+		return Registry{}, err
+	}
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return Registry{}, fmt.Errorf("registry %s holds %q: %w", registryPath(root), data, err)
+	}
+	if r.Versions == nil {
+		r.Versions = map[string]int{}
+	}
+	return r, nil
+}
+
+func writeRegistry(s session.Session, root string, r Registry) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = (managednode.Node{Path: registryPath(root), Data: string(data), Parents: true, OnConflict: managednode.Overwrite}).Ensure(s)
+	return err
+}
+
+// state is what inspect read of a node.
+type state struct {
+	exists bool
+	data   string
+	stat   *zookeeper.Stat
+}
+
+// inspect compares the node at n.Path with n.
+func inspect(s session.Session, n Node, r Registry) ([]Drift, state, error) {
+	data, stat, err := s.Get(n.Path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		if n.Mode == session.Ephemeral {
+			return nil, state{}, nil
+		}
+		return []Drift{{Path: n.Path, Kind: Missing}}, state{}, nil
+	}
+	if err != nil {
+		return nil, state{}, err
+	}
+	st := state{exists: true, data: data, stat: stat}
+
+	var drift []Drift
+	mode := session.Persistent
+	if stat.EphemeralOwner() != 0 {
+		mode = session.Ephemeral
+	}
+	if mode != n.Mode {
+		drift = append(drift, Drift{Path: n.Path, Kind: WrongMode, Detail: fmt.Sprintf("%s, declared %s", mode, n.Mode)})
+	}
+
+	current, _, err := s.ACL(n.Path)
+	if err != nil {
+		return nil, state{}, err
+	}
+	if !acl.Equal(current, n.acl()) {
+		diff := acl.DiffACL(current, n.acl())
+		drift = append(drift, Drift{Path: n.Path, Kind: WrongACL, Detail: strings.ReplaceAll(diff.String(), "\n", ", ")})
+	}
+
+	if n.Mode == session.Persistent && n.Version != 0 {
+		if v := r.Versions[n.Path]; v != n.Version {
+			drift = append(drift, Drift{Path: n.Path, Kind: WrongVersion, Detail: fmt.Sprintf("version %d, declared %d", v, n.Version)})
+		}
+	}
+	return drift, st, nil
+}
+
+// Check reports how the live tree differs from the schema, without changing
+// anything. Nodes are checked in path order.
+func Check(s session.Session, sc Schema) ([]Drift, error) {
+	nodes, err := sc.validate()
+	if err != nil {
+		return nil, err
+	}
+	r, err := ReadRegistry(s, sc.Root)
+	if err != nil {
+		return nil, err
+	}
+	var drift []Drift
+	for _, n := range nodes {
+		d, _, err := inspect(s, n, r)
+		if err != nil {
+			return drift, fmt.Errorf("checking %s: %w", n.Path, err)
+		}
+		drift = append(drift, d...)
+	}
+	return drift, nil
+}
+
+type options struct {
+	recipeopts.Options
+	runner string
+}
+
+// Option configures Migrate: the option below, or one of the options every
+// recipe shares from recipeopts. The timeout bounds the wait for the lock,
+// and the base path must be left at the schema's root; the registry stays
+// JSON, as ReadRegistry reads it, so the codec isn't used.
+type Option = recipeopts.Option
+
+// WithRunner sets the identity recorded in the registry and the lock, the
+// host name and process ID by default.
+func WithRunner(id string) Option {
+	return recipeopts.Own(func(o *options) { o.runner = id })
+}
+
+// Migrate converges the live tree to the schema, holding a lock under the
+// schema's root so that concurrent runs wait for each other, and returns the
+// drift it found, each marked Fixed if it was. It gives up with ctx's error if
+// ctx is done, or the timeout passes, before the lock is acquired.
+//
+// Nodes are migrated in path order: missing persistent nodes are created,
+// along with missing parents, ACLs are set back to their declaration, and
+// data at an older version is converted with the node's Migrate function.
+// Every node's data version is recorded as soon as it changes, so a run dying
+// midway is carried on by the next one. Drift found before an error is
+// returned along with it.
+func Migrate(ctx context.Context, s *session.ZKSession, sc Schema, opts ...Option) (drift []Drift, err error) {
+	nodes, err := sc.validate()
+	if err != nil {
+		return nil, err
+	}
+	o := &options{Options: recipeopts.Defaults(sc.Root)}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.BasePath != sc.Root {
+		return nil, fmt.Errorf("%w: base path %q isn't the schema's root %q", recipeopts.ErrInvalidOptions, o.BasePath, sc.Root)
+	}
+	if o.runner == "" {
+		host, _ := os.Hostname()
+		o.runner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	if _, err := (managednode.Node{Path: lockPath(sc.Root), Parents: true, OnConflict: managednode.Adopt}).Ensure(s); err != nil {
+		return nil, err
+	}
+	l, err := lock.NewGlobalLock(s, lockPath(sc.Root), o.runner)
+	if err != nil {
+		return nil, err
+	}
+	_, end := o.StartOperation(s, "schema", "migrate", sc.Root)
+	defer func() { end(err) }()
+	epoch := s.Epoch()
+	lockCtx, cancel := o.Context(ctx)
+	defer cancel()
+	if err := l.Acquire(lockCtx); err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	r, err := ReadRegistry(s, sc.Root)
+	if err != nil {
+		return nil, err
+	}
+	m := &migration{session: s, root: sc.Root, runner: o.runner, epoch: epoch, registry: r}
+
+	for _, n := range nodes {
+		d, st, err := inspect(s, n, r)
+		if err != nil {
+			return drift, fmt.Errorf("checking %s: %w", n.Path, err)
+		}
+		for i := range d {
+			if err := m.fix(n, &d[i], st); err != nil {
+				return append(drift, d[:i+1]...), fmt.Errorf("migrating %s: %w", n.Path, err)
+			}
+		}
+		drift = append(drift, d...)
+	}
+	return drift, nil
+}
+
+type migration struct {
+	session  *session.ZKSession
+	root     string
+	runner   string
+	epoch    uint64
+	registry Registry
+}
+
+// fix fixes d if it can, marking it Fixed.
+func (m *migration) fix(n Node, d *Drift, st state) error {
+	if m.session.Epoch() != m.epoch {
+		return ErrSessionExpired
+	}
+	switch d.Kind {
+	case Missing:
+		if _, err := (managednode.Node{Path: n.Path, Data: n.Data, ACL: n.acl(), Parents: true}).Ensure(m.session); err != nil {
+			return err
+		}
+		if n.Version != 0 {
+			if err := m.record(n); err != nil {
+				return err
+			}
+		}
+
+	case WrongACL:
+		if err := m.session.SetACL(n.Path, n.acl(), st.stat.AVersion()); err != nil {
+			return err
+		}
+
+	case WrongVersion:
+		if m.registry.Versions[n.Path] > n.Version {
+			return nil
+		}
+		if n.Migrate != nil {
+			data, err := n.Migrate(st.data, m.registry.Versions[n.Path])
+			if err != nil {
+				return fmt.Errorf("from version %d: %w", m.registry.Versions[n.Path], err)
+			}
+			if _, err := m.session.Set(n.Path, data, st.stat.Version()); err != nil {
+				return err
+			}
+		}
+		if err := m.record(n); err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+	d.Fixed = true
+	return nil
+}
+
+// record records the declared version of n in the registry.
+func (m *migration) record(n Node) error {
+	m.registry.Versions[n.Path] = n.Version
+	m.registry.Runner = m.runner
+	m.registry.Time = time.Now().UTC()
+	return writeRegistry(m.session, m.root, m.registry)
+}
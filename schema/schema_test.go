@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var readOnly = zookeeper.WorldACL(zookeeper.PERM_READ | zookeeper.PERM_WRITE | zookeeper.PERM_ADMIN)
+
+func testSchema(version int) Schema {
+	return Schema{Root: "/test/app", Nodes: []Node{
+		{Path: "/test/app/config", Data: `{"format":1}`, Version: version, Migrate: func(data string, from int) (string, error) {
+			return `{"format":2}`, nil
+		}},
+		{Path: "/test/app/members", ACL: readOnly},
+		{Path: "/test/app/leader", Mode: session.Ephemeral},
+	}}
+}
+
+func TestValidateShouldRejectBadDeclarations(t *testing.T) {
+	for _, sc := range []Schema{
+		{Root: "test"},
+		{Root: "/test", Nodes: []Node{{Path: "/test/a/"}}},
+		{Root: "/test", Nodes: []Node{{Path: "/test/a"}, {Path: "/test/a"}}},
+		{Root: "/test", Nodes: []Node{{Path: "/test/a", Mode: session.Container}}},
+		{Root: "/test", Nodes: []Node{{Path: "/test/a", Mode: session.Ephemeral, Version: 1}}},
+	} {
+		_, err := sc.validate()
+		assert.Error(t, err, "%+v", sc)
+	}
+}
+
+func TestMigrateShouldRejectInvalidOptions(t *testing.T) {
+	_, err := Migrate(context.Background(), nil, testSchema(1), recipeopts.WithBasePath("/elsewhere"))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+	_, err = Migrate(context.Background(), nil, testSchema(1), recipeopts.WithTimeout(-time.Second))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestMigrateShouldCreateTree(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		drift, err := Check(s, testSchema(1))
+		assert.NoError(t, err)
+		assert.Equal(t, []Drift{{Path: "/test/app/config", Kind: Missing}, {Path: "/test/app/members", Kind: Missing}}, drift)
+
+		drift, err = Migrate(context.Background(), s, testSchema(1), WithRunner("tester"))
+		assert.NoError(t, err)
+		assert.Len(t, drift, 2)
+		for _, d := range drift {
+			assert.True(t, d.Fixed, d.String())
+		}
+
+		data, _, err := s.Get("/test/app/config")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"format":1}`, data)
+		current, _, err := s.ACL("/test/app/members")
+		assert.NoError(t, err)
+		assert.Equal(t, readOnly, current)
+
+		r, err := ReadRegistry(s, "/test/app")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"/test/app/config": 1}, r.Versions)
+		assert.Equal(t, "tester", r.Runner)
+
+		drift, err = Check(s, testSchema(1))
+		assert.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+}
+
+func TestMigrateShouldFixDrift(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		_, err := Migrate(context.Background(), s, testSchema(1))
+		assert.NoError(t, err)
+		assert.NoError(t, s.SetACL("/test/app/members", zookeeper.WorldACL(zookeeper.PERM_ALL), -1))
+		_, err = s.Create("/test/app/leader", "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+		assert.NoError(t, err)
+
+		drift, err := Migrate(context.Background(), s, testSchema(2))
+		assert.NoError(t, err)
+		kinds := map[DriftKind]bool{}
+		for _, d := range drift {
+			kinds[d.Kind] = true
+			assert.Equal(t, d.Kind != WrongMode, d.Fixed, d.String())
+		}
+		assert.Equal(t, map[DriftKind]bool{WrongVersion: true, WrongACL: true, WrongMode: true}, kinds)
+
+		data, _, err := s.Get("/test/app/config")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"format":2}`, data)
+
+		drift, err = Check(s, testSchema(2))
+		assert.NoError(t, err)
+		assert.Equal(t, []Drift{{Path: "/test/app/leader", Kind: WrongMode, Detail: "Persistent, declared Ephemeral"}}, drift)
+	})
+}
+
+func TestMigrateShouldStopOnFailedMigration(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		_, err := Migrate(context.Background(), s, testSchema(1))
+		assert.NoError(t, err)
+
+		broken := errors.New("broken")
+		sc := testSchema(2)
+		sc.Nodes[0].Migrate = func(string, int) (string, error) { return "", broken }
+		_, err = Migrate(context.Background(), s, sc)
+		assert.True(t, errors.Is(err, broken), err)
+
+		r, err := ReadRegistry(s, "/test/app")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, r.Versions["/test/app/config"])
+	})
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// AffinityChange tells that the ZooKeeper session behind a ZKSession was
+// replaced, as after an expiry, so its AffinityToken changed from Old to New.
+// Epoch is the epoch of the new session.
+type AffinityChange struct {
+	Old   string
+	New   string
+	Epoch uint64
+}
+
+// AffinityToken returns an opaque token identifying the ZooKeeper session
+// currently behind s, for routing layers that key sticky state on it. It is
+// derived from the session ID alone, never the session password, stays the
+// same across reconnections to the same session, and changes when the
+// session expires or is recycled and a new one replaces it. It is "" if the
+// session's ID isn't known.
+func (s *ZKSession) AffinityToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.affinity
+}
+
+// SubscribeAffinity delivers an AffinityChange to subscription every time the
+// session's AffinityToken changes, after the session event reporting the new
+// session. Like Subscribe, delivery is in order and blocks the session until
+// subscription receives the change.
+func (s *ZKSession) SubscribeAffinity(subscription chan<- AffinityChange) {
+	s.affinityChanges.Subscribe(subscription)
+}
+
+// refreshAffinity reads the token of the current connection's session, and
+// returns the change if it differs from the last one. s.mu must be held.
+func (s *ZKSession) refreshAffinity() (AffinityChange, bool) {
+	if s.conn == nil {
+		return AffinityChange{}, false
+	}
+	token := affinityToken(s.conn.ClientId())
+	if token == "" || token == s.affinity {
+		return AffinityChange{}, false
+	}
+	change := AffinityChange{Old: s.affinity, New: token, Epoch: s.Epoch()}
+	s.affinity = token
+	return change, true
+}
+
+// affinityToken derives the token of the session identified by id.
+func affinityToken(id *zookeeper.ClientId) string {
+	if id == nil {
+		return ""
+	}
+	saved, err := id.Save()
+	if err != nil {
+		return ""
+	}
+	return tokenForSessionID(saved)
+}
+
+// tokenForSessionID hashes the session ID at the start of a saved client ID,
+// leaving out the password after it. A zero ID, as before the session is
+// established, has no token.
+func tokenForSessionID(saved []byte) string {
+	if len(saved) < 8 {
+		return ""
+	}
+	id := saved[:8]
+	zero := true
+	for _, b := range id {
+		zero = zero && b == 0
+	}
+	if zero {
+		return ""
+	}
+	sum := sha256.Sum256(id)
+	return hex.EncodeToString(sum[:12])
+}
+
+// SessionID returns the ID of the ZooKeeper session behind s, as ephemeral
+// nodes report their owner in Stat.EphemeralOwner. ok is false if the ID
+// isn't known, such as before the session is established.
+func SessionID(s Session) (id int64, ok bool) {
+	clientID := s.ClientId()
+	if clientID == nil {
+		return 0, false
+	}
+	saved, err := clientID.Save()
+	if err != nil || len(saved) < 8 {
+		return 0, false
+	}
+	id = int64(binary.BigEndian.Uint64(saved[:8]))
+	return id, id != 0
+}
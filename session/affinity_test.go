@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenForSessionIDShouldIgnorePassword(t *testing.T) {
+	saved := func(id byte, password byte) []byte {
+		b := make([]byte, 24)
+		b[7] = id
+		for i := 8; i < len(b); i++ {
+			b[i] = password
+		}
+		return b
+	}
+
+	token := tokenForSessionID(saved(1, 'a'))
+	assert.Len(t, token, 24)
+	assert.Equal(t, token, tokenForSessionID(saved(1, 'b')))
+	assert.NotEqual(t, token, tokenForSessionID(saved(2, 'a')))
+	assert.Empty(t, tokenForSessionID(saved(0, 'a')))
+	assert.Empty(t, tokenForSessionID(nil))
+}
+
+func TestRefreshAffinityWithoutConnectionShouldKeepToken(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}, affinity: "token"}
+	changes := make(chan AffinityChange, 1)
+	s.SubscribeAffinity(changes)
+
+	s.notifySubscribers(SessionReconnected)
+	assert.Equal(t, "token", s.AffinityToken())
+	assert.Empty(t, changes)
+}
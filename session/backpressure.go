@@ -0,0 +1,217 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBackpressureRecovery is how long the conditions of
+	// WithBackpressure must be clear before backpressure is lifted, unless
+	// BackpressureThresholds.Recovery is given.
+	DefaultBackpressureRecovery = 30 * time.Second
+
+	// backpressureCheckInterval is how often the conditions are checked
+	// besides on every operation, to lift backpressure and follow backlogs
+	// while the session is idle.
+	backpressureCheckInterval = time.Second
+	// latencyWeight is the weight of each operation in the average latency.
+	latencyWeight = 0.1
+)
+
+// BackpressureThresholds are the conditions under which WithBackpressure
+// signals applications to shed non-critical ZooKeeper work. Zero fields
+// aren't checked.
+type BackpressureThresholds struct {
+	// Reconnects is the number of reconnections within ReconnectWindow that
+	// makes a reconnect storm.
+	Reconnects      int
+	ReconnectWindow time.Duration
+	// Latency is the moving average of operation latencies, weighted towards
+	// recent operations, above which ZooKeeper is deemed slow.
+	Latency time.Duration
+	// Backlog is the number of callbacks waiting for the pool of
+	// WithCallbackPool and of operations waiting for a slot of
+	// WithMaxInflight above which the session is falling behind, as when
+	// recipes pile up re-arming watches after a reconnect.
+	Backlog int
+	// Recovery is how long every condition must have been clear before
+	// backpressure is lifted, DefaultBackpressureRecovery if zero, so that
+	// the signal doesn't flap.
+	Recovery time.Duration
+}
+
+// WithBackpressure signals when the session meets one of thresholds'
+// conditions, so that applications can shed non-critical ZooKeeper work
+// using a single standard signal rather than thresholds of their own. See
+// ZKSession.Backpressure. notify, if not nil, is called with active set when
+// backpressure starts, along with the condition met, and unset when it is
+// lifted. It is called in order, and must not block.
+func WithBackpressure(thresholds BackpressureThresholds, notify func(active bool, reason string)) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		if thresholds.Recovery == 0 {
+			thresholds.Recovery = DefaultBackpressureRecovery
+		}
+		so.backpressure = &thresholds
+		so.backpressureNotify = notify
+		return so
+	}
+}
+
+// Backpressure returns a channel that is closed once the session comes under
+// backpressure, as configured with WithBackpressure, and already closed while
+// it is. Once backpressure is lifted, Backpressure returns a new channel.
+// Without WithBackpressure the channel is never closed.
+func (s *ZKSession) Backpressure() <-chan struct{} {
+	return s.pressure.signal()
+}
+
+// BackpressureReason returns the condition that put the session under
+// backpressure, or "" if it isn't.
+func (s *ZKSession) BackpressureReason() string {
+	return s.pressure.current()
+}
+
+// backpressure follows the conditions of WithBackpressure. Like debugState,
+// a nil *backpressure is valid and signals nothing.
+type backpressure struct {
+	thresholds BackpressureThresholds
+	notify     func(active bool, reason string)
+
+	mu         sync.Mutex
+	reconnects []time.Time
+	latency    time.Duration
+	reason     string
+	clearSince time.Time
+	// active is closed while the session is under backpressure, and
+	// replaced when it is lifted.
+	active chan struct{}
+	// notifying orders the calls to notify.
+	notifying sync.Mutex
+}
+
+func newBackpressure(thresholds BackpressureThresholds, notify func(bool, string)) *backpressure {
+	return &backpressure{thresholds: thresholds, notify: notify, active: make(chan struct{})}
+}
+
+func (b *backpressure) signal() <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active
+}
+
+func (b *backpressure) current() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reason
+}
+
+func (b *backpressure) recordEvent(s *ZKSession, event ZKSessionEvent) {
+	if b == nil || (event != SessionReconnected && event != SessionExpiredReconnected) {
+		return
+	}
+	b.mu.Lock()
+	b.reconnects = append(b.reconnects, time.Now())
+	b.mu.Unlock()
+	b.check(s, time.Now())
+}
+
+func (b *backpressure) recordLatency(s *ZKSession, latency time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.latency == 0 {
+		b.latency = latency
+	} else {
+		b.latency += time.Duration(latencyWeight * float64(latency-b.latency))
+	}
+	b.mu.Unlock()
+	b.check(s, time.Now())
+}
+
+// condition returns the first condition met at now, or "".
+func (b *backpressure) condition(s *ZKSession, now time.Time) string {
+	t := b.thresholds
+	if t.Reconnects > 0 {
+		recent := b.reconnects[:0]
+		for _, at := range b.reconnects {
+			if now.Sub(at) < t.ReconnectWindow {
+				recent = append(recent, at)
+			}
+		}
+		b.reconnects = recent
+		if len(recent) >= t.Reconnects {
+			return fmt.Sprintf("%d reconnects within %s", len(recent), t.ReconnectWindow)
+		}
+	}
+	if t.Latency > 0 && b.latency > t.Latency {
+		return fmt.Sprintf("average latency %s over %s", b.latency.Round(time.Microsecond), t.Latency)
+	}
+	if t.Backlog > 0 {
+		if backlog := s.callbacks.depth() + s.inflight.depth(); backlog > t.Backlog {
+			return fmt.Sprintf("backlog of %d over %d", backlog, t.Backlog)
+		}
+	}
+	return ""
+}
+
+// check starts or lifts backpressure according to the conditions at now.
+func (b *backpressure) check(s *ZKSession, now time.Time) {
+	b.mu.Lock()
+	reason := b.condition(s, now)
+	switch {
+	case reason != "":
+		b.clearSince = time.Time{}
+		if b.reason != "" {
+			b.mu.Unlock()
+			return
+		}
+		b.reason = reason
+		close(b.active)
+		s.log.Printf("gozk-recipes/session: under backpressure: %s", reason)
+	case b.reason == "":
+		b.mu.Unlock()
+		return
+	case b.clearSince.IsZero():
+		b.clearSince = now
+		b.mu.Unlock()
+		return
+	case now.Sub(b.clearSince) < b.thresholds.Recovery:
+		b.mu.Unlock()
+		return
+	default:
+		b.reason, b.clearSince = "", time.Time{}
+		b.active = make(chan struct{})
+		s.log.Printf("gozk-recipes/session: backpressure lifted")
+	}
+	active := reason != ""
+	b.notifying.Lock()
+	b.mu.Unlock()
+	defer b.notifying.Unlock()
+	if b.notify != nil {
+		_ = Protect(s, "backpressure", func() { b.notify(active, reason) })
+	}
+}
+
+// backpressureLoop checks the conditions of WithBackpressure periodically
+// until the session terminates.
+func (s *ZKSession) backpressureLoop() {
+	ticker := time.NewTicker(backpressureCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.pressure.check(s, now)
+		case <-s.managed:
+			return
+		}
+	}
+}
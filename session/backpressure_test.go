@@ -0,0 +1,85 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type backpressureChange struct {
+	active bool
+	reason string
+}
+
+func newBackpressureSession(thresholds BackpressureThresholds) (*ZKSession, *[]backpressureChange) {
+	var changes []backpressureChange
+	opts := WithBackpressure(thresholds, func(active bool, reason string) {
+		changes = append(changes, backpressureChange{active, reason})
+	})(SessionOpts{})
+	s := &ZKSession{log: &nullLogger{}, opts: opts, pressure: newBackpressure(*opts.backpressure, opts.backpressureNotify)}
+	return s, &changes
+}
+
+func TestBackpressureShouldStartOnReconnectStorm(t *testing.T) {
+	s, changes := newBackpressureSession(BackpressureThresholds{Reconnects: 2, ReconnectWindow: time.Minute})
+	signal := s.Backpressure()
+
+	s.pressure.recordEvent(s, SessionReconnected)
+	assert.Empty(t, s.BackpressureReason())
+	s.pressure.recordEvent(s, SessionDisconnected)
+	s.pressure.recordEvent(s, SessionExpiredReconnected)
+
+	assert.Equal(t, "2 reconnects within 1m0s", s.BackpressureReason())
+	assert.True(t, isClosed(signal))
+	assert.Equal(t, []backpressureChange{{true, "2 reconnects within 1m0s"}}, *changes)
+
+	// Reconnects outside the window no longer count, but backpressure is
+	// only lifted after the recovery time.
+	s.pressure.check(s, time.Now().Add(2*time.Minute))
+	assert.NotEmpty(t, s.BackpressureReason())
+}
+
+func TestBackpressureShouldLiftAfterRecovery(t *testing.T) {
+	s, changes := newBackpressureSession(BackpressureThresholds{Latency: 10 * time.Millisecond, Recovery: time.Minute})
+
+	s.pressure.recordLatency(s, 50*time.Millisecond)
+	assert.Contains(t, s.BackpressureReason(), "average latency 50ms")
+	assert.True(t, isClosed(s.Backpressure()))
+
+	for s.pressure.latency > 10*time.Millisecond {
+		s.pressure.recordLatency(s, time.Millisecond)
+	}
+	start := time.Now()
+	s.pressure.check(s, start)
+	s.pressure.check(s, start.Add(30*time.Second))
+	assert.NotEmpty(t, s.BackpressureReason())
+
+	s.pressure.check(s, start.Add(time.Minute))
+	assert.Empty(t, s.BackpressureReason())
+	assert.False(t, isClosed(s.Backpressure()))
+	if assert.Len(t, *changes, 2) {
+		assert.Equal(t, backpressureChange{false, ""}, (*changes)[1])
+	}
+}
+
+func TestBackpressureShouldFollowBacklog(t *testing.T) {
+	s, _ := newBackpressureSession(BackpressureThresholds{Backlog: 1})
+	s.inflight = newInflightLimiter(0)
+	for i := 0; i < 2; i++ {
+		go s.inflight.acquire()
+	}
+	assert.Eventually(t, func() bool {
+		s.pressure.check(s, time.Now())
+		return s.BackpressureReason() == "backlog of 2 over 1"
+	}, time.Second, time.Millisecond)
+	s.inflight.release()
+	s.inflight.release()
+}
+
+func TestBackpressureWithoutOptionShouldNeverSignal(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	s.pressure.recordLatency(s, time.Hour)
+	assert.Nil(t, s.Backpressure())
+	assert.Empty(t, s.BackpressureReason())
+}
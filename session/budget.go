@@ -0,0 +1,52 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultDeadlineMargin is how much time before a context's deadline retry
+// loops stop starting new attempts, unless WithDeadlineMargin is given, so
+// that the caller gets a deadline error on time rather than a late failure
+// from an attempt that couldn't finish.
+const DefaultDeadlineMargin = 100 * time.Millisecond
+
+// Remaining returns the time left until ctx's deadline, or false if it has
+// none.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// outOfBudget returns an error wrapping context.DeadlineExceeded if waiting
+// for wait would leave less than margin before ctx's deadline, or ctx's error
+// if it is done.
+func outOfBudget(ctx context.Context, wait, margin time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	remaining, ok := Remaining(ctx)
+	if !ok || remaining-wait >= margin {
+		return nil
+	}
+	return fmt.Errorf("stopped with %s left before the deadline: %w", remaining.Round(time.Millisecond), context.DeadlineExceeded)
+}
+
+// RetryAttempt describes a failed attempt of a retry loop, as reported to
+// the function given to WithRetryHook, before the loop waits to try again.
+type RetryAttempt struct {
+	Path string
+	// Attempt counts the attempts made, starting at 1.
+	Attempt int
+	Err     error
+	// Backoff is how long the loop is about to wait.
+	Backoff time.Duration
+	// Remaining is the time left until the context's deadline, if
+	// HasDeadline.
+	Remaining   time.Duration
+	HasDeadline bool
+}
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemainingShouldReportDeadline(t *testing.T) {
+	_, ok := Remaining(context.Background())
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	remaining, ok := Remaining(ctx)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second))
+}
+
+func TestOutOfBudgetShouldStopBeforeDeadline(t *testing.T) {
+	assert.NoError(t, outOfBudget(context.Background(), time.Hour, DefaultDeadlineMargin))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, outOfBudget(ctx, 500*time.Millisecond, DefaultDeadlineMargin))
+	err := outOfBudget(ctx, 950*time.Millisecond, DefaultDeadlineMargin)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	cancel()
+	assert.Equal(t, context.Canceled, outOfBudget(ctx, 0, DefaultDeadlineMargin))
+}
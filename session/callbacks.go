@@ -0,0 +1,157 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCallbackTimeout is how long a callback run by the callback pool may
+// take before it is abandoned, unless WithCallbackPool is given a timeout.
+const DefaultCallbackTimeout = 10 * time.Second
+
+// callbackPool runs user callbacks on a fixed number of workers. Callbacks
+// with the same key run one at a time, in the order they were dispatched. A
+// nil pool runs callbacks on the dispatching goroutine.
+type callbackPool struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string][]queuedCallback
+	// ready holds the keys with pending callbacks and none running.
+	ready  []string
+	queued int
+	closed bool
+}
+
+type queuedCallback struct {
+	component string
+	run       func()
+}
+
+func newCallbackPool(s *ZKSession, workers int, timeout time.Duration) *callbackPool {
+	p := &callbackPool{timeout: timeout, pending: make(map[string][]queuedCallback)}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < workers; i++ {
+		Go(s, "callbacks", func() { p.work(s) })
+	}
+	return p
+}
+
+func (p *callbackPool) dispatch(key string, cb queuedCallback) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	queue, busy := p.pending[key]
+	p.pending[key] = append(queue, cb)
+	p.queued++
+	if !busy {
+		p.ready = append(p.ready, key)
+		p.cond.Signal()
+	}
+}
+
+// next waits for a callback to run, returning false once the pool is closed.
+func (p *callbackPool) next() (string, queuedCallback, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.ready) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if p.closed {
+		return "", queuedCallback{}, false
+	}
+	key := p.ready[0]
+	p.ready = p.ready[1:]
+	cb := p.pending[key][0]
+	p.queued--
+	return key, cb, true
+}
+
+// done marks the callback for key finished, readying the next one for it.
+func (p *callbackPool) done(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	queue := p.pending[key][1:]
+	if len(queue) == 0 {
+		delete(p.pending, key)
+		return
+	}
+	p.pending[key] = queue
+	p.ready = append(p.ready, key)
+	p.cond.Signal()
+}
+
+func (p *callbackPool) work(s *ZKSession) {
+	for {
+		key, cb, ok := p.next()
+		if !ok {
+			return
+		}
+
+		finished := make(chan struct{})
+		Go(s, cb.component, func() {
+			defer close(finished)
+			_ = Protect(s, cb.component, cb.run)
+		})
+
+		timer := time.NewTimer(p.timeout)
+		select {
+		case <-finished:
+		case <-timer.C:
+			s.log.Printf("gozk-recipes/session: %s callback still running after %v, no longer waiting for it", cb.component, p.timeout)
+			s.reportError("running callback", fmt.Errorf("%s callback timed out after %v", cb.component, p.timeout), false)
+		}
+		timer.Stop()
+		p.done(key)
+	}
+}
+
+func (p *callbackPool) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.pending = make(map[string][]queuedCallback)
+	p.ready = nil
+	p.queued = 0
+	p.cond.Broadcast()
+}
+
+func (p *callbackPool) depth() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queued
+}
+
+// Dispatch runs callback, a user callback of component such as a watch or
+// cache listener. With WithCallbackPool it is queued for the session's
+// callback pool and Dispatch returns right away, so a slow callback doesn't
+// hold up the recipe delivering it; callbacks dispatched with the same key
+// run one at a time, in dispatch order. Otherwise callback runs before
+// Dispatch returns. Either way a panic in callback is recovered as with
+// Protect.
+func Dispatch(s Session, component, key string, callback func()) {
+	if zs, ok := s.(*ZKSession); ok && zs.callbacks != nil {
+		zs.callbacks.dispatch(component+"/"+key, queuedCallback{component: component, run: callback})
+		return
+	}
+	_ = Protect(s, component, callback)
+}
+
+// CallbackQueueDepth returns the number of callbacks waiting to run on the
+// pool configured with WithCallbackPool.
+func (s *ZKSession) CallbackQueueDepth() int {
+	return s.callbacks.depth()
+}
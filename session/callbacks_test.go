@@ -0,0 +1,89 @@
+package session
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchShouldRunInlineWithoutPool(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	ran := false
+	Dispatch(s, "test", "a", func() { ran = true })
+	assert.True(t, ran)
+
+	Dispatch(s, "test", "a", func() { panic("boom") })
+}
+
+func TestCallbackPoolShouldKeepKeysInOrder(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	s.callbacks = newCallbackPool(s, 4, time.Second)
+	defer s.callbacks.close()
+
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		Dispatch(s, "test", "a", func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	for i := range got {
+		assert.Equal(t, i, got[i])
+	}
+	assert.Len(t, got, 20)
+}
+
+func TestCallbackPoolShouldNotLetSlowKeysBlockOthers(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	s.callbacks = newCallbackPool(s, 2, time.Second)
+	defer s.callbacks.close()
+
+	release := make(chan struct{})
+	defer close(release)
+	Dispatch(s, "test", "slow", func() { <-release })
+	Dispatch(s, "test", "slow", func() {})
+
+	done := make(chan struct{})
+	Dispatch(s, "test", "fast", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("callback held up by a slow key")
+	}
+	assert.Equal(t, 1, s.CallbackQueueDepth())
+}
+
+func TestCallbackPoolShouldAbandonTimedOutCallbacks(t *testing.T) {
+	errs := make(chan error, 1)
+	s := &ZKSession{opts: WithErrorChannel(errs)(SessionOpts{}), log: &nullLogger{}}
+	s.callbacks = newCallbackPool(s, 1, 20*time.Millisecond)
+	defer s.callbacks.close()
+
+	release := make(chan struct{})
+	defer close(release)
+	Dispatch(s, "test", "a", func() { <-release })
+
+	done := make(chan struct{})
+	Dispatch(s, "test", "a", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out callback kept holding its worker")
+	}
+
+	var internal *InternalError
+	assert.True(t, errors.As(<-errs, &internal))
+	assert.Equal(t, "running callback", internal.Op)
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrUnknownWatchKind is returned by RemoveWatches for a kind other than
+// "exists", "data" or "children".
+var ErrUnknownWatchKind = errors.New("unknown watch kind")
+
+// WatchRemover is implemented by sessions that can remove the watches they
+// set from the server, with the removeWatches call of ZooKeeper 3.5 and
+// later. kind is "exists", "data" or "children", naming the watches of
+// ExistsW, GetW and ChildrenW. The server keeps a single watch for every
+// watch of a kind a session sets on a path, so an implementation must only
+// remove it once no other watch set through the session on path and kind is
+// outstanding.
+//
+// ZKSession implements it, and ExistsWContext and the others give their
+// watches up through it, but only as an emulation for now: gozk has no
+// removeWatches, so the watches stay on the server, and in the client, until
+// they fire or the session expires, as they would with servers older than
+// 3.5.
+type WatchRemover interface {
+	RemoveWatches(path, kind string) error
+}
+
+// RemoveWatches gives up the watches of kind set through the session on
+// path, as the removeWatches call of ZooKeeper 3.5 does. The call isn't
+// available through gozk, so it does nothing but count them in
+// Stats.AbandonedWatches, and warn the first time that they stay on the
+// server until they fire or the session expires.
+func (s *ZKSession) RemoveWatches(path, kind string) error {
+	switch kind {
+	case "exists", "data", "children":
+	default:
+		return fmt.Errorf("removing %q watches on %q: %w", kind, path, ErrUnknownWatchKind)
+	}
+	s.removeWatchesWarning.Do(func() {
+		s.log.Printf("gozk-recipes/session: watches can't be removed from the server without removeWatches, given up watches stay until they fire or the session expires")
+	})
+	s.abandonWatch(path, kind)
+	return nil
+}
+
+// ExistsWContext is s.ExistsW(path), except that the watch is given up once
+// ctx is done: its channel is then closed without an event, and the watch is
+// removed from the server if s is a WatchRemover. Asking for a watch after
+// ctx is done fails with ctx.Err().
+func ExistsWContext(ctx context.Context, s Session, path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	stat, watch, err := s.ExistsW(path)
+	return stat, cancellable(ctx, s, path, "exists", watch), err
+}
+
+// GetWContext is s.GetW(path), with the watch given up once ctx is done as
+// with ExistsWContext.
+func GetWContext(ctx context.Context, s Session, path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, nil, err
+	}
+	value, stat, watch, err := s.GetW(path)
+	return value, stat, cancellable(ctx, s, path, "data", watch), err
+}
+
+// ChildrenWContext is s.ChildrenW(path), with the watch given up once ctx is
+// done as with ExistsWContext.
+func ChildrenWContext(ctx context.Context, s Session, path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	children, stat, watch, err := s.ChildrenW(path)
+	return children, stat, cancellable(ctx, s, path, "children", watch), err
+}
+
+// cancellable forwards watch through a new channel until ctx is done, then
+// closes it and gives the watch up.
+func cancellable(ctx context.Context, s Session, path, kind string, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if watch == nil {
+		return nil
+	}
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		defer close(forwarded)
+		select {
+		case event, ok := <-watch:
+			if ok {
+				forwarded <- event
+			}
+		case <-ctx.Done():
+			AbandonWatch(s, path, kind)
+		}
+	}()
+	return forwarded
+}
+
+// AbandonWatch gives up a watch of kind on path that the caller won't wait
+// for anymore, such as the watch still armed when a recipe stops: it is
+// removed with RemoveWatches if s is a WatchRemover, and counted in
+// Stats.AbandonedWatches otherwise.
+func AbandonWatch(s Session, path, kind string) {
+	if remover, ok := s.(WatchRemover); ok && remover.RemoveWatches(path, kind) == nil {
+		return
+	}
+	if abandoner, ok := s.(interface{ abandonWatch(path, kind string) }); ok {
+		abandoner.abandonWatch(path, kind)
+	}
+}
+
+func (s *ZKSession) abandonWatch(path, kind string) {
+	if s.stats != nil {
+		atomic.AddUint64(&s.stats.abandonedWatches, 1)
+	}
+}
+
+func (v *View) abandonWatch(path, kind string) {
+	AbandonWatch(v.parent, v.full(path), kind)
+}
+
+func (r *ReadOnlySession) abandonWatch(path, kind string) {
+	AbandonWatch(r.parent, path, kind)
+}
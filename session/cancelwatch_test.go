@@ -0,0 +1,90 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+// removerSession records the watches removed through it.
+type removerSession struct {
+	pathSession
+	mu      sync.Mutex
+	removed []string
+}
+
+func (r *removerSession) RemoveWatches(path, kind string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, kind+" "+path)
+	return nil
+}
+
+func (r *removerSession) removedWatches() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.removed...)
+}
+
+func TestGetWContextShouldForwardEvents(t *testing.T) {
+	parent := &removerSession{pathSession: pathSession{watches: make(chan zookeeper.Event, 1)}}
+	_, _, watch, err := GetWContext(context.Background(), parent, "/config")
+	assert.NoError(t, err)
+
+	parent.watches <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: "/config"}
+	select {
+	case event, ok := <-watch:
+		assert.True(t, ok)
+		assert.Equal(t, "/config", event.Path)
+	case <-time.After(time.Second):
+		t.Fatal("event not forwarded")
+	}
+	assert.Empty(t, parent.removedWatches())
+}
+
+func TestGetWContextShouldRemoveWatchOnCancel(t *testing.T) {
+	parent := &removerSession{pathSession: pathSession{watches: make(chan zookeeper.Event, 1)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, watch, err := GetWContext(ctx, NewView(parent, "/app1"), "/config")
+	assert.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-watch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("watch not closed")
+	}
+	assert.Equal(t, []string{"data /app1/config"}, parent.removedWatches())
+
+	_, _, _, err = GetWContext(ctx, parent, "/config")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAbandonedWatchShouldBeCounted(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}, stats: newSessionStats()}
+	watch := make(chan zookeeper.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarded := cancellable(ctx, s, "/config", "children", s.trackWatch("/config", "children", nil, watch))
+
+	cancel()
+	<-forwarded
+	stats := s.Stats()
+	assert.Equal(t, uint64(1), stats.AbandonedWatches)
+	assert.Equal(t, int64(1), stats.Watches)
+}
+
+func TestRemoveWatchesShouldWarnOnceAndCount(t *testing.T) {
+	rec := &recordingLogger{}
+	s := &ZKSession{log: rec, stats: newSessionStats()}
+	assert.NoError(t, s.RemoveWatches("/config", "data"))
+	assert.NoError(t, s.RemoveWatches("/config", "children"))
+	assert.ErrorIs(t, s.RemoveWatches("/config", "any"), ErrUnknownWatchKind)
+
+	assert.Len(t, rec.lines, 1)
+	assert.Equal(t, uint64(2), s.Stats().AbandonedWatches)
+}
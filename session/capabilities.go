@@ -0,0 +1,167 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/gozk-recipes/retry"
+)
+
+// driverModule is the module the session talks to ZooKeeper through.
+const driverModule = "github.com/Shopify/gozk"
+
+// Capabilities describes the optional features of a session and the server
+// it is connected to, for operators to check a fleet's configuration; see
+// (*ZKSession).Capabilities.
+type Capabilities struct {
+	// Driver is the client the session talks to ZooKeeper through, and
+	// DriverVersion the version of its module the program was built with, or
+	// "unknown" when the build didn't record it.
+	Driver        string `json:"driver"`
+	DriverVersion string `json:"driver_version"`
+	// TLS, SASL and PersistentWatches report whether the session uses TLS
+	// connections, SASL authentication and persistent watches. The driver
+	// supports none of them, so they are currently always false.
+	TLS               bool `json:"tls"`
+	SASL              bool `json:"sasl"`
+	PersistentWatches bool `json:"persistent_watches"`
+	// AuthSchemes are the schemes of the credentials given to WithAuth.
+	AuthSchemes []string `json:"auth_schemes,omitempty"`
+	// Namespace is the node given to WithNamespace, if any.
+	Namespace string `json:"namespace,omitempty"`
+	// RetryPolicy is the policy given to WithRetryPolicy, if any.
+	RetryPolicy *retry.Policy `json:"retry_policy,omitempty"`
+	// Features are the other optional features enabled, named after their
+	// options, such as "compression" for WithCompression, and sorted.
+	Features []string `json:"features"`
+	// Server is the server the session is connected to, and ServerVersion
+	// the version it reports to srvr, or ServerVersionError why it couldn't
+	// be found out, as when srvr isn't whitelisted.
+	Server             string `json:"server,omitempty"`
+	ServerVersion      string `json:"server_version,omitempty"`
+	ServerVersionError string `json:"server_version_error,omitempty"`
+}
+
+// Capabilities reports the session's optional features and the version of
+// the server it is connected to. The version is asked with srvr, which must
+// be whitelisted on the servers, and the question gives up after the timeout
+// given to WithServerProber, or DefaultProbeTimeout.
+func (s *ZKSession) Capabilities() Capabilities {
+	c := Capabilities{
+		Driver:        driverModule,
+		DriverVersion: driverVersion(),
+		Namespace:     s.opts.namespace,
+		Features:      s.opts.features(),
+	}
+	for _, auth := range s.opts.auth {
+		c.AuthSchemes = append(c.AuthSchemes, auth.Scheme)
+	}
+	if policy, ok := s.RetryPolicy(); ok {
+		c.RetryPolicy = &policy
+	}
+
+	if s.conn == nil {
+		return c
+	}
+	if c.Server = s.CurrentServer(); c.Server == "" {
+		c.ServerVersionError = "not connected"
+		return c
+	}
+	timeout := s.opts.probeTimeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	reply, err := fourLetterWord(ctx, c.Server, "srvr")
+	if err != nil {
+		c.ServerVersionError = err.Error()
+		return c
+	}
+	if c.ServerVersion = parseServerVersion(reply); c.ServerVersion == "" {
+		c.ServerVersionError = "srvr didn't report a version"
+	}
+	return c
+}
+
+// driverVersion returns the version of the driver's module in the build.
+func driverVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != driverModule {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
+
+// parseServerVersion extracts the version from a srvr reply, such as
+// "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7" from "Zookeeper version:
+// 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on ...".
+func parseServerVersion(reply string) string {
+	scanner := bufio.NewScanner(strings.NewReader(reply))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "Zookeeper version" {
+			continue
+		}
+		version, _, _ := strings.Cut(value, ",")
+		return strings.TrimSpace(version)
+	}
+	return ""
+}
+
+// features names the optional features enabled in so, by the options
+// enabling them.
+func (so SessionOpts) features() []string {
+	enabled := map[string]bool{
+		"backpressure":        so.backpressure != nil,
+		"children_hedging":    len(so.childrenHedging) > 0,
+		"client_info":         so.clientInfoDir != "",
+		"compression":         so.compressThreshold > 0,
+		"dry_run":             so.dryRun,
+		"encryption":          len(so.encryptPrefixes) > 0,
+		"expiry_simulation":   so.expirySimulate,
+		"expvar":              so.expvarName != "",
+		"failure_diagnostics": so.diagnostics,
+		"hedged_reads":        so.hedgeAfter > 0,
+		"interceptors":        len(so.interceptors) > 0,
+		"keepalive":           so.keepalive > 0,
+		"max_inflight":        so.maxInflight > 0,
+		"max_lifetime":        so.maxLifetime > 0,
+		"monotonic_reads":     so.monotonicReads,
+		"namespace_creation":  so.createNamespace,
+		"own_write_ordering":  so.orderOwnWrites,
+		"ownership_tags":      so.ownershipTags,
+		"path_limits":         so.pathLimits != PathLimits{},
+		"preferred_server":    so.preferredServer != "",
+		"protected_paths":     len(so.protectedPaths) > 0,
+		"registry":            so.registered,
+		"rtt_tracking":        so.rttInterval > 0,
+		"server_probing":      so.prober != nil,
+		"server_role":         so.role != AnyServer,
+		"strict":              so.strict,
+		"timeline":            so.timelinePath != "",
+		"transformers":        len(so.transformers) > 0,
+		"validation":          len(so.writeValidators) > 0 || len(so.readValidators) > 0,
+		"watchdog":            so.watchdog > 0,
+	}
+	var features []string
+	for name, on := range enabled {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServerVersionShouldReadSrvrReply(t *testing.T) {
+	reply := "Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC\nLatency min/avg/max: 0/0.0/0\nMode: follower\n"
+	assert.Equal(t, "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7", parseServerVersion(reply))
+	assert.Equal(t, "", parseServerVersion("Mode: standalone\n"))
+}
+
+func TestCapabilitiesShouldReportConfiguration(t *testing.T) {
+	policy := retry.Policy{Initial: time.Millisecond, Max: time.Second}
+	opts := WithNamespace("tenant")(SessionOpts{})
+	opts = WithAuth("digest", "user:password")(opts)
+	opts = WithRetryPolicy(policy)(opts)
+	opts = WithCompression(1024)(opts)
+	opts = WithHedgedReads(10 * time.Millisecond)(opts)
+	s := &ZKSession{opts: opts}
+
+	c := s.Capabilities()
+	assert.Equal(t, driverModule, c.Driver)
+	assert.NotEmpty(t, c.DriverVersion)
+	assert.False(t, c.TLS)
+	assert.Equal(t, "/tenant", c.Namespace)
+	assert.Equal(t, []string{"digest"}, c.AuthSchemes)
+	assert.Equal(t, &policy, c.RetryPolicy)
+	assert.Equal(t, []string{"compression", "hedged_reads"}, c.Features)
+	assert.Empty(t, c.ServerVersion)
+
+	assert.Nil(t, (&ZKSession{}).Capabilities().RetryPolicy)
+}
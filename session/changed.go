@@ -0,0 +1,73 @@
+package session
+
+import zookeeper "github.com/Shopify/gozk"
+
+// StatDelta describes how a node changed between two observations of its
+// Stat. The version deltas count the changes to the node's data, children
+// and ACL, and are only meaningful if the node is the same node at both
+// observations: neither Created, Deleted nor Recreated.
+type StatDelta struct {
+	// Created is set if the node didn't exist at the first observation.
+	Created bool
+	// Deleted is set if the node doesn't exist at the second observation.
+	Deleted bool
+	// Recreated is set if the node was deleted and created again in between.
+	Recreated bool
+
+	Data     int
+	Children int
+	ACL      int
+}
+
+// Changed reports whether the node changed at all.
+func (d StatDelta) Changed() bool {
+	return d.Created || d.Deleted || d.Recreated || d.Data != 0 || d.Children != 0 || d.ACL != 0
+}
+
+// statVersions is the part of a Stat compared by Delta.
+type statVersions struct {
+	czxid    int64
+	data     int
+	children int
+	acl      int
+}
+
+func versionsOf(stat *zookeeper.Stat) *statVersions {
+	if stat == nil {
+		return nil
+	}
+	return &statVersions{czxid: stat.Czxid(), data: stat.Version(), children: stat.CVersion(), acl: stat.AVersion()}
+}
+
+func (v *statVersions) delta(to *statVersions) StatDelta {
+	switch {
+	case v == nil && to == nil:
+		return StatDelta{}
+	case v == nil:
+		return StatDelta{Created: true}
+	case to == nil:
+		return StatDelta{Deleted: true}
+	case v.czxid != to.czxid:
+		return StatDelta{Recreated: true}
+	}
+	return StatDelta{Data: to.data - v.data, Children: to.children - v.children, ACL: to.acl - v.acl}
+}
+
+// Delta returns how a node changed from the Stat from to the Stat to, either
+// of which is nil if the node didn't exist.
+func Delta(from, to *zookeeper.Stat) StatDelta {
+	return versionsOf(from).delta(versionsOf(to))
+}
+
+// ChangedSince reads path's Stat and returns how the node changed since since,
+// a Stat observed earlier or nil if the node didn't exist, along with the
+// current Stat to pass to the next call. It costs a single Exists call and
+// sets no watch, for consumers that poll occasionally and refresh only what
+// changed.
+func ChangedSince(s Session, path string, since *zookeeper.Stat) (StatDelta, *zookeeper.Stat, error) {
+	stat, err := s.Exists(path)
+	if err != nil {
+		return StatDelta{}, nil, err
+	}
+	return Delta(since, stat), stat, nil
+}
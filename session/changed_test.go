@@ -0,0 +1,38 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatVersionsDelta(t *testing.T) {
+	before := &statVersions{czxid: 10, data: 1, children: 4, acl: 0}
+
+	assert.Equal(t, StatDelta{}, before.delta(before))
+	assert.False(t, before.delta(before).Changed())
+	assert.Equal(t, StatDelta{Data: 2, Children: 1}, before.delta(&statVersions{czxid: 10, data: 3, children: 5}))
+	assert.Equal(t, StatDelta{Recreated: true}, before.delta(&statVersions{czxid: 20}))
+	assert.Equal(t, StatDelta{Deleted: true}, before.delta(nil))
+	assert.Equal(t, StatDelta{Created: true}, (*statVersions)(nil).delta(before))
+	assert.False(t, (*statVersions)(nil).delta(nil).Changed())
+}
+
+func TestChangedSinceShouldReportDeltas(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		delta, stat, err := ChangedSince(session, "/test", nil)
+		assert.NoError(t, err)
+		assert.True(t, delta.Created)
+
+		session.Set("/test", "foo", -1)
+		session.Create("/test/a", "", 0, defaultACLs)
+		delta, stat, err = ChangedSince(session, "/test", stat)
+		assert.NoError(t, err)
+		assert.Equal(t, StatDelta{Data: 1, Children: 1}, delta)
+
+		delta, _, err = ChangedSince(session, "/test", stat)
+		assert.NoError(t, err)
+		assert.False(t, delta.Changed())
+	})
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"fmt"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ChildrenMatching returns the sorted names of the children of path that
+// match pattern, using path.Match syntax. ZooKeeper can't filter on the
+// server, so all children are still fetched, but only matching names are
+// retained.
+func ChildrenMatching(s Session, path, pattern string) ([]string, *zookeeper.Stat, error) {
+	match, err := globMatcher(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	children, stat, err := s.Children(path)
+	return filterChildren(children, match), stat, err
+}
+
+// ChildrenMatchingW is like ChildrenMatching, and also leaves a watch on path.
+// The watch fires on any change to path's children, matching or not.
+func ChildrenMatchingW(s Session, path, pattern string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	match, err := globMatcher(pattern)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	children, stat, watch, err := s.ChildrenW(path)
+	return filterChildren(children, match), stat, watch, err
+}
+
+// ChildrenWithPrefix returns the sorted names of the children of path that
+// start with prefix.
+func ChildrenWithPrefix(s Session, path, prefix string) ([]string, *zookeeper.Stat, error) {
+	children, stat, err := s.Children(path)
+	return filterChildren(children, prefixMatcher(prefix)), stat, err
+}
+
+// ChildrenWithPrefixW is like ChildrenWithPrefix, and also leaves a watch on
+// path. The watch fires on any change to path's children.
+func ChildrenWithPrefixW(s Session, path, prefix string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	children, stat, watch, err := s.ChildrenW(path)
+	return filterChildren(children, prefixMatcher(prefix)), stat, watch, err
+}
+
+// childrenStatsConcurrency is how many Exists calls ChildrenStats has in
+// flight at once.
+const childrenStatsConcurrency = 16
+
+// ChildStat is the name of a child and its Stat.
+type ChildStat struct {
+	Name string
+	Stat *zookeeper.Stat
+}
+
+// ChildrenStats returns the children of path, sorted by name, along with the
+// Stat of each, for callers that need their versions or timestamps but not
+// their data. ZooKeeper has no call returning them with the listing, and gozk
+// no multi-read, so each Stat is read with Exists, several at once so the
+// requests are pipelined on the connection; see WithChildrenHedging to tune
+// how many, and hedge the slow ones, for large parents. Children deleted
+// meanwhile are left out. The Stat returned is path's.
+func ChildrenStats(s Session, path string) ([]ChildStat, *zookeeper.Stat, error) {
+	children, stat, err := s.Children(path)
+	if err != nil {
+		return nil, stat, err
+	}
+	sort.Strings(children)
+
+	parent := path
+	if parent == "/" {
+		parent = ""
+	}
+	stats := make([]ChildStat, len(children))
+	errs := make([]error, len(children))
+	concurrency := childrenStatsConcurrency
+	if z, ok := s.(interface{ statsConcurrency(string) int }); ok {
+		concurrency = z.statsConcurrency(path)
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, name := range children {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stats[i].Name = name
+			stats[i].Stat, errs[i] = s.Exists(parent + "/" + name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	found := stats[:0]
+	for i, child := range stats {
+		if errs[i] != nil {
+			return nil, stat, errs[i]
+		}
+		if child.Stat != nil {
+			found = append(found, child)
+		}
+	}
+	return found, stat, nil
+}
+
+func globMatcher(pattern string) (func(string) bool, error) {
+	if _, err := gopath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := gopath.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+func prefixMatcher(prefix string) func(string) bool {
+	return func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	}
+}
+
+// filterChildren keeps the names accepted by match, in place, and sorts them.
+// Filtering in place avoids a second copy of very large listings.
+func filterChildren(children []string, match func(string) bool) []string {
+	if children == nil {
+		return nil
+	}
+	matched := children[:0]
+	for _, name := range children {
+		if match(name) {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterChildrenShouldMatchGlob(t *testing.T) {
+	match, err := globMatcher("worker-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	children := []string{"worker-2", "lock-1", "worker-1", "workers"}
+	assert.Equal(t, []string{"worker-1", "worker-2"}, filterChildren(children, match))
+}
+
+func TestFilterChildrenShouldMatchPrefix(t *testing.T) {
+	children := []string{"b-1", "a-2", "a-1"}
+	assert.Equal(t, []string{"a-1", "a-2"}, filterChildren(children, prefixMatcher("a-")))
+	assert.Nil(t, filterChildren(nil, prefixMatcher("a-")))
+}
+
+func TestGlobMatcherShouldRejectBadPattern(t *testing.T) {
+	_, err := globMatcher("[")
+	assert.Error(t, err)
+}
+
+// statSession lists children and returns a Stat for those in stats.
+type statSession struct {
+	Session
+	children []string
+	stats    map[string]*zookeeper.Stat
+}
+
+func (s *statSession) Children(path string) ([]string, *zookeeper.Stat, error) {
+	return s.children, nil, nil
+}
+
+func (s *statSession) Exists(path string) (*zookeeper.Stat, error) {
+	if path == "/jobs/broken" {
+		return nil, &zookeeper.Error{Op: "exists", Code: zookeeper.ZCONNECTIONLOSS, Path: path}
+	}
+	return s.stats[path], nil
+}
+
+func TestChildrenStatsShouldSkipDeletedChildren(t *testing.T) {
+	stat := &zookeeper.Stat{}
+	s := &statSession{children: []string{"job-2", "gone", "job-1"}, stats: map[string]*zookeeper.Stat{"/jobs/job-1": stat, "/jobs/job-2": stat}}
+
+	children, _, err := ChildrenStats(s, "/jobs")
+	assert.NoError(t, err)
+	assert.Equal(t, []ChildStat{{"job-1", stat}, {"job-2", stat}}, children)
+
+	s.children = append(s.children, "broken")
+	_, _, err = ChildrenStats(s, "/jobs")
+	assert.True(t, zookeeper.IsError(err, zookeeper.ZCONNECTIONLOSS))
+}
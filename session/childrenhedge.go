@@ -0,0 +1,209 @@
+package session
+
+import (
+	gopath "path"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHedgePercentile, DefaultMinHedgeDelay and DefaultMaxHedgeDelay
+	// are the ChildrenHedging settings used when left zero.
+	DefaultHedgePercentile = 0.95
+	DefaultMinHedgeDelay   = 2 * time.Millisecond
+	DefaultMaxHedgeDelay   = time.Second
+
+	// hedgeWindow is how many recent latencies the hedging delay is computed
+	// from, hedgeWarmup how many must be known before it adapts, and
+	// hedgeRefresh how many are recorded between computations.
+	hedgeWindow  = 256
+	hedgeWarmup  = 32
+	hedgeRefresh = 16
+)
+
+// ChildrenHedging configures how WithChildrenHedging hedges the reads of the
+// children of large parents.
+type ChildrenHedging struct {
+	// Percentile is the share of recent reads that complete before a read
+	// is hedged, DefaultHedgePercentile if zero.
+	Percentile float64
+	// MinDelay is the shortest hedging delay, so that reads are not hedged
+	// over scheduling noise alone, DefaultMinHedgeDelay if zero.
+	MinDelay time.Duration
+	// MaxDelay is the longest hedging delay, and the one used until enough
+	// latencies are known, DefaultMaxHedgeDelay if zero.
+	MaxDelay time.Duration
+	// Concurrency is how many Exists calls ChildrenStats has in flight at
+	// once for these parents, 16 if zero.
+	Concurrency int
+}
+
+type prefixHedging struct {
+	prefix  string
+	hedging ChildrenHedging
+}
+
+// WithChildrenHedging hedges the reads of the children of the nodes at or
+// below prefix, such as service registries with thousands of members, with a
+// delay that adapts to the latencies seen: a listing with Children, or the
+// Stat of a child read with Exists, as ChildrenStats does for each child, is
+// sent a second time once it has taken longer than Percentile of the recent
+// reads of the same kind did. Listings and stat reads are tracked apart, as a
+// listing of a large node takes much longer than a stat, so ChildrenStats's
+// many small reads are hedged on their own latencies and a single slow
+// server, during a leader election say, holds up neither.
+//
+// Like WithHedgedReads, which it takes precedence over for these paths, only
+// reads that don't set watches are hedged, and HedgedReads counts the
+// attempts sent. Where several prefixes match a path the longest one wins.
+func WithChildrenHedging(prefix string, hedging ChildrenHedging) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.childrenHedging = append(so.childrenHedging, prefixHedging{prefix: prefix, hedging: hedging})
+		return so
+	}
+}
+
+// childrenHedge is the state of one WithChildrenHedging prefix.
+type childrenHedge struct {
+	prefix  string
+	hedging ChildrenHedging
+	// listings and stats are the latencies of the listings of the nodes
+	// at or below prefix, and of the stat reads of their children.
+	listings latencyWindow
+	stats    latencyWindow
+}
+
+func newChildrenHedges(configs []prefixHedging) []*childrenHedge {
+	var hedges []*childrenHedge
+	for _, c := range configs {
+		h := &childrenHedge{prefix: c.prefix, hedging: c.hedging}
+		if h.hedging.Percentile == 0 {
+			h.hedging.Percentile = DefaultHedgePercentile
+		}
+		if h.hedging.MinDelay == 0 {
+			h.hedging.MinDelay = DefaultMinHedgeDelay
+		}
+		if h.hedging.MaxDelay == 0 {
+			h.hedging.MaxDelay = DefaultMaxHedgeDelay
+		}
+		if h.hedging.Concurrency == 0 {
+			h.hedging.Concurrency = childrenStatsConcurrency
+		}
+		hedges = append(hedges, h)
+	}
+	return hedges
+}
+
+// childrenHedgeFor returns the hedging of the children of parent, or nil.
+func (s *ZKSession) childrenHedgeFor(parent string) *childrenHedge {
+	var found *childrenHedge
+	for _, h := range s.childrenHedges {
+		if underPrefix(parent, h.prefix) && (found == nil || len(h.prefix) > len(found.prefix)) {
+			found = h
+		}
+	}
+	return found
+}
+
+// hedgedListing is hedged for a listing of path, with the adaptive delay of
+// its prefix if it has one.
+func hedgedListing[T any](s *ZKSession, path string, read func() (T, error)) (T, error) {
+	h := s.childrenHedgeFor(path)
+	if h == nil {
+		return hedged(s, read)
+	}
+	return hedgedAfter(s, h.delay(&h.listings), &h.listings, read)
+}
+
+// hedgedStat is hedged for a stat read of path, with the adaptive delay of
+// its parent's prefix if it has one.
+func hedgedStat[T any](s *ZKSession, path string, read func() (T, error)) (T, error) {
+	if path == "/" {
+		return hedged(s, read)
+	}
+	h := s.childrenHedgeFor(gopath.Dir(path))
+	if h == nil {
+		return hedged(s, read)
+	}
+	return hedgedAfter(s, h.delay(&h.stats), &h.stats, read)
+}
+
+// delay returns the hedging delay for the reads tracked in w.
+func (h *childrenHedge) delay(w *latencyWindow) time.Duration {
+	d, ok := w.percentile(h.hedging.Percentile)
+	switch {
+	case !ok || d > h.hedging.MaxDelay:
+		return h.hedging.MaxDelay
+	case d < h.hedging.MinDelay:
+		return h.hedging.MinDelay
+	}
+	return d
+}
+
+// statsConcurrency returns how many Exists calls ChildrenStats has in flight
+// at once for the children of parent.
+func (s *ZKSession) statsConcurrency(parent string) int {
+	if h := s.childrenHedgeFor(parent); h != nil {
+		return h.hedging.Concurrency
+	}
+	return childrenStatsConcurrency
+}
+
+// ChildrenHedgeDelays returns the delays after which a listing of parent, and
+// a stat read of one of its children, are currently hedged, or false if
+// parent isn't below a prefix given to WithChildrenHedging.
+func (s *ZKSession) ChildrenHedgeDelays(parent string) (listing, stat time.Duration, ok bool) {
+	h := s.childrenHedgeFor(parent)
+	if h == nil {
+		return 0, 0, false
+	}
+	return h.delay(&h.listings), h.delay(&h.stats), true
+}
+
+// latencyWindow keeps the most recent latencies of a kind of read, and a
+// percentile of them refreshed every few records rather than sorted for each
+// read.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgeWindow]time.Duration
+	n, next int
+	// stale counts the records since cached was computed, for share
+	// cachedAt.
+	stale    int
+	cached   time.Duration
+	cachedAt float64
+}
+
+func (w *latencyWindow) record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeWindow
+	if w.n < hedgeWindow {
+		w.n++
+	}
+	w.stale++
+}
+
+// percentile returns the latency below which share p of the recorded ones
+// fall, or false until enough have been recorded.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.n < hedgeWarmup {
+		return 0, false
+	}
+	if w.stale < hedgeRefresh && w.cachedAt == p {
+		return w.cached, true
+	}
+	sorted := make([]time.Duration, w.n)
+	copy(sorted, w.samples[:w.n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(w.n))
+	if i >= w.n {
+		i = w.n - 1
+	}
+	w.cached, w.cachedAt, w.stale = sorted[i], p, 0
+	return w.cached, true
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWindowShouldTrackPercentile(t *testing.T) {
+	var w latencyWindow
+	for i := 1; i < hedgeWarmup; i++ {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := w.percentile(0.5)
+	assert.False(t, ok)
+
+	w.record(hedgeWarmup * time.Millisecond)
+	d, ok := w.percentile(0.5)
+	assert.True(t, ok)
+	assert.Equal(t, (hedgeWarmup/2+1)*time.Millisecond, d)
+
+	// Old latencies fall out of the window.
+	for i := 0; i < hedgeWindow; i++ {
+		w.record(time.Second)
+	}
+	d, _ = w.percentile(0.5)
+	assert.Equal(t, time.Second, d)
+}
+
+func TestChildrenHedgingShouldAdaptDelayPerPrefix(t *testing.T) {
+	opts := WithChildrenHedging("/services", ChildrenHedging{MinDelay: time.Millisecond, MaxDelay: 100 * time.Millisecond})(SessionOpts{})
+	opts = WithChildrenHedging("/services/big", ChildrenHedging{Concurrency: 64})(opts)
+	s := &ZKSession{opts: opts, childrenHedges: newChildrenHedges(opts.childrenHedging)}
+
+	_, _, ok := s.ChildrenHedgeDelays("/other")
+	assert.False(t, ok)
+	assert.Equal(t, childrenStatsConcurrency, s.statsConcurrency("/services/web"))
+	assert.Equal(t, 64, s.statsConcurrency("/services/big"))
+
+	listing, stat, ok := s.ChildrenHedgeDelays("/services/web")
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, listing)
+	assert.Equal(t, 100*time.Millisecond, stat)
+
+	for i := 0; i < hedgeWarmup; i++ {
+		_, err := hedgedStat(s, "/services/web/i-1", func() (int, error) { return 1, nil })
+		assert.NoError(t, err)
+	}
+	listing, stat, _ = s.ChildrenHedgeDelays("/services/web")
+	assert.Equal(t, 100*time.Millisecond, listing)
+	assert.Equal(t, time.Millisecond, stat)
+
+	// Stat reads are now hedged quickly.
+	var calls int32
+	start := time.Now()
+	value, err := hedgedStat(s, "/services/web/i-2", func() (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			time.Sleep(time.Second)
+		}
+		return int(n), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, uint64(1), s.HedgedReads())
+}
+
+func TestValidateShouldRejectInvalidChildrenHedging(t *testing.T) {
+	opts := SessionOpts{servers: []string{"zk1:2181"}, sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout}
+	opts = WithChildrenHedging("services", ChildrenHedging{})(opts)
+	opts = WithChildrenHedging("/a", ChildrenHedging{Percentile: 1})(opts)
+	opts = WithChildrenHedging("/b", ChildrenHedging{MinDelay: time.Second, MaxDelay: time.Millisecond})(opts)
+	err := opts.Validate()
+	assert.Equal(t, `invalid session options: children hedging prefix "services" must be an absolute path; children hedging percentile for "/a" must be between 0 and 1, got 1; children hedging maximum delay for "/b" must not be shorter than its minimum`, err.Error())
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ClientInfo identifies the application using a session.
+type ClientInfo struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+	Host    string `json:"host"`
+}
+
+func (c ClientInfo) String() string {
+	s := c.Service
+	if c.Version != "" {
+		s += "/" + c.Version
+	}
+	return s + " (" + c.Host + ")"
+}
+
+// WithClientInfo identifies the application using the session. Its log lines
+// are prefixed with info, which DebugInfo also reports. The host defaults to
+// the machine's host name.
+//
+// ZooKeeper has no way for clients to identify themselves in the server's
+// connection dumps; see WithClientInfoNode for a way to match them with
+// applications.
+func WithClientInfo(info ClientInfo) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.clientInfo = info
+		return so
+	}
+}
+
+// WithClientInfoNode writes the session's ClientInfo as JSON to an ephemeral
+// sequential node under dir, created along with its parents if needed, and
+// again whenever the session is re-established after an expiry. The node's
+// ephemeral owner is the session ID, so the sessions listed by the servers'
+// cons command can be matched with the applications owning them.
+func WithClientInfoNode(dir string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.clientInfoDir = dir
+		return so
+	}
+}
+
+// ClientInfo returns the identification given with WithClientInfo.
+func (s *ZKSession) ClientInfo() ClientInfo {
+	return s.opts.clientInfo
+}
+
+// withHost fills in the host name of an identification that lacks one.
+func (c ClientInfo) withHost() ClientInfo {
+	if c != (ClientInfo{}) && c.Host == "" {
+		c.Host, _ = os.Hostname()
+	}
+	return c
+}
+
+// clientLogger prefixes every line with the client's identification.
+type clientLogger struct {
+	logger stdLogger
+	prefix string
+}
+
+func (l *clientLogger) Printf(format string, v ...interface{}) {
+	l.logger.Printf("[%s] %s", l.prefix, fmt.Sprintf(format, v...))
+}
+
+// writeClientInfoNode creates the session's client info node.
+func (s *ZKSession) writeClientInfoNode() error {
+	data, err := json.Marshal(s.opts.clientInfo)
+	if err != nil {
+		return err
+	}
+	acl := s.DefaultACL()
+	dir := "/" + strings.Trim(s.opts.clientInfoDir, "/")
+	parts := strings.Split(strings.TrimPrefix(dir, "/"), "/")
+	for i := range parts {
+		parent := "/" + strings.Join(parts[:i+1], "/")
+		if _, err := s.Create(parent, "", 0, acl); err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return fmt.Errorf("creating client info node under %s: %w", dir, err)
+		}
+	}
+	if _, err := s.Create(dir+"/client-", string(data), zookeeper.EPHEMERAL|zookeeper.SEQUENCE, acl); err != nil {
+		return fmt.Errorf("creating client info node under %s: %w", dir, err)
+	}
+	return nil
+}
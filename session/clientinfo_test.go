@@ -0,0 +1,28 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientInfoShouldPrefixLogLines(t *testing.T) {
+	info := ClientInfo{Service: "billing", Version: "1.2.3", Host: "web-1"}
+	assert.Equal(t, "billing/1.2.3 (web-1)", info.String())
+
+	rec := &recordingLogger{}
+	(&clientLogger{logger: rec, prefix: info.String()}).Printf("gozk-recipes/session: %s", "connected")
+	assert.Equal(t, []string{"[billing/1.2.3 (web-1)] gozk-recipes/session: connected"}, rec.lines)
+}
+
+func TestClientInfoShouldDefaultHost(t *testing.T) {
+	assert.NotEmpty(t, ClientInfo{Service: "billing"}.withHost().Host)
+	assert.Equal(t, ClientInfo{}, ClientInfo{}.withHost())
+}
+
+func TestClientInfoNodeShouldNeedService(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 10 * time.Second, connectTimeout: time.Second}
+	assert.ErrorIs(t, WithClientInfoNode("/clients")(base).Validate(), ErrInvalidOptions)
+	assert.NoError(t, WithClientInfoNode("/clients")(WithClientInfo(ClientInfo{Service: "billing"})(base)).Validate())
+}
@@ -0,0 +1,65 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// Codec converts structured values to and from znode data.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// JSONCodec encodes values as JSON. It is the default codec.
+var JSONCodec Codec = jsonCodec{}
+
+// Codec returns the codec used by the typed helpers, set with WithCodec.
+func (s *ZKSession) Codec() Codec {
+	if s.opts.codec == nil {
+		return JSONCodec
+	}
+	return s.opts.codec
+}
+
+// codecOf returns the codec configured for s, or JSONCodec if s doesn't have
+// one.
+func codecOf(s Session) Codec {
+	if c, ok := s.(interface{ Codec() Codec }); ok {
+		return c.Codec()
+	}
+	return JSONCodec
+}
+
+// GetAs reads the node at path and decodes it into a T with the session's
+// codec. An empty node decodes to the zero value of T.
+func GetAs[T any](s Session, path string) (T, *zookeeper.Stat, error) {
+	var v T
+	data, stat, err := s.Get(path)
+	if err != nil {
+		return v, stat, err
+	}
+	if data != "" {
+		if err := codecOf(s).Unmarshal([]byte(data), &v); err != nil {
+			return v, stat, fmt.Errorf("decoding %q: %w", path, err)
+		}
+	}
+	return v, stat, nil
+}
+
+// SetAs encodes v with the session's codec and writes it to the existing node
+// at path, if its version matches.
+func SetAs[T any](s Session, path string, v T, version int) (*zookeeper.Stat, error) {
+	data, err := codecOf(s).Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %q: %w", path, err)
+	}
+	return s.Set(path, string(data), version)
+}
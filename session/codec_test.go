@@ -0,0 +1,30 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAsShouldRoundTripWithGetAs(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/config")
+
+		if _, err := SetAs(session, "/test/config", testConfig{Replicas: 2, Tags: []string{"a"}}, -1); err != nil {
+			t.Fatal("SetAs error: ", err)
+		}
+		AssertNodeValueEqual(t, session, "/test/config", `{"replicas":2,"tags":["a"]}`)
+
+		config, stat, err := GetAs[testConfig](session, "/test/config")
+		if err != nil {
+			t.Fatal("GetAs error: ", err)
+		}
+		assert.Equal(t, testConfig{Replicas: 2, Tags: []string{"a"}}, config)
+		assert.Equal(t, 1, stat.Version())
+	})
+}
+
+func TestCodecShouldDefaultToJSON(t *testing.T) {
+	assert.Equal(t, JSONCodec, (&ZKSession{}).Codec())
+	assert.Equal(t, JSONCodec, codecOf(struct{ Session }{}))
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// MaxZnodeSize is the largest value, after compression, that can be stored in
+// a znode. Larger values are rejected by the server, and would be truncated by
+// gozk's read buffer.
+const MaxZnodeSize = 1024 * 1024
+
+// compressionMagic prefixes values compressed by the session so they can be
+// recognized and transparently decompressed on read.
+const compressionMagic = "\x00gzk\x01"
+
+// ErrDataTooLarge is returned when a value is larger than MaxZnodeSize, even
+// after compression.
+var ErrDataTooLarge = errors.New("data exceeds the maximum znode size")
+
+// encodeValue transforms value if path has a transformer, compresses it when
+// it exceeds the configured threshold, encrypts it if path is under an
+// encrypted prefix, and enforces the znode size limit.
+func (s *ZKSession) encodeValue(path string, value string) (string, error) {
+	value, err := s.transformWrite(path, value)
+	if err != nil {
+		return "", err
+	}
+
+	if s.opts.compressThreshold > 0 && len(value) > s.opts.compressThreshold {
+		var buf bytes.Buffer
+		buf.WriteString(compressionMagic)
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(value)); err != nil {
+			return "", fmt.Errorf("compressing data for %q: %w", path, err)
+		}
+		if err := w.Close(); err != nil {
+			return "", fmt.Errorf("compressing data for %q: %w", path, err)
+		}
+		value = buf.String()
+	}
+
+	if s.encrypts(path) {
+		if value, err = s.encrypt(path, value); err != nil {
+			return "", err
+		}
+	}
+
+	if len(value) > MaxZnodeSize {
+		return "", fmt.Errorf("writing %q: %w (%d > %d bytes)", path, ErrDataTooLarge, len(value), MaxZnodeSize)
+	}
+	return value, nil
+}
+
+// decodeValue reverses encodeValue, and strips any ownership annotation; see
+// WithOwnershipTags.
+func (s *ZKSession) decodeValue(path string, value string) (string, error) {
+	value, err := s.decodePayload(path, value)
+	if err != nil {
+		return "", err
+	}
+	_, value, _ = ParseOwnership(value)
+	return s.transformRead(path, value)
+}
+
+// decodePayload decrypts and decompresses value. Values without the
+// encryption or compression headers are returned unchanged, so sessions
+// without compression enabled can still read compressed nodes.
+func (s *ZKSession) decodePayload(path string, value string) (string, error) {
+	if strings.HasPrefix(value, encryptionMagic) {
+		var err error
+		if value, err = s.decrypt(path, value); err != nil {
+			return "", err
+		}
+	}
+
+	if !strings.HasPrefix(value, compressionMagic) {
+		return value, nil
+	}
+
+	r, err := gzip.NewReader(strings.NewReader(value[len(compressionMagic):]))
+	if err != nil {
+		return "", fmt.Errorf("decompressing data for %q: %w", path, err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("decompressing data for %q: %w", path, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeValueBelowThresholdShouldBeUnchanged(t *testing.T) {
+	s := &ZKSession{opts: WithCompression(16)(SessionOpts{})}
+
+	encoded, err := s.encodeValue("/foo", "small")
+	if err != nil {
+		t.Error("encodeValue error: ", err)
+	}
+	assert.Equal(t, "small", encoded)
+}
+
+func TestEncodeValueShouldRoundTrip(t *testing.T) {
+	s := &ZKSession{opts: WithCompression(16)(SessionOpts{})}
+	value := strings.Repeat("compressible ", 1000)
+
+	encoded, err := s.encodeValue("/foo", value)
+	if err != nil {
+		t.Error("encodeValue error: ", err)
+	}
+	assert.True(t, len(encoded) < len(value))
+
+	decoded, err := s.decodeValue("/foo", encoded)
+	if err != nil {
+		t.Error("decodeValue error: ", err)
+	}
+	assert.Equal(t, value, decoded)
+}
+
+func TestEncodeValueShouldRejectOversizedData(t *testing.T) {
+	s := &ZKSession{}
+
+	_, err := s.encodeValue("/foo", strings.Repeat("x", MaxZnodeSize+1))
+	if !errors.Is(err, ErrDataTooLarge) {
+		t.Error("Expected ErrDataTooLarge, got: ", err)
+	}
+}
@@ -0,0 +1,161 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrTLSUnsupported is returned when a configuration enables TLS, which the
+// underlying C client doesn't support.
+var ErrTLSUnsupported = errors.New("TLS is not supported by the zookeeper client")
+
+// Config holds session settings loaded from a file or the environment.
+// Timeouts are Go duration strings, such as "5s".
+type Config struct {
+	Servers        []string     `yaml:"servers" json:"servers"`
+	SessionTimeout string       `yaml:"session_timeout" json:"session_timeout"`
+	ConnectTimeout string       `yaml:"connect_timeout" json:"connect_timeout"`
+	Namespace      string       `yaml:"namespace" json:"namespace"`
+	Auth           []AuthConfig `yaml:"auth" json:"auth"`
+	TLS            *TLSConfig   `yaml:"tls" json:"tls"`
+}
+
+// AuthConfig is a set of credentials added to the session once connected.
+type AuthConfig struct {
+	Scheme      string `yaml:"scheme" json:"scheme"`
+	Credentials string `yaml:"credentials" json:"credentials"`
+}
+
+// TLSConfig is accepted so configuration shared with other clients parses,
+// but enabling it is an error; see ErrTLSUnsupported.
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	CAFile   string `yaml:"ca_file" json:"ca_file"`
+}
+
+// LoadConfig reads a Config from a YAML or JSON file.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("reading zookeeper config: %w", err)
+	}
+	// YAML is a superset of JSON, so both are handled by the YAML decoder.
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parsing zookeeper config %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// ConfigFromEnv reads a Config from the environment:
+//
+//	ZK_SERVERS          comma-separated host:port list
+//	ZK_SESSION_TIMEOUT  duration
+//	ZK_CONNECT_TIMEOUT  duration
+//	ZK_NAMESPACE        chroot path
+//	ZK_AUTH             comma-separated scheme:credentials list
+//	ZK_TLS              "true" to enable TLS
+func ConfigFromEnv() Config {
+	c := Config{
+		SessionTimeout: os.Getenv("ZK_SESSION_TIMEOUT"),
+		ConnectTimeout: os.Getenv("ZK_CONNECT_TIMEOUT"),
+		Namespace:      os.Getenv("ZK_NAMESPACE"),
+	}
+	if servers := os.Getenv("ZK_SERVERS"); servers != "" {
+		c.Servers = strings.Split(servers, ",")
+	}
+	if auth := os.Getenv("ZK_AUTH"); auth != "" {
+		for _, entry := range strings.Split(auth, ",") {
+			scheme, credentials, _ := strings.Cut(entry, ":")
+			c.Auth = append(c.Auth, AuthConfig{Scheme: scheme, Credentials: credentials})
+		}
+	}
+	if os.Getenv("ZK_TLS") == "true" {
+		c.TLS = &TLSConfig{Enabled: true}
+	}
+	return c
+}
+
+// Options validates c and returns the equivalent session options.
+func (c Config) Options() ([]SessionOpt, error) {
+	if len(c.Servers) == 0 {
+		return nil, errors.New("invalid zookeeper config: no servers")
+	}
+	for _, server := range c.Servers {
+		if !strings.Contains(server, ":") {
+			return nil, fmt.Errorf("invalid zookeeper config: server %q has no port", server)
+		}
+	}
+	if c.TLS != nil && c.TLS.Enabled {
+		return nil, fmt.Errorf("invalid zookeeper config: %w", ErrTLSUnsupported)
+	}
+
+	opts := []SessionOpt{WithZookeepers(c.Servers)}
+	if c.SessionTimeout != "" {
+		timeout, err := time.ParseDuration(c.SessionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zookeeper config: session_timeout: %w", err)
+		}
+		opts = append(opts, WithSessionTimeout(timeout))
+	}
+	if c.ConnectTimeout != "" {
+		timeout, err := time.ParseDuration(c.ConnectTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zookeeper config: connect_timeout: %w", err)
+		}
+		opts = append(opts, WithConnectTimeout(timeout))
+	}
+	if c.Namespace != "" {
+		opts = append(opts, WithNamespace(c.Namespace))
+	}
+	for _, auth := range c.Auth {
+		if auth.Scheme == "" {
+			return nil, errors.New("invalid zookeeper config: auth entry without scheme")
+		}
+		opts = append(opts, WithAuth(auth.Scheme, auth.Credentials))
+	}
+	return opts, nil
+}
+
+// WithConfigFile applies the settings in the YAML or JSON file at path; see
+// Config. Errors loading or validating the file are returned when the session
+// is created.
+func WithConfigFile(path string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		c, err := LoadConfig(path)
+		if err != nil {
+			so.err = err
+			return so
+		}
+		return so.apply(c)
+	}
+}
+
+func (so SessionOpts) apply(c Config) SessionOpts {
+	opts, err := c.Options()
+	if err != nil {
+		so.err = err
+		return so
+	}
+	for _, opt := range opts {
+		so = opt(so)
+	}
+	return so
+}
+
+// NewSessionFromEnv creates a session configured from the environment, as
+// described by ConfigFromEnv. opts are applied on top.
+func NewSessionFromEnv(opts ...SessionOpt) (*ZKSession, error) {
+	env := func(so SessionOpts) SessionOpts {
+		return so.apply(ConfigFromEnv())
+	}
+	return NewSessionWithOpts(append([]SessionOpt{env}, opts...)...)
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigShouldParseYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zk.yml")
+	err := ioutil.WriteFile(path, []byte(`
+servers: [zk1:2181, zk2:2181]
+session_timeout: 10s
+namespace: app
+auth:
+  - scheme: digest
+    credentials: user:pass
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	so := WithConfigFile(path)(SessionOpts{})
+	if so.err != nil {
+		t.Fatal("WithConfigFile error: ", so.err)
+	}
+	assert.Equal(t, []string{"zk1:2181", "zk2:2181"}, so.servers)
+	assert.Equal(t, 10*time.Second, so.sessionTimeout)
+	assert.Equal(t, "zk1:2181,zk2:2181/app", so.serverList())
+	assert.Equal(t, []AuthConfig{{Scheme: "digest", Credentials: "user:pass"}}, so.auth)
+}
+
+func TestConfigOptionsShouldValidate(t *testing.T) {
+	_, err := Config{}.Options()
+	assert.Error(t, err)
+
+	_, err = Config{Servers: []string{"zk1"}}.Options()
+	assert.Error(t, err)
+
+	_, err = Config{Servers: []string{"zk1:2181"}, SessionTimeout: "soon"}.Options()
+	assert.Error(t, err)
+
+	_, err = Config{Servers: []string{"zk1:2181"}, TLS: &TLSConfig{Enabled: true}}.Options()
+	assert.True(t, errors.Is(err, ErrTLSUnsupported))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("ZK_SERVERS", "zk1:2181,zk2:2181")
+	t.Setenv("ZK_CONNECT_TIMEOUT", "3s")
+	t.Setenv("ZK_AUTH", "digest:user:pass")
+
+	c := ConfigFromEnv()
+	assert.Equal(t, []string{"zk1:2181", "zk2:2181"}, c.Servers)
+	assert.Equal(t, "3s", c.ConnectTimeout)
+	assert.Equal(t, []AuthConfig{{Scheme: "digest", Credentials: "user:pass"}}, c.Auth)
+}
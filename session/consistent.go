@@ -0,0 +1,86 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// consistentReadAttempts is how many times ReadConsistent reads the nodes
+// before giving up.
+const consistentReadAttempts = 5
+
+// ErrTornRead is returned by ReadConsistent when the nodes kept changing while
+// they were being read.
+var ErrTornRead = errors.New("nodes were modified while being read")
+
+// NodeValue is a node read by ReadConsistent. Stat is nil if the node doesn't
+// exist.
+type NodeValue struct {
+	Path string
+	Data string
+	Stat *zookeeper.Stat
+}
+
+// ReadConsistent reads paths and returns their values as they all were at a
+// single point in time. Nodes are read one after the other, then checked
+// again: if none was modified, created or deleted in the meantime, each value
+// was current at the moment between the two passes. Otherwise the read is
+// retried, and if the nodes keep changing the last values read are returned
+// with ErrTornRead.
+func ReadConsistent(s Session, paths []string) ([]NodeValue, error) {
+	var values []NodeValue
+	var changed []string
+	for attempt := 0; attempt < consistentReadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+		}
+
+		var err error
+		if values, err = readNodes(s, paths); err != nil {
+			return nil, err
+		}
+		if changed, err = changedNodes(s, values); err != nil {
+			return nil, err
+		}
+		if len(changed) == 0 {
+			return values, nil
+		}
+	}
+	return values, fmt.Errorf("reading %q: %w: %q", paths, ErrTornRead, changed)
+}
+
+func readNodes(s Session, paths []string) ([]NodeValue, error) {
+	values := make([]NodeValue, len(paths))
+	for i, path := range paths {
+		data, stat, err := s.Get(path)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			values[i] = NodeValue{Path: path}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[i] = NodeValue{Path: path, Data: data, Stat: stat}
+	}
+	return values, nil
+}
+
+// changedNodes returns the paths whose state differs from values.
+func changedNodes(s Session, values []NodeValue) ([]string, error) {
+	var changed []string
+	for _, value := range values {
+		stat, err := s.Exists(value.Path)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case stat == nil && value.Stat == nil:
+		case stat == nil || value.Stat == nil || stat.Mzxid() != value.Stat.Mzxid():
+			changed = append(changed, value.Path)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,23 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConsistentShouldReadAllNodes(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/b")
+
+		values, err := ReadConsistent(session, []string{"/test/a", "/test/b", "/test/missing"})
+		if err != nil {
+			t.Fatal("ReadConsistent error: ", err)
+		}
+		if assert.Len(t, values, 3) {
+			assert.Equal(t, "/test/a", values[0].Data)
+			assert.Equal(t, "/test/b", values[1].Data)
+			assert.Nil(t, values[2].Stat)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrCopyTargetExists is returned by CopySubtree, with FailIfExists, for a
+// target node that already exists. It is an ErrAlreadyExists.
+var ErrCopyTargetExists = NewError("copy target already exists", ErrAlreadyExists)
+
+// OverwritePolicy is what CopySubtree does with target nodes that already
+// exist.
+type OverwritePolicy int
+
+const (
+	// FailIfExists stops the copy with ErrCopyTargetExists.
+	FailIfExists OverwritePolicy = iota
+	// SkipExisting leaves the existing node as it is, still copying its
+	// missing descendants.
+	SkipExisting
+	// OverwriteExisting replaces the existing node's data, and its ACL when
+	// ACLs are copied.
+	OverwriteExisting
+)
+
+// CopyProgress reports a node handled by CopySubtree.
+type CopyProgress struct {
+	Source string
+	Target string
+	// Skipped is set if the target was left as it was, under SkipExisting.
+	Skipped bool
+	// Done counts the nodes handled so far, including this one.
+	Done int
+}
+
+type copyOptions struct {
+	acls        bool
+	concurrency int
+	overwrite   OverwritePolicy
+	progress    func(CopyProgress)
+}
+
+// CopyOption configures CopySubtree.
+type CopyOption func(copyOptions) copyOptions
+
+// WithCopyACLs makes CopySubtree give each target node the ACL of its source,
+// rather than the session's default ACL.
+func WithCopyACLs() CopyOption {
+	return func(o copyOptions) copyOptions {
+		o.acls = true
+		return o
+	}
+}
+
+// WithCopyConcurrency lets CopySubtree copy up to n nodes at once.
+func WithCopyConcurrency(n int) CopyOption {
+	return func(o copyOptions) copyOptions {
+		o.concurrency = n
+		return o
+	}
+}
+
+// WithOverwrite sets what CopySubtree does with target nodes that already
+// exist, FailIfExists by default.
+func WithOverwrite(policy OverwritePolicy) CopyOption {
+	return func(o copyOptions) copyOptions {
+		o.overwrite = policy
+		return o
+	}
+}
+
+// WithCopyProgress makes CopySubtree call fn after each node is handled. Calls
+// are never concurrent, and arrive in the order nodes were handled.
+func WithCopyProgress(fn func(CopyProgress)) CopyOption {
+	return func(o copyOptions) copyOptions {
+		o.progress = fn
+		return o
+	}
+}
+
+// CopySubtree copies the data of src and every node below it to the same
+// paths under dst, which must not be within src. Parents are copied before
+// their children, so dst's parent must exist but dst itself need not.
+// Ephemeral nodes are left out, as they belong to their sessions. The copy
+// isn't atomic: nodes changed while it runs may be copied in either state,
+// and a failed copy leaves the nodes already copied in place.
+func CopySubtree(s Session, src, dst string, opts ...CopyOption) error {
+	o := copyOptions{concurrency: 1}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	if dst == src || strings.HasPrefix(dst, strings.TrimSuffix(src, "/")+"/") {
+		return fmt.Errorf("copying %s to %s: target is within the source", src, dst)
+	}
+
+	var mu sync.Mutex
+	done := 0
+	return Walk(s, src, func(path string, data []byte, stat *zookeeper.Stat) error {
+		if stat.EphemeralOwner() != 0 {
+			return SkipSubtree
+		}
+		target := dst
+		if path != src {
+			target = strings.TrimSuffix(dst, "/") + strings.TrimPrefix(path, strings.TrimSuffix(src, "/"))
+		}
+
+		acl := defaultACLOf(s)
+		if o.acls {
+			var err error
+			if acl, _, err = s.ACL(path); err != nil {
+				if zookeeper.IsError(err, zookeeper.ZNONODE) {
+					return SkipSubtree
+				}
+				return fmt.Errorf("copying %s to %s: %w", path, target, err)
+			}
+		}
+
+		skip, err := copyNode(s, target, string(data), acl, o)
+		if err != nil {
+			return fmt.Errorf("copying %s to %s: %w", path, target, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if o.progress != nil {
+			o.progress(CopyProgress{Source: path, Target: target, Skipped: skip, Done: done})
+		}
+		return nil
+	}, WithConcurrency(o.concurrency))
+}
+
+// copyNode creates target with data and acl, applying the overwrite policy if
+// it exists. It returns whether the target was skipped.
+func copyNode(s Session, target, data string, acl []zookeeper.ACL, o copyOptions) (bool, error) {
+	_, err := s.Create(target, data, 0, acl)
+	if !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		return false, err
+	}
+
+	switch o.overwrite {
+	case SkipExisting:
+		return true, nil
+	case OverwriteExisting:
+		if _, err := s.Set(target, data, -1); err != nil {
+			return false, err
+		}
+		if o.acls {
+			return false, s.SetACL(target, acl, -1)
+		}
+		return false, nil
+	default:
+		return false, ErrCopyTargetExists
+	}
+}
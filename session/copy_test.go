@@ -0,0 +1,65 @@
+package session
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopySubtreeShouldCopyDataAndACLs(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/src", "/test/src/a", "/test/src/a/b")
+		if _, err := session.Set("/test/src/a/b", "value", -1); err != nil {
+			t.Fatal(err)
+		}
+		readOnly := zookeeper.WorldACL(zookeeper.PERM_READ | zookeeper.PERM_CREATE | zookeeper.PERM_DELETE)
+		if err := session.SetACL("/test/src/a", readOnly, -1); err != nil {
+			t.Fatal(err)
+		}
+
+		var targets []string
+		err := CopySubtree(session, "/test/src", "/test/dst", WithCopyACLs(), WithCopyConcurrency(4), WithCopyProgress(func(p CopyProgress) {
+			targets = append(targets, p.Target)
+			assert.Equal(t, len(targets), p.Done)
+		}))
+		if err != nil {
+			t.Fatal("CopySubtree error: ", err)
+		}
+		assert.ElementsMatch(t, []string{"/test/dst", "/test/dst/a", "/test/dst/a/b"}, targets)
+
+		data, _, err := session.Get("/test/dst/a/b")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", data)
+		acl, _, err := session.ACL("/test/dst/a")
+		assert.NoError(t, err)
+		assert.Equal(t, readOnly, acl)
+	})
+}
+
+func TestCopySubtreeShouldApplyOverwritePolicy(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/src", "/test/src/a", "/test/dst", "/test/dst/a")
+		if _, err := session.Set("/test/src/a", "new", -1); err != nil {
+			t.Fatal(err)
+		}
+
+		err := CopySubtree(session, "/test/src", "/test/dst")
+		assert.ErrorIs(t, err, ErrCopyTargetExists)
+		assert.ErrorIs(t, err, ErrAlreadyExists)
+
+		assert.NoError(t, CopySubtree(session, "/test/src", "/test/dst", WithOverwrite(SkipExisting)))
+		data, _, _ := session.Get("/test/dst/a")
+		assert.Equal(t, "", data)
+
+		assert.NoError(t, CopySubtree(session, "/test/src", "/test/dst", WithOverwrite(OverwriteExisting)))
+		data, _, _ = session.Get("/test/dst/a")
+		assert.Equal(t, "new", data)
+	})
+}
+
+func TestCopySubtreeShouldRejectTargetWithinSource(t *testing.T) {
+	assert.Error(t, CopySubtree(nil, "/test/src", "/test/src/copy"))
+	assert.Error(t, CopySubtree(nil, "/test/src", "/test/src"))
+	assert.Error(t, CopySubtree(nil, "/", "/copy"))
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrWatchTrackingDisabled is returned by CoverageReport for sessions created
+// without WithRegistry, which don't track their watches.
+var ErrWatchTrackingDisabled = errors.New("watches are only tracked for sessions created with WithRegistry")
+
+// NodeCoverage is a node and the kinds of watch, "data", "children" or
+// "exists", outstanding on it.
+type NodeCoverage struct {
+	Path  string   `json:"path"`
+	Kinds []string `json:"kinds"`
+}
+
+// CoverageReport compares the nodes under a path with the watches the
+// session has set on them.
+type CoverageReport struct {
+	Path string `json:"path"`
+	// Watched are the existing nodes with at least one watch.
+	Watched []NodeCoverage `json:"watched"`
+	// Unwatched are the existing nodes without any watch, which changes go
+	// unnoticed on.
+	Unwatched []string `json:"unwatched"`
+	// Orphaned are the watches on nodes that don't exist. Exists watches
+	// there are waiting for the node to be created; other kinds are left
+	// over from a deleted node, and fire once the deletion is delivered.
+	Orphaned []NodeCoverage `json:"orphaned"`
+}
+
+// CoverageReport walks path and its descendants and reports which of them
+// have watches set through the session, and which watches are on nodes that
+// don't exist, to find out why a change went unnoticed. The walk and the
+// watches are read at slightly different times, so nodes changing meanwhile
+// may be misreported. It requires WithRegistry.
+func (s *ZKSession) CoverageReport(path string) (CoverageReport, error) {
+	if s.debug == nil {
+		return CoverageReport{}, ErrWatchTrackingDisabled
+	}
+
+	var existing []string
+	err := Walk(s, path, func(node string, _ []byte, _ *zookeeper.Stat) error {
+		existing = append(existing, node)
+		return nil
+	})
+	if err != nil {
+		return CoverageReport{}, err
+	}
+	return coverage(path, existing, s.DebugInfo().Watches), nil
+}
+
+// coverage builds the report for the existing nodes under path given the
+// outstanding watches.
+func coverage(path string, existing []string, watches []WatchInfo) CoverageReport {
+	kinds := make(map[string][]string)
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for _, watch := range watches {
+		if watch.Path == path || strings.HasPrefix(watch.Path, prefix) {
+			kinds[watch.Path] = append(kinds[watch.Path], watch.Kind)
+		}
+	}
+
+	report := CoverageReport{Path: path}
+	for _, node := range existing {
+		if k, ok := kinds[node]; ok {
+			report.Watched = append(report.Watched, NodeCoverage{Path: node, Kinds: k})
+			delete(kinds, node)
+		} else {
+			report.Unwatched = append(report.Unwatched, node)
+		}
+	}
+	for node, k := range kinds {
+		report.Orphaned = append(report.Orphaned, NodeCoverage{Path: node, Kinds: k})
+	}
+
+	for _, nodes := range [][]NodeCoverage{report.Watched, report.Orphaned} {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Path < nodes[j].Path })
+		for _, node := range nodes {
+			sort.Strings(node.Kinds)
+		}
+	}
+	sort.Strings(report.Unwatched)
+	return report
+}
@@ -0,0 +1,30 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverageShouldSplitWatchedUnwatchedAndOrphaned(t *testing.T) {
+	watches := []WatchInfo{
+		{Path: "/test", Kind: "data", Count: 1},
+		{Path: "/test", Kind: "children", Count: 1},
+		{Path: "/test/gone", Kind: "data", Count: 1},
+		{Path: "/test/pending", Kind: "exists", Count: 2},
+		{Path: "/testing", Kind: "data", Count: 1},
+	}
+
+	report := coverage("/test", []string{"/test", "/test/a", "/test/b"}, watches)
+	assert.Equal(t, []NodeCoverage{{Path: "/test", Kinds: []string{"children", "data"}}}, report.Watched)
+	assert.Equal(t, []string{"/test/a", "/test/b"}, report.Unwatched)
+	assert.Equal(t, []NodeCoverage{
+		{Path: "/test/gone", Kinds: []string{"data"}},
+		{Path: "/test/pending", Kinds: []string{"exists"}},
+	}, report.Orphaned)
+}
+
+func TestCoverageReportShouldRequireRegistry(t *testing.T) {
+	_, err := (&ZKSession{}).CoverageReport("/test")
+	assert.ErrorIs(t, err, ErrWatchTrackingDisabled)
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// CreateMode is the kind of znode to create.
+type CreateMode int
+
+const (
+	// Persistent nodes exist until they are deleted.
+	Persistent CreateMode = iota
+	// Ephemeral nodes are deleted when the session that created them ends.
+	Ephemeral
+	// PersistentSequential nodes are persistent and have a sequence number
+	// appended to their name.
+	PersistentSequential
+	// EphemeralSequential nodes are ephemeral and have a sequence number
+	// appended to their name.
+	EphemeralSequential
+	// Container nodes are deleted by the server once their last child is
+	// deleted. They require ZooKeeper 3.5.3.
+	Container
+	// TTL nodes are deleted by the server once they have had no children and
+	// no modifications for their TTL. They require ZooKeeper 3.5.3.
+	TTL
+)
+
+// ErrInvalidCreateMode is returned for create modes or flag combinations that
+// can't be created with this client.
+var ErrInvalidCreateMode = errors.New("invalid create mode")
+
+func (m CreateMode) String() string {
+	switch m {
+	case Persistent:
+		return "Persistent"
+	case Ephemeral:
+		return "Ephemeral"
+	case PersistentSequential:
+		return "PersistentSequential"
+	case EphemeralSequential:
+		return "EphemeralSequential"
+	case Container:
+		return "Container"
+	case TTL:
+		return "TTL"
+	}
+	return fmt.Sprintf("CreateMode(%d)", int(m))
+}
+
+// Flags returns the Create flags for m. Container and TTL nodes need a newer
+// create request than gozk supports, so they are rejected; janitor.CreateTTL
+// emulates TTL nodes instead.
+func (m CreateMode) Flags() (int, error) {
+	switch m {
+	case Persistent:
+		return 0, nil
+	case Ephemeral:
+		return zookeeper.EPHEMERAL, nil
+	case PersistentSequential:
+		return zookeeper.SEQUENCE, nil
+	case EphemeralSequential:
+		return zookeeper.EPHEMERAL | zookeeper.SEQUENCE, nil
+	case Container, TTL:
+		return 0, fmt.Errorf("%w: %s nodes are not supported by the gozk client", ErrInvalidCreateMode, m)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrInvalidCreateMode, m)
+}
+
+// ModeFromFlags returns the CreateMode matching Create flags.
+func ModeFromFlags(flags int) (CreateMode, error) {
+	switch flags {
+	case 0:
+		return Persistent, nil
+	case zookeeper.EPHEMERAL:
+		return Ephemeral, nil
+	case zookeeper.SEQUENCE:
+		return PersistentSequential, nil
+	case zookeeper.EPHEMERAL | zookeeper.SEQUENCE:
+		return EphemeralSequential, nil
+	}
+	return 0, fmt.Errorf("%w: unknown flags %#x", ErrInvalidCreateMode, flags)
+}
+
+// CreateWithMode is like Create, but takes a CreateMode instead of raw flags.
+func (s *ZKSession) CreateWithMode(path string, value string, mode CreateMode, aclv []zookeeper.ACL) (string, error) {
+	flags, err := mode.Flags()
+	if err != nil {
+		return "", fmt.Errorf("creating %q: %w", path, err)
+	}
+	return s.Create(path, value, flags, aclv)
+}
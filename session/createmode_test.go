@@ -0,0 +1,35 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateModeFlagsShouldRoundTrip(t *testing.T) {
+	for _, mode := range []CreateMode{Persistent, Ephemeral, PersistentSequential, EphemeralSequential} {
+		flags, err := mode.Flags()
+		if err != nil {
+			t.Error("Flags error: ", err)
+		}
+
+		parsed, err := ModeFromFlags(flags)
+		if err != nil {
+			t.Error("ModeFromFlags error: ", err)
+		}
+		assert.Equal(t, mode, parsed)
+	}
+}
+
+func TestUnsupportedCreateModesShouldBeRejected(t *testing.T) {
+	for _, mode := range []CreateMode{Container, TTL, CreateMode(42)} {
+		if _, err := mode.Flags(); !errors.Is(err, ErrInvalidCreateMode) {
+			t.Error("Expected ErrInvalidCreateMode for ", mode, ", got: ", err)
+		}
+	}
+
+	if _, err := ModeFromFlags(8); !errors.Is(err, ErrInvalidCreateMode) {
+		t.Error("Expected ErrInvalidCreateMode, got: ", err)
+	}
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func (e ZKSessionEvent) String() string {
+	switch e {
+	case SessionClosed:
+		return "SessionClosed"
+	case SessionDisconnected:
+		return "SessionDisconnected"
+	case SessionReconnected:
+		return "SessionReconnected"
+	case SessionExpiredReconnected:
+		return "SessionExpiredReconnected"
+	case SessionFailed:
+		return "SessionFailed"
+	case SessionGated:
+		return "SessionGated"
+	case SessionExpired:
+		return "SessionExpired"
+	case SessionDegraded:
+		return "SessionDegraded"
+	case SessionRestored:
+		return "SessionRestored"
+	case SessionFailedPartition:
+		return "SessionFailedPartition"
+	case SessionFailedQuorumLoss:
+		return "SessionFailedQuorumLoss"
+	case SessionFailedAuth:
+		return "SessionFailedAuth"
+	}
+	return "Unknown"
+}
+
+// DebugInfo is the state of a session rendered by DebugHandler.
+type DebugInfo struct {
+	Name          string        `json:"name"`
+	Client        string        `json:"client,omitempty"`
+	Servers       []string      `json:"servers"`
+	CurrentServer string        `json:"current_server"`
+	Epoch         uint64        `json:"epoch"`
+	Events        []EventRecord `json:"events"`
+	Watches       []WatchInfo   `json:"watches"`
+	Attachments   []Attachment  `json:"attachments"`
+}
+
+// DebugInfo returns a snapshot of the session's state. Events, watches and
+// attachments are only tracked for sessions created with WithRegistry.
+func (s *ZKSession) DebugInfo() DebugInfo {
+	info := DebugInfo{
+		Name:    s.Name(),
+		Servers: s.opts.servers,
+		Epoch:   s.Epoch(),
+	}
+	if s.conn != nil {
+		info.CurrentServer = s.CurrentServer()
+	}
+	if c := s.opts.clientInfo; c != (ClientInfo{}) {
+		info.Client = c.String()
+	}
+
+	if d := s.debug; d != nil {
+		d.mu.Lock()
+		info.Events = append(info.Events, d.history...)
+		for watch, count := range d.watches {
+			watch.Count = count
+			info.Watches = append(info.Watches, watch)
+		}
+		for a := range d.attachments {
+			info.Attachments = append(info.Attachments, *a)
+		}
+		d.mu.Unlock()
+	}
+
+	sort.Slice(info.Watches, func(i, j int) bool {
+		if info.Watches[i].Path != info.Watches[j].Path {
+			return info.Watches[i].Path < info.Watches[j].Path
+		}
+		return info.Watches[i].Kind < info.Watches[j].Kind
+	})
+	sort.Slice(info.Attachments, func(i, j int) bool {
+		return info.Attachments[i].Since.Before(info.Attachments[j].Since)
+	})
+	return info
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html><head><title>ZooKeeper sessions</title></head><body>
+{{range .}}<h2>{{.Name}}</h2>
+<p>{{if .Client}}Client: {{.Client}}<br>
+{{end}}Servers: {{range .Servers}}{{.}} {{end}}<br>
+Connected to: {{.CurrentServer}}<br>
+Epoch: {{.Epoch}}</p>
+<h3>Recipes</h3><ul>{{range .Attachments}}<li>{{.Recipe}} {{.Detail}} (since {{.Since.Format "2006-01-02 15:04:05"}})</li>{{end}}</ul>
+<h3>Watches</h3><ul>{{range .Watches}}<li>{{.Kind}} {{.Path}} &times;{{.Count}}</li>{{end}}</ul>
+<h3>Events</h3><ul>{{range .Events}}<li>{{.Time.Format "2006-01-02 15:04:05.000"}} {{.Event}}</li>{{end}}</ul>
+{{else}}<p>No registered sessions.</p>{{end}}
+</body></html>
+`))
+
+// DebugHandler returns an http.Handler rendering the state of every session
+// created with WithRegistry, as HTML, or as JSON if the request asks for it
+// with ?format=json or an Accept header.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessions := Sessions()
+		infos := make([]DebugInfo, 0, len(sessions))
+		for _, s := range sessions {
+			infos = append(infos, s.DebugInfo())
+		}
+
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(infos)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = debugTemplate.Execute(w, infos)
+	})
+}
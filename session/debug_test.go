@@ -0,0 +1,58 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugStateShouldTrackWatchesUntilFired(t *testing.T) {
+	s := &ZKSession{debug: newDebugState()}
+
+	watch := make(chan zookeeper.Event, 1)
+	forwarded := s.debug.trackWatch("/foo", "data", watch)
+	assert.Equal(t, []WatchInfo{{Path: "/foo", Kind: "data", Count: 1}}, s.DebugInfo().Watches)
+
+	watch <- zookeeper.Event{Path: "/foo"}
+	assert.Equal(t, "/foo", (<-forwarded).Path)
+	assert.Empty(t, s.DebugInfo().Watches)
+}
+
+func TestAttachShouldRecordRecipes(t *testing.T) {
+	s := &ZKSession{debug: newDebugState()}
+
+	detach := Attach(s, "lock", "/locks/foo")
+	if attachments := s.DebugInfo().Attachments; assert.Len(t, attachments, 1) {
+		assert.Equal(t, "lock", attachments[0].Recipe)
+		assert.Equal(t, "/locks/foo", attachments[0].Detail)
+	}
+
+	detach()
+	detach()
+	assert.Empty(t, s.DebugInfo().Attachments)
+
+	// Sessions without WithRegistry ignore attachments.
+	Attach(&ZKSession{}, "lock", "/locks/foo")()
+}
+
+func TestDebugHandlerShouldRenderJSON(t *testing.T) {
+	s := &ZKSession{opts: SessionOpts{name: "debug-test"}, debug: newDebugState()}
+	s.debug.recordEvent(SessionDisconnected)
+	register(s)
+	defer unregister(s)
+
+	w := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+
+	var infos []DebugInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "debug-test", infos[0].Name)
+		assert.Equal(t, "SessionDisconnected", infos[0].Events[0].Event)
+	}
+}
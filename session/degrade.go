@@ -0,0 +1,195 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrDisconnected is returned by recipes following FailFast or
+// BlockUntilReconnect while their session is disconnected. It is an
+// ErrSessionLost.
+var ErrDisconnected = NewError("session is disconnected", ErrSessionLost)
+
+type disconnectMode int
+
+const (
+	disconnectAttempt disconnectMode = iota
+	disconnectFailFast
+	disconnectServeStale
+	disconnectBlock
+)
+
+// DisconnectPolicy is what a recipe does when called while its session is
+// disconnected. The zero value keeps each recipe's default, which is to go
+// ahead and let the ZooKeeper calls it makes fail or wait as they will.
+type DisconnectPolicy struct {
+	mode     disconnectMode
+	deadline time.Duration
+}
+
+// FailFast fails calls made while disconnected with ErrDisconnected.
+func FailFast() DisconnectPolicy {
+	return DisconnectPolicy{mode: disconnectFailFast}
+}
+
+// ServeStale answers calls made while disconnected from local state, such as
+// a cache's contents, which may be out of date. Recipes without local state
+// go ahead as with the zero policy.
+func ServeStale() DisconnectPolicy {
+	return DisconnectPolicy{mode: disconnectServeStale}
+}
+
+// BlockUntilReconnect holds calls made while disconnected until the session
+// reconnects, failing them with ErrDisconnected if it hasn't within deadline.
+func BlockUntilReconnect(deadline time.Duration) DisconnectPolicy {
+	return DisconnectPolicy{mode: disconnectBlock, deadline: deadline}
+}
+
+// IsZero reports whether p is the zero policy, leaving recipes to their
+// defaults.
+func (p DisconnectPolicy) IsZero() bool {
+	return p.mode == disconnectAttempt
+}
+
+// Check applies p for a call to a recipe using the session followed by c. It
+// returns an error if the call must fail, and stale set if it should be
+// answered from local state.
+func (p DisconnectPolicy) Check(c *ConnectionState) (stale bool, err error) {
+	return p.CheckReady(c, nil, nil)
+}
+
+// CheckReady is Check for recipes that also need to be ready, such as a cache
+// that has completed its initial sync, to answer calls with up to date
+// state. ready is closed once the recipe is ready, or nil if it always is;
+// calls failing for want of readiness fail with notReady.
+func (p DisconnectPolicy) CheckReady(c *ConnectionState, ready <-chan struct{}, notReady error) (stale bool, err error) {
+	connected := c == nil || c.Connected()
+	if connected && isClosed(ready) {
+		return false, nil
+	}
+	switch p.mode {
+	case disconnectFailFast:
+		if !connected {
+			return false, ErrDisconnected
+		}
+		return false, notReady
+	case disconnectServeStale:
+		return true, nil
+	case disconnectBlock:
+		start := time.Now()
+		if !connected && !c.Wait(p.deadline) {
+			return false, fmt.Errorf("waited %s for the session to reconnect: %w", p.deadline, ErrDisconnected)
+		}
+		if ready != nil {
+			timer := time.NewTimer(p.deadline - time.Since(start))
+			defer timer.Stop()
+			select {
+			case <-ready:
+			case <-timer.C:
+				return false, fmt.Errorf("waited %s: %w", p.deadline, notReady)
+			}
+		}
+	}
+	return false, nil
+}
+
+// isClosed reports whether ready, if not nil, is closed.
+func isClosed(ready <-chan struct{}) bool {
+	if ready == nil {
+		return true
+	}
+	select {
+	case <-ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConnectionState follows a session's events to tell whether it is connected,
+// for recipes applying a DisconnectPolicy. The session is taken to be
+// connected until it reports otherwise, and disconnected for good once it
+// fails, expires or is closed.
+type ConnectionState struct {
+	mu        sync.Mutex
+	connected bool
+	ended     bool
+	// reconnected is closed when the session reconnects, and replaced when
+	// it disconnects.
+	reconnected chan struct{}
+}
+
+// NewConnectionState starts following the events of s.
+func NewConnectionState(s Session) *ConnectionState {
+	c := &ConnectionState{connected: true, reconnected: make(chan struct{})}
+	close(c.reconnected)
+
+	events := make(chan ZKSessionEvent)
+	s.Subscribe(events)
+	Go(s, "connection state", func() { c.follow(events) })
+	return c
+}
+
+func (c *ConnectionState) follow(events <-chan ZKSessionEvent) {
+	for event := range events {
+		switch event {
+		case SessionDisconnected, SessionGated:
+			c.set(false)
+		case SessionReconnected, SessionExpiredReconnected:
+			c.set(true)
+		case SessionClosed, SessionFailed, SessionExpired:
+			c.set(false)
+			c.mu.Lock()
+			c.ended = true
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (c *ConnectionState) set(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if connected == c.connected {
+		return
+	}
+	c.connected = connected
+	if connected {
+		close(c.reconnected)
+	} else {
+		c.reconnected = make(chan struct{})
+	}
+}
+
+// Connected reports whether the session is connected.
+func (c *ConnectionState) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Ended reports whether the session has failed, expired or been closed, and
+// so won't connect again.
+func (c *ConnectionState) Ended() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ended
+}
+
+// Wait blocks until the session is connected, for up to timeout, and reports
+// whether it is.
+func (c *ConnectionState) Wait(timeout time.Duration) bool {
+	c.mu.Lock()
+	reconnected := c.reconnected
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-reconnected:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
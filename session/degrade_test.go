@@ -0,0 +1,54 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisconnectPolicyShouldApplyWhileDisconnected(t *testing.T) {
+	s := &stubSession{}
+	c := NewConnectionState(s)
+
+	for _, p := range []DisconnectPolicy{{}, FailFast(), ServeStale(), BlockUntilReconnect(time.Second)} {
+		stale, err := p.Check(c)
+		assert.False(t, stale)
+		assert.NoError(t, err)
+	}
+
+	s.events <- SessionDisconnected
+	s.events <- SessionGated
+	assert.False(t, c.Connected())
+
+	_, err := FailFast().Check(c)
+	assert.ErrorIs(t, err, ErrDisconnected)
+	assert.ErrorIs(t, err, ErrSessionLost)
+	stale, err := ServeStale().Check(c)
+	assert.True(t, stale)
+	assert.NoError(t, err)
+	_, err = BlockUntilReconnect(10 * time.Millisecond).Check(c)
+	assert.ErrorIs(t, err, ErrDisconnected)
+	_, err = DisconnectPolicy{}.Check(c)
+	assert.NoError(t, err)
+
+	go func() { s.events <- SessionReconnected }()
+	_, err = BlockUntilReconnect(time.Second).Check(c)
+	assert.NoError(t, err)
+	assert.True(t, c.Connected())
+}
+
+func TestDisconnectPolicyShouldWaitForReadiness(t *testing.T) {
+	notReady := errors.New("not ready")
+	ready := make(chan struct{})
+
+	_, err := FailFast().CheckReady(nil, ready, notReady)
+	assert.Equal(t, notReady, err)
+	_, err = BlockUntilReconnect(10*time.Millisecond).CheckReady(nil, ready, notReady)
+	assert.ErrorIs(t, err, notReady)
+
+	close(ready)
+	_, err = FailFast().CheckReady(nil, ready, notReady)
+	assert.NoError(t, err)
+}
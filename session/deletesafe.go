@@ -0,0 +1,83 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrNodeNotEmpty is matched, with errors.Is, by the NotEmptyError returned
+// by DeleteSafe.
+var ErrNodeNotEmpty = errors.New("node has children")
+
+// NotEmptyError is returned by DeleteSafe when the node has children and
+// recursive deletion wasn't allowed.
+type NotEmptyError struct {
+	Path     string
+	Children int
+}
+
+func (e *NotEmptyError) Error() string {
+	return fmt.Sprintf("refusing to delete %q: %d children", e.Path, e.Children)
+}
+
+func (e *NotEmptyError) Is(target error) bool {
+	return target == ErrNodeNotEmpty
+}
+
+type deleteOptions struct {
+	recursive   bool
+	concurrency int
+	rate        float64
+	progress    func(DeleteProgress)
+}
+
+// DeleteOption configures DeleteSafe and DeleteRecursive.
+type DeleteOption func(deleteOptions) deleteOptions
+
+// AllowRecursive lets DeleteSafe delete a node's descendants along with it.
+func AllowRecursive() DeleteOption {
+	return func(o deleteOptions) deleteOptions {
+		o.recursive = true
+		return o
+	}
+}
+
+// DeleteSafe deletes the node at path if its version matches. Unlike Delete,
+// a node with children is refused with a *NotEmptyError reporting how many,
+// which isn't worth retrying, unless AllowRecursive is given. Then the
+// version is checked before any descendant is deleted, and the descendants
+// are deleted as with DeleteRecursive.
+func (s *ZKSession) DeleteSafe(path string, version int, opts ...DeleteOption) error {
+	var o deleteOptions
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	stat, err := s.Exists(path)
+	if err != nil {
+		return err
+	}
+	if stat == nil {
+		return &zookeeper.Error{Op: "delete", Code: zookeeper.ZNONODE, Path: path}
+	}
+	if version != -1 && stat.Version() != version {
+		return &zookeeper.Error{Op: "delete", Code: zookeeper.ZBADVERSION, Path: path}
+	}
+	if stat.NumChildren() == 0 {
+		return s.Delete(path, version)
+	}
+	if !o.recursive {
+		return &NotEmptyError{Path: path, Children: stat.NumChildren()}
+	}
+
+	children, err := s.ChildrenRecursive(path, -1)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteProtected(append(children, path)...); err != nil {
+		return err
+	}
+	return s.deleteTree(path, version, children, o)
+}
@@ -0,0 +1,38 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteSafeShouldRefuseNonEmptyNode(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/b")
+
+		err := session.DeleteSafe("/test", -1)
+		assert.True(t, errors.Is(err, ErrNodeNotEmpty))
+		var notEmpty *NotEmptyError
+		if assert.True(t, errors.As(err, &notEmpty)) {
+			assert.Equal(t, 2, notEmpty.Children)
+		}
+
+		if stat, _ := session.Exists("/test/a"); stat == nil {
+			t.Error("Expected /test/a to still exist")
+		}
+	})
+}
+
+func TestDeleteSafeShouldDeleteRecursivelyWhenAllowed(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/a/b")
+
+		if err := session.DeleteSafe("/test", 0, AllowRecursive()); err != nil {
+			t.Fatal("DeleteSafe error: ", err)
+		}
+		if stat, _ := session.Exists("/test"); stat != nil {
+			t.Error("Expected /test to be deleted")
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// DeleteProgress is reported to the function given to WithDeleteProgress
+// after every node a recursive delete removes.
+type DeleteProgress struct {
+	// Path is the node just deleted.
+	Path string
+	// Deleted counts the nodes deleted so far, out of Total found when the
+	// delete started, the root included. Nodes already gone by the time they
+	// are deleted, as when resuming an interrupted delete, count as deleted.
+	Deleted int
+	Total   int
+}
+
+// WithDeleteConcurrency lets recursive deletes remove up to n nodes at once.
+// The tree is deleted a level at a time, deepest first, so a node is only
+// deleted once all of its descendants are.
+func WithDeleteConcurrency(n int) DeleteOption {
+	return func(o deleteOptions) deleteOptions {
+		o.concurrency = n
+		return o
+	}
+}
+
+// WithDeleteRate limits recursive deletes to perSecond deletions, however
+// many are made at once, so that deleting a large tree doesn't swamp the
+// ensemble with writes.
+func WithDeleteRate(perSecond float64) DeleteOption {
+	return func(o deleteOptions) deleteOptions {
+		o.rate = perSecond
+		return o
+	}
+}
+
+// WithDeleteProgress calls progress after every node a recursive delete
+// removes. It may be called from several goroutines with
+// WithDeleteConcurrency, though never at once, and must not block.
+func WithDeleteProgress(progress func(DeleteProgress)) DeleteOption {
+	return func(o deleteOptions) deleteOptions {
+		o.progress = progress
+		return o
+	}
+}
+
+// deleteTree deletes descendants, deepest first, then root if its version
+// matches. Descendants already gone are skipped, so an interrupted delete
+// resumes by deleting the same tree again.
+func (s *ZKSession) deleteTree(root string, version int, descendants []string, o deleteOptions) error {
+	levels := make(map[int][]string)
+	var depths []int
+	for _, node := range descendants {
+		depth := strings.Count(node, "/")
+		if _, ok := levels[depth]; !ok {
+			depths = append(depths, depth)
+		}
+		levels[depth] = append(levels[depth], node)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(depths)))
+
+	d := &treeDeleter{session: s, opts: o, total: len(descendants) + 1}
+	if o.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / o.rate))
+		defer ticker.Stop()
+		d.tick = ticker.C
+	}
+	for _, depth := range depths {
+		if err := d.deleteLevel(levels[depth]); err != nil {
+			return err
+		}
+	}
+	return d.delete(root, version, false)
+}
+
+type treeDeleter struct {
+	session *ZKSession
+	opts    deleteOptions
+	tick    <-chan time.Time
+
+	mu      sync.Mutex
+	deleted int
+	total   int
+}
+
+// deleteLevel deletes nodes, none of which is an ancestor of another.
+func (d *treeDeleter) deleteLevel(nodes []string) error {
+	workers := d.opts.concurrency
+	if workers > len(nodes) {
+		workers = len(nodes)
+	}
+	if workers <= 1 {
+		for _, node := range nodes {
+			if err := d.delete(node, -1, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	work := make(chan string)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range work {
+				if err := d.delete(node, -1, true); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for _, node := range nodes {
+		select {
+		case work <- node:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	return err
+}
+
+// delete deletes the node at path, at the configured rate, and reports
+// progress. With missingOK, a node already gone counts as deleted.
+func (d *treeDeleter) delete(path string, version int, missingOK bool) error {
+	if d.tick != nil {
+		<-d.tick
+	}
+	err := d.session.Delete(path, version)
+	if err != nil && !(missingOK && zookeeper.IsError(err, zookeeper.ZNONODE)) {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleted++
+	if d.opts.progress != nil {
+		p := DeleteProgress{Path: path, Deleted: d.deleted, Total: d.total}
+		_ = Protect(d.session, "delete progress", func() { d.opts.progress(p) })
+	}
+	return nil
+}
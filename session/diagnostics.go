@@ -0,0 +1,226 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ServerDiagnosis is what a diagnostics sweep found out about one server.
+type ServerDiagnosis struct {
+	Server string
+	// Reachable reports whether the server accepted a TCP connection, and
+	// Latency how long that took.
+	Reachable bool
+	Latency   time.Duration
+	// Ruok reports whether the server answered "imok" to ruok.
+	Ruok bool
+	// Mode and Zxid are the server's answers to srvr, such as "follower" and
+	// "0x100000002", or empty if srvr failed.
+	Mode string
+	Zxid string
+	// Err is the first check that failed.
+	Err error
+
+	srvrErr error
+}
+
+// srvrAnswered reports whether a reachable server answered srvr, with an
+// empty Mode if it isn't serving requests.
+func (d ServerDiagnosis) srvrAnswered() bool {
+	return d.Reachable && d.srvrErr == nil
+}
+
+func (d ServerDiagnosis) String() string {
+	if !d.Reachable {
+		return fmt.Sprintf("%s unreachable: %v", d.Server, d.Err)
+	}
+	parts := []string{fmt.Sprintf("%s reachable in %s", d.Server, d.Latency.Round(time.Microsecond))}
+	if d.Ruok {
+		parts = append(parts, "imok")
+	}
+	if d.Mode != "" {
+		parts = append(parts, "mode "+d.Mode, "zxid "+d.Zxid)
+	}
+	if d.Err != nil {
+		parts = append(parts, d.Err.Error())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// errStateAuthFailed is the failure of a session whose connection reported
+// STATE_AUTH_FAILED.
+var errStateAuthFailed = errors.New("zookeeper.STATE_AUTH_FAILURE")
+
+// fail delivers SessionFailed and logs err, along with the findings of a
+// diagnostics sweep when WithFailureDiagnostics is given. SessionFailed is
+// preceded by the event telling its cause, if classifyFailure can.
+func (s *ZKSession) fail(err error) {
+	if s.opts.diagnostics {
+		s.beat(time.Now().Add(s.opts.diagnosticsTimeout))
+	}
+	diagnoses := s.diagnose()
+
+	if cause, ok := classifyFailure(err, diagnoses); ok {
+		s.publish(cause, diagnoses)
+	}
+	s.publish(SessionFailed, diagnoses)
+	s.log.Printf("gozk-recipes/session.SessionFailed: %v, session terminated", err)
+	s.logDiagnoses(diagnoses)
+}
+
+// classifyFailure tells from err and the diagnoses of the sweep, if any,
+// whether the session failed for its credentials, a partition from every
+// server, or the ensemble not serving. A server that is reachable but doesn't
+// answer srvr, as when it isn't whitelisted, can't tell whether it serves, so
+// then no quorum loss is reported.
+func classifyFailure(err error, diagnoses []ServerDiagnosis) (ZKSessionEvent, bool) {
+	var zkErr *zookeeper.Error
+	if errors.Is(err, errStateAuthFailed) || (errors.As(err, &zkErr) && zkErr.Code == zookeeper.ZAUTHFAILED) {
+		return SessionFailedAuth, true
+	}
+	if len(diagnoses) == 0 {
+		return 0, false
+	}
+
+	reachable, answered := 0, 0
+	for _, d := range diagnoses {
+		if !d.Reachable {
+			continue
+		}
+		reachable++
+		if d.Mode != "" {
+			// Serving, so the ensemble has a quorum.
+			return 0, false
+		}
+		if d.srvrAnswered() {
+			answered++
+		}
+	}
+	switch {
+	case reachable == 0:
+		return SessionFailedPartition, true
+	case answered == reachable:
+		return SessionFailedQuorumLoss, true
+	}
+	return 0, false
+}
+
+// diagnose runs the diagnostics sweep if WithFailureDiagnostics is given.
+func (s *ZKSession) diagnose() []ServerDiagnosis {
+	if !s.opts.diagnostics {
+		return nil
+	}
+	return diagnoseServers(s.opts.servers, s.opts.diagnosticsTimeout)
+}
+
+func (s *ZKSession) logDiagnoses(diagnoses []ServerDiagnosis) {
+	for _, d := range diagnoses {
+		s.log.Printf("gozk-recipes/session: diagnostics: %s", d)
+	}
+}
+
+// DiagnoseServers runs the checks of WithFailureDiagnostics against servers,
+// given as host:port, in parallel, for tools reporting on an ensemble
+// without a session. It gives up after timeout.
+func DiagnoseServers(servers []string, timeout time.Duration) []ServerDiagnosis {
+	return diagnoseServers(servers, timeout)
+}
+
+// diagnoseServers checks every server in parallel, giving up after timeout.
+func diagnoseServers(servers []string, timeout time.Duration) []ServerDiagnosis {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	diagnoses := make([]ServerDiagnosis, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			diagnoses[i] = diagnoseServer(ctx, server)
+		}(i, server)
+	}
+	wg.Wait()
+	return diagnoses
+}
+
+func diagnoseServer(ctx context.Context, server string) ServerDiagnosis {
+	d := ServerDiagnosis{Server: server}
+
+	latency, err := TCPProber()(ctx, server)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+	d.Reachable = true
+	d.Latency = latency
+
+	if _, err := RuokProber()(ctx, server); err != nil {
+		d.Err = fmt.Errorf("ruok: %w", err)
+	} else {
+		d.Ruok = true
+	}
+
+	reply, err := fourLetterWord(ctx, server, "srvr")
+	if err != nil {
+		d.srvrErr = err
+		if d.Err == nil {
+			d.Err = fmt.Errorf("srvr: %w", err)
+		}
+		return d
+	}
+	d.Mode, d.Zxid = parseSrvr(reply)
+	return d
+}
+
+// fourLetterWord sends cmd to server and returns its whole reply.
+func fourLetterWord(ctx context.Context, server, cmd string) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return "", err
+	}
+	reply, err := io.ReadAll(io.LimitReader(conn, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(string(reply), "is not executed because it is not in the whitelist") {
+		return "", fmt.Errorf("%s is not whitelisted", cmd)
+	}
+	return string(reply), nil
+}
+
+// parseSrvr extracts the mode and zxid from a srvr reply.
+func parseSrvr(reply string) (mode, zxid string) {
+	scanner := bufio.NewScanner(strings.NewReader(reply))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Mode":
+			mode = strings.TrimSpace(value)
+		case "Zxid":
+			zxid = strings.TrimSpace(value)
+		}
+	}
+	return mode, zxid
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveFourLetterWords answers ruok and srvr like a healthy follower.
+func serveFourLetterWords(t *testing.T) string {
+	return serveSrvr(t, "Zookeeper version: 3.8.4\nZxid: 0x100000002\nMode: follower\nNode count: 5\n")
+}
+
+// serveSrvr answers ruok, and srvr with reply.
+func serveSrvr(t *testing.T, reply string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4)
+			if _, err := conn.Read(buf); err == nil {
+				switch string(buf) {
+				case "ruok":
+					conn.Write([]byte("imok"))
+				case "srvr":
+					conn.Write([]byte(reply))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func unreachableServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestDiagnoseServersShouldCheckEveryServer(t *testing.T) {
+	healthy, down := serveFourLetterWords(t), unreachableServer(t)
+
+	diagnoses := diagnoseServers([]string{healthy, down}, time.Second)
+	assert.Len(t, diagnoses, 2)
+
+	assert.True(t, diagnoses[0].Reachable)
+	assert.True(t, diagnoses[0].Ruok)
+	assert.Equal(t, "follower", diagnoses[0].Mode)
+	assert.Equal(t, "0x100000002", diagnoses[0].Zxid)
+	assert.NoError(t, diagnoses[0].Err)
+
+	assert.False(t, diagnoses[1].Reachable)
+	assert.Error(t, diagnoses[1].Err)
+	assert.True(t, strings.HasPrefix(diagnoses[1].String(), down+" unreachable"))
+}
+
+func TestFailShouldAttachDiagnostics(t *testing.T) {
+	healthy := serveFourLetterWords(t)
+	s := &ZKSession{opts: WithFailureDiagnostics(time.Second)(SessionOpts{servers: []string{healthy}}), log: &nullLogger{}}
+	events := make(chan SessionEvent, 1)
+	s.SubscribeEvents(events)
+
+	s.fail(errors.New("quorum lost"))
+
+	event := <-events
+	assert.Equal(t, SessionFailed, event.Event)
+	if assert.Len(t, event.Diagnostics, 1) {
+		assert.Equal(t, "follower", event.Diagnostics[0].Mode)
+	}
+}
+
+func TestFailShouldTellQuorumLossFromPartition(t *testing.T) {
+	notServing := serveSrvr(t, "This ZooKeeper instance is not currently serving requests\n")
+	for name, tc := range map[string]struct {
+		servers []string
+		cause   ZKSessionEvent
+	}{
+		"quorum loss": {[]string{notServing, unreachableServer(t)}, SessionFailedQuorumLoss},
+		"partition":   {[]string{unreachableServer(t), unreachableServer(t)}, SessionFailedPartition},
+	} {
+		s := &ZKSession{opts: WithFailureDiagnostics(time.Second)(SessionOpts{servers: tc.servers}), log: &nullLogger{}}
+		events := make(chan SessionEvent, 2)
+		s.SubscribeEvents(events)
+
+		s.fail(errors.New("redial failed"))
+
+		assert.Equal(t, tc.cause, (<-events).Event, name)
+		assert.Equal(t, SessionFailed, (<-events).Event, name)
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	serving := ServerDiagnosis{Reachable: true, Mode: "leader"}
+	notServing := ServerDiagnosis{Reachable: true}
+	unwhitelisted := ServerDiagnosis{Reachable: true, srvrErr: errors.New("srvr is not whitelisted")}
+	down := ServerDiagnosis{}
+	redial := errors.New("redial failed")
+
+	cause, ok := classifyFailure(fmt.Errorf("adding digest auth: %w", &zookeeper.Error{Code: zookeeper.ZAUTHFAILED}), nil)
+	assert.True(t, ok)
+	assert.Equal(t, SessionFailedAuth, cause)
+	cause, _ = classifyFailure(errStateAuthFailed, []ServerDiagnosis{down})
+	assert.Equal(t, SessionFailedAuth, cause)
+
+	_, ok = classifyFailure(redial, nil)
+	assert.False(t, ok)
+	_, ok = classifyFailure(redial, []ServerDiagnosis{serving, notServing, down})
+	assert.False(t, ok)
+	_, ok = classifyFailure(redial, []ServerDiagnosis{notServing, unwhitelisted})
+	assert.False(t, ok)
+	cause, _ = classifyFailure(redial, []ServerDiagnosis{notServing, notServing, down})
+	assert.Equal(t, SessionFailedQuorumLoss, cause)
+	cause, _ = classifyFailure(redial, []ServerDiagnosis{down, down})
+	assert.Equal(t, SessionFailedPartition, cause)
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// DigestCredentials returns the id a digest ACL entry must use for user and
+// password: the user name and the base64 SHA-1 of "user:password", as
+// ZooKeeper's DigestAuthenticationProvider computes it.
+func DigestCredentials(user, password string) string {
+	sum := sha1.Sum([]byte(user + ":" + password))
+	return user + ":" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// DigestACL returns an ACL granting perms to the digest user.
+func DigestACL(user, password string, perms uint32) []zookeeper.ACL {
+	return []zookeeper.ACL{{Perms: perms, Scheme: "digest", Id: DigestCredentials(user, password)}}
+}
+
+// DefaultACL returns the ACL the session's helpers, such as
+// CreateRecursiveAndSet and Update, create nodes with: open to everyone,
+// unless the session was created with WithDigestAuth.
+func (s *ZKSession) DefaultACL() []zookeeper.ACL {
+	if s.opts.defaultACL != nil {
+		return s.opts.defaultACL
+	}
+	return defaultACLs
+}
+
+// defaultACLOf returns s's DefaultACL, or an open ACL for sessions without
+// one.
+func defaultACLOf(s Session) []zookeeper.ACL {
+	if withACL, ok := s.(interface{ DefaultACL() []zookeeper.ACL }); ok {
+		return withACL.DefaultACL()
+	}
+	return defaultACLs
+}
@@ -0,0 +1,22 @@
+package session
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestCredentialsShouldMatchZooKeeper(t *testing.T) {
+	// The example superDigest from the ZooKeeper administrator's guide.
+	assert.Equal(t, "super:D/InIHSb7yEEbrWz8b9l71RjZJU=", DigestCredentials("super", "test"))
+}
+
+func TestWithDigestAuthShouldSetAuthAndACL(t *testing.T) {
+	opts := WithDigestAuth("super", "test")(SessionOpts{})
+	assert.Equal(t, []AuthConfig{{Scheme: "digest", Credentials: "super:test"}}, opts.auth)
+
+	s := &ZKSession{opts: opts}
+	assert.Equal(t, []zookeeper.ACL{{Perms: zookeeper.PERM_ALL, Scheme: "digest", Id: "super:D/InIHSb7yEEbrWz8b9l71RjZJU="}}, s.DefaultACL())
+	assert.Equal(t, defaultACLs, (&ZKSession{}).DefaultACL())
+}
@@ -0,0 +1,227 @@
+package session
+
+import (
+	"fmt"
+	gopath "path"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// JournalEntry records a mutating operation skipped by a dry-run session.
+type JournalEntry struct {
+	Time    time.Time
+	Op      string
+	Path    string
+	Data    string
+	Flags   int
+	Version int
+	ACL     []zookeeper.ACL
+}
+
+// dryRunJournal collects the operations a dry-run session would have sent.
+// It also keeps the nodes the session would have created and not deleted
+// since, which Children lists, and the next sequence number of each parent
+// sequential nodes were created under.
+type dryRunJournal struct {
+	mu        sync.Mutex
+	entries   []JournalEntry
+	created   map[string]bool
+	sequences map[string]int
+}
+
+// isCreated reports whether the dry run created the node at path.
+func (j *dryRunJournal) isCreated(path string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.created[path]
+}
+
+// setCreated records whether the node at path exists only in the dry run.
+func (j *dryRunJournal) setCreated(path string, created bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.created == nil {
+		j.created = map[string]bool{}
+	}
+	if created {
+		j.created[path] = true
+	} else {
+		delete(j.created, path)
+	}
+}
+
+// nextSequence returns the sequence number of the next sequential node under
+// parent. The server numbers them with the parent's child version, cversion,
+// which the dry run's own creates don't bump, so they are counted here.
+func (j *dryRunJournal) nextSequence(parent string, cversion int) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.sequences == nil {
+		j.sequences = map[string]int{}
+	}
+	seq := j.sequences[parent]
+	if seq < cversion {
+		seq = cversion
+	}
+	j.sequences[parent] = seq + 1
+	return seq
+}
+
+// withCreated adds to children, those of the node at path, the ones the dry
+// run created.
+func (j *dryRunJournal) withCreated(path string, children []string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for created := range j.created {
+		if gopath.Dir(created) != path {
+			continue
+		}
+		name := gopath.Base(created)
+		listed := false
+		for _, child := range children {
+			listed = listed || child == name
+		}
+		if !listed {
+			children = append(children, name)
+		}
+	}
+	return children
+}
+
+// Journal returns the operations skipped by a session created with
+// WithDryRun, oldest first.
+func (s *ZKSession) Journal() []JournalEntry {
+	if s.journal == nil {
+		return nil
+	}
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	return append([]JournalEntry(nil), s.journal.entries...)
+}
+
+func (s *ZKSession) record(entry JournalEntry) {
+	entry.Time = time.Now()
+	s.log.Printf("gozk-recipes/session: dry run: %s %q (version %d, %d bytes)", entry.Op, entry.Path, entry.Version, len(entry.Data))
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	s.journal.entries = append(s.journal.entries, entry)
+}
+
+// The dryRun methods check an operation against the live tree, returning the
+// error the server would, and record it instead of sending it. value has
+// already been validated and encoded.
+
+func (s *ZKSession) dryRunCreate(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	// The path of a sequential node is only a prefix, such as one ending in
+	// "/", which the server would reject as a node path.
+	if flags&zookeeper.SEQUENCE == 0 {
+		if stat, err := s.conn.Exists(path); err != nil {
+			return "", err
+		} else if stat != nil || s.journal.isCreated(path) {
+			return "", &zookeeper.Error{Op: "create", Code: zookeeper.ZNODEEXISTS, Path: path}
+		}
+	}
+
+	parentPath := gopath.Dir(path)
+	parent, err := s.conn.Exists(parentPath)
+	if err != nil {
+		return "", err
+	}
+	if parent == nil && !s.journal.isCreated(parentPath) {
+		return "", &zookeeper.Error{Op: "create", Code: zookeeper.ZNONODE, Path: path}
+	}
+
+	s.record(JournalEntry{Op: "create", Path: path, Data: value, Flags: flags, ACL: aclv})
+	created := path
+	if flags&zookeeper.SEQUENCE != 0 {
+		cversion := 0
+		if parent != nil {
+			cversion = parent.CVersion()
+		}
+		created = fmt.Sprintf("%s%010d", path, s.journal.nextSequence(parentPath, cversion))
+	}
+	s.journal.setCreated(created, true)
+	return created, nil
+}
+
+// dryRunCheck returns the node's Stat, or the error the server would return if
+// it's missing or version doesn't match.
+func (s *ZKSession) dryRunCheck(op, path string, version int, current func(*zookeeper.Stat) int) (*zookeeper.Stat, error) {
+	stat, err := s.conn.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if stat == nil {
+		return nil, &zookeeper.Error{Op: op, Code: zookeeper.ZNONODE, Path: path}
+	}
+	if version != -1 && version != current(stat) {
+		return nil, &zookeeper.Error{Op: op, Code: zookeeper.ZBADVERSION, Path: path}
+	}
+	return stat, nil
+}
+
+func dataVersion(stat *zookeeper.Stat) int { return stat.Version() }
+func aclVersion(stat *zookeeper.Stat) int  { return stat.AVersion() }
+
+func (s *ZKSession) dryRunSet(path string, value string, version int) (*zookeeper.Stat, error) {
+	stat, err := s.dryRunCheck("set", path, version, dataVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.record(JournalEntry{Op: "set", Path: path, Data: value, Version: version})
+	return stat, nil
+}
+
+func (s *ZKSession) dryRunDelete(path string, version int) error {
+	if s.journal.isCreated(path) {
+		s.journal.setCreated(path, false)
+		s.record(JournalEntry{Op: "delete", Path: path, Version: version})
+		return nil
+	}
+	stat, err := s.dryRunCheck("delete", path, version, dataVersion)
+	if err != nil {
+		return err
+	}
+	if stat.NumChildren() > 0 {
+		return &zookeeper.Error{Op: "delete", Code: zookeeper.ZNOTEMPTY, Path: path}
+	}
+	s.record(JournalEntry{Op: "delete", Path: path, Version: version})
+	return nil
+}
+
+func (s *ZKSession) dryRunSetACL(path string, aclv []zookeeper.ACL, version int) error {
+	if _, err := s.dryRunCheck("setacl", path, version, aclVersion); err != nil {
+		return err
+	}
+	s.record(JournalEntry{Op: "setacl", Path: path, Version: version, ACL: aclv})
+	return nil
+}
+
+// dryRunRetryChange runs change once against the node's current value, which
+// returns the encoded value to write.
+func (s *ZKSession) dryRunRetryChange(path string, flags int, acl []zookeeper.ACL, change zookeeper.ChangeFunc) error {
+	value, stat, err := s.conn.Get(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		newValue, err := change("", nil)
+		if err != nil {
+			return err
+		}
+		_, err = s.dryRunCreate(path, newValue, flags, acl)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	newValue, err := change(value, stat)
+	if err != nil {
+		return err
+	}
+	if newValue == value {
+		return nil
+	}
+	_, err = s.dryRunSet(path, newValue, stat.Version())
+	return err
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunShouldRecordWithoutWriting(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/foo")
+
+		dry, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithDryRun())
+		if err != nil {
+			t.Fatal("Failed to connect to Zookeeper: ", err)
+		}
+		defer dry.Close()
+
+		if _, err := dry.Set("/test/foo", "changed", -1); err != nil {
+			t.Error("Set error: ", err)
+		}
+		if _, err := dry.Create("/test/bar", "new", 0, defaultACLs); err != nil {
+			t.Error("Create error: ", err)
+		}
+		AssertNodeValueEqual(t, session, "/test/foo", "/test/foo")
+		if stat, _ := session.Exists("/test/bar"); stat != nil {
+			t.Error("Expected /test/bar not to be created")
+		}
+
+		journal := dry.Journal()
+		if assert.Len(t, journal, 2) {
+			assert.Equal(t, "set", journal[0].Op)
+			assert.Equal(t, "changed", journal[0].Data)
+			assert.Equal(t, "create", journal[1].Op)
+			assert.Equal(t, "/test/bar", journal[1].Path)
+		}
+	})
+}
+
+func TestDryRunShouldReportServerErrors(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/foo")
+
+		dry, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithDryRun())
+		if err != nil {
+			t.Fatal("Failed to connect to Zookeeper: ", err)
+		}
+		defer dry.Close()
+
+		_, err = dry.Create("/test/foo", "", 0, defaultACLs)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNODEEXISTS))
+
+		_, err = dry.Set("/test/foo", "", 5)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZBADVERSION))
+
+		err = dry.Delete("/test", -1)
+		assert.True(t, zookeeper.IsError(err, zookeeper.ZNOTEMPTY))
+
+		assert.Empty(t, dry.Journal())
+	})
+}
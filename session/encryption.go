@@ -0,0 +1,134 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// encryptionMagic prefixes values encrypted by the session. It is followed by
+// a one byte key ID length, the key ID, the nonce and the AES-GCM sealed data.
+const encryptionMagic = "\x00gzk\x02"
+
+// ErrNoKeyProvider is returned when reading an encrypted value from a session
+// without encryption configured.
+var ErrNoKeyProvider = errors.New("value is encrypted but no key provider is configured")
+
+// KeyProvider supplies AES keys for value encryption. Keys are identified so
+// they can be rotated: values are written with the current key, and the key ID
+// stored with each value picks the key to decrypt it with. A provider can
+// implement envelope encryption by returning data keys unwrapped through a
+// KMS.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt new values with, and its ID. IDs
+	// are at most 255 bytes.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the given ID.
+	Key(id string) ([]byte, error)
+}
+
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// StaticKeys returns a KeyProvider that encrypts with the key named current
+// and can decrypt with any of keys. Keys must be 16, 24 or 32 bytes long, to
+// select AES-128, AES-192 or AES-256.
+func StaticKeys(current string, keys map[string][]byte) KeyProvider {
+	return &staticKeys{current: current, keys: keys}
+}
+
+func (k *staticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.current)
+	return k.current, key, err
+}
+
+func (k *staticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// encrypts reports whether values written to path are encrypted.
+func (s *ZKSession) encrypts(path string) bool {
+	if s.opts.keys == nil {
+		return false
+	}
+	for _, prefix := range s.opts.encryptPrefixes {
+		if underPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ZKSession) encrypt(path string, value string) (string, error) {
+	id, key, err := s.opts.keys.CurrentKey()
+	if err != nil {
+		return "", fmt.Errorf("encrypting data for %q: %w", path, err)
+	}
+	if len(id) > 255 {
+		return "", fmt.Errorf("encrypting data for %q: key ID %q is too long", path, id)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", fmt.Errorf("encrypting data for %q: %w", path, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("encrypting data for %q: %w", path, err)
+	}
+
+	header := encryptionMagic + string([]byte{byte(len(id))}) + id
+	// The path is authenticated so values can't be swapped between nodes.
+	sealed := aead.Seal(nil, nonce, []byte(value), []byte(path))
+	return header + string(nonce) + string(sealed), nil
+}
+
+func (s *ZKSession) decrypt(path string, value string) (string, error) {
+	if s.opts.keys == nil {
+		return "", fmt.Errorf("decrypting data for %q: %w", path, ErrNoKeyProvider)
+	}
+
+	value = strings.TrimPrefix(value, encryptionMagic)
+	if len(value) < 1 || len(value) < 1+int(value[0]) {
+		return "", fmt.Errorf("decrypting data for %q: truncated header", path)
+	}
+	id := value[1 : 1+int(value[0])]
+	value = value[1+len(id):]
+
+	key, err := s.opts.keys.Key(id)
+	if err != nil {
+		return "", fmt.Errorf("decrypting data for %q: %w", path, err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", fmt.Errorf("decrypting data for %q: %w", path, err)
+	}
+	if len(value) < aead.NonceSize() {
+		return "", fmt.Errorf("decrypting data for %q: truncated nonce", path)
+	}
+
+	nonce, sealed := value[:aead.NonceSize()], value[aead.NonceSize():]
+	plain, err := aead.Open(nil, []byte(nonce), []byte(sealed), []byte(path))
+	if err != nil {
+		return "", fmt.Errorf("decrypting data for %q: %w", path, err)
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
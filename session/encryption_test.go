@@ -0,0 +1,90 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeys(current string) KeyProvider {
+	return StaticKeys(current, map[string][]byte{
+		"v1": []byte("0123456789abcdef"),
+		"v2": []byte("0123456789abcdef0123456789abcdef"),
+	})
+}
+
+func TestEncryptedValueShouldRoundTrip(t *testing.T) {
+	s := &ZKSession{opts: WithEncryption(testKeys("v1"), "/secrets")(SessionOpts{})}
+
+	encoded, err := s.encodeValue("/secrets/db", "hunter2")
+	if err != nil {
+		t.Fatal("encodeValue error: ", err)
+	}
+	assert.True(t, strings.HasPrefix(encoded, encryptionMagic))
+	assert.NotContains(t, encoded, "hunter2")
+
+	decoded, err := s.decodeValue("/secrets/db", encoded)
+	if err != nil {
+		t.Fatal("decodeValue error: ", err)
+	}
+	assert.Equal(t, "hunter2", decoded)
+}
+
+func TestEncryptionShouldOnlyApplyUnderPrefixes(t *testing.T) {
+	s := &ZKSession{opts: WithEncryption(testKeys("v1"), "/secrets")(SessionOpts{})}
+
+	encoded, err := s.encodeValue("/secretsauce", "plain")
+	if err != nil {
+		t.Fatal("encodeValue error: ", err)
+	}
+	assert.Equal(t, "plain", encoded)
+}
+
+func TestEncryptionShouldComposeWithCompression(t *testing.T) {
+	opts := WithEncryption(testKeys("v1"), "/secrets")(WithCompression(16)(SessionOpts{}))
+	s := &ZKSession{opts: opts}
+	value := strings.Repeat("compressible ", 1000)
+
+	encoded, err := s.encodeValue("/secrets/big", value)
+	if err != nil {
+		t.Fatal("encodeValue error: ", err)
+	}
+	assert.True(t, len(encoded) < len(value))
+
+	decoded, err := s.decodeValue("/secrets/big", encoded)
+	if err != nil {
+		t.Fatal("decodeValue error: ", err)
+	}
+	assert.Equal(t, value, decoded)
+}
+
+func TestDecryptShouldUseStoredKeyID(t *testing.T) {
+	old := &ZKSession{opts: WithEncryption(testKeys("v1"), "/secrets")(SessionOpts{})}
+	rotated := &ZKSession{opts: WithEncryption(testKeys("v2"), "/secrets")(SessionOpts{})}
+
+	encoded, err := old.encodeValue("/secrets/db", "hunter2")
+	if err != nil {
+		t.Fatal("encodeValue error: ", err)
+	}
+	decoded, err := rotated.decodeValue("/secrets/db", encoded)
+	if err != nil {
+		t.Fatal("decodeValue error: ", err)
+	}
+	assert.Equal(t, "hunter2", decoded)
+}
+
+func TestDecryptShouldRejectMovedValues(t *testing.T) {
+	s := &ZKSession{opts: WithEncryption(testKeys("v1"), "/secrets")(SessionOpts{})}
+
+	encoded, err := s.encodeValue("/secrets/db", "hunter2")
+	if err != nil {
+		t.Fatal("encodeValue error: ", err)
+	}
+	_, err = s.decodeValue("/secrets/other", encoded)
+	assert.Error(t, err)
+
+	_, err = (&ZKSession{}).decodeValue("/secrets/db", encoded)
+	assert.True(t, errors.Is(err, ErrNoKeyProvider))
+}
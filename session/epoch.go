@@ -0,0 +1,40 @@
+package session
+
+import "sync/atomic"
+
+// Epoch returns the session's generation: 0 for the first ZooKeeper session,
+// incremented every time the session expires and is replaced, or the
+// connection otherwise ends up on a new ZooKeeper session. Results
+// requested under an older epoch were produced by a session that no longer
+// exists, and any state derived from it, such as ephemeral nodes and watches,
+// is gone.
+func (s *ZKSession) Epoch() uint64 {
+	return atomic.LoadUint64(&s.epoch)
+}
+
+// EpochOf returns the epoch of s, or 0 if s doesn't track epochs.
+func EpochOf(s Session) uint64 {
+	if e, ok := s.(interface{ Epoch() uint64 }); ok {
+		return e.Epoch()
+	}
+	return 0
+}
+
+// sessionChanged reports whether the connection is on a different ZooKeeper
+// session than when last checked, remembering the current one. It is only
+// called from the manage loop.
+func (s *ZKSession) sessionChanged() bool {
+	return s.noteSession(affinityToken(s.conn.ClientId()))
+}
+
+// noteSession remembers token as identifying the current session, reporting
+// whether it replaces a different one. An empty token, of a session not yet
+// established, is ignored.
+func (s *ZKSession) noteSession(token string) bool {
+	if token == "" {
+		return false
+	}
+	changed := s.sessionToken != "" && token != s.sessionToken
+	s.sessionToken = token
+	return changed
+}
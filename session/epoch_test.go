@@ -0,0 +1,26 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpochOfShouldDefaultToZero(t *testing.T) {
+	s := &ZKSession{epoch: 3}
+	assert.Equal(t, uint64(3), EpochOf(s))
+
+	var wrapped struct{ Session }
+	wrapped.Session = s
+	assert.Equal(t, uint64(0), EpochOf(wrapped))
+}
+
+func TestNoteSessionShouldReportNewSessions(t *testing.T) {
+	s := &ZKSession{}
+	assert.False(t, s.noteSession(""), "no session yet")
+	assert.False(t, s.noteSession("first"), "first session seen")
+	assert.False(t, s.noteSession("first"), "same session")
+	assert.False(t, s.noteSession(""), "not established yet")
+	assert.True(t, s.noteSession("second"), "new session")
+	assert.Equal(t, "second", s.sessionToken)
+}
@@ -0,0 +1,35 @@
+package session
+
+import "fmt"
+
+// InternalError is an error the session handled itself rather than returning
+// it to a caller, such as failing to close a replaced connection or a
+// reconnect hook returning an error. See WithErrorChannel.
+type InternalError struct {
+	// Op describes what the session was doing, for example "closing
+	// connection".
+	Op  string
+	Err error
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// reportError delivers err to the channel given to WithErrorChannel, if any,
+// without blocking. It returns true if strict mode turns the error into a
+// session failure, which is only the case for errors marked as escalating.
+// Callers remain responsible for logging.
+func (s *ZKSession) reportError(op string, err error, escalate bool) bool {
+	if s.opts.errors != nil {
+		select {
+		case s.opts.errors <- &InternalError{Op: op, Err: err}:
+		default:
+		}
+	}
+	return escalate && s.opts.strict
+}
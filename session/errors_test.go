@@ -0,0 +1,34 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportErrorShouldDeliverWithoutBlocking(t *testing.T) {
+	errs := make(chan error, 1)
+	s := &ZKSession{opts: WithErrorChannel(errs)(SessionOpts{})}
+
+	failure := errors.New("close failed")
+	assert.False(t, s.reportError("closing connection", failure, true))
+	assert.False(t, s.reportError("closing connection", failure, false))
+
+	var internal *InternalError
+	assert.True(t, errors.As(<-errs, &internal))
+	assert.Equal(t, "closing connection", internal.Op)
+	assert.ErrorIs(t, internal, failure)
+	assert.Len(t, errs, 0)
+}
+
+func TestStrictModeShouldFailOnReconnectHookErrors(t *testing.T) {
+	failure := errors.New("hook failed")
+	opts := WithOnReconnect(func(bool) error { return failure })(SessionOpts{})
+
+	lenient := &ZKSession{opts: opts, log: &nullLogger{}}
+	assert.NoError(t, lenient.runReconnectHooks(false))
+
+	strict := &ZKSession{opts: WithStrictMode()(opts), log: &nullLogger{}}
+	assert.ErrorIs(t, strict.runReconnectHooks(false), failure)
+}
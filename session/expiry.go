@@ -0,0 +1,50 @@
+package session
+
+import "errors"
+
+// ErrExpirySimulationDisabled is returned by SimulateExpiry for sessions
+// created without WithExpirySimulation.
+var ErrExpirySimulationDisabled = errors.New("expiry simulation not enabled for this session")
+
+// SimulateExpiry makes the session behave as though it expired, without
+// needing the server to expire it, so tests can exercise expiry handling.
+// Subscribers see SessionDisconnected; the session is then closed, deleting
+// its ephemeral nodes and firing its watches, and a new session is
+// established. As after a real expiry, the epoch is advanced, the hooks
+// registered with WithOnReconnect run with expired set and subscribers see
+// SessionExpiredReconnected, all before SimulateExpiry returns. If the new
+// session can't be established the session fails. With WithNoAutoRedial,
+// subscribers see SessionExpired instead and no new session is established.
+//
+// It requires WithExpirySimulation.
+func (s *ZKSession) SimulateExpiry() error {
+	if !s.opts.expirySimulate {
+		return ErrExpirySimulationDisabled
+	}
+	req := reconnectRequest{expire: true, done: make(chan error, 1)}
+	select {
+	case s.reconnects <- req:
+	case <-s.managed:
+		return ErrSessionTerminated
+	}
+	return <-req.done
+}
+
+// expire closes the current session and replaces it with a new one.
+func (s *ZKSession) expire() error {
+	s.closeConn()
+	conn, events, err := s.dialSession()
+	if err != nil {
+		return err
+	}
+	s.replaceConn(conn, events)
+	s.log.Printf("gozk-recipes/session: session replaced after simulated expiry, now connected to %s", conn.ConnectedServer())
+	return nil
+}
+
+// reportExpired delivers SessionExpired, for sessions that don't redial after
+// an expiry.
+func (s *ZKSession) reportExpired(reason string) {
+	s.notifySubscribers(SessionExpired)
+	s.log.Printf("gozk-recipes/session.SessionExpired: %s, not redialing as automatic redial is disabled", reason)
+}
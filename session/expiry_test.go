@@ -0,0 +1,76 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateExpiryShouldReplaceSession(t *testing.T) {
+	expiredHook := false
+	s, err := NewSessionWithOpts(
+		WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")),
+		WithExpirySimulation(),
+		WithOnReconnect(func(expired bool) error {
+			expiredHook = expired
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	path, err := s.Create("/test-simulated-expiry", "", zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan ZKSessionEvent, 4)
+	s.Subscribe(events)
+	epoch := s.Epoch()
+
+	assert.NoError(t, s.SimulateExpiry())
+	assert.Equal(t, SessionDisconnected, <-events)
+	assert.Equal(t, SessionExpiredReconnected, <-events)
+	assert.True(t, expiredHook)
+	assert.Equal(t, epoch+1, s.Epoch())
+
+	stat, err := s.Exists(path)
+	assert.NoError(t, err)
+	assert.Nil(t, stat)
+}
+
+func TestSimulateExpiryShouldRequireOption(t *testing.T) {
+	s := &ZKSession{}
+	assert.ErrorIs(t, s.SimulateExpiry(), ErrExpirySimulationDisabled)
+}
+
+func TestSimulateExpiryWithoutAutoRedialShouldEndSession(t *testing.T) {
+	s, err := NewSessionWithOpts(
+		WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")),
+		WithExpirySimulation(),
+		WithNoAutoRedial(),
+	)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	events := make(chan ZKSessionEvent, 4)
+	s.Subscribe(events)
+
+	assert.NoError(t, s.SimulateExpiry())
+	assert.Equal(t, SessionDisconnected, <-events)
+	assert.Equal(t, SessionExpired, <-events)
+	assert.ErrorIs(t, s.SimulateExpiry(), ErrSessionTerminated)
+}
+
+func TestNoAutoRedialShouldRejectMaxSessionLifetime(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 10 * time.Second, connectTimeout: time.Second}
+	assert.NoError(t, WithNoAutoRedial()(base).Validate())
+	assert.ErrorIs(t, WithNoAutoRedial()(WithMaxSessionLifetime(time.Hour)(base)).Validate(), ErrInvalidOptions)
+}
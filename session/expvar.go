@@ -0,0 +1,221 @@
+package session
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// WithExpvar publishes the session's Stats under name with the expvar
+// package, so they are served at /debug/vars along with the process's other
+// variables. A session created later with the same name, such as one replacing
+// a closed session, takes the name over.
+func WithExpvar(name string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.expvarName = name
+		return so
+	}
+}
+
+// Stats are counters kept since a session was created with WithExpvar.
+type Stats struct {
+	// Ops counts the calls made to ZooKeeper by operation, such as "get" or
+	// "create", whether they succeeded or not.
+	Ops map[string]uint64 `json:"ops"`
+	// Errors counts the failed calls by ZooKeeper error, such as "no node",
+	// with "other" for errors that didn't come from ZooKeeper.
+	Errors map[string]uint64 `json:"errors"`
+	// Reconnects counts the times the session reconnected, whether or not
+	// it had expired meanwhile.
+	Reconnects uint64 `json:"reconnects"`
+	// Watches is the number of watches set and yet to fire.
+	Watches int64 `json:"watches"`
+	// AbandonedWatches counts the watches given up by ExistsWContext and the
+	// others without being removed from the server, where they stay until
+	// they fire. They are still counted in Watches meanwhile.
+	AbandonedWatches uint64 `json:"abandoned_watches"`
+	// Subscriptions is the number of channels subscribed to the session's
+	// events.
+	Subscriptions int `json:"subscriptions"`
+	// InvalidReads counts the reads rejected by WithReadValidator, by the
+	// validator's pattern.
+	InvalidReads map[string]uint64 `json:"invalid_reads"`
+}
+
+// Stats returns the session's counters, which are all zero unless the session
+// was created with WithExpvar.
+func (s *ZKSession) Stats() Stats {
+	stats := s.stats.snapshot()
+	if s.stats != nil {
+		stats.Subscriptions = s.stats.subscriptions(s)
+	}
+	return stats
+}
+
+// sessionStats keeps the counters behind Stats. Like debugState, a nil
+// *sessionStats is valid and records nothing.
+type sessionStats struct {
+	// reconnects, watches and abandonedWatches are first to keep them
+	// 64-bit aligned for atomic access.
+	reconnects       uint64
+	watches          int64
+	abandonedWatches uint64
+
+	mu           sync.Mutex
+	ops          map[string]uint64
+	errors       map[string]uint64
+	invalidReads map[string]uint64
+	// subscribed is the subscription count last read, reported while a
+	// publication holds the topics.
+	subscribed int
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{ops: make(map[string]uint64), errors: make(map[string]uint64), invalidReads: make(map[string]uint64)}
+}
+
+// record counts a call to ZooKeeper made for op, failed with err if not nil.
+func (st *sessionStats) record(op string, err error) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ops[op]++
+	if err != nil {
+		st.errors[errorCode(err)]++
+	}
+}
+
+// errorCode names the ZooKeeper error err, or "other".
+func errorCode(err error) string {
+	var zkErr *zookeeper.Error
+	if errors.As(err, &zkErr) {
+		return zkErr.Code.String()
+	}
+	return "other"
+}
+
+// recordInvalidRead counts a read rejected by the validator for pattern.
+func (st *sessionStats) recordInvalidRead(pattern string) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.invalidReads[pattern]++
+}
+
+func (st *sessionStats) recordEvent(event ZKSessionEvent) {
+	if st != nil && (event == SessionReconnected || event == SessionExpiredReconnected) {
+		atomic.AddUint64(&st.reconnects, 1)
+	}
+}
+
+// trackWatch counts watch as outstanding for DebugInfo and Stats, as
+// enabled.
+func (s *ZKSession) trackWatch(path, kind string, stat *zookeeper.Stat, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	return s.trackOwnWatch(path, kind, stat, s.countWatch(path, kind, watch))
+}
+
+// countWatch counts watch as outstanding in the session's stats and debug
+// state.
+func (s *ZKSession) countWatch(path, kind string, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	return s.stats.trackWatch(s.debug.trackWatch(path, kind, watch))
+}
+
+// trackWatch counts watch as outstanding until it fires, forwarding it
+// through a new channel as debugState.trackWatch does.
+func (st *sessionStats) trackWatch(watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if st == nil || watch == nil {
+		return watch
+	}
+
+	atomic.AddInt64(&st.watches, 1)
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		event, ok := <-watch
+		atomic.AddInt64(&st.watches, -1)
+		if ok {
+			forwarded <- event
+		}
+		close(forwarded)
+	}()
+	return forwarded
+}
+
+// subscriptions counts the subscribers to s's event topics, without waiting
+// for a publication that may be held up by one of them.
+func (st *sessionStats) subscriptions(s *ZKSession) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	plain, ok := s.sessionEvents.TrySubscribers()
+	if !ok {
+		return st.subscribed
+	}
+	sequenced, ok := s.sequencedEvents.TrySubscribers()
+	if !ok {
+		return st.subscribed
+	}
+	st.subscribed = len(plain) + len(sequenced)
+	return st.subscribed
+}
+
+func (st *sessionStats) snapshot() Stats {
+	if st == nil {
+		return Stats{Ops: map[string]uint64{}, Errors: map[string]uint64{}, InvalidReads: map[string]uint64{}}
+	}
+	stats := Stats{
+		Ops:              make(map[string]uint64),
+		Errors:           make(map[string]uint64),
+		InvalidReads:     make(map[string]uint64),
+		Reconnects:       atomic.LoadUint64(&st.reconnects),
+		Watches:          atomic.LoadInt64(&st.watches),
+		AbandonedWatches: atomic.LoadUint64(&st.abandonedWatches),
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for op, n := range st.ops {
+		stats.Ops[op] = n
+	}
+	for code, n := range st.errors {
+		stats.Errors[code] = n
+	}
+	for pattern, n := range st.invalidReads {
+		stats.InvalidReads[pattern] = n
+	}
+	return stats
+}
+
+// expvarSessions are the sessions whose Stats are published, by name, for
+// the expvar.Func published for each name, which can't be replaced.
+var (
+	expvarMu       sync.Mutex
+	expvarSessions = make(map[string]*ZKSession)
+)
+
+func publishExpvar(name string, s *ZKSession) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if _, ok := expvarSessions[name]; !ok {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			expvarMu.Lock()
+			s := expvarSessions[name]
+			expvarMu.Unlock()
+			return s.Stats()
+		}))
+	}
+	expvarSessions[name] = s
+}
+
+// expvarTaken reports whether name is already published by something other
+// than a session.
+func expvarTaken(name string) bool {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	_, ours := expvarSessions[name]
+	return !ours && expvar.Get(name) != nil
+}
@@ -0,0 +1,84 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsShouldCountOpsAndErrors(t *testing.T) {
+	s := &ZKSession{stats: newSessionStats()}
+	noNode := &zookeeper.Error{Op: "get", Code: zookeeper.ZNONODE, Path: "/foo"}
+
+	s.stats.record("get", nil)
+	s.stats.record("get", noNode)
+	s.stats.record("create", errors.New("invalid value"))
+	s.stats.recordEvent(SessionDisconnected)
+	s.stats.recordEvent(SessionReconnected)
+	s.stats.recordEvent(SessionExpiredReconnected)
+
+	stats := s.Stats()
+	assert.Equal(t, map[string]uint64{"get": 2, "create": 1}, stats.Ops)
+	assert.Equal(t, map[string]uint64{zookeeper.ZNONODE.String(): 1, "other": 1}, stats.Errors)
+	assert.Equal(t, uint64(2), stats.Reconnects)
+}
+
+func TestStatsShouldCountOutstandingWatches(t *testing.T) {
+	s := &ZKSession{stats: newSessionStats()}
+	watch := make(chan zookeeper.Event, 1)
+
+	forwarded := s.trackWatch("/foo", "data", nil, watch)
+	assert.Equal(t, int64(1), s.Stats().Watches)
+
+	watch <- zookeeper.Event{Path: "/foo"}
+	assert.Equal(t, "/foo", (<-forwarded).Path)
+	assert.Equal(t, int64(0), s.Stats().Watches)
+}
+
+func TestStatsShouldCountSubscriptions(t *testing.T) {
+	s := &ZKSession{stats: newSessionStats()}
+	s.Subscribe(make(chan ZKSessionEvent))
+	s.sequencedEvents.Subscribe(make(chan SessionEvent))
+
+	assert.Equal(t, 2, s.Stats().Subscriptions)
+}
+
+func TestStatsShouldBeEmptyWithoutExpvar(t *testing.T) {
+	s := &ZKSession{}
+	s.stats.record("get", nil)
+
+	assert.Empty(t, s.Stats().Ops)
+}
+
+func TestPublishExpvarShouldServeLatestSession(t *testing.T) {
+	name := "gozk-recipes-test-" + strings.ReplaceAll(t.Name(), "/", "-")
+	first := &ZKSession{stats: newSessionStats()}
+	first.stats.record("get", nil)
+	publishExpvar(name, first)
+
+	second := &ZKSession{stats: newSessionStats()}
+	second.stats.record("set", nil)
+	assert.False(t, expvarTaken(name))
+	publishExpvar(name, second)
+
+	var stats Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Fatal("Unmarshal error: ", err)
+	}
+	assert.Equal(t, map[string]uint64{"set": 1}, stats.Ops)
+}
+
+func TestValidateShouldRejectPublishedExpvar(t *testing.T) {
+	expvar.NewInt("gozk-recipes-test-taken")
+	opts := WithExpvar("gozk-recipes-test-taken")(WithZookeepers([]string{"localhost:2181"})(SessionOpts{
+		sessionTimeout: DefaultSessionTimeout,
+		connectTimeout: DefaultConnectTimeout,
+	}))
+
+	assert.ErrorIs(t, opts.Validate(), ErrInvalidOptions)
+}
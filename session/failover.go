@@ -0,0 +1,216 @@
+package session
+
+import (
+	"errors"
+	"sync/atomic"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/internal/eventbus"
+)
+
+// FailoverSession sends operations to a primary session, and reads to a
+// standby session while the primary is down, for read-mostly clients that
+// prefer possibly stale reads to failed ones, such as during maintenance of
+// the primary's ensemble. It implements Session, so recipes can use it
+// unchanged.
+//
+// The primary is down from the time it reports SessionDisconnected,
+// SessionGated, SessionFailed or SessionExpired until it reconnects.
+// Reads failing on the primary with an ErrSessionLost error before it
+// reports being down are retried on the standby. Writes, and everything
+// else, always go to the primary, as do reads while the standby is down too.
+//
+// The standby may be connected to another ensemble, mirroring the primary's,
+// and is best created with WithKeepalive so that it stays connected while
+// idle. Watches set while the primary is down are set on the standby, and
+// fire with its changes.
+type FailoverSession struct {
+	// degraded and standbyDown are set while the primary and the standby are
+	// down.
+	degraded    int32
+	standbyDown int32
+
+	primary Session
+	standby Session
+	events  eventbus.Topic[ZKSessionEvent]
+}
+
+var _ Session = (*FailoverSession)(nil)
+
+// NewFailoverSession returns a session failing reads over from primary to
+// standby.
+func NewFailoverSession(primary, standby Session) *FailoverSession {
+	f := &FailoverSession{primary: primary, standby: standby}
+
+	primaryEvents := make(chan ZKSessionEvent)
+	primary.Subscribe(primaryEvents)
+	Go(primary, "failover", func() { f.followPrimary(primaryEvents) })
+
+	standbyEvents := make(chan ZKSessionEvent)
+	standby.Subscribe(standbyEvents)
+	Go(standby, "failover standby", func() { f.followStandby(standbyEvents) })
+	return f
+}
+
+// followPrimary tracks the primary's state, passing its events on to
+// subscribers along with SessionDegraded and SessionRestored.
+func (f *FailoverSession) followPrimary(events <-chan ZKSessionEvent) {
+	for event := range events {
+		f.events.Publish(event)
+		switch event {
+		case SessionDisconnected, SessionGated, SessionFailed, SessionExpired:
+			if atomic.CompareAndSwapInt32(&f.degraded, 0, 1) {
+				f.events.Publish(SessionDegraded)
+			}
+		case SessionReconnected, SessionExpiredReconnected:
+			if atomic.CompareAndSwapInt32(&f.degraded, 1, 0) {
+				f.events.Publish(SessionRestored)
+			}
+		}
+		if event == SessionClosed || event == SessionFailed || event == SessionExpired {
+			return
+		}
+	}
+}
+
+// followStandby tracks the standby's state.
+func (f *FailoverSession) followStandby(events <-chan ZKSessionEvent) {
+	for event := range events {
+		switch event {
+		case SessionDisconnected, SessionGated, SessionFailed, SessionExpired, SessionClosed:
+			atomic.StoreInt32(&f.standbyDown, 1)
+		case SessionReconnected, SessionExpiredReconnected:
+			atomic.StoreInt32(&f.standbyDown, 0)
+		}
+		if event == SessionClosed || event == SessionFailed || event == SessionExpired {
+			return
+		}
+	}
+}
+
+// Degraded reports whether the primary is down, with reads going to the
+// standby if it is up.
+func (f *FailoverSession) Degraded() bool {
+	return atomic.LoadInt32(&f.degraded) == 1
+}
+
+func (f *FailoverSession) standbyUp() bool {
+	return atomic.LoadInt32(&f.standbyDown) == 0
+}
+
+// read runs op against the session reads should go to, retrying it on the
+// standby if the primary turns out to be unreachable.
+func (f *FailoverSession) read(op func(Session) error) error {
+	if f.Degraded() && f.standbyUp() {
+		return op(f.standby)
+	}
+	err := op(f.primary)
+	if err != nil && errors.Is(Classify(err), ErrSessionLost) && f.standbyUp() {
+		return op(f.standby)
+	}
+	return err
+}
+
+func (f *FailoverSession) ACL(path string) (acl []zookeeper.ACL, stat *zookeeper.Stat, err error) {
+	err = f.read(func(s Session) error {
+		acl, stat, err = s.ACL(path)
+		return err
+	})
+	return acl, stat, err
+}
+
+// AddAuth adds the credentials to both sessions.
+func (f *FailoverSession) AddAuth(scheme, cert string) error {
+	if err := f.primary.AddAuth(scheme, cert); err != nil {
+		return err
+	}
+	return f.standby.AddAuth(scheme, cert)
+}
+
+func (f *FailoverSession) Children(path string) (children []string, stat *zookeeper.Stat, err error) {
+	err = f.read(func(s Session) error {
+		children, stat, err = s.Children(path)
+		return err
+	})
+	return children, stat, err
+}
+
+func (f *FailoverSession) ChildrenW(path string) (children []string, stat *zookeeper.Stat, watch <-chan zookeeper.Event, err error) {
+	err = f.read(func(s Session) error {
+		children, stat, watch, err = s.ChildrenW(path)
+		return err
+	})
+	return children, stat, watch, err
+}
+
+// ClientId returns the client ID of the primary.
+func (f *FailoverSession) ClientId() *zookeeper.ClientId {
+	return f.primary.ClientId()
+}
+
+// Close closes both sessions, returning the primary's error first.
+func (f *FailoverSession) Close() error {
+	err := f.primary.Close()
+	if standbyErr := f.standby.Close(); err == nil {
+		err = standbyErr
+	}
+	return err
+}
+
+func (f *FailoverSession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	return f.primary.Create(path, value, flags, aclv)
+}
+
+func (f *FailoverSession) Delete(path string, version int) error {
+	return f.primary.Delete(path, version)
+}
+
+func (f *FailoverSession) Exists(path string) (stat *zookeeper.Stat, err error) {
+	err = f.read(func(s Session) error {
+		stat, err = s.Exists(path)
+		return err
+	})
+	return stat, err
+}
+
+func (f *FailoverSession) ExistsW(path string) (stat *zookeeper.Stat, watch <-chan zookeeper.Event, err error) {
+	err = f.read(func(s Session) error {
+		stat, watch, err = s.ExistsW(path)
+		return err
+	})
+	return stat, watch, err
+}
+
+func (f *FailoverSession) Get(path string) (data string, stat *zookeeper.Stat, err error) {
+	err = f.read(func(s Session) error {
+		data, stat, err = s.Get(path)
+		return err
+	})
+	return data, stat, err
+}
+
+func (f *FailoverSession) GetW(path string) (data string, stat *zookeeper.Stat, watch <-chan zookeeper.Event, err error) {
+	err = f.read(func(s Session) error {
+		data, stat, watch, err = s.GetW(path)
+		return err
+	})
+	return data, stat, watch, err
+}
+
+func (f *FailoverSession) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	return f.primary.Set(path, value, version)
+}
+
+func (f *FailoverSession) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	return f.primary.RetryChange(path, flags, acl, changeFunc)
+}
+
+func (f *FailoverSession) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	return f.primary.SetACL(path, aclv, version)
+}
+
+// Subscribe subscribes to the primary's events, along with SessionDegraded
+// and SessionRestored as reads move to the standby and back.
+func (f *FailoverSession) Subscribe(subscription chan<- ZKSessionEvent) {
+	f.events.Subscribe(subscription)
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSession answers Get with its name, or err, and hands out its events.
+type stubSession struct {
+	Session
+	name   string
+	err    error
+	events chan<- ZKSessionEvent
+}
+
+func (s *stubSession) Get(path string) (string, *zookeeper.Stat, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	return s.name, nil, nil
+}
+
+func (s *stubSession) Subscribe(subscription chan<- ZKSessionEvent) {
+	s.events = subscription
+}
+
+func TestFailoverSessionShouldReadFromStandbyWhilePrimaryIsDown(t *testing.T) {
+	primary, standby := &stubSession{name: "primary"}, &stubSession{name: "standby"}
+	f := NewFailoverSession(primary, standby)
+	events := make(chan ZKSessionEvent, 4)
+	f.Subscribe(events)
+
+	data, _, _ := f.Get("/foo")
+	assert.Equal(t, "primary", data)
+
+	primary.events <- SessionDisconnected
+	assert.Equal(t, SessionDisconnected, <-events)
+	assert.Equal(t, SessionDegraded, <-events)
+	assert.True(t, f.Degraded())
+	data, _, _ = f.Get("/foo")
+	assert.Equal(t, "standby", data)
+
+	primary.events <- SessionReconnected
+	assert.Equal(t, SessionReconnected, <-events)
+	assert.Equal(t, SessionRestored, <-events)
+	assert.False(t, f.Degraded())
+	data, _, _ = f.Get("/foo")
+	assert.Equal(t, "primary", data)
+}
+
+func TestFailoverSessionShouldRetryLostReadsOnStandby(t *testing.T) {
+	primary, standby := &stubSession{name: "primary"}, &stubSession{name: "standby"}
+	f := NewFailoverSession(primary, standby)
+
+	primary.err = &zookeeper.Error{Op: "get", Code: zookeeper.ZCONNECTIONLOSS}
+	data, _, err := f.Get("/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "standby", data)
+
+	primary.err = &zookeeper.Error{Op: "get", Code: zookeeper.ZNONODE}
+	_, _, err = f.Get("/foo")
+	assert.True(t, zookeeper.IsError(err, zookeeper.ZNONODE))
+}
+
+func TestFailoverSessionShouldKeepReadsOnPrimaryWhileStandbyIsDown(t *testing.T) {
+	primary, standby := &stubSession{name: "primary"}, &stubSession{name: "standby"}
+	f := NewFailoverSession(primary, standby)
+	f.Subscribe(make(chan ZKSessionEvent, 4))
+
+	// The second event is taken only once the first has been handled.
+	standby.events <- SessionDisconnected
+	standby.events <- SessionDisconnected
+	primary.events <- SessionDisconnected
+	assert.Eventually(t, f.Degraded, time.Second, time.Millisecond)
+	data, _, _ := f.Get("/foo")
+	assert.Equal(t, "primary", data)
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns a deterministic hash of the structure and data of the
+// subtree rooted at path. Node paths are hashed relative to path, so the same
+// tree stored under different roots (or in different ensembles) produces the
+// same fingerprint.
+//
+// Nodes are visited depth first in lexical order and hashed as they're read,
+// so memory use is bounded by the depth and fan-out of the tree rather than
+// its total size.
+func (s *ZKSession) Fingerprint(path string) (string, error) {
+	hash := sha256.New()
+	stack := []string{path}
+	lenBuf := make([]byte, 8)
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		data, _, err := s.Get(node)
+		if err != nil {
+			return "", err
+		}
+
+		children, _, err := s.Children(node)
+		if err != nil {
+			return "", err
+		}
+
+		relative := strings.TrimPrefix(node, path)
+		binary.BigEndian.PutUint64(lenBuf, uint64(len(relative)))
+		hash.Write(lenBuf)
+		hash.Write([]byte(relative))
+		binary.BigEndian.PutUint64(lenBuf, uint64(len(data)))
+		hash.Write(lenBuf)
+		hash.Write([]byte(data))
+
+		parent := node
+		if parent == "/" {
+			parent = ""
+		}
+
+		// Push in reverse so children are popped in lexical order.
+		sort.Sort(sort.Reverse(sort.StringSlice(children)))
+		for _, child := range children {
+			stack = append(stack, parent+"/"+child)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyFingerprint reports whether the subtree rooted at path matches a
+// fingerprint previously returned by Fingerprint.
+func (s *ZKSession) VerifyFingerprint(path string, expected string) (bool, error) {
+	actual, err := s.Fingerprint(path)
+	if err != nil {
+		return false, err
+	}
+	return actual == expected, nil
+}
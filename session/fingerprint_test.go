@@ -0,0 +1,45 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintOfIdenticalSubtreesShouldMatch(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/a/foo", "/test/a/foo/bar", "/test/b", "/test/b/foo", "/test/b/foo/bar")
+
+		a, err := session.Fingerprint("/test/a")
+		if err != nil {
+			t.Error("Fingerprint error: ", err)
+		}
+
+		ok, err := session.VerifyFingerprint("/test/b", a)
+		if err != nil {
+			t.Error("VerifyFingerprint error: ", err)
+		}
+		assert.True(t, ok)
+	})
+}
+
+func TestFingerprintShouldChangeWithData(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/foo")
+
+		before, err := session.Fingerprint("/test")
+		if err != nil {
+			t.Error("Fingerprint error: ", err)
+		}
+
+		if _, err := session.Set("/test/foo", "spam", -1); err != nil {
+			t.Error("Set error: ", err)
+		}
+
+		after, err := session.Fingerprint("/test")
+		if err != nil {
+			t.Error("Fingerprint error: ", err)
+		}
+		assert.NotEqual(t, before, after)
+	})
+}
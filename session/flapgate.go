@@ -0,0 +1,88 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// maxCoolOffFactor caps how far the cool-off period grows when the gate
+// closes repeatedly.
+const maxCoolOffFactor = 8
+
+// flapGate counts disconnects and closes once more than maxFlaps of them
+// happen within window. Each closing that follows shortly after the previous
+// cool-off doubles the cool-off period.
+type flapGate struct {
+	maxFlaps int
+	window   time.Duration
+	coolOff  time.Duration
+
+	flaps     []time.Time
+	current   time.Duration
+	lastClose time.Time
+}
+
+func newFlapGate(maxFlaps int, window, coolOff time.Duration) *flapGate {
+	return &flapGate{maxFlaps: maxFlaps, window: window, coolOff: coolOff}
+}
+
+// record registers a disconnect at now and returns the cool-off period to
+// keep operations gated for, if the gate closed.
+func (g *flapGate) record(now time.Time) (time.Duration, bool) {
+	if g == nil {
+		return 0, false
+	}
+
+	cutoff := now.Add(-g.window)
+	recent := g.flaps[:0]
+	for _, flap := range g.flaps {
+		if flap.After(cutoff) {
+			recent = append(recent, flap)
+		}
+	}
+	g.flaps = append(recent, now)
+
+	if len(g.flaps) <= g.maxFlaps {
+		return 0, false
+	}
+
+	if g.current == 0 || now.Sub(g.lastClose) > g.current+g.window {
+		g.current = g.coolOff
+	} else if g.current < g.coolOff*maxCoolOffFactor {
+		g.current *= 2
+	}
+	g.lastClose = now
+	g.flaps = g.flaps[:0]
+	return g.current, true
+}
+
+// ErrSessionGated is returned by operations while they are gated after the
+// session's connection flapped; see WithFlapGate. It is an ErrSessionLost.
+var ErrSessionGated = NewError("operations are gated after the session's connection flapped", ErrSessionLost)
+
+// closeGate fails operations with ErrSessionGated until openGate, so that the
+// application backs off instead of acting on a connection that keeps coming
+// and going. Only operations are gated: the client keeps reconnecting as
+// usual, and the session, its ephemeral nodes and its watches survive.
+func (s *ZKSession) closeGate() {
+	atomic.StoreInt32(&s.gated, 1)
+}
+
+func (s *ZKSession) openGate() {
+	atomic.StoreInt32(&s.gated, 0)
+}
+
+// isGated reports whether operations are gated.
+func (s *ZKSession) isGated() bool {
+	return atomic.LoadInt32(&s.gated) == 1
+}
+
+func (s *ZKSession) closeConn() {
+	s.mu.Lock()
+	err := s.conn.Close()
+	s.mu.Unlock()
+	if err != nil {
+		s.log.Printf("gozk-recipes/session: error in closing existing zookeeper connection: %v", err)
+		s.reportError("closing connection", err, false)
+	}
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlapGateClosesAfterMaxFlaps(t *testing.T) {
+	g := newFlapGate(2, time.Minute, time.Second)
+	now := time.Now()
+
+	_, closed := g.record(now)
+	assert.False(t, closed)
+	_, closed = g.record(now.Add(time.Second))
+	assert.False(t, closed)
+
+	coolOff, closed := g.record(now.Add(2 * time.Second))
+	assert.True(t, closed)
+	assert.Equal(t, time.Second, coolOff)
+}
+
+func TestFlapGateForgetsFlapsOutsideWindow(t *testing.T) {
+	g := newFlapGate(2, time.Minute, time.Second)
+	now := time.Now()
+
+	g.record(now)
+	g.record(now.Add(time.Second))
+	_, closed := g.record(now.Add(2 * time.Minute))
+	assert.False(t, closed)
+}
+
+func TestFlapGateLengthensCoolOffWhenClosedRepeatedly(t *testing.T) {
+	g := newFlapGate(1, time.Minute, time.Second)
+	now := time.Now()
+
+	var coolOffs []time.Duration
+	for i := 0; i < 10; i++ {
+		g.record(now)
+		coolOff, closed := g.record(now.Add(time.Millisecond))
+		assert.True(t, closed)
+		coolOffs = append(coolOffs, coolOff)
+		now = now.Add(coolOff + time.Second)
+	}
+
+	assert.Equal(t, time.Second, coolOffs[0])
+	assert.Equal(t, 2*time.Second, coolOffs[1])
+	assert.Equal(t, 8*time.Second, coolOffs[9])
+}
+
+func TestNilFlapGateNeverCloses(t *testing.T) {
+	var g *flapGate
+	_, closed := g.record(time.Now())
+	assert.False(t, closed)
+}
+
+func TestGatedSessionShouldFailCallsUntilOpened(t *testing.T) {
+	s := &ZKSession{stats: newSessionStats(), log: &nullLogger{}}
+	s.closeGate()
+
+	called := false
+	call := func(Op) (Result, error) {
+		called = true
+		return Result{}, nil
+	}
+	_, err := s.do(Op{Name: "get", Path: "/foo"}, call)
+	assert.ErrorIs(t, err, ErrSessionGated)
+	assert.ErrorIs(t, err, ErrSessionLost)
+	assert.False(t, called)
+
+	s.openGate()
+	_, err = s.do(Op{Name: "get", Path: "/foo"}, call)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
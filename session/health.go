@@ -0,0 +1,137 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// DefaultProbeTimeout bounds how long server probing may delay a (re)connect.
+const DefaultProbeTimeout = 2 * time.Second
+
+// ServerProber checks the health of a single server, given as host:port, and
+// returns how long the check took. A non-nil error marks the server unhealthy.
+type ServerProber func(ctx context.Context, server string) (time.Duration, error)
+
+// TCPProber considers a server healthy if it accepts a TCP connection.
+func TCPProber() ServerProber {
+	return func(ctx context.Context, server string) (time.Duration, error) {
+		start := time.Now()
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", server)
+		if err != nil {
+			return 0, err
+		}
+		_ = conn.Close()
+		return time.Since(start), nil
+	}
+}
+
+// RuokProber sends the "ruok" four letter word and considers a server healthy
+// only if it answers "imok". Unlike TCPProber, it catches servers that accept
+// connections but can't serve requests. The server must have ruok in its
+// 4lw.commands.whitelist.
+func RuokProber() ServerProber {
+	return func(ctx context.Context, server string) (time.Duration, error) {
+		start := time.Now()
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", server)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+		if _, err := conn.Write([]byte("ruok")); err != nil {
+			return 0, err
+		}
+		reply := make([]byte, 4)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return 0, err
+		}
+		if string(reply) != "imok" {
+			return 0, fmt.Errorf("server %s answered %q to ruok", server, reply)
+		}
+		return time.Since(start), nil
+	}
+}
+
+// probeServers probes servers in parallel and returns why each unhealthy one
+// failed, by server. It returns nil if there is no prober.
+func probeServers(servers []string, prober ServerProber, timeout time.Duration) map[string]error {
+	if prober == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			_, errs[i] = prober(ctx, server)
+		}(i, server)
+	}
+	wg.Wait()
+
+	unhealthy := make(map[string]error)
+	for i, err := range errs {
+		if err != nil {
+			unhealthy[servers[i]] = err
+		}
+	}
+	return unhealthy
+}
+
+// logUnhealthy probes servers with the configured prober and logs the
+// unhealthy ones.
+func (so SessionOpts) logUnhealthy(servers []string) {
+	unhealthy := probeServers(servers, so.prober, so.probeTimeout)
+	if len(unhealthy) == 0 || so.logger == nil {
+		return
+	}
+	names := make([]string, 0, len(unhealthy))
+	for server := range unhealthy {
+		names = append(names, server)
+	}
+	sort.Strings(names)
+	for _, server := range names {
+		so.logger.Printf("gozk-recipes/session: server %s is unhealthy: %v", server, unhealthy[server])
+	}
+}
+
+// serverList returns the comma-separated servers to (re)dial, followed by the
+// namespace, logging those the configured prober finds unhealthy. Every
+// server is kept, since the client only ever connects to those it was given.
+func (so SessionOpts) serverList() string {
+	return so.hostList() + so.namespace
+}
+
+// hostList is serverList without the namespace, as SetServers takes it.
+func (so SessionOpts) hostList() string {
+	servers := so.roleServers()
+	so.logUnhealthy(servers)
+	return strings.Join(servers, ",")
+}
+
+// addAuth adds the configured credentials to conn.
+func (so SessionOpts) addAuth(conn *zookeeper.Conn) error {
+	for _, auth := range so.auth {
+		if err := conn.AddAuth(auth.Scheme, auth.Credentials); err != nil {
+			return fmt.Errorf("adding %s auth: %w", auth.Scheme, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeServersShouldReportUnhealthyServers(t *testing.T) {
+	unhealthy := errors.New("unhealthy")
+	prober := func(ctx context.Context, server string) (time.Duration, error) {
+		if server == "c:2181" {
+			return 0, unhealthy
+		}
+		return time.Millisecond, nil
+	}
+
+	failed := probeServers([]string{"a:2181", "b:2181", "c:2181"}, prober, time.Second)
+	assert.Equal(t, map[string]error{"c:2181": unhealthy}, failed)
+	assert.Nil(t, probeServers([]string{"a:2181"}, nil, time.Second))
+}
+
+func TestServerListShouldKeepUnhealthyServers(t *testing.T) {
+	prober := func(ctx context.Context, server string) (time.Duration, error) {
+		return 0, errors.New("unhealthy")
+	}
+	logger := &recordingLogger{}
+	so := WithServerProber(prober, time.Second)(WithZookeepers([]string{"a:2181", "b:2181"})(SessionOpts{logger: logger}))
+
+	assert.Equal(t, "a:2181,b:2181", so.serverList())
+	assert.Len(t, logger.lines, 2)
+}
+
+func TestRuokProber(t *testing.T) {
+	serve := func(reply string) string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			buf := make([]byte, 4)
+			if _, err := conn.Read(buf); err == nil && string(buf) == "ruok" {
+				conn.Write([]byte(reply))
+			}
+		}()
+		return l.Addr().String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := RuokProber()(ctx, serve("imok"))
+	assert.NoError(t, err)
+
+	_, err = RuokProber()(ctx, serve("nope"))
+	assert.Error(t, err)
+}
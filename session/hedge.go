@@ -0,0 +1,71 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type hedgeResult[T any] struct {
+	value T
+	err   error
+}
+
+// hedged runs read and, if the session hedges reads and read hasn't returned
+// within the hedging delay, runs it a second time. It returns the first
+// successful result, or the last error if both attempts fail.
+func hedged[T any](s *ZKSession, read func() (T, error)) (T, error) {
+	return hedgedAfter(s, s.opts.hedgeAfter, nil, read)
+}
+
+// hedgedAfter is hedged with the given delay, recording the latency of every
+// attempt that succeeds in window, if any, including those that complete
+// after another has been returned.
+func hedgedAfter[T any](s *ZKSession, delay time.Duration, window *latencyWindow, read func() (T, error)) (T, error) {
+	if window == nil && delay <= 0 {
+		return read()
+	}
+	timed := func() (T, error) {
+		start := time.Now()
+		value, err := read()
+		if err == nil && window != nil {
+			window.record(time.Since(start))
+		}
+		return value, err
+	}
+	if delay <= 0 {
+		return timed()
+	}
+
+	results := make(chan hedgeResult[T], 2)
+	attempt := func() {
+		value, err := timed()
+		results <- hedgeResult[T]{value, err}
+	}
+	go attempt()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedgeSent := false
+	pending := 1
+	for {
+		select {
+		case r := <-results:
+			pending--
+			// A quick failure isn't slowness, so it isn't hedged.
+			if r.err == nil || !hedgeSent || pending == 0 {
+				return r.value, r.err
+			}
+		case <-timer.C:
+			hedgeSent = true
+			pending++
+			atomic.AddUint64(&s.hedges, 1)
+			go attempt()
+		}
+	}
+}
+
+// HedgedReads returns the number of reads retried by WithHedgedReads because
+// the first attempt was slow.
+func (s *ZKSession) HedgedReads() uint64 {
+	return atomic.LoadUint64(&s.hedges)
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgedShouldReturnFasterAttempt(t *testing.T) {
+	s := &ZKSession{opts: WithHedgedReads(20 * time.Millisecond)(SessionOpts{})}
+	var calls int32
+	start := time.Now()
+	value, err := hedged(s, func() (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			time.Sleep(time.Second)
+		}
+		return int(n), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, uint64(1), s.HedgedReads())
+}
+
+func TestHedgedShouldNotHedgeFastReads(t *testing.T) {
+	s := &ZKSession{opts: WithHedgedReads(time.Second)(SessionOpts{})}
+	errBoom := errors.New("boom")
+
+	value, err := hedged(s, func() (int, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	_, err = hedged(s, func() (int, error) { return 0, errBoom })
+	assert.ErrorIs(t, err, errBoom)
+	assert.Equal(t, uint64(0), s.HedgedReads())
+}
+
+func TestHedgedShouldWaitForSecondAttemptAfterFailure(t *testing.T) {
+	s := &ZKSession{opts: WithHedgedReads(10 * time.Millisecond)(SessionOpts{})}
+	var calls int32
+	value, err := hedged(s, func() (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(50 * time.Millisecond)
+			return 0, errors.New("slow failure")
+		}
+		time.Sleep(100 * time.Millisecond)
+		return 2, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
@@ -0,0 +1,65 @@
+package session
+
+import "sync"
+
+// inflightLimiter bounds the number of concurrent operations. Waiters are
+// admitted in the order they arrived. A nil limiter admits everything.
+type inflightLimiter struct {
+	mu        sync.Mutex
+	available int
+	queue     []chan struct{}
+}
+
+func newInflightLimiter(n int) *inflightLimiter {
+	return &inflightLimiter{available: n}
+}
+
+func (l *inflightLimiter) acquire() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	if l.available > 0 && len(l.queue) == 0 {
+		l.available--
+		l.mu.Unlock()
+		return
+	}
+	admitted := make(chan struct{})
+	l.queue = append(l.queue, admitted)
+	l.mu.Unlock()
+
+	<-admitted
+}
+
+func (l *inflightLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) > 0 {
+		// Hand the slot directly to the next waiter.
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+		return
+	}
+	l.available++
+}
+
+func (l *inflightLimiter) depth() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.queue)
+}
+
+// InflightQueueDepth returns the number of operations waiting for a slot when
+// the session was created with WithMaxInflight.
+func (s *ZKSession) InflightQueueDepth() int {
+	return s.inflight.depth()
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInflightLimiterShouldAdmitInOrder(t *testing.T) {
+	l := newInflightLimiter(1)
+	l.acquire()
+
+	admitted := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			l.acquire()
+			admitted <- i
+		}(i)
+		for l.depth() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		l.release()
+		assert.Equal(t, i, <-admitted)
+	}
+	assert.Equal(t, 0, l.depth())
+}
+
+func TestNilInflightLimiterShouldNotBlock(t *testing.T) {
+	var l *inflightLimiter
+	l.acquire()
+	l.release()
+	assert.Equal(t, 0, l.depth())
+}
+
+func TestRetryChangeShouldLetItsChangeFunctionUseTheSession(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/foo", "/test/bar")
+
+		limited, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithMaxInflight(1))
+		if err != nil {
+			t.Fatal("Failed to connect to Zookeeper: ", err)
+		}
+		defer limited.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- limited.RetryChange("/test/foo", 0, defaultACLs, func(string, *zookeeper.Stat) (string, error) {
+				value, _, err := limited.Get("/test/bar")
+				return value, err
+			})
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("RetryChange deadlocked waiting for its own inflight slot")
+		}
+		AssertNodeValueEqual(t, session, "/test/foo", "/test/bar")
+	})
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// Op is a call a session makes to ZooKeeper, as seen by interceptors. Only
+// the fields the call takes are set.
+type Op struct {
+	// Name is the call: "acl", "add_auth", "children", "children_w",
+	// "create", "delete", "exists", "exists_w", "get", "get_w", "set",
+	// "retry_change" or "set_acl".
+	Name string
+	Path string
+	// Data is the value written by create and set, after encoding, or the
+	// certificate given to add_auth.
+	Data    string
+	Version int
+	Flags   int
+	ACL     []zookeeper.ACL
+	// Scheme is the authentication scheme given to add_auth.
+	Scheme string
+}
+
+// Result is what a call to ZooKeeper returned. Only the fields the call
+// returns are set: Data holds the value read by get and get_w, before
+// decoding, and the path created by create.
+type Result struct {
+	Data     string
+	Stat     *zookeeper.Stat
+	Children []string
+	ACL      []zookeeper.ACL
+	Watch    <-chan zookeeper.Event
+}
+
+// Handler makes a call to ZooKeeper.
+type Handler func(op Op) (Result, error)
+
+// Interceptor wraps the handler making the call op, returning one that may
+// change the call before passing it on to next, make other calls, change the
+// result, or fail the call without making it.
+type Interceptor func(op Op, next Handler) Handler
+
+// WithInterceptor wraps every call the session makes to ZooKeeper in
+// interceptor, for policies such as injecting credentials, rewriting paths or
+// enforcing latency budgets. Interceptors added first see each call first.
+// They see calls after the session has validated and encoded them, and
+// results before it decodes them; calls made in dry-run mode aren't sent to
+// ZooKeeper, and don't go through interceptors.
+func WithInterceptor(interceptor Interceptor) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.interceptors = append(so.interceptors, interceptor)
+		return so
+	}
+}
+
+// do makes the call op with call, wrapped in the session's interceptors.
+func (s *ZKSession) do(op Op, call Handler) (Result, error) {
+	if s.isGated() {
+		s.stats.record(op.Name, ErrSessionGated)
+		return Result{}, ErrSessionGated
+	}
+	h := call
+	for i := len(s.opts.interceptors) - 1; i >= 0; i-- {
+		h = s.opts.interceptors[i](op, h)
+	}
+	start := time.Now()
+	r, err := h(op)
+	s.stats.record(op.Name, err)
+	s.pressure.recordLatency(s, time.Since(start))
+	return r, err
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptorsShouldWrapCallsInOrder(t *testing.T) {
+	var seen []string
+	trace := func(name string) SessionOpt {
+		return WithInterceptor(func(op Op, next Handler) Handler {
+			return func(op Op) (Result, error) {
+				seen = append(seen, name+" "+op.Path)
+				return next(op)
+			}
+		})
+	}
+	rewrite := WithInterceptor(func(op Op, next Handler) Handler {
+		return func(op Op) (Result, error) {
+			op.Path = "/tenant" + op.Path
+			return next(op)
+		}
+	})
+	s := &ZKSession{opts: rewrite(trace("outer")(SessionOpts{}))}
+	s.opts = trace("inner")(s.opts)
+
+	r, err := s.do(Op{Name: "get", Path: "/foo"}, func(op Op) (Result, error) {
+		return Result{Data: "value of " + op.Path}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "value of /tenant/foo", r.Data)
+	assert.Equal(t, []string{"outer /foo", "inner /tenant/foo"}, seen)
+}
+
+func TestInterceptorShouldFailCallsWithoutMakingThem(t *testing.T) {
+	denied := errors.New("denied")
+	s := &ZKSession{stats: newSessionStats(), opts: WithInterceptor(func(op Op, next Handler) Handler {
+		if op.Name == "delete" {
+			return func(Op) (Result, error) { return Result{}, denied }
+		}
+		return next
+	})(SessionOpts{})}
+
+	called := false
+	_, err := s.do(Op{Name: "delete", Path: "/foo"}, func(Op) (Result, error) {
+		called = true
+		return Result{}, nil
+	})
+	assert.ErrorIs(t, err, denied)
+	assert.False(t, called)
+	assert.Equal(t, map[string]uint64{"other": 1}, s.Stats().Errors)
+}
@@ -0,0 +1,12 @@
+package session
+
+import (
+	"time"
+
+	"github.com/Shopify/gozk-recipes/retry"
+)
+
+// connectJitter returns a random delay up to the configured maximum.
+func (so SessionOpts) connectJitter() time.Duration {
+	return retry.Random(so.connectJitterMax)
+}
@@ -0,0 +1,19 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectJitterShouldStayWithinMax(t *testing.T) {
+	if d := (SessionOpts{}).connectJitter(); d != 0 {
+		t.Errorf("Expected no jitter by default, got %s", d)
+	}
+
+	opts := WithConnectJitter(10 * time.Millisecond)(SessionOpts{})
+	for i := 0; i < 100; i++ {
+		if d := opts.connectJitter(); d < 0 || d >= 10*time.Millisecond {
+			t.Fatalf("Jitter %s out of range", d)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package session
+
+import (
+	"fmt"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// Update reads the document at path into a T, calls mutate on it and writes
+// the result back, retrying from the read if the node was modified
+// concurrently. Documents are encoded with the session's codec, JSON unless
+// set with WithCodec. A missing or empty node is treated as the zero value of
+// T and created with default ACLs. Returning an error from mutate aborts the
+// update.
+//
+// mutate may be called more than once, so it must not have side effects
+// beyond changing the document.
+func Update[T any](s Session, path string, mutate func(*T) error) error {
+	return update(s, codecOf(s), path, mutate)
+}
+
+func update[T any](s Session, codec Codec, path string, mutate func(*T) error) error {
+	return s.RetryChange(path, 0, defaultACLOf(s), func(oldValue string, oldStat *zookeeper.Stat) (string, error) {
+		var doc T
+		if oldValue != "" {
+			if err := codec.Unmarshal([]byte(oldValue), &doc); err != nil {
+				return "", fmt.Errorf("decoding %q: %w", path, err)
+			}
+		}
+
+		if err := mutate(&doc); err != nil {
+			return "", err
+		}
+
+		data, err := codec.Marshal(doc)
+		if err != nil {
+			return "", fmt.Errorf("encoding %q: %w", path, err)
+		}
+		return string(data), nil
+	})
+}
+
+// UpdateJSON is like Update for untyped JSON objects. It always uses JSON,
+// regardless of the session's codec.
+func (s *ZKSession) UpdateJSON(path string, mutate func(doc map[string]interface{}) error) error {
+	return update(s, JSONCodec, path, func(doc *map[string]interface{}) error {
+		if *doc == nil {
+			*doc = make(map[string]interface{})
+		}
+		return mutate(*doc)
+	})
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Replicas int      `json:"replicas"`
+	Tags     []string `json:"tags"`
+}
+
+func TestUpdateShouldCreateMissingNode(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+
+		err := Update(session, "/test/config", func(c *testConfig) error {
+			c.Replicas = 3
+			return nil
+		})
+		if err != nil {
+			t.Error("Update error: ", err)
+		}
+
+		AssertNodeValueEqual(t, session, "/test/config", `{"replicas":3,"tags":null}`)
+	})
+}
+
+func TestUpdateJSONShouldPreserveOtherFields(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		if _, err := session.Set("/test", `{"a":1,"b":"two"}`, -1); err != nil {
+			t.Error("Set error: ", err)
+		}
+
+		err := session.UpdateJSON("/test", func(doc map[string]interface{}) error {
+			doc["a"] = doc["a"].(float64) + 1
+			return nil
+		})
+		if err != nil {
+			t.Error("UpdateJSON error: ", err)
+		}
+
+		data, _, err := session.Get("/test")
+		if err != nil {
+			t.Error("Get error: ", err)
+		}
+		assert.JSONEq(t, `{"a":2,"b":"two"}`, data)
+	})
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var errKeepaliveTimeout = NewError("keepalive ping timed out", ErrTimeout)
+
+// touch records that an operation was made on the session.
+func (s *ZKSession) touch() {
+	atomic.StoreInt64(&s.activity, time.Now().UnixNano())
+}
+
+func (s *ZKSession) lastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.activity))
+}
+
+// keepaliveLoop pings the server whenever the session has been idle for the
+// period configured with WithKeepalive, until the session terminates.
+func (s *ZKSession) keepaliveLoop() {
+	idle := s.opts.keepalive
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-s.managed:
+			return
+		}
+
+		if wait := time.Until(s.lastActivity().Add(idle)); wait > 0 {
+			timer.Reset(wait)
+			continue
+		}
+
+		// A failed ping is only reported: the client detects dead
+		// connections itself, and reconnecting would risk the session over
+		// what may be a short blip.
+		timeout := s.operationTimeout(idle)
+		if err := s.ping(timeout); err != nil {
+			if err == errKeepaliveTimeout {
+				// The ping's own latency is only recorded once it returns.
+				s.pressure.recordLatency(s, timeout)
+			}
+			s.log.Printf("gozk-recipes/session: keepalive ping failed: %v", err)
+			s.reportError("keepalive ping", err, false)
+		}
+		timer.Reset(idle)
+	}
+}
+
+// ping makes a cheap request, failing if it errors or takes longer than
+// timeout.
+func (s *ZKSession) ping(timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Exists("/")
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errKeepaliveTimeout
+	}
+}
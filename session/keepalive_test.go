@@ -0,0 +1,21 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepaliveShouldPingIdleSession(t *testing.T) {
+	s, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithKeepalive(100*time.Millisecond))
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	start := s.lastActivity()
+	assert.Eventually(t, func() bool { return s.lastActivity().After(start) }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,33 @@
+package session
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+const (
+	labelSession   = "gozk-recipes.session"
+	labelComponent = "gozk-recipes.component"
+)
+
+// Name returns the session's name, as set with WithName. It defaults to the
+// comma-separated server list.
+func (s *ZKSession) Name() string {
+	return s.opts.name
+}
+
+// Go runs f in a new goroutine carrying pprof labels that identify the
+// session and the library component it belongs to, so goroutine dumps and
+// profiles can be attributed. Sessions without a Name, such as wrappers, are
+// labelled "unknown".
+func Go(s Session, component string, f func()) {
+	name := "unknown"
+	if named, ok := s.(interface{ Name() string }); ok {
+		name = named.Name()
+	}
+
+	labels := pprof.Labels(labelSession, name, labelComponent, component)
+	go pprof.Do(context.Background(), labels, func(context.Context) {
+		f()
+	})
+}
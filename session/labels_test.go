@@ -0,0 +1,29 @@
+package session
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoShouldLabelGoroutine(t *testing.T) {
+	s := &ZKSession{opts: SessionOpts{name: "primary"}}
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	Go(s, "cache", func() {
+		close(running)
+		<-release
+	})
+	<-running
+
+	var dump bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&dump, 1); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, dump.String(), `"gozk-recipes.component":"cache"`)
+	assert.Contains(t, dump.String(), `"gozk-recipes.session":"primary"`)
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/retry"
+)
+
+// lifetime returns how long the current session may live before it is
+// recycled: the maximum set with WithMaxSessionLifetime, less up to a tenth
+// so that processes started together don't all recycle at once.
+func (so SessionOpts) lifetime() time.Duration {
+	if so.maxLifetime <= 0 {
+		return 0
+	}
+	return so.maxLifetime - retry.Random(so.maxLifetime/10+1)
+}
+
+// recycleRetry returns how long to wait before retrying a failed recycle.
+func (so SessionOpts) recycleRetry() time.Duration {
+	retry := so.maxLifetime / 10
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return retry
+}
+
+// recycle replaces the session with a newly established one, closing the old
+// session only once the new one is connected. ZooKeeper can't hand ephemeral
+// nodes over to another session, so this is a forced expiry: closing the old
+// session deletes its ephemeral nodes and fires its watches right away, and
+// locks, leadership and registrations held through them are lost until
+// recipes recreate them on the new session, which the caller makes them do as
+// after an expiry. Subscribers get SessionDisconnected before the old session
+// is closed, so they stop relying on its nodes before other clients can take
+// them over. If the new session can't be established the old one is kept.
+func (s *ZKSession) recycle() error {
+	conn, events, err := s.dialSession()
+	if err != nil {
+		return err
+	}
+	s.notifySubscribers(SessionDisconnected)
+	s.log.Printf("gozk-recipes/session.SessionDisconnected: recycling session, its ephemeral nodes will be deleted")
+	old := s.replaceConn(conn, events)
+	if err := old.Close(); err != nil {
+		s.log.Printf("gozk-recipes/session: error in closing recycled zookeeper connection: %v", err)
+		s.reportError("closing connection", err, false)
+	}
+	s.log.Printf("gozk-recipes/session: session recycled, now connected to %s", conn.ConnectedServer())
+	return nil
+}
+
+// dialSession establishes a new session, rather than redialing the current
+// one, and waits until it is connected and authenticated.
+func (s *ZKSession) dialSession() (*zookeeper.Conn, <-chan zookeeper.Event, error) {
+	conn, events, err := dial(s.opts.serverList(), s.opts.sessionTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := waitForConnection(events, s.opts.connectTimeout); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if err := s.opts.addAuth(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	conn.SetServersResolutionDelay(s.opts.dnsRefresh)
+	return conn, events, nil
+}
+
+// replaceConn makes conn, a connection to a new session, the current one and
+// starts a new epoch. It returns the previous connection.
+func (s *ZKSession) replaceConn(conn *zookeeper.Conn, events <-chan zookeeper.Event) *zookeeper.Conn {
+	s.mu.Lock()
+	old := s.conn
+	s.conn = conn
+	s.events = events
+	s.opts = WithZookeeperClientID(conn.ClientId())(s.opts)
+	s.mu.Unlock()
+	s.noteSession(affinityToken(conn.ClientId()))
+	atomic.AddUint64(&s.epoch, 1)
+	s.recordRedial("new session")
+	return old
+}
+
+// reestablished runs the reconnect hooks and tells subscribers that the
+// session was replaced by a new one, as after an expiry.
+func (s *ZKSession) reestablished(reason string) error {
+	if err := s.runReconnectHooks(true); err != nil {
+		return err
+	}
+	s.notifySubscribers(SessionExpiredReconnected)
+	s.log.Printf("gozk-recipes/session.SessionExpiredReconnected: %s, ephemeral nodes of the old session purged", reason)
+	return nil
+}
@@ -0,0 +1,28 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifetimeShouldBeJitteredBelowMaximum(t *testing.T) {
+	so := WithMaxSessionLifetime(time.Hour)(SessionOpts{})
+	for i := 0; i < 100; i++ {
+		d := so.lifetime()
+		assert.LessOrEqual(t, d, time.Hour)
+		assert.GreaterOrEqual(t, d, 54*time.Minute)
+	}
+	assert.Equal(t, 6*time.Minute, so.recycleRetry())
+
+	assert.Equal(t, time.Duration(0), SessionOpts{}.lifetime())
+	assert.Equal(t, time.Second, WithMaxSessionLifetime(5*time.Second)(SessionOpts{}).recycleRetry())
+}
+
+func TestMaxSessionLifetimeShouldBeValidated(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 10 * time.Second, connectTimeout: time.Second}
+	assert.NoError(t, WithMaxSessionLifetime(time.Hour)(base).Validate())
+	assert.ErrorIs(t, WithMaxSessionLifetime(-time.Hour)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithMaxSessionLifetime(time.Second)(base).Validate(), ErrInvalidOptions)
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrManagerClosed is returned when creating or adding sessions and
+// components through a Manager after its Close.
+var ErrManagerClosed = errors.New("session manager closed")
+
+// Manager owns the lifecycle of a service's sessions and of the recipes,
+// caches and other components built on them, so that shutting the service
+// down closes all of them, in dependency order and within a deadline, and no
+// connection is left open.
+//
+// Components are closed before the sessions, most recently added first, so
+// a component built on others is closed before them, and sessions are then
+// closed most recently added first too. Recipes registered with a session
+// through RegisterCloser are also closed by the session's Close, so only
+// components that aren't need adding.
+type Manager struct {
+	mu         sync.Mutex
+	sessions   []Session
+	components []io.Closer
+	closed     bool
+}
+
+// NewManager returns an empty Manager.
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// NewSession creates a session with opts, as NewSessionWithOpts does, owned
+// by the Manager.
+func (m *Manager) NewSession(opts ...SessionOpt) (*ZKSession, error) {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return nil, ErrManagerClosed
+	}
+
+	s, err := NewSessionWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.AddSession(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddSession makes the Manager own s, closing it after the components.
+func (m *Manager) AddSession(s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrManagerClosed
+	}
+	m.sessions = append(m.sessions, s)
+	return nil
+}
+
+// Add makes the Manager own c, such as a cache or a recipe, closing it
+// before the components added before it and before the sessions.
+func (m *Manager) Add(c io.Closer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrManagerClosed
+	}
+	m.components = append(m.components, c)
+	return nil
+}
+
+// Close closes the components, then the sessions, one at a time in reverse
+// order of addition. Once ctx is done, the rest are still closed, without
+// waiting for them, so their connections go away even when a component
+// hangs. It returns the errors of the closers that failed or didn't return
+// in time. Closing the Manager again has no effect.
+func (m *Manager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	closers := make([]io.Closer, 0, len(m.components)+len(m.sessions))
+	for i := len(m.components) - 1; i >= 0; i-- {
+		closers = append(closers, m.components[i])
+	}
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		closers = append(closers, m.sessions[i])
+	}
+	m.components, m.sessions = nil, nil
+	m.mu.Unlock()
+
+	return closeInOrder(ctx, closers)
+}
+
+// CloseAll closes every session in the registry, that is created with
+// WithRegistry, along with the recipes registered with them, within ctx's
+// deadline like Manager.Close.
+func CloseAll(ctx context.Context) error {
+	sessions := Sessions()
+	closers := make([]io.Closer, len(sessions))
+	for i, s := range sessions {
+		closers[i] = s
+	}
+	return closeInOrder(ctx, closers)
+}
+
+// closeInOrder closes closers one at a time, waiting for each until ctx is
+// done. The error wraps ctx's if a closer didn't return in time.
+func closeInOrder(ctx context.Context, closers []io.Closer) error {
+	var problems []string
+	var expired error
+	for _, c := range closers {
+		done := make(chan error, 1)
+		go func(c io.Closer) { done <- c.Close() }(c)
+
+		select {
+		case err := <-done:
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("closing %T: %v", c, err))
+			}
+		case <-ctx.Done():
+			expired = ctx.Err()
+			problems = append(problems, fmt.Sprintf("closing %T: %v", c, expired))
+		}
+	}
+	if expired != nil {
+		return fmt.Errorf("shutting down: %s: %w", strings.Join(problems, "; "), expired)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("shutting down: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
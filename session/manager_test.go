@@ -0,0 +1,59 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closeRecorder appends its name to closed when closed, after waiting for
+// block if set.
+type closeRecorder struct {
+	Session
+	name   string
+	closed *[]string
+	block  chan struct{}
+	err    error
+}
+
+func (c *closeRecorder) Close() error {
+	if c.block != nil {
+		<-c.block
+	}
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}
+
+func TestManagerShouldCloseInDependencyOrder(t *testing.T) {
+	var closed []string
+	m := NewManager()
+	assert.NoError(t, m.AddSession(&closeRecorder{name: "session a", closed: &closed}))
+	assert.NoError(t, m.Add(&closeRecorder{name: "cache", closed: &closed}))
+	assert.NoError(t, m.AddSession(&closeRecorder{name: "session b", closed: &closed}))
+	assert.NoError(t, m.Add(&closeRecorder{name: "lock", closed: &closed, err: errors.New("spam")}))
+
+	err := m.Close(context.Background())
+	assert.Equal(t, "shutting down: closing *session.closeRecorder: spam", err.Error())
+	assert.Equal(t, []string{"lock", "cache", "session b", "session a"}, closed)
+
+	assert.NoError(t, m.Close(context.Background()))
+	assert.Equal(t, ErrManagerClosed, m.Add(&closeRecorder{closed: &closed}))
+}
+
+func TestManagerShouldNotWaitPastDeadline(t *testing.T) {
+	var closed []string
+	block := make(chan struct{})
+	defer close(block)
+	m := NewManager()
+	assert.NoError(t, m.Add(&closeRecorder{name: "stuck", closed: &closed, block: block}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := m.Close(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), time.Second)
+}
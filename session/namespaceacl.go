@@ -0,0 +1,60 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// TenantPlaceholder is replaced in the ids of the ACL template given to
+// WithNamespaceCreation by the tenant owning the namespace.
+const TenantPlaceholder = "{tenant}"
+
+// expandACL returns template with TenantPlaceholder in each id replaced by
+// tenant.
+func expandACL(template []zookeeper.ACL, tenant string) []zookeeper.ACL {
+	acl := make([]zookeeper.ACL, len(template))
+	for i, entry := range template {
+		entry.Id = strings.ReplaceAll(entry.Id, TenantPlaceholder, tenant)
+		acl[i] = entry
+	}
+	return acl
+}
+
+// ensureNamespace creates the namespace node, and any missing parents, over a
+// connection outside the namespace. The namespace node gets the configured
+// ACL template; parents get the default ACL. A namespace node that already
+// exists is left as it is.
+func (so SessionOpts) ensureNamespace() error {
+	so.logUnhealthy(so.servers)
+	servers := strings.Join(so.servers, ",")
+	conn, events, err := dial(servers, so.sessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := waitForConnection(events, so.connectTimeout); err != nil {
+		return err
+	}
+	if err := so.addAuth(conn); err != nil {
+		return err
+	}
+
+	parentACL := defaultACLs
+	if so.defaultACL != nil {
+		parentACL = so.defaultACL
+	}
+	parts := strings.Split(strings.TrimPrefix(so.namespace, "/"), "/")
+	for i := range parts {
+		path := "/" + strings.Join(parts[:i+1], "/")
+		acl := parentACL
+		if i == len(parts)-1 {
+			acl = expandACL(so.namespaceACL, so.tenant)
+		}
+		if _, err := conn.Create(path, "", 0, acl); err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return fmt.Errorf("creating namespace %s: %w", so.namespace, err)
+		}
+	}
+	return nil
+}
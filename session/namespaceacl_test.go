@@ -0,0 +1,63 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+var tenantTemplate = []zookeeper.ACL{
+	{Scheme: "world", Id: "anyone", Perms: zookeeper.PERM_READ},
+	{Scheme: "digest", Id: TenantPlaceholder + ":x0n8YgfRHWLrGmbNoWzT8v8g8MI=", Perms: zookeeper.PERM_ALL},
+}
+
+func TestExpandACLShouldSubstituteTenant(t *testing.T) {
+	acl := expandACL(tenantTemplate, "acme")
+	assert.Equal(t, "anyone", acl[0].Id)
+	assert.Equal(t, "acme:x0n8YgfRHWLrGmbNoWzT8v8g8MI=", acl[1].Id)
+	assert.Equal(t, TenantPlaceholder+":x0n8YgfRHWLrGmbNoWzT8v8g8MI=", tenantTemplate[1].Id)
+}
+
+func TestNamespaceCreationShouldBeValidated(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: time.Second, connectTimeout: time.Second}
+	assert.NoError(t, WithNamespaceCreation("acme", tenantTemplate)(WithNamespace("/tenants/acme")(base)).Validate())
+	assert.ErrorIs(t, WithNamespaceCreation("acme", tenantTemplate)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithNamespaceCreation("", tenantTemplate)(WithNamespace("/tenants/acme")(base)).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithNamespaceCreation("acme", nil)(WithNamespace("/tenants/acme")(base)).Validate(), ErrInvalidOptions)
+}
+
+func TestNamespaceCreationShouldApplyTemplate(t *testing.T) {
+	servers := strings.Split(zkenv.GetZooKeepers(t), ",")
+	root, err := NewSessionWithOpts(WithZookeepers(servers))
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer root.Close()
+	root.DeleteRecursive("/test-tenants")
+	defer root.DeleteRecursive("/test-tenants")
+
+	s, err := NewSessionWithOpts(
+		WithZookeepers(servers),
+		WithNamespace("/test-tenants/acme"),
+		WithNamespaceCreation("acme", []zookeeper.ACL{
+			{Scheme: "world", Id: "anyone", Perms: zookeeper.PERM_ALL},
+			{Scheme: "digest", Id: TenantPlaceholder + ":x0n8YgfRHWLrGmbNoWzT8v8g8MI=", Perms: zookeeper.PERM_ADMIN},
+		}),
+	)
+	if err != nil {
+		t.Fatal("Failed to create namespace: ", err)
+	}
+	defer s.Close()
+
+	acl, _, err := root.ACL("/test-tenants/acme")
+	assert.NoError(t, err)
+	assert.Len(t, acl, 2)
+	assert.Equal(t, "acme:x0n8YgfRHWLrGmbNoWzT8v8g8MI=", acl[1].Id)
+
+	_, err = s.Create("/job", "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// operationPrefix starts every operation ID of this process, telling apart
+// the IDs of processes logging to the same place.
+var operationPrefix = fmt.Sprintf("%06x", rand.New(rand.NewSource(time.Now().UnixNano())).Int31n(1<<24))
+
+var operationSeq uint64
+
+// Operation is a logical recipe operation spanning several ZooKeeper calls,
+// and possibly several goroutines, such as a lock acquisition or a cache's
+// initial sync. Its ID correlates the OperationEvents published and the log
+// lines written for it.
+type Operation struct {
+	ID     string
+	Recipe string
+	Name   string
+	Detail string
+	Start  time.Time
+
+	session *ZKSession
+}
+
+// OperationEvent reports the progress of an Operation: its start, each step
+// and its end, when Done is set. Elapsed is the time since the operation
+// started, so subscribers can derive latency metrics.
+type OperationEvent struct {
+	ID      string
+	Recipe  string
+	Name    string
+	Detail  string
+	Step    string
+	Done    bool
+	Err     error
+	Elapsed time.Duration
+	Time    time.Time
+}
+
+// SubscribeOperations delivers an OperationEvent to subscription as recipes
+// start, progress through and end operations on the session. Like Subscribe,
+// delivery is in order and blocks the recipe until subscription receives the
+// event.
+func (s *ZKSession) SubscribeOperations(subscription chan<- OperationEvent) {
+	s.operationEvents.Subscribe(subscription)
+}
+
+// StartOperation starts an operation of recipe on s, assigning it a new ID.
+// name is what the recipe is doing, for example "acquire", and detail
+// describes the recipe instance, for example the path of a lock. Events are
+// only published on ZKSession, but every operation gets an ID.
+func StartOperation(s Session, recipe, name, detail string) *Operation {
+	op := &Operation{
+		ID:     fmt.Sprintf("%s-%d", operationPrefix, atomic.AddUint64(&operationSeq, 1)),
+		Recipe: recipe,
+		Name:   name,
+		Detail: detail,
+		Start:  time.Now(),
+	}
+	op.session, _ = s.(*ZKSession)
+	op.publish("", false, nil)
+	return op
+}
+
+// Step records that the operation reached step, for example "waiting for
+// lock-0000000041".
+func (op *Operation) Step(step string) {
+	op.publish(step, false, nil)
+}
+
+// End records that the operation finished, failing with err if it isn't nil.
+// Failures are logged with the operation's ID.
+func (op *Operation) End(err error) {
+	if err != nil {
+		op.Logf("%s %s failed after %s: %v", op.Name, op.Detail, time.Since(op.Start).Round(time.Millisecond), err)
+	}
+	op.publish("", true, err)
+}
+
+// Logf logs through the session's logger, tagging the line with the
+// recipe and the operation's ID.
+func (op *Operation) Logf(format string, args ...interface{}) {
+	if op.session == nil {
+		return
+	}
+	op.session.log.Printf("gozk-recipes/%s [op %s]: %s", op.Recipe, op.ID, fmt.Sprintf(format, args...))
+}
+
+func (op *Operation) publish(step string, done bool, err error) {
+	if op.session == nil {
+		return
+	}
+	now := time.Now()
+	event := OperationEvent{
+		ID:      op.ID,
+		Recipe:  op.Recipe,
+		Name:    op.Name,
+		Detail:  op.Detail,
+		Step:    step,
+		Done:    done,
+		Err:     err,
+		Elapsed: now.Sub(op.Start),
+		Time:    now,
+	}
+	op.session.recordOperation(event)
+	op.session.operationEvents.Publish(event)
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationShouldPublishCorrelatedEvents(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	events := make(chan OperationEvent, 3)
+	s.SubscribeOperations(events)
+
+	failed := errors.New("failed")
+	op := StartOperation(s, "lock", "acquire", "/locks/foo")
+	op.Step("waiting")
+	op.End(failed)
+
+	start, step, end := <-events, <-events, <-events
+	for _, event := range []OperationEvent{start, step, end} {
+		assert.Equal(t, op.ID, event.ID)
+		assert.Equal(t, "lock", event.Recipe)
+		assert.Equal(t, "/locks/foo", event.Detail)
+	}
+	assert.False(t, start.Done)
+	assert.Equal(t, "waiting", step.Step)
+	assert.True(t, end.Done)
+	assert.Equal(t, failed, end.Err)
+}
+
+func TestOperationShouldGetUniqueIDs(t *testing.T) {
+	var s Session = &stubSession{}
+	first, second := StartOperation(s, "cache", "sync", "/"), StartOperation(s, "cache", "sync", "/")
+	assert.NotEqual(t, first.ID, second.ID)
+	first.End(errors.New("not logged without a ZKSession"))
+}
@@ -6,14 +6,90 @@ import (
 	"time"
 
 	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/retry"
 )
 
 type SessionOpts struct {
-	recvTimeout time.Duration
-	logger      stdLogger
-	clientID    *zookeeper.ClientId
-	servers     []string
-	dnsRefresh  time.Duration
+	sessionTimeout time.Duration
+	connectTimeout time.Duration
+	logger         stdLogger
+	logWindow      time.Duration
+	clientID       *zookeeper.ClientId
+	servers        []string
+	namespace      string
+	auth           []AuthConfig
+	defaultACL     []zookeeper.ACL
+	name           string
+	err            error
+	dnsRefresh     time.Duration
+	flapGate       *flapGate
+
+	writeValidators   []WriteValidator
+	readValidators    []readValidator
+	codec             Codec
+	compressThreshold int
+	encryptPrefixes   []string
+	transformers      []prefixTransformer
+	childrenHedging   []prefixHedging
+	keys              KeyProvider
+	maxInflight       int
+	pathLimits        PathLimits
+
+	onReconnect []func(expired bool) error
+	watchdog    time.Duration
+	dryRun      bool
+	registered  bool
+
+	monotonicReads bool
+	protectedPaths []string
+	keepalive      time.Duration
+
+	connectJitterMax time.Duration
+	preferredServer  string
+	observers        []string
+	role             ServerRole
+	panicHandler     func(*PanicError)
+	shutdownTimeout  time.Duration
+	errors           chan<- error
+	strict           bool
+
+	prober       ServerProber
+	probeTimeout time.Duration
+
+	diagnostics        bool
+	diagnosticsTimeout time.Duration
+
+	callbackWorkers int
+	callbackTimeout time.Duration
+
+	maxLifetime    time.Duration
+	expirySimulate bool
+	noRedial       bool
+	hedgeAfter     time.Duration
+
+	createNamespace bool
+	tenant          string
+	namespaceACL    []zookeeper.ACL
+
+	clientInfo    ClientInfo
+	clientInfoDir string
+	ownershipTags bool
+
+	expvarName   string
+	interceptors []Interceptor
+
+	timelinePath     string
+	timelineMaxBytes int64
+	timelineBackups  int
+
+	backpressure       *BackpressureThresholds
+	backpressureNotify func(active bool, reason string)
+
+	rttInterval time.Duration
+	retryPolicy retry.Policy
+
+	orderOwnWrites    bool
+	suppressOwnWrites bool
 }
 
 // Create initializes a new session with the settings in s by connecting to the
@@ -23,15 +99,32 @@ func (s SessionOpts) Create() (*ZKSession, error) {
 	var events <-chan zookeeper.Event
 	var err error
 
-	if len(s.servers) == 0 {
-		return nil, fmt.Errorf("no zookeeper servers specified")
+	if err := s.Validate(); err != nil {
+		return nil, err
 	}
 
-	servers := strings.Join(s.servers, ",")
-	if s.clientID == nil {
-		conn, events, err = zookeeper.Dial(servers, s.recvTimeout)
-	} else {
-		conn, events, err = zookeeper.Redial(servers, s.recvTimeout, s.clientID)
+	if s.name == "" {
+		s.name = strings.Join(s.servers, ",")
+	}
+	s.clientInfo = s.clientInfo.withHost()
+	time.Sleep(s.connectJitter())
+	if s.createNamespace {
+		if err := s.ensureNamespace(); err != nil {
+			return nil, err
+		}
+	}
+	pinned := false
+	if preferred := s.preferredServers(); preferred != "" {
+		conn, events, err = s.dialPreferred(preferred)
+		pinned = err == nil
+	}
+	if !pinned {
+		servers := s.serverList()
+		if s.clientID == nil {
+			conn, events, err = dial(servers, s.sessionTimeout)
+		} else {
+			conn, events, err = redial(servers, s.sessionTimeout, s.clientID)
+		}
 	}
 
 	if err != nil {
@@ -41,23 +134,93 @@ func (s SessionOpts) Create() (*ZKSession, error) {
 	conn.SetServersResolutionDelay(s.dnsRefresh)
 
 	session := &ZKSession{
-		opts:          s,
-		conn:          conn,
-		events:        events,
-		subscriptions: make([]chan<- ZKSessionEvent, 0),
-		log:           s.logger,
+		opts:       s,
+		conn:       conn,
+		events:     events,
+		log:        s.logger,
+		pinned:     pinned,
+		flapGate:   s.flapGate,
+		reconnects: make(chan reconnectRequest),
+		managed:    make(chan struct{}),
+	}
+	if s.registered {
+		session.debug = newDebugState()
+	}
+	if s.dryRun {
+		session.journal = &dryRunJournal{}
+	}
+	if s.expvarName != "" {
+		session.stats = newSessionStats()
+	}
+	if s.clientInfo != (ClientInfo{}) {
+		session.log = &clientLogger{logger: session.log, prefix: s.clientInfo.String()}
+	}
+	if s.logWindow > 0 {
+		session.log = newRateLimitedLogger(session.log, s.logWindow)
+	}
+	if s.maxInflight > 0 {
+		session.inflight = newInflightLimiter(s.maxInflight)
+	}
+	if s.backpressure != nil {
+		session.pressure = newBackpressure(*s.backpressure, s.backpressureNotify)
 	}
+	if s.rttInterval > 0 {
+		session.rtt = &rttTracker{}
+	}
+	if s.orderOwnWrites {
+		session.ownWrites = newOwnWrites()
+	}
+	session.childrenHedges = newChildrenHedges(s.childrenHedging)
 
-	err = waitForConnection(events)
-	if err != nil {
+	if !pinned {
+		err = waitForConnection(events, s.connectTimeout)
+		if err != nil {
+			_ = session.conn.Close()
+			return nil, fmt.Errorf("waiting for initial connection: %w", err)
+		}
+	}
+	if err := s.addAuth(conn); err != nil {
 		_ = session.conn.Close()
-		return nil, fmt.Errorf("waiting for initial connection: %w", err)
+		return nil, err
+	}
+	session.affinity = affinityToken(conn.ClientId())
+	session.sessionToken = session.affinity
+	if s.timelinePath != "" {
+		if session.timeline, err = openTimeline(s.timelinePath, s.timelineMaxBytes, s.timelineBackups); err != nil {
+			_ = session.conn.Close()
+			return nil, err
+		}
+	}
+	if s.callbackWorkers > 0 {
+		session.callbacks = newCallbackPool(session, s.callbackWorkers, s.callbackTimeout)
+	}
+	if s.clientInfoDir != "" {
+		if err := session.writeClientInfoNode(); err != nil {
+			_ = session.conn.Close()
+			return nil, err
+		}
+		session.opts.onReconnect = append(session.opts.onReconnect, func(expired bool) error {
+			if !expired {
+				return nil
+			}
+			return session.writeClientInfoNode()
+		})
+	}
+	if s.registered {
+		register(session)
+	}
+	if s.expvarName != "" {
+		publishExpvar(s.expvarName, session)
 	}
 
 	return session, nil
 }
 
-func waitForConnection(events <-chan zookeeper.Event) error {
+// dial and redial connect sessions, replaced in tests to see what is dialed.
+var dial, redial = zookeeper.Dial, zookeeper.Redial
+
+func waitForConnection(events <-chan zookeeper.Event, timeout time.Duration) error {
+	deadline := time.After(timeout)
 	for {
 		select {
 		case event := <-events:
@@ -67,7 +230,7 @@ func waitForConnection(events <-chan zookeeper.Event) error {
 			case zookeeper.STATE_CONNECTED:
 				return nil
 			}
-		case <-time.After(5 * time.Second):
+		case <-deadline:
 			return ErrZKSessionNotConnected
 		}
 	}
@@ -76,9 +239,30 @@ func waitForConnection(events <-chan zookeeper.Event) error {
 type SessionOpt func(SessionOpts) SessionOpts
 
 // WithRecvTimeout creates a session with the given timeout.
+//
+// Deprecated: the value is only used as the session timeout; use
+// WithSessionTimeout instead.
 func WithRecvTimeout(timeout time.Duration) SessionOpt {
+	return WithSessionTimeout(timeout)
+}
+
+// WithSessionTimeout creates a session that negotiates the given session
+// timeout with the server. This governs how long the ensemble keeps the
+// session (and its ephemeral nodes) alive while the client is disconnected.
+// It must be at least a millisecond, as the client hands it over in whole
+// milliseconds.
+func WithSessionTimeout(timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.sessionTimeout = timeout
+		return so
+	}
+}
+
+// WithConnectTimeout creates a session that waits at most the given duration
+// for the initial connection to be established.
+func WithConnectTimeout(timeout time.Duration) SessionOpt {
 	return func(so SessionOpts) SessionOpts {
-		so.recvTimeout = timeout
+		so.connectTimeout = timeout
 		return so
 	}
 }
@@ -95,10 +279,70 @@ func WithLogger(logger stdLogger) SessionOpt {
 	}
 }
 
-// WithZookeepers creates a session with the given zookeeper hosts.
+// WithLogRateLimit suppresses repeats of the same log line within window, so
+// that a flapping connection doesn't flood the logs. The first occurrence is
+// logged immediately, and once the window is over a summary reports how many
+// repeats were suppressed. Lines are the same if they have the same format and
+// errors, whatever their other arguments.
+func WithLogRateLimit(window time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.logWindow = window
+		return so
+	}
+}
+
+// WithZookeepers creates a session with the given zookeeper hosts. Hosts with
+// an ":observer" suffix are marked as observers; see WithObservers.
 func WithZookeepers(zookeepers []string) SessionOpt {
 	return func(so SessionOpts) SessionOpts {
-		so.servers = zookeepers
+		var observers []string
+		so.servers, observers = splitObservers(zookeepers)
+		so.observers = append(so.observers, observers...)
+		return so
+	}
+}
+
+// WithNamespace roots the session at the given path, ZooKeeper's chroot: all
+// paths used with the session are relative to it. The node must exist, unless
+// WithNamespaceCreation is given.
+func WithNamespace(namespace string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.namespace = "/" + strings.Trim(namespace, "/")
+		return so
+	}
+}
+
+// WithAuth adds credentials to the session on connection, and again whenever
+// the session is redialed.
+func WithAuth(scheme, credentials string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.auth = append(so.auth, AuthConfig{Scheme: scheme, Credentials: credentials})
+		return so
+	}
+}
+
+// WithDigestAuth authenticates the session as the digest user on every
+// connect, and makes it create nodes accessible only to that user from its
+// helpers; see DefaultACL.
+func WithDigestAuth(user, password string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so = WithAuth("digest", user+":"+password)(so)
+		so.defaultACL = DigestACL(user, password, zookeeper.PERM_ALL)
+		return so
+	}
+}
+
+// WithPreferredServer makes the session connect to server, given as
+// host:port, if it can, instead of letting the client pick one of the
+// configured servers at random; for example to stay on an observer in the
+// same rack. If server can't be reached within half the connect timeout, the
+// configured servers are used. Once connected to server, losing the
+// connection makes the session fall back to the configured servers right
+// away, keeping the session; redials after that, such as after session
+// expiry, use the configured servers too.
+func WithPreferredServer(server string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.preferredServer = server
 		return so
 	}
 }
@@ -118,3 +362,380 @@ func WithDNSRefresh(duration time.Duration) SessionOpt {
 		return so
 	}
 }
+
+// WithFlapGate creates a session that, after more than maxFlaps disconnects
+// within window, gates operations for coolOff, emitting SessionGated:
+// operations fail with ErrSessionGated and reconnections aren't announced
+// until the cool-off is over. Only operations are gated; the client keeps
+// reconnecting as usual, so the session, its ephemeral nodes and its watches
+// are kept. The cool-off period doubles each time the gate closes again soon
+// after the previous cool-off.
+func WithFlapGate(maxFlaps int, window, coolOff time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.flapGate = newFlapGate(maxFlaps, window, coolOff)
+		return so
+	}
+}
+
+// WithWriteValidator creates a session that runs validator before every Create
+// and Set, rejecting the write if it returns an error. Validators run in the
+// order they were added.
+func WithWriteValidator(validator WriteValidator) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.writeValidators = append(so.writeValidators, validator)
+		return so
+	}
+}
+
+// WithReadValidator creates a session that runs validate over the data of
+// nodes whose path matches pattern, in the syntax of path.Match, as it is read
+// by Get and GetW, and so by the caches built on them. Data it rejects isn't
+// returned: the read fails with an InvalidDataError, which is also logged,
+// reported on the errors channel and counted in Stats, so that a malformed
+// value written by another system is stopped before it reaches the
+// application. Reads made to change a node, as by RetryChange, aren't
+// validated, so bad data can still be repaired.
+func WithReadValidator(pattern string, validate func(data []byte) error) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.readValidators = append(so.readValidators, readValidator{pattern: pattern, validate: validate})
+		return so
+	}
+}
+
+// WithPathLimits creates a session that refuses to create nodes beyond limits,
+// returning an error wrapping ErrPathLimit instead, to stop a runaway loop
+// from building a pathological tree.
+func WithPathLimits(limits PathLimits) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.pathLimits = limits
+		return so
+	}
+}
+
+// WithCompression creates a session that gzip compresses values larger than
+// threshold bytes before writing them. Compressed values are decompressed
+// transparently on read.
+func WithCompression(threshold int) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.compressThreshold = threshold
+		return so
+	}
+}
+
+// WithMaxInflight creates a session that allows at most n operations to be
+// outstanding at once. Further operations wait for a slot, first come first
+// served; InflightQueueDepth reports how many are waiting. An operation takes
+// a single slot, which RetryChange gives up while its change function runs,
+// so the function can use the session; interceptors must not, as they run
+// within the slot of the operation they wrap.
+func WithMaxInflight(n int) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.maxInflight = n
+		return so
+	}
+}
+
+// WithName names the session. The name labels the library's goroutines in
+// pprof profiles and goroutine dumps; it defaults to the server list.
+func WithName(name string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.name = name
+		return so
+	}
+}
+
+// WithServerProber probes the configured servers in parallel before every
+// dial, including redials after session expiry, and logs those found
+// unhealthy. The client is still given every server, as it only ever
+// reconnects to the servers it was given, and picks among them at random
+// regardless of their order. Probing gives up after timeout, or
+// DefaultProbeTimeout if it's zero.
+func WithServerProber(prober ServerProber, timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		if timeout <= 0 {
+			timeout = DefaultProbeTimeout
+		}
+		so.prober = prober
+		so.probeTimeout = timeout
+		return so
+	}
+}
+
+// WithEncryption creates a session that encrypts values written under any of
+// prefixes with AES-GCM, using keys from the given provider. Encryption
+// happens after compression, so it composes with WithCompression. Encrypted
+// values are recognized and decrypted on read regardless of their path.
+func WithEncryption(keys KeyProvider, prefixes ...string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.keys = keys
+		so.encryptPrefixes = append(so.encryptPrefixes, prefixes...)
+		return so
+	}
+}
+
+// WithOnReconnect runs hook synchronously from the session's event loop each
+// time the connection is re-established, before SessionReconnected or
+// SessionExpiredReconnected is broadcast to subscribers. expired reports
+// whether the previous session expired, so ephemeral nodes and watches must be
+// re-created. Errors are logged and don't prevent the broadcast. Hooks run in
+// the order they were added, and must not wait on session events.
+func WithOnReconnect(hook func(expired bool) error) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.onReconnect = append(so.onReconnect, hook)
+		return so
+	}
+}
+
+// WithCodec sets the codec used by GetAs, SetAs and Update. The default is
+// JSONCodec.
+func WithCodec(codec Codec) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.codec = codec
+		return so
+	}
+}
+
+// WithWatchdog supervises the goroutine handling connection events. If it
+// panics it is restarted; if it stops making progress for longer than
+// timeout, for example because a subscriber doesn't drain its channel or a
+// redial hangs, SessionFailed is delivered to subscribers so the session can
+// be replaced instead of silently becoming a zombie.
+func WithWatchdog(timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.watchdog = timeout
+		return so
+	}
+}
+
+// WithDryRun creates a session that doesn't modify the tree: Create, Set,
+// Delete, SetACL and RetryChange are validated, including against the current
+// state of the tree, and logged and recorded in Journal instead of being sent
+// to the server. Reads are unaffected, so a dry run sees none of its own
+// writes, except that Children lists the nodes it created, and Delete
+// removes them, so that recipes queueing with sequential nodes, such as
+// locks, can go ahead.
+func WithDryRun() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.dryRun = true
+		return so
+	}
+}
+
+// WithRegistry adds the session to the process-wide registry returned by
+// Sessions until it is closed, and tracks its recent events, outstanding
+// watches and attached recipes for DebugHandler. Tracking watches costs a
+// goroutine per outstanding watch.
+func WithRegistry() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.registered = true
+		return so
+	}
+}
+
+// WithConnectJitter delays the initial connection, and redials after session
+// expiry, by a random duration up to maxDelay, so that a fleet restarting at
+// once doesn't establish all its sessions and watches at the same moment.
+func WithConnectJitter(maxDelay time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.connectJitterMax = maxDelay
+		return so
+	}
+}
+
+// WithMonotonicReads checks, every time the session reconnects, that the
+// server it landed on has caught up with the latest state the session has
+// read, using the node LastZxid was observed on. ZooKeeper already refuses to
+// reconnect a session to a server behind it, so this matters for new
+// sessions, after an expiry, landing on a lagging follower. The reconnection
+// is then announced only once the server has caught up, so reads don't travel
+// back in time; a server still behind after about a second is kept, and the
+// problem logged.
+func WithMonotonicReads() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.monotonicReads = true
+		return so
+	}
+}
+
+// WithKeepalive pings the server with a cheap Exists call whenever the session
+// has been idle for the given period, keeping load balancer and NAT mappings
+// alive. A ping that fails or doesn't complete within the period, or within
+// the OperationTimeout with WithAdaptiveTimeouts, is logged and reported to
+// the error channel, and counts towards the session's stats and backpressure.
+// It doesn't force a reconnect: the client already pings the server at a third
+// of the session timeout and reconnects on its own when the connection dies.
+func WithKeepalive(idle time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.keepalive = idle
+		return so
+	}
+}
+
+// WithDeleteProtection refuses to delete nodes whose path matches one of
+// patterns, in the syntax of path.Match, with an error wrapping
+// ErrDeleteProtected. DeleteRecursive and DeleteSafe check the whole subtree
+// before deleting anything. Protected nodes can still be deleted with
+// ForceDelete, so a script has to ask for it explicitly.
+func WithDeleteProtection(patterns ...string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.protectedPaths = append(so.protectedPaths, patterns...)
+		return so
+	}
+}
+
+// WithPanicHandler calls handler with every panic recovered from a user
+// callback, such as a reconnect hook or a recipe listener, after logging it.
+// See Protect.
+func WithPanicHandler(handler func(*PanicError)) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.panicHandler = handler
+		return so
+	}
+}
+
+// WithShutdownTimeout sets how long Close waits for each component registered
+// with Register before moving on to the next.
+func WithShutdownTimeout(timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.shutdownTimeout = timeout
+		return so
+	}
+}
+
+// WithErrorChannel delivers the errors the session handles internally, such
+// as failing to close a replaced connection or to re-authenticate, to ch as
+// *InternalError values. Delivery never blocks the session: errors are
+// dropped while ch is full.
+func WithErrorChannel(ch chan<- error) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.errors = ch
+		return so
+	}
+}
+
+// WithStrictMode fails the session, delivering SessionFailed, when adding
+// credentials to a new connection fails, a reconnect hook returns an error or
+// panics, or the manage loop supervised by WithWatchdog panics, instead of
+// logging the error and carrying on.
+func WithStrictMode() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.strict = true
+		return so
+	}
+}
+
+// WithFailureDiagnostics checks every configured server before SessionFailed is
+// delivered: whether it accepts connections, answers ruok, and what srvr
+// reports as its mode and last zxid. The findings are logged and attached to
+// the SessionEvent, and tell SessionFailedPartition and
+// SessionFailedQuorumLoss apart. The sweep gives up after timeout, or DefaultProbeTimeout if
+// it's zero. The servers must have ruok and srvr in their
+// 4lw.commands.whitelist for the last two checks.
+func WithFailureDiagnostics(timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		if timeout == 0 {
+			timeout = DefaultProbeTimeout
+		}
+		so.diagnostics = true
+		so.diagnosticsTimeout = timeout
+		return so
+	}
+}
+
+// WithCallbackPool runs the user callbacks recipes hand to Dispatch, such as
+// SharedValue listeners and staleness callbacks, on workers goroutines instead
+// of the recipe's own, so that a slow callback doesn't delay re-arming watches
+// or other notifications. A callback still running after timeout, or
+// DefaultCallbackTimeout if it's zero, is left to finish on its own and no
+// longer counts against the pool; the error is logged and delivered to the
+// channel given to WithErrorChannel.
+func WithCallbackPool(workers int, timeout time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		if timeout == 0 {
+			timeout = DefaultCallbackTimeout
+		}
+		so.callbackWorkers = workers
+		so.callbackTimeout = timeout
+		return so
+	}
+}
+
+// WithMaxSessionLifetime recycles the session once it has lived for about
+// lifetime, for policies requiring sessions and credentials to be rotated
+// periodically. Each session lives between nine tenths of lifetime and
+// lifetime, so processes started together don't recycle together.
+//
+// Recycling is a forced expiry, as ZooKeeper can't hand ephemeral nodes over
+// to another session. A new session is established, with the credentials
+// configured at the time, and subscribers get SessionDisconnected before the
+// old one is closed. Closing it deletes its ephemeral nodes right away, so
+// locks, leadership and registrations are lost, and other clients may take
+// them, until recipes recreate them: the hooks registered with WithOnReconnect
+// run with expired set and subscribers get SessionExpiredReconnected, as after
+// an expiry. If the new session can't be established the old one is kept and
+// recycling is retried after a tenth of lifetime.
+func WithMaxSessionLifetime(lifetime time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.maxLifetime = lifetime
+		return so
+	}
+}
+
+// WithExpirySimulation enables SimulateExpiry, for tests of expiry handling.
+// It is meant for test sessions only.
+func WithExpirySimulation() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.expirySimulate = true
+		return so
+	}
+}
+
+// WithNoAutoRedial leaves recovery from an expiry to the application: when
+// the session expires, subscribers get SessionExpired and the session is
+// never re-established, so the application can, for example, exit and be
+// restarted by its supervisor. Reconnecting to the same session before it
+// expires is still automatic. It can't be combined with
+// WithMaxSessionLifetime, which establishes new sessions.
+func WithNoAutoRedial() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.noRedial = true
+		return so
+	}
+}
+
+// WithHedgedReads sends a second attempt of a Get, Exists or Children call
+// that hasn't returned after delay, and returns whichever attempt succeeds
+// first, cutting the tail latency of reads held up by a slow server, such as
+// one taking part in a leader election. Both attempts go through the
+// session's connection, and only reads, which are idempotent, are hedged;
+// watch-setting reads aren't, as they would set the watch twice. A read
+// failing before delay is returned right away. HedgedReads counts the
+// attempts sent, to tune delay, typically to around the p99 read latency.
+func WithHedgedReads(delay time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.hedgeAfter = delay
+		return so
+	}
+}
+
+// WithNamespaceCreation creates the node given to WithNamespace, and any
+// missing parents, before the session connects to it, so the session's root
+// is set up with the right permissions rather than fixed up later. The
+// namespace node is created with acl, in which TenantPlaceholder in the ids
+// is replaced by tenant, so one template serves every tenant's namespace:
+//
+//	session.WithNamespaceCreation("billing", []zookeeper.ACL{
+//		{Scheme: "sasl", Id: session.TenantPlaceholder, Perms: zookeeper.PERM_ALL},
+//		{Scheme: "world", Id: "anyone", Perms: zookeeper.PERM_READ},
+//	})
+//
+// Parents are created with the default ACL; see DefaultACL. An existing
+// namespace node is left as it is, whatever its ACL.
+func WithNamespaceCreation(tenant string, acl []zookeeper.ACL) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.createNamespace = true
+		so.tenant = tenant
+		so.namespaceACL = acl
+		return so
+	}
+}
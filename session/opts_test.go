@@ -0,0 +1,80 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDial replaces dial and redial for the duration of the test, recording
+// the session timeout they're given and failing with errDialed.
+func stubDial(t *testing.T) *time.Duration {
+	var dialed time.Duration
+	dial = func(servers string, timeout time.Duration) (*zookeeper.Conn, <-chan zookeeper.Event, error) {
+		dialed = timeout
+		return nil, nil, errDialed
+	}
+	redial = func(servers string, timeout time.Duration, clientID *zookeeper.ClientId) (*zookeeper.Conn, <-chan zookeeper.Event, error) {
+		dialed = timeout
+		return nil, nil, errDialed
+	}
+	t.Cleanup(func() { dial, redial = zookeeper.Dial, zookeeper.Redial })
+	return &dialed
+}
+
+var errDialed = errors.New("dialed")
+
+func TestTimeoutsShouldDefault(t *testing.T) {
+	so := defaultSessionOpts()
+	assert.Equal(t, DefaultSessionTimeout, so.sessionTimeout)
+	assert.Equal(t, DefaultConnectTimeout, so.connectTimeout)
+	assert.Equal(t, DefaultRecvTimeout, WithRecvTimeout(DefaultRecvTimeout)(SessionOpts{}).sessionTimeout)
+}
+
+func TestSessionTimeoutShouldBeValidated(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 10 * time.Second, connectTimeout: time.Second}
+	assert.NoError(t, WithSessionTimeout(time.Millisecond)(base).Validate())
+	assert.ErrorIs(t, WithSessionTimeout(0)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithSessionTimeout(-time.Second)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithSessionTimeout(500*time.Microsecond)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithRecvTimeout(500*time.Microsecond)(base).Validate(), ErrInvalidOptions)
+}
+
+func TestConnectTimeoutShouldBeValidated(t *testing.T) {
+	base := SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 10 * time.Second, connectTimeout: time.Second}
+	assert.NoError(t, WithConnectTimeout(time.Millisecond)(base).Validate())
+	assert.ErrorIs(t, WithConnectTimeout(0)(base).Validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, WithConnectTimeout(-time.Second)(base).Validate(), ErrInvalidOptions)
+}
+
+func TestSessionTimeoutShouldBePassedToDial(t *testing.T) {
+	dialed := stubDial(t)
+	servers := WithZookeepers([]string{"localhost:2181"})
+
+	_, err := NewSessionWithOpts(servers)
+	assert.ErrorIs(t, err, errDialed)
+	assert.Equal(t, DefaultSessionTimeout, *dialed)
+
+	_, err = NewSessionWithOpts(servers, WithSessionTimeout(1500*time.Millisecond), WithConnectTimeout(time.Second))
+	assert.ErrorIs(t, err, errDialed)
+	assert.Equal(t, 1500*time.Millisecond, *dialed)
+
+	_, err = NewSessionWithOpts(servers, WithSessionTimeout(time.Second), WithZookeeperClientID(&zookeeper.ClientId{}))
+	assert.ErrorIs(t, err, errDialed)
+	assert.Equal(t, time.Second, *dialed)
+
+	*dialed = 0
+	_, err = NewSessionWithOpts(servers, WithSessionTimeout(500*time.Microsecond))
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+	assert.Equal(t, time.Duration(0), *dialed, "Expected a too short session timeout not to be dialed")
+}
+
+func TestConnectTimeoutShouldBoundInitialConnection(t *testing.T) {
+	start := time.Now()
+	assert.ErrorIs(t, waitForConnection(make(chan zookeeper.Event), 50*time.Millisecond), ErrZKSessionNotConnected)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), DefaultConnectTimeout)
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// ownershipMagic prefixes the ownership annotation of values tagged with
+// TagOwnership. The annotation is JSON, ended by a newline.
+const ownershipMagic = "\x00gzo\x01"
+
+// Ownership attributes a recipe's node to the process that wrote it.
+type Ownership struct {
+	// Recipe is the recipe the node belongs to, such as "lock".
+	Recipe string `json:"recipe"`
+	// Service is the service given with WithClientInfo, if any.
+	Service string `json:"service,omitempty"`
+	Host    string `json:"host"`
+	PID     int    `json:"pid"`
+	// Session is the session's Name, and Epoch its epoch when writing.
+	Session string `json:"session,omitempty"`
+	Epoch   uint64 `json:"epoch"`
+}
+
+// WithOwnershipTags makes recipes annotate the data of the nodes they create
+// for their own use, such as lock nodes, registrations and queue items, with
+// an Ownership naming the service, host, process and session epoch writing
+// them, so operators can attribute any node in the tree to its creator. The
+// annotation is stripped on read by every session of this package, whether
+// it was created with WithOwnershipTags or not, so applications see their
+// data unchanged; other clients see it as a prefix of the data. Use
+// ParseOwnership or ZKSession.Owner to read it.
+func WithOwnershipTags() SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.ownershipTags = true
+		return so
+	}
+}
+
+// TagOwnership returns data annotated with the Ownership of the node a
+// recipe is writing for its own use, if s was created with
+// WithOwnershipTags, or data unchanged otherwise.
+func TagOwnership(s Session, recipe, data string) string {
+	tagger, ok := s.(interface {
+		ownership(recipe string) (Ownership, bool)
+	})
+	if !ok {
+		return data
+	}
+	owner, ok := tagger.ownership(recipe)
+	if !ok {
+		return data
+	}
+	tag, err := json.Marshal(owner)
+	if err != nil {
+		return data
+	}
+	return ownershipMagic + string(tag) + "\n" + data
+}
+
+func (s *ZKSession) ownership(recipe string) (Ownership, bool) {
+	if !s.opts.ownershipTags {
+		return Ownership{}, false
+	}
+	host := s.opts.clientInfo.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return Ownership{
+		Recipe:  recipe,
+		Service: s.opts.clientInfo.Service,
+		Host:    host,
+		PID:     os.Getpid(),
+		Session: s.Name(),
+		Epoch:   s.Epoch(),
+	}, true
+}
+
+func (v *View) ownership(recipe string) (Ownership, bool) {
+	tagger, ok := v.parent.(interface {
+		ownership(recipe string) (Ownership, bool)
+	})
+	if !ok {
+		return Ownership{}, false
+	}
+	return tagger.ownership(recipe)
+}
+
+// ParseOwnership splits data as stored in ZooKeeper into its ownership
+// annotation and the data written by the recipe. ok is false, and data is
+// returned unchanged, for data without an annotation. Data stored compressed
+// or encrypted by a session must be read through the session; see
+// ZKSession.Owner.
+func ParseOwnership(data string) (owner Ownership, rest string, ok bool) {
+	if !strings.HasPrefix(data, ownershipMagic) {
+		return Ownership{}, data, false
+	}
+	end := strings.IndexByte(data, '\n')
+	if end < 0 || json.Unmarshal([]byte(data[len(ownershipMagic):end]), &owner) != nil {
+		return Ownership{}, data, false
+	}
+	return owner, data[end+1:], true
+}
+
+// Owner returns the ownership annotation of the node at path; ok is false if
+// it has none.
+func (s *ZKSession) Owner(path string) (owner Ownership, ok bool, err error) {
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+
+	r, err := s.do(Op{Name: "get", Path: path}, func(op Op) (Result, error) {
+		value, stat, err := s.conn.Get(op.Path)
+		return Result{Data: value, Stat: stat}, err
+	})
+	if err != nil {
+		return Ownership{}, false, err
+	}
+	value, err := s.decodePayload(path, r.Data)
+	if err != nil {
+		return Ownership{}, false, err
+	}
+	owner, _, ok = ParseOwnership(value)
+	return owner, ok, nil
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagOwnershipShouldRoundTrip(t *testing.T) {
+	s := &ZKSession{opts: WithOwnershipTags()(WithClientInfo(ClientInfo{Service: "billing", Host: "web-1"})(SessionOpts{name: "main"})), epoch: 3}
+
+	tagged := TagOwnership(s, "lock", "holder")
+	owner, rest, ok := ParseOwnership(tagged)
+	assert.True(t, ok)
+	assert.Equal(t, "holder", rest)
+	assert.Equal(t, Ownership{Recipe: "lock", Service: "billing", Host: "web-1", PID: os.Getpid(), Session: "main", Epoch: 3}, owner)
+
+	decoded, err := s.decodeValue("/locks/0000000001", tagged)
+	assert.NoError(t, err)
+	assert.Equal(t, "holder", decoded)
+
+	_, rest, ok = ParseOwnership(TagOwnership(NewView(s, "/app"), "lock", "holder"))
+	assert.True(t, ok)
+	assert.Equal(t, "holder", rest)
+}
+
+func TestTagOwnershipShouldBeOptIn(t *testing.T) {
+	assert.Equal(t, "holder", TagOwnership(&ZKSession{}, "lock", "holder"))
+	assert.Equal(t, "holder", TagOwnership(&pathSession{}, "lock", "holder"))
+
+	for _, data := range []string{"plain", ownershipMagic + "{broken\nholder", ownershipMagic + "{}"} {
+		_, rest, ok := ParseOwnership(data)
+		assert.False(t, ok, data)
+		assert.Equal(t, data, rest)
+	}
+}
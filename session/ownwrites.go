@@ -0,0 +1,343 @@
+package session
+
+import (
+	gopath "path"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ownWriteMemory is how many watches FromOwnWrite remembers the origin of.
+const ownWriteMemory = 1024
+
+// WithOwnWriteOrdering orders the watch notifications triggered by the
+// session's own writes after the writes: a data, exists or children watch
+// that fires because of a Create, Set or Delete made through the session is
+// only delivered once that call has returned to its caller, and
+// FromOwnWrite reports it as caused by the session.
+//
+// With suppress, such notifications aren't delivered at all. The watch is
+// armed again instead, and fires for the next change, or right away if
+// another client changed the node in the meantime. Deletions are always
+// delivered, as the watch can't be armed again on a node that is gone.
+//
+// A write is known to have triggered a watch when it was the first change
+// to the node since the watch was armed, which for children watches takes
+// an extra read of the parent after creating or deleting a child. Changes
+// made with the session's RetryChange, or by other sessions, are never
+// counted as its own.
+func WithOwnWriteOrdering(suppress bool) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.orderOwnWrites = true
+		so.suppressOwnWrites = suppress
+		return so
+	}
+}
+
+// FromOwnWrite reports whether the event received from watch, a watch
+// channel returned by the session, was triggered by one of the session's own
+// writes. It is always false without WithOwnWriteOrdering, and for watches
+// that haven't fired yet. Only the most recent watches to fire are
+// remembered.
+func (s *ZKSession) FromOwnWrite(watch <-chan zookeeper.Event) bool {
+	return s.ownWrites.fromOwnWrite(watch)
+}
+
+// ownWatch is a watch armed through the session, with the state of its node
+// when it was armed.
+type ownWatch struct {
+	kind string
+	// exists is whether the node existed; version its data version, or its
+	// children version for children watches.
+	exists  bool
+	version int
+
+	// decided is set by the first of the session's writes to change the
+	// node since the watch was armed. If that write triggered the watch,
+	// expect is the type of the event it triggered and after the version
+	// it left the node at.
+	decided bool
+	expect  int
+	after   int
+}
+
+// ownWrites tracks the session's writes and watches to order and tag the
+// notifications the writes trigger. It is nil without WithOwnWriteOrdering.
+type ownWrites struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	// inflight counts the writes in flight by path, including the parents
+	// of created and deleted nodes.
+	inflight map[string]int
+	watches  map[string][]*ownWatch
+
+	// origins remembers which fired watches were triggered by the session,
+	// the oldest first in order.
+	origins map[<-chan zookeeper.Event]bool
+	order   []<-chan zookeeper.Event
+}
+
+func newOwnWrites() *ownWrites {
+	w := &ownWrites{
+		inflight: map[string]int{},
+		watches:  map[string][]*ownWatch{},
+		origins:  map[<-chan zookeeper.Event]bool{},
+	}
+	w.changed = sync.NewCond(&w.mu)
+	return w
+}
+
+// writePaths returns the paths whose watches a write op to path may
+// trigger: the node's, and its parent's for creations and deletions.
+func writePaths(path, op string) []string {
+	if op == "set" {
+		return []string{path}
+	}
+	return []string{path, gopath.Dir(path)}
+}
+
+// beginOwnWrite records a write op to path in flight, to be ended with
+// endOwnWrite.
+func (s *ZKSession) beginOwnWrite(path, op string) {
+	w := s.ownWrites
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range writePaths(path, op) {
+		w.inflight[p]++
+	}
+}
+
+// endOwnWrite records the outcome of a write begun with beginOwnWrite: op is
+// "create", "sequential" for sequential creations, "set" or "delete", and stat
+// the node's Stat after a set. The watches waiting for the write are
+// released.
+func (s *ZKSession) endOwnWrite(path, op string, stat *zookeeper.Stat, err error) {
+	w := s.ownWrites
+	if w == nil {
+		return
+	}
+	version, parentVersion := -1, -1
+	if stat != nil {
+		version = stat.Version()
+	}
+	if err == nil && op != "set" && w.undecided(gopath.Dir(path), "children") {
+		if parent, _ := s.conn.Exists(gopath.Dir(path)); parent != nil {
+			parentVersion = parent.CVersion()
+		}
+	}
+	w.end(path, op, version, parentVersion, err)
+}
+
+// end is endOwnWrite with the data version the write left the node at, and
+// the children version of its parent after a creation or deletion, or -1
+// when unknown.
+func (w *ownWrites) end(path, op string, version, parentVersion int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer w.changed.Broadcast()
+	for _, p := range writePaths(path, op) {
+		if w.inflight[p]--; w.inflight[p] <= 0 {
+			delete(w.inflight, p)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	for _, watch := range w.watches[path] {
+		// A sequential node's path isn't the one requested, so no watch
+		// on it was armed before.
+		if watch.decided || watch.kind == "children" || op == "sequential" {
+			continue
+		}
+		watch.decided = true
+		switch {
+		case op == "set" && watch.exists && version >= 0 && version == watch.version+1:
+			watch.expect, watch.after = zookeeper.EVENT_CHANGED, version
+		case op == "create" && !watch.exists:
+			watch.expect, watch.after = zookeeper.EVENT_CREATED, 0
+		case op == "delete" && watch.exists:
+			watch.expect = zookeeper.EVENT_DELETED
+		}
+	}
+	if op == "set" {
+		return
+	}
+	for _, watch := range w.watches[gopath.Dir(path)] {
+		if watch.decided || watch.kind != "children" {
+			continue
+		}
+		watch.decided = true
+		if parentVersion >= 0 && parentVersion == watch.version+1 {
+			watch.expect, watch.after = zookeeper.EVENT_CHILD, parentVersion
+		}
+	}
+}
+
+// undecided reports whether a watch of kind on path is yet to be decided.
+func (w *ownWrites) undecided(path, kind string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, watch := range w.watches[path] {
+		if watch.kind == kind && !watch.decided {
+			return true
+		}
+	}
+	return false
+}
+
+// armAt records a watch of kind on path armed when its node was at stat.
+func (w *ownWrites) armAt(path, kind string, stat *zookeeper.Stat) *ownWatch {
+	if stat == nil {
+		return w.arm(path, kind, false, 0)
+	}
+	if kind == "children" {
+		return w.arm(path, kind, true, stat.CVersion())
+	}
+	return w.arm(path, kind, true, stat.Version())
+}
+
+// arm records a watch of kind on path armed when its node existed, at
+// version, its data version or its children version for children watches.
+func (w *ownWrites) arm(path, kind string, exists bool, version int) *ownWatch {
+	watch := &ownWatch{kind: kind, exists: exists, version: version}
+	w.mu.Lock()
+	w.watches[path] = append(w.watches[path], watch)
+	w.mu.Unlock()
+	return watch
+}
+
+// fire waits for the writes in flight to path to return, then forgets
+// watch and reports whether event was triggered by one of them.
+func (w *ownWrites) fire(path string, watch *ownWatch, event zookeeper.Event) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if event.Ok() {
+		for w.inflight[path] > 0 {
+			w.changed.Wait()
+		}
+	}
+	watches := w.watches[path]
+	for i, armed := range watches {
+		if armed == watch {
+			watches = append(watches[:i:i], watches[i+1:]...)
+			break
+		}
+	}
+	if len(watches) == 0 {
+		delete(w.watches, path)
+	} else {
+		w.watches[path] = watches
+	}
+	return event.Ok() && watch.expect != 0 && watch.expect == event.Type
+}
+
+// remember records whether the event delivered on forwarded was triggered by
+// the session.
+func (w *ownWrites) remember(forwarded <-chan zookeeper.Event, own bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.order) >= ownWriteMemory {
+		delete(w.origins, w.order[0])
+		w.order = w.order[1:]
+	}
+	w.origins[forwarded] = own
+	w.order = append(w.order, forwarded)
+}
+
+func (w *ownWrites) fromOwnWrite(watch <-chan zookeeper.Event) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.origins[watch]
+}
+
+// trackOwnWatch forwards watch, armed on path when its node was at stat,
+// through a new channel once the writes in flight to path have returned. With
+// suppression, notifications triggered by the session's writes are swallowed
+// and the watch armed again.
+func (s *ZKSession) trackOwnWatch(path, kind string, stat *zookeeper.Stat, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if s.ownWrites == nil || watch == nil {
+		return watch
+	}
+	return s.forwardOwnWatch(path, kind, s.ownWrites.armAt(path, kind, stat), watch)
+}
+
+// forwardOwnWatch is trackOwnWatch for a watch already armed.
+func (s *ZKSession) forwardOwnWatch(path, kind string, armed *ownWatch, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	w := s.ownWrites
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		defer close(forwarded)
+		for {
+			event, ok := <-watch
+			if !ok {
+				w.fire(path, armed, zookeeper.Event{})
+				return
+			}
+			own := w.fire(path, armed, event)
+			if own && s.opts.suppressOwnWrites && event.Type != zookeeper.EVENT_DELETED {
+				next, nextArmed, missed := s.rearm(path, kind, armed.after)
+				if next != nil {
+					watch, armed = next, nextArmed
+					continue
+				}
+				event, own = missed, false
+			}
+			w.remember(forwarded, own)
+			forwarded <- event
+			return
+		}
+	}()
+	return forwarded
+}
+
+// rearm arms a watch of kind on path again after suppressing a notification
+// triggered by a write that left the node at version. If the node has
+// changed since, no watch is returned but the event the caller missed.
+func (s *ZKSession) rearm(path, kind string, version int) (<-chan zookeeper.Event, *ownWatch, zookeeper.Event) {
+	var stat *zookeeper.Stat
+	var watch <-chan zookeeper.Event
+	var err error
+	current := -1
+	missed := zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: path, State: zookeeper.STATE_CONNECTED}
+	switch kind {
+	case "data":
+		_, stat, watch, err = s.conn.GetW(path)
+		if stat != nil {
+			current = stat.Version()
+		}
+	case "exists":
+		stat, watch, err = s.conn.ExistsW(path)
+		if stat != nil {
+			current = stat.Version()
+		}
+	case "children":
+		_, stat, watch, err = s.conn.ChildrenW(path)
+		if stat != nil {
+			current = stat.CVersion()
+		}
+		missed.Type = zookeeper.EVENT_CHILD
+	}
+	if err == nil && stat != nil && current == version {
+		return s.countWatch(path, kind, watch), s.ownWrites.armAt(path, kind, stat), missed
+	}
+
+	if watch != nil {
+		go func() { <-watch }()
+	}
+	switch {
+	case zookeeper.IsError(err, zookeeper.ZNONODE) || (err == nil && stat == nil):
+		missed.Type = zookeeper.EVENT_DELETED
+	case err != nil:
+		// Without a watch the caller would never hear of the node again,
+		// so the notification is delivered after all.
+		s.log.Printf("gozk-recipes/session: couldn't watch %s again after its own write, delivering the notification: %v", path, err)
+	}
+	return nil, nil, missed
+}
@@ -0,0 +1,103 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnWriteShouldBeDeliveredAfterWriteReturns(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}, ownWrites: newOwnWrites()}
+	raw := make(chan zookeeper.Event, 1)
+	watch := s.forwardOwnWatch("/config", "data", s.ownWrites.arm("/config", "data", true, 0), raw)
+
+	s.beginOwnWrite("/config", "set")
+	raw <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: "/config", State: zookeeper.STATE_CONNECTED}
+	select {
+	case <-watch:
+		t.Fatal("Notification delivered before the write returned")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.ownWrites.end("/config", "set", 1, -1, nil)
+	event := <-watch
+	assert.Equal(t, zookeeper.EVENT_CHANGED, event.Type)
+	assert.True(t, s.FromOwnWrite(watch))
+}
+
+func TestOwnWriteShouldNotClaimOtherWritersChanges(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}, ownWrites: newOwnWrites()}
+	raw := make(chan zookeeper.Event, 1)
+	watch := s.forwardOwnWatch("/config", "data", s.ownWrites.arm("/config", "data", true, 3), raw)
+
+	// Another writer got in first, so the write left the node two versions
+	// on.
+	s.beginOwnWrite("/config", "set")
+	s.ownWrites.end("/config", "set", 5, -1, nil)
+	raw <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: "/config", State: zookeeper.STATE_CONNECTED}
+	<-watch
+	assert.False(t, s.FromOwnWrite(watch))
+
+	// A creation watched with Exists.
+	raw = make(chan zookeeper.Event, 1)
+	watch = s.trackWatch("/new", "exists", nil, raw)
+	s.beginOwnWrite("/new", "create")
+	s.ownWrites.end("/new", "create", -1, -1, nil)
+	raw <- zookeeper.Event{Type: zookeeper.EVENT_CREATED, Path: "/new", State: zookeeper.STATE_CONNECTED}
+	<-watch
+	assert.True(t, s.FromOwnWrite(watch))
+
+	// A child created under a children watch.
+	raw = make(chan zookeeper.Event, 1)
+	watch = s.forwardOwnWatch("/jobs", "children", s.ownWrites.arm("/jobs", "children", true, 7), raw)
+	s.beginOwnWrite("/jobs/job-1", "create")
+	s.ownWrites.end("/jobs/job-1", "create", -1, 8, nil)
+	raw <- zookeeper.Event{Type: zookeeper.EVENT_CHILD, Path: "/jobs", State: zookeeper.STATE_CONNECTED}
+	<-watch
+	assert.True(t, s.FromOwnWrite(watch))
+
+	assert.False(t, (&ZKSession{}).FromOwnWrite(watch))
+	assert.Empty(t, s.ownWrites.watches)
+	assert.Empty(t, s.ownWrites.inflight)
+}
+
+func TestOwnWriteShouldBeSuppressed(t *testing.T) {
+	s, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithOwnWriteOrdering(true))
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	other, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")))
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer other.Close()
+
+	node, err := s.Create("/gozk-test-own-writes", "", zookeeper.EPHEMERAL, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, watch, err := s.GetW(node)
+	assert.NoError(t, err)
+	_, err = s.Set(node, "own", -1)
+	assert.NoError(t, err)
+	select {
+	case event := <-watch:
+		t.Fatalf("Own write delivered: %v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_, err = other.Set(node, "other", -1)
+	assert.NoError(t, err)
+	select {
+	case event := <-watch:
+		assert.Equal(t, zookeeper.EVENT_CHANGED, event.Type)
+		assert.False(t, s.FromOwnWrite(watch))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Other writer's change wasn't delivered")
+	}
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a panic recovered from a user callback by Protect.
+type PanicError struct {
+	// Component is the recipe or part of the session that ran the callback.
+	Component string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s callback: %v", e.Component, e.Value)
+}
+
+// Protect runs a user callback, recovering from a panic in it so that a
+// misbehaving callback can't take down the goroutine that called it, such as
+// the session's manage loop or a recipe's watch loop. A recovered panic is
+// returned as a *PanicError; for a ZKSession it is also logged, passed to the
+// handler configured with WithPanicHandler and delivered to SubscribePanics
+// subscribers.
+func Protect(s Session, component string, callback func()) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p := &PanicError{Component: component, Value: r, Stack: debug.Stack()}
+		err = p
+		if zs, ok := s.(*ZKSession); ok {
+			zs.reportPanic(p)
+		}
+	}()
+	callback()
+	return nil
+}
+
+func (s *ZKSession) reportPanic(p *PanicError) {
+	if s.log != nil {
+		s.log.Printf("gozk-recipes/session: %v", p)
+	}
+	if s.opts.panicHandler != nil {
+		s.opts.panicHandler(p)
+	}
+	s.panics.Publish(p)
+}
+
+// SubscribePanics delivers the panics recovered by Protect for this session
+// to subscription.
+func (s *ZKSession) SubscribePanics(subscription chan<- *PanicError) {
+	s.panics.Subscribe(subscription)
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtectShouldRecoverAndReport(t *testing.T) {
+	var handled *PanicError
+	s := &ZKSession{opts: WithPanicHandler(func(p *PanicError) { handled = p })(SessionOpts{}), log: &nullLogger{}}
+	panics := make(chan *PanicError, 1)
+	s.SubscribePanics(panics)
+
+	err := Protect(s, "test", func() { panic("boom") })
+
+	var p *PanicError
+	assert.True(t, errors.As(err, &p))
+	assert.Equal(t, "test", p.Component)
+	assert.Equal(t, "boom", p.Value)
+	assert.Same(t, p, handled)
+	assert.Same(t, p, <-panics)
+	assert.NoError(t, Protect(s, "test", func() {}))
+}
+
+func TestReconnectHooksShouldSurvivePanics(t *testing.T) {
+	called := false
+	opts := WithOnReconnect(func(bool) error { panic("boom") })(SessionOpts{})
+	opts = WithOnReconnect(func(bool) error { called = true; return nil })(opts)
+
+	s := &ZKSession{opts: opts, log: &nullLogger{}}
+	s.runReconnectHooks(false)
+	assert.True(t, called)
+}
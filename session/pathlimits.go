@@ -0,0 +1,62 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrPathLimit is matched, with errors.Is, by the error returned when a
+// Create would exceed the limits set with WithPathLimits.
+var ErrPathLimit = errors.New("path limit exceeded")
+
+// sequenceSuffixLength is the length of the counter ZooKeeper appends to
+// sequential nodes.
+const sequenceSuffixLength = 10
+
+// PathLimits bound the shape of the tree a session may create. A zero limit
+// is not enforced.
+type PathLimits struct {
+	// MaxDepth is the most path components a created node may have; /a/b is
+	// at depth 2.
+	MaxDepth int
+	// MaxLength is the longest path a created node may have, in bytes,
+	// including the counter added to sequential nodes.
+	MaxLength int
+	// MaxChildren is the most children a node may have before Create refuses
+	// to add another. Enforcing it costs a read of the parent per Create, and
+	// concurrent creates may overshoot it.
+	MaxChildren int
+}
+
+// checkPathLimits checks that creating path with flags stays within the
+// configured limits.
+func (s *ZKSession) checkPathLimits(p string, flags int) error {
+	limits := s.opts.pathLimits
+
+	if limits.MaxDepth > 0 {
+		if depth := strings.Count(strings.TrimSuffix(p, "/"), "/"); depth > limits.MaxDepth {
+			return fmt.Errorf("creating %q: %w: depth %d, limit is %d", p, ErrPathLimit, depth, limits.MaxDepth)
+		}
+	}
+	if limits.MaxLength > 0 {
+		length := len(p)
+		if flags&zookeeper.SEQUENCE != 0 {
+			length += sequenceSuffixLength
+		}
+		if length > limits.MaxLength {
+			return fmt.Errorf("creating %q: %w: length %d, limit is %d", p, ErrPathLimit, length, limits.MaxLength)
+		}
+	}
+	if limits.MaxChildren > 0 {
+		// Read through the connection: the caller holds an inflight slot.
+		stat, err := s.conn.Exists(path.Dir(p))
+		if err == nil && stat != nil && stat.NumChildren() >= limits.MaxChildren {
+			return fmt.Errorf("creating %q: %w: %s has %d children, limit is %d", p, ErrPathLimit, path.Dir(p), stat.NumChildren(), limits.MaxChildren)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package session
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathLimitsShouldRejectDeepAndLongPaths(t *testing.T) {
+	s := &ZKSession{opts: WithPathLimits(PathLimits{MaxDepth: 3, MaxLength: 20})(SessionOpts{})}
+
+	assert.NoError(t, s.checkPathLimits("/a/b/c", 0))
+	assert.ErrorIs(t, s.checkPathLimits("/a/b/c/d", 0), ErrPathLimit)
+
+	assert.NoError(t, s.checkPathLimits("/jobs/job-", zookeeper.SEQUENCE))
+	assert.ErrorIs(t, s.checkPathLimits("/jobs/longer-", zookeeper.SEQUENCE), ErrPathLimit)
+	assert.ErrorIs(t, s.checkPathLimits("/aaaaaaaaaaaaaaaaaaaaa", 0), ErrPathLimit)
+}
+
+func TestPathLimitsShouldBeValidated(t *testing.T) {
+	opts := WithPathLimits(PathLimits{MaxDepth: -1})(SessionOpts{servers: []string{"localhost:2181"}, sessionTimeout: 1, connectTimeout: 1})
+	assert.ErrorIs(t, opts.Validate(), ErrInvalidOptions)
+}
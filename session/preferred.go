@@ -0,0 +1,28 @@
+package session
+
+import zookeeper "github.com/Shopify/gozk"
+
+// dialPreferred connects to the preferred servers only, the one given to
+// WithPreferredServer or the observers for ReadPreferred, returning once the
+// connection is established.
+func (so SessionOpts) dialPreferred(servers string) (*zookeeper.Conn, <-chan zookeeper.Event, error) {
+	server := servers + so.namespace
+
+	var conn *zookeeper.Conn
+	var events <-chan zookeeper.Event
+	var err error
+	if so.clientID == nil {
+		conn, events, err = dial(server, so.sessionTimeout)
+	} else {
+		conn, events, err = redial(server, so.sessionTimeout, so.clientID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := waitForConnection(events, so.connectTimeout/2); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn, events, nil
+}
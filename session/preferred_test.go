@@ -0,0 +1,37 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferredServerShouldFallBackWhenUnreachable(t *testing.T) {
+	s, err := NewSessionWithOpts(
+		WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")),
+		WithConnectTimeout(2*time.Second),
+		WithPreferredServer("127.0.0.1:1"),
+	)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	assert.False(t, s.pinned)
+	_, _, err = s.Children("/")
+	assert.NoError(t, err)
+}
+
+func TestPreferredServerShouldPinWhenReachable(t *testing.T) {
+	servers := strings.Split(zkenv.GetZooKeepers(t), ",")
+	s, err := NewSessionWithOpts(WithZookeepers(servers), WithPreferredServer(servers[0]))
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	assert.True(t, s.pinned)
+}
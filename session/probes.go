@@ -0,0 +1,75 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Probe reports why part of a service isn't ready to serve, or nil once it
+// is, for ReadyHandler.
+type Probe func() error
+
+// SyncedProbe is a Probe failing until done is closed, such as the
+// InitialSyncDone channel of a cache the service needs populated.
+func SyncedProbe(name string, done <-chan struct{}) Probe {
+	return func() error {
+		if !isClosed(done) {
+			return fmt.Errorf("%s has not completed its initial sync", name)
+		}
+		return nil
+	}
+}
+
+// ReadyHandler returns an http.Handler for readiness probes, such as
+// Kubernetes', answering 200 while s is connected and every probe passes, and
+// 503 otherwise, listing why one per line. The handler follows s from when it
+// is created on, so it should be created along with the session.
+func ReadyHandler(s Session, probes ...Probe) http.Handler {
+	c := NewConnectionState(s)
+	return probeHandler(func() []string {
+		var problems []string
+		switch {
+		case c.Ended():
+			problems = append(problems, "session has ended")
+		case !c.Connected():
+			problems = append(problems, "session is disconnected")
+		}
+		for _, probe := range probes {
+			if err := probe(); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		return problems
+	})
+}
+
+// LiveHandler returns an http.Handler for liveness probes answering 503 once
+// s has failed, expired or been closed, as the process needs restarting to
+// get a new session, and 200 otherwise. A disconnected session is still
+// live: restarting wouldn't bring ZooKeeper back, and restarting every
+// instance at once while it is unreachable would turn an outage into a
+// stampede of reconnecting clients.
+func LiveHandler(s Session) http.Handler {
+	c := NewConnectionState(s)
+	return probeHandler(func() []string {
+		if c.Ended() {
+			return []string{"session has ended"}
+		}
+		return nil
+	})
+}
+
+func probeHandler(check func() []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		problems := check()
+		if len(problems) == 0 {
+			_, _ = w.Write([]byte("ok\n"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(strings.Join(problems, "\n") + "\n"))
+	})
+}
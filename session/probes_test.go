@@ -0,0 +1,53 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(h http.Handler) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	return w.Code, w.Body.String()
+}
+
+func TestReadyHandlerShouldFollowSessionAndProbes(t *testing.T) {
+	s := &stubSession{}
+	synced := make(chan struct{})
+	var recipeErr error
+	ready := ReadyHandler(s, SyncedProbe("cache /config", synced), func() error { return recipeErr })
+	readyEvents := s.events
+	live := LiveHandler(s)
+	liveEvents := s.events
+
+	code, body := probe(ready)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "cache /config has not completed its initial sync\n", body)
+
+	close(synced)
+	code, body = probe(ready)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	recipeErr = errors.New("election not joined")
+	readyEvents <- SessionDisconnected
+	readyEvents <- SessionGated
+	code, body = probe(ready)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "session is disconnected\nelection not joined\n", body)
+
+	liveEvents <- SessionDisconnected
+	code, _ = probe(live)
+	assert.Equal(t, http.StatusOK, code)
+
+	liveEvents <- SessionExpired
+	assert.Eventually(t, func() bool {
+		code, _ := probe(live)
+		return code == http.StatusServiceUnavailable
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,30 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrDeleteProtected is matched, with errors.Is, by the error returned when
+// deleting a node protected with WithDeleteProtection.
+var ErrDeleteProtected = errors.New("node is delete-protected")
+
+// deleteProtected returns an error wrapping ErrDeleteProtected if any of paths
+// matches a pattern given to WithDeleteProtection.
+func (s *ZKSession) deleteProtected(paths ...string) error {
+	for _, p := range paths {
+		for _, pattern := range s.opts.protectedPaths {
+			if matched, _ := path.Match(pattern, p); matched {
+				return fmt.Errorf("deleting %q: %w (matches %q, use ForceDelete)", p, ErrDeleteProtected, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// ForceDelete deletes the node at path like Delete, bypassing the protection
+// configured with WithDeleteProtection.
+func (s *ZKSession) ForceDelete(path string, version int) error {
+	return s.delete(path, version)
+}
@@ -0,0 +1,17 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteProtectedShouldMatchPatterns(t *testing.T) {
+	s := &ZKSession{opts: WithDeleteProtection("/config", "/config/*/root")(SessionOpts{})}
+
+	assert.True(t, errors.Is(s.deleteProtected("/config"), ErrDeleteProtected))
+	assert.True(t, errors.Is(s.deleteProtected("/other", "/config/app/root"), ErrDeleteProtected))
+	assert.NoError(t, s.deleteProtected("/config/app", "/other"))
+	assert.True(t, errors.Is(s.Delete("/config", -1), ErrDeleteProtected))
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// rateLimitedLogger passes through the first occurrence of each log line and
+// suppresses repeats of it for a window, then logs how many were suppressed.
+// Lines are grouped by format string and the errors among their arguments,
+// so repeats with different arguments still count as the same line, but
+// different errors logged with the same format don't suppress one another.
+type rateLimitedLogger struct {
+	logger stdLogger
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+}
+
+type rateLimitEntry struct {
+	start      time.Time
+	suppressed int
+	flush      *time.Timer
+}
+
+func newRateLimitedLogger(logger stdLogger, window time.Duration) *rateLimitedLogger {
+	return &rateLimitedLogger{
+		logger:  logger,
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]*rateLimitEntry),
+	}
+}
+
+func (l *rateLimitedLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := rateLimitKey(format, v)
+	now := l.now()
+	entry, ok := l.entries[key]
+	if ok && now.Sub(entry.start) < l.window {
+		entry.suppressed++
+		if entry.flush == nil {
+			entry.flush = time.AfterFunc(l.window-now.Sub(entry.start), func() {
+				l.mu.Lock()
+				defer l.mu.Unlock()
+				l.summarize(key)
+			})
+		}
+		return
+	}
+
+	if ok {
+		l.summarize(key)
+	}
+	l.entries[key] = &rateLimitEntry{start: now}
+	l.logger.Printf(format, v...)
+}
+
+// summarize logs how many repeats of the line of key were suppressed, if any,
+// and forgets it. l.mu must be held.
+func (l *rateLimitedLogger) summarize(key string) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	if entry.flush != nil {
+		entry.flush.Stop()
+	}
+	delete(l.entries, key)
+	if entry.suppressed > 0 {
+		l.logger.Printf("gozk-recipes/session: suppressed %d repeats in last %s of: %s", entry.suppressed, l.window, key)
+	}
+}
+
+// rateLimitKey groups a log line by its format and the errors among its
+// arguments: the code of ZooKeeper errors, which leaves their path out, and
+// the message of others.
+func rateLimitKey(format string, v []interface{}) string {
+	var key strings.Builder
+	key.WriteString(format)
+	for _, arg := range v {
+		err, ok := arg.(error)
+		if !ok || err == nil {
+			continue
+		}
+		key.WriteString(" [")
+		var zkErr *zookeeper.Error
+		if errors.As(err, &zkErr) {
+			key.WriteString(zkErr.Code.String())
+		} else {
+			key.WriteString(err.Error())
+		}
+		key.WriteString("]")
+	}
+	return key.String()
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestRateLimitedLoggerShouldSummarizeRepeats(t *testing.T) {
+	rec := &recordingLogger{}
+	now := time.Unix(0, 0)
+	l := newRateLimitedLogger(rec, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		l.Printf("reconnecting to %d", i)
+	}
+	l.Printf("other line")
+	assert.Equal(t, []string{"reconnecting to 0", "other line"}, rec.lines)
+
+	now = now.Add(time.Minute)
+	l.Printf("reconnecting to %d", 3)
+	assert.Equal(t, []string{
+		"reconnecting to 0",
+		"other line",
+		"gozk-recipes/session: suppressed 2 repeats in last 1m0s of: reconnecting to %d",
+		"reconnecting to 3",
+	}, rec.lines)
+}
+
+func TestRateLimitedLoggerShouldFlushAfterWindow(t *testing.T) {
+	rec := &recordingLogger{}
+	l := newRateLimitedLogger(rec, 10*time.Millisecond)
+
+	l.Printf("flap")
+	l.Printf("flap")
+	time.Sleep(50 * time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	assert.Equal(t, []string{
+		"flap",
+		"gozk-recipes/session: suppressed 1 repeats in last 10ms of: flap",
+	}, rec.lines)
+}
+
+func TestRateLimitedLoggerShouldKeepDifferentErrorsApart(t *testing.T) {
+	rec := &recordingLogger{}
+	now := time.Unix(0, 0)
+	l := newRateLimitedLogger(rec, time.Minute)
+	l.now = func() time.Time { return now }
+
+	noNode := func(path string) error { return &zookeeper.Error{Op: "get", Code: zookeeper.ZNONODE, Path: path} }
+	l.Printf("gozk-recipes/session: %v", noNode("/a"))
+	l.Printf("gozk-recipes/session: %v", noNode("/b"))
+	l.Printf("gozk-recipes/session: %v", errors.New("auth failed"))
+	l.Printf("gozk-recipes/session: %v", errors.New("auth failed"))
+	assert.Equal(t, []string{
+		"gozk-recipes/session: " + noNode("/a").Error(),
+		"gozk-recipes/session: auth failed",
+	}, rec.lines)
+
+	now = now.Add(time.Minute)
+	l.Printf("gozk-recipes/session: %v", errors.New("auth failed"))
+	assert.Contains(t, rec.lines, "gozk-recipes/session: suppressed 1 repeats in last 1m0s of: gozk-recipes/session: %v [auth failed]")
+}
@@ -0,0 +1,136 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrReadOnlySession is matched, with errors.Is, by the errors returned by
+// the mutating methods of a ReadOnlySession.
+var ErrReadOnlySession = errors.New("session is read-only")
+
+// ReadOnlySession is a Session that forwards reads and watches to another
+// session and refuses every write, for handing to components that must never
+// change anything, such as dashboards and exporters. Create, Delete, Set,
+// RetryChange and SetACL fail with an error wrapping ErrReadOnlySession
+// without reaching ZooKeeper.
+type ReadOnlySession struct {
+	parent Session
+}
+
+var _ Session = (*ReadOnlySession)(nil)
+
+// NewReadOnly returns a read-only facade for s. A facade of a facade is the
+// facade itself.
+func NewReadOnly(s Session) *ReadOnlySession {
+	if ro, ok := s.(*ReadOnlySession); ok {
+		return ro
+	}
+	return &ReadOnlySession{parent: s}
+}
+
+// ReadOnly returns a read-only facade for s; see ReadOnlySession.
+func (s *ZKSession) ReadOnly() *ReadOnlySession {
+	return NewReadOnly(s)
+}
+
+// ReadOnly returns a read-only facade for the view; see ReadOnlySession.
+func (v *View) ReadOnly() *ReadOnlySession {
+	return NewReadOnly(v)
+}
+
+// Parent returns the session the facade was created for.
+func (r *ReadOnlySession) Parent() Session {
+	return r.parent
+}
+
+func refuse(op, path string) error {
+	return fmt.Errorf("%s %q: %w", op, path, ErrReadOnlySession)
+}
+
+func (r *ReadOnlySession) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	return r.parent.ACL(path)
+}
+
+// AddAuth is forwarded, as reading nodes may need credentials. It adds them
+// to the parent session's connection.
+func (r *ReadOnlySession) AddAuth(scheme, cert string) error {
+	return r.parent.AddAuth(scheme, cert)
+}
+
+func (r *ReadOnlySession) Children(path string) ([]string, *zookeeper.Stat, error) {
+	return r.parent.Children(path)
+}
+
+func (r *ReadOnlySession) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.parent.ChildrenW(path)
+}
+
+func (r *ReadOnlySession) ClientId() *zookeeper.ClientId {
+	return r.parent.ClientId()
+}
+
+// Close has no effect: the connection belongs to the parent session.
+func (r *ReadOnlySession) Close() error {
+	return nil
+}
+
+func (r *ReadOnlySession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	return "", refuse("creating", path)
+}
+
+func (r *ReadOnlySession) Delete(path string, version int) error {
+	return refuse("deleting", path)
+}
+
+func (r *ReadOnlySession) Exists(path string) (*zookeeper.Stat, error) {
+	return r.parent.Exists(path)
+}
+
+func (r *ReadOnlySession) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.parent.ExistsW(path)
+}
+
+func (r *ReadOnlySession) Get(path string) (string, *zookeeper.Stat, error) {
+	return r.parent.Get(path)
+}
+
+func (r *ReadOnlySession) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.parent.GetW(path)
+}
+
+func (r *ReadOnlySession) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	return nil, refuse("setting", path)
+}
+
+func (r *ReadOnlySession) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	return refuse("changing", path)
+}
+
+func (r *ReadOnlySession) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	return refuse("setting the ACL of", path)
+}
+
+func (r *ReadOnlySession) Subscribe(subscription chan<- ZKSessionEvent) {
+	r.parent.Subscribe(subscription)
+}
+
+// Epoch, Name, Codec and Register forward to the parent session, so recipes
+// that only read, such as caches and watchers, behave as they would with the
+// session itself.
+
+func (r *ReadOnlySession) Epoch() uint64 { return EpochOf(r.parent) }
+
+func (r *ReadOnlySession) Name() string {
+	if named, ok := r.parent.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "unknown"
+}
+
+func (r *ReadOnlySession) Codec() Codec { return codecOf(r.parent) }
+
+func (r *ReadOnlySession) Register(c io.Closer) func() { return RegisterCloser(r.parent, c) }
@@ -0,0 +1,32 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyShouldRefuseWrites(t *testing.T) {
+	parent := &pathSession{}
+	r := NewReadOnly(parent)
+	assert.Same(t, r, NewReadOnly(r))
+
+	_, err := r.Create("/config", "", 0, nil)
+	assert.True(t, errors.Is(err, ErrReadOnlySession))
+	_, err = r.Set("/config", "", -1)
+	assert.True(t, errors.Is(err, ErrReadOnlySession))
+	assert.True(t, errors.Is(r.Delete("/config", -1), ErrReadOnlySession))
+	assert.True(t, errors.Is(r.SetACL("/config", nil, -1), ErrReadOnlySession))
+	assert.True(t, errors.Is(r.RetryChange("/config", 0, nil, func(string, *zookeeper.Stat) (string, error) {
+		t.Error("change function called")
+		return "", nil
+	}), ErrReadOnlySession))
+	assert.Empty(t, parent.paths)
+
+	_, _, err = r.Get("/config")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/config"}, parent.paths)
+	assert.NoError(t, r.Close())
+}
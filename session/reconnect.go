@@ -0,0 +1,111 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrSessionTerminated is returned by Reconnect once the session has been
+// closed or has failed. It is an ErrSessionLost.
+var ErrSessionTerminated = NewError("zookeeper session terminated", ErrSessionLost)
+
+// reconnectRequest asks the manage loop to reconnect, staying off the servers
+// in avoid, or with expire set to expire the session; see SimulateExpiry.
+type reconnectRequest struct {
+	avoid  []string
+	expire bool
+	done   chan error
+}
+
+// Reconnect updates the connection's server list, resolving the servers'
+// host names again, and adds the credentials configured with WithAuth again.
+// It keeps the connection and so the session: ephemeral nodes and watches
+// survive, and subscribers only see SessionDisconnected and
+// SessionReconnected if the client moves to another server.
+//
+// Servers given in avoid, as host:port, are left out of the server list, for
+// draining a server before maintenance; pass CurrentServer to move off the
+// current one. The client then moves to one of the remaining servers, keeping
+// the session. Avoided servers stay out until the next Reconnect, or until the
+// session is redialed after it expires. Reconnect fails without changing
+// anything if an avoided server isn't configured or no other server would be
+// left.
+func (s *ZKSession) Reconnect(avoid ...string) error {
+	req := reconnectRequest{avoid: avoid, done: make(chan error, 1)}
+	select {
+	case s.reconnects <- req:
+	case <-s.managed:
+		return ErrSessionTerminated
+	}
+	return <-req.done
+}
+
+// setServers switches the connection to servers, a comma separated list of
+// host:port without the namespace, keeping the session.
+func (s *ZKSession) setServers(servers string) {
+	s.mu.Lock()
+	s.conn.SetServers(servers)
+	s.mu.Unlock()
+	s.log.Printf("gozk-recipes/session: server list set to %s", servers)
+}
+
+// hostListAvoiding is hostList without the servers in avoid.
+func (so SessionOpts) hostListAvoiding(avoid []string) (string, error) {
+	if len(avoid) == 0 {
+		return so.hostList(), nil
+	}
+
+	remaining := so
+	remaining.servers = nil
+	matched := make([]bool, len(avoid))
+	for _, server := range so.servers {
+		keep := true
+		for i, a := range avoid {
+			if sameServer(server, a) {
+				matched[i] = true
+				keep = false
+			}
+		}
+		if keep {
+			remaining.servers = append(remaining.servers, server)
+		}
+	}
+
+	for i, a := range avoid {
+		if !matched[i] {
+			return "", fmt.Errorf("server %s is not one of the configured servers", a)
+		}
+	}
+	if len(remaining.servers) == 0 {
+		return "", fmt.Errorf("no servers left after avoiding %s", strings.Join(avoid, ", "))
+	}
+	return remaining.hostList(), nil
+}
+
+// sameServer reports whether the configured server is server. Servers are
+// compared as given, and server may also be one of the configured server's
+// addresses, as reported by CurrentServer.
+func sameServer(configured, server string) bool {
+	if configured == server {
+		return true
+	}
+	host, port, err := net.SplitHostPort(configured)
+	if err != nil {
+		return false
+	}
+	addr, addrPort, err := net.SplitHostPort(server)
+	if err != nil || port != addrPort || net.ParseIP(addr) == nil {
+		return false
+	}
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
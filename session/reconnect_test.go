@@ -0,0 +1,119 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectShouldKeepSession(t *testing.T) {
+	s, err := NewZKSession(zkenv.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	s.DeleteRecursive("/test")
+	if _, err := s.Create("/test", "", 0, defaultACLs); err != nil {
+		t.Fatal("Create error: ", err)
+	}
+	defer s.DeleteRecursive("/test")
+	if _, err := s.Create("/test/ephemeral", "", zookeeper.EPHEMERAL, defaultACLs); err != nil {
+		t.Fatal("Create error: ", err)
+	}
+	id, err := s.ClientId().Save()
+	if err != nil {
+		t.Fatal("ClientId error: ", err)
+	}
+	epoch := s.Epoch()
+
+	if err := s.Reconnect(); err != nil {
+		t.Fatal("Reconnect error: ", err)
+	}
+
+	stat, err := s.Exists("/test/ephemeral")
+	if err != nil {
+		t.Fatal("Exists error: ", err)
+	}
+	assert.NotNil(t, stat, "Expected the ephemeral node to survive Reconnect")
+	current, err := s.ClientId().Save()
+	if err != nil {
+		t.Fatal("ClientId error: ", err)
+	}
+	assert.Equal(t, id, current)
+	assert.Equal(t, epoch, s.Epoch())
+}
+
+func TestReconnectAvoidingCurrentServerShouldKeepSession(t *testing.T) {
+	servers := zkenv.GetZooKeepers(t)
+	if !strings.Contains(servers, ",") {
+		t.Skip("needs more than one server in ZOOKEEPERS")
+	}
+	s, err := NewZKSession(servers, 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+
+	s.DeleteRecursive("/test")
+	if _, err := s.Create("/test", "", 0, defaultACLs); err != nil {
+		t.Fatal("Create error: ", err)
+	}
+	defer s.DeleteRecursive("/test")
+	if _, err := s.Create("/test/ephemeral", "", zookeeper.EPHEMERAL, defaultACLs); err != nil {
+		t.Fatal("Create error: ", err)
+	}
+	id, err := s.ClientId().Save()
+	if err != nil {
+		t.Fatal("ClientId error: ", err)
+	}
+	epoch := s.Epoch()
+
+	if err := s.Reconnect(s.CurrentServer()); err != nil {
+		t.Fatal("Reconnect error: ", err)
+	}
+
+	stat, err := s.Exists("/test/ephemeral")
+	if err != nil {
+		t.Fatal("Exists error: ", err)
+	}
+	assert.NotNil(t, stat, "Expected the ephemeral node to survive moving off the server")
+	current, err := s.ClientId().Save()
+	if err != nil {
+		t.Fatal("ClientId error: ", err)
+	}
+	assert.Equal(t, id, current)
+	assert.Equal(t, epoch, s.Epoch())
+}
+
+func TestReconnectShouldFailOnceTerminated(t *testing.T) {
+	s := &ZKSession{managed: make(chan struct{})}
+	close(s.managed)
+	assert.Equal(t, ErrSessionTerminated, s.Reconnect())
+}
+
+func TestHostListAvoidingShouldDropAvoidedServers(t *testing.T) {
+	opts := SessionOpts{servers: []string{"10.0.0.1:2181", "10.0.0.2:2181", "10.0.0.3:2181"}, namespace: "/app"}
+
+	servers, err := opts.hostListAvoiding([]string{"10.0.0.2:2181"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:2181,10.0.0.3:2181", servers)
+
+	servers, err = opts.hostListAvoiding(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, opts.hostList(), servers)
+}
+
+func TestHostListAvoidingShouldRejectUnknownOrAllServers(t *testing.T) {
+	opts := SessionOpts{servers: []string{"10.0.0.1:2181", "10.0.0.2:2181"}}
+
+	_, err := opts.hostListAvoiding([]string{"10.0.0.9:2181"})
+	assert.Error(t, err)
+
+	_, err = opts.hostListAvoiding([]string{"10.0.0.1:2181", "10.0.0.2:2181"})
+	assert.Error(t, err)
+}
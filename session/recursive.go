@@ -1,7 +1,6 @@
 package session
 
 import (
-	"sort"
 	"strings"
 
 	"github.com/Shopify/gozk"
@@ -63,7 +62,7 @@ func (s *ZKSession) CreateRecursiveAndSet(path string, data string) error {
 		}
 
 		if stat == nil {
-			if _, err := s.Create(path[:index], "", 0, defaultACLs); err != nil {
+			if _, err := s.Create(path[:index], "", 0, s.DefaultACL()); err != nil {
 				return err
 			}
 		}
@@ -71,31 +70,30 @@ func (s *ZKSession) CreateRecursiveAndSet(path string, data string) error {
 
 	stat, err := s.Set(path, data, -1)
 	if stat == nil {
-		_, err = s.Create(path, data, 0, defaultACLs)
+		_, err = s.Create(path, data, 0, s.DefaultACL())
 	}
 
 	return err
 }
 
-type nodePaths []string
-
-func (s nodePaths) Len() int           { return len(s) }
-func (s nodePaths) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s nodePaths) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+// DeleteRecursive removes a given path and all of its descendents. By
+// default nodes are deleted one at a time; see WithDeleteConcurrency,
+// WithDeleteRate and WithDeleteProgress for large trees. Descendants deleted
+// meanwhile are skipped, so a delete that was interrupted is resumed by
+// calling DeleteRecursive again.
+func (s *ZKSession) DeleteRecursive(path string, opts ...DeleteOption) error {
+	var o deleteOptions
+	for _, opt := range opts {
+		o = opt(o)
+	}
 
-// DeleteRecursive removes a given path and all of its descendents.
-func (s *ZKSession) DeleteRecursive(path string) error {
 	children, err := s.ChildrenRecursive(path, -1)
 	if err != nil {
 		return err
 	}
 
-	sort.Sort(sort.Reverse(nodePaths(children)))
-
-	for _, child := range children {
-		if err := s.Delete(child, -1); err != nil {
-			return err
-		}
+	if err := s.deleteProtected(append(children, path)...); err != nil {
+		return err
 	}
-	return s.Delete(path, -1)
+	return s.deleteTree(path, -1, children, o)
 }
@@ -5,7 +5,7 @@ import (
 	"time"
 
 	zookeeper "github.com/Shopify/gozk"
-	"github.com/Shopify/gozk-recipes/test"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,7 +40,7 @@ func AssertNodeExists(t *testing.T, session *ZKSession, path string) {
 }
 
 func withTestStore(t *testing.T, f func(*ZKSession)) {
-	store, err := NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	store, err := NewZKSession(zkenv.GetZooKeepers(t), 200*time.Millisecond, nil)
 	if err != nil {
 		t.Error("Failed to connect to Zookeeper: ", err)
 	}
@@ -149,3 +149,22 @@ func TestDeleteRecursiveShouldDelete(t *testing.T) {
 		AssertNodeExists(t, session, "/test")
 	})
 }
+
+func TestDeleteRecursiveShouldDeleteConcurrentlyWithProgress(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/a/1", "/test/a/2", "/test/b", "/test/b/1")
+
+		var progress []DeleteProgress
+		err := session.DeleteRecursive("/test", WithDeleteConcurrency(4), WithDeleteRate(1000), WithDeleteProgress(func(p DeleteProgress) {
+			progress = append(progress, p)
+		}))
+		if err != nil {
+			t.Fatal("DeleteRecursive error: ", err)
+		}
+
+		AssertNodeDoesNotExist(t, session, "/test")
+		if assert.Len(t, progress, 6) {
+			assert.Equal(t, DeleteProgress{Path: "/test", Deleted: 6, Total: 6}, progress[5])
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// eventHistorySize is how many session events are kept for debugging.
+const eventHistorySize = 64
+
+// registry holds the sessions created with WithRegistry.
+var registry = struct {
+	mu       sync.Mutex
+	sessions map[*ZKSession]struct{}
+}{sessions: make(map[*ZKSession]struct{})}
+
+// Sessions returns the live sessions created with WithRegistry, by name.
+func Sessions() []*ZKSession {
+	registry.mu.Lock()
+	sessions := make([]*ZKSession, 0, len(registry.sessions))
+	for s := range registry.sessions {
+		sessions = append(sessions, s)
+	}
+	registry.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Name() < sessions[j].Name()
+	})
+	return sessions
+}
+
+func register(s *ZKSession) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.sessions[s] = struct{}{}
+}
+
+func unregister(s *ZKSession) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.sessions, s)
+}
+
+// EventRecord is a session event delivered to subscribers.
+type EventRecord struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+}
+
+// WatchInfo counts the outstanding watches of one kind on a path.
+type WatchInfo struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// Attachment is a recipe using a session, registered with Attach.
+type Attachment struct {
+	Recipe string    `json:"recipe"`
+	Detail string    `json:"detail"`
+	Since  time.Time `json:"since"`
+}
+
+// debugState is what a registered session tracks for the debug handler.
+type debugState struct {
+	mu          sync.Mutex
+	history     []EventRecord
+	watches     map[WatchInfo]int
+	attachments map[*Attachment]struct{}
+}
+
+func newDebugState() *debugState {
+	return &debugState{
+		watches:     make(map[WatchInfo]int),
+		attachments: make(map[*Attachment]struct{}),
+	}
+}
+
+func (d *debugState) recordEvent(event ZKSessionEvent) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.history) == eventHistorySize {
+		d.history = append(d.history[:0], d.history[1:]...)
+	}
+	d.history = append(d.history, EventRecord{Time: time.Now(), Event: event.String()})
+}
+
+// trackWatch counts watch as outstanding until it fires. Watch events can't
+// be observed without consuming them, so they are forwarded through a new
+// channel, which is returned.
+func (d *debugState) trackWatch(path, kind string, watch <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if d == nil || watch == nil {
+		return watch
+	}
+
+	key := WatchInfo{Path: path, Kind: kind}
+	d.mu.Lock()
+	d.watches[key]++
+	d.mu.Unlock()
+
+	forwarded := make(chan zookeeper.Event, 1)
+	go func() {
+		event, ok := <-watch
+		d.mu.Lock()
+		if d.watches[key]--; d.watches[key] == 0 {
+			delete(d.watches, key)
+		}
+		d.mu.Unlock()
+		if ok {
+			forwarded <- event
+		}
+		close(forwarded)
+	}()
+	return forwarded
+}
+
+// RecipeEvent is published when a recipe attaches to or detaches from a
+// session; see Attach and SubscribeRecipes.
+type RecipeEvent struct {
+	Recipe   string
+	Detail   string
+	Attached bool
+	Time     time.Time
+}
+
+// SubscribeRecipes delivers a RecipeEvent to subscription whenever a recipe
+// starts or stops using the session. Like Subscribe, delivery is in order and
+// blocks the recipe until subscription receives the event.
+func (s *ZKSession) SubscribeRecipes(subscription chan<- RecipeEvent) {
+	s.recipeEvents.Subscribe(subscription)
+}
+
+// Attach records that a recipe is using s until the returned function is
+// called, publishing a RecipeEvent for each, and tracking the recipe for the
+// debug handler if the session was created with WithRegistry. detail
+// describes the recipe instance, for example the path of a lock. It does
+// nothing for sessions other than ZKSession.
+func Attach(s Session, recipe, detail string) (detach func()) {
+	zs, ok := s.(*ZKSession)
+	if !ok {
+		return func() {}
+	}
+
+	a := &Attachment{Recipe: recipe, Detail: detail, Since: time.Now()}
+	d := zs.debug
+	if d != nil {
+		d.mu.Lock()
+		d.attachments[a] = struct{}{}
+		d.mu.Unlock()
+	}
+	zs.recipeEvents.Publish(RecipeEvent{Recipe: recipe, Detail: detail, Attached: true, Time: a.Since})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if d != nil {
+				d.mu.Lock()
+				delete(d.attachments, a)
+				d.mu.Unlock()
+			}
+			zs.recipeEvents.Publish(RecipeEvent{Recipe: recipe, Detail: detail, Time: time.Now()})
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/retry"
+)
+
+// DefaultMaxAttempts is how many times RetryChangeCtx tries a change unless
+// WithMaxAttempts is given.
+const DefaultMaxAttempts = 10
+
+// ErrConflictsExhausted is matched, with errors.Is, by the ConflictError
+// returned by RetryChangeCtx.
+var ErrConflictsExhausted = errors.New("change kept conflicting with concurrent writes")
+
+// ConflictError is returned by RetryChangeCtx when every attempt at a change
+// lost a race with another writer. Err is the conflict seen last.
+type ConflictError struct {
+	Path     string
+	Attempts int
+	Err      error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("changing %s: gave up after %d conflicting attempts: %v", e.Path, e.Attempts, e.Err)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflictsExhausted
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ChangeFuncError is returned by RetryChangeCtx when the change function
+// itself fails. The change isn't retried.
+type ChangeFuncError struct {
+	Path string
+	Err  error
+}
+
+func (e *ChangeFuncError) Error() string {
+	return fmt.Sprintf("changing %s: %v", e.Path, e.Err)
+}
+
+func (e *ChangeFuncError) Unwrap() error {
+	return e.Err
+}
+
+// changePolicy is how RetryChangeCtx backs off unless given WithRetryBackoff
+// or a session with WithRetryPolicy.
+var changePolicy = retry.Policy{Initial: 10 * time.Millisecond, Max: time.Second, Jitter: retry.FullJitter}
+
+type retryOptions struct {
+	maxAttempts int
+	policy      *retry.Policy
+	margin      time.Duration
+	hook        func(RetryAttempt)
+}
+
+// RetryOption configures RetryChangeCtx.
+type RetryOption func(retryOptions) retryOptions
+
+// WithMaxAttempts gives up on a change after n conflicting attempts, or never
+// with n of zero.
+func WithMaxAttempts(n int) RetryOption {
+	return func(o retryOptions) retryOptions {
+		o.maxAttempts = n
+		return o
+	}
+}
+
+// WithRetryBackoff waits between conflicting attempts, up to initial after the
+// first and twice as long after each one since, up to max. The waits are
+// jittered so contending writers spread out. Zero doesn't wait at all. It
+// takes precedence over the session's WithRetryPolicy.
+func WithRetryBackoff(initial, max time.Duration) RetryOption {
+	return func(o retryOptions) retryOptions {
+		o.policy = &retry.Policy{Initial: initial, Max: max, Jitter: retry.FullJitter}
+		return o
+	}
+}
+
+// WithDeadlineMargin stops starting attempts, and waiting between them, once
+// less than margin would be left before the context's deadline, returning an
+// error wrapping context.DeadlineExceeded. It is DefaultDeadlineMargin
+// otherwise.
+func WithDeadlineMargin(margin time.Duration) RetryOption {
+	return func(o retryOptions) retryOptions {
+		o.margin = margin
+		return o
+	}
+}
+
+// WithRetryHook calls hook after every conflicting attempt that is to be
+// tried again, with the time left until the context's deadline, for logging
+// or metrics. It is called from the retrying goroutine and must not block.
+func WithRetryHook(hook func(RetryAttempt)) RetryOption {
+	return func(o retryOptions) retryOptions {
+		o.hook = hook
+		return o
+	}
+}
+
+// RetryChangeCtx is RetryChange with a bound on how long it keeps at it. Like
+// RetryChange, it reads the node at path, calls changeFunc with its value, or
+// with an empty value and a nil Stat if it doesn't exist, and writes the
+// result back at the version read, creating the node with flags and acl if
+// needed. A write losing a race with another writer is tried again from the
+// read, up to DefaultMaxAttempts times unless WithMaxAttempts is given,
+// backing off between attempts with the session's retry policy if it has one;
+// see WithRetryBackoff.
+//
+// It gives up with ctx's error once ctx is done, or once too little time is
+// left before its deadline to start an attempt; see WithDeadlineMargin. It
+// gives up with a ChangeFuncError if changeFunc fails, and with a
+// ConflictError once out of attempts. Other errors from ZooKeeper are
+// returned as they are.
+func RetryChangeCtx(ctx context.Context, s Session, path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc, opts ...RetryOption) error {
+	o := retryOptions{maxAttempts: DefaultMaxAttempts, margin: DefaultDeadlineMargin}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	policy := RetryPolicyOf(s, changePolicy)
+	if o.policy != nil {
+		policy = *o.policy
+	}
+
+	backoff := policy.Start()
+	for attempt := 1; ; attempt++ {
+		if err := outOfBudget(ctx, 0, o.margin); err != nil {
+			return fmt.Errorf("changing %s: %w", path, err)
+		}
+
+		conflict, err := tryChange(s, path, flags, acl, changeFunc)
+		if conflict == nil {
+			return err
+		}
+		if o.maxAttempts > 0 && attempt >= o.maxAttempts {
+			return &ConflictError{Path: path, Attempts: attempt, Err: conflict}
+		}
+
+		wait := backoff.Next()
+		if o.hook != nil {
+			remaining, ok := Remaining(ctx)
+			o.hook(RetryAttempt{Path: path, Attempt: attempt, Err: conflict, Backoff: wait, Remaining: remaining, HasDeadline: ok})
+		}
+		if err := outOfBudget(ctx, wait, o.margin); err != nil {
+			return fmt.Errorf("changing %s: %w", path, err)
+		}
+		if err := retry.Sleep(ctx, wait); err != nil {
+			return fmt.Errorf("changing %s: %w", path, err)
+		}
+	}
+}
+
+// tryChange makes a single attempt at a change. It returns the error of a
+// write that lost a race, to be tried again, as conflict, and any other
+// outcome as err.
+func tryChange(s Session, path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) (conflict, err error) {
+	oldValue, stat, err := s.Get(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		oldValue, stat = "", nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	newValue, err := changeFunc(oldValue, stat)
+	if err != nil {
+		return nil, &ChangeFuncError{Path: path, Err: err}
+	}
+
+	if stat == nil {
+		_, err = s.Create(path, newValue, flags, acl)
+		if zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+			return err, nil
+		}
+		return nil, err
+	}
+	if newValue == oldValue {
+		return nil, nil
+	}
+	_, err = s.Set(path, newValue, stat.Version())
+	if zookeeper.IsError(err, zookeeper.ZBADVERSION) || zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return err, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryChangeCtxShouldCreateAndUpdate(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+		increment := func(old string, _ *zookeeper.Stat) (string, error) {
+			n, _ := strconv.Atoi(old)
+			return strconv.Itoa(n + 1), nil
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := RetryChangeCtx(context.Background(), session, "/test/counter", 0, acl, increment); err != nil {
+				t.Fatal("RetryChangeCtx error: ", err)
+			}
+		}
+		AssertNodeValueEqual(t, session, "/test/counter", "2")
+	})
+}
+
+func TestRetryChangeCtxShouldNotRetryFailingChange(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		failure := errors.New("invalid value")
+		calls := 0
+
+		err := RetryChangeCtx(context.Background(), session, "/test", 0, nil, func(string, *zookeeper.Stat) (string, error) {
+			calls++
+			return "", failure
+		})
+
+		var changeErr *ChangeFuncError
+		assert.True(t, errors.As(err, &changeErr))
+		assert.ErrorIs(t, err, failure)
+		assert.False(t, errors.Is(err, ErrConflictsExhausted))
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryChangeCtxShouldGiveUpAfterMaxAttempts(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		calls := 0
+
+		// Every attempt is beaten by a write made while it is deciding.
+		err := RetryChangeCtx(context.Background(), session, "/test", 0, nil, func(string, *zookeeper.Stat) (string, error) {
+			calls++
+			if _, err := session.Set("/test", "concurrent", -1); err != nil {
+				t.Error("Set error: ", err)
+			}
+			return "mine", nil
+		}, WithMaxAttempts(3), WithRetryBackoff(time.Millisecond, time.Millisecond))
+
+		var conflict *ConflictError
+		assert.True(t, errors.As(err, &conflict))
+		assert.ErrorIs(t, err, ErrConflictsExhausted)
+		assert.True(t, zookeeper.IsError(conflict.Err, zookeeper.ZBADVERSION))
+		assert.Equal(t, 3, conflict.Attempts)
+		assert.Equal(t, 3, calls)
+		AssertNodeValueEqual(t, session, "/test", "concurrent")
+	})
+}
+
+func TestRetryChangeCtxShouldStopWhenContextIsDone(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+		ctx, cancel := context.WithCancel(context.Background())
+
+		err := RetryChangeCtx(ctx, session, "/test", 0, nil, func(string, *zookeeper.Stat) (string, error) {
+			cancel()
+			if _, err := session.Set("/test", "concurrent", -1); err != nil {
+				t.Error("Set error: ", err)
+			}
+			return "mine", nil
+		}, WithMaxAttempts(0))
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
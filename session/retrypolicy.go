@@ -0,0 +1,33 @@
+package session
+
+import "github.com/Shopify/gozk-recipes/retry"
+
+// WithRetryPolicy sets how the recipes using the session back off between
+// retries and waits, unless they are given a backoff of their own:
+// WaitForCreate and WaitForDelete between failed reads, RetryChangeCtx
+// between conflicting writes, and any recipe reading the policy with
+// RetryPolicyOf.
+func WithRetryPolicy(policy retry.Policy) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.retryPolicy = policy
+		return so
+	}
+}
+
+// RetryPolicy returns the policy set with WithRetryPolicy, or false if none
+// was.
+func (s *ZKSession) RetryPolicy() (retry.Policy, bool) {
+	return s.opts.retryPolicy, !s.opts.retryPolicy.IsZero()
+}
+
+// RetryPolicyOf returns the retry policy of s, or fallback if s has none,
+// such as a ZKSession given no WithRetryPolicy. Recipes without a more
+// specific default pass retry.DefaultPolicy.
+func RetryPolicyOf(s Session, fallback retry.Policy) retry.Policy {
+	if withPolicy, ok := s.(interface{ RetryPolicy() (retry.Policy, bool) }); ok {
+		if policy, ok := withPolicy.RetryPolicy(); ok {
+			return policy
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,26 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyOfShouldFallBackWithoutWithRetryPolicy(t *testing.T) {
+	fallback := retry.Policy{Initial: time.Millisecond, Max: time.Millisecond}
+	s := &ZKSession{log: &nullLogger{}}
+	assert.Equal(t, fallback, RetryPolicyOf(s, fallback))
+
+	policy := retry.Policy{Initial: time.Second, Max: time.Minute, Jitter: retry.FullJitter}
+	s.opts = WithRetryPolicy(policy)(s.opts)
+	assert.Equal(t, policy, RetryPolicyOf(s, fallback))
+	assert.Equal(t, fallback, RetryPolicyOf(nil, fallback))
+}
+
+func TestValidateShouldRejectInvalidRetryPolicy(t *testing.T) {
+	opts := SessionOpts{servers: []string{"zk1:2181"}, sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout}
+	opts = WithRetryPolicy(retry.Policy{Initial: time.Second, Max: time.Millisecond})(opts)
+	assert.Error(t, opts.Validate())
+}
@@ -0,0 +1,149 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// observerSuffix marks observers in the server lists given to
+// WithZookeepers and NewZKSession.
+const observerSuffix = ":observer"
+
+// ServerRole selects which of the configured servers a session connects to,
+// depending on whether they are observers; see WithObservers.
+type ServerRole int
+
+const (
+	// AnyServer lets the client pick any configured server.
+	AnyServer ServerRole = iota
+	// ReadPreferred connects to an observer if one can be reached, for
+	// sessions behind read-only facades and read-heavy caches, so that the
+	// observers deployed to absorb read and watch load do so. It pins the
+	// session to the observers as WithPreferredServer pins it to a server:
+	// if no observer can be reached within half the connect timeout, or the
+	// connection to one is lost, the session moves to every configured
+	// server, keeping its ephemeral nodes and watches.
+	ReadPreferred
+	// ParticipantsOnly never connects to an observer, for sessions making
+	// mostly writes, which observers would forward to the leader. Roles
+	// apply to the whole session: a ReadPreferred session's writes go
+	// through the observer it is connected to, and sending some writes
+	// through a participant takes a separate ParticipantsOnly session.
+	ParticipantsOnly
+)
+
+func (r ServerRole) String() string {
+	switch r {
+	case AnyServer:
+		return "AnyServer"
+	case ReadPreferred:
+		return "ReadPreferred"
+	case ParticipantsOnly:
+		return "ParticipantsOnly"
+	}
+	return fmt.Sprintf("ServerRole(%d)", int(r))
+}
+
+// WithObservers marks servers, given as host:port, among the configured ones
+// as ZooKeeper observers, for WithServerRole. Observers can also be marked in
+// the server list itself with an ":observer" suffix, as in
+// "zk1:2181,zk2:2181,zk3:2181,obs1:2181:observer".
+func WithObservers(servers ...string) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.observers = append(so.observers, servers...)
+		return so
+	}
+}
+
+// WithServerRole makes the session connect to the servers of role; see
+// ServerRole.
+func WithServerRole(role ServerRole) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.role = role
+		return so
+	}
+}
+
+// splitObservers strips the observer suffix from servers, returning the
+// servers along with those marked as observers.
+func splitObservers(servers []string) (all, observers []string) {
+	all = make([]string, len(servers))
+	for i, server := range servers {
+		if trimmed := strings.TrimSuffix(server, observerSuffix); trimmed != server {
+			server = trimmed
+			observers = append(observers, server)
+		}
+		all[i] = server
+	}
+	return all, observers
+}
+
+// containsServer reports whether server is one of servers. Servers are
+// matched as given, so observers must be given as they are configured.
+func containsServer(servers []string, server string) bool {
+	for _, s := range servers {
+		if s == server {
+			return true
+		}
+	}
+	return false
+}
+
+// isObserver reports whether the configured server is an observer.
+func (so SessionOpts) isObserver(server string) bool {
+	return containsServer(so.observers, server)
+}
+
+// configuredServer returns the configured server whose address is addr, as
+// reported by CurrentServer, or addr if none is.
+func (so SessionOpts) configuredServer(addr string) string {
+	for _, server := range so.servers {
+		if sameServer(server, addr) {
+			return server
+		}
+	}
+	return addr
+}
+
+// roleServers returns the configured servers the session's role allows.
+func (so SessionOpts) roleServers() []string {
+	if so.role != ParticipantsOnly {
+		return so.servers
+	}
+	var participants []string
+	for _, server := range so.servers {
+		if !so.isObserver(server) {
+			participants = append(participants, server)
+		}
+	}
+	return participants
+}
+
+// preferredServers returns the servers the session is pinned to when it can
+// reach them, the one given to WithPreferredServer or the observers for
+// ReadPreferred, or "" if none.
+func (so SessionOpts) preferredServers() string {
+	if so.preferredServer != "" {
+		return so.preferredServer
+	}
+	if so.role == ReadPreferred {
+		return strings.Join(so.observers, ",")
+	}
+	return ""
+}
+
+// OnObserver reports whether the session is connected to one of the servers
+// marked with WithObservers. The configured server behind each address the
+// session connects to is looked up once, so it stays cheap to call.
+func (s *ZKSession) OnObserver() bool {
+	addr := s.CurrentServer()
+	if addr == "" {
+		return false
+	}
+	if observer, ok := s.observerAddrs.Load(addr); ok {
+		return observer.(bool)
+	}
+	observer := s.opts.isObserver(s.opts.configuredServer(addr))
+	s.observerAddrs.Store(addr, observer)
+	return observer
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithZookeepersShouldMarkObservers(t *testing.T) {
+	opts := WithZookeepers([]string{"zk1:2181", "zk2:2181", "obs1:2181:observer"})(SessionOpts{})
+	assert.Equal(t, []string{"zk1:2181", "zk2:2181", "obs1:2181"}, opts.servers)
+	assert.Equal(t, []string{"obs1:2181"}, opts.observers)
+	assert.True(t, opts.isObserver("obs1:2181"))
+	assert.False(t, opts.isObserver("zk1:2181"))
+}
+
+func TestServerRoleShouldSelectServers(t *testing.T) {
+	opts := WithZookeepers([]string{"zk1:2181", "obs1:2181:observer", "obs2:2181"})(SessionOpts{})
+	opts = WithObservers("obs2:2181")(opts)
+
+	assert.Equal(t, opts.servers, opts.roleServers())
+	assert.Empty(t, opts.preferredServers())
+
+	participants := WithServerRole(ParticipantsOnly)(opts)
+	assert.Equal(t, []string{"zk1:2181"}, participants.roleServers())
+	assert.Equal(t, "zk1:2181", participants.serverList())
+
+	reads := WithServerRole(ReadPreferred)(opts)
+	assert.Equal(t, "obs1:2181,obs2:2181", reads.preferredServers())
+	assert.Equal(t, opts.servers, reads.roleServers())
+}
+
+func TestValidateShouldCheckServerRoles(t *testing.T) {
+	opts := SessionOpts{sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout}
+	opts = WithZookeepers([]string{"obs1:2181:observer"})(opts)
+	assert.NoError(t, opts.Validate())
+
+	assert.EqualError(t, WithServerRole(ParticipantsOnly)(opts).Validate(),
+		"invalid session options: participants-only server role needs a server that isn't an observer")
+	assert.EqualError(t, WithObservers("obs2:2181")(opts).Validate(),
+		`invalid session options: observer "obs2:2181" is not one of the configured servers`)
+
+	opts = WithZookeepers([]string{"zk1:2181"})(SessionOpts{sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout})
+	assert.EqualError(t, WithServerRole(ReadPreferred)(opts).Validate(),
+		"invalid session options: read-preferred server role needs observers")
+}
+
+func TestConfiguredServerShouldMatchAddresses(t *testing.T) {
+	opts := WithZookeepers([]string{"10.0.0.1:2181", "10.0.0.2:2181:observer"})(SessionOpts{})
+	assert.Equal(t, "10.0.0.2:2181", opts.configuredServer("10.0.0.2:2181"))
+	assert.True(t, opts.isObserver(opts.configuredServer("10.0.0.2:2181")))
+	assert.Equal(t, "10.0.0.9:2181", opts.configuredServer("10.0.0.9:2181"))
+	assert.False(t, opts.isObserver(opts.configuredServer("10.0.0.9:2181")))
+}
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/internal/eventbus"
+)
+
+// Router sends the operations on each path to the session owning the
+// subtree it is in, so that subtrees can live on different ensembles, such as
+// while they are migrated from one to another, without the application
+// knowing which. It implements Session, so recipes can use it unchanged.
+//
+// Paths are routed to the session of the longest prefix they are under, and
+// other paths to the fallback session. Each session only sees its own
+// subtrees: listing the parent of a routed prefix on the fallback doesn't
+// include the prefix's node unless it exists there too, and a recipe whose
+// nodes span several subtrees doesn't get ZooKeeper's ordering guarantees
+// across them.
+type Router struct {
+	fallback Session
+	// routes are sorted with the longest prefixes first, so the first
+	// match is the most specific.
+	routes   []route
+	sessions []Session
+	events   eventbus.Topic[ZKSessionEvent]
+}
+
+type route struct {
+	prefix  string
+	session Session
+}
+
+var _ Session = (*Router)(nil)
+
+// NewRouter returns a Router sending the paths under each prefix of routes to
+// its session, and the paths under none of them to fallback. Prefixes must be
+// absolute paths, and a session may own several of them.
+func NewRouter(fallback Session, routes map[string]Session) (*Router, error) {
+	r := &Router{fallback: fallback, sessions: []Session{fallback}}
+	for prefix, s := range routes {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("route prefix %q must be an absolute path", prefix)
+		}
+		if prefix == "/" {
+			return nil, fmt.Errorf("route prefix %q covers every path; route it as the fallback instead", prefix)
+		}
+		if s == nil {
+			return nil, fmt.Errorf("route prefix %q has no session", prefix)
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		for _, rt := range r.routes {
+			if rt.prefix == prefix {
+				return nil, fmt.Errorf("route prefix %q is given more than once", prefix)
+			}
+		}
+		r.routes = append(r.routes, route{prefix: prefix, session: s})
+		if !containsSession(r.sessions, s) {
+			r.sessions = append(r.sessions, s)
+		}
+	}
+	sort.Slice(r.routes, func(i, j int) bool {
+		return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+	})
+
+	for _, s := range r.sessions {
+		events := make(chan ZKSessionEvent)
+		s.Subscribe(events)
+		Go(s, "router", func() { r.forward(events) })
+	}
+	return r, nil
+}
+
+func containsSession(sessions []Session, s Session) bool {
+	for _, known := range sessions {
+		if known == s {
+			return true
+		}
+	}
+	return false
+}
+
+// forward passes a session's events on to subscribers.
+func (r *Router) forward(events <-chan ZKSessionEvent) {
+	for event := range events {
+		r.events.Publish(event)
+		if event == SessionClosed || event == SessionFailed || event == SessionExpired {
+			return
+		}
+	}
+}
+
+// SessionFor returns the session the operations on path are sent to.
+func (r *Router) SessionFor(path string) Session {
+	for _, rt := range r.routes {
+		if underPrefix(path, rt.prefix) {
+			return rt.session
+		}
+	}
+	return r.fallback
+}
+
+func (r *Router) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	return r.SessionFor(path).ACL(path)
+}
+
+// AddAuth adds the credentials to every session.
+func (r *Router) AddAuth(scheme, cert string) error {
+	for _, s := range r.sessions {
+		if err := s.AddAuth(scheme, cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Router) Children(path string) ([]string, *zookeeper.Stat, error) {
+	return r.SessionFor(path).Children(path)
+}
+
+func (r *Router) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.SessionFor(path).ChildrenW(path)
+}
+
+// ClientId returns the client ID of the fallback session.
+func (r *Router) ClientId() *zookeeper.ClientId {
+	return r.fallback.ClientId()
+}
+
+// Close closes every session, returning the first error.
+func (r *Router) Close() error {
+	var err error
+	for _, s := range r.sessions {
+		if closeErr := s.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (r *Router) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	return r.SessionFor(path).Create(path, value, flags, aclv)
+}
+
+func (r *Router) Delete(path string, version int) error {
+	return r.SessionFor(path).Delete(path, version)
+}
+
+func (r *Router) Exists(path string) (*zookeeper.Stat, error) {
+	return r.SessionFor(path).Exists(path)
+}
+
+func (r *Router) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.SessionFor(path).ExistsW(path)
+}
+
+func (r *Router) Get(path string) (string, *zookeeper.Stat, error) {
+	return r.SessionFor(path).Get(path)
+}
+
+func (r *Router) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return r.SessionFor(path).GetW(path)
+}
+
+func (r *Router) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	return r.SessionFor(path).Set(path, value, version)
+}
+
+func (r *Router) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	return r.SessionFor(path).RetryChange(path, flags, acl, changeFunc)
+}
+
+func (r *Router) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	return r.SessionFor(path).SetACL(path, aclv, version)
+}
+
+// Subscribe subscribes to the events of every session. Events don't tell
+// which session they come from, so a SessionDisconnected may only concern
+// some of the subtrees.
+func (r *Router) Subscribe(subscription chan<- ZKSessionEvent) {
+	r.events.Subscribe(subscription)
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterShouldRouteByLongestPrefix(t *testing.T) {
+	a, b, c := &stubSession{name: "a"}, &stubSession{name: "b"}, &stubSession{name: "c"}
+	r, err := NewRouter(a, map[string]Session{
+		"/discovery":        b,
+		"/config/":          c,
+		"/discovery/legacy": a,
+	})
+	assert.NoError(t, err)
+
+	for path, want := range map[string]string{
+		"/":                        "a",
+		"/other":                   "a",
+		"/discovery":               "b",
+		"/discovery/web":           "b",
+		"/discoveryx":              "a",
+		"/discovery/legacy/web":    "a",
+		"/config":                  "c",
+		"/config/features/enabled": "c",
+	} {
+		data, _, _ := r.Get(path)
+		assert.Equal(t, want, data, path)
+	}
+	assert.Len(t, r.sessions, 3)
+}
+
+func TestRouterShouldForwardEventsOfEverySession(t *testing.T) {
+	a, b := &stubSession{name: "a"}, &stubSession{name: "b"}
+	r, err := NewRouter(a, map[string]Session{"/config": b})
+	assert.NoError(t, err)
+	events := make(chan ZKSessionEvent, 2)
+	r.Subscribe(events)
+
+	b.events <- SessionDisconnected
+	assert.Equal(t, SessionDisconnected, <-events)
+	a.events <- SessionReconnected
+	assert.Equal(t, SessionReconnected, <-events)
+}
+
+func TestNewRouterShouldRejectInvalidRoutes(t *testing.T) {
+	a := &stubSession{name: "a"}
+	for _, routes := range []map[string]Session{
+		{"config": a},
+		{"/": a},
+		{"/config": nil},
+		{"/config": a, "/config/": a},
+	} {
+		_, err := NewRouter(a, routes)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,192 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRTTSampleInterval is how often WithAdaptiveTimeouts measures the
+	// round trip to the server unless given another interval.
+	DefaultRTTSampleInterval = 10 * time.Second
+
+	// minOperationTimeout bounds adaptive timeouts from below, so that a
+	// fast LAN doesn't make them trip on a garbage collection pause.
+	minOperationTimeout = 200 * time.Millisecond
+	// rttWeight and rttVarWeight are the weights of each sample in the
+	// smoothed round trip time and its variation, as for TCP's
+	// retransmission timeout.
+	rttWeight    = 0.125
+	rttVarWeight = 0.25
+)
+
+// RTTEstimate is the round trip to the connected server observed by
+// WithAdaptiveTimeouts.
+type RTTEstimate struct {
+	// Server is the server measured; the estimate starts over when the
+	// session reconnects.
+	Server string
+	// Smoothed is the moving average of the round trips, and Variation the
+	// moving average of their distance from it.
+	Smoothed  time.Duration
+	Variation time.Duration
+	Samples   int
+	// OperationTimeout is how long an operation can be expected to take at
+	// most; see ZKSession.OperationTimeout.
+	OperationTimeout time.Duration
+}
+
+// WithAdaptiveTimeouts measures the round trip to the connected server every
+// interval, DefaultRTTSampleInterval if zero, with a cheap Exists call, and
+// derives the timeouts the session uses internally, such as that of the
+// WithKeepalive ping, from it rather than from static defaults. It logs a
+// warning when the round trips make the session timeout too tight, as the
+// client must hear from the server within a third of it to keep the session
+// alive. See ZKSession.RTT.
+func WithAdaptiveTimeouts(interval time.Duration) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		if interval == 0 {
+			interval = DefaultRTTSampleInterval
+		}
+		so.rttInterval = interval
+		return so
+	}
+}
+
+// RTT returns the round trip to the connected server observed so far, and
+// false without WithAdaptiveTimeouts or before the first measurement.
+func (s *ZKSession) RTT() (RTTEstimate, bool) {
+	return s.rtt.estimate(s)
+}
+
+// OperationTimeout returns how long an operation can be expected to take at
+// most given the observed round trips, for callers setting their own
+// deadlines: the smoothed round trip plus four times its variation, no
+// shorter than 200ms and no longer than the session timeout. It is the
+// session timeout without WithAdaptiveTimeouts or measurements.
+func (s *ZKSession) OperationTimeout() time.Duration {
+	return s.operationTimeout(s.opts.sessionTimeout)
+}
+
+// operationTimeout returns the adaptive operation timeout, or fallback
+// without one.
+func (s *ZKSession) operationTimeout(fallback time.Duration) time.Duration {
+	if e, ok := s.rtt.estimate(s); ok {
+		return e.OperationTimeout
+	}
+	return fallback
+}
+
+// rttTracker follows the round trips measured by WithAdaptiveTimeouts. Like
+// debugState, a nil *rttTracker is valid and measures nothing.
+type rttTracker struct {
+	mu        sync.Mutex
+	server    string
+	smoothed  time.Duration
+	variation time.Duration
+	samples   int
+	tight     bool
+}
+
+func (r *rttTracker) estimate(s *ZKSession) (RTTEstimate, bool) {
+	if r == nil {
+		return RTTEstimate{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.samples == 0 {
+		return RTTEstimate{}, false
+	}
+	return RTTEstimate{
+		Server:           r.server,
+		Smoothed:         r.smoothed,
+		Variation:        r.variation,
+		Samples:          r.samples,
+		OperationTimeout: r.timeout(s.opts.sessionTimeout),
+	}, true
+}
+
+// timeout returns the operation timeout for the samples so far. r.mu must be
+// held.
+func (r *rttTracker) timeout(sessionTimeout time.Duration) time.Duration {
+	timeout := r.smoothed + 4*r.variation
+	if timeout < minOperationTimeout {
+		timeout = minOperationTimeout
+	}
+	if sessionTimeout > 0 && timeout > sessionTimeout {
+		timeout = sessionTimeout
+	}
+	return timeout
+}
+
+func (r *rttTracker) recordEvent(event ZKSessionEvent) {
+	if r == nil || (event != SessionReconnected && event != SessionExpiredReconnected) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The session may have landed on another server.
+	r.server, r.smoothed, r.variation, r.samples = "", 0, 0, 0
+}
+
+// record adds a round trip measured to server, and warns when the session
+// timeout becomes too tight for the round trips, or stops being.
+func (r *rttTracker) record(s *ZKSession, server string, rtt time.Duration) {
+	r.mu.Lock()
+	if r.samples == 0 || r.server != server {
+		r.server, r.smoothed, r.variation, r.samples = server, rtt, rtt/2, 0
+	} else {
+		diff := rtt - r.smoothed
+		if diff < 0 {
+			diff = -diff
+		}
+		r.variation += time.Duration(rttVarWeight * float64(diff-r.variation))
+		r.smoothed += time.Duration(rttWeight * float64(rtt-r.smoothed))
+	}
+	r.samples++
+
+	// The client heartbeats every third of the session timeout, and the
+	// session is at risk once a round trip may take longer than that.
+	expected := r.smoothed + 4*r.variation
+	tight := s.opts.sessionTimeout > 0 && expected > s.opts.sessionTimeout/3
+	changed := tight != r.tight
+	r.tight = tight
+	smoothed, variation := r.smoothed, r.variation
+	r.mu.Unlock()
+
+	switch {
+	case changed && tight:
+		s.log.Printf("gozk-recipes/session: session timeout %s is too tight for round trips of %s (±%s) to %s, consider at least %s",
+			s.opts.sessionTimeout, smoothed.Round(time.Millisecond), variation.Round(time.Millisecond), server, (3 * expected).Round(time.Second))
+	case changed:
+		s.log.Printf("gozk-recipes/session: round trips of %s to %s fit the session timeout %s again", smoothed.Round(time.Millisecond), server, s.opts.sessionTimeout)
+	}
+}
+
+// rttLoop measures the round trip to the server every interval configured
+// with WithAdaptiveTimeouts, until the session terminates.
+func (s *ZKSession) rttLoop() {
+	ticker := time.NewTicker(s.opts.rttInterval)
+	defer ticker.Stop()
+	for {
+		s.sampleRTT()
+		select {
+		case <-ticker.C:
+		case <-s.managed:
+			return
+		}
+	}
+}
+
+// sampleRTT measures a round trip to the connected server, if any.
+func (s *ZKSession) sampleRTT() {
+	server := s.CurrentServer()
+	if server == "" {
+		return
+	}
+	start := time.Now()
+	if err := s.ping(s.opts.sessionTimeout); err != nil {
+		return
+	}
+	s.rtt.record(s, server, time.Since(start))
+}
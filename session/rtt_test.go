@@ -0,0 +1,70 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRTTSession(sessionTimeout time.Duration) (*ZKSession, *recordingLogger) {
+	rec := &recordingLogger{}
+	opts := WithAdaptiveTimeouts(0)(SessionOpts{sessionTimeout: sessionTimeout})
+	return &ZKSession{log: rec, opts: opts, rtt: &rttTracker{}}, rec
+}
+
+func TestRTTShouldDeriveOperationTimeout(t *testing.T) {
+	s, _ := newRTTSession(5 * time.Second)
+	assert.Equal(t, 5*time.Second, s.OperationTimeout())
+
+	for i := 0; i < 50; i++ {
+		s.rtt.record(s, "zk1:2181", 300*time.Millisecond)
+	}
+	e, ok := s.RTT()
+	assert.True(t, ok)
+	assert.Equal(t, "zk1:2181", e.Server)
+	assert.Equal(t, 300*time.Millisecond, e.Smoothed)
+	assert.Equal(t, 50, e.Samples)
+	assert.Equal(t, 300*time.Millisecond+4*e.Variation, s.OperationTimeout())
+
+	// A fast network still leaves room for pauses.
+	s.rtt.record(s, "zk2:2181", time.Millisecond)
+	assert.Equal(t, minOperationTimeout, s.OperationTimeout())
+}
+
+func TestRTTShouldStartOverOnReconnect(t *testing.T) {
+	s, _ := newRTTSession(5 * time.Second)
+	s.rtt.record(s, "zk1:2181", 100*time.Millisecond)
+	s.rtt.recordEvent(SessionReconnected)
+	_, ok := s.RTT()
+	assert.False(t, ok)
+}
+
+func TestRTTShouldWarnWhenSessionTimeoutIsTight(t *testing.T) {
+	s, rec := newRTTSession(time.Second)
+	s.rtt.record(s, "zk1:2181", 50*time.Millisecond)
+	assert.Empty(t, rec.lines)
+
+	s.rtt.record(s, "zk1:2181", time.Second)
+	s.rtt.record(s, "zk1:2181", time.Second)
+	if assert.Len(t, rec.lines, 1) {
+		assert.Contains(t, rec.lines[0], "session timeout 1s is too tight")
+	}
+	// The estimate can't exceed the session timeout.
+	assert.Equal(t, time.Second, s.OperationTimeout())
+
+	for i := 0; i < 50; i++ {
+		s.rtt.record(s, "zk1:2181", 10*time.Millisecond)
+	}
+	if assert.Len(t, rec.lines, 2) {
+		assert.Contains(t, rec.lines[1], "fit the session timeout 1s again")
+	}
+}
+
+func TestRTTWithoutOptionShouldUseSessionTimeout(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}, opts: SessionOpts{sessionTimeout: time.Second}}
+	s.rtt.recordEvent(SessionReconnected)
+	_, ok := s.RTT()
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, s.OperationTimeout())
+}
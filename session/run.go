@@ -0,0 +1,24 @@
+package session
+
+import "context"
+
+// RunUntil implements the Run method of long-running recipes, which runs them
+// until ctx is done, in a way suitable for errgroup.Group. It starts the
+// recipe with start, returning its error if it fails, and waits. Once ctx is
+// done it stops the recipe with stop and returns ctx.Err(), or stop's error if
+// it fails. If the recipe stops by itself first, as when its session is
+// closed, closing done, RunUntil returns nil.
+func RunUntil(ctx context.Context, start func() error, done <-chan struct{}, stop func() error) error {
+	if err := start(); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		if err := stop(); err != nil {
+			return err
+		}
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
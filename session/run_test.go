@@ -0,0 +1,51 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUntilShouldStopWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started, stopped := false, false
+	start := func() error {
+		started = true
+		cancel()
+		return nil
+	}
+	stop := func() error {
+		stopped = true
+		return nil
+	}
+
+	err := RunUntil(ctx, start, make(chan struct{}), stop)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, started)
+	assert.True(t, stopped)
+}
+
+func TestRunUntilShouldReturnWhenRecipeStops(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	stop := func() error {
+		t.Error("stop called for a recipe that stopped by itself")
+		return nil
+	}
+
+	err := RunUntil(context.Background(), func() error { return nil }, done, stop)
+	assert.NoError(t, err)
+}
+
+func TestRunUntilShouldReturnStartAndStopErrors(t *testing.T) {
+	failure := errors.New("failure")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ok := func() error { return nil }
+	fail := func() error { return failure }
+
+	assert.ErrorIs(t, RunUntil(ctx, fail, nil, ok), failure)
+	assert.ErrorIs(t, RunUntil(ctx, ok, nil, fail), failure)
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// sequenceDigits is the width ZooKeeper zero-pads sequence suffixes to.
+const sequenceDigits = 10
+
+// CreateSequential creates a sequential node under the given path prefix and
+// returns the created path along with its parsed sequence number. The SEQUENCE
+// flag is added to flags, so only EPHEMERAL needs to be passed explicitly.
+func (s *ZKSession) CreateSequential(path string, value string, flags int, aclv []zookeeper.ACL) (string, int, error) {
+	created, err := s.Create(path, value, flags|zookeeper.SEQUENCE, aclv)
+	if err != nil {
+		return "", 0, err
+	}
+
+	seq, err := ParseSequence(created)
+	if err != nil {
+		return created, 0, err
+	}
+	return created, seq, nil
+}
+
+// ParseSequence returns the sequence number ZooKeeper appended to name, which
+// may be a full path or just the node name. Sequence numbers are signed 32 bit
+// counters, so they become negative once they roll over.
+//
+// A '-' just before the ten digit suffix is ambiguous when the node prefix
+// itself ends in '-'; it's only treated as a sign when the digits don't fit a
+// positive sequence.
+func ParseSequence(name string) (int, error) {
+	if len(name) < sequenceDigits {
+		return 0, fmt.Errorf("%q has no sequence suffix", name)
+	}
+
+	suffix := name[len(name)-sequenceDigits:]
+	seq, err := strconv.Atoi(suffix)
+	if err != nil || suffix[0] == '+' {
+		return 0, fmt.Errorf("%q has no sequence suffix", name)
+	}
+
+	// Negative values of ten digits or more don't fit in the padded width, so
+	// the sign ends up just before the suffix.
+	if seq > math.MaxInt32 && len(name) > sequenceDigits && name[len(name)-sequenceDigits-1] == '-' {
+		seq = -seq
+	}
+	if seq > math.MaxInt32 || seq < math.MinInt32 {
+		return 0, fmt.Errorf("%q has an out of range sequence suffix", name)
+	}
+	return seq, nil
+}
+
+// SequenceLess reports whether sequence a was issued before sequence b,
+// taking rollover into account: negative sequences come after positive ones.
+func SequenceLess(a, b int) bool {
+	return uint32(int32(a)) < uint32(int32(b))
+}
+
+// SortBySequence sorts sibling node names by the order in which their
+// sequence numbers were issued. Names without a valid sequence suffix are
+// sorted first, in lexical order.
+func SortBySequence(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		a, errA := ParseSequence(names[i])
+		b, errB := ParseSequence(names[j])
+		switch {
+		case errA != nil && errB != nil:
+			return names[i] < names[j]
+		case errA != nil:
+			return true
+		case errB != nil:
+			return false
+		}
+		return SequenceLess(a, b)
+	})
+}
+
+// SequentialChild is a child node named with a sequence suffix, as created
+// with the SEQUENCE flag.
+type SequentialChild struct {
+	Name     string
+	Sequence int
+	// Gap is how many sequence numbers were issued between the previous
+	// child's and this one's without a child still having them. It is zero
+	// for the first child.
+	Gap int
+}
+
+// SequentialChildren returns the children of path that have a sequence
+// suffix, in the order their sequence numbers were issued, along with path's
+// stat. Children without a suffix are left out.
+//
+// ZooKeeper draws the sequence numbers of all of a node's children from one
+// counter, the node's child version, which also advances when a child is
+// deleted or created without SEQUENCE. So a Gap means the children changed
+// between two sequential creations, as when a queue item was deleted, not
+// necessarily that a sequential child is missing, and a zero Gap means no
+// child was created or deleted in between.
+func SequentialChildren(s Session, path string) ([]SequentialChild, *zookeeper.Stat, error) {
+	children, stat, err := s.Children(path)
+	return ParseSequentialChildren(children), stat, err
+}
+
+// SequentialChildrenW is like SequentialChildren, and also leaves a watch on
+// path. The watch fires on any change to path's children.
+func SequentialChildrenW(s Session, path string) ([]SequentialChild, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	children, stat, watch, err := s.ChildrenW(path)
+	return ParseSequentialChildren(children), stat, watch, err
+}
+
+// ParseSequentialChildren orders the sibling node names that have a sequence
+// suffix as SequentialChildren does, for names listed some other way, such as
+// from a cache.
+func ParseSequentialChildren(names []string) []SequentialChild {
+	var children []SequentialChild
+	for _, name := range names {
+		if seq, err := ParseSequence(name); err == nil {
+			children = append(children, SequentialChild{Name: name, Sequence: seq})
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].Sequence == children[j].Sequence {
+			return children[i].Name < children[j].Name
+		}
+		return SequenceLess(children[i].Sequence, children[j].Sequence)
+	})
+	for i := 1; i < len(children); i++ {
+		// Subtracting as uint32 counts across rollover.
+		issued := uint32(int32(children[i].Sequence)) - uint32(int32(children[i-1].Sequence))
+		if issued > 1 {
+			children[i].Gap = int(issued - 1)
+		}
+	}
+	return children
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSequence(t *testing.T) {
+	cases := map[string]int{
+		"0000000000":             0,
+		"/locks/0000000042":      42,
+		"/queue/item-0000000007": 7,
+		"item--000000005":        -5,
+		"item--2147483648":       math.MinInt32,
+		"item-2147483647":        math.MaxInt32,
+		"item-2147483648":        math.MinInt32,
+	}
+
+	for name, expected := range cases {
+		seq, err := ParseSequence(name)
+		if err != nil {
+			t.Error("ParseSequence error: ", err)
+		}
+		assert.Equal(t, expected, seq, name)
+	}
+}
+
+func TestParseSequenceWithoutSuffixShouldFail(t *testing.T) {
+	for _, name := range []string{"", "lock", "/locks/abcdefghij", "item-+000000001", "item_2147483648"} {
+		if _, err := ParseSequence(name); err == nil {
+			t.Error("Expected error parsing sequence of ", name)
+		}
+	}
+}
+
+func TestSortBySequenceShouldHandleRollover(t *testing.T) {
+	names := []string{"n_-000000001", "n_2147483647", "n-2147483648", "n_0000000001", "garbage"}
+	SortBySequence(names)
+
+	assert.Equal(t, []string{"garbage", "n_0000000001", "n_2147483647", "n-2147483648", "n_-000000001"}, names)
+}
+
+func TestParseSequentialChildrenShouldDetectGaps(t *testing.T) {
+	children := ParseSequentialChildren([]string{"item-0000000004", "config", "item-0000000001", "item-0000000002", "item--000000001", "item-2147483647"})
+
+	var names []string
+	var gaps []int
+	for _, child := range children {
+		names = append(names, child.Name)
+		gaps = append(gaps, child.Gap)
+	}
+	assert.Equal(t, []string{"item-0000000001", "item-0000000002", "item-0000000004", "item-2147483647", "item--000000001"}, names)
+	assert.Equal(t, []int{0, 0, 1, math.MaxInt32 - 5, math.MaxInt32}, gaps)
+	assert.Equal(t, -1, children[4].Sequence)
+	assert.Empty(t, ParseSequentialChildren([]string{"config"}))
+}
@@ -1,13 +1,16 @@
 package session
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/internal/eventbus"
 )
 
 type ZKSessionEvent uint
@@ -26,8 +29,8 @@ func (l *nullLogger) Printf(format string, v ...interface{}) {}
 var ErrZKSessionNotConnected = errors.New("unable to connect to ZooKeeper")
 
 // ErrZKSessionDisconnected indicates the session *was* connected, but has
-// become disconnected in a way deemed unrecoverable.
-var ErrZKSessionDisconnected = errors.New("connection to ZooKeeper was lost")
+// become disconnected in a way deemed unrecoverable. It is an ErrSessionLost.
+var ErrZKSessionDisconnected = NewError("connection to ZooKeeper was lost", ErrSessionLost)
 
 const (
 	// SessionClosed is normally only returned as a direct result of calling Close() on the ZKSession object. It is a
@@ -45,37 +48,151 @@ const (
 	// event), but that the reconnection took longer than the session timeout, and all ephemeral nodes were purged.
 	SessionExpiredReconnected
 	// SessionFailed indicates that the session failed unrecoverably. This may mean incorrect credentials, or broken quorum,
-	// or a partition from the entire ZooKeeper cluster, or any other mode of absolute failure.
+	// or a partition from the entire ZooKeeper cluster, or any other mode of absolute failure. When the cause can be
+	// told apart, it is preceded by SessionFailedPartition, SessionFailedQuorumLoss or SessionFailedAuth.
 	SessionFailed
+	// SessionGated indicates that the connection flapped too often and operations fail with ErrSessionGated for a
+	// cool-off period. Only operations are gated: the client keeps reconnecting, and the session is kept. It is
+	// followed by SessionReconnected or SessionExpiredReconnected once the cool-off is over and the connection is up,
+	// or by SessionFailed.
+	SessionGated
+	// SessionExpired indicates that the session expired and, as WithNoAutoRedial was given, no new session will be
+	// established. All ephemeral nodes were purged. It is a terminal state.
+	SessionExpired
+	// SessionDegraded is delivered by FailoverSession when its primary goes down and reads move to the standby.
+	SessionDegraded
+	// SessionRestored is delivered by FailoverSession when its primary is back and reads return to it.
+	SessionRestored
+	// SessionFailedPartition is delivered just before SessionFailed when no server of the ensemble could be reached
+	// from this client, pointing at a network partition or a client-side problem rather than at ZooKeeper itself.
+	// It is only told apart with WithFailureDiagnostics.
+	SessionFailedPartition
+	// SessionFailedQuorumLoss is delivered just before SessionFailed when servers could be reached but none of them
+	// is serving requests, as when the ensemble has lost its quorum. It is only told apart with
+	// WithFailureDiagnostics, whose srvr check must be whitelisted on the servers.
+	SessionFailedQuorumLoss
+	// SessionFailedAuth is delivered just before SessionFailed when the session failed because its credentials
+	// were rejected.
+	SessionFailedAuth
 
 	DefaultRecvTimeout = 5 * time.Second
+
+	// DefaultSessionTimeout is the session timeout negotiated with the server
+	// unless WithSessionTimeout is given.
+	DefaultSessionTimeout = DefaultRecvTimeout
+	// DefaultConnectTimeout is how long to wait for the initial connection
+	// unless WithConnectTimeout is given.
+	DefaultConnectTimeout = 5 * time.Second
 )
 
+// Session is the set of ZooKeeper operations provided by ZKSession. Recipes
+// accepting a Session work with ZKSession as well as with decorators wrapping
+// it.
+type Session interface {
+	ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error)
+	AddAuth(scheme, cert string) error
+	Children(path string) ([]string, *zookeeper.Stat, error)
+	ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error)
+	ClientId() *zookeeper.ClientId
+	Close() error
+	Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error)
+	Delete(path string, version int) error
+	Exists(path string) (*zookeeper.Stat, error)
+	ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error)
+	Get(path string) (string, *zookeeper.Stat, error)
+	GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error)
+	Set(path string, value string, version int) (*zookeeper.Stat, error)
+	RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error
+	SetACL(path string, aclv []zookeeper.ACL, version int) error
+	Subscribe(subscription chan<- ZKSessionEvent)
+}
+
+var _ Session = (*ZKSession)(nil)
+
 type ZKSession struct {
+	// epoch, heartbeat, activity and hedges are first to keep them 64-bit
+	// aligned for atomic access.
+	epoch     uint64
+	heartbeat int64
+	activity  int64
+	hedges    uint64
+
+	// gated is set while operations are gated; see closeGate.
+	gated int32
+
 	opts   SessionOpts
 	conn   *zookeeper.Conn
 	events <-chan zookeeper.Event
 	mu     sync.Mutex
 
-	subscriptions []chan<- ZKSessionEvent
-	log           stdLogger
+	// sessionEvents and sequencedEvents are published to under mu, which
+	// also guards eventSeq, so both topics see events in the same order.
+	sessionEvents   eventbus.Topic[ZKSessionEvent]
+	sequencedEvents eventbus.Topic[SessionEvent]
+	eventSeq        uint64
+	recipeEvents    eventbus.Topic[RecipeEvent]
+	operationEvents eventbus.Topic[OperationEvent]
+	panics          eventbus.Topic[*PanicError]
+	affinityChanges eventbus.Topic[AffinityChange]
+	// affinity is the AffinityToken, guarded by mu.
+	affinity string
+	// sessionToken identifies the ZooKeeper session the manage loop last
+	// saw connected, as an AffinityToken; see sessionChanged.
+	sessionToken string
+
+	log       stdLogger
+	flapGate  *flapGate
+	inflight  *inflightLimiter
+	journal   *dryRunJournal
+	debug     *debugState
+	stats     *sessionStats
+	timeline  *timeline
+	pressure  *backpressure
+	rtt       *rttTracker
+	ownWrites *ownWrites
+	// childrenHedges holds the adaptive hedging of each WithChildrenHedging
+	// prefix.
+	childrenHedges []*childrenHedge
+	zxids          zxidTracker
+	// pinned is set while connected to the preferred servers only, the one
+	// given to WithPreferredServer or the observers for ReadPreferred. It is
+	// owned by the manage loop.
+	pinned bool
+	// observerAddrs caches whether each address the session connected to
+	// is an observer's; see OnObserver.
+	observerAddrs sync.Map
+
+	// reconnects carries Reconnect requests to the manage loop, which closes
+	// managed when it exits.
+	reconnects chan reconnectRequest
+	managed    chan struct{}
+
+	shutdown  shutdownList
+	callbacks *callbackPool
+
+	removeWatchesWarning sync.Once
 }
 
 func ResumeZKSession(servers string, recvTimeout time.Duration, logger stdLogger, clientId *zookeeper.ClientId) (*ZKSession, error) {
 	return NewSessionWithOpts(
 		WithLogger(logger),
 		WithZookeepers(strings.Split(servers, ",")),
-		WithRecvTimeout(recvTimeout),
+		WithSessionTimeout(recvTimeout),
 		WithZookeeperClientID(clientId),
 	)
 }
 
-func NewSessionWithOpts(opts ...SessionOpt) (*ZKSession, error) {
-	sessionOpts := SessionOpts{
-		logger:      &nullLogger{},
-		recvTimeout: DefaultRecvTimeout,
+// defaultSessionOpts returns the options NewSessionWithOpts starts from.
+func defaultSessionOpts() SessionOpts {
+	return SessionOpts{
+		logger:         &nullLogger{},
+		sessionTimeout: DefaultSessionTimeout,
+		connectTimeout: DefaultConnectTimeout,
 	}
+}
 
+func NewSessionWithOpts(opts ...SessionOpt) (*ZKSession, error) {
+	sessionOpts := defaultSessionOpts()
 	for _, so := range opts {
 		sessionOpts = so(sessionOpts)
 	}
@@ -85,7 +202,25 @@ func NewSessionWithOpts(opts ...SessionOpt) (*ZKSession, error) {
 		return nil, fmt.Errorf("creating zookeeper session: %w", err)
 	}
 
-	go session.manage()
+	manage := session.manage
+	if sessionOpts.watchdog > 0 {
+		session.beat(time.Now())
+		manage = session.supervise
+	}
+	Go(session, "manage", func() {
+		defer close(session.managed)
+		manage()
+	})
+	if sessionOpts.keepalive > 0 {
+		session.touch()
+		Go(session, "keepalive", session.keepaliveLoop)
+	}
+	if session.pressure != nil {
+		Go(session, "backpressure", session.backpressureLoop)
+	}
+	if session.rtt != nil {
+		Go(session, "rtt", session.rttLoop)
+	}
 
 	return session, nil
 }
@@ -94,7 +229,7 @@ func NewZKSession(servers string, recvTimeout time.Duration, logger stdLogger) (
 	return NewSessionWithOpts(
 		WithLogger(logger),
 		WithZookeepers(strings.Split(servers, ",")),
-		WithRecvTimeout(recvTimeout),
+		WithSessionTimeout(recvTimeout),
 	)
 }
 
@@ -113,29 +248,177 @@ func (s *ZKSession) SetServersResolutionDelay(delay time.Duration) {
 }
 
 func (s *ZKSession) Subscribe(subscription chan<- ZKSessionEvent) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.subscriptions = append(s.subscriptions, subscription)
+	s.sessionEvents.Subscribe(subscription)
+}
+
+// Events subscribes to session events and returns a channel that delivers
+// them, so that callers can simply range over it:
+//
+//	for ev := range s.Events(ctx) {
+//		...
+//	}
+//
+// The channel is closed, and the subscription removed, when ctx is done or
+// after a terminal SessionClosed, SessionFailed or SessionExpired event has been delivered.
+func (s *ZKSession) Events(ctx context.Context) <-chan ZKSessionEvent {
+	in := make(chan ZKSessionEvent)
+	out := make(chan ZKSessionEvent)
+	s.Subscribe(in)
+
+	Go(s, "events", func() {
+		defer close(out)
+		defer s.sessionEvents.Unsubscribe(in)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-in:
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event == SessionClosed || event == SessionFailed || event == SessionExpired {
+					return
+				}
+			}
+		}
+	})
+
+	return out
 }
 
 func (s *ZKSession) notifySubscribers(event ZKSessionEvent) {
+	s.publish(event, nil)
+}
+
+// publish delivers event, with diagnoses on the SessionEvent.
+func (s *ZKSession) publish(event ZKSessionEvent, diagnoses []ServerDiagnosis) {
+	s.debug.recordEvent(event)
+	s.stats.recordEvent(event)
+	s.pressure.recordEvent(s, event)
+	s.rtt.recordEvent(event)
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	for _, subscriber := range s.subscriptions {
-		subscriber <- event
+
+	s.eventSeq++
+	s.recordEvent(event)
+	rich := SessionEvent{Event: event, Seq: s.eventSeq, Time: time.Now(), Epoch: s.Epoch(), Diagnostics: diagnoses}
+	s.sessionEvents.Publish(event)
+	s.sequencedEvents.Publish(rich)
+	if event == SessionReconnected || event == SessionExpiredReconnected {
+		if change, ok := s.refreshAffinity(); ok {
+			s.affinityChanges.Publish(change)
+		}
 	}
 }
 
 func (s *ZKSession) manage() {
+	var beats <-chan time.Time
+	if s.opts.watchdog > 0 {
+		ticker := time.NewTicker(s.opts.watchdog / 4)
+		defer ticker.Stop()
+		beats = ticker.C
+	}
+
+	var recycle <-chan time.Time
+	var lifetime *time.Timer
+	if d := s.opts.lifetime(); d > 0 {
+		lifetime = time.NewTimer(d)
+		defer lifetime.Stop()
+		recycle = lifetime.C
+	}
+
 	expired := false
+	// connected tracks the connection while operations are gated, which
+	// gateOpens ends.
+	connected := true
+	var gateOpens <-chan time.Time
 	for {
 		select {
+		case now := <-beats:
+			s.beat(now)
+		case <-gateOpens:
+			gateOpens = nil
+			s.openGate()
+			s.log.Printf("gozk-recipes/session: cool-off over, operations no longer gated")
+			if connected {
+				if err := s.announceConnected(expired); err != nil {
+					s.fail(err)
+					return
+				}
+				expired = false
+			}
+		case <-recycle:
+			if err := s.recycle(); err != nil {
+				s.log.Printf("gozk-recipes/session: couldn't recycle session, keeping it: %v", err)
+				s.reportError("recycling session", err, false)
+				lifetime.Reset(s.opts.recycleRetry())
+				continue
+			}
+			lifetime.Reset(s.opts.lifetime())
+			if err := s.reestablished("session recycled"); err != nil {
+				s.fail(err)
+				return
+			}
+		case req := <-s.reconnects:
+			if req.expire {
+				s.notifySubscribers(SessionDisconnected)
+				s.log.Printf("gozk-recipes/session.SessionDisconnected: simulating session expiry")
+				if s.opts.noRedial {
+					s.closeConn()
+					atomic.AddUint64(&s.epoch, 1)
+					s.reportExpired("simulated session expiry")
+					req.done <- nil
+					return
+				}
+				err := s.expire()
+				if err == nil {
+					err = s.reestablished("simulated session expiry")
+				}
+				req.done <- err
+				if err != nil {
+					s.fail(err)
+					return
+				}
+				if lifetime != nil {
+					lifetime.Reset(s.opts.lifetime())
+				}
+				continue
+			}
+			servers, err := s.opts.hostListAvoiding(req.avoid)
+			if err != nil {
+				req.done <- err
+				continue
+			}
+			s.setServers(servers)
+			req.done <- s.opts.addAuth(s.conn)
 		case event := <-s.events:
+			s.recordState(event)
 			switch event.State {
 			case zookeeper.STATE_EXPIRED_SESSION:
 				s.log.Printf("gozk-recipes/session: got STATE_EXPIRED_SESSION for conn %+v", s.conn)
 				expired = true
-				conn, events, err := zookeeper.Redial(strings.Join(s.opts.servers, ","), s.opts.recvTimeout, s.opts.clientID)
+				atomic.AddUint64(&s.epoch, 1)
+				if s.opts.noRedial {
+					s.reportExpired("session expired")
+					return
+				}
+				if jitter := s.opts.connectJitter(); jitter > 0 {
+					s.beat(time.Now().Add(jitter))
+					time.Sleep(jitter)
+				}
+				conn, events, err := redial(s.opts.serverList(), s.opts.sessionTimeout, s.opts.clientID)
+				if err == nil {
+					if authErr := s.opts.addAuth(conn); authErr != nil {
+						s.log.Printf("gozk-recipes/session: %v", authErr)
+						if s.reportError("authenticating", authErr, true) {
+							_ = conn.Close()
+							err = authErr
+						}
+					}
+				}
 				if err == nil {
 					s.log.Printf("gozk-recipes/session: STATE_EXPIRED_SESSION redialed conn %+v", conn)
 					s.mu.Lock()
@@ -143,41 +426,79 @@ func (s *ZKSession) manage() {
 						err := s.conn.Close()
 						if err != nil {
 							s.log.Printf("gozk-recipes/session: error in closing existing zookeeper connection: %v", err)
+							s.reportError("closing connection", err, false)
 						}
 					}
 					s.conn = conn
 					s.events = events
 					s.opts = WithZookeeperClientID(conn.ClientId())(s.opts)
 					s.mu.Unlock()
+					s.recordRedial("new session after expiry")
 					s.log.Printf("gozk-recipes/session: session re-established with %s", s.conn.ConnectedServer())
+					if lifetime != nil {
+						lifetime.Reset(s.opts.lifetime())
+					}
 				}
 				if err != nil {
-					s.notifySubscribers(SessionFailed)
-					s.log.Printf("gozk-recipes/session.SessionFailed: %s, session terminated", err.Error())
+					s.fail(err)
 					return
 				}
 
 			case zookeeper.STATE_AUTH_FAILED:
-				s.notifySubscribers(SessionFailed)
-				s.log.Printf("gozk-recipes/session.SessionFailed: zookeeper.STATE_AUTH_FAILURE, session terminated")
+				s.fail(errStateAuthFailed)
 				return
 
 			case zookeeper.STATE_CONNECTING:
+				connected = false
+				if gateOpens != nil {
+					// Subscribers were told operations are gated.
+					continue
+				}
 				s.notifySubscribers(SessionDisconnected)
 				s.log.Printf("gozk-recipes/session.SessionDisconnected: attempting to reconnect")
 
+				if s.pinned {
+					s.pinned = false
+					s.log.Printf("gozk-recipes/session: lost preferred server %s, falling back to all servers", s.opts.preferredServers())
+					// The client reconnects to the full list, keeping the
+					// session.
+					s.setServers(s.opts.hostList())
+					continue
+				}
+
+				if coolOff, closed := s.flapGate.record(time.Now()); closed {
+					s.closeGate()
+					gateOpens = time.After(coolOff)
+					s.notifySubscribers(SessionGated)
+					s.log.Printf("gozk-recipes/session.SessionGated: connection flapping, failing operations for %s", coolOff)
+				}
+
 			case zookeeper.STATE_ASSOCIATING:
 				// No action to take, this is fine.
 
 			case zookeeper.STATE_CONNECTED:
-				if expired {
-					s.notifySubscribers(SessionExpiredReconnected)
-					s.log.Printf("gozk-recipes/session.SessionExpiredReconnected: all ephemeral nodes purged")
-					expired = false
-				} else {
-					s.notifySubscribers(SessionReconnected)
-					s.log.Printf("gozk-recipes/session.SessionReconnected: reconnected before timed out")
+				connected = true
+				if s.opts.monotonicReads && !s.awaitCaughtUp() {
+					s.log.Printf("gozk-recipes/session: server %s still behind zxid %d, reads may go back in time", s.conn.ConnectedServer(), s.LastZxid())
+				}
+
+				if s.sessionChanged() && !expired {
+					// Connected to a new session without seeing the old one
+					// expire, so its ephemeral nodes and watches are gone all
+					// the same.
+					atomic.AddUint64(&s.epoch, 1)
+					expired = true
+					s.log.Printf("gozk-recipes/session: connected to a new session, treating the old one as expired")
+				}
+				if gateOpens != nil {
+					// Announced once the cool-off is over.
+					continue
+				}
+				if err := s.announceConnected(expired); err != nil {
+					s.fail(err)
+					return
 				}
+				expired = false
 			case zookeeper.STATE_CLOSED:
 				s.notifySubscribers(SessionClosed)
 				s.log.Printf("gozk-recipes/session.SessionClosed: normally caused by call to Close(), session terminated")
@@ -187,62 +508,307 @@ func (s *ZKSession) manage() {
 	}
 }
 
+// announceConnected runs the reconnect hooks and tells subscribers that the
+// session is connected again, having expired in between if expired is set.
+func (s *ZKSession) announceConnected(expired bool) error {
+	if err := s.runReconnectHooks(expired); err != nil {
+		return err
+	}
+	if expired {
+		s.notifySubscribers(SessionExpiredReconnected)
+		s.log.Printf("gozk-recipes/session.SessionExpiredReconnected: all ephemeral nodes purged")
+	} else {
+		s.notifySubscribers(SessionReconnected)
+		s.log.Printf("gozk-recipes/session.SessionReconnected: reconnected before timed out")
+	}
+	return nil
+}
+
+// runReconnectHooks runs every reconnect hook, returning an error only if
+// strict mode makes a failed hook fatal.
+func (s *ZKSession) runReconnectHooks(expired bool) error {
+	for _, hook := range s.opts.onReconnect {
+		var err error
+		if perr := Protect(s, "reconnect hook", func() { err = hook(expired) }); perr != nil {
+			err = perr
+		} else if err != nil {
+			s.log.Printf("gozk-recipes/session: reconnect hook failed: %v", err)
+		}
+		if err != nil && s.reportError("running reconnect hook", err, true) {
+			return fmt.Errorf("reconnect hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
 func (s *ZKSession) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
-	return s.conn.ACL(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	r, err := s.do(Op{Name: "acl", Path: path}, func(op Op) (Result, error) {
+		acl, stat, err := s.conn.ACL(op.Path)
+		return Result{ACL: acl, Stat: stat}, err
+	})
+	s.zxids.observe(path, r.Stat)
+	return r.ACL, r.Stat, err
 }
 
 func (s *ZKSession) AddAuth(scheme, cert string) error {
-	return s.conn.AddAuth(scheme, cert)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	_, err := s.do(Op{Name: "add_auth", Scheme: scheme, Data: cert}, func(op Op) (Result, error) {
+		return Result{}, s.conn.AddAuth(op.Scheme, op.Data)
+	})
+	return err
 }
 
 func (s *ZKSession) Children(path string) ([]string, *zookeeper.Stat, error) {
-	return s.conn.Children(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	r, err := s.do(Op{Name: "children", Path: path}, func(op Op) (Result, error) {
+		return hedgedListing(s, path, func() (Result, error) {
+			children, stat, err := s.conn.Children(op.Path)
+			return Result{Children: children, Stat: stat}, err
+		})
+	})
+	s.zxids.observe(path, r.Stat)
+	if s.journal != nil && zookeeper.IsError(err, zookeeper.ZNONODE) && s.journal.isCreated(path) {
+		err = nil
+	}
+	if s.journal != nil && err == nil {
+		r.Children = s.journal.withCreated(path, r.Children)
+	}
+	return r.Children, r.Stat, err
 }
 
 func (s *ZKSession) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
-	return s.conn.ChildrenW(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	r, err := s.do(Op{Name: "children_w", Path: path}, func(op Op) (Result, error) {
+		children, stat, watch, err := s.conn.ChildrenW(op.Path)
+		return Result{Children: children, Stat: stat, Watch: watch}, err
+	})
+	s.zxids.observe(path, r.Stat)
+	return r.Children, r.Stat, s.trackWatch(path, "children", r.Stat, r.Watch), err
 }
 
 func (s *ZKSession) ClientId() *zookeeper.ClientId {
 	return s.conn.ClientId()
 }
 
+// Close shuts down the components registered with Register, then closes the
+// connection. Callbacks still queued for the pool configured with
+// WithCallbackPool are dropped.
 func (s *ZKSession) Close() error {
-	return s.conn.Close()
+	s.closeRegistered()
+	s.callbacks.close()
+	unregister(s)
+	err := s.conn.Close()
+	if terr := s.timeline.close(); terr != nil && err == nil {
+		err = terr
+	}
+	return err
 }
 
 func (s *ZKSession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
-	return s.conn.Create(path, value, flags, aclv)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+
+	if _, err := ModeFromFlags(flags); err != nil {
+		return "", fmt.Errorf("creating %q: %w", path, err)
+	}
+	if err := s.checkPathLimits(path, flags); err != nil {
+		return "", err
+	}
+	if err := s.validateWrite(path, value); err != nil {
+		return "", err
+	}
+	value, err := s.encodeValue(path, value)
+	if err != nil {
+		return "", err
+	}
+	if s.journal != nil {
+		return s.dryRunCreate(path, value, flags, aclv)
+	}
+	write := "create"
+	if flags&zookeeper.SEQUENCE != 0 {
+		write = "sequential"
+	}
+	s.beginOwnWrite(path, write)
+	r, err := s.do(Op{Name: "create", Path: path, Data: value, Flags: flags, ACL: aclv}, func(op Op) (Result, error) {
+		created, err := s.conn.Create(op.Path, op.Data, op.Flags, op.ACL)
+		return Result{Data: created}, err
+	})
+	s.endOwnWrite(path, write, nil, err)
+	return r.Data, err
 }
 
 func (s *ZKSession) Delete(path string, version int) error {
-	return s.conn.Delete(path, version)
+	if err := s.deleteProtected(path); err != nil {
+		return err
+	}
+	return s.delete(path, version)
+}
+
+func (s *ZKSession) delete(path string, version int) error {
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	if s.journal != nil {
+		return s.dryRunDelete(path, version)
+	}
+	s.beginOwnWrite(path, "delete")
+	_, err := s.do(Op{Name: "delete", Path: path, Version: version}, func(op Op) (Result, error) {
+		return Result{}, s.conn.Delete(op.Path, op.Version)
+	})
+	s.endOwnWrite(path, "delete", nil, err)
+	return err
 }
 
 func (s *ZKSession) Exists(path string) (*zookeeper.Stat, error) {
-	return s.conn.Exists(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	r, err := s.do(Op{Name: "exists", Path: path}, func(op Op) (Result, error) {
+		return hedgedStat(s, path, func() (Result, error) {
+			stat, err := s.conn.Exists(op.Path)
+			return Result{Stat: stat}, err
+		})
+	})
+	s.zxids.observe(path, r.Stat)
+	return r.Stat, err
 }
 
 func (s *ZKSession) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
-	return s.conn.ExistsW(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	r, err := s.do(Op{Name: "exists_w", Path: path}, func(op Op) (Result, error) {
+		stat, watch, err := s.conn.ExistsW(op.Path)
+		return Result{Stat: stat, Watch: watch}, err
+	})
+	s.zxids.observe(path, r.Stat)
+	return r.Stat, s.trackWatch(path, "exists", r.Stat, r.Watch), err
 }
 
 func (s *ZKSession) Get(path string) (string, *zookeeper.Stat, error) {
-	return s.conn.Get(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+
+	r, err := s.do(Op{Name: "get", Path: path}, func(op Op) (Result, error) {
+		return hedged(s, func() (Result, error) {
+			value, stat, err := s.conn.Get(op.Path)
+			return Result{Data: value, Stat: stat}, err
+		})
+	})
+	s.zxids.observe(path, r.Stat)
+	if err != nil {
+		return r.Data, r.Stat, err
+	}
+	value, err := s.decodeValue(path, r.Data)
+	if err == nil {
+		err = s.validateRead(path, value)
+	}
+	if err != nil {
+		return "", r.Stat, err
+	}
+	return value, r.Stat, nil
 }
 
 func (s *ZKSession) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
-	return s.conn.GetW(path)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+
+	r, err := s.do(Op{Name: "get_w", Path: path}, func(op Op) (Result, error) {
+		value, stat, watch, err := s.conn.GetW(op.Path)
+		return Result{Data: value, Stat: stat, Watch: watch}, err
+	})
+	s.zxids.observe(path, r.Stat)
+	watch := s.trackWatch(path, "data", r.Stat, r.Watch)
+	if err != nil {
+		return r.Data, r.Stat, watch, err
+	}
+	value, err := s.decodeValue(path, r.Data)
+	if err == nil {
+		err = s.validateRead(path, value)
+	}
+	if err != nil {
+		return "", r.Stat, watch, err
+	}
+	return value, r.Stat, watch, nil
 }
 
 func (s *ZKSession) Set(path string, value string, version int) (*zookeeper.Stat, error) {
-	return s.conn.Set(path, value, version)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+
+	if err := s.validateWrite(path, value); err != nil {
+		return nil, err
+	}
+	value, err := s.encodeValue(path, value)
+	if err != nil {
+		return nil, err
+	}
+	if s.journal != nil {
+		return s.dryRunSet(path, value, version)
+	}
+	s.beginOwnWrite(path, "set")
+	r, err := s.do(Op{Name: "set", Path: path, Data: value, Version: version}, func(op Op) (Result, error) {
+		stat, err := s.conn.Set(op.Path, op.Data, op.Version)
+		return Result{Stat: stat}, err
+	})
+	s.zxids.observe(path, r.Stat)
+	s.endOwnWrite(path, "set", r.Stat, err)
+	return r.Stat, err
 }
 
 func (s *ZKSession) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
-	return s.conn.RetryChange(path, flags, acl, changeFunc)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	change := func(oldValue string, oldStat *zookeeper.Stat) (string, error) {
+		oldValue, err := s.decodeValue(path, oldValue)
+		if err != nil {
+			return "", err
+		}
+		// The slot is given up while changeFunc runs, so that it can use
+		// the session without waiting for a slot held by its own caller.
+		s.inflight.release()
+		newValue, err := changeFunc(oldValue, oldStat)
+		s.inflight.acquire()
+		if err != nil {
+			return newValue, err
+		}
+		if err := s.validateWrite(path, newValue); err != nil {
+			return "", err
+		}
+		return s.encodeValue(path, newValue)
+	}
+	if s.journal != nil {
+		return s.dryRunRetryChange(path, flags, acl, change)
+	}
+	_, err := s.do(Op{Name: "retry_change", Path: path, Flags: flags, ACL: acl}, func(op Op) (Result, error) {
+		return Result{}, s.conn.RetryChange(op.Path, op.Flags, op.ACL, change)
+	})
+	return err
 }
 
 func (s *ZKSession) SetACL(path string, aclv []zookeeper.ACL, version int) error {
-	return s.conn.SetACL(path, aclv, version)
+	s.inflight.acquire()
+	s.touch()
+	defer s.inflight.release()
+	if s.journal != nil {
+		return s.dryRunSetACL(path, aclv, version)
+	}
+	_, err := s.do(Op{Name: "set_acl", Path: path, ACL: aclv, Version: version}, func(op Op) (Result, error) {
+		return Result{}, s.conn.SetACL(op.Path, op.ACL, op.Version)
+	})
+	return err
 }
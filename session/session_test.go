@@ -1,12 +1,16 @@
 package session
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"testing"
 	"time"
 
 	zookeeper "github.com/Shopify/gozk"
-	"github.com/Shopify/gozk-recipes/test"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
 )
 
 func invalidClientId(t *testing.T) *zookeeper.ClientId {
@@ -24,10 +28,10 @@ func invalidClientId(t *testing.T) *zookeeper.ClientId {
 }
 
 func TestReceiveEventWhenSubscribing(t *testing.T) {
-	proxy := test.CreateProxy(t)
+	proxy := zkenv.CreateProxy(t)
 	defer proxy.Delete()
 
-	store, err := NewZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, nil)
+	store, err := NewZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil)
 	if err != nil {
 		t.Error("Failed to connect to Zookeeper: ", err)
 	}
@@ -73,10 +77,10 @@ func TestReceiveEventWhenSubscribing(t *testing.T) {
 }
 
 func TestResumeZKSessionWithValidSession(t *testing.T) {
-	proxy := test.CreateProxy(t)
+	proxy := zkenv.CreateProxy(t)
 	defer proxy.Delete()
 
-	store, err := NewZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, log.Default())
+	store, err := NewZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, log.Default())
 	if err != nil {
 		t.Error("Failed to connect to Zookeeper: ", err)
 	}
@@ -95,7 +99,7 @@ func TestResumeZKSessionWithValidSession(t *testing.T) {
 	}
 
 	//ResumeZKSession is expected to automatically close any previously existing sessions.
-	resumeStore, err := ResumeZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, nil, clientId)
+	resumeStore, err := ResumeZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil, clientId)
 	if err != nil {
 		t.Error("Failed to resume session with Zookeeper: ", err)
 	}
@@ -112,22 +116,22 @@ func TestResumeZKSessionWithValidSession(t *testing.T) {
 }
 
 func TestResumeZKSessionFailsWithInvalidClientId(t *testing.T) {
-	proxy := test.CreateProxy(t)
+	proxy := zkenv.CreateProxy(t)
 	defer proxy.Delete()
 
 	invalidClientId := invalidClientId(t)
 
-	_, err := ResumeZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, nil, invalidClientId)
+	_, err := ResumeZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil, invalidClientId)
 	if err == nil {
 		t.Error("Resumed session with Zookeeper using incorrect clientId.")
 	}
 }
 
 func TestResumeZKSessionWithInvalidClientIdDoesNotDisconnectExistingSession(t *testing.T) {
-	proxy := test.CreateProxy(t)
+	proxy := zkenv.CreateProxy(t)
 	defer proxy.Delete()
 
-	store, err := NewZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, nil)
+	store, err := NewZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil)
 	if err != nil {
 		t.Error("Failed to connect to Zookeeper: ", err)
 	}
@@ -138,7 +142,7 @@ func TestResumeZKSessionWithInvalidClientIdDoesNotDisconnectExistingSession(t *t
 
 	invalidClientId := invalidClientId(t)
 
-	_, err = ResumeZKSession(test.GetToxiProxyHost(t)+":"+test.PROXY_PORT, 200*time.Millisecond, nil, invalidClientId)
+	_, err = ResumeZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil, invalidClientId)
 	if err == nil {
 		t.Error("Resumed session with Zookeeper using incorrect clientId.")
 	}
@@ -152,3 +156,74 @@ func TestResumeZKSessionWithInvalidClientIdDoesNotDisconnectExistingSession(t *t
 		t.Log("Existing session was not disconnected by ResumeZKSession with invalid clientId")
 	}
 }
+
+func TestEventsClosesWhenContextIsDone(t *testing.T) {
+	proxy := zkenv.CreateProxy(t)
+	defer proxy.Delete()
+
+	store, err := NewZKSession(zkenv.GetToxiProxyHost(t)+":"+zkenv.PROXY_PORT, 200*time.Millisecond, nil)
+	if err != nil {
+		t.Error("Failed to connect to Zookeeper: ", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := store.Events(ctx)
+
+	go func() {
+		err := proxy.Disable()
+		if err != nil {
+			t.Error("Failed to disable proxy: ", err)
+		}
+
+		_, _, err = store.Children("/")
+		if err == nil {
+			t.Error("Expected error when listing children")
+		}
+
+		err = proxy.Enable()
+		if err != nil {
+			t.Error("Failed to enable proxy: ", err)
+		}
+	}()
+
+	select {
+	case event := <-events:
+		if event != SessionDisconnected {
+			t.Error("Expected to receive disconnected: ", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Failed to receive event")
+	}
+
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Error("Expected events channel to be closed")
+			return
+		}
+	}
+}
+
+func TestReconnectHooksShouldRunInOrder(t *testing.T) {
+	var calls []string
+	opts := WithOnReconnect(func(expired bool) error {
+		calls = append(calls, fmt.Sprintf("first %v", expired))
+		return errors.New("boom")
+	})(SessionOpts{})
+	opts = WithOnReconnect(func(expired bool) error {
+		calls = append(calls, fmt.Sprintf("second %v", expired))
+		return nil
+	})(opts)
+
+	s := &ZKSession{opts: opts, log: &nullLogger{}}
+	s.runReconnectHooks(true)
+	assert.Equal(t, []string{"first true", "second true"}, calls)
+}
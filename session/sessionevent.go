@@ -0,0 +1,22 @@
+package session
+
+import "time"
+
+// SessionEvent is a ZKSessionEvent with delivery metadata. Seq increases by
+// one with every event the session delivers, starting at 1, so a subscriber
+// can tell whether it missed any; Time is when the event was delivered, for
+// correlating with server logs. Diagnostics holds the findings of the sweep
+// run before a SessionFailed event when WithFailureDiagnostics is given.
+type SessionEvent struct {
+	Event       ZKSessionEvent
+	Seq         uint64
+	Time        time.Time
+	Epoch       uint64
+	Diagnostics []ServerDiagnosis
+}
+
+// SubscribeEvents is like Subscribe, delivering events with their sequence
+// number and timestamp.
+func (s *ZKSession) SubscribeEvents(subscription chan<- SessionEvent) {
+	s.sequencedEvents.Subscribe(subscription)
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionEventsShouldBeSequenced(t *testing.T) {
+	s := &ZKSession{}
+	events := make(chan SessionEvent, 2)
+	s.SubscribeEvents(events)
+
+	s.notifySubscribers(SessionDisconnected)
+	s.notifySubscribers(SessionReconnected)
+
+	first, second := <-events, <-events
+	assert.Equal(t, SessionDisconnected, first.Event)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, SessionReconnected, second.Event)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.False(t, second.Time.Before(first.Time))
+}
+
+func TestAttachShouldPublishRecipeEvents(t *testing.T) {
+	s := &ZKSession{}
+	events := make(chan RecipeEvent, 2)
+	s.SubscribeRecipes(events)
+
+	detach := Attach(s, "lock", "/locks/a")
+	detach()
+	detach()
+
+	attached, detached := <-events, <-events
+	assert.Equal(t, "lock", attached.Recipe)
+	assert.True(t, attached.Attached)
+	assert.Equal(t, "/locks/a", detached.Detail)
+	assert.False(t, detached.Attached)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// ErrNoShards is returned by NewShardedSession when given no shards.
+var ErrNoShards = errors.New("sharded session needs at least one shard")
+
+// ShardKeyFunc maps a path to the key used to pick its shard.
+type ShardKeyFunc func(path string) string
+
+// TopLevelShardKey shards by the first path component, so that every node in
+// a subtree such as /locks/... is handled by the same session. ZooKeeper only
+// orders operations within a session, so recipes that write a node and then
+// list its parent must keep both on one shard to see their own writes.
+func TopLevelShardKey(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// ShardedSession spreads operations over several sessions, picking one by
+// hashing each operation's path, for clients that saturate a single
+// connection. It implements Session, so recipes can use it unchanged.
+type ShardedSession struct {
+	shards []Session
+	key    ShardKeyFunc
+}
+
+var _ Session = (*ShardedSession)(nil)
+
+// NewShardedSession routes operations over shards, which should all be
+// connected to the same ensemble. Paths are sharded with key, or
+// TopLevelShardKey if it's nil. It returns ErrNoShards if there are none.
+func NewShardedSession(key ShardKeyFunc, shards ...Session) (*ShardedSession, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+	for i, shard := range shards {
+		if shard == nil {
+			return nil, fmt.Errorf("shard %d has no session", i)
+		}
+	}
+	if key == nil {
+		key = TopLevelShardKey
+	}
+	return &ShardedSession{shards: shards, key: key}, nil
+}
+
+// Shard returns the session handling path.
+func (s *ShardedSession) Shard(path string) Session {
+	h := fnv.New32a()
+	h.Write([]byte(s.key(path)))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *ShardedSession) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	return s.Shard(path).ACL(path)
+}
+
+// AddAuth adds the credentials to every shard.
+func (s *ShardedSession) AddAuth(scheme, cert string) error {
+	for _, shard := range s.shards {
+		if err := shard.AddAuth(scheme, cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedSession) Children(path string) ([]string, *zookeeper.Stat, error) {
+	return s.Shard(path).Children(path)
+}
+
+func (s *ShardedSession) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return s.Shard(path).ChildrenW(path)
+}
+
+// ClientId returns the client ID of the first shard.
+func (s *ShardedSession) ClientId() *zookeeper.ClientId {
+	return s.shards[0].ClientId()
+}
+
+// Close closes every shard, returning the first error.
+func (s *ShardedSession) Close() error {
+	var first error
+	for _, shard := range s.shards {
+		if err := shard.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (s *ShardedSession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	return s.Shard(path).Create(path, value, flags, aclv)
+}
+
+func (s *ShardedSession) Delete(path string, version int) error {
+	return s.Shard(path).Delete(path, version)
+}
+
+func (s *ShardedSession) Exists(path string) (*zookeeper.Stat, error) {
+	return s.Shard(path).Exists(path)
+}
+
+func (s *ShardedSession) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return s.Shard(path).ExistsW(path)
+}
+
+func (s *ShardedSession) Get(path string) (string, *zookeeper.Stat, error) {
+	return s.Shard(path).Get(path)
+}
+
+func (s *ShardedSession) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	return s.Shard(path).GetW(path)
+}
+
+func (s *ShardedSession) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	return s.Shard(path).Set(path, value, version)
+}
+
+func (s *ShardedSession) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	return s.Shard(path).RetryChange(path, flags, acl, changeFunc)
+}
+
+func (s *ShardedSession) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	return s.Shard(path).SetACL(path, aclv, version)
+}
+
+// Subscribe subscribes to events from every shard. Events aren't tagged with
+// their shard.
+func (s *ShardedSession) Subscribe(subscription chan<- ZKSessionEvent) {
+	for _, shard := range s.shards {
+		shard.Subscribe(subscription)
+	}
+}
@@ -0,0 +1,36 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopLevelShardKey(t *testing.T) {
+	assert.Equal(t, "locks", TopLevelShardKey("/locks/foo/lock-0000000001"))
+	assert.Equal(t, "locks", TopLevelShardKey("/locks"))
+	assert.Equal(t, "", TopLevelShardKey("/"))
+}
+
+func TestShardedSessionShouldRouteSubtreesTogether(t *testing.T) {
+	shards := []Session{&ZKSession{}, &ZKSession{}, &ZKSession{}, &ZKSession{}}
+	s, err := NewShardedSession(nil, shards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Same(t, s.Shard("/locks"), s.Shard("/locks/foo/bar"))
+
+	used := map[Session]bool{}
+	for _, root := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h"} {
+		used[s.Shard(root)] = true
+	}
+	assert.True(t, len(used) > 1)
+}
+
+func TestNewShardedSessionShouldRequireShards(t *testing.T) {
+	_, err := NewShardedSession(nil)
+	assert.ErrorIs(t, err, ErrNoShards)
+	_, err = NewShardedSession(nil, &ZKSession{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long Close waits for each registered closer,
+// unless WithShutdownTimeout or RegisterWithTimeout is given.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// CloserFunc adapts a function to io.Closer, for registering recipes whose
+// Close doesn't return an error.
+type CloserFunc func() error
+
+func (f CloserFunc) Close() error { return f() }
+
+type registeredCloser struct {
+	id      int
+	closer  io.Closer
+	timeout time.Duration
+}
+
+// shutdownList holds the closers Close runs before closing the connection.
+type shutdownList struct {
+	mu      sync.Mutex
+	nextID  int
+	closers []registeredCloser
+}
+
+// Register adds c to the components closed by Close before the connection is
+// closed, so they can still use the session while shutting down. Components
+// are closed one at a time, most recently registered first, each given the
+// shutdown timeout to return. The returned function removes c again, and
+// should be called when c is closed by other means.
+func (s *ZKSession) Register(c io.Closer) (unregister func()) {
+	return s.RegisterWithTimeout(c, 0)
+}
+
+// RegisterWithTimeout is like Register, giving c timeout to close instead of
+// the session's shutdown timeout.
+func (s *ZKSession) RegisterWithTimeout(c io.Closer, timeout time.Duration) (unregister func()) {
+	l := &s.shutdown
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	id := l.nextID
+	l.closers = append(l.closers, registeredCloser{id: id, closer: c, timeout: timeout})
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, rc := range l.closers {
+			if rc.id == id {
+				l.closers = append(l.closers[:i], l.closers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// RegisterCloser registers c with s if it is a ZKSession; see
+// ZKSession.Register. Recipes call it when started, so that closing the
+// session shuts them down before the connection goes away.
+func RegisterCloser(s Session, c io.Closer) (unregister func()) {
+	r, ok := s.(interface {
+		Register(io.Closer) func()
+	})
+	if !ok {
+		return func() {}
+	}
+	return r.Register(c)
+}
+
+// closeRegistered closes the registered components in reverse registration
+// order. A component that fails or doesn't return in time is logged and left
+// behind.
+func (s *ZKSession) closeRegistered() {
+	l := &s.shutdown
+	l.mu.Lock()
+	closers := l.closers
+	l.closers = nil
+	l.mu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		rc := closers[i]
+		timeout := rc.timeout
+		if timeout <= 0 {
+			timeout = s.opts.shutdownTimeout
+		}
+		if timeout <= 0 {
+			timeout = DefaultShutdownTimeout
+		}
+
+		done := make(chan error, 1)
+		Go(s, "shutdown", func() {
+			var err error
+			if perr := Protect(s, "shutdown", func() { err = rc.closer.Close() }); perr != nil {
+				err = perr
+			}
+			done <- err
+		})
+
+		timer := time.NewTimer(timeout)
+		select {
+		case err := <-done:
+			if err != nil {
+				s.log.Printf("gozk-recipes/session: closing %T: %v", rc.closer, err)
+				s.reportError("shutting down", err, false)
+			}
+		case <-timer.C:
+			s.log.Printf("gozk-recipes/session: closing %T timed out after %v", rc.closer, timeout)
+			s.reportError("shutting down", fmt.Errorf("closing %T timed out after %v", rc.closer, timeout), false)
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseRegisteredShouldCloseInReverseOrder(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+
+	var closed []string
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		s.Register(CloserFunc(func() error {
+			closed = append(closed, name)
+			return nil
+		}))
+	}
+	unregister := s.Register(CloserFunc(func() error {
+		closed = append(closed, "removed")
+		return nil
+	}))
+	unregister()
+
+	s.closeRegistered()
+	assert.Equal(t, []string{"third", "second", "first"}, closed)
+
+	s.closeRegistered()
+	assert.Len(t, closed, 3)
+}
+
+func TestCloseRegisteredShouldNotWaitPastTimeout(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+
+	release := make(chan struct{})
+	defer close(release)
+	var closedAfter bool
+	s.Register(CloserFunc(func() error {
+		closedAfter = true
+		return errors.New("failed")
+	}))
+	s.RegisterWithTimeout(CloserFunc(func() error {
+		<-release
+		return nil
+	}), 10*time.Millisecond)
+
+	start := time.Now()
+	s.closeRegistered()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, closedAfter)
+}
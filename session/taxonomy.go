@@ -0,0 +1,79 @@
+package session
+
+import (
+	"errors"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// The error kinds shared by the recipes. Recipe errors match the kinds that
+// describe them with errors.Is, alongside their own sentinel, so applications
+// can handle, say, every lost ownership the same way whichever recipe
+// reported it. Classify maps ZooKeeper's own errors onto the same kinds.
+var (
+	// ErrNotOwner means the caller acted on something it doesn't own, or no
+	// longer owns.
+	ErrNotOwner = errors.New("not the owner")
+	// ErrTimeout means an operation didn't complete in time.
+	ErrTimeout = errors.New("timed out")
+	// ErrSessionLost means the session's connection or the session itself
+	// went away, taking ephemeral nodes and watches with it.
+	ErrSessionLost = errors.New("session lost")
+	// ErrAlreadyExists means something the caller tried to create or claim
+	// is already there.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrRevoked means something the caller held, such as leadership or
+	// ownership, was taken away.
+	ErrRevoked = errors.New("revoked")
+)
+
+// kindError is an error matching one or more error kinds.
+type kindError struct {
+	msg   string
+	kinds []error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Is(target error) bool {
+	for _, kind := range e.kinds {
+		if target == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NewError returns an error with message msg that matches each of kinds with
+// errors.Is, for recipes declaring sentinel errors within the shared kinds.
+func NewError(msg string, kinds ...error) error {
+	return &kindError{msg: msg, kinds: kinds}
+}
+
+// Classify returns the kind of err: ErrNotOwner, ErrTimeout, ErrSessionLost,
+// ErrAlreadyExists or ErrRevoked, or nil if it is none of them. ZooKeeper
+// errors are classified by their code.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, kind := range []error{ErrNotOwner, ErrTimeout, ErrSessionLost, ErrAlreadyExists, ErrRevoked} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+
+	var zkErr *zookeeper.Error
+	if !errors.As(err, &zkErr) {
+		return nil
+	}
+	switch zkErr.Code {
+	case zookeeper.ZNODEEXISTS:
+		return ErrAlreadyExists
+	case zookeeper.ZOPERATIONTIMEOUT:
+		return ErrTimeout
+	case zookeeper.ZCONNECTIONLOSS, zookeeper.ZSESSIONEXPIRED, zookeeper.ZSESSIONMOVED, zookeeper.ZCLOSING:
+		return ErrSessionLost
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewErrorShouldMatchItsKinds(t *testing.T) {
+	errStolen := NewError("lease stolen", ErrNotOwner, ErrRevoked)
+	wrapped := fmt.Errorf("renewing lease: %w", errStolen)
+
+	assert.ErrorIs(t, wrapped, errStolen)
+	assert.ErrorIs(t, wrapped, ErrNotOwner)
+	assert.ErrorIs(t, wrapped, ErrRevoked)
+	assert.False(t, errors.Is(wrapped, ErrTimeout))
+	assert.Equal(t, "renewing lease: lease stolen", wrapped.Error())
+	assert.Equal(t, ErrNotOwner, Classify(wrapped))
+}
+
+func TestClassifyShouldMapZooKeeperErrors(t *testing.T) {
+	zkErr := func(code zookeeper.ErrorCode) error {
+		return fmt.Errorf("creating /a: %w", &zookeeper.Error{Op: "create", Code: code, Path: "/a"})
+	}
+
+	assert.Equal(t, ErrAlreadyExists, Classify(zkErr(zookeeper.ZNODEEXISTS)))
+	assert.Equal(t, ErrTimeout, Classify(zkErr(zookeeper.ZOPERATIONTIMEOUT)))
+	assert.Equal(t, ErrSessionLost, Classify(zkErr(zookeeper.ZCONNECTIONLOSS)))
+	assert.Equal(t, ErrSessionLost, Classify(zkErr(zookeeper.ZSESSIONEXPIRED)))
+	assert.Nil(t, Classify(zkErr(zookeeper.ZNONODE)))
+	assert.Nil(t, Classify(errors.New("something else")))
+	assert.Nil(t, Classify(nil))
+}
+
+func TestSessionErrorsShouldBeClassified(t *testing.T) {
+	assert.Equal(t, ErrSessionLost, Classify(ErrZKSessionDisconnected))
+	assert.Equal(t, ErrSessionLost, Classify(ErrSessionTerminated))
+	assert.Equal(t, ErrTimeout, Classify(errKeepaliveTimeout))
+}
@@ -0,0 +1,192 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// The kinds of TimelineEntry.
+const (
+	// TimelineEvent entries are the events delivered to subscribers.
+	TimelineEvent = "event"
+	// TimelineState entries are the state changes reported by the ZooKeeper
+	// client, which the session turns into events.
+	TimelineState = "state"
+	// TimelineRedial entries are new connections replacing the session's
+	// current one.
+	TimelineRedial = "redial"
+	// TimelineOperation entries are the progress of recipe operations; see
+	// StartOperation.
+	TimelineOperation = "operation"
+)
+
+// TimelineEntry is a line of the timeline written with WithTimeline. Seq
+// increases by one with every entry the session writes, so entries are in
+// the exact order the session saw them, whatever their timestamps.
+type TimelineEntry struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+	Epoch   uint64    `json:"epoch"`
+	Kind    string    `json:"kind"`
+	// Event is the event, state or operation step recorded.
+	Event  string `json:"event,omitempty"`
+	Detail string `json:"detail,omitempty"`
+
+	// The fields of operation entries.
+	OperationID string        `json:"operation_id,omitempty"`
+	Recipe      string        `json:"recipe,omitempty"`
+	Done        bool          `json:"done,omitempty"`
+	Err         string        `json:"err,omitempty"`
+	Elapsed     time.Duration `json:"elapsed,omitempty"`
+}
+
+// WithTimeline appends a timeline of the session's events, client state
+// changes, redials and recipe operations to the file at path, as JSON lines,
+// for reconstructing the exact order of events after an incident; see the
+// zktimeline command. Unlike logs, the timeline isn't rate limited. Once the
+// file reaches maxBytes it is rotated, keeping up to backups previous files
+// named path.1, path.2 and so on, newest first. Zero maxBytes never rotates.
+func WithTimeline(path string, maxBytes int64, backups int) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.timelinePath = path
+		so.timelineMaxBytes = maxBytes
+		so.timelineBackups = backups
+		return so
+	}
+}
+
+// timeline writes TimelineEntries. Like debugState, a nil *timeline is valid
+// and records nothing.
+type timeline struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+	seq      uint64
+	// failed is set once a write has failed and been logged, so a full disk
+	// doesn't log every entry.
+	failed bool
+}
+
+func openTimeline(path string, maxBytes int64, backups int) (*timeline, error) {
+	t := &timeline{path: path, maxBytes: maxBytes, backups: backups}
+	if err := t.open(); err != nil {
+		return nil, fmt.Errorf("opening timeline: %w", err)
+	}
+	return t, nil
+}
+
+func (t *timeline) open() error {
+	file, err := os.OpenFile(t.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	t.file, t.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file to path.1, and older backups along, and
+// starts a new file.
+func (t *timeline) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return err
+	}
+	if t.backups > 0 {
+		for i := t.backups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", t.path, i), fmt.Sprintf("%s.%d", t.path, i+1))
+		}
+		if err := os.Rename(t.path, t.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(t.path); err != nil {
+		return err
+	}
+	return t.open()
+}
+
+// record writes entry for s, numbering it. Errors are logged once.
+func (t *timeline) record(s *ZKSession, entry TimelineEntry) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	entry.Seq = t.seq
+	entry.Time = time.Now()
+	entry.Session = s.Name()
+	entry.Epoch = s.Epoch()
+	line, err := json.Marshal(entry)
+	if err == nil {
+		line = append(line, '\n')
+		if t.maxBytes > 0 && t.size > 0 && t.size+int64(len(line)) > t.maxBytes {
+			err = t.rotate()
+		}
+	}
+	if err == nil {
+		var n int
+		n, err = t.file.Write(line)
+		t.size += int64(n)
+	}
+	if err != nil && !t.failed {
+		t.failed = true
+		s.log.Printf("gozk-recipes/session: couldn't write timeline %s, dropping entries: %v", t.path, err)
+		s.reportError("writing timeline", err, false)
+	} else if err == nil {
+		t.failed = false
+	}
+}
+
+func (t *timeline) close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.file.Close()
+}
+
+func (s *ZKSession) recordEvent(event ZKSessionEvent) {
+	s.timeline.record(s, TimelineEntry{Kind: TimelineEvent, Event: event.String()})
+}
+
+func (s *ZKSession) recordState(event zookeeper.Event) {
+	s.timeline.record(s, TimelineEntry{Kind: TimelineState, Event: event.String()})
+}
+
+func (s *ZKSession) recordRedial(detail string) {
+	s.timeline.record(s, TimelineEntry{Kind: TimelineRedial, Event: "connection replaced", Detail: detail})
+}
+
+func (s *ZKSession) recordOperation(event OperationEvent) {
+	entry := TimelineEntry{
+		Kind:        TimelineOperation,
+		Event:       event.Name,
+		Detail:      event.Detail,
+		OperationID: event.ID,
+		Recipe:      event.Recipe,
+		Done:        event.Done,
+		Elapsed:     event.Elapsed,
+	}
+	if event.Step != "" {
+		entry.Event += ": " + event.Step
+	}
+	if event.Err != nil {
+		entry.Err = event.Err.Error()
+	}
+	s.timeline.record(s, entry)
+}
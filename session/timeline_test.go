@@ -0,0 +1,89 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTimeline(t *testing.T, path string) []TimelineEntry {
+	file, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer file.Close()
+
+	var entries []TimelineEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry TimelineEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	assert.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestTimelineShouldRecordEntriesInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline")
+	tl, err := openTimeline(path, 0, 0)
+	assert.NoError(t, err)
+	s := &ZKSession{log: &nullLogger{}, timeline: tl, opts: SessionOpts{name: "test"}, epoch: 2}
+
+	s.recordEvent(SessionReconnected)
+	s.recordRedial("same session")
+	s.recordOperation(OperationEvent{ID: "1", Recipe: "lock", Name: "acquire", Step: "waiting", Done: true, Err: errors.New("failure")})
+	assert.NoError(t, tl.close())
+
+	entries := readTimeline(t, path)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, uint64(1), entries[0].Seq)
+		assert.Equal(t, TimelineEvent, entries[0].Kind)
+		assert.Equal(t, SessionReconnected.String(), entries[0].Event)
+		assert.Equal(t, "test", entries[0].Session)
+		assert.Equal(t, uint64(2), entries[0].Epoch)
+
+		assert.Equal(t, TimelineRedial, entries[1].Kind)
+		assert.Equal(t, "same session", entries[1].Detail)
+
+		assert.Equal(t, uint64(3), entries[2].Seq)
+		assert.Equal(t, TimelineOperation, entries[2].Kind)
+		assert.Equal(t, "acquire: waiting", entries[2].Event)
+		assert.Equal(t, "lock", entries[2].Recipe)
+		assert.Equal(t, "failure", entries[2].Err)
+		assert.True(t, entries[2].Done)
+	}
+}
+
+func TestTimelineShouldRotateFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline")
+	tl, err := openTimeline(path, 1, 2)
+	assert.NoError(t, err)
+	s := &ZKSession{log: &nullLogger{}, timeline: tl}
+
+	// Every entry is over maxBytes, so each one starts a new file.
+	for i := 0; i < 4; i++ {
+		s.recordRedial("")
+	}
+	assert.NoError(t, tl.close())
+
+	for file, seq := range map[string]uint64{path: 4, path + ".1": 3, path + ".2": 2} {
+		entries := readTimeline(t, file)
+		if assert.Len(t, entries, 1, file) {
+			assert.Equal(t, seq, entries[0].Seq, file)
+		}
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNilTimelineShouldRecordNothing(t *testing.T) {
+	s := &ZKSession{log: &nullLogger{}}
+	s.recordEvent(SessionReconnected)
+	assert.NoError(t, s.timeline.close())
+}
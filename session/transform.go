@@ -0,0 +1,246 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+)
+
+// Transformer converts the values of nodes kept in a legacy encoding, such as
+// those written by old Java clients, so applications read and write them in
+// the form they expect.
+type Transformer interface {
+	// Decode converts data as stored at path into the form applications
+	// read.
+	Decode(path string, stored []byte) ([]byte, error)
+	// Encode converts data written by applications to path into the form
+	// stored.
+	Encode(path string, data []byte) ([]byte, error)
+}
+
+// TransformerFuncs is a Transformer made of two functions, either of which
+// may be nil to leave the data unchanged that way, as when migrated values
+// are read from the legacy encoding but written in the new one.
+type TransformerFuncs struct {
+	DecodeFunc func(path string, stored []byte) ([]byte, error)
+	EncodeFunc func(path string, data []byte) ([]byte, error)
+}
+
+func (t TransformerFuncs) Decode(path string, stored []byte) ([]byte, error) {
+	if t.DecodeFunc == nil {
+		return stored, nil
+	}
+	return t.DecodeFunc(path, stored)
+}
+
+func (t TransformerFuncs) Encode(path string, data []byte) ([]byte, error) {
+	if t.EncodeFunc == nil {
+		return data, nil
+	}
+	return t.EncodeFunc(path, data)
+}
+
+type prefixTransformer struct {
+	prefix      string
+	transformer Transformer
+}
+
+// WithTransformer converts the values of the nodes at or below prefix with
+// transformer: values read are decoded after the session decrypts and
+// decompresses them, and values written are encoded before it compresses and
+// encrypts them, so validators and codecs only see the decoded form. Where
+// several prefixes match a path the longest one wins.
+func WithTransformer(prefix string, transformer Transformer) SessionOpt {
+	return func(so SessionOpts) SessionOpts {
+		so.transformers = append(so.transformers, prefixTransformer{prefix: prefix, transformer: transformer})
+		return so
+	}
+}
+
+// transformerFor returns the transformer configured for path, or nil.
+func (s *ZKSession) transformerFor(path string) Transformer {
+	var found *prefixTransformer
+	for i, t := range s.opts.transformers {
+		if underPrefix(path, t.prefix) && (found == nil || len(t.prefix) > len(found.prefix)) {
+			found = &s.opts.transformers[i]
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return found.transformer
+}
+
+// transformRead decodes value read from path with its transformer, if any.
+func (s *ZKSession) transformRead(path string, value string) (string, error) {
+	t := s.transformerFor(path)
+	if t == nil {
+		return value, nil
+	}
+	data, err := t.Decode(path, []byte(value))
+	if err != nil {
+		return "", fmt.Errorf("transforming data read from %q: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// transformWrite encodes value written to path with its transformer, if any.
+func (s *ZKSession) transformWrite(path string, value string) (string, error) {
+	t := s.transformerFor(path)
+	if t == nil {
+		return value, nil
+	}
+	data, err := t.Encode(path, []byte(value))
+	if err != nil {
+		return "", fmt.Errorf("transforming data for %q: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// gzipMagic starts every gzip stream.
+const gzipMagic = "\x1f\x8b"
+
+// Gzip returns a Transformer for values wrapped in plain gzip streams, as
+// some Java clients compress their data, unlike the session's own
+// compression which marks its values; see WithCompression. Values that aren't
+// gzip streams are read unchanged, so nodes can be migrated one at a time.
+func Gzip() Transformer {
+	return TransformerFuncs{
+		DecodeFunc: func(path string, stored []byte) ([]byte, error) {
+			if !bytes.HasPrefix(stored, []byte(gzipMagic)) {
+				return stored, nil
+			}
+			r, err := gzip.NewReader(bytes.NewReader(stored))
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			return ioutil.ReadAll(r)
+		},
+		EncodeFunc: func(path string, data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+	}
+}
+
+// Java serialization stream constants; see the Java Object Serialization
+// Specification.
+const (
+	javaStreamHeader = "\xac\xed\x00\x05"
+	javaString       = 0x74
+	javaLongString   = 0x7c
+)
+
+// ErrNotJavaString is returned when reading a Java serialization stream that
+// holds anything but a single string.
+var ErrNotJavaString = errors.New("value is a Java serialized object, not a string")
+
+// JavaString returns a Transformer for values holding a java.lang.String in
+// Java serialization, as written by Java clients storing strings with
+// ObjectOutputStream, such as ZkClient's SerializableSerializer. Values
+// without the serialization header are read unchanged.
+func JavaString() Transformer {
+	return TransformerFuncs{
+		DecodeFunc: func(path string, stored []byte) ([]byte, error) {
+			if !bytes.HasPrefix(stored, []byte(javaStreamHeader)) {
+				return stored, nil
+			}
+			s, err := decodeJavaString(stored[len(javaStreamHeader):])
+			if err != nil {
+				return nil, err
+			}
+			return []byte(s), nil
+		},
+		EncodeFunc: func(path string, data []byte) ([]byte, error) {
+			return encodeJavaString(string(data)), nil
+		},
+	}
+}
+
+func decodeJavaString(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", ErrNotJavaString
+	}
+	var n uint64
+	switch b[0] {
+	case javaString:
+		if len(b) < 3 {
+			return "", errors.New("truncated Java string")
+		}
+		n, b = uint64(binary.BigEndian.Uint16(b[1:])), b[3:]
+	case javaLongString:
+		if len(b) < 9 {
+			return "", errors.New("truncated Java string")
+		}
+		n, b = binary.BigEndian.Uint64(b[1:]), b[9:]
+	default:
+		return "", ErrNotJavaString
+	}
+	if uint64(len(b)) != n {
+		return "", fmt.Errorf("Java string is %d bytes, %d given", n, len(b))
+	}
+	return decodeModifiedUTF8(b)
+}
+
+// decodeModifiedUTF8 decodes Java's modified UTF-8, which encodes UTF-16 code
+// units rather than code points, and NUL in two bytes.
+func decodeModifiedUTF8(b []byte) (string, error) {
+	units := make([]uint16, 0, len(b))
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xe0 == 0xc0 && i+1 < len(b) && b[i+1]&0xc0 == 0x80:
+			units = append(units, uint16(c&0x1f)<<6|uint16(b[i+1]&0x3f))
+			i += 2
+		case c&0xf0 == 0xe0 && i+2 < len(b) && b[i+1]&0xc0 == 0x80 && b[i+2]&0xc0 == 0x80:
+			units = append(units, uint16(c&0x0f)<<12|uint16(b[i+1]&0x3f)<<6|uint16(b[i+2]&0x3f))
+			i += 3
+		default:
+			return "", fmt.Errorf("invalid modified UTF-8 at byte %d", i)
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+func encodeJavaString(s string) []byte {
+	var body strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		switch {
+		case u != 0 && u < 0x80:
+			body.WriteByte(byte(u))
+		case u < 0x800:
+			body.WriteByte(0xc0 | byte(u>>6))
+			body.WriteByte(0x80 | byte(u&0x3f))
+		default:
+			body.WriteByte(0xe0 | byte(u>>12))
+			body.WriteByte(0x80 | byte(u>>6&0x3f))
+			body.WriteByte(0x80 | byte(u&0x3f))
+		}
+	}
+
+	out := []byte(javaStreamHeader)
+	if n := body.Len(); n <= 0xffff {
+		out = append(out, javaString, byte(n>>8), byte(n))
+	} else {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(n))
+		out = append(append(out, javaLongString), length[:]...)
+	}
+	return append(out, body.String()...)
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJavaStringShouldDecodeSerializedStrings(t *testing.T) {
+	// new ObjectOutputStream(out).writeObject("hello")
+	stored := []byte("\xac\xed\x00\x05\x74\x00\x05hello")
+	data, err := JavaString().Decode("/legacy", stored)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	encoded, err := JavaString().Encode("/legacy", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, stored, encoded)
+
+	for _, s := range []string{"", "nul\x00", "été", "emoji \U0001F600", strings.Repeat("x", 70000)} {
+		encoded, _ := JavaString().Encode("/legacy", []byte(s))
+		decoded, err := JavaString().Decode("/legacy", encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, s, string(decoded))
+	}
+
+	plain, err := JavaString().Decode("/legacy", []byte(`{"migrated":true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"migrated":true}`, string(plain))
+
+	// An ArrayList rather than a string.
+	_, err = JavaString().Decode("/legacy", []byte("\xac\xed\x00\x05\x73\x72"))
+	assert.True(t, errors.Is(err, ErrNotJavaString))
+}
+
+func TestGzipShouldDecodeOnlyGzipStreams(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(`{"a":1}`))
+	w.Close()
+
+	data, err := Gzip().Decode("/legacy", buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(data))
+
+	data, err = Gzip().Decode("/legacy", []byte(`{"a":2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":2}`, string(data))
+}
+
+func TestTransformerShouldApplyToLongestPrefix(t *testing.T) {
+	upper := TransformerFuncs{
+		DecodeFunc: func(path string, stored []byte) ([]byte, error) { return bytes.ToUpper(stored), nil },
+	}
+	opts := WithTransformer("/legacy", JavaString())(SessionOpts{})
+	opts = WithTransformer("/legacy/raw", upper)(opts)
+	opts = WithCompression(16)(opts)
+	s := &ZKSession{opts: opts}
+
+	value := strings.Repeat("compressible ", 10)
+	encoded, err := s.encodeValue("/legacy/a", value)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, compressionMagic))
+	payload, _ := s.decodePayload("/legacy/a", encoded)
+	assert.True(t, strings.HasPrefix(payload, javaStreamHeader))
+	decoded, err := s.decodeValue("/legacy/a", encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, value, decoded)
+
+	encoded, _ = s.encodeValue("/legacy/raw/b", "abc")
+	assert.Equal(t, "abc", encoded)
+	decoded, _ = s.decodeValue("/legacy/raw/b", encoded)
+	assert.Equal(t, "ABC", decoded)
+
+	decoded, _ = s.decodeValue("/legacyother", "abc")
+	assert.Equal(t, "abc", decoded)
+}
+
+func TestValidateShouldRejectInvalidTransformers(t *testing.T) {
+	opts := SessionOpts{servers: []string{"zk1:2181"}, sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout}
+	opts = WithTransformer("legacy", Gzip())(opts)
+	opts = WithTransformer("/a", nil)(opts)
+	opts = WithTransformer("/b", Gzip())(opts)
+	opts = WithTransformer("/b", JavaString())(opts)
+	err := opts.Validate()
+	assert.Equal(t, `invalid session options: transformer prefix "legacy" must be an absolute path; transformer for "/a" is nil; transformer prefix "/b" is given twice`, err.Error())
+}
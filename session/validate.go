@@ -0,0 +1,101 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	gopath "path"
+	"strings"
+)
+
+// WriteValidator inspects a write before it is sent to ZooKeeper. Returning an
+// error rejects the write.
+type WriteValidator func(path string, data []byte) error
+
+// validateWrite runs every configured WriteValidator against a write to path.
+func (s *ZKSession) validateWrite(path string, value string) error {
+	for _, validator := range s.opts.writeValidators {
+		if err := validator(path, []byte(value)); err != nil {
+			return fmt.Errorf("validating write to %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// MaxDataSize returns a WriteValidator rejecting values larger than limit
+// bytes.
+func MaxDataSize(limit int) WriteValidator {
+	return func(path string, data []byte) error {
+		if len(data) > limit {
+			return fmt.Errorf("data is %d bytes, limit is %d", len(data), limit)
+		}
+		return nil
+	}
+}
+
+// ValidJSONUnder returns a WriteValidator requiring that values written at or
+// below prefix are valid JSON. Empty values are allowed so that intermediate
+// nodes can still be created.
+func ValidJSONUnder(prefix string) WriteValidator {
+	return func(path string, data []byte) error {
+		if !underPrefix(path, prefix) {
+			return nil
+		}
+		if len(data) > 0 && !json.Valid(data) {
+			return fmt.Errorf("data is not valid JSON")
+		}
+		return nil
+	}
+}
+
+// underPrefix reports whether path is prefix or one of its descendants.
+func underPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// ErrInvalidData is matched, with errors.Is, by the InvalidDataError returned
+// for data rejected by a validator given to WithReadValidator.
+var ErrInvalidData = errors.New("invalid data")
+
+// InvalidDataError is returned by Get and GetW when the data read from Path
+// is rejected by the validator given to WithReadValidator for Pattern.
+type InvalidDataError struct {
+	Path    string
+	Pattern string
+	Err     error
+}
+
+func (e *InvalidDataError) Error() string {
+	return fmt.Sprintf("invalid data at %q (matches %q): %v", e.Path, e.Pattern, e.Err)
+}
+
+func (e *InvalidDataError) Is(target error) bool {
+	return target == ErrInvalidData
+}
+
+func (e *InvalidDataError) Unwrap() error {
+	return e.Err
+}
+
+type readValidator struct {
+	pattern  string
+	validate func(data []byte) error
+}
+
+// validateRead runs the read validators whose pattern matches path against
+// value, counting, logging and reporting a rejection.
+func (s *ZKSession) validateRead(path string, value string) error {
+	for _, v := range s.opts.readValidators {
+		if matched, _ := gopath.Match(v.pattern, path); !matched {
+			continue
+		}
+		if err := v.validate([]byte(value)); err != nil {
+			invalid := &InvalidDataError{Path: path, Pattern: v.pattern, Err: err}
+			s.stats.recordInvalidRead(v.pattern)
+			s.log.Printf("gozk-recipes/session: rejecting data read from %s: %v", path, err)
+			s.reportError("validating read", invalid, false)
+			return invalid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxDataSize(t *testing.T) {
+	validator := MaxDataSize(4)
+
+	if err := validator("/foo", []byte("1234")); err != nil {
+		t.Error("Expected value within limit to be accepted: ", err)
+	}
+	if err := validator("/foo", []byte("12345")); err == nil {
+		t.Error("Expected value over limit to be rejected")
+	}
+}
+
+func TestValidJSONUnder(t *testing.T) {
+	validator := ValidJSONUnder("/config")
+
+	if err := validator("/config/app", []byte(`{"a": 1}`)); err != nil {
+		t.Error("Expected valid JSON to be accepted: ", err)
+	}
+	if err := validator("/config/app", []byte("")); err != nil {
+		t.Error("Expected empty value to be accepted: ", err)
+	}
+	if err := validator("/config/app", []byte("{")); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+	if err := validator("/configuration", []byte("{")); err != nil {
+		t.Error("Expected path outside prefix to be accepted: ", err)
+	}
+}
+
+func TestValidateWriteRunsAllValidators(t *testing.T) {
+	s := &ZKSession{opts: WithWriteValidator(MaxDataSize(10))(WithWriteValidator(ValidJSONUnder("/config"))(SessionOpts{}))}
+
+	if err := s.validateWrite("/config/app", "{"); err == nil || !strings.Contains(err.Error(), "/config/app") {
+		t.Error("Expected write to be rejected with the path in the error: ", err)
+	}
+	if err := s.validateWrite("/other", "12345678901"); err == nil {
+		t.Error("Expected write to be rejected")
+	}
+	if err := s.validateWrite("/other", "ok"); err != nil {
+		t.Error("Expected write to be accepted: ", err)
+	}
+}
+
+func TestValidateReadShouldRejectMatchingPaths(t *testing.T) {
+	notEmpty := func(data []byte) error {
+		if len(data) == 0 {
+			return errors.New("empty")
+		}
+		return nil
+	}
+	errs := make(chan error, 1)
+	s := &ZKSession{
+		log:   &nullLogger{},
+		stats: newSessionStats(),
+		opts:  WithReadValidator("/config/*", notEmpty)(WithErrorChannel(errs)(SessionOpts{})),
+	}
+
+	assert.NoError(t, s.validateRead("/config/app", "{}"))
+	assert.NoError(t, s.validateRead("/other", ""))
+
+	err := s.validateRead("/config/app", "")
+	assert.True(t, errors.Is(err, ErrInvalidData))
+	var invalid *InvalidDataError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, "/config/app", invalid.Path)
+		assert.Equal(t, "/config/*", invalid.Pattern)
+	}
+	assert.Equal(t, map[string]uint64{"/config/*": 1}, s.Stats().InvalidReads)
+	assert.True(t, errors.Is(<-errs, ErrInvalidData))
+}
+
+func TestValidateShouldRejectBadReadValidatorPatterns(t *testing.T) {
+	opts := WithReadValidator("/config/[", func([]byte) error { return nil })(WithZookeepers([]string{"zk1:2181"})(SessionOpts{}))
+	opts.sessionTimeout, opts.connectTimeout = DefaultSessionTimeout, DefaultConnectTimeout
+	assert.ErrorContains(t, opts.Validate(), `read validator pattern "/config/[" is invalid`)
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOptions is wrapped by the error Validate returns for inconsistent
+// session options.
+var ErrInvalidOptions = errors.New("invalid session options")
+
+// Validate checks that the options describe a session that can be created,
+// reporting every problem found, so that mistakes such as an empty server
+// list are reported as such rather than as a failure to connect. Create calls
+// it before dialing.
+func (s SessionOpts) Validate() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(s.servers) == 0 {
+		add("no zookeeper servers specified")
+	}
+	for i, server := range s.servers {
+		if strings.TrimSpace(server) == "" {
+			add("server %d of %d is empty", i+1, len(s.servers))
+		} else if strings.Contains(server, ",") {
+			add("server %q must be a single host:port", server)
+		}
+	}
+
+	positive := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"session timeout", s.sessionTimeout},
+		{"connect timeout", s.connectTimeout},
+	}
+	for _, d := range positive {
+		if d.value <= 0 {
+			add("%s must be positive, got %s", d.name, d.value)
+		}
+	}
+	// gozk hands the session timeout to the C client in whole milliseconds.
+	if s.sessionTimeout > 0 && s.sessionTimeout < time.Millisecond {
+		add("session timeout must be at least 1ms, got %s", s.sessionTimeout)
+	}
+
+	nonNegative := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"log rate limit window", s.logWindow},
+		{"DNS refresh interval", s.dnsRefresh},
+		{"watchdog timeout", s.watchdog},
+		{"keepalive idle time", s.keepalive},
+		{"connect jitter", s.connectJitterMax},
+		{"shutdown timeout", s.shutdownTimeout},
+		{"probe timeout", s.probeTimeout},
+		{"diagnostics timeout", s.diagnosticsTimeout},
+		{"callback timeout", s.callbackTimeout},
+		{"hedged read delay", s.hedgeAfter},
+	}
+	for _, d := range nonNegative {
+		if d.value < 0 {
+			add("%s must not be negative, got %s", d.name, d.value)
+		}
+	}
+	if s.compressThreshold < 0 {
+		add("compression threshold must not be negative, got %d", s.compressThreshold)
+	}
+	if s.timelineMaxBytes < 0 || s.timelineBackups < 0 {
+		add("timeline rotation must not be negative, got %d bytes and %d backups", s.timelineMaxBytes, s.timelineBackups)
+	}
+	for _, v := range s.readValidators {
+		if _, err := path.Match(v.pattern, ""); err != nil {
+			add("read validator pattern %q is invalid: %v", v.pattern, err)
+		}
+	}
+	if s.maxInflight < 0 {
+		add("max inflight must not be negative, got %d", s.maxInflight)
+	}
+	if l := s.pathLimits; l.MaxDepth < 0 || l.MaxLength < 0 || l.MaxChildren < 0 {
+		add("path limits must not be negative, got depth %d, length %d and children %d", l.MaxDepth, l.MaxLength, l.MaxChildren)
+	}
+	if s.maxLifetime < 0 || (s.maxLifetime > 0 && s.maxLifetime < s.sessionTimeout) {
+		add("max session lifetime must not be negative or shorter than the session timeout, got %s", s.maxLifetime)
+	}
+	if s.noRedial && s.maxLifetime > 0 {
+		add("max session lifetime needs automatic redial")
+	}
+	if b := s.backpressure; b != nil {
+		if b.Reconnects < 0 || b.Latency < 0 || b.Backlog < 0 || b.Recovery < 0 {
+			add("backpressure thresholds must not be negative, got %d reconnects, latency %s, backlog %d and recovery %s", b.Reconnects, b.Latency, b.Backlog, b.Recovery)
+		}
+		if b.Reconnects > 0 && b.ReconnectWindow <= 0 {
+			add("backpressure reconnect threshold needs a positive window, got %s", b.ReconnectWindow)
+		}
+	}
+	for _, observer := range s.observers {
+		if !containsServer(s.servers, observer) {
+			add("observer %q is not one of the configured servers", observer)
+		}
+	}
+	switch s.role {
+	case AnyServer:
+	case ReadPreferred:
+		if len(s.observers) == 0 {
+			add("read-preferred server role needs observers")
+		}
+		if s.preferredServer != "" {
+			add("read-preferred server role and a preferred server are exclusive")
+		}
+	case ParticipantsOnly:
+		if len(s.servers) > 0 && len(s.roleServers()) == 0 {
+			add("participants-only server role needs a server that isn't an observer")
+		}
+	default:
+		add("unknown server role %s", s.role)
+	}
+	if s.rttInterval < 0 {
+		add("adaptive timeout sample interval must not be negative, got %s", s.rttInterval)
+	}
+	if err := s.retryPolicy.Validate(); err != nil {
+		add("%v, got %+v", err, s.retryPolicy)
+	}
+	if s.callbackWorkers < 0 {
+		add("callback workers must not be negative, got %d", s.callbackWorkers)
+	}
+
+	if g := s.flapGate; g != nil && (g.maxFlaps <= 0 || g.window <= 0 || g.coolOff <= 0) {
+		add("flap gate needs positive max flaps, window and cool-off, got %d, %s and %s", g.maxFlaps, g.window, g.coolOff)
+	}
+	if s.createNamespace && (s.namespace == "" || s.namespace == "/") {
+		add("namespace creation needs a namespace")
+	}
+	if s.createNamespace && len(s.namespaceACL) == 0 {
+		add("namespace creation needs an ACL")
+	}
+	for _, entry := range s.namespaceACL {
+		if s.tenant == "" && strings.Contains(entry.Id, TenantPlaceholder) {
+			add("namespace ACL template refers to the tenant but none is given")
+			break
+		}
+	}
+	if s.clientInfoDir != "" && s.clientInfo.Service == "" {
+		add("client info node needs client info with a service name")
+	}
+	if s.expvarName != "" && expvarTaken(s.expvarName) {
+		add("expvar %q is already published", s.expvarName)
+	}
+	if len(s.encryptPrefixes) > 0 && s.keys == nil {
+		add("encryption needs a key provider")
+	}
+	prefixes := map[string]bool{}
+	for _, t := range s.transformers {
+		switch {
+		case !strings.HasPrefix(t.prefix, "/"):
+			add("transformer prefix %q must be an absolute path", t.prefix)
+		case t.transformer == nil:
+			add("transformer for %q is nil", t.prefix)
+		case prefixes[t.prefix]:
+			add("transformer prefix %q is given twice", t.prefix)
+		}
+		prefixes[t.prefix] = true
+	}
+	hedged := map[string]bool{}
+	for _, h := range s.childrenHedging {
+		switch {
+		case !strings.HasPrefix(h.prefix, "/"):
+			add("children hedging prefix %q must be an absolute path", h.prefix)
+		case hedged[h.prefix]:
+			add("children hedging prefix %q is given twice", h.prefix)
+		case h.hedging.Percentile < 0 || h.hedging.Percentile >= 1:
+			add("children hedging percentile for %q must be between 0 and 1, got %v", h.prefix, h.hedging.Percentile)
+		case h.hedging.MinDelay < 0 || h.hedging.MaxDelay < 0:
+			add("children hedging delays for %q must not be negative", h.prefix)
+		case h.hedging.MaxDelay > 0 && h.hedging.MaxDelay < h.hedging.MinDelay:
+			add("children hedging maximum delay for %q must not be shorter than its minimum", h.prefix)
+		case h.hedging.Concurrency < 0:
+			add("children hedging concurrency for %q must not be negative, got %d", h.prefix, h.hedging.Concurrency)
+		}
+		hedged[h.prefix] = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(problems, "; "))
+	}
+	return nil
+}
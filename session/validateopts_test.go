@@ -0,0 +1,31 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateShouldDescribeEveryProblem(t *testing.T) {
+	opts := SessionOpts{servers: []string{"zk1:2181", ""}, connectTimeout: DefaultConnectTimeout}
+	opts = WithKeepalive(-time.Second)(opts)
+
+	err := opts.Validate()
+	assert.True(t, errors.Is(err, ErrInvalidOptions))
+	assert.Equal(t, "invalid session options: server 2 of 2 is empty; session timeout must be positive, got 0s; keepalive idle time must not be negative, got -1s", err.Error())
+}
+
+func TestValidateShouldAcceptDefaults(t *testing.T) {
+	opts := SessionOpts{sessionTimeout: DefaultSessionTimeout, connectTimeout: DefaultConnectTimeout}
+	assert.Error(t, opts.Validate())
+
+	opts = WithZookeepers([]string{"zk1:2181", "zk2:2181"})(opts)
+	assert.NoError(t, opts.Validate())
+}
+
+func TestNewSessionWithOptsShouldRejectEmptyServers(t *testing.T) {
+	_, err := NewZKSession("", time.Second, nil)
+	assert.True(t, errors.Is(err, ErrInvalidOptions))
+}
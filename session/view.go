@@ -0,0 +1,184 @@
+package session
+
+import (
+	"io"
+	"strings"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// View is a Session rooted at a namespace of another session, like a chroot
+// but sharing the other session's connection, watches and event loop. Paths
+// given to a View are relative to its namespace, and paths it returns, such
+// as those of sequential nodes and watch events, are made relative again, so
+// recipes and applications can each be given their own root without opening a
+// session per root.
+//
+// As with a chroot, the namespace node must exist before nodes are created
+// under it.
+type View struct {
+	parent    Session
+	namespace string
+}
+
+var _ Session = (*View)(nil)
+
+// NewView returns a view of s rooted at namespace, an absolute path. Views of
+// views nest.
+func NewView(s Session, namespace string) *View {
+	namespace = strings.TrimSuffix(namespace, "/")
+	if parent, ok := s.(*View); ok {
+		return &View{parent: parent.parent, namespace: parent.namespace + namespace}
+	}
+	return &View{parent: s, namespace: namespace}
+}
+
+// WithNamespace returns a view of s rooted at namespace; see View.
+func (s *ZKSession) WithNamespace(namespace string) *View {
+	return NewView(s, namespace)
+}
+
+// WithNamespace returns a view nested in v, rooted at namespace relative to
+// v's namespace.
+func (v *View) WithNamespace(namespace string) *View {
+	return NewView(v, namespace)
+}
+
+// Namespace returns the absolute path the view is rooted at.
+func (v *View) Namespace() string {
+	if v.namespace == "" {
+		return "/"
+	}
+	return v.namespace
+}
+
+// Parent returns the session the view was created from.
+func (v *View) Parent() Session {
+	return v.parent
+}
+
+func (v *View) full(path string) string {
+	if path == "/" {
+		return v.Namespace()
+	}
+	return v.namespace + path
+}
+
+func (v *View) relative(path string) string {
+	if v.namespace == "" {
+		return path
+	}
+	if path == v.namespace {
+		return "/"
+	}
+	if strings.HasPrefix(path, v.namespace+"/") {
+		return path[len(v.namespace):]
+	}
+	return path
+}
+
+// watch relays events from w with their paths made relative.
+func (v *View) watch(w <-chan zookeeper.Event) <-chan zookeeper.Event {
+	if w == nil {
+		return nil
+	}
+	relayed := make(chan zookeeper.Event, 1)
+	Go(v.parent, "view", func() {
+		defer close(relayed)
+		for event := range w {
+			if event.Path != "" {
+				event.Path = v.relative(event.Path)
+			}
+			relayed <- event
+		}
+	})
+	return relayed
+}
+
+func (v *View) ACL(path string) ([]zookeeper.ACL, *zookeeper.Stat, error) {
+	return v.parent.ACL(v.full(path))
+}
+
+func (v *View) AddAuth(scheme, cert string) error {
+	return v.parent.AddAuth(scheme, cert)
+}
+
+func (v *View) Children(path string) ([]string, *zookeeper.Stat, error) {
+	return v.parent.Children(v.full(path))
+}
+
+func (v *View) ChildrenW(path string) ([]string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	children, stat, w, err := v.parent.ChildrenW(v.full(path))
+	return children, stat, v.watch(w), err
+}
+
+func (v *View) ClientId() *zookeeper.ClientId {
+	return v.parent.ClientId()
+}
+
+// Close has no effect: the connection belongs to the parent session and the
+// other views sharing it.
+func (v *View) Close() error {
+	return nil
+}
+
+func (v *View) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	created, err := v.parent.Create(v.full(path), value, flags, aclv)
+	return v.relative(created), err
+}
+
+func (v *View) Delete(path string, version int) error {
+	return v.parent.Delete(v.full(path), version)
+}
+
+func (v *View) Exists(path string) (*zookeeper.Stat, error) {
+	return v.parent.Exists(v.full(path))
+}
+
+func (v *View) ExistsW(path string) (*zookeeper.Stat, <-chan zookeeper.Event, error) {
+	stat, w, err := v.parent.ExistsW(v.full(path))
+	return stat, v.watch(w), err
+}
+
+func (v *View) Get(path string) (string, *zookeeper.Stat, error) {
+	return v.parent.Get(v.full(path))
+}
+
+func (v *View) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	data, stat, w, err := v.parent.GetW(v.full(path))
+	return data, stat, v.watch(w), err
+}
+
+func (v *View) Set(path string, value string, version int) (*zookeeper.Stat, error) {
+	return v.parent.Set(v.full(path), value, version)
+}
+
+func (v *View) RetryChange(path string, flags int, acl []zookeeper.ACL, changeFunc zookeeper.ChangeFunc) error {
+	return v.parent.RetryChange(v.full(path), flags, acl, changeFunc)
+}
+
+func (v *View) SetACL(path string, aclv []zookeeper.ACL, version int) error {
+	return v.parent.SetACL(v.full(path), aclv, version)
+}
+
+func (v *View) Subscribe(subscription chan<- ZKSessionEvent) {
+	v.parent.Subscribe(subscription)
+}
+
+// Epoch, Name, Codec, DefaultACL and Register forward to the parent session,
+// so recipes given a view behave as they would with the session itself.
+
+func (v *View) Epoch() uint64 { return EpochOf(v.parent) }
+
+func (v *View) Name() string {
+	if named, ok := v.parent.(interface{ Name() string }); ok {
+		return named.Name() + v.namespace
+	}
+	return "unknown" + v.namespace
+}
+
+func (v *View) Codec() Codec { return codecOf(v.parent) }
+
+func (v *View) DefaultACL() []zookeeper.ACL { return defaultACLOf(v.parent) }
+
+func (v *View) Register(c io.Closer) func() { return RegisterCloser(v.parent, c) }
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+// pathSession records the paths it is called with.
+type pathSession struct {
+	Session
+	paths   []string
+	watches chan zookeeper.Event
+}
+
+func (p *pathSession) Create(path string, value string, flags int, aclv []zookeeper.ACL) (string, error) {
+	p.paths = append(p.paths, path)
+	return path + "0000000001", nil
+}
+
+func (p *pathSession) Get(path string) (string, *zookeeper.Stat, error) {
+	p.paths = append(p.paths, path)
+	return "", nil, nil
+}
+
+func (p *pathSession) GetW(path string) (string, *zookeeper.Stat, <-chan zookeeper.Event, error) {
+	p.paths = append(p.paths, path)
+	return "", nil, p.watches, nil
+}
+
+func TestViewShouldMapPaths(t *testing.T) {
+	parent := &pathSession{watches: make(chan zookeeper.Event, 1)}
+	v := NewView(parent, "/app1/")
+	assert.Equal(t, "/app1", v.Namespace())
+
+	created, err := v.Create("/jobs/job-", "", zookeeper.SEQUENCE, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/jobs/job-0000000001", created)
+
+	_, _, _ = v.Get("/")
+	_, _, _ = v.WithNamespace("/team").Get("/config")
+	assert.Equal(t, []string{"/app1/jobs/job-", "/app1", "/app1/team/config"}, parent.paths)
+
+	_, _, w, err := v.GetW("/config")
+	assert.NoError(t, err)
+	parent.watches <- zookeeper.Event{Type: zookeeper.EVENT_CHANGED, Path: "/app1/config"}
+	assert.Equal(t, "/config", (<-w).Path)
+	close(parent.watches)
+	_, ok := <-w
+	assert.False(t, ok)
+}
+
+func TestViewShouldNotCloseParent(t *testing.T) {
+	v := NewView(&pathSession{}, "/app1")
+	assert.NoError(t, v.Close())
+	assert.Equal(t, "/", NewView(&pathSession{}, "/").Namespace())
+}
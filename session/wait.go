@@ -0,0 +1,67 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/retry"
+)
+
+// waitPolicy is how WaitForCreate and WaitForDelete back off unless given a
+// session with WithRetryPolicy.
+var waitPolicy = retry.Policy{Initial: 50 * time.Millisecond, Max: 5 * time.Second}
+
+// WaitForCreate blocks until the node at path exists, returning its Stat, or
+// until ctx is done. Errors talking to ZooKeeper are retried with exponential
+// backoff, or the session's retry policy if it has one, unless the backoff would leave less than DefaultDeadlineMargin
+// before ctx's deadline; watches lost to a reconnection are re-armed. The
+// watch of a wait given up is given up as with ExistsWContext.
+func WaitForCreate(ctx context.Context, s Session, path string) (*zookeeper.Stat, error) {
+	var stat *zookeeper.Stat
+	err := waitFor(ctx, s, path, func(st *zookeeper.Stat) bool {
+		stat = st
+		return st != nil
+	})
+	return stat, err
+}
+
+// WaitForDelete blocks until the node at path doesn't exist, or until ctx is
+// done. It retries and re-arms watches like WaitForCreate.
+func WaitForDelete(ctx context.Context, s Session, path string) error {
+	return waitFor(ctx, s, path, func(st *zookeeper.Stat) bool {
+		return st == nil
+	})
+}
+
+func waitFor(ctx context.Context, s Session, path string, done func(*zookeeper.Stat) bool) error {
+	backoff := RetryPolicyOf(s, waitPolicy).Start()
+	for {
+		stat, watch, err := ExistsWContext(ctx, s, path)
+		if err != nil {
+			wait := backoff.Next()
+			if outOfBudget(ctx, wait, DefaultDeadlineMargin) != nil {
+				// Retrying wouldn't finish in time.
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return context.DeadlineExceeded
+			}
+			if err := retry.Sleep(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+		backoff.Reset()
+
+		if done(stat) {
+			return nil
+		}
+
+		select {
+		case <-watch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
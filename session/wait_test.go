@@ -0,0 +1,48 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForCreateShouldReturnOnceCreated(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			session.Create("/test/foo", "", 0, defaultACLs)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stat, err := WaitForCreate(ctx, session, "/test/foo")
+		if err != nil {
+			t.Fatal("WaitForCreate error: ", err)
+		}
+		assert.NotNil(t, stat)
+	})
+}
+
+func TestWaitForDeleteShouldHonourContext(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err := WaitForDelete(ctx, session, "/test")
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			session.Delete("/test", -1)
+		}()
+
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, WaitForDelete(ctx, session, "/test"))
+	})
+}
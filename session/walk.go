@@ -0,0 +1,156 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// SkipSubtree can be returned by a WalkFunc to skip the descendants of the
+// node it was called for. The walk carries on with the node's siblings.
+var SkipSubtree = errors.New("skip this subtree")
+
+// WalkFunc is called by Walk for every node visited. Returning an error other
+// than SkipSubtree stops the walk, and Walk returns that error.
+type WalkFunc func(path string, data []byte, stat *zookeeper.Stat) error
+
+type walkOptions struct {
+	maxDepth    int
+	concurrency int
+}
+
+// WalkOption configures Walk.
+type WalkOption func(walkOptions) walkOptions
+
+// WithMaxDepth limits the walk to nodes at most depth levels below its root.
+// Zero or less means no limit.
+func WithMaxDepth(depth int) WalkOption {
+	return func(o walkOptions) walkOptions {
+		o.maxDepth = depth
+		return o
+	}
+}
+
+// WithConcurrency lets the walk read up to n nodes at once. The WalkFunc is
+// then called concurrently and in no particular order.
+func WithConcurrency(n int) WalkOption {
+	return func(o walkOptions) walkOptions {
+		o.concurrency = n
+		return o
+	}
+}
+
+// Walk visits path and its descendants, calling visit with each node's data
+// and Stat. By default nodes are visited one at a time, depth first, parents
+// before children and siblings in lexical order. Nodes deleted while the walk
+// is running are skipped.
+func Walk(s Session, path string, visit WalkFunc, opts ...WalkOption) error {
+	o := walkOptions{concurrency: 1}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	w := &walker{session: s, visit: visit, opts: o}
+	if o.concurrency <= 1 {
+		return w.walk(path, 0)
+	}
+
+	w.sem = make(chan struct{}, o.concurrency)
+	w.stop = make(chan struct{})
+	w.wg.Add(1)
+	go w.walkConcurrent(path, 0)
+	w.wg.Wait()
+	return w.err
+}
+
+type walker struct {
+	session Session
+	visit   WalkFunc
+	opts    walkOptions
+
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+	stop chan struct{}
+	err  error
+}
+
+// read visits path and returns its children, or nil if they shouldn't be
+// walked.
+func (w *walker) read(path string, depth int) ([]string, error) {
+	data, stat, err := w.session.Get(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.visit(path, []byte(data), stat)
+	if err == SkipSubtree {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w.opts.maxDepth > 0 && depth >= w.opts.maxDepth {
+		return nil, nil
+	}
+
+	children, _, err := w.session.Children(path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(children)
+	parent := path
+	if parent == "/" {
+		parent = ""
+	}
+	for i, child := range children {
+		children[i] = parent + "/" + child
+	}
+	return children, nil
+}
+
+func (w *walker) walk(path string, depth int) error {
+	children, err := w.read(path, depth)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := w.walk(child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkConcurrent(path string, depth int) {
+	defer w.wg.Done()
+
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.stop:
+		return
+	}
+	children, err := w.read(path, depth)
+	<-w.sem
+
+	if err != nil {
+		w.once.Do(func() {
+			w.err = err
+			close(w.stop)
+		})
+		return
+	}
+	for _, child := range children {
+		w.wg.Add(1)
+		go w.walkConcurrent(child, depth+1)
+	}
+}
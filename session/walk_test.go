@@ -0,0 +1,78 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkShouldVisitDepthFirstInOrder(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/b", "/test/a", "/test/a/c", "/test/b/d")
+
+		var visited []string
+		err := Walk(session, "/test", func(path string, data []byte, stat *zookeeper.Stat) error {
+			visited = append(visited, path)
+			if path == "/test/b" {
+				return SkipSubtree
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error("Walk error: ", err)
+		}
+		assert.Equal(t, []string{"/test", "/test/a", "/test/a/c", "/test/b"}, visited)
+	})
+}
+
+func TestWalkShouldRespectMaxDepth(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/a/b", "/test/a/b/c")
+
+		var visited []string
+		err := Walk(session, "/test", func(path string, data []byte, stat *zookeeper.Stat) error {
+			visited = append(visited, path)
+			return nil
+		}, WithMaxDepth(2))
+		if err != nil {
+			t.Error("Walk error: ", err)
+		}
+		assert.Equal(t, []string{"/test", "/test/a", "/test/a/b"}, visited)
+	})
+}
+
+func TestWalkConcurrentShouldVisitAllNodes(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a", "/test/b", "/test/a/c", "/test/b/d")
+
+		var mu sync.Mutex
+		var visited []string
+		err := Walk(session, "/test", func(path string, data []byte, stat *zookeeper.Stat) error {
+			mu.Lock()
+			defer mu.Unlock()
+			visited = append(visited, path)
+			return nil
+		}, WithConcurrency(4))
+		if err != nil {
+			t.Error("Walk error: ", err)
+		}
+		sort.Strings(visited)
+		assert.Equal(t, []string{"/test", "/test/a", "/test/a/c", "/test/b", "/test/b/d"}, visited)
+	})
+}
+
+func TestWalkShouldStopOnError(t *testing.T) {
+	withTestStore(t, func(session *ZKSession) {
+		initializeZK(t, session, "/test", "/test/a")
+
+		boom := errors.New("boom")
+		err := Walk(session, "/test", func(path string, data []byte, stat *zookeeper.Stat) error {
+			return boom
+		})
+		assert.Equal(t, boom, err)
+	})
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// beat records that the manage loop is alive. Until is normally now, but can
+// be in the future when the loop is knowingly about to block, such as while
+// waiting out the connect jitter before redialing.
+func (s *ZKSession) beat(until time.Time) {
+	atomic.StoreInt64(&s.heartbeat, until.UnixNano())
+}
+
+func (s *ZKSession) lastBeat() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.heartbeat))
+}
+
+// supervise runs the manage loop under the watchdog configured with
+// WithWatchdog. A loop that panics is restarted; a loop that stops beating is
+// reported as SessionFailed, once for every time it does.
+func (s *ZKSession) supervise() {
+	stop := make(chan struct{})
+	defer close(stop)
+	Go(s, "watchdog", func() {
+		s.watchdog(stop)
+	})
+
+	for s.manageRecovering() {
+		s.log.Printf("gozk-recipes/session: restarting session management")
+	}
+}
+
+// manageRecovering runs the manage loop and reports whether it panicked and
+// should be restarted. In strict mode a panic fails the session instead.
+func (s *ZKSession) manageRecovering() (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Printf("gozk-recipes/session: session management panicked: %v", r)
+			if s.reportError("managing session", fmt.Errorf("panic: %v", r), true) {
+				s.fail(errors.New("session management panicked"))
+				return
+			}
+			panicked = true
+		}
+	}()
+	s.manage()
+	return false
+}
+
+func (s *ZKSession) watchdog(stop <-chan struct{}) {
+	timeout := s.opts.watchdog
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	// reported is the last beat of the wedge last reported, which isn't
+	// reported again until the loop beats past it.
+	var reported time.Time
+	for {
+		select {
+		case <-ticker.C:
+			last := s.lastBeat()
+			if !reported.IsZero() {
+				if !last.After(reported) {
+					continue
+				}
+				reported = time.Time{}
+			}
+			if stalled := time.Since(last); stalled > timeout {
+				s.log.Printf("gozk-recipes/session.SessionFailed: session management wedged for %s, session terminated", stalled)
+				s.failWedged()
+				s.logDiagnoses(s.diagnose())
+				reported = last
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// failWedged delivers SessionFailed to subscribers on behalf of a wedged
+// manage loop. If the loop is stuck delivering an event to a subscriber that
+// isn't draining its channel, the topic is locked and nothing more can be
+// delivered.
+func (s *ZKSession) failWedged() {
+	subscriptions, ok := s.sessionEvents.TrySubscribers()
+	if !ok {
+		s.log.Printf("gozk-recipes/session: event delivery is blocked by a subscriber, unable to report SessionFailed")
+		return
+	}
+
+	for _, subscriber := range subscriptions {
+		go func(subscriber chan<- ZKSessionEvent) {
+			select {
+			case subscriber <- SessionFailed:
+			case <-time.After(s.opts.watchdog):
+			}
+		}(subscriber)
+	}
+}
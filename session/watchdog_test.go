@@ -0,0 +1,67 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogShouldFailWedgedSession(t *testing.T) {
+	s := &ZKSession{opts: WithWatchdog(40 * time.Millisecond)(SessionOpts{}), log: &nullLogger{}}
+	events := make(chan ZKSessionEvent)
+	s.Subscribe(events)
+	s.beat(time.Now())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watchdog(stop)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, SessionFailed, event)
+	case <-time.After(time.Second):
+		t.Fatal("Expected SessionFailed from the watchdog")
+	}
+}
+
+func TestWatchdogShouldReportEveryWedge(t *testing.T) {
+	s := &ZKSession{opts: WithWatchdog(40 * time.Millisecond)(SessionOpts{}), log: &nullLogger{}}
+	events := make(chan ZKSessionEvent)
+	s.Subscribe(events)
+	s.beat(time.Now())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watchdog(stop)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			assert.Equal(t, SessionFailed, event)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected SessionFailed from the watchdog for wedge %d", i+1)
+		}
+		select {
+		case event := <-events:
+			t.Fatalf("Expected a single report of wedge %d, got %v", i+1, event)
+		case <-time.After(100 * time.Millisecond):
+		}
+		// The loop recovers, then wedges again.
+		s.beat(time.Now())
+	}
+}
+
+func TestWatchdogShouldToleratePausedHeartbeat(t *testing.T) {
+	s := &ZKSession{opts: WithWatchdog(20 * time.Millisecond)(SessionOpts{}), log: &nullLogger{}}
+	events := make(chan ZKSessionEvent, 1)
+	s.Subscribe(events)
+	s.beat(time.Now().Add(time.Second))
+
+	stop := make(chan struct{})
+	go s.watchdog(stop)
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+
+	assert.Len(t, events, 0)
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+)
+
+// catchUpPolls and catchUpInterval bound how long WithMonotonicReads waits for
+// a server behind the session's last zxid to catch up.
+const (
+	catchUpPolls    = 10
+	catchUpInterval = 100 * time.Millisecond
+)
+
+// zxidTracker remembers the highest zxid seen in the Stats returned to the
+// session, and the node it was seen on.
+type zxidTracker struct {
+	mu   sync.Mutex
+	zxid int64
+	path string
+}
+
+func statZxid(stat *zookeeper.Stat) int64 {
+	if stat.Pzxid() > stat.Mzxid() {
+		return stat.Pzxid()
+	}
+	return stat.Mzxid()
+}
+
+func (t *zxidTracker) observe(path string, stat *zookeeper.Stat) {
+	if stat != nil {
+		t.advance(path, statZxid(stat))
+	}
+}
+
+func (t *zxidTracker) advance(path string, zxid int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if zxid > t.zxid {
+		t.zxid = zxid
+		t.path = path
+	}
+}
+
+func (t *zxidTracker) last() (int64, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.zxid, t.path
+}
+
+// LastZxid returns the highest zxid among the Stats the session has returned:
+// every read from here on should reflect at least the state as of that zxid.
+func (s *ZKSession) LastZxid() int64 {
+	zxid, _ := s.zxids.last()
+	return zxid
+}
+
+// behind reports whether the server the session is connected to hasn't yet
+// seen LastZxid, by re-reading the node it was observed on. A node that no
+// longer exists can't tell a server that is behind from a later deletion, so
+// it counts as caught up.
+func (s *ZKSession) behind() bool {
+	zxid, path := s.zxids.last()
+	if zxid == 0 {
+		return false
+	}
+	stat, err := s.conn.Exists(path)
+	if err != nil || stat == nil {
+		return false
+	}
+	return statZxid(stat) < zxid
+}
+
+// awaitCaughtUp waits for the server the session is connected to to catch up
+// with LastZxid, as a sync would, polling behind for a bounded time. It
+// reports whether the server caught up.
+func (s *ZKSession) awaitCaughtUp() bool {
+	for i := 0; i < catchUpPolls; i++ {
+		if !s.behind() {
+			return true
+		}
+		s.beat(time.Now().Add(catchUpInterval))
+		time.Sleep(catchUpInterval)
+	}
+	return !s.behind()
+}
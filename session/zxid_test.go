@@ -0,0 +1,54 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZxidTrackerShouldKeepHighest(t *testing.T) {
+	var tracker zxidTracker
+	tracker.advance("/a", 5)
+	tracker.advance("/b", 3)
+
+	zxid, path := tracker.last()
+	assert.Equal(t, int64(5), zxid)
+	assert.Equal(t, "/a", path)
+
+	tracker.advance("/c", 7)
+	zxid, path = tracker.last()
+	assert.Equal(t, int64(7), zxid)
+	assert.Equal(t, "/c", path)
+}
+
+func TestLastZxidShouldFollowReads(t *testing.T) {
+	s, err := NewSessionWithOpts(WithZookeepers(strings.Split(zkenv.GetZooKeepers(t), ",")), WithSessionTimeout(200*time.Millisecond), WithMonotonicReads())
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := s.Set("/test", "bar", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, s.LastZxid() >= stat.Mzxid())
+	assert.False(t, s.behind())
+
+	if err := s.Reconnect(); err != nil {
+		t.Fatal("Reconnect error: ", err)
+	}
+	_, stat, err = s.Get("/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, s.LastZxid() >= stat.Mzxid())
+}
@@ -0,0 +1,206 @@
+// Package sharedvalue implements a value shared between processes through a
+// znode, with a local cache kept up to date by a watch and optimistic
+// concurrency for updates.
+package sharedvalue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/watch"
+)
+
+// ErrNotStarted is returned when using a SharedValue before Start.
+var ErrNotStarted = errors.New("shared value not started")
+
+// Listener is called with the new value and version each time the shared
+// value changes. A deleted node is reported with an empty value and version
+// -1.
+type Listener func(value string, version int)
+
+// SharedValue is a value stored in a znode and cached locally.
+type SharedValue struct {
+	session session.Session
+	path    string
+	seed    string
+	o       recipeopts.Options
+	watcher *watch.Watcher
+
+	mu        sync.RWMutex
+	value     string
+	version   int
+	started   bool
+	listeners map[*Listener]struct{}
+
+	done chan struct{}
+}
+
+// Option configures a SharedValue, with the options every recipe shares from
+// recipeopts. Updates are reported to the metrics and their failures logged.
+type Option = recipeopts.Option
+
+// New creates a SharedValue stored at path, the default base path, or fails
+// if opts are invalid. seed is the initial value written if the node doesn't
+// exist when the value is started.
+func New(s session.Session, path string, seed string, opts ...Option) (*SharedValue, error) {
+	o := recipeopts.Defaults(path)
+	if err := recipeopts.Apply(&o, opts...); err != nil {
+		return nil, err
+	}
+	return &SharedValue{
+		session:   s,
+		path:      o.BasePath,
+		seed:      seed,
+		o:         o,
+		version:   -1,
+		listeners: make(map[*Listener]struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start creates the node with the seed value if it doesn't exist, then loads
+// the current value and starts following changes to it. The cache is
+// re-synced automatically when the session reconnects.
+func (v *SharedValue) Start() error {
+	_, err := v.session.Create(v.path, v.seed, 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		return err
+	}
+
+	v.watcher = watch.New(v.session, v.path)
+	v.watcher.Start()
+	v.update(<-v.watcher.Events())
+
+	v.mu.Lock()
+	v.started = true
+	v.mu.Unlock()
+
+	session.Go(v.session, "sharedvalue", v.run)
+	return nil
+}
+
+func (v *SharedValue) run() {
+	defer close(v.done)
+	for event := range v.watcher.Events() {
+		v.update(event)
+	}
+}
+
+func (v *SharedValue) update(event watch.Event) {
+	value, version := "", -1
+	if event.Exists {
+		value, version = event.Data, event.Stat.Version()
+	}
+
+	v.mu.Lock()
+	v.value, v.version = value, version
+	listeners := make([]Listener, 0, len(v.listeners))
+	for l := range v.listeners {
+		listeners = append(listeners, *l)
+	}
+	v.mu.Unlock()
+
+	if event.Type == watch.Initial {
+		return
+	}
+	for _, l := range listeners {
+		l := l
+		session.Dispatch(v.session, "sharedvalue", v.path, func() { l(value, version) })
+	}
+}
+
+// Get returns the cached value.
+func (v *SharedValue) Get() string {
+	value, _ := v.GetVersioned()
+	return value
+}
+
+// GetVersioned returns the cached value and the node version it was read at,
+// for use with TrySetValue.
+func (v *SharedValue) GetVersioned() (string, int) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.value, v.version
+}
+
+// SetValue unconditionally replaces the shared value.
+func (v *SharedValue) SetValue(value string) (err error) {
+	if !v.isStarted() {
+		return ErrNotStarted
+	}
+	_, end := v.o.StartOperation(v.session, "sharedvalue", "set", v.path)
+	defer func() { end(err) }()
+	_, err = v.session.Set(v.path, value, -1)
+	return err
+}
+
+// TrySetValue replaces the shared value only if it is still at
+// expectedVersion, reporting whether it did. On success the local cache is
+// updated right away, without waiting for the watch.
+func (v *SharedValue) TrySetValue(expectedVersion int, value string) (ok bool, err error) {
+	if !v.isStarted() {
+		return false, ErrNotStarted
+	}
+	_, end := v.o.StartOperation(v.session, "sharedvalue", "set", v.path)
+	defer func() { end(err) }()
+
+	stat, err := v.session.Set(v.path, value, expectedVersion)
+	if zookeeper.IsError(err, zookeeper.ZBADVERSION) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	v.mu.Lock()
+	if stat.Version() > v.version {
+		v.value, v.version = value, stat.Version()
+	}
+	v.mu.Unlock()
+	return true, nil
+}
+
+// AddListener registers l to be called on every change, until the returned
+// function is called. Listeners are called one at a time, in the order
+// changes are observed, through session.Dispatch; unless the session has a
+// callback pool they are called from the value's watch loop and must not
+// block.
+func (v *SharedValue) AddListener(l Listener) (remove func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.listeners[&l] = struct{}{}
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.listeners, &l)
+	}
+}
+
+func (v *SharedValue) isStarted() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.started
+}
+
+// Close stops following the shared value. The node is left in place.
+func (v *SharedValue) Close() {
+	if !v.isStarted() {
+		return
+	}
+	v.watcher.Close()
+	<-v.done
+}
+
+// Run starts the shared value and follows it until ctx is done, closing it
+// and returning ctx.Err(), or until its watcher is closed with the session,
+// returning nil. See session.RunUntil.
+func (v *SharedValue) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, v.Start, v.done, func() error {
+		v.Close()
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package sharedvalue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newValue(t *testing.T, s session.Session) *SharedValue {
+	v, err := New(s, "/test", "seed")
+	if err != nil {
+		t.Fatal("New error: ", err)
+	}
+	return v
+}
+
+func TestNewShouldRejectInvalidOptions(t *testing.T) {
+	_, err := New(nil, "/test", "seed", recipeopts.WithTimeout(-time.Second))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestSharedValueShouldSeedAndCompareAndSet(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		if err := v.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer v.Close()
+
+		value, version := v.GetVersioned()
+		assert.Equal(t, "seed", value)
+		assert.Equal(t, 0, version)
+
+		ok, err := v.TrySetValue(version, "first")
+		if err != nil {
+			t.Fatal("TrySetValue error: ", err)
+		}
+		assert.True(t, ok)
+		assert.Equal(t, "first", v.Get())
+
+		ok, err = v.TrySetValue(version, "stale")
+		if err != nil {
+			t.Fatal("TrySetValue error: ", err)
+		}
+		assert.False(t, ok)
+	})
+}
+
+func TestSharedValueShouldNotifyListeners(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		v := newValue(t, s)
+		if err := v.Start(); err != nil {
+			t.Fatal("Start error: ", err)
+		}
+		defer v.Close()
+
+		changes := make(chan string, 1)
+		v.AddListener(func(value string, version int) {
+			changes <- value
+		})
+
+		if _, err := s.Set("/test", "external", -1); err != nil {
+			t.Fatal("Set error: ", err)
+		}
+		select {
+		case value := <-changes:
+			assert.Equal(t, "external", value)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Listener wasn't called")
+		}
+		assert.Equal(t, "external", v.Get())
+	})
+}
@@ -0,0 +1,250 @@
+// Package sink publishes changes to a ZooKeeper subtree to external systems,
+// such as message buses, with at-least-once delivery.
+//
+// A Bridge consumes the DiffStream of a cache.TreeCache and hands each change
+// to a Publisher, retrying until it succeeds. After each published change the
+// highest zxid seen is saved as a resume token, so that a restarted bridge only
+// republishes nodes modified since.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+)
+
+// Message types.
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+	// Synced is published once the initial state of the tree has been
+	// published. Nodes deleted while the bridge wasn't running are not
+	// reported, so consumers needing an exact mirror should reconcile against
+	// the nodes published since the previous Synced message.
+	Synced = "synced"
+)
+
+// ResumeToken records how far a Bridge has published. It is the highest zxid
+// of a published change.
+type ResumeToken struct {
+	Zxid int64 `json:"zxid"`
+}
+
+// Message is a single change published by a Bridge. Data is encoded as base64
+// in JSON, so that node data that isn't valid UTF-8 survives publishing.
+type Message struct {
+	Type    string      `json:"type"`
+	Path    string      `json:"path,omitempty"`
+	Data    []byte      `json:"data,omitempty"`
+	Version int         `json:"version,omitempty"`
+	Token   ResumeToken `json:"token"`
+}
+
+// Publisher delivers messages to an external system. Publish may be called
+// again with the same message after an error, or after the bridge restarts.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// TokenStore persists resume tokens between runs of a Bridge.
+type TokenStore interface {
+	Load() (ResumeToken, error)
+	Save(token ResumeToken) error
+}
+
+type writerPublisher struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterPublisher returns a Publisher writing messages to w as
+// newline-delimited JSON.
+func NewWriterPublisher(w io.Writer) Publisher {
+	return &writerPublisher{encoder: json.NewEncoder(w)}
+}
+
+func (p *writerPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.encoder.Encode(msg)
+}
+
+type options struct {
+	recipeopts.Options
+	tokens        TokenStore
+	retryInterval time.Duration
+}
+
+// Option configures a Bridge: one of the options below, or one of the options
+// every recipe shares from recipeopts. The base path is the subtree published,
+// the timeout bounds each Publish, and publishes are reported to the metrics
+// and their failures logged.
+type Option = recipeopts.Option
+
+// WithTokenStore loads the resume token from store when the bridge starts, and
+// saves it as changes are published.
+func WithTokenStore(store TokenStore) Option {
+	return recipeopts.Own(func(o *options) { o.tokens = store })
+}
+
+// WithRetryInterval sets how long to wait before retrying a failed Publish.
+func WithRetryInterval(interval time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.retryInterval = interval })
+}
+
+// Bridge publishes the changes to a subtree of a TreeCache.
+type Bridge struct {
+	cache     *cache.TreeCache
+	path      string
+	publisher Publisher
+	opts      options
+
+	token  ResumeToken
+	synced bool
+	// pending is the highest zxid seen during the initial sync, which only
+	// becomes the token once the whole tree has been published.
+	pending int64
+}
+
+// NewBridge creates a bridge publishing changes to path, the default base
+// path, and its descendants in tc, or fails if opts are invalid.
+func NewBridge(tc *cache.TreeCache, path string, publisher Publisher, opts ...Option) (*Bridge, error) {
+	o := &options{Options: recipeopts.Defaults(path), retryInterval: time.Second}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	if o.retryInterval < 0 {
+		return nil, fmt.Errorf("%w: retry interval must not be negative, got %s", recipeopts.ErrInvalidOptions, o.retryInterval)
+	}
+	return &Bridge{cache: tc, path: o.BasePath, publisher: publisher, opts: *o}, nil
+}
+
+// Run publishes changes until ctx is done, returning ctx.Err(), or until the
+// cache is closed, returning nil. Run may only be called once.
+func (b *Bridge) Run(ctx context.Context) error {
+	if b.opts.tokens != nil {
+		token, err := b.opts.tokens.Load()
+		if err != nil {
+			return err
+		}
+		b.token = token
+	}
+
+	diffs := b.cache.DiffStream(b.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case diff, ok := <-diffs:
+			if !ok {
+				return nil
+			}
+			if err := b.process(ctx, diff); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *Bridge) process(ctx context.Context, diff cache.Diff) error {
+	if _, ok := diff.(cache.NodeRejected); ok {
+		// The cache kept the node's last valid data, which was published.
+		return nil
+	}
+	msg, zxid := b.message(diff)
+	if !b.synced && msg.Type == Created && zxid <= b.token.Zxid {
+		// Already published before the bridge was restarted.
+		return nil
+	}
+
+	if !b.synced && msg.Type != Synced {
+		if zxid > b.pending {
+			b.pending = zxid
+		}
+		zxid = b.token.Zxid
+	}
+	if msg.Type == Synced {
+		b.synced = true
+		zxid = b.pending
+	}
+	if zxid > b.token.Zxid {
+		msg.Token = ResumeToken{Zxid: zxid}
+	} else {
+		msg.Token = b.token
+	}
+
+	for {
+		err := b.publish(ctx, msg)
+		if err == nil {
+			break
+		}
+
+		select {
+		case <-time.After(b.opts.retryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if msg.Token == b.token {
+		return nil
+	}
+	b.token = msg.Token
+	if b.opts.tokens != nil {
+		return b.opts.tokens.Save(b.token)
+	}
+	return nil
+}
+
+// publish hands msg to the publisher once, within the timeout.
+func (b *Bridge) publish(ctx context.Context, msg Message) error {
+	ctx, cancel := b.opts.Context(ctx)
+	defer cancel()
+	start := time.Now()
+	err := b.publisher.Publish(ctx, msg)
+	if err != nil {
+		b.opts.Logger.Printf("gozk-recipes/sink: publishing %s %s failed, retrying in %s: %v", msg.Type, msg.Path, b.opts.retryInterval, err)
+	}
+	if b.opts.Metrics != nil {
+		b.opts.Metrics.ObserveOperation("sink", "publish", time.Since(start), err)
+	}
+	return err
+}
+
+// message converts diff into a Message, returning the zxid of the change, or
+// zero if it isn't known.
+func (b *Bridge) message(diff cache.Diff) (Message, int64) {
+	switch d := diff.(type) {
+	case cache.NodeCreated:
+		msg := Message{Type: Created, Path: d.Path, Data: []byte(d.Data)}
+		if d.Stat != nil {
+			msg.Version = d.Stat.Version()
+			return msg, d.Stat.Mzxid()
+		}
+		return msg, 0
+	case cache.NodeUpdated:
+		msg := Message{Type: Updated, Path: d.Path, Data: []byte(d.New.Data)}
+		if d.New.Stat != nil {
+			msg.Version = d.New.Stat.Version()
+			return msg, d.New.Stat.Mzxid()
+		}
+		return msg, 0
+	case cache.NodeDeleted:
+		return Message{Type: Deleted, Path: d.Path}, 0
+	}
+	return Message{Type: Synced}, 0
+}
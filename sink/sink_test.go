@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/cache"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryTokens struct {
+	token ResumeToken
+	saves int
+}
+
+func (m *memoryTokens) Load() (ResumeToken, error) { return m.token, nil }
+
+func (m *memoryTokens) Save(token ResumeToken) error {
+	m.token = token
+	m.saves++
+	return nil
+}
+
+func newBridge(t *testing.T, publisher Publisher, opts ...Option) *Bridge {
+	b, err := NewBridge(nil, "/", publisher, opts...)
+	if err != nil {
+		t.Fatal("NewBridge error: ", err)
+	}
+	return b
+}
+
+func TestNewBridgeShouldRejectInvalidOptions(t *testing.T) {
+	_, err := NewBridge(nil, "/", nil, WithRetryInterval(-time.Second))
+	assert.True(t, errors.Is(err, recipeopts.ErrInvalidOptions))
+}
+
+func TestProcessShouldRetryUntilPublished(t *testing.T) {
+	attempts := 0
+	var published []Message
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("unavailable")
+		}
+		published = append(published, msg)
+		return nil
+	})
+
+	b := newBridge(t, publisher, WithRetryInterval(time.Millisecond))
+	if err := b.process(context.Background(), cache.NodeDeleted{Path: "/foo"}); err != nil {
+		t.Fatal("process error: ", err)
+	}
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []Message{{Type: Deleted, Path: "/foo"}}, published)
+}
+
+func TestProcessShouldStopRetryingWhenCancelled(t *testing.T) {
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		return errors.New("unavailable")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b := newBridge(t, publisher, WithRetryInterval(time.Millisecond))
+	err := b.process(ctx, cache.NodeDeleted{Path: "/foo"})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestProcessShouldKeepTokenUntilSynced(t *testing.T) {
+	tokens := &memoryTokens{token: ResumeToken{Zxid: 10}}
+	var buf bytes.Buffer
+
+	b := newBridge(t, NewWriterPublisher(&buf), WithTokenStore(tokens))
+	b.token = tokens.token
+
+	if err := b.process(context.Background(), cache.NodeDeleted{Path: "/foo"}); err != nil {
+		t.Fatal("process error: ", err)
+	}
+	if err := b.process(context.Background(), cache.InitialSyncDone{}); err != nil {
+		t.Fatal("process error: ", err)
+	}
+
+	assert.Equal(t, ResumeToken{Zxid: 10}, tokens.token)
+	assert.Equal(t, "{\"type\":\"deleted\",\"path\":\"/foo\",\"token\":{\"zxid\":10}}\n{\"type\":\"synced\",\"token\":{\"zxid\":10}}\n", buf.String())
+}
+
+func TestWriterPublisherShouldKeepBinaryData(t *testing.T) {
+	var buf bytes.Buffer
+	b := newBridge(t, NewWriterPublisher(&buf))
+	b.synced = true
+	data := "\xff\xfe\x00\x80spam\xc3"
+	if err := b.process(context.Background(), cache.NodeCreated{Path: "/foo", Data: data}); err != nil {
+		t.Fatal("process error: ", err)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(buf.Bytes(), &msg); err != nil {
+		t.Fatal("Unmarshal error: ", err)
+	}
+	assert.Equal(t, []byte(data), msg.Data)
+}
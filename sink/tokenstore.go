@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"encoding/json"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+type zkTokenStore struct {
+	session session.Session
+	path    string
+}
+
+// NewZKTokenStore returns a TokenStore keeping the resume token as JSON in the
+// znode at path, which is created on the first save.
+func NewZKTokenStore(s session.Session, path string) TokenStore {
+	return &zkTokenStore{session: s, path: path}
+}
+
+func (z *zkTokenStore) Load() (ResumeToken, error) {
+	var token ResumeToken
+	data, _, err := z.session.Get(z.path)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		return token, nil
+	}
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal([]byte(data), &token)
+	return token, err
+}
+
+func (z *zkTokenStore) Save(token ResumeToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	_, err = z.session.Set(z.path, string(data), -1)
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		_, err = z.session.Create(z.path, string(data), 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	}
+	return err
+}
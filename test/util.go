@@ -1,57 +1,32 @@
 package test
 
 import (
-	"fmt"
-	"os"
-	"strings"
 	"testing"
+	"time"
 
-	toxiproxy "github.com/Shopify/toxiproxy/v2/client"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test/zkenv"
 )
 
-const PROXY_PORT = "27445"
+const PROXY_PORT = zkenv.PROXY_PORT
 
-func CreateProxy(t *testing.T) *toxiproxy.Proxy {
-	url := GetToxiProxyURL(t)
-	zks := GetZooKeepers(t)
-	host := GetToxiProxyHost(t)
+var (
+	CreateProxy      = zkenv.CreateProxy
+	GetToxiProxyURL  = zkenv.GetToxiProxyURL
+	GetToxiProxyHost = zkenv.GetToxiProxyHost
+	GetZooKeepers    = zkenv.GetZooKeepers
+)
 
-	client := toxiproxy.NewClient(url)
-	proxyName := fmt.Sprintf("gozk_test_zookeeper_%s", t.Name())
-	proxy, err := client.CreateProxy(proxyName, host+":"+PROXY_PORT, zks)
+// WithSession runs f with a session to the test ensemble, from which /test
+// has been deleted, closing it once f returns.
+func WithSession(t *testing.T, f func(*session.ZKSession)) {
+	s, err := session.NewZKSession(GetZooKeepers(t), 200*time.Millisecond, nil)
 	if err != nil {
-		if strings.Contains(err.Error(), "proxy already exists") {
-			t.Logf("api error %q", err.Error())
-			proxies, err := client.Proxies()
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			proxy = proxies[proxyName]
-		} else {
-			t.Fatal("Couldn't create proxy. Is toxiproxy running? Error: ", err)
-		}
+		t.Fatal("Failed to connect to Zookeeper: ", err)
 	}
-	return proxy
-}
+	defer s.Close()
 
-func GetToxiProxyURL(t *testing.T) string {
-	if os.Getenv("TOXIPROXY_URL") == "" {
-		t.Fatal("TOXIPROXY_URL environment variable must be defined")
-	}
-	return os.Getenv("TOXIPROXY_URL")
-}
+	s.DeleteRecursive("/test")
 
-func GetToxiProxyHost(t *testing.T) string {
-	if os.Getenv("TOXIPROXY_HOST") == "" {
-		t.Fatal("TOXIPROXY_HOST environment variable must be defined")
-	}
-	return os.Getenv("TOXIPROXY_HOST")
-}
-
-func GetZooKeepers(t *testing.T) string {
-	if os.Getenv("ZOOKEEPERS") == "" {
-		t.Fatal("ZOOKEEPERS environment variable must be defined")
-	}
-	return os.Getenv("ZOOKEEPERS")
+	f(s)
 }
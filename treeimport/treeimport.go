@@ -0,0 +1,131 @@
+// Package treeimport loads a tree of znodes described in a YAML file into
+// ZooKeeper, rendering Go template placeholders in node paths and data from
+// a values file, so per-environment trees differing in a handful of
+// substitutions can share one description.
+//
+// A tree file maps node paths to their data:
+//
+//	nodes:
+//	  /config/{{.env}}/db: "host=db.{{.datacenter}}.internal"
+//	  /config/{{.env}}/flags: ""
+//
+// and a values file holds the substitutions:
+//
+//	env: production
+//	datacenter: us-east-1
+//
+// Referencing a value missing from the values file is an error.
+package treeimport
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"gopkg.in/yaml.v3"
+)
+
+// Tree is the description of the nodes to import.
+type Tree struct {
+	Nodes map[string]string `yaml:"nodes"`
+}
+
+// LoadTree reads a tree file.
+func LoadTree(path string) (Tree, error) {
+	var tree Tree
+	if err := loadYAML(path, &tree); err != nil {
+		return Tree{}, fmt.Errorf("loading tree %q: %w", path, err)
+	}
+	return tree, nil
+}
+
+// LoadValues reads a values file.
+func LoadValues(path string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := loadYAML(path, &values); err != nil {
+		return nil, fmt.Errorf("loading values %q: %w", path, err)
+	}
+	return values, nil
+}
+
+func loadYAML(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// Render substitutes values into the paths and data of tree, returning the
+// rendered nodes.
+func Render(tree Tree, values map[string]interface{}) (map[string]string, error) {
+	rendered := make(map[string]string, len(tree.Nodes))
+	for path, data := range tree.Nodes {
+		renderedPath, err := render(path, values)
+		if err != nil {
+			return nil, fmt.Errorf("rendering path %q: %w", path, err)
+		}
+		renderedData, err := render(data, values)
+		if err != nil {
+			return nil, fmt.Errorf("rendering data of %q: %w", path, err)
+		}
+		if _, ok := rendered[renderedPath]; ok {
+			return nil, fmt.Errorf("rendering path %q: %q is defined twice", path, renderedPath)
+		}
+		rendered[renderedPath] = renderedData
+	}
+	return rendered, nil
+}
+
+func render(text string, values map[string]interface{}) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Import renders tree with values and sets every node, creating it and its
+// parents as necessary. Nodes are written parents first, and the rendered
+// paths are returned in the order they were written. Nothing is written if
+// rendering fails; nodes written before a ZooKeeper error are returned along
+// with it.
+func Import(s *session.ZKSession, tree Tree, values map[string]interface{}) ([]string, error) {
+	nodes, err := Render(tree, values)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(nodes))
+	for path := range nodes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for i, path := range paths {
+		if err := s.CreateRecursiveAndSet(path, nodes[path]); err != nil {
+			return paths[:i], fmt.Errorf("importing %q: %w", path, err)
+		}
+	}
+	return paths, nil
+}
+
+// ImportFiles loads the tree and values files and imports them; see Import.
+func ImportFiles(s *session.ZKSession, treePath, valuesPath string) ([]string, error) {
+	tree, err := LoadTree(treePath)
+	if err != nil {
+		return nil, err
+	}
+	values, err := LoadValues(valuesPath)
+	if err != nil {
+		return nil, err
+	}
+	return Import(s, tree, values)
+}
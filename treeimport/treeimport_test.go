@@ -0,0 +1,65 @@
+package treeimport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var values = map[string]interface{}{"env": "production", "datacenter": "us-east-1"}
+
+func TestRenderShouldSubstituteValues(t *testing.T) {
+	tree := Tree{Nodes: map[string]string{
+		"/test/{{.env}}/db": "host=db.{{.datacenter}}.internal",
+		"/test/{{.env}}":    "",
+	}}
+
+	nodes, err := Render(tree, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"/test/production/db": "host=db.us-east-1.internal",
+		"/test/production":    "",
+	}, nodes)
+}
+
+func TestRenderShouldRejectMissingValues(t *testing.T) {
+	_, err := Render(Tree{Nodes: map[string]string{"/test/{{.region}}": ""}}, values)
+	assert.Error(t, err)
+}
+
+func TestImportFilesShouldWriteTree(t *testing.T) {
+	dir := t.TempDir()
+	treePath, valuesPath := filepath.Join(dir, "tree.yml"), filepath.Join(dir, "values.yml")
+	if err := os.WriteFile(treePath, []byte("nodes:\n  /test/{{.env}}/db: \"host=db.{{.datacenter}}.internal\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(valuesPath, []byte("env: staging\ndatacenter: us-west-2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	defer s.Close()
+	s.DeleteRecursive("/test")
+
+	paths, err := ImportFiles(s, treePath, valuesPath)
+	if err != nil {
+		t.Fatal("ImportFiles error: ", err)
+	}
+	assert.Equal(t, []string{"/test/staging/db"}, paths)
+
+	data, _, err := s.Get("/test/staging/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "host=db.us-west-2.internal", data)
+}
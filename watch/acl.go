@@ -0,0 +1,191 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultACLInterval is how often an ACLWatcher polls its node unless
+// WatchACL is given another interval.
+const DefaultACLInterval = 5 * time.Second
+
+// ACLEvent is the ACL of a watched node after a change. Stat is the node's
+// Stat as read with the ACL, whose AVersion tells the ACL's version, and
+// Epoch the session epoch it was read in.
+//
+// Previous is the event delivered before this one, with its own Previous
+// cleared, so the old and new ACLs can be compared. It is nil for Initial
+// events.
+type ACLEvent struct {
+	Type     EventType
+	Path     string
+	Exists   bool
+	ACL      []zookeeper.ACL
+	Stat     *zookeeper.Stat
+	Epoch    uint64
+	Previous *ACLEvent
+}
+
+// ACLWatcher follows the ACL of a single znode, so that security tooling can
+// find out about permission changes on critical nodes. ZooKeeper watches
+// don't fire on ACL changes, so the watcher polls the node's Stat and reads
+// the ACL again when its ACL version or creation zxid changes. Changes are
+// therefore seen within an interval rather than right away, and an ACL
+// changed and changed back in between is missed.
+type ACLWatcher struct {
+	session  session.Session
+	path     string
+	interval time.Duration
+	events   chan ACLEvent
+
+	started bool
+	last    *ACLEvent
+
+	unregister func()
+	closeOnce  sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// WatchACL creates an ACLWatcher for path, polling it every interval, or
+// DefaultACLInterval if interval isn't positive. The node doesn't need to
+// exist. The Stat poll is a cheap Exists call, so interval can be kept low.
+func WatchACL(s session.Session, path string, interval time.Duration) *ACLWatcher {
+	if interval <= 0 {
+		interval = DefaultACLInterval
+	}
+	return &ACLWatcher{
+		session:  s,
+		path:     path,
+		interval: interval,
+		events:   make(chan ACLEvent, eventBuffer),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins watching in the background. The first event delivered is
+// always Initial; after it, Created, Deleted and Recreated are delivered as
+// for a Watcher, and Changed when the node's ACL changes. The watcher is
+// closed when the session is.
+func (w *ACLWatcher) Start() {
+	w.unregister = session.RegisterCloser(w.session, session.CloserFunc(func() error {
+		w.Close()
+		return nil
+	}))
+	session.Go(w.session, "watch", w.run)
+}
+
+// Events returns the channel events are delivered on. It must be drained
+// promptly, and is closed once the watcher is closed.
+func (w *ACLWatcher) Events() <-chan ACLEvent {
+	return w.events
+}
+
+// Close stops the watcher. Closing it again has no effect.
+func (w *ACLWatcher) Close() {
+	w.closeOnce.Do(func() {
+		if w.unregister != nil {
+			w.unregister()
+		}
+		close(w.stop)
+		<-w.done
+	})
+}
+
+// Run starts the watcher and watches until ctx is done, closing it and
+// returning ctx.Err(), or until it is closed otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (w *ACLWatcher) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		w.Start()
+		return nil
+	}, w.done, func() error {
+		w.Close()
+		return nil
+	})
+}
+
+func (w *ACLWatcher) run() {
+	detach := session.Attach(w.session, "watch", w.path)
+	defer detach()
+	defer close(w.done)
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		// A failed poll is tried again at the next tick.
+		_ = w.poll()
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// poll checks the node's Stat and delivers an event if its ACL changed since
+// the last poll.
+func (w *ACLWatcher) poll() error {
+	epoch := session.EpochOf(w.session)
+	stat, err := w.session.Exists(w.path)
+	if err != nil {
+		return err
+	}
+	if w.started && !w.changed(stat) {
+		return nil
+	}
+
+	var acl []zookeeper.ACL
+	if stat != nil {
+		acl, stat, err = w.session.ACL(w.path)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			acl, stat, err = nil, nil, nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	event := ACLEvent{Path: w.path, Exists: stat != nil, ACL: acl, Stat: stat, Epoch: epoch}
+	switch {
+	case !w.started:
+		event.Type = Initial
+	case event.Exists && !w.last.Exists:
+		event.Type = Created
+	case !event.Exists && w.last.Exists:
+		event.Type = Deleted
+	case event.Exists && stat.Czxid() != w.last.Stat.Czxid():
+		event.Type = Recreated
+	case event.Exists && stat.AVersion() != w.last.Stat.AVersion():
+		event.Type = Changed
+	default:
+		return nil
+	}
+	event.Previous = w.last
+
+	w.started = true
+	last := event
+	last.Previous = nil
+	w.last = &last
+	select {
+	case w.events <- event:
+	case <-w.stop:
+	}
+	return nil
+}
+
+// changed reports whether stat, the node's current Stat or nil if it doesn't
+// exist, differs from the last one delivered in a way that can change its
+// ACL.
+func (w *ACLWatcher) changed(stat *zookeeper.Stat) bool {
+	if stat == nil || !w.last.Exists {
+		return (stat != nil) != w.last.Exists
+	}
+	return stat.Czxid() != w.last.Stat.Czxid() || stat.AVersion() != w.last.Stat.AVersion()
+}
@@ -0,0 +1,63 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func nextACLEvent(t *testing.T, events <-chan ACLEvent) ACLEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failed to receive event")
+	}
+	return ACLEvent{}
+}
+
+func TestACLWatcherShouldFollowACLChanges(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		w := WatchACL(s, "/test", 20*time.Millisecond)
+		w.Start()
+		defer w.Close()
+
+		e := nextACLEvent(t, w.Events())
+		assert.Equal(t, Initial, e.Type)
+		assert.False(t, e.Exists)
+
+		if _, err := s.Create("/test", "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+		e = nextACLEvent(t, w.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, zookeeper.WorldACL(zookeeper.PERM_ALL), e.ACL)
+
+		// Data changes don't change the ACL.
+		if _, err := s.Set("/test", "spam", -1); err != nil {
+			t.Fatal(err)
+		}
+		restricted := zookeeper.WorldACL(zookeeper.PERM_READ | zookeeper.PERM_ADMIN)
+		if err := s.SetACL("/test", restricted, -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextACLEvent(t, w.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, restricted, e.ACL)
+		assert.Equal(t, 1, e.Stat.AVersion())
+		if assert.NotNil(t, e.Previous) {
+			assert.Equal(t, zookeeper.WorldACL(zookeeper.PERM_ALL), e.Previous.ACL)
+			assert.Nil(t, e.Previous.Previous)
+		}
+
+		if err := s.Delete("/test", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextACLEvent(t, w.Events())
+		assert.Equal(t, Deleted, e.Type)
+	})
+}
@@ -0,0 +1,363 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// GlobWatcher follows every znode whose path matches a pattern, such as
+// "/config/*/features", including nodes that come to match after it starts.
+// Each matching node is followed by a Watcher, and the parents that
+// wildcards are matched against by a children watch, so new matches are
+// covered as they are created.
+type GlobWatcher struct {
+	session  session.Session
+	pattern  string
+	segments []string
+	opts     options
+	events   chan Event
+	handler  middleware.Handler[Event]
+
+	// dirs and nodes are only used by the run loop, which the goroutines
+	// following them report to on changes and nodeEvents.
+	dirs       map[string]*globDir
+	nodes      map[string]*globNode
+	changes    chan dirChange
+	nodeEvents chan nodeEvent
+
+	unregister func()
+	closeOnce  sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// globDir is a node whose children are matched against the wildcard segment
+// at level.
+type globDir struct {
+	level int
+	// initial is set if the matches of the first listing are nodes that
+	// matched when the watcher started.
+	initial  bool
+	listed   bool
+	children map[string]bool
+	stop     chan struct{}
+}
+
+// globNode is a matching node.
+type globNode struct {
+	watcher *Watcher
+	initial bool
+	exists  bool
+	last    *Event
+	stop    chan struct{}
+}
+
+type dirChange struct {
+	path     string
+	dir      *globDir
+	children []string
+}
+
+type nodeEvent struct {
+	node  *globNode
+	event Event
+}
+
+// WatchGlob creates a GlobWatcher for pattern, an absolute path whose
+// segments may use the syntax of path.Match. A wildcard only matches within a
+// segment, so "/config/*/features" matches "/config/app/features" but not
+// "/config/app/v2/features". The options apply to the watcher of each
+// matching node, except WithMiddleware, whose middlewares see the events of
+// all of them.
+func WatchGlob(s session.Session, pattern string, opts ...Option) (*GlobWatcher, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("glob pattern %q must be absolute", pattern)
+	}
+	if _, err := gopath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+	}
+
+	var o options
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	g := &GlobWatcher{
+		session:    s,
+		pattern:    pattern,
+		events:     make(chan Event, eventBuffer),
+		dirs:       make(map[string]*globDir),
+		nodes:      make(map[string]*globNode),
+		changes:    make(chan dirChange),
+		nodeEvents: make(chan nodeEvent),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if trimmed := strings.Trim(pattern, "/"); trimmed != "" {
+		g.segments = strings.Split(trimmed, "/")
+	}
+	g.handler = middleware.Chain(g.send, o.middlewares...)
+	o.middlewares = nil
+	g.opts = o
+	return g, nil
+}
+
+// Start begins watching in the background. The watcher is closed when the
+// session is.
+func (g *GlobWatcher) Start() {
+	g.unregister = session.RegisterCloser(g.session, session.CloserFunc(func() error {
+		g.Close()
+		return nil
+	}))
+	session.Go(g.session, "watch", g.run)
+}
+
+// Events returns the channel the events of every matching node are delivered
+// on, each naming its node in Path. Nodes that match when the watcher starts
+// are delivered as Initial, and nodes that come to match later as Created.
+// Matching paths without a node aren't delivered until the node is created.
+// When a parent of matching nodes is deleted, those still believed to exist
+// are delivered as Deleted. The channel must be drained promptly, and is
+// closed once the watcher is closed.
+func (g *GlobWatcher) Events() <-chan Event {
+	return g.events
+}
+
+// Close stops the watcher and the watches it set. Closing it again has no
+// effect.
+func (g *GlobWatcher) Close() {
+	g.closeOnce.Do(func() {
+		if g.unregister != nil {
+			g.unregister()
+		}
+		close(g.stop)
+		<-g.done
+	})
+}
+
+// Run starts the watcher and watches until ctx is done, closing it and
+// returning ctx.Err(), or until it is closed otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (g *GlobWatcher) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		g.Start()
+		return nil
+	}, g.done, func() error {
+		g.Close()
+		return nil
+	})
+}
+
+func (g *GlobWatcher) run() {
+	detach := session.Attach(g.session, "watch", g.pattern)
+	defer detach()
+	defer close(g.done)
+	defer close(g.events)
+	defer g.remove("/", false)
+
+	g.expand("/", 0, true)
+	for {
+		select {
+		case c := <-g.changes:
+			g.update(c)
+		case e := <-g.nodeEvents:
+			g.forward(e)
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// expand watches what matches the segments from level on under p.
+func (g *GlobWatcher) expand(p string, level int, initial bool) {
+	for ; level < len(g.segments) && !hasMeta(g.segments[level]); level++ {
+		p = join(p, g.segments[level])
+	}
+	if level == len(g.segments) {
+		g.watchNode(p, initial)
+		return
+	}
+	if _, ok := g.dirs[p]; ok {
+		return
+	}
+
+	d := &globDir{level: level, initial: initial, children: make(map[string]bool), stop: make(chan struct{})}
+	g.dirs[p] = d
+	session.Go(g.session, "watch", func() { g.list(p, d) })
+}
+
+// list sends the children of the node at p to the run loop every time they
+// change, until d is removed.
+func (g *GlobWatcher) list(p string, d *globDir) {
+	for {
+		children, _, watch, err := g.session.ChildrenW(p)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			var stat *zookeeper.Stat
+			stat, watch, err = g.session.ExistsW(p)
+			if err == nil && stat != nil {
+				// Created between the two calls; list it right away.
+				continue
+			}
+			children = nil
+		}
+		if err != nil {
+			select {
+			case <-time.After(retryInterval):
+				continue
+			case <-d.stop:
+				return
+			}
+		}
+
+		select {
+		case g.changes <- dirChange{path: p, dir: d, children: children}:
+		case <-d.stop:
+			return
+		}
+		select {
+		case <-watch:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// update follows the children of a dir that now match, and stops following
+// those that are gone.
+func (g *GlobWatcher) update(c dirChange) {
+	d := c.dir
+	if g.dirs[c.path] != d {
+		// Removed since the listing.
+		return
+	}
+	initial := d.initial && !d.listed
+	d.listed = true
+
+	current := make(map[string]bool, len(c.children))
+	var added []string
+	for _, child := range c.children {
+		if matched, _ := gopath.Match(g.segments[d.level], child); !matched {
+			continue
+		}
+		current[child] = true
+		if !d.children[child] {
+			added = append(added, child)
+		}
+	}
+	for child := range d.children {
+		if !current[child] {
+			g.remove(join(c.path, child), true)
+		}
+	}
+	d.children = current
+
+	sort.Strings(added)
+	for _, child := range added {
+		g.expand(join(c.path, child), d.level+1, initial)
+	}
+}
+
+func (g *GlobWatcher) watchNode(p string, initial bool) {
+	if _, ok := g.nodes[p]; ok {
+		return
+	}
+
+	n := &globNode{watcher: newWatcher(g.session, p, g.opts), initial: initial, stop: make(chan struct{})}
+	g.nodes[p] = n
+	n.watcher.Start()
+	session.Go(g.session, "watch", func() {
+		for event := range n.watcher.Events() {
+			select {
+			case g.nodeEvents <- nodeEvent{node: n, event: event}:
+			case <-n.stop:
+				return
+			}
+		}
+	})
+}
+
+// forward delivers an event of a matching node's watcher.
+func (g *GlobWatcher) forward(e nodeEvent) {
+	n, event := e.node, e.event
+	if g.nodes[event.Path] != n {
+		// Removed since the event.
+		return
+	}
+	if event.Type == Initial && event.Exists && !n.initial {
+		event.Type = Created
+	}
+	n.exists = event.Exists
+	if g.opts.previous {
+		last := event
+		last.Previous = nil
+		n.last = &last
+	}
+	if event.Type == Initial && !event.Exists {
+		// The path has no node yet.
+		return
+	}
+	g.handler(event)
+}
+
+// remove stops following prefix and everything under it. With deleted,
+// nodes believed to exist are delivered as Deleted, children first.
+func (g *GlobWatcher) remove(prefix string, deleted bool) {
+	for p, d := range g.dirs {
+		if under(p, prefix) {
+			close(d.stop)
+			delete(g.dirs, p)
+		}
+	}
+
+	var gone []string
+	previous := make(map[string]*Event)
+	for p, n := range g.nodes {
+		if !under(p, prefix) {
+			continue
+		}
+		close(n.stop)
+		n.watcher.Close()
+		delete(g.nodes, p)
+		if deleted && n.exists {
+			gone = append(gone, p)
+			previous[p] = n.last
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(gone)))
+	epoch := session.EpochOf(g.session)
+	for _, p := range gone {
+		g.handler(Event{Type: Deleted, Path: p, Epoch: epoch, Previous: previous[p]})
+	}
+}
+
+func (g *GlobWatcher) send(event Event) {
+	select {
+	case g.events <- event:
+	case <-g.stop:
+	}
+}
+
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
+
+func join(parent, child string) string {
+	if parent == "/" {
+		return "/" + child
+	}
+	return parent + "/" + child
+}
+
+// under reports whether p is prefix or one of its descendants.
+func under(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
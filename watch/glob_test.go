@@ -0,0 +1,74 @@
+package watch
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobWatcherShouldFollowMatchingNodes(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+		for _, node := range []string{"/test", "/test/a", "/test/a/features", "/test/b", "/test/b/other"} {
+			if _, err := s.Create(node, node, 0, acl); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		g, err := WatchGlob(s, "/test/*/features")
+		assert.NoError(t, err)
+		g.Start()
+		defer g.Close()
+
+		e := nextEvent(t, g.Events())
+		assert.Equal(t, Initial, e.Type)
+		assert.Equal(t, "/test/a/features", e.Path)
+		assert.Equal(t, "/test/a/features", e.Data)
+
+		// Nodes under a new match and new nodes under an existing one are
+		// both covered.
+		if _, err := s.Create("/test/c", "", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Create("/test/c/features", "c", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, g.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, "/test/c/features", e.Path)
+
+		if _, err := s.Create("/test/b/features", "b", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, g.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, "/test/b/features", e.Path)
+
+		if _, err := s.Set("/test/a/features", "spam", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, g.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, "spam", e.Data)
+
+		if err := s.Delete("/test/c/features", -1); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete("/test/c", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, g.Events())
+		assert.Equal(t, Deleted, e.Type)
+		assert.Equal(t, "/test/c/features", e.Path)
+	})
+}
+
+func TestWatchGlobShouldRejectInvalidPatterns(t *testing.T) {
+	_, err := WatchGlob(nil, "test/*")
+	assert.Error(t, err)
+	_, err = WatchGlob(nil, "/test/[")
+	assert.Error(t, err)
+}
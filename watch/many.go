@@ -0,0 +1,146 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// MultiWatcher follows several znodes, delivering the events of all of them
+// on a single channel.
+type MultiWatcher struct {
+	session  session.Session
+	watchers []*Watcher
+	events   chan Event
+	handler  middleware.Handler[Event]
+
+	unregister func()
+	closeOnce  sync.Once
+	forward    chan Event
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// GetAndWatchMany reads the nodes at paths and watches them from then on,
+// returning their state, by path, along with a MultiWatcher delivering every
+// change made after it. Each node is read with the same call that sets its
+// watch, so no change between the read and the watch is lost, as it can be
+// when reading first and then starting a Watcher. The nodes don't need to
+// exist. Each is read at its own point in time, so the snapshot isn't a
+// consistent view across paths; Stat.Mzxid tells the order of their last
+// changes. The options apply as with WatchGlob. Changes made before the
+// watcher is started are delivered once it is.
+func GetAndWatchMany(s session.Session, paths []string, opts ...Option) (map[string]Event, *MultiWatcher, error) {
+	var o options
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	m := &MultiWatcher{
+		session: s,
+		events:  make(chan Event, eventBuffer),
+		forward: make(chan Event),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	m.handler = middleware.Chain(m.send, o.middlewares...)
+	o.middlewares = nil
+
+	snapshot := make(map[string]Event, len(paths))
+	for _, p := range paths {
+		if _, ok := snapshot[p]; ok {
+			continue
+		}
+		w := newWatcher(s, p, o)
+		initial, err := w.prime()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", p, err)
+		}
+		snapshot[p] = initial
+		m.watchers = append(m.watchers, w)
+	}
+	return snapshot, m, nil
+}
+
+// Start begins watching in the background. The watcher is closed when the
+// session is.
+func (m *MultiWatcher) Start() {
+	m.unregister = session.RegisterCloser(m.session, session.CloserFunc(func() error {
+		m.Close()
+		return nil
+	}))
+	for _, w := range m.watchers {
+		w := w
+		w.Start()
+		session.Go(m.session, "watch", func() {
+			for event := range w.Events() {
+				select {
+				case m.forward <- event:
+				case <-m.stop:
+					return
+				}
+			}
+		})
+	}
+	session.Go(m.session, "watch", m.run)
+}
+
+// Events returns the channel the changes of every node are delivered on,
+// each naming its node in Path. It must be drained promptly, and is closed
+// once the watcher is closed.
+func (m *MultiWatcher) Events() <-chan Event {
+	return m.events
+}
+
+// Close stops the watcher. Closing it again has no effect.
+func (m *MultiWatcher) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		if m.unregister == nil {
+			// Never started.
+			close(m.events)
+			close(m.done)
+			return
+		}
+		m.unregister()
+		for _, w := range m.watchers {
+			w.Close()
+		}
+		<-m.done
+	})
+}
+
+// Run starts the watcher and watches until ctx is done, closing it and
+// returning ctx.Err(), or until it is closed otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (m *MultiWatcher) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		m.Start()
+		return nil
+	}, m.done, func() error {
+		m.Close()
+		return nil
+	})
+}
+
+func (m *MultiWatcher) run() {
+	defer close(m.done)
+	defer close(m.events)
+	for {
+		select {
+		case event := <-m.forward:
+			m.handler(event)
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *MultiWatcher) send(event Event) {
+	select {
+	case m.events <- event:
+	case <-m.stop:
+	}
+}
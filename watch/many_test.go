@@ -0,0 +1,48 @@
+package watch
+
+import (
+	"testing"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAndWatchManyShouldNotMissChangesAfterTheRead(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		acl := zookeeper.WorldACL(zookeeper.PERM_ALL)
+		if _, err := s.Create("/test", "", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Create("/test/a", "a", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot, m, err := GetAndWatchMany(s, []string{"/test/a", "/test/b"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer m.Close()
+		assert.Equal(t, Initial, snapshot["/test/a"].Type)
+		assert.Equal(t, "a", snapshot["/test/a"].Data)
+		assert.False(t, snapshot["/test/b"].Exists)
+
+		// Changed before the watcher is started.
+		if _, err := s.Set("/test/a", "spam", -1); err != nil {
+			t.Fatal(err)
+		}
+		m.Start()
+		e := nextEvent(t, m.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, "/test/a", e.Path)
+		assert.Equal(t, "spam", e.Data)
+
+		if _, err := s.Create("/test/b", "b", 0, acl); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, m.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, "/test/b", e.Path)
+	})
+}
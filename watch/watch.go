@@ -0,0 +1,416 @@
+// Package watch provides managed watches: a Watcher follows a single znode,
+// re-arming its ZooKeeper watch every time it fires or the session
+// reconnects, and delivers the node's state as a stream of events.
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// retryInterval is how long to wait before re-reading the node after an error
+// talking to ZooKeeper.
+var retryInterval = time.Second
+
+// eventBuffer is the number of events buffered for a Watcher.
+const eventBuffer = 16
+
+// EventType describes what happened to a watched node.
+type EventType int
+
+const (
+	// Initial carries the state of the node when the watcher started.
+	Initial EventType = iota
+	// Created is delivered when the node comes into existence.
+	Created
+	// Changed is delivered when the node's data changes.
+	Changed
+	// Deleted is delivered when the node is deleted.
+	Deleted
+	// Recreated is delivered when the node was deleted and created again
+	// since the last event, which the watcher tells apart from a change by
+	// the node's creation zxid. A recreated ephemeral node may belong to a
+	// different session; see Stat.EphemeralOwner.
+	Recreated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Initial:
+		return "Initial"
+	case Created:
+		return "Created"
+	case Changed:
+		return "Changed"
+	case Deleted:
+		return "Deleted"
+	case Recreated:
+		return "Recreated"
+	}
+	return "Unknown"
+}
+
+// Event is the state of a watched node after a change. Epoch is the session
+// epoch the state was read in; see session.ZKSession.Epoch.
+//
+// With WithPrevious, Previous is the event delivered before this one, with
+// its own Previous cleared, so a consumer can compare the old and new data
+// and versions without reading the node again. It is nil for Initial events
+// and without WithPrevious.
+type Event struct {
+	Type     EventType
+	Path     string
+	Exists   bool
+	Data     string
+	Stat     *zookeeper.Stat
+	Epoch    uint64
+	Previous *Event
+}
+
+// Stale describes a change the watcher missed, found by the staleness check.
+type Stale struct {
+	Path          string
+	SeenExists    bool
+	SeenVersion   int
+	ActualExists  bool
+	ActualVersion int
+}
+
+type options struct {
+	staleInterval time.Duration
+	onStale       func(Stale)
+	minInterval   time.Duration
+	previous      bool
+	middlewares   []middleware.Middleware[Event]
+}
+
+// Option configures a Watcher.
+type Option func(options) options
+
+// WithStalenessCheck polls the node's Stat every interval and compares it to
+// the last state delivered. If they differ, a notification was missed: onStale
+// is called and the node is re-read, delivering the missed change. The poll
+// is a cheap Exists call, so interval can be kept low-rate.
+func WithStalenessCheck(interval time.Duration, onStale func(Stale)) Option {
+	return func(o options) options {
+		o.staleInterval = interval
+		o.onStale = onStale
+		return o
+	}
+}
+
+// WithMinInterval limits how often the node is re-read after its watch fires
+// to once every interval. Changes made in between are coalesced: the next
+// read delivers the latest state only, so consumers of very hot nodes see at
+// most one event per interval.
+func WithMinInterval(interval time.Duration) Option {
+	return func(o options) options {
+		o.minInterval = interval
+		return o
+	}
+}
+
+// WithPrevious sets Previous on every event after the first to the state of
+// the node delivered before it. The watcher keeps the last data delivered to
+// do so.
+func WithPrevious() Option {
+	return func(o options) options {
+		o.previous = true
+		return o
+	}
+}
+
+// WithMiddleware passes events through middlewares, the first seeing each
+// event first, before they are delivered on Events. Middlewares run on the
+// watcher's goroutine, so they must not block for long. Events they drop
+// still update the watcher's view of the node, so a dropped change isn't
+// delivered again.
+func WithMiddleware(middlewares ...middleware.Middleware[Event]) Option {
+	return func(o options) options {
+		o.middlewares = append(o.middlewares, middlewares...)
+		return o
+	}
+}
+
+// Watcher follows a single znode.
+type Watcher struct {
+	session session.Session
+	path    string
+	opts    options
+	events  chan Event
+	handler middleware.Handler[Event]
+
+	started bool
+	exists  bool
+	stat    *zookeeper.Stat
+	last    *Event
+	// armed is the watch set by prime, which run waits on before reading
+	// the node again. kind is the kind of the watch last set, "data" or
+	// "exists".
+	armed <-chan zookeeper.Event
+	kind  string
+
+	unregister func()
+	closeOnce  sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// New creates a Watcher for path. The node doesn't need to exist.
+func New(s session.Session, path string, opts ...Option) *Watcher {
+	var o options
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	return newWatcher(s, path, o)
+}
+
+func newWatcher(s session.Session, path string, o options) *Watcher {
+	w := &Watcher{
+		session: s,
+		path:    path,
+		opts:    o,
+		events:  make(chan Event, eventBuffer),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	w.handler = middleware.Chain(w.send, o.middlewares...)
+	return w
+}
+
+// Start begins watching in the background. The first event delivered is
+// always Initial. The watcher is closed when the session is.
+func (w *Watcher) Start() {
+	w.unregister = session.RegisterCloser(w.session, session.CloserFunc(func() error {
+		w.Close()
+		return nil
+	}))
+	session.Go(w.session, "watch", w.run)
+}
+
+// Events returns the channel events are delivered on. It must be drained
+// promptly, and is closed once the watcher is closed.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close stops the watcher. Closing it again has no effect.
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		if w.unregister != nil {
+			w.unregister()
+		}
+		close(w.stop)
+		<-w.done
+	})
+}
+
+// Run starts the watcher and watches until ctx is done, closing it and
+// returning ctx.Err(), or until it is closed otherwise, as with its session,
+// returning nil. See session.RunUntil.
+func (w *Watcher) Run(ctx context.Context) error {
+	return session.RunUntil(ctx, func() error {
+		w.Start()
+		return nil
+	}, w.done, func() error {
+		w.Close()
+		return nil
+	})
+}
+
+func (w *Watcher) run() {
+	detach := session.Attach(w.session, "watch", w.path)
+	defer detach()
+	defer close(w.done)
+	defer close(w.events)
+
+	var stale <-chan time.Time
+	if w.opts.staleInterval > 0 {
+		ticker := time.NewTicker(w.opts.staleInterval)
+		defer ticker.Stop()
+		stale = ticker.C
+	}
+
+	var lastRead time.Time
+	watch := w.armed
+	if watch != nil {
+		lastRead = time.Now()
+	}
+	for {
+		if watch == nil {
+			if !w.throttle(lastRead) {
+				return
+			}
+			lastRead = time.Now()
+
+			var err error
+			if watch, err = w.read(); err != nil {
+				select {
+				case <-time.After(retryInterval):
+					continue
+				case <-w.stop:
+					return
+				}
+			}
+		}
+
+	wait:
+		for {
+			select {
+			case <-watch:
+				break wait
+			case <-stale:
+				if w.isStale() {
+					// The watch missed a change and may never fire, so give
+					// it up before the re-read arms another.
+					session.AbandonWatch(w.session, w.path, w.kind)
+					break wait
+				}
+			case <-w.stop:
+				session.AbandonWatch(w.session, w.path, w.kind)
+				return
+			}
+		}
+		watch = nil
+	}
+}
+
+// prime reads the node and arms its watch before the watcher is started,
+// returning the Initial event rather than delivering it. The watcher then
+// delivers the changes made after that read.
+func (w *Watcher) prime() (Event, error) {
+	var initial Event
+	handler := w.handler
+	w.handler = func(event Event) { initial = event }
+	defer func() { w.handler = handler }()
+
+	for !w.started {
+		watch, err := w.read()
+		if err != nil {
+			return Event{}, err
+		}
+		w.armed = watch
+	}
+	return initial, nil
+}
+
+// throttle waits until the minimum interval since lastRead has elapsed,
+// returning false if the watcher was closed in the meantime.
+func (w *Watcher) throttle(lastRead time.Time) bool {
+	if w.opts.minInterval <= 0 || lastRead.IsZero() {
+		return true
+	}
+	wait := time.Until(lastRead.Add(w.opts.minInterval))
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+// read fetches the node, delivers an event if its state changed since the
+// last read, and returns the re-armed watch.
+func (w *Watcher) read() (<-chan zookeeper.Event, error) {
+	epoch := session.EpochOf(w.session)
+	data, stat, watch, err := w.session.GetW(w.path)
+	exists := true
+	w.kind = "data"
+	if zookeeper.IsError(err, zookeeper.ZNONODE) {
+		exists = false
+		w.kind = "exists"
+		stat, watch, err = w.session.ExistsW(w.path)
+		if err == nil && stat != nil {
+			// Created between the two calls; read it again right away,
+			// giving up the exists watch the data watch replaces.
+			session.AbandonWatch(w.session, w.path, w.kind)
+			return closedWatch(), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	event := Event{Path: w.path, Exists: exists, Data: data, Stat: stat, Epoch: epoch}
+	switch {
+	case !w.started:
+		event.Type = Initial
+	case exists && !w.exists:
+		event.Type = Created
+	case !exists && w.exists:
+		event.Type = Deleted
+	case exists && stat.Czxid() != w.stat.Czxid():
+		event.Type = Recreated
+	case exists && stat.Version() != w.stat.Version():
+		event.Type = Changed
+	default:
+		return watch, nil
+	}
+
+	if w.opts.previous {
+		event.Previous = w.last
+		last := event
+		last.Previous = nil
+		w.last = &last
+	}
+
+	w.started = true
+	w.exists = exists
+	w.stat = stat
+	w.deliver(event)
+	return watch, nil
+}
+
+func (w *Watcher) deliver(event Event) {
+	w.handler(event)
+}
+
+func (w *Watcher) send(event Event) {
+	select {
+	case w.events <- event:
+	case <-w.stop:
+	}
+}
+
+// isStale compares the node's current Stat with the last state delivered,
+// reporting any difference to the onStale callback.
+func (w *Watcher) isStale() bool {
+	stat, err := w.session.Exists(w.path)
+	if err != nil {
+		return false
+	}
+
+	s := Stale{Path: w.path, SeenExists: w.exists, ActualExists: stat != nil}
+	if w.stat != nil {
+		s.SeenVersion = w.stat.Version()
+	}
+	if stat != nil {
+		s.ActualVersion = stat.Version()
+	}
+	recreated := w.stat != nil && stat != nil && w.stat.Czxid() != stat.Czxid()
+	if s.SeenExists == s.ActualExists && (!s.ActualExists || s.SeenVersion == s.ActualVersion) && !recreated {
+		return false
+	}
+
+	if w.opts.onStale != nil {
+		session.Dispatch(w.session, "watch", w.path, func() { w.opts.onStale(s) })
+	}
+	return true
+}
+
+func closedWatch() <-chan zookeeper.Event {
+	watch := make(chan zookeeper.Event)
+	close(watch)
+	return watch
+}
@@ -0,0 +1,222 @@
+package watch
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/faultysession"
+	"github.com/Shopify/gozk-recipes/middleware"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failed to receive event")
+	}
+	return Event{}
+}
+
+func TestWatcherShouldFollowNode(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		w := New(s, "/test")
+		w.Start()
+		defer w.Close()
+
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Initial, e.Type)
+		assert.False(t, e.Exists)
+
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, "foo", e.Data)
+
+		if _, err := s.Set("/test", "bar", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, "bar", e.Data)
+
+		if err := s.Delete("/test", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Deleted, e.Type)
+		assert.False(t, e.Exists)
+	})
+}
+
+func TestStalenessCheckShouldRecoverMissedChanges(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		stale := make(chan Stale, 1)
+		lossy := faultysession.New(s, faultysession.WithDroppedWatches(1))
+		w := New(lossy, "/test", WithStalenessCheck(50*time.Millisecond, func(s Stale) {
+			stale <- s
+		}))
+		w.Start()
+		defer w.Close()
+
+		assert.Equal(t, Initial, nextEvent(t, w.Events()).Type)
+
+		if _, err := s.Set("/test", "bar", -1); err != nil {
+			t.Fatal(err)
+		}
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, "bar", e.Data)
+
+		missed := <-stale
+		assert.Equal(t, 0, missed.SeenVersion)
+		assert.Equal(t, 1, missed.ActualVersion)
+		assert.Equal(t, uint64(1), s.Stats().AbandonedWatches, "Expected the dropped watch to be given up")
+	})
+}
+
+func TestMinIntervalShouldCoalesceChanges(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "0", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		w := New(s, "/test", WithMinInterval(500*time.Millisecond))
+		w.Start()
+		defer w.Close()
+
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Initial, e.Type)
+
+		for _, v := range []string{"1", "2", "3", "4", "5"} {
+			if _, err := s.Set("/test", v, -1); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Changed, e.Type)
+		assert.Equal(t, "5", e.Data)
+
+		select {
+		case e := <-w.Events():
+			t.Fatalf("Unexpected event %v", e)
+		case <-time.After(time.Second):
+		}
+	})
+}
+
+func TestWatcherShouldIncludePreviousState(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		w := New(s, "/test", WithPrevious())
+		w.Start()
+		defer w.Close()
+
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Initial, e.Type)
+		assert.Nil(t, e.Previous)
+
+		if _, err := s.Set("/test", "bar", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Changed, e.Type)
+		if assert.NotNil(t, e.Previous) {
+			assert.Equal(t, "foo", e.Previous.Data)
+			assert.Equal(t, 0, e.Previous.Stat.Version())
+			assert.Equal(t, 1, e.Stat.Version())
+			assert.Nil(t, e.Previous.Previous)
+		}
+
+		if err := s.Delete("/test", -1); err != nil {
+			t.Fatal(err)
+		}
+		e = nextEvent(t, w.Events())
+		assert.Equal(t, Deleted, e.Type)
+		if assert.NotNil(t, e.Previous) {
+			assert.Equal(t, "bar", e.Previous.Data)
+		}
+	})
+}
+
+func TestWatcherShouldApplyMiddleware(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		seen := make(chan EventType, 4)
+		w := New(s, "/test", WithMiddleware(
+			middleware.Tap(func(e Event) { seen <- e.Type }),
+			middleware.Filter(func(e Event) bool { return e.Type != Changed }),
+			middleware.Transform(func(e Event) Event {
+				e.Data = strings.ToUpper(e.Data)
+				return e
+			}),
+		))
+		w.Start()
+		defer w.Close()
+
+		assert.Equal(t, Initial, nextEvent(t, w.Events()).Type)
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Created, e.Type)
+		assert.Equal(t, "FOO", e.Data)
+
+		if _, err := s.Set("/test", "bar", -1); err != nil {
+			t.Fatal(err)
+		}
+		assert.Eventually(t, func() bool { return len(seen) == 3 }, 5*time.Second, 10*time.Millisecond)
+		if err := s.Delete("/test", -1); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, Deleted, nextEvent(t, w.Events()).Type)
+
+		close(seen)
+		var types []EventType
+		for typ := range seen {
+			types = append(types, typ)
+		}
+		assert.Equal(t, []EventType{Initial, Created, Changed, Deleted}, types)
+	})
+}
+
+func TestWatcherShouldDetectRecreatedNode(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		if _, err := s.Create("/test", "foo", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		w := New(s, "/test", WithMinInterval(500*time.Millisecond))
+		w.Start()
+		defer w.Close()
+
+		initial := nextEvent(t, w.Events())
+		assert.Equal(t, Initial, initial.Type)
+
+		if err := s.Delete("/test", -1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Create("/test", "bar", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+			t.Fatal(err)
+		}
+
+		e := nextEvent(t, w.Events())
+		assert.Equal(t, Recreated, e.Type)
+		assert.Equal(t, "bar", e.Data)
+		assert.NotEqual(t, initial.Stat.Czxid(), e.Stat.Czxid())
+	})
+}
@@ -0,0 +1,306 @@
+// Package writeproxy funnels coordinated writes through the leader of a group
+// of instances, so that writes every instance would otherwise make at once,
+// fighting over the same nodes, are made by one of them, one at a time.
+//
+// Instances submit requests as sequential nodes under a queue node, and the
+// instance leading the group's election hands them, in order, to a Handler
+// that makes the writes, then writes the Handler's answer back into the
+// request's node for the submitter to pick up. A leader losing its leadership
+// after handling a request but before answering it leaves the request to the
+// next leader, so requests are handled at least once and Handlers should be
+// idempotent, such as by setting counters to values rather than adding to
+// them.
+package writeproxy
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/election"
+	"github.com/Shopify/gozk-recipes/managednode"
+	"github.com/Shopify/gozk-recipes/recipeopts"
+	"github.com/Shopify/gozk-recipes/session"
+)
+
+// DefaultResponseTTL is how long answered requests are kept for their
+// submitters to read unless WithResponseTTL is given.
+const DefaultResponseTTL = time.Minute
+
+// requestPrefix prefixes the names of the queue's sequential nodes.
+const requestPrefix = "r-"
+
+// retryInterval is how long the leader waits before reading the queue again
+// after an error.
+var retryInterval = time.Second
+
+// Request is a write submitted to the leader. Kind and Payload are the
+// application's, and From is the ID of the submitting instance.
+type Request struct {
+	Kind    string `json:"kind"`
+	Payload string `json:"payload"`
+	From    string `json:"from,omitempty"`
+}
+
+// Handler makes the writes req asks for, as the leader, returning the answer
+// to pass back to its submitter. ctx is cancelled once leadership is lost.
+type Handler func(ctx context.Context, req Request) (string, error)
+
+// RemoteError is returned by Submit when the leader's Handler failed.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "writeproxy: leader failed the request: " + e.Message
+}
+
+// envelope is the data of a request node: the request, and its answer once
+// handled.
+type envelope struct {
+	Request  Request   `json:"request"`
+	Response *response `json:"response,omitempty"`
+}
+
+type response struct {
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type options struct {
+	recipeopts.Options
+	responseTTL time.Duration
+}
+
+// Option configures a Proxy: the option below, or one of the options every
+// recipe shares from recipeopts. The timeout bounds each Submit, and requests
+// are encoded with the codec, which every instance of the group must share.
+type Option = recipeopts.Option
+
+// WithResponseTTL deletes answered requests their submitter hasn't picked up
+// after ttl, such as when it gave up or went away.
+func WithResponseTTL(ttl time.Duration) Option {
+	return recipeopts.Own(func(o *options) { o.responseTTL = ttl })
+}
+
+// Proxy submits requests to the leader of its group, and handles everyone's
+// while it is the leader.
+type Proxy struct {
+	session  session.Session
+	queue    string
+	id       string
+	handle   Handler
+	o        options
+	selector *election.LeaderSelector
+}
+
+// New returns a Proxy for the group under root, the default base path,
+// taking part in its election as id and handling requests with handle while
+// leader, or fails if opts are invalid.
+func New(s session.Session, root, id string, handle Handler, opts ...Option) (*Proxy, error) {
+	o := &options{Options: recipeopts.Defaults(root), responseTTL: DefaultResponseTTL}
+	if err := recipeopts.Apply(o, opts...); err != nil {
+		return nil, err
+	}
+	p := &Proxy{session: s, queue: o.Path("queue"), id: id, handle: handle, o: *o}
+	selector, err := election.NewLeaderSelector(s, o.Path("leader"), id, p.lead)
+	if err != nil {
+		return nil, err
+	}
+	p.selector = selector
+	return p, nil
+}
+
+// Start creates the group's nodes if needed and joins its election.
+func (p *Proxy) Start() error {
+	if _, err := (managednode.Node{Path: p.queue, Parents: true, OnConflict: managednode.Adopt}).Ensure(p.session); err != nil {
+		return err
+	}
+	return p.selector.Start()
+}
+
+// Close stops handling requests and leaves the election. Requests submitted
+// through the Proxy are left to the next leader.
+func (p *Proxy) Close() error {
+	return p.selector.Close()
+}
+
+// Run starts the Proxy and takes part in the group until ctx is done,
+// returning ctx.Err(). See election.LeaderSelector.Run.
+func (p *Proxy) Run(ctx context.Context) error {
+	if _, err := (managednode.Node{Path: p.queue, Parents: true, OnConflict: managednode.Adopt}).Ensure(p.session); err != nil {
+		return err
+	}
+	return p.selector.Run(ctx)
+}
+
+// Submit queues a request for the leader, including when the Proxy is the
+// leader itself, so that every request is handled in a single order, and
+// waits for its answer. It returns the Handler's result, a *RemoteError if
+// the Handler failed, or ctx's error if ctx is done first, in which case the
+// request is withdrawn unless the leader is already handling it.
+func (p *Proxy) Submit(ctx context.Context, kind, payload string) (result string, err error) {
+	op, end := p.o.StartOperation(p.session, "writeproxy", "submit", kind)
+	defer func() { end(err) }()
+	ctx, cancel := p.o.Context(ctx)
+	defer cancel()
+
+	data, err := p.o.Codec.Marshal(envelope{Request: Request{Kind: kind, Payload: payload, From: p.id}})
+	if err != nil {
+		return "", err
+	}
+	node, err := p.session.Create(path.Join(p.queue, requestPrefix), string(data), zookeeper.SEQUENCE, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if err != nil {
+		return "", err
+	}
+	op.Step("queued as " + path.Base(node))
+
+	for {
+		data, _, watch, err := p.session.GetW(node)
+		if err != nil {
+			return "", err
+		}
+		var env envelope
+		if err := p.o.Codec.Unmarshal([]byte(data), &env); err != nil {
+			return "", err
+		}
+		if r := env.Response; r != nil {
+			if err := p.session.Delete(node, -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+				op.Step("deleting the answered request failed: " + err.Error())
+			}
+			if r.Error != "" {
+				return "", &RemoteError{Message: r.Error}
+			}
+			return r.Result, nil
+		}
+
+		select {
+		case <-watch:
+		case <-ctx.Done():
+			session.AbandonWatch(p.session, node, "data")
+			_ = p.session.Delete(node, -1)
+			return "", ctx.Err()
+		}
+	}
+}
+
+// lead handles the queued requests, in order, while leader.
+func (p *Proxy) lead(ctx context.Context) error {
+	// answered remembers when the requests answered during this leadership
+	// were answered, to clean them up.
+	answered := map[string]time.Time{}
+	sweep := time.NewTicker(p.o.responseTTL)
+	defer sweep.Stop()
+	for {
+		children, _, watch, err := p.session.ChildrenW(p.queue)
+		if err == nil {
+			session.SortBySequence(children)
+			err = p.drain(ctx, children, answered)
+		}
+		if err != nil {
+			if watch != nil {
+				session.AbandonWatch(p.session, p.queue, "children")
+			}
+			timer := time.NewTimer(retryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			}
+			continue
+		}
+
+		select {
+		case <-watch:
+		case <-sweep.C:
+			session.AbandonWatch(p.session, p.queue, "children")
+		case <-ctx.Done():
+			session.AbandonWatch(p.session, p.queue, "children")
+			return nil
+		}
+	}
+}
+
+// drain handles the unanswered requests among children, and deletes those
+// answered longer than the response TTL ago.
+func (p *Proxy) drain(ctx context.Context, children []string, answered map[string]time.Time) error {
+	present := map[string]bool{}
+	for _, name := range children {
+		if !strings.HasPrefix(name, requestPrefix) {
+			continue
+		}
+		present[name] = true
+		node := path.Join(p.queue, name)
+		if at, ok := answered[name]; ok {
+			if time.Since(at) > p.o.responseTTL {
+				if err := p.session.Delete(node, -1); err != nil && !zookeeper.IsError(err, zookeeper.ZNONODE) {
+					return err
+				}
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		data, stat, err := p.session.Get(node)
+		if zookeeper.IsError(err, zookeeper.ZNONODE) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var env envelope
+		if err := p.o.Codec.Unmarshal([]byte(data), &env); err != nil {
+			// Not a request; drop it so it doesn't block the queue.
+			_ = p.session.Delete(node, -1)
+			continue
+		}
+		if env.Response != nil {
+			answered[name] = stat.MTime()
+			continue
+		}
+
+		env.Response = p.run(ctx, env.Request)
+		if ctx.Err() != nil {
+			// Leadership was lost while handling the request, so the
+			// answer may be stale; the next leader handles it again.
+			return nil
+		}
+		reply, err := p.o.Codec.Marshal(env)
+		if err != nil {
+			return err
+		}
+		_, err = p.session.Set(node, string(reply), stat.Version())
+		switch {
+		case zookeeper.IsError(err, zookeeper.ZNONODE):
+			// The submitter gave up.
+		case err != nil:
+			return err
+		default:
+			answered[name] = time.Now()
+		}
+	}
+	for name := range answered {
+		if !present[name] {
+			delete(answered, name)
+		}
+	}
+	return nil
+}
+
+// run calls the Handler for req, turning a failure or a panic into an answer.
+func (p *Proxy) run(ctx context.Context, req Request) *response {
+	var result string
+	var err error
+	if perr := session.Protect(p.session, "writeproxy", func() { result, err = p.handle(ctx, req) }); perr != nil {
+		err = perr
+	}
+	if err != nil {
+		return &response{Error: err.Error()}
+	}
+	return &response{Result: result}
+}
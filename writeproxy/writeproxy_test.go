@@ -0,0 +1,78 @@
+package writeproxy
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyShouldSerializeRequestsThroughLeader(t *testing.T) {
+	test.WithSession(t, func(s *session.ZKSession) {
+		var mu sync.Mutex
+		total, handlers := 0, map[string]bool{}
+		handler := func(id string) Handler {
+			return func(ctx context.Context, req Request) (string, error) {
+				if req.Kind != "add" {
+					return "", errors.New("unknown request " + req.Kind)
+				}
+				n, err := strconv.Atoi(req.Payload)
+				if err != nil {
+					return "", err
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				handlers[id] = true
+				total += n
+				return strconv.Itoa(total), nil
+			}
+		}
+
+		a, err := New(s, "/test/counters", "a", handler("a"))
+		if err != nil {
+			t.Fatal("New error: ", err)
+		}
+		b, err := New(s, "/test/counters", "b", handler("b"))
+		if err != nil {
+			t.Fatal("New error: ", err)
+		}
+		for _, p := range []*Proxy{a, b} {
+			if err := p.Start(); err != nil {
+				t.Fatal("Start error: ", err)
+			}
+			defer p.Close()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			p := a
+			if i%2 == 1 {
+				p = b
+			}
+			wg.Add(1)
+			go func(p *Proxy) {
+				defer wg.Done()
+				_, err := p.Submit(ctx, "add", "1")
+				assert.NoError(t, err)
+			}(p)
+		}
+		wg.Wait()
+
+		mu.Lock()
+		assert.Equal(t, 10, total)
+		assert.Len(t, handlers, 1)
+		mu.Unlock()
+
+		_, err = b.Submit(ctx, "subtract", "1")
+		var remote *RemoteError
+		assert.True(t, errors.As(err, &remote))
+	})
+}
@@ -0,0 +1,270 @@
+// Package zkcompat exposes a session.Session through the method set of
+// github.com/go-zookeeper/zk's Conn, so code written against go-zookeeper can
+// be moved onto the gozk session used by the recipes one call site at a time.
+//
+// Only this direction is provided: gozk's Stat wraps a cgo struct that cannot
+// be constructed outside the gozk package, so a go-zookeeper connection cannot
+// be made to satisfy session.Session faithfully.
+package zkcompat
+
+import (
+	"errors"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/go-zookeeper/zk"
+)
+
+// Conn adapts a session.Session to go-zookeeper's calling conventions: data is
+// []byte, versions and flags are int32, stats are *zk.Stat, watches deliver
+// zk.Event and errors are go-zookeeper's sentinel values, so comparisons like
+// err == zk.ErrNoNode keep working.
+type Conn struct {
+	s session.Session
+}
+
+// New returns a Conn issuing every call on s. Closing the Conn closes s.
+func New(s session.Session) *Conn {
+	return &Conn{s: s}
+}
+
+// Session returns the session the Conn wraps, for handing to recipes.
+func (c *Conn) Session() session.Session {
+	return c.s
+}
+
+func (c *Conn) Get(path string) ([]byte, *zk.Stat, error) {
+	value, stat, err := c.s.Get(path)
+	if err != nil {
+		return nil, nil, Error(err)
+	}
+	return []byte(value), Stat(stat), nil
+}
+
+func (c *Conn) GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	value, stat, watch, err := c.s.GetW(path)
+	if err != nil {
+		return nil, nil, nil, Error(err)
+	}
+	return []byte(value), Stat(stat), c.forward(watch), nil
+}
+
+func (c *Conn) Set(path string, data []byte, version int32) (*zk.Stat, error) {
+	stat, err := c.s.Set(path, string(data), int(version))
+	if err != nil {
+		return nil, Error(err)
+	}
+	return Stat(stat), nil
+}
+
+func (c *Conn) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	created, err := c.s.Create(path, string(data), int(flags), FromACL(acl))
+	if err != nil {
+		return "", Error(err)
+	}
+	return created, nil
+}
+
+func (c *Conn) Delete(path string, version int32) error {
+	return Error(c.s.Delete(path, int(version)))
+}
+
+// Exists reports whether path exists. Like go-zookeeper, a missing node is not
+// an error and is reported with a zero Stat.
+func (c *Conn) Exists(path string) (bool, *zk.Stat, error) {
+	stat, err := c.s.Exists(path)
+	if err != nil {
+		return false, nil, Error(err)
+	}
+	if stat == nil {
+		return false, &zk.Stat{}, nil
+	}
+	return true, Stat(stat), nil
+}
+
+func (c *Conn) ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	stat, watch, err := c.s.ExistsW(path)
+	if err != nil {
+		return false, nil, nil, Error(err)
+	}
+	if stat == nil {
+		return false, &zk.Stat{}, c.forward(watch), nil
+	}
+	return true, Stat(stat), c.forward(watch), nil
+}
+
+func (c *Conn) Children(path string) ([]string, *zk.Stat, error) {
+	children, stat, err := c.s.Children(path)
+	if err != nil {
+		return nil, nil, Error(err)
+	}
+	return children, Stat(stat), nil
+}
+
+func (c *Conn) ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	children, stat, watch, err := c.s.ChildrenW(path)
+	if err != nil {
+		return nil, nil, nil, Error(err)
+	}
+	return children, Stat(stat), c.forward(watch), nil
+}
+
+func (c *Conn) GetACL(path string) ([]zk.ACL, *zk.Stat, error) {
+	acl, stat, err := c.s.ACL(path)
+	if err != nil {
+		return nil, nil, Error(err)
+	}
+	return ToACL(acl), Stat(stat), nil
+}
+
+// SetACL replaces the ACL of path. gozk does not return the node's stat from
+// the write, so the returned Stat comes from a follow-up read and may reflect
+// later changes.
+func (c *Conn) SetACL(path string, acl []zk.ACL, version int32) (*zk.Stat, error) {
+	if err := c.s.SetACL(path, FromACL(acl), int(version)); err != nil {
+		return nil, Error(err)
+	}
+	_, stat, err := c.s.ACL(path)
+	if err != nil {
+		return nil, Error(err)
+	}
+	return Stat(stat), nil
+}
+
+func (c *Conn) AddAuth(scheme string, auth []byte) error {
+	return Error(c.s.AddAuth(scheme, string(auth)))
+}
+
+// Close closes the underlying session.
+func (c *Conn) Close() {
+	c.s.Close()
+}
+
+// forward translates the single event gozk delivers on watch. If the session
+// closes before the watch fires, the channel receives EventNotWatching with
+// zk.ErrClosing, as go-zookeeper does.
+func (c *Conn) forward(watch <-chan zookeeper.Event) <-chan zk.Event {
+	out := make(chan zk.Event, 1)
+	session.Go(c.s, "zkcompat", func() {
+		defer close(out)
+		e, ok := <-watch
+		if !ok {
+			out <- zk.Event{Type: zk.EventNotWatching, State: zk.StateDisconnected, Err: zk.ErrClosing}
+			return
+		}
+		out <- Event(e)
+	})
+	return out
+}
+
+// Stat converts a gozk stat. A nil stat converts to nil.
+func Stat(stat *zookeeper.Stat) *zk.Stat {
+	if stat == nil {
+		return nil
+	}
+	return &zk.Stat{
+		Czxid:          stat.Czxid(),
+		Mzxid:          stat.Mzxid(),
+		Ctime:          stat.CTime().UnixMilli(),
+		Mtime:          stat.MTime().UnixMilli(),
+		Version:        int32(stat.Version()),
+		Cversion:       int32(stat.CVersion()),
+		Aversion:       int32(stat.AVersion()),
+		EphemeralOwner: stat.EphemeralOwner(),
+		DataLength:     int32(stat.DataLength()),
+		NumChildren:    int32(stat.NumChildren()),
+		Pzxid:          stat.Pzxid(),
+	}
+}
+
+// ToACL converts gozk ACL entries to go-zookeeper's.
+func ToACL(acl []zookeeper.ACL) []zk.ACL {
+	if acl == nil {
+		return nil
+	}
+	converted := make([]zk.ACL, len(acl))
+	for i, entry := range acl {
+		converted[i] = zk.ACL{Perms: int32(entry.Perms), Scheme: entry.Scheme, ID: entry.Id}
+	}
+	return converted
+}
+
+// FromACL converts go-zookeeper ACL entries to gozk's.
+func FromACL(acl []zk.ACL) []zookeeper.ACL {
+	if acl == nil {
+		return nil
+	}
+	converted := make([]zookeeper.ACL, len(acl))
+	for i, entry := range acl {
+		converted[i] = zookeeper.ACL{Perms: uint32(entry.Perms), Scheme: entry.Scheme, Id: entry.ID}
+	}
+	return converted
+}
+
+var eventTypes = map[int]zk.EventType{
+	zookeeper.EVENT_CREATED:     zk.EventNodeCreated,
+	zookeeper.EVENT_DELETED:     zk.EventNodeDeleted,
+	zookeeper.EVENT_CHANGED:     zk.EventNodeDataChanged,
+	zookeeper.EVENT_CHILD:       zk.EventNodeChildrenChanged,
+	zookeeper.EVENT_SESSION:     zk.EventSession,
+	zookeeper.EVENT_NOTWATCHING: zk.EventNotWatching,
+	zookeeper.EVENT_CLOSED:      zk.EventNotWatching,
+}
+
+var states = map[int]zk.State{
+	zookeeper.STATE_CONNECTED:       zk.StateHasSession,
+	zookeeper.STATE_CONNECTING:      zk.StateConnecting,
+	zookeeper.STATE_ASSOCIATING:     zk.StateConnecting,
+	zookeeper.STATE_EXPIRED_SESSION: zk.StateExpired,
+	zookeeper.STATE_AUTH_FAILED:     zk.StateAuthFailed,
+	zookeeper.STATE_CLOSED:          zk.StateDisconnected,
+}
+
+// Event converts a gozk watch or session event. A closed event is reported as
+// EventNotWatching with zk.ErrClosing.
+func Event(e zookeeper.Event) zk.Event {
+	converted := zk.Event{Type: zk.EventNotWatching, State: zk.StateUnknown, Path: e.Path}
+	if t, ok := eventTypes[e.Type]; ok {
+		converted.Type = t
+	}
+	if s, ok := states[e.State]; ok {
+		converted.State = s
+	}
+	if e.Type == zookeeper.EVENT_CLOSED {
+		converted.Err = zk.ErrClosing
+	}
+	return converted
+}
+
+var errorCodes = map[zookeeper.ErrorCode]error{
+	zookeeper.ZCONNECTIONLOSS:          zk.ErrConnectionClosed,
+	zookeeper.ZOPERATIONTIMEOUT:        zk.ErrConnectionClosed,
+	zookeeper.ZBADARGUMENTS:            zk.ErrBadArguments,
+	zookeeper.ZINVALIDSTATE:            zk.ErrConnectionClosed,
+	zookeeper.ZAPIERROR:                zk.ErrAPIError,
+	zookeeper.ZNONODE:                  zk.ErrNoNode,
+	zookeeper.ZNOAUTH:                  zk.ErrNoAuth,
+	zookeeper.ZBADVERSION:              zk.ErrBadVersion,
+	zookeeper.ZNOCHILDRENFOREPHEMERALS: zk.ErrNoChildrenForEphemerals,
+	zookeeper.ZNODEEXISTS:              zk.ErrNodeExists,
+	zookeeper.ZNOTEMPTY:                zk.ErrNotEmpty,
+	zookeeper.ZSESSIONEXPIRED:          zk.ErrSessionExpired,
+	zookeeper.ZINVALIDACL:              zk.ErrInvalidACL,
+	zookeeper.ZAUTHFAILED:              zk.ErrAuthFailed,
+	zookeeper.ZCLOSING:                 zk.ErrClosing,
+	zookeeper.ZNOTHING:                 zk.ErrNothing,
+	zookeeper.ZSESSIONMOVED:            zk.ErrSessionMoved,
+}
+
+// Error maps a gozk error to the matching go-zookeeper sentinel. Errors without
+// a counterpart, including the session package's own, are returned unchanged.
+func Error(err error) error {
+	var zkErr *zookeeper.Error
+	if !errors.As(err, &zkErr) {
+		return err
+	}
+	if mapped, ok := errorCodes[zkErr.Code]; ok {
+		return mapped
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package zkcompat
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	zookeeper "github.com/Shopify/gozk"
+	"github.com/Shopify/gozk-recipes/session"
+	"github.com/Shopify/gozk-recipes/test"
+	"github.com/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorShouldMapToSentinels(t *testing.T) {
+	noNode := &zookeeper.Error{Op: "get", Code: zookeeper.ZNONODE, Path: "/missing"}
+	assert.Equal(t, zk.ErrNoNode, Error(noNode))
+	assert.Equal(t, zk.ErrNoNode, Error(fmt.Errorf("wrapped: %w", noNode)))
+	assert.Equal(t, zk.ErrBadVersion, Error(&zookeeper.Error{Code: zookeeper.ZBADVERSION}))
+	assert.Equal(t, session.ErrZKSessionNotConnected, Error(session.ErrZKSessionNotConnected))
+	assert.Nil(t, Error(nil))
+}
+
+func TestEventShouldTranslateTypesAndStates(t *testing.T) {
+	e := Event(zookeeper.Event{Type: zookeeper.EVENT_CHANGED, State: zookeeper.STATE_CONNECTED, Path: "/test"})
+	assert.Equal(t, zk.Event{Type: zk.EventNodeDataChanged, State: zk.StateHasSession, Path: "/test"}, e)
+
+	closed := Event(zookeeper.Event{Type: zookeeper.EVENT_CLOSED, State: zookeeper.STATE_CLOSED})
+	assert.Equal(t, zk.EventNotWatching, closed.Type)
+	assert.Equal(t, zk.ErrClosing, closed.Err)
+}
+
+func TestACLShouldRoundTrip(t *testing.T) {
+	acl := zookeeper.WorldACL(zookeeper.PERM_READ | zookeeper.PERM_WRITE)
+	converted := ToACL(acl)
+	assert.Equal(t, zk.WorldACL(zk.PermRead|zk.PermWrite), converted)
+	assert.Equal(t, acl, FromACL(converted))
+}
+
+func TestConnShouldBehaveLikeGoZookeeper(t *testing.T) {
+	s, err := session.NewZKSession(test.GetZooKeepers(t), 200*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal("Failed to connect to Zookeeper: ", err)
+	}
+	c := New(s)
+	defer c.Close()
+	s.DeleteRecursive("/test")
+
+	_, _, err = c.Get("/test")
+	assert.Equal(t, zk.ErrNoNode, err)
+
+	ok, _, watch, err := c.ExistsW("/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ok)
+
+	if _, err := c.Create("/test", []byte("one"), 0, zk.WorldACL(zk.PermAll)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-watch:
+		assert.Equal(t, zk.EventNodeCreated, e.Type)
+		assert.Equal(t, "/test", e.Path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for watch")
+	}
+
+	_, err = c.Create("/test", nil, 0, zk.WorldACL(zk.PermAll))
+	assert.Equal(t, zk.ErrNodeExists, err)
+
+	data, stat, err := c.Get("/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte("one"), data)
+	assert.Equal(t, int32(3), stat.DataLength)
+
+	_, err = c.Set("/test", []byte("two"), stat.Version+1)
+	assert.Equal(t, zk.ErrBadVersion, err)
+	stat, err = c.Set("/test", []byte("two"), stat.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(1), stat.Version)
+
+	assert.NoError(t, c.Delete("/test", -1))
+	ok, _, err = c.Exists("/test")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}